@@ -0,0 +1,36 @@
+// Package accounts defines wallets, which sign messages and transactions for eth_sign, eth_signTransaction
+// and eth_sendTransaction. Wallets are optional, by default the node doesn't manage any keys.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+var (
+	ErrUnknownAccount = errors.New("unknown account")
+	ErrLocked         = errors.New("account is locked")
+)
+
+// Wallet signs with keys of the accounts, which it manages: local keystore or external signer
+type Wallet interface {
+	// Accounts returns addresses of all accounts of the wallet
+	Accounts(ctx context.Context) ([]common.Address, error)
+	// SignText signs TextHash of the text. V of the returned [R || S || V] signature is 27 or 28, as eth_sign returns it.
+	SignText(ctx context.Context, account common.Address, text []byte) ([]byte, error)
+	// SignTx signs the transaction with EIP-155 replay protection for the chain
+	SignTx(ctx context.Context, account common.Address, tx types.Transaction, chainID *big.Int) (types.Transaction, error)
+}
+
+// TextHash is the hash of the message prefixed with "\x19Ethereum Signed Message:\n" and its length, such hash
+// can't be the hash of a transaction, so signing it doesn't allow to take funds of the account
+func TextHash(data []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return crypto.Keccak256([]byte(msg))
+}
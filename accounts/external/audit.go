@@ -0,0 +1,62 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// AuditRecord is the request to the signer, one JSON line of the audit log
+type AuditRecord struct {
+	Time     time.Time     `json:"time"`
+	Remote   string        `json:"remote,omitempty"` // address of the RPC client, which caused the request
+	Method   string        `json:"method"`
+	Params   []interface{} `json:"params"`
+	Duration string        `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Audit appends records of all requests to the signer to the file. Passwords are never sent to the signer,
+// so they can't get into the audit log.
+type Audit struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func OpenAudit(path string) (*Audit, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("audit log of external signer: %w", err)
+	}
+	return &Audit{file: file}, nil
+}
+
+func (a *Audit) Record(r AuditRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		log.Error("Could not encode audit record of external signer", "method", r.Method, "error", err)
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, err = a.file.Write(append(line, '\n')); err != nil {
+		log.Error("Could not write audit record of external signer", "method", r.Method, "error", err)
+	}
+}
+
+func (a *Audit) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.file.Close()
+}
+
+// remoteFromContext returns address of the client of the RPC call, which is being served
+func remoteFromContext(ctx context.Context) string {
+	remote, _ := ctx.Value("remote").(string)
+	return remote
+}
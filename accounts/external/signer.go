@@ -0,0 +1,164 @@
+// Package external is the wallet, keys of which are kept by the external signer (clef or compatible)
+// and never touch the node
+package external
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Signer calls account_* methods of the signer, which asks its user to approve the requests
+type Signer struct {
+	endpoint string
+	client   *rpc.Client
+	audit    *Audit // nil if requests are not audited
+}
+
+var _ accounts.Wallet = &Signer{}
+
+// Dial connects to the signer at the endpoint: path of IPC socket, http(s):// or ws(s):// URL
+func Dial(ctx context.Context, endpoint string) (*Signer, error) {
+	client, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("external signer %s: %w", endpoint, err)
+	}
+	return NewSigner(endpoint, client), nil
+}
+
+func NewSigner(endpoint string, client *rpc.Client) *Signer {
+	return &Signer{endpoint: endpoint, client: client}
+}
+
+func (s *Signer) Endpoint() string { return s.endpoint }
+
+// SetAudit makes the signer record every request into the audit log
+func (s *Signer) SetAudit(audit *Audit) { s.audit = audit }
+
+// Call calls the method of the signer. Every call is logged, and recorded into the audit log if it's set.
+func (s *Signer) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	start := time.Now()
+	err := s.client.CallContext(ctx, result, method, args...)
+	remote := remoteFromContext(ctx)
+	if err != nil {
+		log.Info("Request to external signer failed", "method", method, "remote", remote, "error", err)
+	} else {
+		log.Info("Request to external signer", "method", method, "remote", remote)
+	}
+	if s.audit != nil {
+		r := AuditRecord{Time: start.UTC(), Remote: remote, Method: method, Params: args, Duration: time.Since(start).String()}
+		if r.Params == nil {
+			r.Params = []interface{}{}
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		s.audit.Record(r)
+	}
+	return err
+}
+
+func (s *Signer) Close() {
+	s.client.Close()
+	if s.audit != nil {
+		_ = s.audit.Close()
+	}
+}
+
+// NewAccount asks the signer to create the new account, the signer asks its user for the password
+func (s *Signer) NewAccount(ctx context.Context) (common.Address, error) {
+	var res common.Address
+	if err := s.Call(ctx, &res, "account_new"); err != nil {
+		return common.Address{}, err
+	}
+	return res, nil
+}
+
+func (s *Signer) Accounts(ctx context.Context) ([]common.Address, error) {
+	var res []common.Address
+	if err := s.Call(ctx, &res, "account_list"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Signer) SignText(ctx context.Context, account common.Address, text []byte) ([]byte, error) {
+	var res hexutil.Bytes
+	if err := s.Call(ctx, &res, "account_signData", "text/plain", account, hexutil.Bytes(text)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// txArgs are arguments of account_signTransaction
+type txArgs struct {
+	From                 common.Address    `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Gas                  hexutil.Uint64    `json:"gas"`
+	GasPrice             *hexutil.Big      `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
+	Value                hexutil.Big       `json:"value"`
+	Nonce                hexutil.Uint64    `json:"nonce"`
+	Input                hexutil.Bytes     `json:"input"`
+	AccessList           *types.AccessList `json:"accessList,omitempty"`
+	ChainID              *hexutil.Big      `json:"chainId,omitempty"`
+}
+
+// SignTx asks the signer to sign the transaction and checks that the returned transaction is signed by the account
+// and has the same hash to sign, the signer may not change the transaction behind the back of the caller
+func (s *Signer) SignTx(ctx context.Context, account common.Address, tx types.Transaction, chainID *big.Int) (types.Transaction, error) {
+	args := txArgs{
+		From:    account,
+		To:      tx.GetTo(),
+		Gas:     hexutil.Uint64(tx.GetGas()),
+		Value:   hexutil.Big(*tx.GetValue().ToBig()),
+		Nonce:   hexutil.Uint64(tx.GetNonce()),
+		Input:   tx.GetData(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GetPrice().ToBig())
+	case types.AccessListTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GetPrice().ToBig())
+		accessList := tx.GetAccessList()
+		args.AccessList = &accessList
+	case types.DynamicFeeTxType:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GetFeeCap().ToBig())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GetTip().ToBig())
+		accessList := tx.GetAccessList()
+		args.AccessList = &accessList
+	default:
+		return nil, fmt.Errorf("transaction type is not supported: %d", tx.Type())
+	}
+	var res struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := s.Call(ctx, &res, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed, err := types.UnmarshalTransactionFromBinary(res.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("external signer returned invalid transaction: %w", err)
+	}
+	if signed.SigningHash(chainID) != tx.SigningHash(chainID) {
+		return nil, fmt.Errorf("external signer returned different transaction")
+	}
+	sender, err := signed.Sender(*types.LatestSignerForChainID(chainID))
+	if err != nil {
+		return nil, fmt.Errorf("external signer returned invalid signature: %w", err)
+	}
+	if sender != account {
+		return nil, fmt.Errorf("external signer returned transaction signed by %x instead of %x", sender, account)
+	}
+	return signed, nil
+}
@@ -0,0 +1,187 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Keys are stored in files of the Web3 Secret Storage format (version 3), the same as geth and other clients do,
+// so existing keystores can be used as is.
+
+const (
+	// StandardScryptN and StandardScryptP make derivation of the key take about 1 second
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	// LightScryptN and LightScryptP are for tests and devnets
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+	version     = 3
+)
+
+var ErrDecrypt = errors.New("could not decrypt key with given password")
+
+type keyJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherParamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherParamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// EncryptKey returns content of the key file with the key encrypted by the password
+func EncryptKey(key *ecdsa.PrivateKey, password string, scryptN, scryptP int) ([]byte, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	id := make([]byte, 16)
+	for _, b := range [][]byte{salt, iv, id} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, err
+		}
+	}
+	derivedKey, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTR(derivedKey[:16], crypto.FromECDSA(key), iv)
+	if err != nil {
+		return nil, err
+	}
+	// random UUID, version 4
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return json.Marshal(keyJSON{
+		Address: hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Version: version,
+	})
+}
+
+// DecryptKey decrypts the key from content of the key file
+func DecryptKey(keyFile []byte, password string) (*ecdsa.PrivateKey, error) {
+	var k keyJSON
+	if err := json.Unmarshal(keyFile, &k); err != nil {
+		return nil, err
+	}
+	if k.Version != version {
+		return nil, fmt.Errorf("version of key file is not supported: %d", k.Version)
+	}
+	if k.Crypto.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("cipher is not supported: %s", k.Crypto.Cipher)
+	}
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	derivedKey, err := deriveKey(k.Crypto, password)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+	plainText, err := aesCTR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ToECDSA(plainText)
+	if err != nil {
+		return nil, err
+	}
+	if address := crypto.PubkeyToAddress(key.PublicKey); k.Address != "" && address != common.HexToAddress(k.Address) {
+		return nil, fmt.Errorf("key content mismatch: have account %x, want %s", address, k.Address)
+	}
+	return key, nil
+}
+
+func deriveKey(c cryptoJSON, password string) ([]byte, error) {
+	salt, err := hex.DecodeString(paramString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, err
+	}
+	dkLen := paramInt(c.KDFParams, "dklen")
+	if dkLen < 32 {
+		return nil, fmt.Errorf("derived key is too short: %d", dkLen)
+	}
+	switch c.KDF {
+	case "scrypt":
+		return scrypt.Key([]byte(password), salt, paramInt(c.KDFParams, "n"), paramInt(c.KDFParams, "r"), paramInt(c.KDFParams, "p"), dkLen)
+	case "pbkdf2":
+		if prf := paramString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("pseudorandom function is not supported: %s", prf)
+		}
+		return pbkdf2.Key([]byte(password), salt, paramInt(c.KDFParams, "c"), dkLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("key derivation function is not supported: %s", c.KDF)
+	}
+}
+
+func paramInt(params map[string]interface{}, name string) int {
+	f, _ := params[name].(float64)
+	return int(f)
+}
+
+func paramString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+func aesCTR(key, text, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(text))
+	cipher.NewCTR(block, iv).XORKeyStream(out, text)
+	return out, nil
+}
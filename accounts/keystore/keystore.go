@@ -0,0 +1,178 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// KeyStore is the wallet of keys stored in the directory, one encrypted key per file.
+// Keys must be unlocked with their passwords before signing, unlocked keys are kept in memory.
+type KeyStore struct {
+	dir      string
+	lock     sync.RWMutex
+	files    map[common.Address]string // account -> path of the key file
+	unlocked map[common.Address]*ecdsa.PrivateKey
+}
+
+var _ accounts.Wallet = &KeyStore{}
+
+// Open reads addresses of the keys in the directory. Files, which are not keys, are skipped.
+func Open(dir string) (*KeyStore, error) {
+	ks := &KeyStore{dir: dir, files: map[common.Address]string{}, unlocked: map[common.Address]*ecdsa.PrivateKey{}}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		// editors and OS put backups and metadata here
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), "~") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var k struct {
+			Address string `json:"address"`
+		}
+		if err = json.Unmarshal(content, &k); err != nil || !common.IsHexAddress(k.Address) {
+			log.Debug("Skipping file in keystore, it's not a key", "path", path)
+			continue
+		}
+		ks.files[common.HexToAddress(k.Address)] = path
+	}
+	return ks, nil
+}
+
+// StoreKey encrypts the key with the password and writes it into the new file of the keystore
+func (ks *KeyStore) StoreKey(key *ecdsa.PrivateKey, password string, scryptN, scryptP int) (common.Address, error) {
+	content, err := EncryptKey(key, password, scryptN, scryptP)
+	if err != nil {
+		return common.Address{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if _, ok := ks.files[address]; ok {
+		return common.Address{}, fmt.Errorf("account %x is already in the keystore", address)
+	}
+	path := filepath.Join(ks.dir, fmt.Sprintf("UTC--%s--%x", time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z"), address))
+	if err = ioutil.WriteFile(path, content, 0600); err != nil {
+		return common.Address{}, err
+	}
+	ks.files[address] = path
+	return address, nil
+}
+
+// Unlock decrypts the key of the account
+func (ks *KeyStore) Unlock(account common.Address, password string) error {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	path, ok := ks.files[account]
+	if !ok {
+		return accounts.ErrUnknownAccount
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := DecryptKey(content, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	ks.unlocked[account] = key
+	return nil
+}
+
+// UnlockWithPasswords unlocks every account with the first of the passwords, which decrypts its key,
+// and returns accounts, which stayed locked
+func (ks *KeyStore) UnlockWithPasswords(passwords []string) ([]common.Address, error) {
+	all, _ := ks.Accounts(context.Background())
+	var locked []common.Address
+	for _, account := range all {
+		unlocked := false
+		for _, password := range passwords {
+			err := ks.Unlock(account, password)
+			if err == nil {
+				unlocked = true
+				break
+			}
+			if !errors.Is(err, ErrDecrypt) {
+				return nil, err
+			}
+		}
+		if !unlocked {
+			locked = append(locked, account)
+		}
+	}
+	return locked, nil
+}
+
+// Lock removes the key of the account from memory
+func (ks *KeyStore) Lock(account common.Address) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	delete(ks.unlocked, account)
+}
+
+func (ks *KeyStore) Accounts(_ context.Context) ([]common.Address, error) {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	res := make([]common.Address, 0, len(ks.files))
+	for account := range ks.files {
+		res = append(res, account)
+	}
+	sort.Slice(res, func(i, j int) bool { return bytes.Compare(res[i][:], res[j][:]) < 0 })
+	return res, nil
+}
+
+func (ks *KeyStore) key(account common.Address) (*ecdsa.PrivateKey, error) {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	if key, ok := ks.unlocked[account]; ok {
+		return key, nil
+	}
+	if _, ok := ks.files[account]; ok {
+		return nil, accounts.ErrLocked
+	}
+	return nil, accounts.ErrUnknownAccount
+}
+
+func (ks *KeyStore) SignText(_ context.Context, account common.Address, text []byte) ([]byte, error) {
+	key, err := ks.key(account)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(accounts.TextHash(text), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+func (ks *KeyStore) SignTx(_ context.Context, account common.Address, tx types.Transaction, chainID *big.Int) (types.Transaction, error) {
+	key, err := ks.key(account)
+	if err != nil {
+		return nil, err
+	}
+	return types.SignTx(tx, *types.LatestSignerForChainID(chainID), key)
+}
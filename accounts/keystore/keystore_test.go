@@ -0,0 +1,93 @@
+package keystore
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptPbkdf2(t *testing.T) {
+	// test vector of Web3 Secret Storage Definition
+	keyFile := `{
+		"crypto" : {
+			"cipher" : "aes-128-ctr",
+			"cipherparams" : {"iv" : "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext" : "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf" : "pbkdf2",
+			"kdfparams" : {"c" : 262144, "dklen" : 32, "prf" : "hmac-sha256", "salt" : "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+			"mac" : "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id" : "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version" : 3
+	}`
+	key, err := DecryptKey([]byte(keyFile), "testpassword")
+	require.NoError(t, err)
+	require.Equal(t, "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d", common.Bytes2Hex(crypto.FromECDSA(key)))
+	_, err = DecryptKey([]byte(keyFile), "wrong")
+	require.Equal(t, ErrDecrypt, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	keyFile, err := EncryptKey(key, "password", LightScryptN, LightScryptP)
+	require.NoError(t, err)
+	decrypted, err := DecryptKey(keyFile, "password")
+	require.NoError(t, err)
+	require.Equal(t, crypto.FromECDSA(key), crypto.FromECDSA(decrypted))
+	_, err = DecryptKey(keyFile, "wrong")
+	require.Equal(t, ErrDecrypt, err)
+}
+
+func TestKeyStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ks, err := Open(dir)
+	require.NoError(t, err)
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	account1, err := ks.StoreKey(key1, "one", LightScryptN, LightScryptP)
+	require.NoError(t, err)
+	account2, err := ks.StoreKey(key2, "two", LightScryptN, LightScryptP)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0600))
+
+	ks, err = Open(dir)
+	require.NoError(t, err)
+	all, err := ks.Accounts(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []common.Address{account1, account2}, all)
+
+	locked, err := ks.UnlockWithPasswords([]string{"zero", "one"})
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{account2}, locked)
+	_, err = ks.SignText(ctx, account2, []byte("hello"))
+	require.Equal(t, accounts.ErrLocked, err)
+	_, err = ks.SignText(ctx, common.Address{1}, []byte("hello"))
+	require.Equal(t, accounts.ErrUnknownAccount, err)
+
+	sig, err := ks.SignText(ctx, account1, []byte("hello"))
+	require.NoError(t, err)
+	require.Contains(t, []byte{27, 28}, sig[crypto.RecoveryIDOffset])
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), sig)
+	require.NoError(t, err)
+	require.Equal(t, account1, crypto.PubkeyToAddress(*pub))
+
+	chainID := big.NewInt(1337)
+	tx, err := ks.SignTx(ctx, account1, types.NewTransaction(0, common.Address{1}, uint256.NewInt(1), 21000, uint256.NewInt(1), nil), chainID)
+	require.NoError(t, err)
+	sender, err := tx.Sender(*types.LatestSignerForChainID(chainID))
+	require.NoError(t, err)
+	require.Equal(t, account1, sender)
+	require.True(t, tx.Protected())
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/common/debug"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/internal/flags"
 	"github.com/ledgerwatch/erigon/params"
 	erigoncli "github.com/ledgerwatch/erigon/turbo/cli"
 	"github.com/ledgerwatch/erigon/turbo/node"
@@ -20,7 +22,7 @@ import (
 func main() {
 	defer debug.LogPanic()
 	app := erigoncli.MakeApp(runErigon, erigoncli.DefaultFlags)
-	if err := app.Run(os.Args); err != nil {
+	if err := app.Run(os.Args); err != nil && !errors.Is(err, flags.ErrEffectiveConfigPrinted) {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/ledgerwatch/erigon/internal/flags"
 	erigoncli "github.com/ledgerwatch/erigon/turbo/cli"
 
 	"github.com/urfave/cli"
@@ -26,7 +28,7 @@ func main() {
 	app := erigoncli.MakeApp(runErigon,
 		append(erigoncli.DefaultFlags, flag), // always use DefaultFlags, but add a new one in the end.
 	)
-	if err := app.Run(os.Args); err != nil {
+	if err := app.Run(os.Args); err != nil && !errors.Is(err, flags.ErrEffectiveConfigPrinted) {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -27,6 +27,9 @@ var (
 	pruneH, pruneR, pruneT, pruneC uint64
 	experiments                    []string
 	chain                          string // Which chain to use (mainnet, ropsten, rinkeby, goerli, etc.)
+	privateApiAddr                 string
+	adminToken                     string
+	bucketAction                   string
 )
 
 func must(err error) {
@@ -128,3 +131,11 @@ func withTxTrace(cmd *cobra.Command) {
 func withChain(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&chain, "chain", "", "pick a chain to assume (mainnet, ropsten, etc.)")
 }
+
+func withPrivateApiAddr(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&privateApiAddr, "private.api.addr", "127.0.0.1:9090", "address of the private api of Erigon")
+}
+
+func withAdminToken(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&adminToken, "private.api.admin.token", "", "admin token of the private api of Erigon, see --private.api.admin.token of Erigon")
+}
@@ -9,10 +9,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	mdbx2 "github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedb"
 	"github.com/ledgerwatch/log/v3"
 	"github.com/spf13/cobra"
 	"github.com/torquem-ch/mdbx-go/mdbx"
@@ -100,6 +103,40 @@ var cmdFToMdbx = &cobra.Command{
 	},
 }
 
+var cmdRemoteToMdbx = &cobra.Command{
+	Use:   "remote_to_mdbx",
+	Short: "copy comma separated '--bucket' from Erigon at '--private.api.addr' to '--chaindata.to'",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, _ := utils.RootContext()
+		logger := log.New()
+		err := remoteToMdbx(ctx, logger, privateApiAddr, toChaindata, strings.Split(bucket, ","))
+		if err != nil {
+			log.Error(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var cmdRemoteBucket = &cobra.Command{
+	Use:   "remote_bucket",
+	Short: "create, clear or drop ('--action') comma separated auxiliary '--bucket' of Erigon at '--private.api.addr', or list tables ('--action=list')",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, _ := utils.RootContext()
+		logger := log.New()
+		var buckets []string
+		if bucket != "" {
+			buckets = strings.Split(bucket, ",")
+		}
+		err := remoteBucket(ctx, logger, privateApiAddr, adminToken, bucketAction, buckets)
+		if err != nil {
+			log.Error(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
 func init() {
 	withDatadir(cmdCompareBucket)
 	withReferenceChaindata(cmdCompareBucket)
@@ -124,6 +161,78 @@ func init() {
 	withBucket(cmdFToMdbx)
 
 	rootCmd.AddCommand(cmdFToMdbx)
+
+	withPrivateApiAddr(cmdRemoteToMdbx)
+	withToChaindata(cmdRemoteToMdbx)
+	withBucket(cmdRemoteToMdbx)
+
+	rootCmd.AddCommand(cmdRemoteToMdbx)
+
+	withPrivateApiAddr(cmdRemoteBucket)
+	withAdminToken(cmdRemoteBucket)
+	withBucket(cmdRemoteBucket)
+	cmdRemoteBucket.Flags().StringVar(&bucketAction, "action", "list", "create, clear, drop or list")
+
+	rootCmd.AddCommand(cmdRemoteBucket)
+}
+
+func remoteToMdbx(ctx context.Context, logger log.Logger, addr, to string, buckets []string) error {
+	src, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), logger).Path(addr).Open("", "", "")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if !src.EnsureVersionCompatibility() {
+		return fmt.Errorf("incompatible version of Erigon at %s", addr)
+	}
+	for _, name := range buckets {
+		copied, err := src.CloneBucket(ctx, name, to)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		log.Info("Copied bucket", "bucket", name, "pairs", copied)
+	}
+	return nil
+}
+
+func remoteBucket(ctx context.Context, logger log.Logger, addr, token, action string, buckets []string) error {
+	db, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), logger).Path(addr).WithAdminToken(token).Open("", "", "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if !db.EnsureVersionCompatibility() {
+		return fmt.Errorf("incompatible version of Erigon at %s", addr)
+	}
+	migrator := db.Migrator(ctx)
+	if action == "list" {
+		names, err := migrator.ListBuckets()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	var do func(string) error
+	switch action {
+	case "create":
+		do = migrator.CreateBucket
+	case "clear":
+		do = migrator.ClearBucket
+	case "drop":
+		do = migrator.DropBucket
+	default:
+		return fmt.Errorf("unknown action %q, expected create, clear, drop or list", action)
+	}
+	for _, name := range buckets {
+		if err := do(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		log.Info("Done", "action", action, "bucket", name)
+	}
+	return nil
 }
 
 func compareStates(ctx context.Context, chaindata string, referenceChaindata string) error {
@@ -11,6 +11,7 @@ import (
 	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
 	"github.com/ledgerwatch/log/v3"
 	"github.com/spf13/cobra"
@@ -65,6 +66,12 @@ func resetState(db kv.RwDB, logger log.Logger, ctx context.Context) error {
 	if err := db.Update(ctx, resetTxLookup); err != nil {
 		return err
 	}
+	if err := db.Update(ctx, resetFeeSeries); err != nil {
+		return err
+	}
+	if err := db.Update(ctx, resetDataGasStats); err != nil {
+		return err
+	}
 	if err := db.Update(ctx, resetTxPool); err != nil {
 		return err
 	}
@@ -135,6 +142,9 @@ func resetExec(tx kv.RwTx, g *core.Genesis) error {
 	if err := tx.ClearBucket(kv.CallTraceSet); err != nil {
 		return err
 	}
+	if err := tx.ClearBucket(ethdb.AddressActivity); err != nil {
+		return err
+	}
 	if err := tx.ClearBucket(kv.Epoch); err != nil {
 		return err
 	}
@@ -223,6 +233,32 @@ func resetTxLookup(tx kv.RwTx) error {
 	return nil
 }
 
+func resetFeeSeries(tx kv.RwTx) error {
+	if err := tx.ClearBucket(ethdb.FeeEpoch); err != nil {
+		return err
+	}
+	if err := stages.SaveStageProgress(tx, stages.FeeSeries, 0); err != nil {
+		return err
+	}
+	if err := stages.SaveStagePruneProgress(tx, stages.FeeSeries, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+func resetDataGasStats(tx kv.RwTx) error {
+	if err := tx.ClearBucket(ethdb.DataGasStats); err != nil {
+		return err
+	}
+	if err := stages.SaveStageProgress(tx, stages.DataGasStats, 0); err != nil {
+		return err
+	}
+	if err := stages.SaveStagePruneProgress(tx, stages.DataGasStats, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
 func resetTxPool(tx kv.RwTx) error {
 	if err := stages.SaveStageProgress(tx, stages.TxPool, 0); err != nil {
 		return err
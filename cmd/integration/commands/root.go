@@ -6,6 +6,7 @@ import (
 	"github.com/ledgerwatch/erigon-lib/kv"
 	kv2 "github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/internal/debug"
 	"github.com/ledgerwatch/erigon/migrations"
 	"github.com/ledgerwatch/log/v3"
@@ -60,6 +61,9 @@ func openDB(path string, logger log.Logger, applyMigrations bool) kv.RwDB {
 
 func openKV(label kv.Label, logger log.Logger, path string, exclusive bool) kv.RwDB {
 	opts := kv2.NewMDBX(logger).Path(path).Label(label)
+	if label == kv.ChainDB {
+		opts = opts.WithTablessCfg(ethdb.WithErigonTables)
+	}
 	if exclusive {
 		opts = opts.Exclusive()
 	}
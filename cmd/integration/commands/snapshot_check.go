@@ -13,6 +13,7 @@ import (
 	kv2 "github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
@@ -247,7 +248,7 @@ func snapshotCheck(ctx context.Context, db kv.RwDB, isNew bool, tmpDir string) (
 		log.Info("Stage4", "progress", stage4.BlockNumber)
 
 		err = stagedsync.SpawnExecuteBlocksStage(stage4, sync, tx, blockNumber, ctx,
-			stagedsync.StageExecuteBlocksCfg(db, pm, batchSize, nil, chainConfig, engine, vmConfig, nil, false, tmpDir),
+			stagedsync.StageExecuteBlocksCfg(db, pm, batchSize, ethconfig.Defaults.Commit, nil, chainConfig, engine, vmConfig, nil, nil, nil, false, false, tmpDir),
 			false)
 		if err != nil {
 			return fmt.Errorf("execution err %w", err)
@@ -185,6 +185,38 @@ var cmdStageTxLookup = &cobra.Command{
 		return nil
 	},
 }
+var cmdStageFeeSeries = &cobra.Command{
+	Use:   "stage_fee_series",
+	Short: "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, _ := utils.RootContext()
+		logger := log.New()
+		db := openDB(chaindata, logger, true)
+		defer db.Close()
+
+		if err := stageFeeSeries(db, ctx); err != nil {
+			log.Error("Error", "err", err)
+			return err
+		}
+		return nil
+	},
+}
+var cmdStageDataGasStats = &cobra.Command{
+	Use:   "stage_data_gas_stats",
+	Short: "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, _ := utils.RootContext()
+		logger := log.New()
+		db := openDB(chaindata, logger, true)
+		defer db.Close()
+
+		if err := stageDataGasStats(db, ctx); err != nil {
+			log.Error("Error", "err", err)
+			return err
+		}
+		return nil
+	},
+}
 var cmdPrintStages = &cobra.Command{
 	Use:   "print_stages",
 	Short: "",
@@ -343,6 +375,20 @@ func init() {
 
 	rootCmd.AddCommand(cmdStageTxLookup)
 
+	withReset(cmdStageFeeSeries)
+	withUnwind(cmdStageFeeSeries)
+	withDatadir(cmdStageFeeSeries)
+	withChain(cmdStageFeeSeries)
+
+	rootCmd.AddCommand(cmdStageFeeSeries)
+
+	withReset(cmdStageDataGasStats)
+	withUnwind(cmdStageDataGasStats)
+	withDatadir(cmdStageDataGasStats)
+	withChain(cmdStageDataGasStats)
+
+	rootCmd.AddCommand(cmdStageDataGasStats)
+
 	withDatadir(cmdPrintMigrations)
 	rootCmd.AddCommand(cmdPrintMigrations)
 
@@ -458,7 +504,7 @@ func stageExec(db kv.RwDB, ctx context.Context) error {
 		pm.TxIndex = prune.Distance(s.BlockNumber - pruneTo)
 	}
 
-	cfg := stagedsync.StageExecuteBlocksCfg(db, pm, batchSize, nil, chainConfig, engine, vmConfig, nil, false, tmpDBPath)
+	cfg := stagedsync.StageExecuteBlocksCfg(db, pm, batchSize, ethconfig.Defaults.Commit, nil, chainConfig, engine, vmConfig, nil, nil, nil, false, false, tmpDBPath)
 	if unwind > 0 {
 		u := sync.NewUnwindState(stages.Execution, s.BlockNumber-unwind, s.BlockNumber)
 		err := stagedsync.UnwindExecutionStage(u, s, nil, ctx, cfg, false)
@@ -823,6 +869,70 @@ func stageTxLookup(db kv.RwDB, ctx context.Context) error {
 	return tx.Commit()
 }
 
+func stageFeeSeries(db kv.RwDB, ctx context.Context) error {
+	_, _, _, _, _, sync, _, _ := newSync(ctx, db, nil)
+
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reset {
+		if err = resetFeeSeries(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	s := stage(sync, tx, nil, stages.FeeSeries)
+	log.Info("Stage", "name", s.ID, "progress", s.BlockNumber)
+
+	cfg := stagedsync.StageFeeSeriesCfg(db)
+	if unwind > 0 {
+		u := sync.NewUnwindState(stages.FeeSeries, s.BlockNumber-unwind, s.BlockNumber)
+		if err = stagedsync.UnwindFeeSeries(u, s, tx, cfg, ctx); err != nil {
+			return err
+		}
+	} else {
+		if err = stagedsync.SpawnFeeSeries(s, tx, cfg, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func stageDataGasStats(db kv.RwDB, ctx context.Context) error {
+	_, _, chainConfig, _, _, sync, _, _ := newSync(ctx, db, nil)
+
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reset {
+		if err = resetDataGasStats(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	s := stage(sync, tx, nil, stages.DataGasStats)
+	log.Info("Stage", "name", s.ID, "progress", s.BlockNumber)
+
+	cfg := stagedsync.StageDataGasStatsCfg(db, chainConfig)
+	if unwind > 0 {
+		u := sync.NewUnwindState(stages.DataGasStats, s.BlockNumber-unwind, s.BlockNumber)
+		if err = stagedsync.UnwindDataGasStats(u, s, tx, cfg, ctx); err != nil {
+			return err
+		}
+	} else {
+		if err = stagedsync.SpawnDataGasStats(s, tx, cfg, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func printAllStages(db kv.RoDB, ctx context.Context) error {
 	return db.View(ctx, func(tx kv.Tx) error { return printStages(tx) })
 }
@@ -918,7 +1028,7 @@ func newSync(ctx context.Context, db kv.RwDB, miningConfig *params.MiningConfig)
 	must(batchSize.UnmarshalText([]byte(batchSizeStr)))
 
 	blockDownloaderWindow := 65536
-	downloadServer, err := download.NewControlServer(db, "", chainConfig, genesisBlock.Hash(), engine, 1, nil, blockDownloaderWindow)
+	downloadServer, err := download.NewControlServer(db, "", chainConfig, genesisBlock.Hash(), engine, 1, nil, blockDownloaderWindow, download.ServingLimits{})
 	if err != nil {
 		panic(err)
 	}
@@ -946,7 +1056,7 @@ func newSync(ctx context.Context, db kv.RwDB, miningConfig *params.MiningConfig)
 		tmpdir,
 		txPool,
 		txPoolP2PServer,
-		nil, nil, nil,
+		nil, nil, nil, nil,
 	)
 	if err != nil {
 		panic(err)
@@ -3,49 +3,133 @@ package cli
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
 	"path"
+	"strings"
 	"time"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	kv2 "github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/services"
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/headlag"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
 	"github.com/ledgerwatch/erigon/ethdb/remotedb"
-	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/ethdb/replication"
 	"github.com/ledgerwatch/erigon/internal/debug"
+	"github.com/ledgerwatch/erigon/internal/flags"
 	"github.com/ledgerwatch/erigon/node"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
 	"github.com/ledgerwatch/log/v3"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 type Flags struct {
 	PrivateApiAddr       string
-	SingleNodeMode       bool // Erigon's database can be read by separated processes on same machine - in read-only mode - with full support of transactions. It will share same "OS PageCache" with Erigon process.
+	PrivateApiCacheSize  int           // Entries of the cache of values read from remote DB, 0 - disabled
+	PrivateApiCacheTTL   time.Duration // Lifetime of entries of the cache, 0 - until the next state change
+	PrivateApiReconnect  time.Duration // Wait so long for Erigon to re-establish transactions after lost connection, 0 - disabled
+	PrivateApiCompress   string        // Compression of the remote DB traffic: snappy, zstd or "" - disabled
+	PrivateApiMetadata   []string      // key=value pairs attached to every call to Erigon, f.e. auth tokens of a proxy
+	PrivateApiDial       time.Duration // Of the first connection to Erigon
+	PrivateApiMaxRecvMsg string        // Size limit of replies of Erigon, f.e. 15MB
+	PrivateApiMaxSendMsg string        // Size limit of requests to Erigon, "" - no limit
+	PrivateApiWindow     string        // Initial flow control window of streams to Erigon, "" - dynamic
+	PrivateApiConnWindow string        // Initial flow control window of connections to Erigon, "" - dynamic
+	PrivateApiKeepalive  time.Duration // Ping connections to Erigon idle for so long, 0 - no pings
+	PrivateApiAckTimeout time.Duration // Reconnect, if a ping is not acknowledged in time
+	PrivateApiShards     int           // Streams to Erigon per transaction, cursors are spread over them
+	PrivateApiPrefetch   int           // Pairs read ahead by one round trip of iterators, <= 1 - disabled
+	PrivateApiReplicas   []string      // Addresses of other Erigon nodes, which back --private.api.addr
+	PrivateApiFailover   string        // Policy of choosing among Erigon nodes: priority or least-loaded
+	PrivateApiMaxLag     uint64        // Erigon nodes so many blocks behind the best one are not used, 0 - lag is not checked
+	SingleNodeMode       bool          // Erigon's database can be read by separated processes on same machine - in read-only mode - with full support of transactions. It will share same "OS PageCache" with Erigon process.
 	Datadir              string
 	Chaindata            string
-	SnapshotDir          string
+	SnapshotDir          string // Headers of old blocks, moved into snapshots by Erigon with --snapshot.layout
 	SnapshotMode         string
 	HttpListenAddress    string
 	TLSCertfile          string
 	TLSCACert            string
 	TLSKeyFile           string
+	TLSVerify            bool   // Verify the certificate of Erigon by the CA certificate, including the name of the server
+	TLSServerName        string // Name of the server expected in the certificate of Erigon instead of the host of --private.api.addr
 	HttpPort             int
 	HttpCORSDomain       []string
 	HttpVirtualHost      []string
 	HttpCompression      bool
 	API                  []string
 	Gascap               uint64
+	EVMMaxMemoryMB       uint64 // Limit of EVM memory of one request, separate from consensus limits
+	EVMMaxCallDepth      int
 	MaxTraces            uint64
+	LogsMaxResults       int                        // eth_getLogs returns logs by pages of this size, 0 - no limit
+	LogsMaxRange         uint64                     // eth_getLogs reads so many blocks by one call, 0 - no limit
+	LogsMaxTime          time.Duration              // eth_getLogs stops reading blocks after it, 0 - no limit
+	StateCache           state.SharedCacheConfig    // Cache of the latest state for calls
+	StateProfile         state.AccessProfilerConfig // Sampling of state reads by calls and traces
 	WebsocketEnabled     bool
 	WebsocketCompression bool
 	RpcAllowListFilePath string
+	RpcListenersFile     string // JSON list of additional listeners, each with own API namespaces, keys and rate limits
 	RpcBatchConcurrency  uint
-	TraceCompatibility   bool // Bug for bug compatibility for trace_ routines with OpenEthereum
+	RpcBatchLimit        int      // Calls of one batch request, bigger batches are refused, 0 - no limit
+	RpcBatchGasCap       uint64   // Gas of all eth_call of one batch request, 0 - no limit
+	RpcConcurrency       int      // Limit of concurrently executed calls, above it interactive calls are started before batch ones
+	RpcBatchMethods      []string // Methods of the batch traffic class
+	RpcBatchKeys         []string // API keys, calls with which are always of the batch class
+	RpcInteractiveKeys   []string // API keys, calls with which are always of the interactive class
+	RpcRateLimits        []string // method=N/s - rates of calls of methods by one client
+	RpcQuotas            []string // method=N - calls of methods by one client per RpcQuotaPeriod
+	RpcQuotaPeriod       time.Duration
+	RpcRateLimitBy       string   // Clients of rate limits are identified by: ip or key
+	RpcRateLimitClients  int      // Limits of so many recent clients are tracked
+	TraceCompatibility   bool     // Bug for bug compatibility for trace_ routines with OpenEthereum
+	ReplicaDir           string   // Local read replica of Erigon's database, maintained by streaming changes from Erigon
+	HistoryFilesDir      string   // History of old blocks, moved out of the database by Erigon with --history.files
+	LogArchiveDir        string   // Logs of old blocks, archived by Erigon with --logs.archive
+	WalletKeystore       string   // Directory of encrypted keys, used by eth_sign and eth_sendTransaction
+	WalletPasswordFile   string   // Passwords of the keys in the keystore, one per line
+	WalletSigner         string   // External signer, which signs instead of the keystore
+	WalletSignerAudit    string   // File, to which requests to the external signer are appended
+	AllowInsecureUnlock  bool     // Unlock keys of the keystore even if the wallet namespace is served on other interfaces than loopback
+	TxMonitorBlocks      uint64   // Submitted transactions, which are not mined after so many blocks, are reported
+	TxBroadcastEndpoints []string // Submitted transactions are also sent to these JSON-RPC endpoints
+	TxBroadcastRetries   int      // Of failed sends to a broadcast endpoint
+	TxPoolGlobalSlots    uint64   // Pending slots of the txpool, its fullness is measured against them
+	HeadLag              uint64   // Serve the head so many blocks behind the real one
+	BuildBlockKeys       []string // API keys, calls with which may use erigon_buildBlock
+
+	MeteringPeriod  time.Duration // Usage of API keys is exported so often, 0 - not metered
+	MeteringFile    string        // Usage of API keys is appended to this file as JSON lines
+	MeteringMaxKeys int           // Usage of API keys above so many distinct ones is exported together
+
+	AccessLogFile string        // Every call is appended to this file as a JSON line, empty - disabled
+	SlowLogTime   time.Duration // Calls longer than it are logged with full params, 0 - disabled
+
+	RpcSessionTTL time.Duration // Sessions of erigon_openSession expire after so long without calls, 0 - disabled
+	RpcSessions   *rpc.Sessions // Created from RpcSessionTTL, shared by erigon_openSession and the server - not a flag
+
+	GrpcListenAddress string // Methods are served over gRPC on this address too, empty - disabled
+	GrpcReflection    bool   // Serve the gRPC reflection service next to the methods
+
+	RpcMiddlewares []rpc.Middleware // Of method calls (auth, caching, billing), set by embedders of the daemon - not a flag
 }
 
 var rootCmd = &cobra.Command{
@@ -57,10 +141,27 @@ func RootCommand() (*cobra.Command, *Flags) {
 	utils.CobraFlags(rootCmd, append(debug.Flags, utils.MetricFlags...))
 
 	cfg := &Flags{}
-	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiAddr, "private.api.addr", "127.0.0.1:9090", "private api network address, for example: 127.0.0.1:9090, empty string means not to start the listener. do not expose to public network. serves remote database interface")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiAddr, "private.api.addr", "127.0.0.1:9090", "private api network address, for example: 127.0.0.1:9090 or unix:///path/to/socket of Erigon on the same host, empty string means not to start the listener. do not expose to public network. serves remote database interface")
+	rootCmd.PersistentFlags().IntVar(&cfg.PrivateApiCacheSize, "private.api.cache.size", 0, "Cache so many values read from remote DB by key (chain config, canonical hashes, headers and so on), to not ask them from Erigon again. Values are shared by transactions of the same view of the DB, entries of old views are dropped on state changes streamed by Erigon. 0 - disabled")
+	rootCmd.PersistentFlags().DurationVar(&cfg.PrivateApiCacheTTL, "private.api.cache.ttl", 0, "Lifetime of entries of --private.api.cache.size. 0 - until the next state change streamed by Erigon, or until evicted by LRU")
+	rootCmd.PersistentFlags().DurationVar(&cfg.PrivateApiReconnect, "private.api.reconnect", 0, "Survive restarts of Erigon: requests, which lost connection to --private.api.addr, wait so long for Erigon and continue their transactions, if the database didn't change in the meantime. 0 - such requests fail")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.PrivateApiMetadata, "private.api.metadata", nil, "Comma separated key=value pairs attached as gRPC metadata to every call to --private.api.addr, f.e. authorization token of a proxy in front of Erigon")
+	rootCmd.PersistentFlags().DurationVar(&cfg.PrivateApiDial, "private.api.dial.timeout", 5*time.Second, "Timeout of the first connection to --private.api.addr")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiMaxRecvMsg, "private.api.max.recv.msg", "15MB", "Size limit of replies of Erigon, the biggest value read from the database (f.e. receipts of a block) must fit into it")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiMaxSendMsg, "private.api.max.send.msg", "", "Size limit of requests to Erigon, f.e. 4MB. Empty - no limit")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiWindow, "private.api.window.size", "", "Initial flow control window of streams to --private.api.addr, f.e. 1MB. Bigger windows speed up big replies over links with a high latency. Empty - grown dynamically by gRPC")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiConnWindow, "private.api.conn.window.size", "", "Initial flow control window of connections to --private.api.addr, f.e. 4MB. Empty - grown dynamically by gRPC")
+	rootCmd.PersistentFlags().DurationVar(&cfg.PrivateApiKeepalive, "private.api.keepalive.time", 0, "Ping connections to --private.api.addr idle for so long, also without open requests, so load balancers and NATs don't drop them. Must not be below --private.api.keepalive.min.time of Erigon (10s by default), otherwise Erigon disconnects. 0 - no pings")
+	rootCmd.PersistentFlags().DurationVar(&cfg.PrivateApiAckTimeout, "private.api.keepalive.timeout", 20*time.Second, "Reconnect to --private.api.addr, if a ping of --private.api.keepalive.time is not acknowledged in so long")
+	rootCmd.PersistentFlags().IntVar(&cfg.PrivateApiShards, "private.api.cursor.shards", 1, "Spread cursors of one request over so many streams to --private.api.addr, so iterators used concurrently (f.e. over logs, receipts and headers) don't wait for each other. Extra streams read the same data as the first one")
+	rootCmd.PersistentFlags().IntVar(&cfg.PrivateApiPrefetch, "private.api.prefetch", 0, "Iterators over the remote DB read ahead so many pairs by one round trip to --private.api.addr, if Erigon supports it. Speeds up long scans (f.e. of logs and receipts) on high-latency links. 0 - one pair per round trip")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.PrivateApiReplicas, "private.api.replicas", nil, "Comma separated addresses of other Erigon nodes of the same chain, which back --private.api.addr: new requests go to a healthy node chosen by --private.api.failover")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiFailover, "private.api.failover", "priority", "Policy of choosing among --private.api.addr and --private.api.replicas: priority (the first healthy one in the order of addresses) or least-loaded (the healthy one with the fewest open transactions)")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.PrivateApiMaxLag, "private.api.maxlag", 0, "Erigon nodes of --private.api.replicas, whose head is more blocks behind the best one, are treated as unhealthy. 0 - lag is not checked")
+	rootCmd.PersistentFlags().StringVar(&cfg.PrivateApiCompress, "private.api.compress", "", "Compress traffic of the remote DB: snappy (cheap, for LAN) or zstd (smaller, for WAN), if Erigon supports it. Empty - disabled")
 	rootCmd.PersistentFlags().StringVar(&cfg.Datadir, "datadir", "", "path to Erigon working directory")
 	rootCmd.PersistentFlags().StringVar(&cfg.Chaindata, "chaindata", "", "path to the database")
-	rootCmd.PersistentFlags().StringVar(&cfg.SnapshotDir, "snapshot.dir", "", "path to snapshot dir(only for chaindata mode)")
+	rootCmd.PersistentFlags().StringVar(&cfg.SnapshotDir, "snapshot.dir", "", "directory of snapshots of Erigon, headers are read from them (only for chaindata mode, default: <datadir>/erigon/snapshots)")
 	rootCmd.PersistentFlags().StringVar(&cfg.SnapshotMode, "snapshot.mode", "", `Configures the storage mode of the app(only for chaindata mode):
 * h - use headers snapshot
 * b - use bodies snapshot
@@ -71,22 +172,78 @@ func RootCommand() (*cobra.Command, *Flags) {
 	rootCmd.PersistentFlags().StringVar(&cfg.TLSCertfile, "tls.cert", "", "certificate for client side TLS handshake")
 	rootCmd.PersistentFlags().StringVar(&cfg.TLSKeyFile, "tls.key", "", "key file for client side TLS handshake")
 	rootCmd.PersistentFlags().StringVar(&cfg.TLSCACert, "tls.cacert", "", "CA certificate for client side TLS handshake")
+	rootCmd.PersistentFlags().BoolVar(&cfg.TLSVerify, "tls.verify", false, "Verify the certificate of Erigon by --tls.cacert, including the name of the server (host of --private.api.addr or --tls.servername). By default any certificate of Erigon is accepted")
+	rootCmd.PersistentFlags().StringVar(&cfg.TLSServerName, "tls.servername", "", "Name of the server expected in Subject Alternative Names or Common Name of the certificate of Erigon, if it differs from the host of --private.api.addr (implies --tls.verify)")
 	rootCmd.PersistentFlags().IntVar(&cfg.HttpPort, "http.port", node.DefaultHTTPPort, "HTTP-RPC server listening port")
 	rootCmd.PersistentFlags().StringSliceVar(&cfg.HttpCORSDomain, "http.corsdomain", []string{}, "Comma separated list of domains from which to accept cross origin requests (browser enforced)")
 	rootCmd.PersistentFlags().StringSliceVar(&cfg.HttpVirtualHost, "http.vhosts", node.DefaultConfig.HTTPVirtualHosts, "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.")
 	rootCmd.PersistentFlags().BoolVar(&cfg.HttpCompression, "http.compression", true, "Disable http compression")
-	rootCmd.PersistentFlags().StringSliceVar(&cfg.API, "http.api", []string{"eth", "erigon"}, "API's offered over the HTTP-RPC interface: eth,erigon,web3,net,debug,trace,txpool,shh,db. Supported methods: https://github.com/ledgerwatch/erigon/tree/devel/cmd/rpcdaemon")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.API, "http.api", []string{"eth", "erigon"}, "API's offered over the HTTP-RPC interface: eth,erigon,web3,net,debug,trace,txpool,shh,db,wallet,personal. Supported methods: https://github.com/ledgerwatch/erigon/tree/devel/cmd/rpcdaemon")
 	rootCmd.PersistentFlags().Uint64Var(&cfg.Gascap, "rpc.gascap", 25000000, "Sets a cap on gas that can be used in eth_call/estimateGas")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.EVMMaxMemoryMB, "rpc.evm.maxmemory", 1024, "Limit of total EVM memory (in MB) of all call frames of one eth_call/estimateGas/trace request, 0 - no limit")
+	rootCmd.PersistentFlags().IntVar(&cfg.EVMMaxCallDepth, "rpc.evm.maxdepth", 0, "Limit of EVM call depth of eth_call/estimateGas/trace requests, 0 - consensus limit (1024)")
+	rootCmd.PersistentFlags().IntVar(&cfg.LogsMaxResults, "rpc.logs.maxresults", 0, "Limit of logs returned by one eth_getLogs call. Bigger results are returned by pages: the error with code -32005 carries the first logs and the continuation token, which is passed back in the 'continuation' field of the filter to get the next page. 0 - no limit")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.LogsMaxRange, "rpc.logs.maxrange", 0, "Limit of blocks of the range read by one eth_getLogs call. Longer ranges are returned by pages, as by --rpc.logs.maxresults. 0 - no limit")
+	rootCmd.PersistentFlags().DurationVar(&cfg.LogsMaxTime, "rpc.logs.maxtime", 0, "Limit of time of reading blocks by one eth_getLogs call, at least one block is read. Slower calls are returned by pages, as by --rpc.logs.maxresults. 0 - no limit")
+	rootCmd.PersistentFlags().StringVar(&cfg.StateCache.Policy, "state.cache.policy", state.DefaultSharedCacheConfig.Policy, "Eviction policy of the cache of the latest state (accounts, storage and code) for eth_call and eth_estimateGas: lru or arc")
+	rootCmd.PersistentFlags().IntVar(&cfg.StateCache.Accounts, "state.cache.accounts", state.DefaultSharedCacheConfig.Accounts, "Accounts kept in the cache of the latest state. Entries of accounts and storage are dropped on every new block. 0 - not cached")
+	rootCmd.PersistentFlags().IntVar(&cfg.StateCache.Storage, "state.cache.storage", state.DefaultSharedCacheConfig.Storage, "Storage slots kept in the cache of the latest state. 0 - not cached")
+	rootCmd.PersistentFlags().IntVar(&cfg.StateCache.Code, "state.cache.code", state.DefaultSharedCacheConfig.Code, "Contract codes kept in the cache of the latest state, by code hash. 0 - not cached")
+	rootCmd.PersistentFlags().IntVar(&cfg.StateProfile.SampleRate, "state.profile.rate", state.DefaultAccessProfilerConfig.SampleRate, "Sample every N-th read of accounts and storage slots by calls and traces, the hottest ones are served by debug_stateAccessProfile. 0 - not profiled")
+	rootCmd.PersistentFlags().DurationVar(&cfg.StateProfile.Window, "state.profile.window", state.DefaultAccessProfilerConfig.Window, "Samples of the state profile are aggregated by windows of this duration")
+	rootCmd.PersistentFlags().IntVar(&cfg.StateProfile.Windows, "state.profile.windows", state.DefaultAccessProfilerConfig.Windows, "The last windows of the state profile kept")
 	rootCmd.PersistentFlags().Uint64Var(&cfg.MaxTraces, "trace.maxtraces", 200, "Sets a limit on traces that can be returned in trace_filter")
 	rootCmd.PersistentFlags().BoolVar(&cfg.WebsocketEnabled, "ws", false, "Enable Websockets")
 	rootCmd.PersistentFlags().BoolVar(&cfg.WebsocketCompression, "ws.compression", false, "Enable Websocket compression (RFC 7692)")
 	rootCmd.PersistentFlags().StringVar(&cfg.RpcAllowListFilePath, "rpc.accessList", "", "Specify granular (method-by-method) API allowlist")
-	rootCmd.PersistentFlags().UintVar(&cfg.RpcBatchConcurrency, "rpc.batch.concurrency", 50, "Does limit amount of goroutines to process 1 batch request. Means 1 bach request can't overload server. 1 batch still can have unlimited amount of request")
+	rootCmd.PersistentFlags().StringVar(&cfg.RpcListenersFile, "rpc.listeners", "", "JSON file with additional HTTP listeners: [{\"addr\": \"host:port\", \"api\": [namespaces], \"ws\": bool, \"keys\": [API keys], \"ratelimit\": [method=N/s], \"quota\": [method=N]}], each served with own API namespaces, API keys and rate limits")
+	rootCmd.PersistentFlags().UintVar(&cfg.RpcBatchConcurrency, "rpc.batch.concurrency", 50, "Does limit amount of goroutines to process 1 batch request. Means 1 bach request can't overload server. 1 batch still can have unlimited amount of request. 1 - calls of a batch are executed sequentially")
+	rootCmd.PersistentFlags().IntVar(&cfg.RpcBatchLimit, "rpc.batch.limit", 0, "Maximum number of calls in 1 batch request, bigger batches are refused. 0 - no limit")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.RpcBatchGasCap, "rpc.batch.gascap", 0, "Maximum gas of all eth_call of 1 batch request together, calls above it fail. 0 - no limit")
+	rootCmd.PersistentFlags().IntVar(&cfg.RpcConcurrency, "rpc.concurrency", 0, "Limit of concurrently executed calls. When it's reached, waiting interactive calls are started before waiting batch calls (traces, logs), and batch calls can't take the last quarter of slots (at least one, if the limit is above 1). 0 - no limit and no prioritization")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.RpcBatchMethods, "rpc.concurrency.batch.methods", rpc.DefaultBatchMethods, "Methods of the batch traffic class, all other methods are interactive. Trailing '*' matches any suffix")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.RpcBatchKeys, "rpc.concurrency.batch.keys", []string{}, "API keys (X-API-Key HTTP header), calls with which are of the batch class regardless of the method")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.RpcInteractiveKeys, "rpc.concurrency.interactive.keys", []string{}, "API keys (X-API-Key HTTP header), calls with which are of the interactive class regardless of the method")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.RpcRateLimits, "rpc.ratelimit", []string{}, "Comma separated limits of rates of calls of methods by one client, f.e. eth_getLogs=10/s,eth_call=50/s,*=100/s (also /m and /h). * applies to each method without own limit. Calls above it fail with error -32005. Empty - no limits")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.RpcQuotas, "rpc.quota", []string{}, "Comma separated quotas of calls of methods by one client per --rpc.quota.period, f.e. eth_getLogs=100000,*=1000000. Calls above it fail with error -32005 until the end of the period. Empty - no quotas")
+	rootCmd.PersistentFlags().DurationVar(&cfg.RpcQuotaPeriod, "rpc.quota.period", 24*time.Hour, "Period of --rpc.quota, periods are aligned to multiples of it since the Unix epoch")
+	rootCmd.PersistentFlags().StringVar(&cfg.RpcRateLimitBy, "rpc.ratelimit.by", rpc.ClientByIP, "Clients of --rpc.ratelimit and --rpc.quota are identified by: ip (address of the client) or key (X-API-Key HTTP header, address for calls without a key). Behind a proxy all clients have its address, use keys then")
+	rootCmd.PersistentFlags().IntVar(&cfg.RpcRateLimitClients, "rpc.ratelimit.clients", 100000, "Track limits of so many recent clients of --rpc.ratelimit and --rpc.quota, older clients start from scratch")
 	rootCmd.PersistentFlags().BoolVar(&cfg.TraceCompatibility, "trace.compat", false, "Bug for bug compatibility with OE for trace_ routines")
+	rootCmd.PersistentFlags().StringVar(&cfg.ReplicaDir, "replica.dir", "", "path to the local read replica of Erigon's database. Requires --private.api.addr of Erigon started with --private.api.replication.log. Reads are served from the replica, which is copied from Erigon on first start and then follows its changes")
+
+	rootCmd.PersistentFlags().Uint64Var(&cfg.HeadLag, "rpc.headlag", 0, "Serve the chain as if it ends so many blocks behind the real head: 'latest', eth_blockNumber, eth_syncing and newHeads use the lagged head, blocks and transactions after it are not found. Gives the same answers on all rpcdaemons behind one load balancer, if none of their nodes is more blocks behind. 0 - disabled")
+	rootCmd.PersistentFlags().DurationVar(&cfg.MeteringPeriod, "rpc.metering.period", 0, "Meter usage of the daemon by API keys (X-API-Key HTTP header): calls, errors, time of calls and bytes of results, exported so often as rpc_usage_* metrics (labelled by first 16 hex digits of SHA-256 of the key) and to --rpc.metering.file. 0 - disabled")
+	rootCmd.PersistentFlags().StringVar(&cfg.MeteringFile, "rpc.metering.file", "", "File, to which usage of every period of --rpc.metering.period is appended as JSON lines, one per API key. Empty - only metrics")
+	rootCmd.PersistentFlags().IntVar(&cfg.MeteringMaxKeys, "rpc.metering.maxkeys", 10000, "Limit of distinct API keys metered separately, usage of other keys is exported under the key 'other'")
+	rootCmd.PersistentFlags().StringVar(&cfg.AccessLogFile, "rpc.accesslog", "", "File, to which every call is appended as a JSON line: method, hash of params, duration, size of the result, client address, API key ID and error. Empty - disabled")
+	rootCmd.PersistentFlags().DurationVar(&cfg.SlowLogTime, "rpc.slowlog", 0, "Calls longer than this are logged with full params (as warnings, and to --rpc.accesslog) and counted in rpc_slow_calls metrics. 0 - disabled")
+	rootCmd.PersistentFlags().DurationVar(&cfg.RpcSessionTTL, "rpc.sessions.ttl", 0, "Enables erigon_openSession: it pins the latest block for calls sent with the returned ID in the X-Session-ID HTTP header, so they see the same state across calls. Sessions expire after so long without calls. 0 - disabled")
+	rootCmd.PersistentFlags().StringVar(&cfg.GrpcListenAddress, "grpc.addr", "", "Serve the enabled APIs over gRPC on this address too (service erigon.rpc.JSONRPC, protobuf Struct requests and Value replies), for internal consumers which don't want to speak JSON. Empty - disabled")
+	rootCmd.PersistentFlags().BoolVar(&cfg.GrpcReflection, "grpc.reflection", false, "Serve the gRPC reflection service on --grpc.addr, so tools like grpcurl can discover the methods")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.BuildBlockKeys, "rpc.buildblock.keys", []string{}, "API keys (X-API-Key HTTP header), calls with which may use erigon_buildBlock to build a block from the txpool without publishing it. Empty - the method is disabled")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.TxMonitorBlocks, "txmonitor.blocks", 0, "Monitor transactions submitted via this rpcdaemon: report transactions, which are not mined after so many blocks or are dropped from the pool, in logs, rpc_local_txs_* metrics and erigon_localTransactions. 0 - disabled")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.TxBroadcastEndpoints, "txbroadcast.endpoints", nil, "Comma separated URLs of JSON-RPC endpoints (relays, other nodes), to which transactions submitted via this rpcdaemon are also sent by eth_sendRawTransaction, in the background. Results are counted in rpc_broadcast_txs metrics")
+	rootCmd.PersistentFlags().Uint64Var(&cfg.TxPoolGlobalSlots, "txpool.globalslots", core.DefaultTxPoolConfig.GlobalSlots, "Executable transaction slots of the txpool of the node (its --txpool.globalslots). eth_gasPrice and erigon_gasStats consider the pool congested, when it's 80% full")
+	rootCmd.PersistentFlags().IntVar(&cfg.TxBroadcastRetries, "txbroadcast.retries", 3, "Retry sends of transactions to --txbroadcast.endpoints, which failed to reach them, so many times with growing delays. Transactions refused by the endpoints are not retried")
+
+	rootCmd.PersistentFlags().StringVar(&cfg.WalletKeystore, "wallet.keystore", "", "Enables eth_accounts, eth_sign, eth_signTransaction and eth_sendTransaction (and the same methods of wallet namespace of --http.api) with keys from the directory (Web3 Secret Storage format, as in geth keystore). Disabled by default")
+	rootCmd.PersistentFlags().StringVar(&cfg.WalletPasswordFile, "wallet.password", "", "File with passwords of the keys of --wallet.keystore, one per line. Every key is unlocked with the first password, which decrypts it, other keys stay locked")
+	rootCmd.PersistentFlags().StringVar(&cfg.WalletSigner, "wallet.signer", "", "Enables the same namespace as --wallet.keystore, but keys are kept by the external signer (clef or compatible): IPC path or http(s)/ws(s) URL. Required by personal namespace of --http.api")
+	rootCmd.PersistentFlags().BoolVar(&cfg.AllowInsecureUnlock, "allow-insecure-unlock", false, "Unlock keys of --wallet.keystore even if the eth or wallet namespace is served over HTTP, WS or gRPC on other interfaces than loopback. Without it rpcdaemon refuses to start then")
+	rootCmd.PersistentFlags().StringVar(&cfg.WalletSignerAudit, "wallet.signer.audit", "", "File, to which every request to --wallet.signer is appended as JSON line: time, address of RPC client, method and params")
+	rootCmd.PersistentFlags().String(flags.ConfigFlagName, "", flags.ConfigFlagUsage)
+	rootCmd.PersistentFlags().Bool(flags.PrintEffectiveConfigFlagName, false, flags.PrintEffectiveConfigFlagUsage)
+	if err := rootCmd.MarkPersistentFlagFilename(flags.ConfigFlagName, "yaml", "yml", "toml"); err != nil {
+		panic(err)
+	}
 
 	if err := rootCmd.MarkPersistentFlagFilename("rpc.accessList", "json"); err != nil {
 		panic(err)
 	}
+	if err := rootCmd.MarkPersistentFlagFilename("rpc.listeners", "json"); err != nil {
+		panic(err)
+	}
 	if err := rootCmd.MarkPersistentFlagDirname("datadir"); err != nil {
 		panic(err)
 	}
@@ -96,8 +253,25 @@ func RootCommand() (*cobra.Command, *Flags) {
 	if err := rootCmd.MarkPersistentFlagDirname("snapshot.dir"); err != nil {
 		panic(err)
 	}
+	if err := rootCmd.MarkPersistentFlagDirname("replica.dir"); err != nil {
+		panic(err)
+	}
+	rootCmd.PersistentFlags().StringVar(&cfg.HistoryFilesDir, "history.files.dir", "", "directory of history files of Erigon (default: <datadir>/erigon/history)")
+	if err := rootCmd.MarkPersistentFlagDirname("history.files.dir"); err != nil {
+		panic(err)
+	}
+	rootCmd.PersistentFlags().StringVar(&cfg.LogArchiveDir, "logs.archive.dir", "", "directory of the log archive of Erigon (default: <datadir>/erigon/logs)")
+	if err := rootCmd.MarkPersistentFlagDirname("logs.archive.dir"); err != nil {
+		panic(err)
+	}
 
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := flags.ApplyConfigCobra(cmd.Flags()); err != nil {
+			if errors.Is(err, flags.ErrEffectiveConfigPrinted) {
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+			}
+			return err
+		}
 		if err := utils.SetupCobra(cmd); err != nil {
 			return err
 		}
@@ -109,9 +283,15 @@ func RootCommand() (*cobra.Command, *Flags) {
 			if cfg.Chaindata == "" {
 				cfg.Chaindata = path.Join(cfg.Datadir, "erigon", "chaindata")
 			}
-			//if cfg.SnapshotDir == "" {
-			//	cfg.SnapshotDir = path.Join(cfg.Datadir, "erigon", "snapshot")
-			//}
+			if cfg.HistoryFilesDir == "" {
+				cfg.HistoryFilesDir = path.Join(path.Dir(cfg.Chaindata), "history")
+			}
+			if cfg.LogArchiveDir == "" {
+				cfg.LogArchiveDir = path.Join(path.Dir(cfg.Chaindata), "logs")
+			}
+			if cfg.SnapshotDir == "" {
+				cfg.SnapshotDir = path.Join(path.Dir(cfg.Chaindata), "snapshots")
+			}
 		}
 		return nil
 	}
@@ -168,78 +348,215 @@ func RemoteServices(cfg Flags, logger log.Logger, rootCancel context.CancelFunc)
 	// If PrivateApiAddr is checked first, the Chaindata option will never work
 	if cfg.SingleNodeMode {
 		var rwKv kv.RwDB
-		rwKv, err = kv2.NewMDBX(logger).Path(cfg.Chaindata).Readonly().Open()
+		rwKv, err = kv2.NewMDBX(logger).Path(cfg.Chaindata).Readonly().WithTablessCfg(ethdb.WithErigonTables).Open()
 		if err != nil {
 			return nil, nil, nil, nil, err
 		}
 		if compatErr := checkDbCompatibility(rwKv); compatErr != nil {
 			return nil, nil, nil, nil, compatErr
 		}
+		if _, statErr := os.Stat(cfg.SnapshotDir); statErr == nil {
+			rwKv = snapshotsync.FollowSnapshots(rwKv, cfg.SnapshotDir)
+		}
+		if _, statErr := os.Stat(cfg.HistoryFilesDir); statErr == nil {
+			files, err := historyfiles.Open(cfg.HistoryFilesDir)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			rwKv = historyfiles.WrapDB(rwKv, files)
+		}
 		db = rwKv
 	} else {
 		log.Info("if you run RPCDaemon on same machine with Erigon add --datadir option")
 	}
 	if cfg.PrivateApiAddr != "" {
-		remoteKv, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remotedbserver.KvServiceAPIVersion), logger).Path(cfg.PrivateApiAddr).Open(cfg.TLSCertfile, cfg.TLSKeyFile, cfg.TLSCACert)
+		md, err := parseMetadata(cfg.PrivateApiMetadata)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		var maxRecvMsg datasize.ByteSize
+		if err = maxRecvMsg.UnmarshalText([]byte(cfg.PrivateApiMaxRecvMsg)); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("invalid --private.api.max.recv.msg: %w", err)
+		}
+		var maxSendMsg, window, connWindow datasize.ByteSize
+		for _, size := range []struct {
+			flag, value string
+			size        *datasize.ByteSize
+		}{
+			{"private.api.max.send.msg", cfg.PrivateApiMaxSendMsg, &maxSendMsg},
+			{"private.api.window.size", cfg.PrivateApiWindow, &window},
+			{"private.api.conn.window.size", cfg.PrivateApiConnWindow, &connWindow},
+		} {
+			if size.value == "" {
+				continue
+			}
+			if err = size.size.UnmarshalText([]byte(size.value)); err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("invalid --%s: %w", size.flag, err)
+			}
+		}
+		remoteOpts := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), logger).Path(cfg.PrivateApiAddr).WithBucketsConfig(ethdb.WithErigonTables).WithReadCache(cfg.PrivateApiCacheSize, cfg.PrivateApiCacheTTL).WithReconnect(cfg.PrivateApiReconnect).WithCompression(cfg.PrivateApiCompress).WithMetadata(md...).
+			DialTimeout(cfg.PrivateApiDial).MaxRecvMsgSize(maxRecvMsg).MaxSendMsgSize(maxSendMsg).WindowSize(window, connWindow).WithCursorShards(cfg.PrivateApiShards).WithPrefetch(cfg.PrivateApiPrefetch)
+		if cfg.PrivateApiKeepalive > 0 {
+			remoteOpts = remoteOpts.Keepalive(keepalive.ClientParameters{Time: cfg.PrivateApiKeepalive, Timeout: cfg.PrivateApiAckTimeout, PermitWithoutStream: true})
+		}
+		if cfg.TLSVerify || cfg.TLSServerName != "" {
+			remoteOpts = remoteOpts.VerifyServerName(cfg.TLSServerName)
+		}
+		if len(cfg.PrivateApiReplicas) > 0 {
+			policy, err := remotedb.ParseFailoverPolicy(cfg.PrivateApiFailover)
+			if err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("invalid --private.api.failover: %w", err)
+			}
+			remoteOpts = remoteOpts.WithReplicas(policy, cfg.PrivateApiMaxLag, cfg.PrivateApiReplicas...)
+		}
+		remoteKv, err := remoteOpts.Open(cfg.TLSCertfile, cfg.TLSKeyFile, cfg.TLSCACert)
 		if err != nil {
 			return nil, nil, nil, nil, fmt.Errorf("could not connect to remoteKv: %w", err)
 		}
-		remoteEth := services.NewRemoteBackend(remoteKv.GrpcConn())
-		mining = services.NewMiningService(remoteKv.GrpcConn())
-		txPool = services.NewTxPoolService(remoteKv.GrpcConn())
+		conn := remotedb.NewSharedConn(remoteKv)
+		remoteEth := services.NewRemoteBackend(conn)
+		mining = services.NewMiningService(conn)
+		txPool = services.NewTxPoolService(conn)
+		conn.Register("ETHBACKEND", remoteEth)
+		conn.Register("Mining", mining)
+		conn.Register("TxPool", txPool)
+		if cfg.ReplicaDir != "" && db == nil {
+			if db, err = openReplica(cfg.ReplicaDir, remoteKv, logger); err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
 		if db == nil {
 			db = remoteKv
 		}
 		eth = remoteEth
 		go func() {
-			if !remoteKv.EnsureVersionCompatibility() {
-				rootCancel()
-			}
-			if !remoteEth.EnsureVersionCompatibility() {
-				rootCancel()
-			}
-			if !mining.EnsureVersionCompatibility() {
-				rootCancel()
-			}
-			if !txPool.EnsureVersionCompatibility() {
+			if !conn.EnsureVersionCompatibility() {
 				rootCancel()
 			}
 		}()
 	}
+	if cfg.HeadLag > 0 && db != nil {
+		log.Info("Serving lagged head", "blocks", cfg.HeadLag)
+		db = headlag.WrapDB(db, cfg.HeadLag)
+	}
 	return db, eth, txPool, mining, err
 }
 
+// openReplica opens the local replica and starts to follow Erigon's replication log. Until the tables are copied
+// (the replica is new, or it fell out of the log), reads are served by Erigon over remoteKv.
+func openReplica(dir string, remoteKv *remotedb.RemoteKV, logger log.Logger) (kv.RoDB, error) {
+	replicaKv, err := kv2.NewMDBX(logger).Path(dir).WithTablessCfg(ethdb.WithErigonTables).Open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open replica: %w", err)
+	}
+	replica := replication.NewReplica(replicaKv, remoteKv, remoteKv.GrpcConn())
+	epoch, _, err := replica.Progress(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	go replica.Run(context.Background())
+	if epoch == 0 {
+		log.Info("[replica] copying the tables, reads are served by Erigon meanwhile", "dir", dir)
+	}
+	return replica.DB(), nil
+}
+
 func StartRpcServer(ctx context.Context, cfg Flags, rpcAPI []rpc.API) error {
 	// register apis and create handler stack
 	httpEndpoint := fmt.Sprintf("%s:%d", cfg.HttpListenAddress, cfg.HttpPort)
 
-	srv := rpc.NewServer(cfg.RpcBatchConcurrency)
-
 	allowListForRPC, err := parseAllowListForRPC(cfg.RpcAllowListFilePath)
 	if err != nil {
 		return err
 	}
-	srv.SetAllowList(allowListForRPC)
-
-	if err := node.RegisterApisFromWhitelist(rpcAPI, cfg.API, srv, false); err != nil {
-		return fmt.Errorf("could not start register RPC apis: %w", err)
+	listeners, err := parseRpcListeners(cfg.RpcListenersFile)
+	if err != nil {
+		return fmt.Errorf("invalid --rpc.listeners: %w", err)
 	}
-
-	httpHandler := node.NewHTTPHandlerStack(srv, cfg.HttpCORSDomain, cfg.HttpVirtualHost, cfg.HttpCompression)
-	var wsHandler http.Handler
-	if cfg.WebsocketEnabled {
-		wsHandler = srv.WebsocketHandler([]string{"*"}, cfg.WebsocketCompression)
+	// the scheduler and middlewares are shared by servers of all listeners, the rate limiter of every listener is
+	// between pre and post
+	var scheduler *rpc.Scheduler
+	if cfg.RpcConcurrency > 0 {
+		scheduler = rpc.NewScheduler(cfg.RpcConcurrency, cfg.RpcBatchMethods)
+		for _, key := range cfg.RpcBatchKeys {
+			scheduler.SetKeyClass(key, rpc.ClassBatch)
+		}
+		for _, key := range cfg.RpcInteractiveKeys {
+			scheduler.SetKeyClass(key, rpc.ClassInteractive)
+		}
 	}
+	var pre, post []rpc.Middleware
+	if cfg.AccessLogFile != "" || cfg.SlowLogTime > 0 {
+		var w io.Writer
+		if cfg.AccessLogFile != "" {
+			f, err := os.OpenFile(cfg.AccessLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("could not open --rpc.accesslog: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		pre = append(pre, rpc.NewAccessLog(w, cfg.SlowLogTime).Middleware())
+	}
+	if cfg.MeteringPeriod > 0 {
+		meter := rpc.NewMeter(cfg.MeteringMaxKeys, cfg.MeteringFile)
+		pre = append(pre, meter.Middleware())
+		go meter.Run(ctx, cfg.MeteringPeriod)
+	}
+	if cfg.RpcSessions != nil {
+		post = append(post, cfg.RpcSessions.Middleware())
+	}
+	post = append(post, cfg.RpcMiddlewares...)
 
-	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if cfg.WebsocketEnabled && r.Method == "GET" {
-			wsHandler.ServeHTTP(w, r)
-			return
+	newServer := func(namespaces, rateLimits, quotas []string) (*rpc.Server, error) {
+		if bad := unavailableAPIs(rpcAPI, namespaces); len(bad) > 0 {
+			return nil, fmt.Errorf("unknown or disabled API namespaces: %s", strings.Join(bad, ", "))
+		}
+		srv := rpc.NewServer(cfg.RpcBatchConcurrency)
+		srv.SetBatchLimits(rpc.BatchLimits{MaxCalls: cfg.RpcBatchLimit, MaxGas: cfg.RpcBatchGasCap})
+		srv.SetAllowList(allowListForRPC)
+		if scheduler != nil {
+			srv.SetScheduler(scheduler)
+		}
+		srv.Use(pre...)
+		if len(rateLimits) > 0 || len(quotas) > 0 {
+			limiter, err := newRateLimiter(cfg, rateLimits, quotas)
+			if err != nil {
+				return nil, err
+			}
+			srv.Use(limiter.Middleware())
+		}
+		srv.Use(post...)
+		if err := node.RegisterApisFromWhitelist(rpcAPI, namespaces, srv, false); err != nil {
+			return nil, fmt.Errorf("could not start register RPC apis: %w", err)
+		}
+		return srv, nil
+	}
+	serve := func(endpoint string, srv *rpc.Server, ws bool, keys []string) (*http.Server, error) {
+		httpHandler := node.NewHTTPHandlerStack(srv, cfg.HttpCORSDomain, cfg.HttpVirtualHost, cfg.HttpCompression)
+		var wsHandler http.Handler
+		if ws {
+			wsHandler = srv.WebsocketHandler([]string{"*"}, cfg.WebsocketCompression)
 		}
-		httpHandler.ServeHTTP(w, r)
-	})
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ws && r.Method == "GET" {
+				wsHandler.ServeHTTP(w, r)
+				return
+			}
+			httpHandler.ServeHTTP(w, r)
+		})
+		if len(keys) > 0 {
+			handler = requireAPIKey(keys, handler)
+		}
+		listener, _, err := node.StartHTTPEndpoint(endpoint, rpc.DefaultHTTPTimeouts, handler)
+		return listener, err
+	}
 
-	listener, _, err := node.StartHTTPEndpoint(httpEndpoint, rpc.DefaultHTTPTimeouts, handler)
+	srv, err := newServer(cfg.API, cfg.RpcRateLimits, cfg.RpcQuotas)
+	if err != nil {
+		return err
+	}
+	listener, err := serve(httpEndpoint, srv, cfg.WebsocketEnabled, nil)
 	if err != nil {
 		return fmt.Errorf("could not start RPC api: %w", err)
 	}
@@ -253,7 +570,86 @@ func StartRpcServer(ctx context.Context, cfg Flags, rpcAPI []rpc.API) error {
 		_ = listener.Shutdown(shutdownCtx)
 		log.Info("HTTP endpoint closed", "url", httpEndpoint)
 	}()
+
+	for _, l := range listeners {
+		l := l
+		lsrv, err := newServer(l.API, l.RateLimit, l.Quota)
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", l.Addr, err)
+		}
+		llistener, err := serve(l.Addr, lsrv, l.WS, l.Keys)
+		if err != nil {
+			lsrv.Stop()
+			return fmt.Errorf("could not start RPC api on %s: %w", l.Addr, err)
+		}
+		log.Info("HTTP endpoint opened", "url", l.Addr, "api", l.API, "ws", l.WS, "keys", len(l.Keys))
+		defer func() {
+			lsrv.Stop()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = llistener.Shutdown(shutdownCtx)
+			log.Info("HTTP endpoint closed", "url", l.Addr)
+		}()
+	}
+
+	if cfg.GrpcListenAddress != "" {
+		grpcListener, err := net.Listen("tcp", cfg.GrpcListenAddress)
+		if err != nil {
+			return fmt.Errorf("could not start gRPC api: %w", err)
+		}
+		grpcServer := grpc.NewServer()
+		rpc.RegisterGRPC(grpcServer, srv)
+		if cfg.GrpcReflection {
+			reflection.Register(grpcServer)
+		}
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Warn("gRPC endpoint failed", "err", err)
+			}
+		}()
+		log.Info("gRPC endpoint opened", "addr", cfg.GrpcListenAddress, "reflection", cfg.GrpcReflection)
+		defer func() {
+			grpcServer.GracefulStop()
+			log.Info("gRPC endpoint closed", "addr", cfg.GrpcListenAddress)
+		}()
+	}
+
 	<-ctx.Done()
 	log.Info("Exiting...")
 	return nil
 }
+
+// newRateLimiter creates the limiter of calls by rates (f.e. eth_call=50/s) and quotas (f.e. eth_call=1000) of methods
+func newRateLimiter(cfg Flags, rateLimits, quotas []string) (*rpc.RateLimiter, error) {
+	for _, spec := range rateLimits {
+		if !strings.Contains(spec, "/") {
+			return nil, fmt.Errorf("invalid --rpc.ratelimit %q, expected a rate, f.e. eth_call=50/s", spec)
+		}
+	}
+	for _, spec := range quotas {
+		if strings.Contains(spec, "/") {
+			return nil, fmt.Errorf("invalid --rpc.quota %q, expected a number of calls, f.e. eth_call=1000", spec)
+		}
+	}
+	limits := map[string]rpc.MethodLimit{}
+	if err := rpc.ParseMethodLimits(rateLimits, limits); err != nil {
+		return nil, fmt.Errorf("invalid --rpc.ratelimit: %w", err)
+	}
+	if err := rpc.ParseMethodLimits(quotas, limits); err != nil {
+		return nil, fmt.Errorf("invalid --rpc.quota: %w", err)
+	}
+	return rpc.NewRateLimiter(limits, cfg.RpcRateLimitBy, cfg.RpcQuotaPeriod, cfg.RpcRateLimitClients)
+}
+
+// parseMetadata converts key=value pairs of --private.api.metadata to the key, value list of metadata.Pairs
+func parseMetadata(pairs []string) ([]string, error) {
+	result := make([]string, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --private.api.metadata %q, expected key=value", pair)
+		}
+		result = append(result, pair[:i], pair[i+1:])
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/rpc"
+)
+
+// rpcListener is an additional HTTP/WS listener of --rpc.listeners, served by its own server with its own API
+// namespaces, API keys and rate limits
+type rpcListener struct {
+	Addr      string   `json:"addr"`      // host:port
+	API       []string `json:"api"`       // namespaces, as --http.api
+	WS        bool     `json:"ws"`        // serve websockets too
+	Keys      []string `json:"keys"`      // only requests with one of these API keys (X-API-Key header) are served, empty - all requests
+	RateLimit []string `json:"ratelimit"` // as --rpc.ratelimit, --rpc.ratelimit of the main listener doesn't apply here
+	Quota     []string `json:"quota"`     // as --rpc.quota, per --rpc.quota.period
+}
+
+func parseRpcListeners(path string) ([]rpcListener, error) {
+	path = strings.TrimSpace(path)
+	if path == "" { // no file is provided
+		return nil, nil
+	}
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var listeners []rpcListener
+	if err = json.Unmarshal(fileContents, &listeners); err != nil {
+		return nil, err
+	}
+	for i, l := range listeners {
+		if l.Addr == "" {
+			return nil, fmt.Errorf("listener %d has no addr", i)
+		}
+		if len(l.API) == 0 {
+			return nil, fmt.Errorf("listener %s has no api", l.Addr)
+		}
+	}
+	return listeners, nil
+}
+
+// EnabledAPIs returns namespaces of --http.api and of all listeners of --rpc.listeners, each once. Services of all of them
+// are created, every listener registers only its own ones.
+func EnabledAPIs(cfg Flags) ([]string, error) {
+	listeners, err := parseRpcListeners(cfg.RpcListenersFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --rpc.listeners: %w", err)
+	}
+	var enabled []string
+	seen := map[string]struct{}{}
+	add := func(namespaces []string) {
+		for _, namespace := range namespaces {
+			if _, ok := seen[namespace]; !ok {
+				seen[namespace] = struct{}{}
+				enabled = append(enabled, namespace)
+			}
+		}
+	}
+	add(cfg.API)
+	for _, l := range listeners {
+		add(l.API)
+	}
+	return enabled, nil
+}
+
+// unavailableAPIs returns namespaces, services of which are not created: unknown ones, or ones which are disabled
+// by other flags (f.e. wallet without --wallet.keystore or --wallet.signer)
+func unavailableAPIs(apis []rpc.API, namespaces []string) []string {
+	available := map[string]struct{}{rpc.MetadataApi: {}}
+	for _, api := range apis {
+		available[api.Namespace] = struct{}{}
+	}
+	var bad []string
+	for _, namespace := range namespaces {
+		if _, ok := available[namespace]; !ok {
+			bad = append(bad, namespace)
+		}
+	}
+	return bad
+}
+
+// requireAPIKey serves only requests with one of the keys in the X-API-Key header, websocket connections send it
+// with the upgrade request
+func requireAPIKey(keys []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := []byte(r.Header.Get("X-API-Key"))
+		for _, k := range keys {
+			if subtle.ConstantTimeCompare(key, []byte(k)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRpcListeners(t *testing.T) {
+	listeners, err := parseRpcListeners("")
+	require.NoError(t, err)
+	require.Empty(t, listeners)
+
+	path := filepath.Join(t.TempDir(), "listeners.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"addr": "127.0.0.1:8546", "api": ["eth", "net"], "keys": ["public"], "ratelimit": ["eth_call=10/s"]},
+		{"addr": "127.0.0.1:8547", "api": ["debug", "trace"], "ws": true}
+	]`), 0600))
+	listeners, err = parseRpcListeners(path)
+	require.NoError(t, err)
+	require.Equal(t, []rpcListener{
+		{Addr: "127.0.0.1:8546", API: []string{"eth", "net"}, Keys: []string{"public"}, RateLimit: []string{"eth_call=10/s"}},
+		{Addr: "127.0.0.1:8547", API: []string{"debug", "trace"}, WS: true},
+	}, listeners)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"addr": "127.0.0.1:8546"}]`), 0600))
+	_, err = parseRpcListeners(path)
+	require.Error(t, err)
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	handler := requireAPIKey([]string{"a", "b"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for key, code := range map[string]int{"a": http.StatusOK, "b": http.StatusOK, "c": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		r := httptest.NewRequest("POST", "/", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, code, w.Code, key)
+	}
+}
+
+func TestEnabledAPIs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "listeners.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"addr": "0.0.0.0:8546", "api": ["eth", "net"]},
+		{"addr": "127.0.0.1:8547", "api": ["debug", "trace"], "ws": true}
+	]`), 0600))
+	enabled, err := EnabledAPIs(Flags{API: []string{"eth", "net", "web3"}, RpcListenersFile: path})
+	require.NoError(t, err)
+	require.Equal(t, []string{"eth", "net", "web3", "debug", "trace"}, enabled)
+
+	apis := []rpc.API{{Namespace: "eth"}, {Namespace: "debug"}}
+	require.Empty(t, unavailableAPIs(apis, []string{"eth", "debug", rpc.MetadataApi}))
+	require.Equal(t, []string{"dbug", "wallet"}, unavailableAPIs(apis, []string{"eth", "dbug", "wallet"}))
+}
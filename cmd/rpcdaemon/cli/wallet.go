@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/accounts/external"
+	"github.com/ledgerwatch/erigon/accounts/keystore"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// OpenWallet returns the wallet configured with --wallet.* flags, nil if none is configured
+func OpenWallet(ctx context.Context, cfg Flags) (accounts.Wallet, error) {
+	switch {
+	case cfg.WalletKeystore != "" && cfg.WalletSigner != "":
+		return nil, fmt.Errorf("--wallet.keystore and --wallet.signer can't be used together")
+	case cfg.WalletSignerAudit != "" && cfg.WalletSigner == "":
+		return nil, fmt.Errorf("--wallet.signer.audit requires --wallet.signer")
+	case cfg.WalletSigner != "":
+		signer, err := external.Dial(ctx, cfg.WalletSigner)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.WalletSignerAudit != "" {
+			audit, err := external.OpenAudit(cfg.WalletSignerAudit)
+			if err != nil {
+				signer.Close()
+				return nil, err
+			}
+			signer.SetAudit(audit)
+		}
+		log.Info("Signing with external signer", "endpoint", cfg.WalletSigner, "audit", cfg.WalletSignerAudit)
+		return signer, nil
+	case cfg.WalletKeystore != "":
+		ks, err := keystore.Open(cfg.WalletKeystore)
+		if err != nil {
+			return nil, err
+		}
+		var passwords []string
+		if cfg.WalletPasswordFile != "" {
+			if !cfg.AllowInsecureUnlock {
+				exposed, err := exposedWalletAddr(cfg)
+				if err != nil {
+					return nil, err
+				}
+				if exposed != "" {
+					return nil, fmt.Errorf("keys can't be unlocked, signing (eth or wallet namespace) is served on %s, which is not loopback: serve it on 127.0.0.1 only or use --allow-insecure-unlock", exposed)
+				}
+			}
+			content, err := ioutil.ReadFile(cfg.WalletPasswordFile)
+			if err != nil {
+				return nil, err
+			}
+			passwords = strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+			for i := range passwords {
+				passwords[i] = strings.TrimRight(passwords[i], "\r")
+			}
+		}
+		locked, err := ks.UnlockWithPasswords(passwords)
+		if err != nil {
+			return nil, err
+		}
+		all, _ := ks.Accounts(ctx)
+		log.Info("Signing with keystore", "dir", cfg.WalletKeystore, "accounts", len(all), "locked", len(locked))
+		for _, account := range locked {
+			log.Warn("Account of keystore is locked, none of passwords fits", "account", account)
+		}
+		return ks, nil
+	default:
+		return nil, nil
+	}
+}
+
+// exposedWalletAddr returns the address of the first listener, which serves signing methods (eth or wallet namespace)
+// on other interfaces than loopback, "" if there is none
+func exposedWalletAddr(cfg Flags) (string, error) {
+	if hasSigningAPI(cfg.API) {
+		if !isLoopbackHost(cfg.HttpListenAddress) {
+			return fmt.Sprintf("%s:%d", cfg.HttpListenAddress, cfg.HttpPort), nil
+		}
+		if cfg.GrpcListenAddress != "" && !isLoopbackAddr(cfg.GrpcListenAddress) {
+			return cfg.GrpcListenAddress, nil
+		}
+	}
+	listeners, err := parseRpcListeners(cfg.RpcListenersFile)
+	if err != nil {
+		return "", fmt.Errorf("invalid --rpc.listeners: %w", err)
+	}
+	for _, l := range listeners {
+		if hasSigningAPI(l.API) && !isLoopbackAddr(l.Addr) {
+			return l.Addr, nil
+		}
+	}
+	return "", nil
+}
+
+// hasSigningAPI - eth_sign, eth_sendTransaction, etc. are served by the same wallet as the wallet namespace
+func hasSigningAPI(api []string) bool {
+	return hasAPI(api, "eth") || hasAPI(api, "wallet")
+}
+
+func hasAPI(api []string, namespace string) bool {
+	for _, a := range api {
+		if a == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackAddr - host:port, see isLoopbackHost
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(host)
+}
+
+// isLoopbackHost returns false for hostnames other than localhost and for empty host (all interfaces)
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
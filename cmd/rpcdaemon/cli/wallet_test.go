@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/accounts/keystore"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenWalletInsecureUnlock(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := keystore.Open(dir)
+	require.NoError(t, err)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	_, err = ks.StoreKey(key, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	passwords := filepath.Join(t.TempDir(), "passwords")
+	require.NoError(t, ioutil.WriteFile(passwords, []byte("password\n"), 0600))
+
+	cfg := Flags{API: []string{"eth", "wallet"}, HttpListenAddress: "0.0.0.0", HttpPort: 8545, WalletKeystore: dir, WalletPasswordFile: passwords}
+	_, err = OpenWallet(context.Background(), cfg)
+	require.EqualError(t, err, "keys can't be unlocked, signing (eth or wallet namespace) is served on 0.0.0.0:8545, which is not loopback: serve it on 127.0.0.1 only or use --allow-insecure-unlock")
+
+	cfg.AllowInsecureUnlock = true
+	_, err = OpenWallet(context.Background(), cfg)
+	require.NoError(t, err)
+
+	// eth signs by the same wallet
+	cfg.AllowInsecureUnlock = false
+	cfg.API = []string{"eth"}
+	_, err = OpenWallet(context.Background(), cfg)
+	require.Error(t, err)
+	cfg.API = []string{"erigon", "net"}
+	_, err = OpenWallet(context.Background(), cfg)
+	require.NoError(t, err)
+
+	// signing is served only on loopback
+	cfg.API, cfg.HttpListenAddress = []string{"eth", "wallet"}, "localhost"
+	_, err = OpenWallet(context.Background(), cfg)
+	require.NoError(t, err)
+
+	// additional listeners are checked too
+	cfg.RpcListenersFile = filepath.Join(t.TempDir(), "listeners.json")
+	require.NoError(t, ioutil.WriteFile(cfg.RpcListenersFile, []byte(`[{"addr": ":8546", "api": ["wallet"]}]`), 0600))
+	_, err = OpenWallet(context.Background(), cfg)
+	require.EqualError(t, err, "keys can't be unlocked, signing (eth or wallet namespace) is served on :8546, which is not loopback: serve it on 127.0.0.1 only or use --allow-insecure-unlock")
+}
@@ -2,22 +2,87 @@ package commands
 
 import (
 	"context"
+	"os"
 
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/accounts/external"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/filters"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/services"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/transactions"
+	"github.com/ledgerwatch/log/v3"
 )
 
 // APIList describes the list of available RPC apis
-func APIList(ctx context.Context, db kv.RoDB, eth services.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient, filters *filters.Filters, cfg cli.Flags, customAPIList []rpc.API) []rpc.API {
+func APIList(ctx context.Context, db kv.RoDB, eth services.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient, filters *filters.Filters, wallet accounts.Wallet, cfg cli.Flags, customAPIList []rpc.API) []rpc.API {
 	var defaultAPIList []rpc.API
 
 	base := NewBaseApi(filters)
+	go func() {
+		<-ctx.Done()
+		base.Close()
+	}()
+	base.evmLimits = transactions.EVMLimits{MaxMemory: cfg.EVMMaxMemoryMB * 1024 * 1024, MaxCallDepth: cfg.EVMMaxCallDepth}
+	base.poolStats = newPoolStatsCache(cfg.TxPoolGlobalSlots)
+	if latest, err := state.NewSharedCache(cfg.StateCache); err != nil {
+		log.Warn("latest state is not cached", "err", err)
+	} else {
+		base.callState.SetLatest(latest)
+	}
+	base.stateProfiler = state.NewAccessProfiler(cfg.StateProfile)
+	base.callState.SetProfiler(base.stateProfiler)
 	ethImpl := NewEthAPI(base, db, eth, txPool, mining, cfg.Gascap)
-	erigonImpl := NewErigonAPI(base, db)
+	if wallet != nil {
+		ethImpl.wallet = NewWalletAPI(ethImpl, wallet)
+	}
+	ethImpl.logsMaxResults = cfg.LogsMaxResults
+	ethImpl.logsMaxRange = cfg.LogsMaxRange
+	ethImpl.logsMaxTime = cfg.LogsMaxTime
+	if cfg.LogArchiveDir != "" {
+		if _, err := os.Stat(cfg.LogArchiveDir); err == nil {
+			if ethImpl.logArchive, err = logarchive.OpenReadOnly(cfg.LogArchiveDir); err != nil {
+				log.Warn("log archive is not available", "dir", cfg.LogArchiveDir, "err", err)
+			}
+		}
+	}
+	if len(cfg.TxBroadcastEndpoints) > 0 {
+		broadcaster, err := NewTxBroadcaster(cfg.TxBroadcastEndpoints, cfg.TxBroadcastRetries)
+		if err != nil {
+			log.Warn("transactions are not broadcast", "err", err)
+		} else {
+			go broadcaster.Run(ctx)
+			ethImpl.txBroadcaster = broadcaster
+		}
+	}
+	erigonImpl := NewErigonAPI(base, db, txPool)
+	erigonImpl.nodeConfig = NodeConfig{
+		SnapshotMode:        cfg.SnapshotMode,
+		SingleNodeMode:      cfg.SingleNodeMode,
+		API:                 cfg.API,
+		Gascap:              cfg.Gascap,
+		MaxTraces:           cfg.MaxTraces,
+		Websocket:           cfg.WebsocketEnabled,
+		HttpCompression:     cfg.HttpCompression,
+		TLS:                 cfg.TLSCertfile != "",
+		AllowList:           cfg.RpcAllowListFilePath != "",
+		RpcBatchConcurrency: cfg.RpcBatchConcurrency,
+		TraceCompatibility:  cfg.TraceCompatibility,
+	}
+	erigonImpl.datadir = cfg.Datadir
+	erigonImpl.buildBlockKeys = cfg.BuildBlockKeys
+	erigonImpl.sessions = cfg.RpcSessions
+	erigonImpl.ethBackend = eth
+	if cfg.TxMonitorBlocks > 0 && filters != nil {
+		txMonitor := NewTxMonitor(db, txPool, cfg.TxMonitorBlocks)
+		go txMonitor.Run(ctx, filters)
+		ethImpl.txMonitor = txMonitor
+		erigonImpl.txMonitor = txMonitor
+	}
 	txpoolImpl := NewTxPoolAPI(base, db, txPool)
 	netImpl := NewNetAPIImpl(eth)
 	debugImpl := NewPrivateDebugAPI(base, db, cfg.Gascap)
@@ -26,7 +91,11 @@ func APIList(ctx context.Context, db kv.RoDB, eth services.ApiBackend, txPool tx
 	dbImpl := NewDBAPIImpl()   /* deprecated */
 	shhImpl := NewSHHAPIImpl() /* deprecated */
 
-	for _, enabledAPI := range cfg.API {
+	enabledAPIs, err := cli.EnabledAPIs(cfg)
+	if err != nil { // StartRpcServer refuses invalid --rpc.listeners
+		enabledAPIs = cfg.API
+	}
+	for _, enabledAPI := range enabledAPIs {
 		switch enabledAPI {
 		case "eth":
 			defaultAPIList = append(defaultAPIList, rpc.API{
@@ -91,6 +160,29 @@ func APIList(ctx context.Context, db kv.RoDB, eth services.ApiBackend, txPool tx
 				Service:   ErigonAPI(erigonImpl),
 				Version:   "1.0",
 			})
+		case "wallet":
+			if wallet == nil {
+				log.Warn("wallet namespace is disabled, it requires --wallet.keystore or --wallet.signer")
+				continue
+			}
+			defaultAPIList = append(defaultAPIList, rpc.API{
+				Namespace: "wallet",
+				Public:    false,
+				Service:   WalletAPI(ethImpl.wallet),
+				Version:   "1.0",
+			})
+		case "personal":
+			signer, ok := wallet.(*external.Signer)
+			if !ok {
+				log.Warn("personal namespace is disabled, it requires --wallet.signer")
+				continue
+			}
+			defaultAPIList = append(defaultAPIList, rpc.API{
+				Namespace: "personal",
+				Public:    false,
+				Service:   PersonalAPI(NewPersonalAPI(NewWalletAPI(ethImpl, signer), signer)),
+				Version:   "1.0",
+			})
 		}
 	}
 
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIListListeners(t *testing.T) {
+	// the example of "Several listeners with own APIs" of README
+	path := filepath.Join(t.TempDir(), "listeners.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"addr": "0.0.0.0:8546", "api": ["eth", "net"], "keys": ["key1", "key2"], "ratelimit": ["eth_call=50/s"], "quota": ["eth_getLogs=100000"]},
+		{"addr": "127.0.0.1:8547", "api": ["debug", "trace"], "ws": true}
+	]`), 0600))
+	cfg := cli.Flags{API: []string{"eth", "net", "web3"}, RpcListenersFile: path}
+	var namespaces []string
+	for _, api := range APIList(context.Background(), rpcdaemontest.CreateTestKV(t), nil, nil, nil, nil, nil, cfg, nil) {
+		namespaces = append(namespaces, api.Namespace)
+	}
+	require.Equal(t, []string{"eth", "net", "web3", "debug", "trace"}, namespaces)
+}
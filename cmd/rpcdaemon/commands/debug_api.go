@@ -2,12 +2,12 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
-	"github.com/ledgerwatch/erigon/common/changeset"
 	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/consensus/ethash"
 	"github.com/ledgerwatch/erigon/core/rawdb"
@@ -17,6 +17,7 @@ import (
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/eth/tracers"
 	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
 	"github.com/ledgerwatch/erigon/internal/ethapi"
 	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/turbo/transactions"
@@ -31,6 +32,10 @@ type PrivateDebugAPI interface {
 	GetModifiedAccountsByHash(_ context.Context, startHash common.Hash, endHash *common.Hash) ([]common.Address, error)
 	TraceCall(ctx context.Context, args ethapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *tracers.TraceConfig, stream *jsoniter.Stream) error
 	AccountAt(ctx context.Context, blockHash common.Hash, txIndex uint64, account common.Address) (*AccountResult, error)
+	IntermediateRoots(ctx context.Context, blockHash common.Hash, config *tracers.TraceConfig) ([]common.Hash, error)
+	ReadStorageRange(ctx context.Context, address common.Address, startSlot common.Hash, maxResults int, blockNrOrHash rpc.BlockNumberOrHash) (*StorageSlotsResult, error)
+	SetStorageLayout(ctx context.Context, address common.Address, layout json.RawMessage) error
+	StateAccessProfile(ctx context.Context, windows *int, limit *int) (*state.AccessProfile, error)
 }
 
 // PrivateDebugAPIImpl is implementation of the PrivateDebugAPI interface based on remote Db access
@@ -38,14 +43,23 @@ type PrivateDebugAPIImpl struct {
 	*BaseAPI
 	db     kv.RoDB
 	GasCap uint64
+
+	traceCache *traceCache
+	layouts    *storageLayouts
+
+	intermediateRootsMaxDepth uint64 // debug_intermediateRoots refuses blocks deeper below the head
 }
 
 // NewPrivateDebugAPI returns PrivateDebugAPIImpl instance
 func NewPrivateDebugAPI(base *BaseAPI, db kv.RoDB, gascap uint64) *PrivateDebugAPIImpl {
 	return &PrivateDebugAPIImpl{
-		BaseAPI: base,
-		db:      db,
-		GasCap:  gascap,
+		BaseAPI:    base,
+		db:         db,
+		GasCap:     gascap,
+		traceCache: newTraceCache(),
+		layouts:    newStorageLayouts(),
+
+		intermediateRootsMaxDepth: intermediateRootsMaxDepth,
 	}
 }
 
@@ -174,7 +188,7 @@ func (api *PrivateDebugAPIImpl) GetModifiedAccountsByNumber(ctx context.Context,
 		return nil, fmt.Errorf("start block (%d) must be less than or equal to end block (%d)", startNum, endNum)
 	}
 
-	return changeset.GetModifiedAccounts(tx, startNum, endNum)
+	return getModifiedAccounts(tx, startNum, endNum)
 }
 
 // GetModifiedAccountsByHash implements debug_getModifiedAccountsByHash. Returns a list of accounts modified in the given block.
@@ -210,7 +224,24 @@ func (api *PrivateDebugAPIImpl) GetModifiedAccountsByHash(ctx context.Context, s
 		return nil, fmt.Errorf("start block (%d) must be less than or equal to end block (%d)", startNum, endNum)
 	}
 
-	return changeset.GetModifiedAccounts(tx, startNum, endNum)
+	return getModifiedAccounts(tx, startNum, endNum)
+}
+
+// getModifiedAccounts returns accounts modified in blocks [startNum, endNum]
+func getModifiedAccounts(tx kv.Tx, startNum, endNum uint64) ([]common.Address, error) {
+	var result []common.Address
+	seen := map[common.Address]struct{}{}
+	if err := temporal.New(tx).HistoryRange(kv.AccountChangeSet, startNum, endNum+1, func(_ uint64, k, _ []byte) (bool, error) {
+		addr := common.BytesToAddress(k)
+		if _, ok := seen[addr]; !ok {
+			seen[addr] = struct{}{}
+			result = append(result, addr)
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func (api *PrivateDebugAPIImpl) AccountAt(ctx context.Context, blockHash common.Hash, txIndex uint64, address common.Address) (*AccountResult, error) {
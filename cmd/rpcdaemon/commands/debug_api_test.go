@@ -9,6 +9,8 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/eth/tracers"
 	"github.com/ledgerwatch/erigon/internal/ethapi"
 )
@@ -64,6 +66,38 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+func TestTraceTransactionCached(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewPrivateDebugAPI(NewBaseApi(nil), db, 0)
+	trace := func(config *tracers.TraceConfig) []byte {
+		var buf bytes.Buffer
+		stream := jsoniter.NewStream(jsoniter.ConfigDefault, &buf, 4096)
+		if err := api.TraceTransaction(context.Background(), common.HexToHash(debugTraceTransactionTests[2].txHash), config, stream); err != nil {
+			t.Fatalf("traceTransaction: %v", err)
+		}
+		if err := stream.Flush(); err != nil {
+			t.Fatalf("error flusing: %v", err)
+		}
+		return buf.Bytes()
+	}
+	first := trace(&tracers.TraceConfig{})
+	if api.traceCache.cache.Len() != 1 {
+		t.Fatalf("trace was not cached")
+	}
+	if second := trace(&tracers.TraceConfig{}); !bytes.Equal(first, second) {
+		t.Errorf("cached trace differs, got %s, expected %s", second, first)
+	}
+	var norefunds = true
+	if noRefunds := trace(&tracers.TraceConfig{NoRefunds: &norefunds}); bytes.Equal(first, noRefunds) {
+		t.Errorf("trace with different config served from cache")
+	}
+	var custom = "{data: [], step: function() {}, fault: function() {}, result: function() { return 1 }}"
+	trace(&tracers.TraceConfig{Tracer: &custom})
+	if api.traceCache.cache.Len() != 2 {
+		t.Errorf("expected 2 cached traces, got %d", api.traceCache.cache.Len())
+	}
+}
+
 func TestTraceTransactionNoRefund(t *testing.T) {
 	db := rpcdaemontest.CreateTestKV(t)
 	api := NewPrivateDebugAPI(NewBaseApi(nil), db, 0)
@@ -93,3 +127,40 @@ func TestTraceTransactionNoRefund(t *testing.T) {
 		}
 	}
 }
+
+func TestIntermediateRoots(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewPrivateDebugAPI(NewBaseApi(nil), db, 0)
+	tx, err := db.BeginRo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	head, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// state of the parent and of the block itself is checked against the headers by the method
+	for n := uint64(1); n <= head; n++ {
+		block, err := rawdb.ReadBlockByNumber(tx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots, err := api.IntermediateRoots(context.Background(), block.Hash(), nil)
+		if err != nil {
+			t.Fatalf("block %d: %v", n, err)
+		}
+		if len(roots) != len(block.Transactions()) {
+			t.Errorf("block %d: expected %d roots, got %d", n, len(block.Transactions()), len(roots))
+		}
+	}
+
+	api.intermediateRootsMaxDepth = 1
+	block, err := rawdb.ReadBlockByNumber(tx, head-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = api.IntermediateRoots(context.Background(), block.Hash(), nil); err == nil {
+		t.Errorf("block %d deeper than the limit is re-executed", head-2)
+	}
+}
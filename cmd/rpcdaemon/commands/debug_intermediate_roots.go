@@ -0,0 +1,380 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/common/keccakcache"
+	"github.com/ledgerwatch/erigon/consensus/ethash"
+	"github.com/ledgerwatch/erigon/consensus/misc"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+)
+
+// intermediateRootsMaxDepth - blocks deeper below the head are refused by debug_intermediateRoots, restoring
+// the state of their parents reads the history of too many blocks
+const intermediateRootsMaxDepth = 10_000
+
+// IntermediateRoots implements debug_intermediateRoots. Returns state roots after each transaction of the block,
+// block rewards are not included. The block is re-executed on top of the state of its parent, which is restored
+// from the hashed state of the head and the history of keys changed since the block, so the older the block
+// the more expensive the call, blocks deeper than intermediateRootsMaxDepth are refused. The config is accepted for compatibility with geth and ignored.
+func (api *PrivateDebugAPIImpl) IntermediateRoots(ctx context.Context, blockHash common.Hash, _ *tracers.TraceConfig) ([]common.Hash, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	chainConfig, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	if chainConfig.Ethash == nil {
+		return nil, fmt.Errorf("debug_intermediateRoots is supported only for ethash chains")
+	}
+	block, _, err := rawdb.ReadBlockByHashWithSenders(tx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	blockNum := block.NumberU64()
+	if blockNum == 0 {
+		return []common.Hash{}, nil
+	}
+	canonicalHash, err := rawdb.ReadCanonicalHash(tx, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if canonicalHash != blockHash {
+		return nil, fmt.Errorf("block %x is not canonical", blockHash)
+	}
+	// hashed state and intermediate hashes are at the progress of the stage calculating state root
+	head, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return nil, err
+	}
+	if blockNum > head {
+		return nil, fmt.Errorf("block %d is not processed yet, state root is calculated up to block %d", blockNum, head)
+	}
+	if head-blockNum > api.intermediateRootsMaxDepth {
+		return nil, fmt.Errorf("block %d is too old, only the last %d blocks are re-executed", blockNum, api.intermediateRootsMaxDepth)
+	}
+	parent := rawdb.ReadHeader(tx, block.ParentHash(), blockNum-1)
+	if parent == nil {
+		return nil, fmt.Errorf("header %d %x not found", blockNum-1, block.ParentHash())
+	}
+
+	overlay := newStateOverlay(tx)
+	if err = overlay.restore(tx, blockNum, head); err != nil {
+		return nil, err
+	}
+	loader := trie.NewFlatDBTrieLoader("intermediateRoots")
+	root, err := overlay.root(ctx, loader)
+	if err != nil {
+		return nil, err
+	}
+	if root != parent.Root {
+		return nil, fmt.Errorf("restored state of block %d has root %x, expected %x, history may be pruned", blockNum-1, root, parent.Root)
+	}
+
+	header := block.Header()
+	engine := ethash.NewFaker()
+	rules := chainConfig.Rules(blockNum)
+	getHeader := func(hash common.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+	checkTEVM := ethdb.GetCheckTEVM(tx)
+	ibs := state.New(state.NewPlainState(tx, blockNum-1))
+	writer := state.NewDbStateWriter(overlay, blockNum)
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	var usedGas uint64
+	if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(ibs)
+	}
+	roots := make([]common.Hash, 0, len(block.Transactions()))
+	for i, txn := range block.Transactions() {
+		ibs.Prepare(txn.Hash(), blockHash, i)
+		// the writer is called for the changes of the transaction, changes of the DAO fork are written with the first one
+		if _, _, err = core.ApplyTransaction(chainConfig, getHeader, engine, nil, gp, ibs, writer, header, txn, &usedGas, vm.Config{}, checkTEVM); err != nil {
+			return nil, fmt.Errorf("transaction %x failed: %w", txn.Hash(), err)
+		}
+		if root, err = overlay.root(ctx, loader); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	// rewards make the state of the block, it must be what was calculated by the sync
+	if err = engine.Finalize(chainConfig, header, ibs, block.Transactions(), block.Uncles(), nil, nil, nil, nil); err != nil {
+		return nil, err
+	}
+	if err = ibs.FinalizeTx(rules, writer); err != nil {
+		return nil, err
+	}
+	if root, err = overlay.root(ctx, loader); err != nil {
+		return nil, err
+	}
+	if root != header.Root {
+		return nil, fmt.Errorf("re-executed block %d has state root %x, expected %x", blockNum, root, header.Root)
+	}
+	return roots, nil
+}
+
+// stateOverlay is the transaction with accounts and storage slots of the hashed state replaced, empty value means
+// deleted. It's only good for trie.FlatDBTrieLoader: cursors of the hashed state support only the operations
+// used by the loader. Writes of state.DbStateWriter go to the overlay, writes to other tables are ignored.
+type stateOverlay struct {
+	kv.Tx
+	accounts map[string][]byte // by address hash
+	storage  map[string][]byte // by address hash, incarnation and key hash
+}
+
+func newStateOverlay(tx kv.Tx) *stateOverlay {
+	return &stateOverlay{Tx: tx, accounts: map[string][]byte{}, storage: map[string][]byte{}}
+}
+
+// restore replaces the hashed state of the head with the state before the block, using the history
+// of the keys changed in blocks [blockNum, head]
+func (o *stateOverlay) restore(tx kv.Tx, blockNum, head uint64) error {
+	ttx := temporal.New(tx)
+	for _, storage := range []bool{false, true} {
+		table := kv.AccountChangeSet
+		if storage {
+			table = kv.StorageChangeSet
+		}
+		changed := map[string]struct{}{}
+		if err := ttx.HistoryRange(table, blockNum, head+1, func(_ uint64, k, _ []byte) (bool, error) {
+			changed[string(k)] = struct{}{}
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		for k := range changed {
+			v, err := ttx.GetAsOf(table, []byte(k), blockNum)
+			if err != nil {
+				return err
+			}
+			if storage {
+				err = o.putPlainStorage([]byte(k), v)
+			} else {
+				err = o.putPlainAccount([]byte(k), v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *stateOverlay) putPlainAccount(address, v []byte) error {
+	addrHash, err := keccakcache.Shared().HashAddress(address)
+	if err != nil {
+		return err
+	}
+	o.accounts[string(addrHash[:])] = v
+	return nil
+}
+
+func (o *stateOverlay) putPlainStorage(key, v []byte) error {
+	addrHash, err := keccakcache.Shared().HashAddress(key[:common.AddressLength])
+	if err != nil {
+		return err
+	}
+	incarnation := binary.BigEndian.Uint64(key[common.AddressLength:])
+	keyHash, err := keccakcache.Shared().HashStorageKey(key[common.AddressLength+common.IncarnationLength:])
+	if err != nil {
+		return err
+	}
+	o.storage[string(dbutils.GenerateCompositeStorageKey(addrHash, incarnation, keyHash))] = v
+	return nil
+}
+
+func (o *stateOverlay) Put(table string, k, v []byte) error {
+	switch table {
+	case kv.HashedAccounts:
+		o.accounts[string(k)] = common.CopyBytes(v)
+	case kv.HashedStorage:
+		o.storage[string(k)] = common.CopyBytes(v)
+	}
+	return nil
+}
+
+func (o *stateOverlay) Delete(table string, k, _ []byte) error {
+	switch table {
+	case kv.HashedAccounts:
+		o.accounts[string(k)] = nil
+	case kv.HashedStorage:
+		o.storage[string(k)] = nil
+	}
+	return nil
+}
+
+// root calculates the state root, intermediate hashes on the paths to the replaced keys are not used
+func (o *stateOverlay) root(ctx context.Context, loader *trie.FlatDBTrieLoader) (common.Hash, error) {
+	rl := trie.NewRetainList(0)
+	// the keys may be missing in the head state, which intermediate hashes are made of
+	for k := range o.accounts {
+		rl.AddKeyWithMarker([]byte(k), true)
+	}
+	for k := range o.storage {
+		rl.AddKeyWithMarker([]byte(k), true)
+	}
+	if err := loader.Reset(rl, nil, nil, false); err != nil {
+		return common.Hash{}, err
+	}
+	return loader.CalcTrieRoot(o, nil, ctx.Done())
+}
+
+func (o *stateOverlay) Cursor(table string) (kv.Cursor, error) {
+	c, err := o.Tx.Cursor(table)
+	if err != nil || table != kv.HashedAccounts {
+		return c, err
+	}
+	return &overlayCursor{Cursor: c, keys: sortedKeys(o.accounts), values: o.accounts}, nil
+}
+
+func (o *stateOverlay) CursorDupSort(table string) (kv.CursorDupSort, error) {
+	c, err := o.Tx.CursorDupSort(table)
+	if err != nil || table != kv.HashedStorage {
+		return c, err
+	}
+	return &overlayDupCursor{CursorDupSort: c, keys: sortedKeys(o.storage), values: o.storage}, nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// overlayCursor merges the table with the replaced keys, only Seek and Next are supported
+type overlayCursor struct {
+	kv.Cursor
+	keys   []string
+	values map[string][]byte
+	i      int    // next replaced key
+	k, v   []byte // next key of the table
+	fromDB bool   // the last returned key is of the table
+}
+
+func (c *overlayCursor) Seek(seek []byte) ([]byte, []byte, error) {
+	var err error
+	if c.k, c.v, err = c.Cursor.Seek(seek); err != nil {
+		return nil, nil, err
+	}
+	c.i = sort.SearchStrings(c.keys, string(seek))
+	return c.current()
+}
+
+func (c *overlayCursor) Next() ([]byte, []byte, error) {
+	if c.fromDB {
+		var err error
+		if c.k, c.v, err = c.Cursor.Next(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		c.i++
+	}
+	return c.current()
+}
+
+func (c *overlayCursor) current() ([]byte, []byte, error) {
+	for c.k != nil {
+		if _, ok := c.values[string(c.k)]; !ok {
+			break
+		}
+		var err error
+		if c.k, c.v, err = c.Cursor.Next(); err != nil {
+			return nil, nil, err
+		}
+	}
+	for c.i < len(c.keys) && len(c.values[c.keys[c.i]]) == 0 {
+		c.i++
+	}
+	if c.i < len(c.keys) && (c.k == nil || c.keys[c.i] < string(c.k)) {
+		c.fromDB = false
+		return []byte(c.keys[c.i]), c.values[c.keys[c.i]], nil
+	}
+	c.fromDB = true
+	return c.k, c.v, nil
+}
+
+// overlayDupCursor merges HashedStorage with the replaced slots, only SeekBothRange and NextDup are supported
+type overlayDupCursor struct {
+	kv.CursorDupSort
+	keys   []string
+	values map[string][]byte
+	prefix []byte // address hash with incarnation
+	i      int    // next replaced slot
+	v      []byte // next slot of the table: key hash with value
+	fromDB bool
+}
+
+func (c *overlayDupCursor) SeekBothRange(key, value []byte) ([]byte, error) {
+	var err error
+	c.prefix = append(c.prefix[:0], key...)
+	if c.v, err = c.CursorDupSort.SeekBothRange(key, value); err != nil {
+		return nil, err
+	}
+	c.i = sort.SearchStrings(c.keys, string(key)+string(value))
+	return c.current()
+}
+
+func (c *overlayDupCursor) NextDup() ([]byte, []byte, error) {
+	if c.fromDB {
+		var err error
+		if _, c.v, err = c.CursorDupSort.NextDup(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		c.i++
+	}
+	v, err := c.current()
+	if err != nil || v == nil {
+		return nil, nil, err
+	}
+	return c.prefix, v, nil
+}
+
+func (c *overlayDupCursor) current() ([]byte, error) {
+	for c.v != nil {
+		if _, ok := c.values[string(c.prefix)+string(c.v[:common.HashLength])]; !ok {
+			break
+		}
+		var err error
+		if _, c.v, err = c.CursorDupSort.NextDup(); err != nil {
+			return nil, err
+		}
+	}
+	for c.i < len(c.keys) && len(c.values[c.keys[c.i]]) == 0 {
+		c.i++
+	}
+	if c.i < len(c.keys) && bytes.HasPrefix([]byte(c.keys[c.i]), c.prefix) {
+		k := c.keys[c.i][len(c.prefix):]
+		if c.v == nil || k < string(c.v[:common.HashLength]) {
+			c.fromDB = false
+			return append([]byte(k), c.values[c.keys[c.i]]...), nil
+		}
+	}
+	c.fromDB = true
+	return c.v, nil
+}
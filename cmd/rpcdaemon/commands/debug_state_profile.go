@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ledgerwatch/erigon/core/state"
+)
+
+// StateAccessProfile implements debug_stateAccessProfile. Returns the hottest accounts and storage slots read by
+// calls and traces in the last windows of the profiler (nil - all kept ones), limit of each (nil - 100).
+func (api *PrivateDebugAPIImpl) StateAccessProfile(_ context.Context, windows *int, limit *int) (*state.AccessProfile, error) {
+	if api.stateProfiler == nil {
+		return nil, errors.New("state access profiler is disabled, see --state.profile.rate")
+	}
+	var w, l int
+	if windows != nil {
+		w = *windows
+	}
+	if limit != nil {
+		l = *limit
+	}
+	return api.stateProfiler.Profile(w, l), nil
+}
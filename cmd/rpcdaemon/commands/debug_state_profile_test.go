@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateAccessProfile(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	base := NewBaseApi(nil)
+	debugAPI := NewPrivateDebugAPI(base, db, 5000000)
+	_, err := debugAPI.StateAccessProfile(context.Background(), nil, nil)
+	require.Error(t, err)
+
+	base.stateProfiler = state.NewAccessProfiler(state.AccessProfilerConfig{SampleRate: 1})
+	base.callState.SetProfiler(base.stateProfiler)
+	ethAPI := NewEthAPI(base, db, nil, nil, nil, 5000000)
+	from := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+	to := common.HexToAddress("0x0d3ab14bbad3d99f4203bd7a11acb94882050e7e")
+	_, err = ethAPI.Call(context.Background(), ethapi.CallArgs{From: &from, To: &to}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil)
+	require.NoError(t, err)
+
+	profile, err := debugAPI.StateAccessProfile(context.Background(), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, profile.SampleRate)
+	require.Equal(t, profile.Samples, profile.Sources[state.AccessSourceRPC])
+	read := map[common.Address]bool{}
+	for _, a := range profile.Accounts {
+		read[a.Address] = true
+	}
+	require.True(t, read[from])
+	require.True(t, read[to])
+}
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/common/debug"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// maxWatchedAccounts is the max amount of addresses (including addresses with watched storage) per subscription
+	maxWatchedAccounts = 100_000
+	// accountWatchHeadersBuffer is the amount of new headers queued for a subscription while it's reading changesets,
+	// header notifications are delivered synchronously so a slow subscription must not stall the others
+	accountWatchHeadersBuffer = 128
+)
+
+// AccountWatchCriteria selects accounts and storage slots watched by erigon_subscribe("accountChanges")
+type AccountWatchCriteria struct {
+	Addresses []common.Address                 `json:"addresses"`
+	Storage   map[common.Address][]common.Hash `json:"storage"`
+}
+
+// AccountChange is the state of a watched account after the block which changed it
+type AccountChange struct {
+	Address  common.Address              `json:"address"`
+	Deleted  bool                        `json:"deleted,omitempty"`
+	Balance  *hexutil.Big                `json:"balance,omitempty"`
+	Nonce    *hexutil.Uint64             `json:"nonce,omitempty"`
+	CodeHash *common.Hash                `json:"codeHash,omitempty"`
+	Storage  map[common.Hash]common.Hash `json:"storage,omitempty"` // only changed watched slots
+}
+
+// AccountChanges is sent for every canonical block which changed at least one of the watched accounts or slots
+type AccountChanges struct {
+	BlockNumber hexutil.Uint64   `json:"blockNumber"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	Changes     []*AccountChange `json:"changes"`
+}
+
+// accountWatch is the set of accounts and slots of a subscription, in a form suitable for matching changeset entries
+type accountWatch struct {
+	accounts map[common.Address]struct{}
+	storage  map[common.Address]map[common.Hash]struct{}
+}
+
+func newAccountWatch(crit AccountWatchCriteria) (*accountWatch, error) {
+	w := &accountWatch{
+		accounts: make(map[common.Address]struct{}, len(crit.Addresses)),
+		storage:  make(map[common.Address]map[common.Hash]struct{}, len(crit.Storage)),
+	}
+	for _, addr := range crit.Addresses {
+		w.accounts[addr] = struct{}{}
+	}
+	for addr, slots := range crit.Storage {
+		m := make(map[common.Hash]struct{}, len(slots))
+		for _, slot := range slots {
+			m[slot] = struct{}{}
+		}
+		w.storage[addr] = m
+	}
+	if len(w.accounts) == 0 && len(w.storage) == 0 {
+		return nil, fmt.Errorf("no addresses to watch")
+	}
+	if len(w.accounts)+len(w.storage) > maxWatchedAccounts {
+		return nil, fmt.Errorf("too many watched addresses, max %d", maxWatchedAccounts)
+	}
+	return w, nil
+}
+
+// changes matches changesets of the given canonical block against the watched set and reads the new values.
+// Returns nil if none of the watched accounts or slots changed.
+func (w *accountWatch) changes(tx kv.Tx, blockNum uint64, blockHash common.Hash) (*AccountChanges, error) {
+	ttx := temporal.New(tx)
+	reader := state.NewPlainState(tx, blockNum)
+	byAddr := map[common.Address]*AccountChange{}
+	var order []common.Address
+	get := func(addr common.Address) *AccountChange {
+		c, ok := byAddr[addr]
+		if !ok {
+			c = &AccountChange{Address: addr}
+			byAddr[addr] = c
+			order = append(order, addr)
+		}
+		return c
+	}
+
+	if len(w.accounts) > 0 {
+		if err := ttx.HistoryRange(kv.AccountChangeSet, blockNum, blockNum+1, func(_ uint64, k, _ []byte) (bool, error) {
+			addr := common.BytesToAddress(k)
+			if _, ok := w.accounts[addr]; !ok {
+				return true, nil
+			}
+			acc, err := reader.ReadAccountData(addr)
+			if err != nil {
+				return false, err
+			}
+			c := get(addr)
+			if acc == nil {
+				c.Deleted = true
+				return true, nil
+			}
+			nonce, codeHash := hexutil.Uint64(acc.Nonce), acc.CodeHash
+			c.Balance, c.Nonce, c.CodeHash = (*hexutil.Big)(acc.Balance.ToBig()), &nonce, &codeHash
+			return true, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(w.storage) > 0 {
+		if err := ttx.HistoryRange(kv.StorageChangeSet, blockNum, blockNum+1, func(_ uint64, k, _ []byte) (bool, error) {
+			addr, incarnation, slot := dbutils.PlainParseCompositeStorageKey(k)
+			slots, ok := w.storage[addr]
+			if !ok {
+				return true, nil
+			}
+			if _, ok = slots[slot]; !ok {
+				return true, nil
+			}
+			v, err := reader.ReadAccountStorage(addr, incarnation, &slot)
+			if err != nil {
+				return false, err
+			}
+			c := get(addr)
+			if c.Storage == nil {
+				c.Storage = map[common.Hash]common.Hash{}
+			}
+			c.Storage[slot] = common.BytesToHash(v)
+			return true, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(order) == 0 {
+		return nil, nil
+	}
+	result := &AccountChanges{BlockNumber: hexutil.Uint64(blockNum), BlockHash: blockHash, Changes: make([]*AccountChange, len(order))}
+	for i, addr := range order {
+		result.Changes[i] = byAddr[addr]
+	}
+	return result, nil
+}
+
+// AccountChanges implements erigon_subscribe("accountChanges", criteria). For every new canonical block it sends
+// the new state of the watched accounts and storage slots which were changed by the block, as recorded in changesets.
+func (api *ErigonImpl) AccountChanges(ctx context.Context, crit AccountWatchCriteria) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	watch, err := newAccountWatch(crit)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer debug.LogPanic()
+		headers := make(chan *types.Header, accountWatchHeadersBuffer)
+		defer close(headers)
+		id := api.filters.SubscribeNewHeads(headers)
+		defer api.filters.UnsubscribeHeads(id)
+
+		for {
+			select {
+			case h := <-headers:
+				changes, err := api.readAccountChanges(watch, h)
+				if err != nil {
+					log.Warn("error while reading account changes", "block", h.Number, "err", err)
+					continue
+				}
+				if changes == nil {
+					continue
+				}
+				if err = notifier.Notify(rpcSub.ID, changes); err != nil {
+					log.Warn("error while notifying subscription", "err", err)
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+func (api *ErigonImpl) readAccountChanges(watch *accountWatch, header *types.Header) (*AccountChanges, error) {
+	tx, err := api.db.BeginRo(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNum, blockHash := header.Number.Uint64(), header.Hash()
+	canonical, err := rawdb.ReadCanonicalHash(tx, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if canonical != blockHash { // reorged already, the new canonical block is announced separately
+		return nil, nil
+	}
+	return watch.changes(tx, blockNum, blockHash)
+}
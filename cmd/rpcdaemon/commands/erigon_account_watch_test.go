@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountWatchChanges(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	tx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = newAccountWatch(AccountWatchCriteria{})
+	require.Error(t, err)
+
+	// block 1 transfers 0.001 ETH to 0x01
+	watched := common.Address{1}
+	watch, err := newAccountWatch(AccountWatchCriteria{Addresses: []common.Address{watched, {2}}})
+	require.NoError(t, err)
+
+	hash, err := rawdb.ReadCanonicalHash(tx, 1)
+	require.NoError(t, err)
+	changes, err := watch.changes(tx, 1, hash)
+	require.NoError(t, err)
+	require.NotNil(t, changes)
+	require.Equal(t, hash, changes.BlockHash)
+	require.Len(t, changes.Changes, 1)
+	require.Equal(t, watched, changes.Changes[0].Address)
+	require.Equal(t, "1000000000000000", changes.Changes[0].Balance.ToInt().String())
+	require.Equal(t, uint64(0), uint64(*changes.Changes[0].Nonce))
+
+	// nothing watched changes in the genesis block
+	hash, err = rawdb.ReadCanonicalHash(tx, 0)
+	require.NoError(t, err)
+	changes, err = watch.changes(tx, 0, hash)
+	require.NoError(t, err)
+	require.Nil(t, changes)
+}
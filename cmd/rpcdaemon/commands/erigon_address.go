@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+)
+
+// AddressActivity is the range of blocks in which an address took part in calls, as a sender, a recipient,
+// a created contract or a block beneficiary
+type AddressActivity struct {
+	FirstBlock hexutil.Uint64 `json:"firstBlock"`
+	LastBlock  hexutil.Uint64 `json:"lastBlock"`
+}
+
+// GetAddressActivity implements erigon_getAddressActivity. Returns the first and the last block in which the address
+// was active, nil if it never was.
+func (api *ErigonImpl) GetAddressActivity(ctx context.Context, address common.Address) (*AddressActivity, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	first, last, found, err := rawdb.ReadAddressActivity(tx, address)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return &AddressActivity{FirstBlock: hexutil.Uint64(first), LastBlock: hexutil.Uint64(last)}, nil
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAddressActivity(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	// 0x01 receives its first transfer in block 1
+	activity, err := api.GetAddressActivity(ctx, common.Address{1})
+	require.NoError(t, err)
+	require.NotNil(t, activity)
+	require.Equal(t, hexutil.Uint64(1), activity.FirstBlock)
+	require.GreaterOrEqual(t, uint64(activity.LastBlock), uint64(1))
+
+	activity, err = api.GetAddressActivity(ctx, common.HexToAddress("0xdeadbeef"))
+	require.NoError(t, err)
+	require.Nil(t, activity)
+}
@@ -3,9 +3,14 @@ package commands
 import (
 	"context"
 
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/services"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
 	"github.com/ledgerwatch/erigon/rpc"
 )
 
@@ -13,15 +18,43 @@ import (
 type ErigonAPI interface {
 	// System related (see ./erigon_system.go)
 	Forks(ctx context.Context) (Forks, error)
+	ForkId(ctx context.Context) (ForkID, error)
+	NodeInfo(ctx context.Context) (*NodeInfo, error)
+	Capabilities(ctx context.Context) (*Capabilities, error)
+	SyncStages(ctx context.Context) ([]SyncStageInfo, error)
 
 	// Blocks related (see ./erigon_blocks.go)
 	GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
 	GetHeaderByHash(_ context.Context, hash common.Hash) (*types.Header, error)
+	GetBlockByTimestamp(ctx context.Context, timeStamp rpc.Timestamp, fullTx bool, direction *string) (map[string]interface{}, error)
+	GetBlocksByRange(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber, fullTx bool) ([]map[string]interface{}, error)
+
+	// Address related (see ./erigon_address.go)
+	GetAddressActivity(ctx context.Context, address common.Address) (*AddressActivity, error)
 
 	// Receipt related (see ./erigon_receipts.go)
 	GetLogsByHash(ctx context.Context, hash common.Hash) ([][]*types.Log, error)
+	GetLatestLogs(ctx context.Context, crit filters.FilterCriteria, limit hexutil.Uint64) ([]*types.Log, error)
 	//GetLogsByNumber(ctx context.Context, number rpc.BlockNumber) ([][]*types.Log, error)
 
+	// Gas price related (see ./erigon_gas_stats.go, ./erigon_fee_series.go, ./erigon_data_gas_stats.go)
+	GasStats(ctx context.Context) (*GasStats, error)
+	GetFeeSeries(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber, resolution hexutil.Uint64) ([]*FeeSeriesPoint, error)
+	GetDataGasStats(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber) ([]*DataGasStats, error)
+
+	// Subscriptions (see ./erigon_account_watch.go)
+	AccountChanges(ctx context.Context, crit AccountWatchCriteria) (*rpc.Subscription, error)
+
+	// Local transactions (see ./erigon_local_txs.go)
+	LocalTransactions(ctx context.Context) ([]LocalTx, error)
+
+	// Sessions pinning the head across calls (see ./erigon_session.go)
+	OpenSession(ctx context.Context) (*Session, error)
+	CloseSession(ctx context.Context, id string) (bool, error)
+
+	// Block production (see ./erigon_build_block.go)
+	BuildBlock(ctx context.Context, args BuildBlockArgs) (*BuiltBlock, error)
+
 	// Issuance / reward related (see ./erigon_issuance.go)
 	// BlockReward(ctx context.Context, blockNr rpc.BlockNumber) (Issuance, error)
 	// UncleReward(ctx context.Context, blockNr rpc.BlockNumber) (Issuance, error)
@@ -31,13 +64,26 @@ type ErigonAPI interface {
 // ErigonImpl is implementation of the ErigonAPI interface
 type ErigonImpl struct {
 	*BaseAPI
-	db kv.RoDB
+	db     kv.RoDB
+	txPool txpool.TxpoolClient
+
+	blockTimestamps *lru.Cache          // block number -> timestamp, only for blocks deep enough to not be reorged
+	txMonitor       *TxMonitor          // nil if submitted transactions are not monitored
+	ethBackend      services.ApiBackend // nil if not connected to Erigon
+
+	nodeConfig     NodeConfig    // settings of the daemon reported by erigon_nodeInfo, chain fields are filled by the call
+	datadir        string        // disk of it is reported by erigon_nodeInfo, empty - not reported
+	buildBlockKeys []string      // API keys, calls with which may use erigon_buildBlock
+	sessions       *rpc.Sessions // nil if erigon_openSession is disabled
 }
 
 // NewErigonAPI returns ErigonImpl instance
-func NewErigonAPI(base *BaseAPI, db kv.RoDB) *ErigonImpl {
+func NewErigonAPI(base *BaseAPI, db kv.RoDB, txPool txpool.TxpoolClient) *ErigonImpl {
+	blockTimestamps, _ := lru.New(blockTimestampsCacheSize)
 	return &ErigonImpl{
-		BaseAPI: base,
-		db:      db,
+		BaseAPI:         base,
+		db:              db,
+		txPool:          txPool,
+		blockTimestamps: blockTimestamps,
 	}
 }
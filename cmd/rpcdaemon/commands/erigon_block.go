@@ -3,11 +3,15 @@ package commands
 import (
 	"context"
 	"fmt"
+	"sort"
 
+	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/adapter/ethapi"
 )
 
 // GetHeaderByNumber implements erigon_getHeaderByNumber. Returns a block's header given a block number ignoring the block's transaction and uncle list (may be faster).
@@ -27,7 +31,10 @@ func (api *ErigonImpl) GetHeaderByNumber(ctx context.Context, blockNumber rpc.Bl
 	}
 	defer tx.Rollback()
 
-	header := rawdb.ReadHeaderByNumber(tx, uint64(blockNumber.Int64()))
+	header, err := api.canonical.HeaderByNumber(tx, uint64(blockNumber.Int64()))
+	if err != nil {
+		return nil, err
+	}
 	if header == nil {
 		return nil, fmt.Errorf("block header not found: %d", blockNumber.Int64())
 	}
@@ -43,7 +50,7 @@ func (api *ErigonImpl) GetHeaderByHash(ctx context.Context, hash common.Hash) (*
 	}
 	defer tx.Rollback()
 
-	header, err := rawdb.ReadHeaderByHash(tx, hash)
+	header, err := api.canonical.HeaderByHash(tx, hash)
 	if err != nil {
 		return nil, err
 	}
@@ -53,3 +60,165 @@ func (api *ErigonImpl) GetHeaderByHash(ctx context.Context, hash common.Hash) (*
 
 	return header, nil
 }
+
+const (
+	// blockTimestampsCacheSize is the amount of block timestamps kept by erigon_getBlockByTimestamp
+	// the upper levels of the binary search are shared by all lookups, so even a small cache saves most header reads
+	blockTimestampsCacheSize = 4096
+	// blockTimestampsMinDepth is the distance from head after which block timestamps are considered final and can be cached
+	blockTimestampsMinDepth = 128
+	// maxBlocksByRange is the max amount of blocks returned by a single erigon_getBlocksByRange call
+	maxBlocksByRange = 1000
+)
+
+// GetBlockByTimestamp implements erigon_getBlockByTimestamp. Returns the latest block with a timestamp at or before the given one,
+// or, if direction is "after", the earliest block with a timestamp at or after it.
+func (api *ErigonImpl) GetBlockByTimestamp(ctx context.Context, timeStamp rpc.Timestamp, fullTx bool, direction *string) (map[string]interface{}, error) {
+	after := false
+	if direction != nil {
+		switch *direction {
+		case "before", "":
+		case "after":
+			after = true
+		default:
+			return nil, fmt.Errorf("invalid direction %q, expected \"before\" or \"after\"", *direction)
+		}
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	head, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the first block with timestamp greater than (or, for "after", at least) the requested one
+	var searchErr error
+	target := timeStamp.Uint64()
+	idx := sort.Search(int(head)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		t, err := api.blockTimestamp(tx, uint64(i), head)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		if after {
+			return t >= target
+		}
+		return t > target
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	var blockNum uint64
+	if after {
+		if uint64(idx) > head {
+			return nil, nil // not error, no block was produced after the given time yet
+		}
+		blockNum = uint64(idx)
+	} else {
+		if idx == 0 {
+			return nil, nil // not error, the given time is before genesis
+		}
+		blockNum = uint64(idx - 1)
+	}
+
+	block, err := api.getBlockByNumber(rpc.BlockNumber(blockNum), tx)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, nil
+	}
+	additionalFields := make(map[string]interface{})
+	td, err := rawdb.ReadTd(tx, block.Hash(), block.NumberU64())
+	if err != nil {
+		return nil, err
+	}
+	additionalFields["totalDifficulty"] = (*hexutil.Big)(td)
+	return ethapi.RPCMarshalBlock(block, true, fullTx, additionalFields)
+}
+
+// GetBlocksByRange implements erigon_getBlocksByRange. Returns canonical blocks in the inclusive range [from, to],
+// all read within one transaction. The range is capped at maxBlocksByRange blocks.
+func (api *ErigonImpl) GetBlocksByRange(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber, fullTx bool) ([]map[string]interface{}, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	fromNum, err := getBlockNumber(from, tx)
+	if err != nil {
+		return nil, err
+	}
+	toNum, err := getBlockNumber(to, tx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	if fromNum > toNum {
+		return nil, fmt.Errorf("start block (%d) must be less than or equal to end block (%d)", fromNum, toNum)
+	}
+	if toNum-fromNum >= maxBlocksByRange {
+		return nil, fmt.Errorf("requested range of %d blocks exceeds the limit of %d", toNum-fromNum+1, maxBlocksByRange)
+	}
+	if fromNum > latest {
+		return []map[string]interface{}{}, nil
+	}
+	if toNum > latest {
+		toNum = latest
+	}
+
+	result := make([]map[string]interface{}, 0, toNum-fromNum+1)
+	for n := fromNum; n <= toNum; n++ {
+		if err = common.Stopped(ctx.Done()); err != nil {
+			return nil, err
+		}
+		block, err := api.getBlockByNumber(rpc.BlockNumber(n), tx)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			break
+		}
+		td, err := rawdb.ReadTd(tx, block.Hash(), block.NumberU64())
+		if err != nil {
+			return nil, err
+		}
+		response, err := ethapi.RPCMarshalBlock(block, true, fullTx, map[string]interface{}{"totalDifficulty": (*hexutil.Big)(td)})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+// blockTimestamp returns timestamp of the canonical block with given number, using the cache for blocks deep enough below head
+func (api *ErigonImpl) blockTimestamp(tx kv.Tx, number uint64, head uint64) (uint64, error) {
+	if t, ok := api.blockTimestamps.Get(number); ok {
+		return t.(uint64), nil
+	}
+	header, err := api.canonical.HeaderByNumber(tx, number)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block header not found: %d", number)
+	}
+	if number+blockTimestampsMinDepth <= head {
+		api.blockTimestamps.Add(number, header.Time)
+	}
+	return header.Time, nil
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBlockByTimestamp(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	header, err := api.GetHeaderByNumber(ctx, rpc.BlockNumber(5))
+	require.NoError(t, err)
+
+	number := func(block map[string]interface{}) uint64 {
+		require.NotNil(t, block)
+		return block["number"].(*hexutil.Big).ToInt().Uint64()
+	}
+	after := "after"
+
+	block, err := api.GetBlockByTimestamp(ctx, rpc.Timestamp(header.Time), false, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), number(block))
+
+	block, err = api.GetBlockByTimestamp(ctx, rpc.Timestamp(header.Time+1), false, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), number(block))
+
+	block, err = api.GetBlockByTimestamp(ctx, rpc.Timestamp(header.Time), false, &after)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), number(block))
+
+	block, err = api.GetBlockByTimestamp(ctx, rpc.Timestamp(header.Time+1), false, &after)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), number(block))
+
+	block, err = api.GetBlockByTimestamp(ctx, rpc.Timestamp(1<<62), false, &after)
+	require.NoError(t, err)
+	require.Nil(t, block)
+
+	invalid := "around"
+	_, err = api.GetBlockByTimestamp(ctx, rpc.Timestamp(header.Time), false, &invalid)
+	require.Error(t, err)
+}
+
+func TestGetBlocksByRange(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	blocks, err := api.GetBlocksByRange(ctx, rpc.BlockNumber(3), rpc.BlockNumber(6), true)
+	require.NoError(t, err)
+	require.Len(t, blocks, 4)
+	for i, block := range blocks {
+		require.Equal(t, uint64(3+i), block["number"].(*hexutil.Big).ToInt().Uint64())
+		require.NotNil(t, block["totalDifficulty"])
+	}
+
+	// range is clamped at the head
+	blocks, err = api.GetBlocksByRange(ctx, rpc.BlockNumber(8), rpc.BlockNumber(100), false)
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+
+	_, err = api.GetBlocksByRange(ctx, rpc.BlockNumber(6), rpc.BlockNumber(3), false)
+	require.Error(t, err)
+	_, err = api.GetBlocksByRange(ctx, rpc.BlockNumber(0), rpc.BlockNumber(maxBlocksByRange), false)
+	require.Error(t, err)
+}
@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/holiman/uint256"
+	proto_txpool "github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/consensus/ethash"
+	"github.com/ledgerwatch/erigon/consensus/misc"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
+)
+
+// BuildBlockArgs are the fields of the header of the block built by erigon_buildBlock
+type BuildBlockArgs struct {
+	Coinbase  common.Address  `json:"coinbase"`
+	Timestamp *hexutil.Uint64 `json:"timestamp"` // default: now, but after the parent
+	ExtraData *hexutil.Bytes  `json:"extraData"`
+	GasLimit  *hexutil.Uint64 `json:"gasLimit"` // default: gas limit of the parent
+}
+
+// BuiltBlock is the block built from the txpool on top of the latest block
+type BuiltBlock struct {
+	Block        map[string]interface{} `json:"block"`
+	CoinbaseDiff *hexutil.Big           `json:"coinbaseDiff"` // change of the coinbase balance by transactions, without the block reward
+	Results      []*BuildBlockTxResult  `json:"results"`      // all tried transactions in the order of execution
+}
+
+// BuildBlockTxResult is the result of a transaction tried by erigon_buildBlock
+type BuildBlockTxResult struct {
+	Hash         common.Hash     `json:"hash"`
+	From         common.Address  `json:"from"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	Included     bool            `json:"included"`
+	Error        string          `json:"error,omitempty"` // why the transaction is not included
+	Status       *hexutil.Uint64 `json:"status,omitempty"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	CoinbaseDiff *hexutil.Big    `json:"coinbaseDiff,omitempty"`
+	Logs         []*types.Log    `json:"logs,omitempty"`
+}
+
+// BuildBlock implements erigon_buildBlock. Builds a block on top of the latest one from pending transactions of the pool,
+// as a miner would do, and returns it with results of all tried transactions. Nothing is published. State root is
+// not calculated and difficulty follows ethash rules. Only calls with API keys from --rpc.buildblock.keys are allowed.
+func (api *ErigonImpl) BuildBlock(ctx context.Context, args BuildBlockArgs) (*BuiltBlock, error) {
+	if len(api.buildBlockKeys) == 0 {
+		return nil, fmt.Errorf("the method erigon_buildBlock is not available, please use --rpc.buildblock.keys option")
+	}
+	if !api.buildBlockAllowed(rpc.APIKeyFromContext(ctx)) {
+		return nil, fmt.Errorf("erigon_buildBlock: API key is not allowed")
+	}
+	reply, err := api.txPool.All(ctx, &proto_txpool.AllRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	parentNum, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	parent := rawdb.ReadHeaderByNumber(tx, parentNum)
+	if parent == nil {
+		return nil, fmt.Errorf("header %d not found", parentNum)
+	}
+	// plain state is the state after the last executed block, the lagged head (--rpc.headlag) is behind it
+	var stateReader state.StateReader
+	if rpchelper.PlainStateAt(rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), tx) {
+		stateReader = state.NewPlainStateReader(tx)
+	} else {
+		stateReader = state.NewPlainState(tx, parentNum)
+	}
+	header := buildBlockHeader(chainConfig, parent, args)
+	signer := types.MakeSigner(chainConfig, header.Number.Uint64())
+	txs, err := pendingBySender(reply)
+	if err != nil {
+		return nil, err
+	}
+	return api.buildBlock(ctx, tx, stateReader, chainConfig, header, types.NewTransactionsByPriceAndNonce(*signer, txs))
+}
+
+func (api *ErigonImpl) buildBlockAllowed(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, k := range api.buildBlockKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func buildBlockHeader(chainConfig *params.ChainConfig, parent *types.Header, args BuildBlockArgs) *types.Header {
+	timestamp := uint64(time.Now().Unix())
+	if args.Timestamp != nil {
+		timestamp = uint64(*args.Timestamp)
+	} else if timestamp <= parent.Time {
+		timestamp = parent.Time + 1
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   args.Coinbase,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       timestamp,
+		Difficulty: ethash.CalcDifficulty(chainConfig, timestamp, parent.Time, parent.Difficulty, parent.Number.Uint64(), parent.UncleHash),
+	}
+	if args.GasLimit != nil {
+		header.GasLimit = uint64(*args.GasLimit)
+	}
+	if args.ExtraData != nil {
+		header.Extra = *args.ExtraData
+	}
+	if chainConfig.IsLondon(header.Number.Uint64()) {
+		header.BaseFee = misc.CalcBaseFee(chainConfig, parent)
+	}
+	return header
+}
+
+// pendingBySender groups executable transactions of the pool by sender, ordered by nonce
+func pendingBySender(reply *proto_txpool.AllReply) (types.TransactionsGroupedBySender, error) {
+	var groups types.TransactionsGroupedBySender
+	bySender := map[common.Address]int{}
+	for _, t := range reply.Txs {
+		if t.Type != proto_txpool.AllReply_PENDING {
+			continue
+		}
+		txn, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(t.RlpTx), 0))
+		if err != nil {
+			return nil, err
+		}
+		sender := common.BytesToAddress(t.Sender)
+		txn.SetSender(sender)
+		i, ok := bySender[sender]
+		if !ok {
+			i = len(groups)
+			bySender[sender] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], txn)
+	}
+	for _, g := range groups {
+		sort.Sort(types.TxByNonce(g))
+	}
+	return groups, nil
+}
+
+// buildBlock executes transactions on top of the state of the parent until the block is full, like the mining stage does
+func (api *ErigonImpl) buildBlock(ctx context.Context, tx kv.Tx, stateReader state.StateReader, chainConfig *params.ChainConfig, header *types.Header, txs *types.TransactionsByPriceAndNonce) (*BuiltBlock, error) {
+	ibs := state.New(stateReader)
+	if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(ibs)
+	}
+	getHeader := func(hash common.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+	checkTEVM := ethdb.GetCheckTEVM(tx)
+	signer := types.MakeSigner(chainConfig, header.Number.Uint64())
+	vmConfig := api.evmLimits.Apply(vm.Config{})
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+	noop := state.NewNoopWriter()
+	coinbaseBefore := ibs.GetBalance(header.Coinbase).Clone()
+
+	var included []types.Transaction
+	var receipts []*types.Receipt
+	var results []*BuildBlockTxResult
+	for gasPool.Gas() >= params.TxGas {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		txn := txs.Peek()
+		if txn == nil {
+			break
+		}
+		from, _ := txn.Sender(*signer)
+		res := &BuildBlockTxResult{Hash: txn.Hash(), From: from, Nonce: hexutil.Uint64(txn.GetNonce())}
+		results = append(results, res)
+		if txn.Protected() && !chainConfig.IsEIP155(header.Number.Uint64()) {
+			res.Error = "replay protected transaction before EIP-155"
+			txs.Pop()
+			continue
+		}
+
+		balance := ibs.GetBalance(header.Coinbase).Clone()
+		snap := ibs.Snapshot()
+		ibs.Prepare(txn.Hash(), common.Hash{}, len(included))
+		receipt, _, err := core.ApplyTransaction(chainConfig, getHeader, ethash.NewFaker(), &header.Coinbase, gasPool, ibs, noop, header, txn, &header.GasUsed, vmConfig, checkTEVM)
+		if err != nil {
+			ibs.RevertToSnapshot(snap)
+			res.Error = err.Error()
+			switch err {
+			case core.ErrGasLimitReached, core.ErrNonceTooHigh:
+				// the next transactions of the sender can't be executed either
+				txs.Pop()
+			default:
+				txs.Shift()
+			}
+			continue
+		}
+		included = append(included, txn)
+		receipts = append(receipts, receipt)
+		status := hexutil.Uint64(receipt.Status)
+		res.Included, res.Status, res.GasUsed, res.Logs = true, &status, hexutil.Uint64(receipt.GasUsed), receipt.Logs
+		res.CoinbaseDiff = coinbaseDiff(balance, ibs.GetBalance(header.Coinbase))
+		txs.Shift()
+	}
+
+	block := types.NewBlock(header, included, nil, receipts)
+	fields, err := ethapi.RPCMarshalBlock(block, true, true)
+	if err != nil {
+		return nil, err
+	}
+	return &BuiltBlock{
+		Block:        fields,
+		CoinbaseDiff: coinbaseDiff(coinbaseBefore, ibs.GetBalance(header.Coinbase)),
+		Results:      results,
+	}, nil
+}
+
+func coinbaseDiff(before, after *uint256.Int) *hexutil.Big {
+	diff := new(big.Int).Sub(after.ToBig(), before.ToBig())
+	return (*hexutil.Big)(diff)
+}
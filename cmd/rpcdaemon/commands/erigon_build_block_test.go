@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/ethdb/headlag"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildBlock(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	acc, err := state.NewPlainStateReader(tx).ReadAccountData(sender)
+	tx.Rollback()
+	require.NoError(t, err)
+
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	price := uint256.NewInt(params.GWei * 100)
+	pool := &testTxPool{}
+	var txs []types.Transaction
+	for i, nonce := range []uint64{acc.Nonce + 1, acc.Nonce, acc.Nonce + 5, acc.Nonce + 6} {
+		txn, err := types.SignTx(types.NewTransaction(nonce, common.Address{1}, uint256.NewInt(1), params.TxGas, price, nil), *signer, key)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, txn.MarshalBinary(&buf))
+		kind := txpool.AllReply_PENDING
+		if i == 3 {
+			kind = txpool.AllReply_QUEUED
+		}
+		pool.all = append(pool.all, &txpool.AllReply_Tx{Type: kind, Sender: sender.Bytes(), RlpTx: buf.Bytes()})
+		txs = append(txs, txn)
+	}
+
+	api := NewErigonAPI(NewBaseApi(nil), db, pool)
+	_, err = api.BuildBlock(ctx, BuildBlockArgs{})
+	require.Error(t, err, "disabled")
+	api.buildBlockKeys = []string{"secret"}
+	_, err = api.BuildBlock(rpc.ContextWithAPIKey(ctx, "wrong"), BuildBlockArgs{})
+	require.Error(t, err, "not allowed")
+
+	coinbase := common.Address{0xc0}
+	extra := hexutil.Bytes("test")
+	built, err := api.BuildBlock(rpc.ContextWithAPIKey(ctx, "secret"), BuildBlockArgs{Coinbase: coinbase, ExtraData: &extra})
+	require.NoError(t, err)
+	require.Len(t, built.Results, 3)
+	require.Equal(t, txs[1].Hash(), built.Results[0].Hash)
+	require.True(t, built.Results[0].Included)
+	require.Equal(t, uint64(params.TxGas), uint64(built.Results[0].GasUsed))
+	require.Equal(t, txs[0].Hash(), built.Results[1].Hash)
+	require.True(t, built.Results[1].Included)
+	require.Equal(t, txs[2].Hash(), built.Results[2].Hash)
+	require.False(t, built.Results[2].Included)
+	require.Contains(t, built.Results[2].Error, core.ErrNonceTooHigh.Error())
+
+	require.Equal(t, coinbase, built.Block["miner"])
+	require.Equal(t, extra, built.Block["extraData"])
+	require.Equal(t, hexutil.Uint64(2*params.TxGas), built.Block["gasUsed"])
+	require.Len(t, built.Block["transactions"], 2)
+	require.True(t, built.CoinbaseDiff.ToInt().Cmp(big.NewInt(0)) > 0)
+	require.Equal(t, 0, built.CoinbaseDiff.ToInt().Cmp(new(big.Int).Add(built.Results[0].CoinbaseDiff.ToInt(), built.Results[1].CoinbaseDiff.ToInt())))
+}
+
+func TestBuildBlockLagged(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	head, err := getLatestBlockNumber(tx)
+	require.NoError(t, err)
+	latest, err := state.NewPlainStateReader(tx).ReadAccountData(sender)
+	require.NoError(t, err)
+	lagged, err := state.NewPlainState(tx, head-2).ReadAccountData(sender)
+	tx.Rollback()
+	require.NoError(t, err)
+	require.NotEqual(t, latest.Nonce, lagged.Nonce)
+
+	// the block is built on top of the state of the lagged head, not of the plain state
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	txn, err := types.SignTx(types.NewTransaction(lagged.Nonce, common.Address{1}, uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei*100), nil), *signer, key)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, txn.MarshalBinary(&buf))
+	pool := &testTxPool{all: []*txpool.AllReply_Tx{{Type: txpool.AllReply_PENDING, Sender: sender.Bytes(), RlpTx: buf.Bytes()}}}
+	api := NewErigonAPI(NewBaseApi(nil), headlag.WrapDB(db, 2), pool)
+	api.buildBlockKeys = []string{"secret"}
+	built, err := api.BuildBlock(rpc.ContextWithAPIKey(ctx, "secret"), BuildBlockArgs{})
+	require.NoError(t, err)
+	require.Equal(t, (*hexutil.Big)(new(big.Int).SetUint64(head-1)), built.Block["number"])
+	require.Len(t, built.Results, 1)
+	require.True(t, built.Results[0].Included, built.Results[0].Error)
+}
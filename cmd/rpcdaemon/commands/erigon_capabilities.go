@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/common/hexutil"
+)
+
+// RPCSpecVersion is the version of the JSON-RPC interface of rpcdaemon: the minor version is bumped when methods
+// are added, the major one on incompatible changes of existing methods
+const RPCSpecVersion = "1.0"
+
+// Capabilities describes what the node serves, so integrators can check it instead of guessing from
+// eth_protocolVersion or the client version string
+type Capabilities struct {
+	EthProtocols []hexutil.Uint `json:"ethProtocols"` // versions of the eth protocol served by sentries, highest first
+	EngineAPI    []string       `json:"engineApi"`    // versions of the Engine API, empty - not served
+	RPCSpec      string         `json:"rpcSpec"`
+	API          []string       `json:"api"` // enabled namespaces of JSON-RPC
+}
+
+// Capabilities implements erigon_capabilities. Returns protocol and API versions served by the node.
+func (api *ErigonImpl) Capabilities(ctx context.Context) (*Capabilities, error) {
+	if api.ethBackend == nil {
+		return nil, fmt.Errorf("capabilities are not available without connection to Erigon")
+	}
+	versions, err := api.ethBackend.EthProtocols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	capabilities := &Capabilities{
+		EthProtocols: make([]hexutil.Uint, len(versions)),
+		EngineAPI:    []string{},
+		RPCSpec:      RPCSpecVersion,
+		API:          api.nodeConfig.API,
+	}
+	for i, v := range versions {
+		capabilities.EthProtocols[i] = hexutil.Uint(v)
+	}
+	return capabilities, nil
+}
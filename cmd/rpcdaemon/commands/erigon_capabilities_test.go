@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/services"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/ethdb/privateapi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type ethBackendMock struct{ protocols []uint }
+
+func (ethBackendMock) Etherbase() (common.Address, error) { return common.Address{}, nil }
+func (ethBackendMock) NetVersion() (uint64, error)        { return 1, nil }
+func (ethBackendMock) NetPeerCount() (uint64, error)      { return 0, nil }
+func (m ethBackendMock) EthProtocols() []uint             { return m.protocols }
+
+func remoteEthBackend(t *testing.T, eth privateapi.EthBackend) *services.RemoteBackend {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	remote.RegisterETHBACKENDServer(server, privateapi.NewEthBackendServer(eth, privateapi.NewEvents()))
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	backend := services.NewRemoteBackend(conn)
+	require.True(t, backend.EnsureVersionCompatibility())
+	return backend
+}
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	backend := remoteEthBackend(t, ethBackendMock{protocols: []uint{66, 65}})
+	api := NewErigonAPI(NewBaseApi(nil), memdb.NewTestDB(t), nil)
+	api.nodeConfig.API = []string{"eth", "erigon"}
+	api.ethBackend = backend
+	capabilities, err := api.Capabilities(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []hexutil.Uint{66, 65}, capabilities.EthProtocols)
+	require.Empty(t, capabilities.EngineAPI)
+	require.Equal(t, RPCSpecVersion, capabilities.RPCSpec)
+	require.Equal(t, []string{"eth", "erigon"}, capabilities.API)
+
+	// eth_protocolVersion tells the highest version, and the legacy one until sentries are connected
+	version, err := NewEthAPI(NewBaseApi(nil), nil, backend, nil, nil, 5000000).ProtocolVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, hexutil.Uint(66), version)
+	version, err = NewEthAPI(NewBaseApi(nil), nil, remoteEthBackend(t, ethBackendMock{}), nil, nil, 5000000).ProtocolVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, hexutil.Uint(66), version)
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/rpc"
+)
+
+// maxDataGasStatsBlocks is the max amount of blocks returned by a single erigon_getDataGasStats call
+const maxDataGasStatsBlocks = 1000
+
+// DataGasStats is the data attached to transactions of one block and the gas paid for it
+type DataGasStats struct {
+	BlockNumber       hexutil.Uint64 `json:"blockNumber"`
+	TxCount           hexutil.Uint64 `json:"txCount"`
+	CalldataBytes     hexutil.Uint64 `json:"calldataBytes"`
+	CalldataZeroBytes hexutil.Uint64 `json:"calldataZeroBytes"`
+	CalldataGas       hexutil.Uint64 `json:"calldataGas"`
+	BlobCount         hexutil.Uint64 `json:"blobCount"`
+	BlobGasUsed       hexutil.Uint64 `json:"blobGasUsed"`
+}
+
+// GetDataGasStats implements erigon_getDataGasStats. Returns calldata and blob usage of every block in the range
+// [from, to], as counted by the DataGasStats stage. Blocks the stage has not reached yet are not returned.
+func (api *ErigonImpl) GetDataGasStats(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber) ([]*DataGasStats, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	fromNum, err := getBlockNumber(from, tx)
+	if err != nil {
+		return nil, err
+	}
+	toNum, err := getBlockNumber(to, tx)
+	if err != nil {
+		return nil, err
+	}
+	if fromNum > toNum {
+		return nil, fmt.Errorf("from block %d is after to block %d", fromNum, toNum)
+	}
+	if toNum-fromNum+1 > maxDataGasStatsBlocks {
+		return nil, fmt.Errorf("range %d-%d exceeds limit of %d blocks", fromNum, toNum, maxDataGasStatsBlocks)
+	}
+	progress, err := stages.GetStageProgress(tx, stages.DataGasStats)
+	if err != nil {
+		return nil, err
+	}
+	if toNum > progress {
+		toNum = progress
+	}
+
+	stats := []*DataGasStats{}
+	if progress == 0 { // the stage has not run yet
+		return stats, nil
+	}
+	for n := fromNum; n <= toNum; n++ {
+		s, err := rawdb.ReadDataGasStats(tx, n)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, fmt.Errorf("data gas stats of block %d not found", n)
+		}
+		stats = append(stats, &DataGasStats{
+			BlockNumber:       hexutil.Uint64(n),
+			TxCount:           hexutil.Uint64(s.Txs),
+			CalldataBytes:     hexutil.Uint64(s.CalldataBytes),
+			CalldataZeroBytes: hexutil.Uint64(s.CalldataZeroBytes),
+			CalldataGas:       hexutil.Uint64(s.CalldataGas),
+			BlobCount:         hexutil.Uint64(s.Blobs),
+			BlobGasUsed:       hexutil.Uint64(s.BlobGasUsed),
+		})
+	}
+	return stats, nil
+}
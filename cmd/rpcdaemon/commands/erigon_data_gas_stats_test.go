@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDataGasStats(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	latest, err := getLatestBlockNumber(tx)
+	require.NoError(t, err)
+
+	stats, err := api.GetDataGasStats(ctx, rpc.BlockNumber(0), rpc.LatestBlockNumber)
+	require.NoError(t, err)
+	require.Len(t, stats, int(latest)+1)
+	var calldata uint64
+	for n, s := range stats {
+		block, err := rawdb.ReadBlockByNumber(tx, uint64(n))
+		require.NoError(t, err)
+		var bytes, zeroBytes uint64
+		for _, txn := range block.Transactions() {
+			for _, b := range txn.GetData() {
+				if b == 0 {
+					zeroBytes++
+				}
+			}
+			bytes += uint64(len(txn.GetData()))
+		}
+		require.Equal(t, hexutil.Uint64(n), s.BlockNumber)
+		require.Equal(t, hexutil.Uint64(len(block.Transactions())), s.TxCount)
+		require.Equal(t, hexutil.Uint64(bytes), s.CalldataBytes)
+		require.Equal(t, hexutil.Uint64(zeroBytes), s.CalldataZeroBytes)
+		require.Equal(t, hexutil.Uint64(zeroBytes*params.TxDataZeroGas+(bytes-zeroBytes)*params.TxDataNonZeroGasEIP2028), s.CalldataGas)
+		require.Zero(t, s.BlobCount)
+		calldata += bytes
+	}
+	require.NotZero(t, calldata, "test chain has no calldata")
+
+	stats, err = api.GetDataGasStats(ctx, rpc.BlockNumber(3), rpc.BlockNumber(5))
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	require.Equal(t, hexutil.Uint64(3), stats[0].BlockNumber)
+
+	_, err = api.GetDataGasStats(ctx, rpc.BlockNumber(5), rpc.BlockNumber(3))
+	require.Error(t, err)
+	_, err = api.GetDataGasStats(ctx, rpc.BlockNumber(0), rpc.BlockNumber(maxDataGasStatsBlocks))
+	require.Error(t, err)
+}
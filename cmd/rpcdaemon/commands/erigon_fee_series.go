@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/rpc"
+)
+
+// maxFeeSeriesPoints is the max amount of points returned by a single erigon_getFeeSeries call
+const maxFeeSeriesPoints = 1000
+
+// FeeSeriesPoint aggregates fee market data of consecutive blocks [FromBlock, ToBlock]
+type FeeSeriesPoint struct {
+	FromBlock      hexutil.Uint64          `json:"fromBlock"`
+	ToBlock        hexutil.Uint64          `json:"toBlock"`
+	TxCount        hexutil.Uint64          `json:"txCount"`
+	BaseFee        *hexutil.Big            `json:"baseFee"` // mean base fee, nil before London
+	MinBaseFee     *hexutil.Big            `json:"minBaseFee"`
+	MaxBaseFee     *hexutil.Big            `json:"maxBaseFee"`
+	GasUsedRatio   float64                 `json:"gasUsedRatio"`
+	TipPercentiles map[string]*hexutil.Big `json:"tipPercentiles"`
+}
+
+// GetFeeSeries implements erigon_getFeeSeries. Returns base fee, gas used ratio and tip percentiles of the blocks in
+// the range [from, to], downsampled to one point per resolution blocks. Data comes from the per-epoch aggregates
+// of the FeeSeries stage, so resolution must be a multiple of the epoch size and the range is aligned to epochs.
+// Tip percentiles of points spanning several epochs are means of the epochs' percentiles weighted by their tx count.
+func (api *ErigonImpl) GetFeeSeries(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber, resolution hexutil.Uint64) ([]*FeeSeriesPoint, error) {
+	if resolution == 0 || uint64(resolution)%rawdb.FeeEpochSize != 0 {
+		return nil, fmt.Errorf("resolution must be a positive multiple of %d", rawdb.FeeEpochSize)
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	fromNum, err := getBlockNumber(from, tx)
+	if err != nil {
+		return nil, err
+	}
+	toNum, err := getBlockNumber(to, tx)
+	if err != nil {
+		return nil, err
+	}
+	if fromNum > toNum {
+		return nil, fmt.Errorf("from block %d is after to block %d", fromNum, toNum)
+	}
+	progress, err := stages.GetStageProgress(tx, stages.FeeSeries)
+	if err != nil {
+		return nil, err
+	}
+	if toNum > progress {
+		toNum = progress
+	}
+	if fromNum > toNum {
+		return []*FeeSeriesPoint{}, nil
+	}
+
+	epochsPerPoint := uint64(resolution) / rawdb.FeeEpochSize
+	firstEpoch, lastEpoch := rawdb.FeeEpochOf(fromNum), rawdb.FeeEpochOf(toNum)
+	if (lastEpoch-firstEpoch)/epochsPerPoint+1 > maxFeeSeriesPoints {
+		return nil, fmt.Errorf("range %d-%d at resolution %d exceeds limit of %d points", fromNum, toNum, resolution, maxFeeSeriesPoints)
+	}
+
+	points := make([]*FeeSeriesPoint, 0, (lastEpoch-firstEpoch)/epochsPerPoint+1)
+	for start := firstEpoch; start <= lastEpoch; start += epochsPerPoint {
+		end := start + epochsPerPoint - 1
+		if end > lastEpoch {
+			end = lastEpoch
+		}
+		epochs := make([]*rawdb.FeeEpoch, 0, end-start+1)
+		for epoch := start; epoch <= end; epoch++ {
+			e, err := rawdb.ReadFeeEpoch(tx, epoch)
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				return nil, fmt.Errorf("fee epoch %d not found", epoch)
+			}
+			epochs = append(epochs, e)
+		}
+		points = append(points, mergeFeeEpochs(start*rawdb.FeeEpochSize, epochs))
+	}
+	return points, nil
+}
+
+// mergeFeeEpochs combines consecutive epochs, the first of which starts at fromBlock, into one point
+func mergeFeeEpochs(fromBlock uint64, epochs []*rawdb.FeeEpoch) *FeeSeriesPoint {
+	var blocks, txs, gasUsed, gasLimit, baseFeeBlocks uint64
+	baseFeeSum := new(big.Int)
+	var minBaseFee, maxBaseFee *big.Int
+	tipSums := make([]*big.Int, len(rawdb.FeeEpochTipPercentiles))
+	for i := range tipSums {
+		tipSums[i] = new(big.Int)
+	}
+	for _, e := range epochs {
+		blocks += e.Blocks
+		txs += e.Txs
+		gasUsed += e.GasUsed
+		gasLimit += e.GasLimit
+		if e.BaseFeeBlocks > 0 {
+			baseFeeBlocks += e.BaseFeeBlocks
+			baseFeeSum.Add(baseFeeSum, e.BaseFeeSum)
+			if minBaseFee == nil || e.MinBaseFee.Cmp(minBaseFee) < 0 {
+				minBaseFee = e.MinBaseFee
+			}
+			if maxBaseFee == nil || e.MaxBaseFee.Cmp(maxBaseFee) > 0 {
+				maxBaseFee = e.MaxBaseFee
+			}
+		}
+		for i, tip := range e.TipPercentiles {
+			tipSums[i].Add(tipSums[i], new(big.Int).Mul(tip, new(big.Int).SetUint64(e.Txs)))
+		}
+	}
+
+	point := &FeeSeriesPoint{
+		FromBlock:      hexutil.Uint64(fromBlock),
+		ToBlock:        hexutil.Uint64(fromBlock + blocks - 1),
+		TxCount:        hexutil.Uint64(txs),
+		MinBaseFee:     (*hexutil.Big)(minBaseFee),
+		MaxBaseFee:     (*hexutil.Big)(maxBaseFee),
+		TipPercentiles: make(map[string]*hexutil.Big, len(rawdb.FeeEpochTipPercentiles)),
+	}
+	if gasLimit > 0 {
+		point.GasUsedRatio = float64(gasUsed) / float64(gasLimit)
+	}
+	if baseFeeBlocks > 0 {
+		point.BaseFee = (*hexutil.Big)(baseFeeSum.Div(baseFeeSum, new(big.Int).SetUint64(baseFeeBlocks)))
+	}
+	if txs > 0 {
+		for i, p := range rawdb.FeeEpochTipPercentiles {
+			point.TipPercentiles[fmt.Sprintf("%d", p)] = (*hexutil.Big)(tipSums[i].Div(tipSums[i], new(big.Int).SetUint64(txs)))
+		}
+	}
+	return point
+}
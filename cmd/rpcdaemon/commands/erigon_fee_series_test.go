@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFeeSeries(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	latest, err := getLatestBlockNumber(tx)
+	require.NoError(t, err)
+	var txCount, gasUsed, gasLimit uint64
+	for n := uint64(0); n <= latest; n++ {
+		block, err := rawdb.ReadBlockByNumber(tx, n)
+		require.NoError(t, err)
+		txCount += uint64(len(block.Transactions()))
+		gasUsed += block.GasUsed()
+		gasLimit += block.GasLimit()
+	}
+
+	points, err := api.GetFeeSeries(ctx, rpc.BlockNumber(0), rpc.LatestBlockNumber, rawdb.FeeEpochSize)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	require.Equal(t, hexutil.Uint64(0), points[0].FromBlock)
+	require.Equal(t, hexutil.Uint64(latest), points[0].ToBlock)
+	require.Equal(t, hexutil.Uint64(txCount), points[0].TxCount)
+	require.Equal(t, float64(gasUsed)/float64(gasLimit), points[0].GasUsedRatio)
+	require.Len(t, points[0].TipPercentiles, len(rawdb.FeeEpochTipPercentiles))
+
+	// range is aligned to epochs
+	points, err = api.GetFeeSeries(ctx, rpc.BlockNumber(3), rpc.BlockNumber(5), 4*rawdb.FeeEpochSize)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	require.Equal(t, hexutil.Uint64(0), points[0].FromBlock)
+
+	_, err = api.GetFeeSeries(ctx, rpc.BlockNumber(0), rpc.LatestBlockNumber, rawdb.FeeEpochSize+1)
+	require.Error(t, err)
+	_, err = api.GetFeeSeries(ctx, rpc.BlockNumber(5), rpc.BlockNumber(3), rawdb.FeeEpochSize)
+	require.Error(t, err)
+}
+
+func TestMergeFeeEpochs(t *testing.T) {
+	epochs := []*rawdb.FeeEpoch{
+		{
+			Blocks: rawdb.FeeEpochSize, Txs: 1, GasUsed: 10, GasLimit: 100,
+			BaseFeeSum: new(big.Int), MinBaseFee: new(big.Int), MaxBaseFee: new(big.Int),
+			TipPercentiles: []*big.Int{big.NewInt(4), big.NewInt(4), big.NewInt(4), big.NewInt(4), big.NewInt(4)},
+		},
+		{
+			Blocks: rawdb.FeeEpochSize, Txs: 3, GasUsed: 30, GasLimit: 100, BaseFeeBlocks: 2,
+			BaseFeeSum: big.NewInt(30), MinBaseFee: big.NewInt(10), MaxBaseFee: big.NewInt(20),
+			TipPercentiles: []*big.Int{big.NewInt(8), big.NewInt(8), big.NewInt(8), big.NewInt(8), big.NewInt(8)},
+		},
+	}
+	point := mergeFeeEpochs(rawdb.FeeEpochSize, epochs)
+	require.Equal(t, hexutil.Uint64(rawdb.FeeEpochSize), point.FromBlock)
+	require.Equal(t, hexutil.Uint64(3*rawdb.FeeEpochSize-1), point.ToBlock)
+	require.Equal(t, hexutil.Uint64(4), point.TxCount)
+	require.Equal(t, 0.2, point.GasUsedRatio)
+	require.Equal(t, int64(15), point.BaseFee.ToInt().Int64())
+	require.Equal(t, int64(10), point.MinBaseFee.ToInt().Int64())
+	require.Equal(t, int64(20), point.MaxBaseFee.ToInt().Int64())
+	require.Equal(t, int64(7), point.TipPercentiles["50"].ToInt().Int64())
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/holiman/uint256"
+	proto_txpool "github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/consensus/misc"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+const (
+	// gasStatsTrendBlocks is the number of recent headers whose base fee is reported by erigon_gasStats
+	gasStatsTrendBlocks = 20
+	// congestedPoolFullness is the share of pending slots above which the pool is considered congested
+	// and eth_gasPrice suggestions are floored at the cheapest executable pending tip
+	congestedPoolFullness = 0.8
+)
+
+// gasStatsPercentiles are the percentiles of pending tips reported by erigon_gasStats
+var gasStatsPercentiles = []int{10, 25, 50, 75, 90}
+
+// GasStats is a snapshot of the fee market as seen by this node: recent base fees and pending txpool tips
+type GasStats struct {
+	BaseFee          *hexutil.Big            `json:"baseFee"`
+	NextBaseFee      *hexutil.Big            `json:"nextBaseFee"`
+	BaseFeeTrend     []*hexutil.Big          `json:"baseFeeTrend"`
+	PendingCount     hexutil.Uint            `json:"pendingCount"`
+	QueuedCount      hexutil.Uint            `json:"queuedCount"`
+	PoolFullness     float64                 `json:"poolFullness"`
+	Congested        bool                    `json:"congested"`
+	MinExecutableTip *hexutil.Big            `json:"minExecutableTip"`
+	TipPercentiles   map[string]*hexutil.Big `json:"tipPercentiles"`
+}
+
+// pendingPoolStats summarises the executable part of the txpool at a given base fee
+type pendingPoolStats struct {
+	tips    []*uint256.Int // effective tips of pending transactions, sorted ascending
+	pending uint64
+	queued  uint64
+	slots   uint64 // pending slots of the pool
+}
+
+// fullness returns the share of pending slots in use
+func (s *pendingPoolStats) fullness() float64 {
+	if s.slots == 0 {
+		return 0
+	}
+	return float64(s.pending) / float64(s.slots)
+}
+
+func (s *pendingPoolStats) congested() bool {
+	return s.fullness() >= congestedPoolFullness
+}
+
+// minTip returns the lowest effective tip among pending transactions, nil if there are none
+func (s *pendingPoolStats) minTip() *uint256.Int {
+	if len(s.tips) == 0 {
+		return nil
+	}
+	return s.tips[0]
+}
+
+// percentile returns the tip at the given percentile (0-100), nil if there are no pending transactions
+func (s *pendingPoolStats) percentile(p int) *uint256.Int {
+	if len(s.tips) == 0 {
+		return nil
+	}
+	return s.tips[(len(s.tips)-1)*p/100]
+}
+
+// readPendingPoolStats collects effective tips of all pending transactions in the pool,
+// evaluated against the base fee of the next block (nil before London)
+func readPendingPoolStats(ctx context.Context, pool proto_txpool.TxpoolClient, baseFee *uint256.Int, slots uint64) (*pendingPoolStats, error) {
+	reply, err := pool.All(ctx, &proto_txpool.AllRequest{})
+	if err != nil {
+		return nil, err
+	}
+	stats := &pendingPoolStats{tips: make([]*uint256.Int, 0, len(reply.Txs)), slots: slots}
+	for i := range reply.Txs {
+		if reply.Txs[i].Type != proto_txpool.AllReply_PENDING {
+			stats.queued++
+			continue
+		}
+		stats.pending++
+		txn, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(reply.Txs[i].RlpTx), 0))
+		if err != nil {
+			return nil, err
+		}
+		if baseFee != nil && txn.GetFeeCap().Lt(baseFee) { // not executable in the next block
+			continue
+		}
+		stats.tips = append(stats.tips, txn.GetEffectiveGasTip(baseFee))
+	}
+	sort.Slice(stats.tips, func(i, j int) bool { return stats.tips[i].Lt(stats.tips[j]) })
+	return stats, nil
+}
+
+// poolStatsCache keeps the pending pool stats of the last head, so eth_gasPrice and erigon_gasStats
+// decode the whole pool once per block, not on every call
+type poolStatsCache struct {
+	slots uint64 // pending slots of the pool, see --txpool.globalslots
+
+	lock  sync.Mutex
+	head  common.Hash
+	stats *pendingPoolStats
+}
+
+func newPoolStatsCache(slots uint64) *poolStatsCache {
+	return &poolStatsCache{slots: slots}
+}
+
+// get returns the stats of the pool at the given head, reading them from the pool once per head.
+// Concurrent callers wait for the read instead of repeating it.
+func (c *poolStatsCache) get(ctx context.Context, pool proto_txpool.TxpoolClient, head *types.Header, baseFee *uint256.Int) (*pendingPoolStats, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.stats != nil && c.head == head.Hash() {
+		return c.stats, nil
+	}
+	stats, err := readPendingPoolStats(ctx, pool, baseFee, c.slots)
+	if err != nil {
+		return nil, err
+	}
+	c.head, c.stats = head.Hash(), stats
+	return stats, nil
+}
+
+// nextBaseFee returns the base fee of the block following the given header, nil if London is not active yet
+func nextBaseFee(cc *params.ChainConfig, head *types.Header) *big.Int {
+	if head == nil || !cc.IsLondon(head.Number.Uint64()+1) {
+		return nil
+	}
+	return misc.CalcBaseFee(cc, head)
+}
+
+// GasStats implements erigon_gasStats. Returns recent base fees together with percentiles of pending txpool tips.
+func (api *ErigonImpl) GasStats(ctx context.Context) (*GasStats, error) {
+	if api.txPool == nil {
+		return nil, fmt.Errorf("erigon_gasStats requires a connection to the txpool")
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cc, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	head := rawdb.ReadCurrentHeader(tx)
+	if head == nil {
+		return nil, fmt.Errorf("current header not found")
+	}
+
+	result := &GasStats{
+		BaseFee:        (*hexutil.Big)(head.BaseFee),
+		BaseFeeTrend:   make([]*hexutil.Big, 0, gasStatsTrendBlocks),
+		TipPercentiles: make(map[string]*hexutil.Big, len(gasStatsPercentiles)),
+	}
+	from := uint64(0)
+	if head.Number.Uint64()+1 > gasStatsTrendBlocks {
+		from = head.Number.Uint64() + 1 - gasStatsTrendBlocks
+	}
+	headers, err := rawdb.ReadHeadersByNumbers(tx, from, head.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		if h == nil || h.BaseFee == nil {
+			continue
+		}
+		result.BaseFeeTrend = append(result.BaseFeeTrend, (*hexutil.Big)(h.BaseFee))
+	}
+
+	var baseFee *uint256.Int
+	if next := nextBaseFee(cc, head); next != nil {
+		result.NextBaseFee = (*hexutil.Big)(next)
+		baseFee, _ = uint256.FromBig(next)
+	}
+	stats, err := api.poolStats.get(ctx, api.txPool, head, baseFee)
+	if err != nil {
+		return nil, err
+	}
+	result.PendingCount = hexutil.Uint(stats.pending)
+	result.QueuedCount = hexutil.Uint(stats.queued)
+	result.PoolFullness = stats.fullness()
+	result.Congested = stats.congested()
+	if tip := stats.minTip(); tip != nil {
+		result.MinExecutableTip = (*hexutil.Big)(tip.ToBig())
+	}
+	for _, p := range gasStatsPercentiles {
+		if tip := stats.percentile(p); tip != nil {
+			result.TipPercentiles[fmt.Sprintf("%d", p)] = (*hexutil.Big)(tip.ToBig())
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestPendingPoolStats(t *testing.T) {
+	require := require.New(t)
+
+	empty := &pendingPoolStats{}
+	require.Nil(empty.minTip())
+	require.Nil(empty.percentile(50))
+	require.False(empty.congested())
+
+	stats := &pendingPoolStats{pending: core.DefaultTxPoolConfig.GlobalSlots, slots: core.DefaultTxPoolConfig.GlobalSlots}
+	for i := uint64(1); i <= 10; i++ {
+		stats.tips = append(stats.tips, uint256.NewInt(i))
+	}
+	require.True(stats.congested())
+	require.Equal(uint64(1), stats.minTip().Uint64())
+	require.Equal(uint64(1), stats.percentile(0).Uint64())
+	require.Equal(uint64(5), stats.percentile(50).Uint64())
+	require.Equal(uint64(10), stats.percentile(100).Uint64())
+
+	stats.pending = core.DefaultTxPoolConfig.GlobalSlots / 2
+	require.False(stats.congested())
+}
+
+// countingTxPool counts reads of the whole pool
+type countingTxPool struct {
+	testTxPool
+	reads int
+}
+
+func (p *countingTxPool) All(ctx context.Context, in *txpool.AllRequest, opts ...grpc.CallOption) (*txpool.AllReply, error) {
+	p.reads++
+	return p.testTxPool.All(ctx, in, opts...)
+}
+
+func TestPoolStatsCache(t *testing.T) {
+	ctx := context.Background()
+	txn := types.NewTransaction(0, common.Address{1}, uint256.NewInt(1), 21000, uint256.NewInt(5), nil)
+	var buf bytes.Buffer
+	require.NoError(t, txn.MarshalBinary(&buf))
+	pool := &countingTxPool{testTxPool: testTxPool{all: []*txpool.AllReply_Tx{{Type: txpool.AllReply_PENDING, RlpTx: buf.Bytes()}}}}
+
+	cache := newPoolStatsCache(2)
+	head := &types.Header{Number: big.NewInt(1)}
+	stats, err := cache.get(ctx, pool, head, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stats.pending)
+	require.Equal(t, 0.5, stats.fullness())
+	_, err = cache.get(ctx, pool, head, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, pool.reads)
+
+	_, err = cache.get(ctx, pool, &types.Header{Number: big.NewInt(2)}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, pool.reads)
+}
@@ -0,0 +1,15 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+)
+
+// LocalTransactions implements erigon_localTransactions. Returns transactions submitted via this rpcdaemon, which are
+// pending, or were mined or dropped from the pool recently.
+func (api *ErigonImpl) LocalTransactions(_ context.Context) ([]LocalTx, error) {
+	if api.txMonitor == nil {
+		return nil, fmt.Errorf("the method erigon_localTransactions is not available, please use --txmonitor.blocks option")
+	}
+	return api.txMonitor.Txs(), nil
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/ethdb/prune"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// NodeInfo is a snapshot of build, configuration and hardware of the node, suitable to be attached to bug reports.
+// It doesn't contain addresses, paths or any other data which could identify the operator.
+type NodeInfo struct {
+	Build    BuildInfo    `json:"build"`
+	Config   NodeConfig   `json:"config"`
+	Hardware HardwareInfo `json:"hardware"`
+}
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	GitBranch string `json:"gitBranch"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+type NodeConfig struct {
+	ChainID             *hexutil.Big `json:"chainId"`
+	Genesis             common.Hash  `json:"genesis"`
+	PruneMode           string       `json:"pruneMode"`
+	SnapshotMode        string       `json:"snapshotMode"`
+	SingleNodeMode      bool         `json:"singleNodeMode"`
+	API                 []string     `json:"api"`
+	Gascap              uint64       `json:"gascap"`
+	MaxTraces           uint64       `json:"maxTraces"`
+	Websocket           bool         `json:"websocket"`
+	HttpCompression     bool         `json:"httpCompression"`
+	TLS                 bool         `json:"tls"`
+	AllowList           bool         `json:"allowList"`
+	RpcBatchConcurrency uint         `json:"rpcBatchConcurrency"`
+	TraceCompatibility  bool         `json:"traceCompatibility"`
+}
+
+// HardwareInfo describes the machine rpcdaemon runs on. Disk fields are only filled when the datadir is local.
+type HardwareInfo struct {
+	CPUs        int    `json:"cpus"`
+	CPUModel    string `json:"cpuModel"`
+	TotalMemory uint64 `json:"totalMemory"`
+	DiskType    string `json:"diskType,omitempty"`
+	DiskTotal   uint64 `json:"diskTotal,omitempty"`
+	DiskFree    uint64 `json:"diskFree,omitempty"`
+}
+
+// NodeInfo implements erigon_nodeInfo. Returns sanitized snapshot of the node's build, configuration and hardware.
+func (api *ErigonImpl) NodeInfo(ctx context.Context) (*NodeInfo, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	chainConfig, genesis, err := api.chainConfigWithGenesis(tx)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := prune.Get(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := api.nodeConfig
+	config.ChainID = (*hexutil.Big)(chainConfig.ChainID)
+	config.Genesis = genesis.Hash()
+	config.PruneMode = pm.String()
+	info := &NodeInfo{
+		Build: BuildInfo{
+			Version:   params.VersionWithMeta,
+			GitCommit: params.GitCommit,
+			GitBranch: params.GitBranch,
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+		},
+		Config:   config,
+		Hardware: readHardwareInfo(api.datadir),
+	}
+	return info, nil
+}
+
+// readHardwareInfo collects hardware profile on best effort basis, fields which can't be read are left empty
+func readHardwareInfo(datadir string) HardwareInfo {
+	info := HardwareInfo{CPUs: runtime.NumCPU()}
+	if cpus, err := cpu.Info(); err == nil && len(cpus) > 0 {
+		info.CPUModel = cpus[0].ModelName
+	}
+	if m, err := mem.VirtualMemory(); err == nil {
+		info.TotalMemory = m.Total
+	}
+	if datadir == "" {
+		return info
+	}
+	if usage, err := disk.Usage(datadir); err == nil {
+		info.DiskTotal = usage.Total
+		info.DiskFree = usage.Free
+	}
+	info.DiskType = diskType(datadir)
+	return info
+}
+
+// diskType guesses type of the disk holding given path: "nvme", "ssd", "hdd" or "unknown".
+// Heuristic relies on /sys/class/block, so on other than Linux systems it's always "unknown".
+func diskType(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "unknown"
+	}
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return "unknown"
+	}
+	var device, mountpoint string
+	for _, p := range partitions {
+		if underMountpoint(path, p.Mountpoint) && len(p.Mountpoint) > len(mountpoint) {
+			device, mountpoint = p.Device, p.Mountpoint
+		}
+	}
+	name := filepath.Base(device)
+	if device == "" || name == "" {
+		return "unknown"
+	}
+	if strings.HasPrefix(name, "nvme") {
+		return "nvme"
+	}
+	// For partitions the queue directory belongs to the parent device
+	sysPath, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return "unknown"
+	}
+	for _, dir := range []string{sysPath, filepath.Dir(sysPath)} {
+		rotational, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(rotational)) == "1" {
+			return "hdd"
+		}
+		return "ssd"
+	}
+	return "unknown"
+}
+
+// underMountpoint reports whether the absolute path is the mountpoint or inside it, by whole path components:
+// /data2 is not under /data
+func underMountpoint(path, mountpoint string) bool {
+	if mountpoint == "" {
+		return false
+	}
+	if path == mountpoint || mountpoint == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(mountpoint, string(filepath.Separator))+string(filepath.Separator))
+}
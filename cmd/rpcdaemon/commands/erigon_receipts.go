@@ -5,8 +5,15 @@ import (
 	"fmt"
 
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+)
+
+const (
+	latestLogsMaxLimit = 10000  // of erigon_getLatestLogs
+	latestLogsWindow   = 100000 // blocks, log indices are read by windows of so many blocks going back from the head
 )
 
 // GetLogsByHash implements erigon_getLogsByHash. Returns an array of arrays of logs generated by the transactions in the block given by the block's hash.
@@ -38,6 +45,66 @@ func (api *ErigonImpl) GetLogsByHash(ctx context.Context, hash common.Hash) ([][
 	return logs, nil
 }
 
+// GetLatestLogs implements erigon_getLatestLogs. Returns up to limit most recent logs matching the criteria, the
+// most recent first, scanning log indices backwards from toBlock (the head by default) to fromBlock (genesis).
+func (api *ErigonImpl) GetLatestLogs(ctx context.Context, crit filters.FilterCriteria, limit hexutil.Uint64) ([]*types.Log, error) {
+	if limit == 0 || limit > latestLogsMaxLimit {
+		return nil, fmt.Errorf("limit must be in [1, %d]", latestLogsMaxLimit)
+	}
+	if crit.BlockHash != nil {
+		return nil, fmt.Errorf("blockHash is not supported, use erigon_getLogsByHash")
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	latest, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	var begin uint64
+	if crit.FromBlock != nil && crit.FromBlock.Sign() > 0 {
+		begin = crit.FromBlock.Uint64()
+	}
+	end := latest
+	if crit.ToBlock != nil && crit.ToBlock.Sign() > 0 && crit.ToBlock.Uint64() < latest {
+		end = crit.ToBlock.Uint64()
+	}
+
+	logs := []*types.Log{}
+	for windowEnd := end; windowEnd >= begin; windowEnd -= latestLogsWindow {
+		windowBegin := begin
+		if windowEnd-begin >= latestLogsWindow {
+			windowBegin = windowEnd - latestLogsWindow + 1
+		}
+		blocks, err := getLogsBlocks(tx, crit, windowBegin, windowEnd)
+		if err != nil {
+			return nil, err
+		}
+		for it := blocks.ReverseIterator(); it.HasNext(); {
+			if err = common.Stopped(ctx.Done()); err != nil {
+				return nil, err
+			}
+			blockLogs, err := getBlockLogs(tx, uint64(it.Next()), crit)
+			if err != nil {
+				return nil, err
+			}
+			for i := len(blockLogs) - 1; i >= 0; i-- {
+				logs = append(logs, blockLogs[i])
+				if uint64(len(logs)) == uint64(limit) {
+					return logs, nil
+				}
+			}
+		}
+		if windowBegin == begin {
+			break
+		}
+	}
+	return logs, nil
+}
+
 // GetLogsByNumber implements erigon_getLogsByHash. Returns all the logs that appear in a block given the block's hash.
 // func (api *ErigonImpl) GetLogsByNumber(ctx context.Context, number rpc.BlockNumber) ([][]*types.Log, error) {
 // 	tx, err := api.db.Begin(ctx, false)
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLatestLogs(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	ethAPI := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	ctx := context.Background()
+
+	all, err := ethAPI.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	var reversed []*types.Log
+	for i := len(all) - 1; i >= 0; i-- {
+		reversed = append(reversed, all[i])
+	}
+
+	logs, err := api.GetLatestLogs(ctx, filters.FilterCriteria{}, 1000)
+	require.NoError(t, err)
+	require.Equal(t, reversed, logs)
+	logs, err = api.GetLatestLogs(ctx, filters.FilterCriteria{}, 1)
+	require.NoError(t, err)
+	require.Equal(t, reversed[:1], logs)
+
+	last := all[len(all)-1]
+	logs, err = api.GetLatestLogs(ctx, filters.FilterCriteria{Addresses: []common.Address{last.Address}, ToBlock: new(big.Int).SetUint64(last.BlockNumber)}, 1)
+	require.NoError(t, err)
+	require.Equal(t, []*types.Log{last}, logs)
+	logs, err = api.GetLatestLogs(ctx, filters.FilterCriteria{FromBlock: new(big.Int).SetUint64(last.BlockNumber + 1)}, 10)
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	_, err = api.GetLatestLogs(ctx, filters.FilterCriteria{}, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/rpc"
+)
+
+// Session is the session opened by erigon_openSession
+type Session struct {
+	ID          string         `json:"id"` // to send in the X-Session-ID header
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+}
+
+// OpenSession implements erigon_openSession. Pins the latest block: calls sent with the ID of the session in
+// the X-Session-ID header see it as "latest" and "pending", until the session is closed or expires.
+func (api *ErigonImpl) OpenSession(ctx context.Context) (*Session, error) {
+	if api.sessions == nil {
+		return nil, fmt.Errorf("the method erigon_openSession is not available, please use --rpc.sessions.ttl option")
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	number, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := rawdb.ReadCanonicalHash(tx, number)
+	if err != nil {
+		return nil, err
+	}
+	id := api.sessions.Open(rpc.PinnedBlock{Number: number, Hash: hash})
+	return &Session{ID: id, BlockNumber: hexutil.Uint64(number), BlockHash: hash}, nil
+}
+
+// CloseSession implements erigon_closeSession. Returns false if the session is unknown or expired already.
+func (api *ErigonImpl) CloseSession(_ context.Context, id string) (bool, error) {
+	if api.sessions == nil {
+		return false, fmt.Errorf("the method erigon_closeSession is not available, please use --rpc.sessions.ttl option")
+	}
+	return api.sessions.Close(id), nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	disabled := NewErigonAPI(NewBaseApi(nil), db, nil)
+	_, err := disabled.OpenSession(context.Background())
+	require.Error(t, err)
+
+	sessions := rpc.NewSessions(time.Minute)
+	erigonAPI := NewErigonAPI(NewBaseApi(nil), db, nil)
+	erigonAPI.sessions = sessions
+	session, err := erigonAPI.OpenSession(context.Background())
+	require.NoError(t, err)
+	require.NotZero(t, session.BlockNumber)
+
+	server := rpc.NewServer(50)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("erigon", erigonAPI))
+	require.NoError(t, server.RegisterName("eth", NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)))
+	server.Use(sessions.Middleware())
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := rpc.DialHTTP(ts.URL)
+	require.NoError(t, err)
+	defer client.Close()
+	client.SetHeader("X-Session-ID", session.ID)
+
+	var block struct {
+		Number hexutil.Uint64 `json:"number"`
+	}
+	require.NoError(t, client.Call(&block, "eth_getBlockByNumber", "latest", false))
+	require.Equal(t, session.BlockNumber, block.Number)
+	var balance hexutil.Big
+	require.NoError(t, client.Call(&balance, "eth_getBalance", "0x71562b71999873db5b286df957af199ec94617f7", "latest"))
+
+	var closed bool
+	require.NoError(t, client.Call(&closed, "erigon_closeSession", session.ID))
+	require.True(t, closed)
+	require.Error(t, client.Call(&block, "eth_getBlockByNumber", "latest", false))
+}
@@ -3,8 +3,12 @@ package commands
 import (
 	"context"
 
+	"fmt"
+
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/core/rawdb"
 )
 
 // Forks is a data type to record a list of forks passed by this node
@@ -29,3 +33,36 @@ func (api *ErigonImpl) Forks(ctx context.Context) (Forks, error) {
 
 	return Forks{genesis.Hash(), forksBlocks}, nil
 }
+
+// SyncStages implements erigon_syncStages. Returns the stable identifiers and labels of stages of the sync in the order
+// of running, as reported by eth_syncing
+func (api *ErigonImpl) SyncStages(_ context.Context) ([]SyncStageInfo, error) {
+	return SyncStagesSchema(), nil
+}
+
+// ForkID is the EIP-2124 fork identifier this node advertises to its peers
+type ForkID struct {
+	ForkHash hexutil.Bytes  `json:"forkHash"`
+	Next     hexutil.Uint64 `json:"next"`
+}
+
+// ForkId implements erigon_forkId. Returns the EIP-2124 fork identifier at the current head, peers advertising
+// an incompatible one are dropped during handshake
+func (api *ErigonImpl) ForkId(ctx context.Context) (ForkID, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return ForkID{}, err
+	}
+	defer tx.Rollback()
+
+	chainConfig, genesis, err := api.chainConfigWithGenesis(tx)
+	if err != nil {
+		return ForkID{}, err
+	}
+	head := rawdb.ReadCurrentHeader(tx)
+	if head == nil {
+		return ForkID{}, fmt.Errorf("current header not found")
+	}
+	id := forkid.NewIDFromForks(forkid.GatherForks(chainConfig), genesis.Hash(), head.Number.Uint64())
+	return ForkID{ForkHash: id.Hash[:], Next: hexutil.Uint64(id.Next)}, nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkId(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	_, _, err := core.CommitGenesisBlock(db, core.DefaultGenesisBlock())
+	require.NoError(t, err)
+
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	id, err := api.ForkId(context.Background())
+	require.NoError(t, err)
+	// Mainnet genesis, see core/forkid tests
+	require.Equal(t, hexutil.Bytes{0xfc, 0x64, 0xec, 0x04}, id.ForkHash)
+	require.Equal(t, hexutil.Uint64(1150000), id.Next)
+}
+
+func TestNodeInfo(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	_, genesis, err := core.CommitGenesisBlock(db, core.DefaultGenesisBlock())
+	require.NoError(t, err)
+
+	api := NewErigonAPI(NewBaseApi(nil), db, nil)
+	api.nodeConfig = NodeConfig{API: []string{"eth", "erigon"}, TLS: true}
+	api.datadir = t.TempDir()
+	info, err := api.NodeInfo(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, genesis.Hash(), info.Config.Genesis)
+	require.Equal(t, uint64(1), info.Config.ChainID.ToInt().Uint64())
+	require.Equal(t, []string{"eth", "erigon"}, info.Config.API)
+	require.True(t, info.Config.TLS)
+	require.NotZero(t, info.Hardware.CPUs)
+	require.NotEmpty(t, info.Hardware.DiskType)
+}
+
+func TestUnderMountpoint(t *testing.T) {
+	require.True(t, underMountpoint("/data", "/data"))
+	require.True(t, underMountpoint("/data/erigon", "/data"))
+	require.True(t, underMountpoint("/data/erigon", "/data/"))
+	require.True(t, underMountpoint("/data/erigon", "/"))
+	require.False(t, underMountpoint("/data2/erigon", "/data"))
+	require.False(t, underMountpoint("/data", "/data/erigon"))
+	require.False(t, underMountpoint("/data", ""))
+}
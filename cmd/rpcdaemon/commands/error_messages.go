@@ -8,3 +8,6 @@ const NotAvailableChainData = "the function %s is not available, please use --pr
 
 // NotAvailableDeprecated x
 const NotAvailableDeprecated = "the method has been deprecated: %s"
+
+// NotAvailableWallet x
+const NotAvailableWallet = "the method %s is not available, please use --wallet.keystore or --wallet.signer option"
@@ -5,6 +5,7 @@ import (
 	"context"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -16,12 +17,19 @@ import (
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/common/math"
+	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	ethFilters "github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/eth/gasprice"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
 	"github.com/ledgerwatch/erigon/internal/ethapi"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
+	"github.com/ledgerwatch/erigon/turbo/transactions"
 )
 
 // EthAPI is a collection of functions that are exposed in the
@@ -76,10 +84,11 @@ type EthAPI interface {
 	Call(ctx context.Context, args ethapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]ethapi.Account) (hexutil.Bytes, error)
 	EstimateGas(ctx context.Context, args ethapi.CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error)
 	SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error)
-	SendTransaction(_ context.Context, txObject interface{}) (common.Hash, error)
-	Sign(ctx context.Context, _ common.Address, _ hexutil.Bytes) (hexutil.Bytes, error)
-	SignTransaction(_ context.Context, txObject interface{}) (common.Hash, error)
-	GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*interface{}, error)
+	ValidateRawTransaction(ctx context.Context, encodedTx hexutil.Bytes, simulate *bool) (*TxValidation, error)
+	SendTransaction(ctx context.Context, args ethapi.SendTxArgs) (common.Hash, error)
+	Sign(ctx context.Context, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error)
+	SignTransaction(ctx context.Context, args ethapi.SendTxArgs) (*ethapi.SignTransactionResult, error)
+	GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*ethapi.AccountResult, error)
 
 	// Mining related (see ./eth_mining.go)
 	Coinbase(ctx context.Context) (common.Address, error)
@@ -98,13 +107,54 @@ type EthAPI interface {
 
 type BaseAPI struct {
 	filters         *filters.Filters
+	headsID         filters.HeadsSubID // subscription, which keeps canonical in sync with reorgs
+	quit            chan struct{}      // closed by Close
+	closeOnce       sync.Once
+	canonical       *rpchelper.CanonicalCache
+	callState       *rpchelper.CallStateCache
+	stateProfiler   *state.AccessProfiler // nil - disabled
+	evmLimits       transactions.EVMLimits
+	poolStats       *poolStatsCache // shared by eth_gasPrice and erigon_gasStats
 	_chainConfig    *params.ChainConfig
 	_genesis        *types.Block
 	_genesisSetOnce sync.Once
 }
 
 func NewBaseApi(f *filters.Filters) *BaseAPI {
-	return &BaseAPI{filters: f}
+	canonical := rpchelper.NewCanonicalCache(rpchelper.DefaultCanonicalCacheSize)
+	callState := rpchelper.NewCallStateCache(rpchelper.DefaultCallStateCacheBlocks, rpchelper.DefaultCallStateCacheItems)
+	poolStats := newPoolStatsCache(core.DefaultTxPoolConfig.GlobalSlots)
+	api := &BaseAPI{filters: f, canonical: canonical, callState: callState, poolStats: poolStats, quit: make(chan struct{})}
+	if f != nil {
+		heads := make(chan *types.Header, 8)
+		api.headsID = f.SubscribeNewHeads(heads)
+		go func() {
+			for {
+				select {
+				case h := <-heads:
+					canonical.OnNewHeader(h)
+				case <-api.quit:
+					return
+				}
+			}
+		}()
+	}
+	return api
+}
+
+// Close stops following new heads, started by NewBaseApi
+func (api *BaseAPI) Close() {
+	api.closeOnce.Do(func() {
+		if api.filters != nil {
+			api.filters.UnsubscribeHeads(api.headsID)
+		}
+		close(api.quit)
+	})
+}
+
+// profiledReader samples reads of r by the state access profiler, if it's enabled
+func (api *BaseAPI) profiledReader(r state.StateReader) state.StateReader {
+	return api.stateProfiler.Reader(state.AccessSourceRPC, r)
 }
 
 func (api *BaseAPI) chainConfig(tx kv.Tx) (*params.ChainConfig, error) {
@@ -154,7 +204,14 @@ func (api *BaseAPI) getBlockByNumber(number rpc.BlockNumber, tx kv.Tx) (*types.B
 		return nil, err
 	}
 
-	block, _, err := rawdb.ReadBlockByNumberWithSenders(tx, n)
+	hash, err := api.canonical.CanonicalHash(tx, n)
+	if err != nil {
+		return nil, err
+	}
+	if hash == (common.Hash{}) {
+		return nil, nil
+	}
+	block, _, err := rawdb.ReadBlockWithSenders(tx, hash, n)
 	return block, err
 }
 
@@ -166,6 +223,17 @@ type APIImpl struct {
 	mining     txpool.MiningClient
 	db         kv.RoDB
 	GasCap     uint64
+	wallet     *WalletAPIImpl      // nil if account management is disabled
+	txMonitor  *TxMonitor          // nil if submitted transactions are not monitored
+	logArchive *logarchive.Archive // nil if the log archive of Erigon is not available
+
+	logsMaxResults int           // eth_getLogs returns pages of so many logs, 0 - no limit
+	logsMaxRange   uint64        // eth_getLogs reads so many blocks of the range by one call, 0 - no limit
+	logsMaxTime    time.Duration // eth_getLogs stops reading of blocks after it, 0 - no limit
+
+	txBroadcaster *TxBroadcaster // nil if submitted transactions are not forwarded to other endpoints
+
+	gasPriceOracle *gasprice.Oracle // shared by calls, keeps the last suggestion and samples of recent blocks
 }
 
 // NewEthAPI returns APIImpl instance
@@ -174,7 +242,7 @@ func NewEthAPI(base *BaseAPI, db kv.RoDB, eth services.ApiBackend, txPool txpool
 		gascap = uint64(math.MaxUint64 / 2)
 	}
 
-	return &APIImpl{
+	api := &APIImpl{
 		BaseAPI:    base,
 		db:         db,
 		ethBackend: eth,
@@ -182,6 +250,8 @@ func NewEthAPI(base *BaseAPI, db kv.RoDB, eth services.ApiBackend, txPool txpool
 		mining:     mining,
 		GasCap:     gascap,
 	}
+	api.gasPriceOracle = gasprice.NewOracle(api, ethconfig.Defaults.GPO).WithSampleCache(gasPriceSampleBlocks)
+	return api
 }
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
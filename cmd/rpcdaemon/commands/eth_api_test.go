@@ -3,9 +3,12 @@ package commands
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetTransactionReceipt(t *testing.T) {
@@ -25,3 +28,27 @@ func TestGetTransactionReceiptUnprotected(t *testing.T) {
 		t.Errorf("calling GetTransactionReceipt for unprotected tx: %v", err)
 	}
 }
+
+func TestGetLogsParallel(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	sequential, err := api.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.NotEmpty(t, sequential)
+
+	blocks := make([]uint32, 0, 11)
+	for i := uint32(0); i <= 10; i++ {
+		blocks = append(blocks, i)
+	}
+	parallel, read, err := api.getLogsParallel(ctx, blocks, filters.FilterCriteria{}, time.Time{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, sequential, parallel)
+	require.Equal(t, len(blocks), read)
+
+	// only the first block is read after the deadline
+	_, read, err = api.getLogsParallel(ctx, blocks, filters.FilterCriteria{}, time.Now().Add(-time.Second), nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, read)
+}
@@ -58,11 +58,12 @@ func (api *APIImpl) CallBundle(ctx context.Context, txHashes []common.Hash, stat
 	}
 
 	var stateReader state.StateReader
-	if num, ok := stateBlockNumberOrHash.Number(); ok && num == rpc.LatestBlockNumber {
+	if rpchelper.PlainStateAt(stateBlockNumberOrHash, tx) {
 		stateReader = state.NewPlainStateReader(tx)
 	} else {
 		stateReader = state.NewPlainState(tx, stateBlockNumber)
 	}
+	stateReader = api.profiledReader(stateReader)
 	st := state.New(stateReader)
 
 	parent := rawdb.ReadHeader(tx, hash, stateBlockNumber)
@@ -92,7 +93,7 @@ func (api *APIImpl) CallBundle(ctx context.Context, txHashes []common.Hash, stat
 	}
 
 	blockCtx, txCtx := transactions.GetEvmContext(firstMsg, header, stateBlockNumberOrHash.RequireCanonical, tx)
-	evm := vm.NewEVM(blockCtx, txCtx, st, chainConfig, vm.Config{Debug: false})
+	evm := vm.NewEVM(blockCtx, txCtx, st, chainConfig, api.evmLimits.Apply(vm.Config{Debug: false}))
 
 	timeoutMilliSeconds := int64(5000)
 	if timeoutMilliSecondsPtr != nil {
@@ -8,16 +8,23 @@ import (
 
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
 	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/common/keccakcache"
 	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
 	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/internal/ethapi"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
 	"github.com/ledgerwatch/erigon/turbo/transactions"
+	"github.com/ledgerwatch/erigon/turbo/trie"
 	"github.com/ledgerwatch/log/v3"
 )
 
@@ -37,8 +44,33 @@ func (api *APIImpl) Call(ctx context.Context, args ethapi.CallArgs, blockNrOrHas
 	if args.Gas == nil || uint64(*args.Gas) == 0 {
 		args.Gas = (*hexutil.Uint64)(&api.GasCap)
 	}
+	gasCap := api.GasCap
+	// calls of a batch share the gas of the batch
+	if batchGas := rpc.BatchGasFromContext(ctx); batchGas != nil {
+		gas := uint64(*args.Gas)
+		if gas > gasCap {
+			gas = gasCap
+		}
+		reserved, err := batchGas.Reserve(gas)
+		if err != nil {
+			return nil, err
+		}
+		args.Gas, gasCap = (*hexutil.Uint64)(&reserved), reserved
+		var used uint64
+		defer func() { batchGas.Refund(reserved - used) }()
+		result, err := transactions.DoCall(ctx, args, tx, blockNrOrHash, overrides, gasCap, chainConfig, api.filters, api.callState, api.evmLimits)
+		if result != nil {
+			used = result.UsedGas
+		}
+		return callResult(result, err)
+	}
+
+	result, err := transactions.DoCall(ctx, args, tx, blockNrOrHash, overrides, gasCap, chainConfig, api.filters, api.callState, api.evmLimits)
+	return callResult(result, err)
+}
 
-	result, err := transactions.DoCall(ctx, args, tx, blockNrOrHash, overrides, api.GasCap, chainConfig, api.filters)
+// callResult returns the return data of the call, or the error of its execution with the revert reason
+func callResult(result *core.ExecutionResult, err error) (hexutil.Bytes, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +199,7 @@ func (api *APIImpl) EstimateGas(ctx context.Context, args ethapi.CallArgs, block
 	executable := func(gas uint64) (bool, *core.ExecutionResult, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
 
-		result, err := transactions.DoCall(ctx, args, dbtx, rpc.BlockNumberOrHash{BlockNumber: &lastBlockNum}, nil, api.GasCap, chainConfig, api.filters)
+		result, err := transactions.DoCall(ctx, args, dbtx, rpc.BlockNumberOrHash{BlockNumber: &lastBlockNum}, nil, api.GasCap, chainConfig, api.filters, api.callState, api.evmLimits)
 		if err != nil {
 			if errors.Is(err, core.ErrIntrinsicGas) {
 				// Special case, raise gas limit
@@ -216,8 +248,125 @@ func (api *APIImpl) EstimateGas(ctx context.Context, args ethapi.CallArgs, block
 	return hexutil.Uint64(hi), nil
 }
 
-// GetProof not implemented
-func (api *APIImpl) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*interface{}, error) {
-	var stub interface{}
-	return &stub, fmt.Errorf(NotImplemented, "eth_getProof")
+// GetProof implements eth_getProof (EIP-1186). Returns the account and storage values of the specified account
+// including the Merkle proofs. Proofs are built from the hashed state and the intermediate trie hashes,
+// so they are only available for the block the hashed state is at. "latest" and "pending" resolve to that block,
+// also when --rpc.headlag holds the head of the other methods back
+func (api *APIImpl) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*ethapi.AccountResult, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the lag wrapper clamps the stage progress, while the hashed state is always at the real one
+	raw := ethdb.UnwrapTx(tx)
+	hashedBlock, err := stages.GetStageProgress(raw, stages.IntermediateHashes)
+	if err != nil {
+		return nil, err
+	}
+	var blockNumber uint64
+	var hash common.Hash
+	if number, ok := blockNrOrHash.Number(); ok && (number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber) {
+		blockNumber = hashedBlock
+		if hash, err = rawdb.ReadCanonicalHash(raw, blockNumber); err != nil {
+			return nil, err
+		}
+	} else {
+		if blockNumber, hash, err = rpchelper.GetBlockNumber(blockNrOrHash, tx, api.filters); err != nil {
+			return nil, err
+		}
+		if blockNumber != hashedBlock {
+			return nil, fmt.Errorf("proofs are available only for the block of the hashed state %d, requested block %d", hashedBlock, blockNumber)
+		}
+	}
+	header := rawdb.ReadHeader(raw, hash, blockNumber)
+	if header == nil {
+		return nil, fmt.Errorf("block %d(%x) not found", blockNumber, hash)
+	}
+
+	addrHash, err := keccakcache.Shared().HashAddress(address[:])
+	if err != nil {
+		return nil, err
+	}
+	var incarnation uint64
+	enc, err := tx.GetOne(kv.HashedAccounts, addrHash[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) > 0 {
+		var acc accounts.Account
+		if err = acc.DecodeForStorage(enc); err != nil {
+			return nil, err
+		}
+		incarnation = acc.Incarnation
+	}
+	keyHashes := make([]common.Hash, len(storageKeys))
+	rl := trie.NewRetainList(0)
+	rl.AddKey(addrHash[:])
+	for i, key := range storageKeys {
+		keyAsHash := common.HexToHash(key)
+		if keyHashes[i], err = keccakcache.Shared().HashStorageKey(keyAsHash[:]); err != nil {
+			return nil, err
+		}
+		if incarnation > 0 {
+			rl.AddKey(dbutils.GenerateCompositeStorageKey(addrHash, incarnation, keyHashes[i]))
+		}
+	}
+
+	loader := trie.NewFlatDBTrieLoader("getProof")
+	if err = loader.Reset(rl, nil, nil, false); err != nil {
+		return nil, err
+	}
+	tr, err := loader.CalcProofTrie(tx, ctx.Done())
+	if err != nil {
+		return nil, err
+	}
+	if tr.Hash() != header.Root {
+		return nil, fmt.Errorf("state root mismatch for block %d: expected %x, got %x", blockNumber, header.Root, tr.Hash())
+	}
+
+	accountProof, err := tr.Prove(addrHash[:], 0, false)
+	if err != nil {
+		return nil, err
+	}
+	result := &ethapi.AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(new(big.Int)),
+		CodeHash:     trie.EmptyCodeHash,
+		StorageHash:  trie.EmptyRoot,
+		StorageProof: make([]ethapi.StorageResult, len(storageKeys)),
+	}
+	acc, _ := tr.GetAccount(addrHash[:])
+	if acc != nil {
+		result.Balance = (*hexutil.Big)(acc.Balance.ToBig())
+		result.CodeHash = acc.CodeHash
+		result.Nonce = hexutil.Uint64(acc.Nonce)
+		result.StorageHash = acc.Root
+	}
+	for i, key := range storageKeys {
+		result.StorageProof[i] = ethapi.StorageResult{Key: key, Value: (*hexutil.Big)(new(big.Int)), Proof: []string{}}
+		if acc == nil || acc.Root == trie.EmptyRoot {
+			continue
+		}
+		trieKey := append(addrHash[:], keyHashes[i][:]...)
+		proof, err := tr.Prove(trieKey, 2*common.HashLength, true)
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof[i].Proof = toHexSlice(proof)
+		if v, ok := tr.Get(trieKey); ok && len(v) > 0 {
+			result.StorageProof[i].Value = (*hexutil.Big)(new(big.Int).SetBytes(v))
+		}
+	}
+	return result, nil
+}
+
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
 }
@@ -1,14 +1,22 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"testing"
 
+	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/ethdb/headlag"
 	"github.com/ledgerwatch/erigon/internal/ethapi"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEstimateGas(t *testing.T) {
@@ -38,3 +46,123 @@ func TestEthCallNonCanonical(t *testing.T) {
 		}
 	}
 }
+
+func TestGetProof(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	tx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	head := rawdb.ReadCurrentHeader(tx)
+	require.NotNil(t, head)
+
+	// Pick a contract with storage
+	var contract common.Address
+	var location common.Hash
+	require.NoError(t, tx.ForEach(kv.PlainState, nil, func(k, v []byte) error {
+		if len(k) == common.AddressLength+common.IncarnationLength+common.HashLength && len(v) > 0 && contract == (common.Address{}) {
+			copy(contract[:], k)
+			copy(location[:], k[common.AddressLength+common.IncarnationLength:])
+		}
+		return nil
+	}))
+	require.NotEqual(t, common.Address{}, contract)
+	tx.Rollback()
+
+	missing := common.HexToHash("0xdeadbeef")
+	res, err := api.GetProof(context.Background(), contract, []string{location.Hex(), missing.Hex()}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	verifyProof(t, head.Root, res.AccountProof)
+	require.NotEqual(t, trie.EmptyRoot, res.StorageHash)
+	require.NotEqual(t, trie.EmptyCodeHash, res.CodeHash)
+	require.Len(t, res.StorageProof, 2)
+	verifyProof(t, res.StorageHash, res.StorageProof[0].Proof)
+	require.NotZero(t, res.StorageProof[0].Value.ToInt().Sign())
+	verifyProof(t, res.StorageHash, res.StorageProof[1].Proof)
+	require.Zero(t, res.StorageProof[1].Value.ToInt().Sign())
+
+	// "latest" is the block of the hashed state, also when the head is held back
+	lagged := NewEthAPI(NewBaseApi(nil), headlag.WrapDB(db, 2), nil, nil, nil, 5000000)
+	res, err = lagged.GetProof(context.Background(), contract, []string{location.Hex()}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	verifyProof(t, head.Root, res.AccountProof)
+	_, err = lagged.GetProof(context.Background(), contract, nil, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head.Number.Uint64()-2)))
+	require.Error(t, err)
+
+	// Absence of an account is proven too
+	res, err = api.GetProof(context.Background(), common.HexToAddress("0xdeadbeef"), []string{location.Hex()}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	verifyProof(t, head.Root, res.AccountProof)
+	require.Equal(t, trie.EmptyRoot, res.StorageHash)
+	require.Empty(t, res.StorageProof[0].Proof)
+
+	_, err = api.GetProof(context.Background(), contract, nil, rpc.BlockNumberOrHashWithNumber(rpc.EarliestBlockNumber))
+	require.Error(t, err)
+}
+
+// verifyProof checks that every node of the proof is referenced by the previous one, starting from the root
+func verifyProof(t *testing.T, root common.Hash, proof []string) {
+	require.NotEmpty(t, proof)
+	var parent []byte
+	for i, enc := range proof {
+		node := hexutil.MustDecode(enc)
+		if i == 0 {
+			require.Equal(t, root, crypto.Keccak256Hash(node))
+		} else if len(node) >= common.HashLength {
+			require.True(t, bytes.Contains(parent, crypto.Keccak256(node)), "node %d is not referenced by its parent", i)
+		} else {
+			require.True(t, bytes.Contains(parent, node), "node %d is not embedded into its parent", i)
+		}
+		parent = node
+	}
+}
+
+func TestEthCallBatchGas(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	server := rpc.NewServer(1)
+	defer server.Stop()
+	server.SetBatchLimits(rpc.BatchLimits{MaxGas: 42000})
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	from := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+	to := common.Address{1}
+	gas := hexutil.Uint64(30000)
+	args := ethapi.CallArgs{From: &from, To: &to, Gas: &gas}
+	batch := make([]rpc.BatchElem, 3)
+	for i := range batch {
+		batch[i] = rpc.BatchElem{Method: "eth_call", Args: []interface{}{args, "latest"}, Result: new(hexutil.Bytes)}
+	}
+	require.NoError(t, client.BatchCall(batch))
+	// the first call returns 9000 unused gas of 30000, the second one takes the remaining 21000
+	require.NoError(t, batch[0].Error)
+	require.NoError(t, batch[1].Error)
+	require.Error(t, batch[2].Error)
+	require.Equal(t, -32005, batch[2].Error.(rpc.Error).ErrorCode())
+}
+
+func TestEthCallLatestHeadLag(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	head := rawdb.ReadCurrentHeader(tx).Number.Uint64()
+	tx.Rollback()
+
+	// returns the balance of the account, which sends transactions in every block
+	code := append(append([]byte{0x73}, common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7").Bytes()...), 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	call := func(api *APIImpl, blockNrOrHash rpc.BlockNumberOrHash) hexutil.Bytes {
+		data := hexutil.Bytes(code)
+		res, err := api.Call(ctx, ethapi.CallArgs{Data: &data}, blockNrOrHash, nil)
+		require.NoError(t, err)
+		return res
+	}
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	lagged := NewEthAPI(NewBaseApi(nil), headlag.WrapDB(db, 2), nil, nil, nil, 5000000)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	require.NotEqual(t, call(api, latest), call(api, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head-2))))
+	// state of the lagged "latest" is of the lagged head, not of the plain state
+	require.Equal(t, call(api, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head-2))), call(lagged, latest))
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/ledgerwatch/erigon/common/debug"
 	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
 	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/log/v3"
 )
@@ -37,44 +39,58 @@ func (api *APIImpl) GetFilterChanges(_ context.Context, index hexutil.Uint64) ([
 	return stub, fmt.Errorf(NotImplemented, "eth_getFilterChanges")
 }
 
-// NewHeads send a notification each time a new (header) block is appended to the chain.
-func (api *APIImpl) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+// NewHeads send a notification each time a new (header) block is appended to the chain. Headers since
+// args.FromBlock are replayed first, followed by SubscriptionCaughtUp.
+func (api *APIImpl) NewHeads(ctx context.Context, args *NewHeadsArgs) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	var from *uint64
+	if args != nil && args.FromBlock != nil && *args.FromBlock >= 0 {
+		n := uint64(*args.FromBlock)
+		from = &n
+	}
 
 	rpcSub := notifier.CreateSubscription()
+	go api.followHeads(notifier, rpcSub, from, api.replayHeads(notifier, rpcSub))
+	return rpcSub, nil
+}
 
-	go func() {
-		defer debug.LogPanic()
-		headers := make(chan *types.Header, 1)
-		defer close(headers)
-		id := api.filters.SubscribeNewHeads(headers)
-		defer api.filters.UnsubscribeHeads(id)
-
-		for {
-			select {
-			case h := <-headers:
-				err := notifier.Notify(rpcSub.ID, h)
-				if err != nil {
-					log.Warn("error while notifying subscription", "err", err)
-				}
-			case <-rpcSub.Err():
-				return
-			}
-		}
-	}()
+// Logs send a notification for each log matching the criteria in new blocks. Logs since crit.FromBlock are
+// replayed first, followed by SubscriptionCaughtUp.
+func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if crit.BlockHash != nil || crit.ToBlock != nil {
+		return &rpc.Subscription{}, fmt.Errorf("logs subscription supports only fromBlock of the range")
+	}
+	var from *uint64
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		n := crit.FromBlock.Uint64()
+		from = &n
+	}
 
+	rpcSub := notifier.CreateSubscription()
+	go api.followHeads(notifier, rpcSub, from, api.replayLogs(notifier, rpcSub, crit))
 	return rpcSub, nil
 }
 
-// NewPendingTransactions send a notification each time a new (header) block is appended to the chain.
-func (api *APIImpl) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// NewPendingTransactionsArgs are options of the newPendingTransactions subscription
+type NewPendingTransactionsArgs struct {
+	IncludeTransactions bool `json:"includeTransactions"` // notify transaction objects, as by eth_getTransactionByHash, instead of hashes
+}
+
+// NewPendingTransactions send a notification each time a new transaction is added to the transaction pool: its hash
+// or, with args.IncludeTransactions, the whole transaction.
+func (api *APIImpl) NewPendingTransactions(ctx context.Context, args *NewPendingTransactionsArgs) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	fullTx := args != nil && args.IncludeTransactions
 
 	rpcSub := notifier.CreateSubscription()
 
@@ -88,6 +104,19 @@ func (api *APIImpl) NewPendingTransactions(ctx context.Context) (*rpc.Subscripti
 		for {
 			select {
 			case txs := <-txsCh:
+				if fullTx {
+					rpcTxs, err := api.pendingTransactions(context.Background(), txs)
+					if err != nil {
+						log.Warn("error while reading pending transactions", "err", err)
+						continue
+					}
+					for _, t := range rpcTxs {
+						if err = notifier.Notify(rpcSub.ID, t); err != nil {
+							log.Warn("error while notifying subscription", "err", err)
+						}
+					}
+					continue
+				}
 				for _, t := range txs {
 					err := notifier.Notify(rpcSub.ID, t.Hash())
 					if err != nil {
@@ -102,3 +131,23 @@ func (api *APIImpl) NewPendingTransactions(ctx context.Context) (*rpc.Subscripti
 
 	return rpcSub, nil
 }
+
+// pendingTransactions returns RPC representations of transactions of the pool, their gas prices are by the base fee
+// of the block after the current one
+func (api *APIImpl) pendingTransactions(ctx context.Context, txs []types.Transaction) ([]*RPCTransaction, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	curHeader := rawdb.ReadCurrentHeader(tx)
+	rpcTxs := make([]*RPCTransaction, len(txs))
+	for i, txn := range txs {
+		rpcTxs[i] = newRPCPendingTransaction(txn, curHeader, chainConfig)
+	}
+	return rpcTxs, nil
+}
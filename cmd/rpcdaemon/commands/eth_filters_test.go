@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/filters"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPendingTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := rpcdaemontest.CreateTestKV(t)
+	ff := filters.New(ctx, nil, nil, nil)
+	api := NewEthAPI(NewBaseApi(ff), db, nil, nil, nil, 5000000)
+	server := rpc.NewServer(50)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	txn, err := types.SignTx(types.NewTransaction(7, common.Address{1}, uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei), nil), *signer, key)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, txn.MarshalBinary(&buf))
+
+	hashes := make(chan json.RawMessage, 100)
+	hashesSub, err := client.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	require.NoError(t, err)
+	defer hashesSub.Unsubscribe()
+	full := make(chan json.RawMessage, 100)
+	fullSub, err := client.EthSubscribe(ctx, full, "newPendingTransactions", map[string]interface{}{"includeTransactions": true})
+	require.NoError(t, err)
+	defer fullSub.Unsubscribe()
+
+	// subscriptions are registered in the background, the transaction is added until both are notified
+	var hash common.Hash
+	var rpcTx RPCTransaction
+	for hash == (common.Hash{}) || rpcTx.Hash == (common.Hash{}) {
+		ff.OnNewTx(&txpool.OnAddReply{RplTxs: [][]byte{buf.Bytes()}})
+		select {
+		case msg := <-hashes:
+			require.NoError(t, json.Unmarshal(msg, &hash))
+		case msg := <-full:
+			require.NoError(t, json.Unmarshal(msg, &rpcTx))
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+	}
+	require.Equal(t, txn.Hash(), hash)
+	require.Equal(t, txn.Hash(), rpcTx.Hash)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey), rpcTx.From)
+	require.Equal(t, uint64(7), uint64(rpcTx.Nonce))
+	require.Nil(t, rpcTx.BlockHash)
+}
+
+func TestNewHeadsHeadLag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := filters.New(ctx, nil, nil, nil)
+	ff.SetHeadLag(2)
+	headers := make(chan *types.Header, 10)
+	id := ff.SubscribeNewHeads(headers)
+	defer ff.UnsubscribeHeads(id)
+	notify := func(n uint64, extra byte) {
+		var buf bytes.Buffer
+		require.NoError(t, rlp.Encode(&buf, &types.Header{Number: new(big.Int).SetUint64(n), Extra: []byte{extra}}))
+		ff.OnNewEvent(&remote.SubscribeReply{Type: remote.Event_HEADER, Data: buf.Bytes()})
+	}
+	received := func() (numbers []uint64, extras []byte) {
+		for len(headers) > 0 {
+			h := <-headers
+			numbers = append(numbers, h.Number.Uint64())
+			extras = append(extras, h.Extra[0])
+		}
+		return numbers, extras
+	}
+
+	notify(1, 0)
+	notify(2, 0)
+	numbers, _ := received()
+	require.Empty(t, numbers)
+	notify(3, 0)
+	notify(4, 0)
+	numbers, _ = received()
+	require.Equal(t, []uint64{1, 2}, numbers)
+	// reorg of the held headers is not visible
+	notify(4, 1)
+	notify(5, 1)
+	notify(6, 1)
+	numbers, extras := received()
+	require.Equal(t, []uint64{3, 4}, numbers)
+	require.Equal(t, []byte{0, 1}, extras)
+}
+
+func TestBaseApiClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := filters.New(ctx, nil, nil, nil)
+	base := NewBaseApi(ff)
+	base.Close()
+	base.Close()
+
+	// nobody reads the heads after Close, they would block the filters if it was still subscribed
+	var buf bytes.Buffer
+	require.NoError(t, rlp.Encode(&buf, &types.Header{Number: big.NewInt(1)}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ff.OnNewEvent(&remote.SubscribeReply{Type: remote.Event_HEADER, Data: buf.Bytes()})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heads are still sent to the closed api")
+	}
+}
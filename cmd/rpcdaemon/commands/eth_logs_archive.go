@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
+)
+
+// getArchivedLogs returns logs of blocks [begin, end) matching the filter criteria from the log archive, and the end
+// of the archive: logs of blocks after it are to be read from the DB. Reading stops when the logs are full (nil - never).
+func (api *APIImpl) getArchivedLogs(ctx context.Context, begin, end uint64, crit filters.FilterCriteria, full func(logs []*types.Log) bool) ([]*types.Log, uint64, error) {
+	archiveEnd, err := api.logArchive.End()
+	if err != nil {
+		return nil, 0, err
+	}
+	if end > archiveEnd {
+		end = archiveEnd
+	}
+	var logs []*types.Log
+	var logIndex uint
+	lastBlock := end
+	if err = api.logArchive.Walk(begin, end, crit.Addresses, crit.Topics, func(blockNum uint64, blockHash common.Hash, tx *logarchive.Tx) (bool, error) {
+		if err := common.Stopped(ctx.Done()); err != nil {
+			return false, err
+		}
+		// the archive passes all transactions with logs of the block, so indices of logs in the block are known
+		if blockNum != lastBlock {
+			lastBlock, logIndex = blockNum, 0
+		}
+		for _, log := range tx.Logs {
+			log.Index = logIndex
+			logIndex++
+		}
+		for _, log := range filterLogs(tx.Logs, crit.Addresses, crit.Topics) {
+			log.BlockNumber = blockNum
+			log.BlockHash = blockHash
+			log.TxIndex = uint(tx.Index)
+			log.TxHash = tx.Hash
+			logs = append(logs, log)
+		}
+		return full == nil || !full(logs), nil
+	}); err != nil {
+		return nil, 0, err
+	}
+	return logs, archiveEnd, nil
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/ethdb/cbor"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveLogs appends logs of blocks [0, to) to the archive, as the LogArchive stage of Erigon does
+func archiveLogs(t *testing.T, db kv.RoDB, archive *logarchive.Archive, to uint64) {
+	require.NoError(t, db.View(context.Background(), func(tx kv.Tx) error {
+		for blockNum := uint64(0); blockNum < to; blockNum++ {
+			block, err := rawdb.ReadBlockByNumber(tx, blockNum)
+			require.NoError(t, err)
+			var txs []logarchive.Tx
+			var prefix [8]byte
+			binary.BigEndian.PutUint64(prefix[:], blockNum)
+			require.NoError(t, tx.ForPrefix(kv.Log, prefix[:], func(k, v []byte) error {
+				var logs types.Logs
+				require.NoError(t, cbor.Unmarshal(&logs, bytes.NewReader(v)))
+				index := binary.BigEndian.Uint32(k[8:])
+				txs = append(txs, logarchive.Tx{Index: index, Hash: block.Transactions()[index].Hash(), Logs: logs})
+				return nil
+			}))
+			require.NoError(t, archive.AppendBlock(blockNum, block.Hash(), txs))
+		}
+		return archive.Flush()
+	}))
+}
+
+func TestGetLogsFromArchive(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writer, err := logarchive.Open(dir)
+	require.NoError(t, err)
+	defer writer.Close()
+	archiveLogs(t, db, writer, 6)
+	archived := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	archived.logArchive, err = logarchive.OpenReadOnly(dir)
+	require.NoError(t, err)
+	defer archived.logArchive.Close()
+
+	var all []*types.Log
+	for _, crit := range []filters.FilterCriteria{
+		{},
+		{FromBlock: big.NewInt(4), ToBlock: big.NewInt(5)},
+		{FromBlock: big.NewInt(5), ToBlock: big.NewInt(8)},
+		{FromBlock: big.NewInt(7)},
+		{Addresses: []common.Address{common.HexToAddress("0x3")}},
+	} {
+		expected, err := api.GetLogs(ctx, crit)
+		require.NoError(t, err)
+		logs, err := archived.GetLogs(ctx, crit)
+		require.NoError(t, err)
+		require.Equal(t, expected, logs)
+		if len(all) == 0 {
+			all = logs
+		}
+	}
+	require.NotEmpty(t, all)
+
+	// filter by the address and the topic of one of the archived logs
+	crit := filters.FilterCriteria{Addresses: []common.Address{all[0].Address}, Topics: [][]common.Hash{{all[0].Topics[0]}}}
+	expected, err := api.GetLogs(ctx, crit)
+	require.NoError(t, err)
+	logs, err := archived.GetLogs(ctx, crit)
+	require.NoError(t, err)
+	require.Equal(t, expected, logs)
+	require.NotEmpty(t, logs)
+}
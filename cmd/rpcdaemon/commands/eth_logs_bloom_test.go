@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLogsNotIndexed(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	all, err := api.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	criteria := []filters.FilterCriteria{
+		{Addresses: []common.Address{all[0].Address}},
+		{Topics: [][]common.Hash{{all[0].Topics[0]}}},
+		{Addresses: []common.Address{all[0].Address}, Topics: [][]common.Hash{{}, {common.HexToHash("0x1")}}},
+		{Addresses: []common.Address{common.HexToAddress("0x1234")}},
+	}
+	expected := make([][]*types.Log, len(criteria))
+	for i, crit := range criteria {
+		expected[i], err = api.GetLogs(ctx, crit)
+		require.NoError(t, err)
+	}
+	require.NotEmpty(t, expected[0])
+	require.NotEmpty(t, expected[1])
+	require.Empty(t, expected[3])
+
+	// as if the LogIndex stage didn't run yet, blooms of headers are used instead
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		if err := tx.ClearBucket(kv.LogAddressIndex); err != nil {
+			return err
+		}
+		if err := tx.ClearBucket(kv.LogTopicIndex); err != nil {
+			return err
+		}
+		return stages.SaveStageProgress(tx, stages.LogIndex, 0)
+	}))
+	for i, crit := range criteria {
+		logs, err := api.GetLogs(ctx, crit)
+		require.NoError(t, err)
+		require.Equal(t, expected[i], logs)
+	}
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+// logsLimitErrorCode is the code of the error returned when eth_getLogs has more logs than the limit
+const logsLimitErrorCode = -32005
+
+// logPosition is the position of a log in the chain, eth_getLogs resumes at it
+type logPosition struct {
+	block    uint64
+	txIndex  uint32
+	logIndex uint32 // index of the log in the block
+}
+
+// before returns true if the log precedes the position
+func (p *logPosition) before(log *types.Log) bool {
+	if log.BlockNumber != p.block {
+		return log.BlockNumber < p.block
+	}
+	return uint32(log.Index) < p.logIndex
+}
+
+// encodeLogsContinuation returns the token of the position of the log
+func encodeLogsContinuation(log *types.Log) string {
+	return encodeLogPosition(&logPosition{block: log.BlockNumber, txIndex: uint32(log.TxIndex), logIndex: uint32(log.Index)})
+}
+
+// encodeLogPosition returns the token of the position: hex of block | txIndex | logIndex
+func encodeLogPosition(p *logPosition) string {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:], p.block)
+	binary.BigEndian.PutUint32(buf[8:], p.txIndex)
+	binary.BigEndian.PutUint32(buf[12:], p.logIndex)
+	return hexutil.Encode(buf[:])
+}
+
+// decodeLogsContinuation parses the token given by a client, empty token - from the beginning of the range
+func decodeLogsContinuation(token string) (*logPosition, error) {
+	if token == "" {
+		return nil, nil
+	}
+	buf, err := hexutil.Decode(token)
+	if err != nil || len(buf) != 16 {
+		return nil, fmt.Errorf("invalid continuation: %q", token)
+	}
+	return &logPosition{
+		block:    binary.BigEndian.Uint64(buf),
+		txIndex:  binary.BigEndian.Uint32(buf[8:]),
+		logIndex: binary.BigEndian.Uint32(buf[12:]),
+	}, nil
+}
+
+// logsLimits cut the work of one eth_getLogs call, the rest of the range is returned by next calls. Zero values - no
+// limits.
+type logsLimits struct {
+	maxRange   uint64        // blocks of the range read by one call, --rpc.logs.maxrange
+	maxTime    time.Duration // reading of blocks stops after it, at least one block is read, --rpc.logs.maxtime
+	maxResults int           // reading stops after one more log, the next page starts at it, --rpc.logs.maxresults
+}
+
+// full tells if the logs (except ones preceding the continuation) are more than maxResults, the rest of the range
+// isn't needed for the page then
+func (l logsLimits) full(logs []*types.Log, from *logPosition) bool {
+	return l.maxResults > 0 && len(logs) > l.maxResults && countLogsAfter(logs, from) > l.maxResults
+}
+
+// countLogsAfter returns the number of logs not preceding the position, nil - all of them
+func countLogsAfter(logs []*types.Log, from *logPosition) int {
+	n := len(logs)
+	for i := 0; i < len(logs) && from != nil && from.before(logs[i]); i++ {
+		n--
+	}
+	return n
+}
+
+// logsCut tells where and why reading of logs was cut by logsLimits
+type logsCut struct {
+	next  logPosition // the first position not read
+	cause string
+}
+
+// LogsLimitError is returned by eth_getLogs when the result is bigger than --rpc.logs.maxresults, the range of the
+// filter is longer than --rpc.logs.maxrange or reading of it takes longer than --rpc.logs.maxtime. It carries the
+// first page of logs and the continuation token, the client passes the token back in the filter to get the rest.
+type LogsLimitError struct {
+	Limit        int    // of results, 0 - the page is cut by Cause
+	Cause        string // the limit of the range or the time, which cut the page
+	Logs         []*types.Log
+	Continuation string
+}
+
+func (e *LogsLimitError) Error() string {
+	if e.Limit == 0 {
+		return fmt.Sprintf("query exceeded %s, pass \"continuation\": %q in the filter to get the next page", e.Cause, e.Continuation)
+	}
+	return fmt.Sprintf("query returned more than %d results, pass \"continuation\": %q in the filter to get the next page", e.Limit, e.Continuation)
+}
+
+func (e *LogsLimitError) ErrorCode() int { return logsLimitErrorCode }
+
+func (e *LogsLimitError) ErrorData() interface{} {
+	return map[string]interface{}{
+		"logs":         e.Logs,
+		"continuation": e.Continuation,
+	}
+}
+
+// pageLogs drops logs preceding the continuation and cuts the result by the limit of results. cut - reading of logs
+// was cut by logsLimits, nil - logs of the whole range are read (or more logs than the limit of results).
+func (api *APIImpl) pageLogs(logs []*types.Log, from *logPosition, cut *logsCut) ([]*types.Log, error) {
+	if from != nil {
+		skip := 0
+		for skip < len(logs) && from.before(logs[skip]) {
+			skip++
+		}
+		logs = logs[skip:]
+	}
+	if api.logsMaxResults <= 0 || len(logs) <= api.logsMaxResults {
+		if cut != nil {
+			return nil, &LogsLimitError{Cause: cut.cause, Logs: returnLogs(logs), Continuation: encodeLogPosition(&cut.next)}
+		}
+		return returnLogs(logs), nil
+	}
+	return nil, &LogsLimitError{
+		Limit:        api.logsMaxResults,
+		Logs:         logs[:api.logsMaxResults],
+		Continuation: encodeLogsContinuation(logs[api.logsMaxResults]),
+	}
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/ethdb/cbor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageLogs(t *testing.T) {
+	var all []*types.Log
+	for block := uint64(3); block < 6; block++ {
+		for i := uint(0); i < 3; i++ {
+			all = append(all, &types.Log{BlockNumber: block, TxIndex: i / 2, Index: i})
+		}
+	}
+	api := &APIImpl{logsMaxResults: 4}
+
+	var paged []*types.Log
+	var from *logPosition
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(all))
+		var logs []*types.Log // the page is read from the block of the continuation
+		for _, log := range all {
+			if from == nil || log.BlockNumber >= from.block {
+				logs = append(logs, log)
+			}
+		}
+		logs, err := api.pageLogs(logs, from, nil)
+		var limitErr *LogsLimitError
+		if !errors.As(err, &limitErr) {
+			require.NoError(t, err)
+			paged = append(paged, logs...)
+			break
+		}
+		require.Equal(t, logsLimitErrorCode, limitErr.ErrorCode())
+		require.Len(t, limitErr.Logs, 4)
+		paged = append(paged, limitErr.Logs...)
+		from, err = decodeLogsContinuation(limitErr.Continuation)
+		require.NoError(t, err)
+	}
+	require.Equal(t, all, paged)
+
+	_, err := decodeLogsContinuation("0x01")
+	require.Error(t, err)
+}
+
+func TestGetLogsContinuation(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	all, err := api.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	last := all[len(all)-1]
+
+	api.logsMaxResults = 1
+	logs, err := api.GetLogs(ctx, filters.FilterCriteria{Continuation: encodeLogsContinuation(last)})
+	require.NoError(t, err)
+	require.Equal(t, []*types.Log{last}, logs)
+
+	// the continuation must be in the range of the filter
+	_, err = api.GetLogs(ctx, filters.FilterCriteria{
+		FromBlock:    new(big.Int).SetUint64(last.BlockNumber + 1),
+		Continuation: encodeLogsContinuation(last),
+	})
+	require.Error(t, err)
+}
+
+func TestGetLogsLimits(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	all, err := api.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for _, limits := range []logsLimits{{maxRange: 2}, {maxTime: time.Nanosecond}, {maxRange: 3, maxTime: time.Nanosecond}} {
+		api.logsMaxRange, api.logsMaxTime = limits.maxRange, limits.maxTime
+		var paged []*types.Log
+		var continuation string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 100)
+			logs, err := api.GetLogs(ctx, filters.FilterCriteria{Continuation: continuation})
+			var limitErr *LogsLimitError
+			if !errors.As(err, &limitErr) {
+				require.NoError(t, err)
+				paged = append(paged, logs...)
+				break
+			}
+			require.Zero(t, limitErr.Limit)
+			require.NotEqual(t, continuation, limitErr.Continuation)
+			paged = append(paged, limitErr.Logs...)
+			continuation = limitErr.Continuation
+		}
+		require.Equal(t, all, paged, "limits %+v", limits)
+	}
+}
+
+func TestGetLogsMaxResults(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ctx := context.Background()
+
+	// a log for every transaction of the blocks, so pages span several blocks
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		for blockNum := uint64(1); blockNum <= 7; blockNum++ {
+			block, err := rawdb.ReadBlockByNumber(tx, blockNum)
+			if err != nil {
+				return err
+			}
+			for i := range block.Transactions() {
+				var buf bytes.Buffer
+				if err := cbor.Marshal(&buf, types.Logs{{Address: common.HexToAddress("0x1234")}}); err != nil {
+					return err
+				}
+				if err := tx.Put(kv.Log, dbutils.LogKey(blockNum, uint32(i)), buf.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	all, err := api.GetLogs(ctx, filters.FilterCriteria{})
+	require.NoError(t, err)
+	require.Greater(t, len(all), 40)
+
+	// reading stops after one log more than the limit
+	logs, cut, err := api.getLogs(ctx, filters.FilterCriteria{}, nil, logsLimits{maxResults: 2})
+	require.NoError(t, err)
+	require.Nil(t, cut)
+	require.Greater(t, len(logs), 2)
+	require.Less(t, len(logs), len(all))
+
+	for _, maxResults := range []int{1, 7, 40} {
+		api.logsMaxResults = maxResults
+		var paged []*types.Log
+		var continuation string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, len(all)+1)
+			logs, err := api.GetLogs(ctx, filters.FilterCriteria{Continuation: continuation})
+			var limitErr *LogsLimitError
+			if !errors.As(err, &limitErr) {
+				require.NoError(t, err)
+				paged = append(paged, logs...)
+				break
+			}
+			require.Len(t, limitErr.Logs, maxResults)
+			paged = append(paged, limitErr.Logs...)
+			continuation = limitErr.Continuation
+		}
+		require.Equal(t, all, paged, "max results %d", maxResults)
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -21,14 +25,32 @@ import (
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/core/vm"
 	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
 	"github.com/ledgerwatch/erigon/ethdb/cbor"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/turbo/transactions"
+	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// getLogsParallelThreshold is the amount of matching blocks after which eth_getLogs reads blocks in parallel
+	getLogsParallelThreshold = 256
+	// getLogsParallelMinDepth - blocks closer to the head are read sequentially in the transaction of the request,
+	// so they are consistent with the head even if reorg happens while logs are read
+	getLogsParallelMinDepth = 128
+)
+
+// getLogsWorkers is the amount of goroutines reading logs of a single eth_getLogs request
+var getLogsWorkers = func() int {
+	if n := runtime.NumCPU() / 2; n < 8 {
+		return n + 1
+	}
+	return 8
+}()
+
 func getReceipts(ctx context.Context, tx kv.Tx, chainConfig *params.ChainConfig, block *types.Block, senders []common.Address) (types.Receipts, error) {
 	if cached := rawdb.ReadReceipts(tx, block, senders); cached != nil {
 		return cached, nil
@@ -61,19 +83,40 @@ func getReceipts(ctx context.Context, tx kv.Tx, chainConfig *params.ChainConfig,
 
 // GetLogs implements eth_getLogs. Returns an array of logs matching a given filter object.
 func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]*types.Log, error) {
+	from, err := decodeLogsContinuation(crit.Continuation)
+	if err != nil {
+		return nil, err
+	}
+	logs, cut, err := api.getLogs(ctx, crit, from, logsLimits{maxRange: api.logsMaxRange, maxTime: api.logsMaxTime, maxResults: api.logsMaxResults})
+	if err != nil {
+		return logs, err
+	}
+	return api.pageLogs(logs, from, cut)
+}
+
+// getLogs returns logs matching the criteria, starting from the block of the continuation if it's given. If reading
+// is cut by the limits, logs up to the position of the cut are returned with it. Logs of the log archive are read
+// regardless of the time limit, it's cheap. Reading stops when there are more logs than the limit of results, the cut
+// is not returned then: the page ends before the limit anyway.
+func (api *APIImpl) getLogs(ctx context.Context, crit filters.FilterCriteria, from *logPosition, limits logsLimits) ([]*types.Log, *logsCut, error) {
 	var begin, end uint64
 	var logs []*types.Log //nolint:prealloc
+	var cut *logsCut
+	var deadline time.Time
+	if limits.maxTime > 0 {
+		deadline = time.Now().Add(limits.maxTime)
+	}
 
 	tx, beginErr := api.db.BeginRo(ctx)
 	if beginErr != nil {
-		return returnLogs(logs), beginErr
+		return returnLogs(logs), nil, beginErr
 	}
 	defer tx.Rollback()
 
 	if crit.BlockHash != nil {
 		number := rawdb.ReadHeaderNumber(tx, *crit.BlockHash)
 		if number == nil {
-			return nil, fmt.Errorf("block not found: %x", *crit.BlockHash)
+			return nil, nil, fmt.Errorf("block not found: %x", *crit.BlockHash)
 		}
 		begin = *number
 		end = *number
@@ -81,7 +124,7 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([
 		// Convert the RPC block numbers into internal representations
 		latest, err := getLatestBlockNumber(tx)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		begin = 0
@@ -93,7 +136,135 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([
 			end = crit.ToBlock.Uint64()
 		}
 	}
+	if from != nil {
+		if from.block < begin || from.block > end {
+			return nil, nil, fmt.Errorf("continuation block %d is out of the range of the filter [%d, %d]", from.block, begin, end)
+		}
+		begin = from.block
+	}
+	if limits.maxRange > 0 && end-begin >= limits.maxRange {
+		end = begin + limits.maxRange - 1
+		cut = &logsCut{next: logPosition{block: end + 1}, cause: fmt.Sprintf("the limit of the range of %d blocks", limits.maxRange)}
+	}
+
+	if api.logArchive != nil {
+		archived, archiveEnd, err := api.getArchivedLogs(ctx, begin, end+1, crit, func(archived []*types.Log) bool {
+			return limits.full(archived, from)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		logs = append(logs, archived...)
+		if limits.full(logs, from) {
+			return returnLogs(logs), nil, nil
+		}
+		if archiveEnd > end {
+			return returnLogs(logs), cut, nil
+		}
+		if archiveEnd > begin {
+			begin = archiveEnd
+		}
+	}
+
+	blockNumbers, err := getLogsBlocks(tx, crit, begin, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blockNumbers.GetCardinality() == 0 {
+		return returnLogs(logs), cut, nil
+	}
+
+	// Blocks deep enough to not be reorged are read in parallel, each worker using own transaction,
+	// the rest is read by this transaction
+	blocks := blockNumbers.ToArray()
+	read := false // at least one block is read before reading is stopped by the time limit
+	if len(blocks) >= getLogsParallelThreshold {
+		latest, err := getLatestBlockNumber(tx)
+		if err != nil {
+			return nil, nil, err
+		}
+		parallel := sort.Search(len(blocks), func(i int) bool {
+			return uint64(blocks[i])+getLogsParallelMinDepth > latest
+		})
+		before := countLogsAfter(logs, from) // the logs of every chunk follow them
+		parallelLogs, stopped, err := api.getLogsParallel(ctx, blocks[:parallel], crit, deadline, func(chunkLogs []*types.Log) bool {
+			return limits.maxResults > 0 && before+countLogsAfter(chunkLogs, from) > limits.maxResults
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		logs = append(logs, parallelLogs...)
+		if limits.full(logs, from) {
+			return returnLogs(logs), nil, nil
+		}
+		if stopped < parallel {
+			return returnLogs(logs), timeCut(blocks[stopped], limits.maxTime), nil
+		}
+		blocks = blocks[parallel:]
+		read = parallel > 0
+	}
+	for _, blockNToMatch := range blocks {
+		if err = common.Stopped(ctx.Done()); err != nil {
+			return nil, nil, err
+		}
+		if read && !deadline.IsZero() && time.Now().After(deadline) {
+			return returnLogs(logs), timeCut(blockNToMatch, limits.maxTime), nil
+		}
+		blockLogs, err := getBlockLogs(tx, uint64(blockNToMatch), crit)
+		if err != nil {
+			return returnLogs(logs), nil, err
+		}
+		logs = append(logs, blockLogs...)
+		read = true
+		if limits.full(logs, from) {
+			return returnLogs(logs), nil, nil
+		}
+	}
+	return returnLogs(logs), cut, nil
+}
+
+// timeCut is logsCut of reading of logs stopped by the time limit before the block
+func timeCut(block uint32, maxTime time.Duration) *logsCut {
+	return &logsCut{next: logPosition{block: uint64(block)}, cause: fmt.Sprintf("the limit of the time of %v", maxTime)}
+}
+
+// getLogsBlocks returns numbers of blocks in [begin, end] which may have logs matching addresses and topics of
+// the criteria: by the log indices for blocks indexed by the LogIndex stage already, by blooms of headers for blocks
+// the stage didn't reach yet (it lags behind Execution during the sync, f.e. the initial one)
+func getLogsBlocks(tx kv.Tx, crit filters.FilterCriteria, begin, end uint64) (*roaring.Bitmap, error) {
+	blockNumbers := roaring.New()
+	indexed, err := stages.GetStageProgress(tx, stages.LogIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(crit.Addresses) == 0 && !hasTopics(crit.Topics) {
+		indexed = end // nothing to filter by
+	}
+	if begin <= indexed {
+		indexedEnd := end
+		if indexedEnd > indexed {
+			indexedEnd = indexed
+		}
+		if blockNumbers, err = getIndexedLogsBlocks(tx, crit, begin, indexedEnd); err != nil {
+			return nil, err
+		}
+		begin = indexedEnd + 1
+	}
+	for blockNum := begin; blockNum <= end; blockNum++ {
+		header := rawdb.ReadHeaderByNumber(tx, blockNum)
+		if header == nil {
+			break
+		}
+		if bloomFilter(header.Bloom, crit.Addresses, crit.Topics) {
+			blockNumbers.Add(uint32(blockNum))
+		}
+	}
+	return blockNumbers, nil
+}
 
+// getIndexedLogsBlocks returns numbers of blocks in [begin, end] which may have logs matching addresses and topics of
+// the criteria, by the log indices
+func getIndexedLogsBlocks(tx kv.Tx, crit filters.FilterCriteria, begin, end uint64) (*roaring.Bitmap, error) {
 	blockNumbers := roaring.New()
 	blockNumbers.AddRange(begin, end+1) // [min,max)
 
@@ -130,59 +301,143 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([
 		}
 	}
 
-	if blockNumbers.GetCardinality() == 0 {
-		return returnLogs(logs), nil
-	}
+	return blockNumbers, nil
+}
 
-	iter := blockNumbers.Iterator()
-	for iter.HasNext() {
-		if err = common.Stopped(ctx.Done()); err != nil {
-			return nil, err
+// hasTopics tells if the topics of the criteria restrict logs, empty alternatives match any topic
+func hasTopics(topics [][]common.Hash) bool {
+	for _, sub := range topics {
+		if len(sub) > 0 {
+			return true
 		}
+	}
+	return false
+}
 
-		blockNToMatch := uint64(iter.Next())
-		var logIndex uint
-		var blockLogs types.Logs
-		if err := tx.ForPrefix(kv.Log, dbutils.EncodeBlockNumber(blockNToMatch), func(k, v []byte) error {
-			var logs types.Logs
-			if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
-				return fmt.Errorf("receipt unmarshal failed:  %w", err)
+// getLogsParallel splits given block numbers into contiguous sub-ranges processed by a bounded pool of workers,
+// results are merged in the order of blocks. Workers stop reading at the deadline (zero - no deadline), except the first
+// block. Logs of blocks preceding the first block not read are returned with the amount of such blocks.
+// Workers stop when logs of their chunk are full (nil - never), chunks after a full one are not read.
+func (api *APIImpl) getLogsParallel(ctx context.Context, blocks []uint32, crit filters.FilterCriteria, deadline time.Time, full func(chunkLogs []*types.Log) bool) ([]*types.Log, int, error) {
+	if len(blocks) == 0 {
+		return nil, 0, nil
+	}
+	chunkSize := (len(blocks) + getLogsWorkers*4 - 1) / (getLogsWorkers * 4)
+	var chunks [][]uint32
+	for len(blocks) > 0 {
+		n := chunkSize
+		if n > len(blocks) {
+			n = len(blocks)
+		}
+		chunks = append(chunks, blocks[:n])
+		blocks = blocks[n:]
+	}
+
+	results := make([][]*types.Log, len(chunks))
+	read := make([]int, len(chunks)) // blocks of chunks read before the deadline
+	fullChunk := int32(len(chunks))  // the first chunk, which logs are full
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, getLogsWorkers)
+	for i := range chunks {
+		i := i
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			for _, log := range logs {
-				log.Index = logIndex
-				logIndex++
+			defer func() { <-sem }()
+
+			tx, err := api.db.BeginRo(ctx)
+			if err != nil {
+				return err
 			}
-			filtered := filterLogs(logs, crit.Addresses, crit.Topics)
-			if len(filtered) > 0 {
-				txIndex := uint(binary.BigEndian.Uint32(k[8:]))
-				for _, log := range filtered {
-					log.TxIndex = txIndex
+			defer tx.Rollback()
+			for j, blockNToMatch := range chunks[i] {
+				if err = common.Stopped(ctx.Done()); err != nil {
+					return err
+				}
+				if (i > 0 || j > 0) && !deadline.IsZero() && time.Now().After(deadline) {
+					return nil
+				}
+				if int32(i) > atomic.LoadInt32(&fullChunk) {
+					return nil
+				}
+				blockLogs, err := getBlockLogs(tx, uint64(blockNToMatch), crit)
+				if err != nil {
+					return err
+				}
+				results[i] = append(results[i], blockLogs...)
+				read[i]++
+				if full != nil && full(results[i]) {
+					for {
+						if first := atomic.LoadInt32(&fullChunk); int32(i) >= first || atomic.CompareAndSwapInt32(&fullChunk, first, int32(i)) {
+							return nil
+						}
+					}
 				}
-				blockLogs = append(blockLogs, filtered...)
 			}
 			return nil
-		}); err != nil {
-			return returnLogs(logs), err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, 0, err
+	}
+	var logs []*types.Log
+	stopped := 0
+	for i, chunkLogs := range results {
+		logs = append(logs, chunkLogs...)
+		stopped += read[i]
+		if read[i] < len(chunks[i]) {
+			break
 		}
+	}
+	return logs, stopped, nil
+}
 
-		if len(blockLogs) > 0 {
-			b, err := rawdb.ReadBlockByNumber(tx, blockNToMatch)
-			if err != nil {
-				return nil, err
-			}
-			if b == nil {
-				return nil, fmt.Errorf("block not found %d", blockNToMatch)
-			}
-			blockHash := b.Hash()
-			for _, log := range blockLogs {
-				log.BlockNumber = blockNToMatch
-				log.BlockHash = blockHash
-				log.TxHash = b.Transactions()[log.TxIndex].Hash()
+// getBlockLogs returns logs of given block matching the filter criteria
+func getBlockLogs(tx kv.Tx, blockNToMatch uint64, crit filters.FilterCriteria) (types.Logs, error) {
+	var logIndex uint
+	var blockLogs types.Logs
+	if err := tx.ForPrefix(kv.Log, dbutils.EncodeBlockNumber(blockNToMatch), func(k, v []byte) error {
+		var logs types.Logs
+		if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
+			return fmt.Errorf("receipt unmarshal failed:  %w", err)
+		}
+		for _, log := range logs {
+			log.Index = logIndex
+			logIndex++
+		}
+		filtered := filterLogs(logs, crit.Addresses, crit.Topics)
+		if len(filtered) > 0 {
+			txIndex := uint(binary.BigEndian.Uint32(k[8:]))
+			for _, log := range filtered {
+				log.TxIndex = txIndex
 			}
-			logs = append(logs, blockLogs...)
+			blockLogs = append(blockLogs, filtered...)
 		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(blockLogs) == 0 {
+		return nil, nil
+	}
+
+	b, err := rawdb.ReadBlockByNumber(tx, blockNToMatch)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("block not found %d", blockNToMatch)
 	}
-	return returnLogs(logs), nil
+	blockHash := b.Hash()
+	for _, log := range blockLogs {
+		log.BlockNumber = blockNToMatch
+		log.BlockHash = blockHash
+		log.TxHash = b.Transactions()[log.TxIndex].Hash()
+	}
+	return blockLogs, nil
 }
 
 // The Topic list restricts matches to particular event topics. Each event has a list
@@ -394,6 +649,36 @@ Logs:
 	return ret
 }
 
+// bloomFilter tells if the block of the bloom may have logs matching the addresses and the topics
+func bloomFilter(bloom types.Bloom, addresses []common.Address, topics [][]common.Hash) bool {
+	if len(addresses) > 0 {
+		var included bool
+		for _, addr := range addresses {
+			if types.BloomLookup(bloom, addr) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, sub := range topics {
+		included := len(sub) == 0 // empty rule set == wildcard
+		for _, topic := range sub {
+			if types.BloomLookup(bloom, topic) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
 func returnLogs(logs []*types.Log) []*types.Log {
 	if logs == nil {
 		return []*types.Log{}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon/common/debug"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// replayChunkBlocks is the number of blocks of history read by one transaction of the replay
+const replayChunkBlocks = 1000
+
+// NewHeadsArgs is the optional parameter of newHeads subscription
+type NewHeadsArgs struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"` // headers since this block are replayed before live ones
+}
+
+// SubscriptionCaughtUp is notified after events of the blocks in the past, requested by fromBlock of the
+// subscription, events after it are live. Each block is delivered once: neither skipped nor repeated at the switch.
+type SubscriptionCaughtUp struct {
+	CaughtUp    bool           `json:"caughtUp"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"` // the last replayed block
+}
+
+// replayFunc delivers events of blocks [from, to), head is the header of the block to-1 if it's a new head
+type replayFunc func(ctx context.Context, from, to uint64, head *types.Header) error
+
+// followHeads delivers events of blocks since from (nil - only live ones) up to the latest one, then events of new
+// heads. Heads which come while the history is replayed are queued, not to block other subscribers of heads.
+func (api *APIImpl) followHeads(notifier *rpc.Notifier, rpcSub *rpc.Subscription, from *uint64, replay replayFunc) {
+	defer debug.LogPanic()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	headers := make(chan *types.Header, 1)
+	defer close(headers)
+	id := api.filters.SubscribeNewHeads(headers)
+	defer api.filters.UnsubscribeHeads(id)
+
+	var next uint64 // the first block not delivered yet, 0 - nothing is delivered
+	var replayed chan error
+	var queued []*types.Header
+	if from != nil {
+		tx, err := api.db.BeginRo(ctx)
+		if err != nil {
+			log.Warn("subscription replay failed", "err", err)
+			return
+		}
+		latest, err := getLatestBlockNumber(tx)
+		tx.Rollback()
+		if err != nil {
+			log.Warn("subscription replay failed", "err", err)
+			return
+		}
+		if *from <= latest {
+			next = latest + 1
+			replayed = make(chan error, 1)
+			go func(from, to uint64) {
+				defer debug.LogPanic()
+				replayed <- replay(ctx, from, to, nil)
+			}(*from, next)
+		}
+	}
+
+	onHead := func(h *types.Header) error {
+		n := h.Number.Uint64()
+		start := n
+		if next > 0 && next <= n {
+			start = next // fills the gap since the last delivered block
+		}
+		next = n + 1
+		return replay(ctx, start, next, h)
+	}
+	for {
+		select {
+		case h := <-headers:
+			if replayed != nil {
+				queued = append(queued, h)
+				continue
+			}
+			if err := onHead(h); err != nil {
+				log.Warn("error while notifying subscription", "err", err)
+			}
+		case err := <-replayed:
+			replayed = nil
+			if err != nil {
+				log.Warn("subscription replay failed", "err", err)
+				return
+			}
+			if err = notifier.Notify(rpcSub.ID, &SubscriptionCaughtUp{CaughtUp: true, BlockNumber: hexutil.Uint64(next - 1)}); err != nil {
+				log.Warn("error while notifying subscription", "err", err)
+			}
+			for _, h := range queued {
+				if h.Number.Uint64() < next { // already replayed
+					continue
+				}
+				if err = onHead(h); err != nil {
+					log.Warn("error while notifying subscription", "err", err)
+				}
+			}
+			queued = nil
+		case <-rpcSub.Err():
+			return
+		}
+	}
+}
+
+// replayHeads notifies headers of blocks, reading them from the database
+func (api *APIImpl) replayHeads(notifier *rpc.Notifier, rpcSub *rpc.Subscription) replayFunc {
+	return func(ctx context.Context, from, to uint64, head *types.Header) error {
+		if head != nil {
+			to--
+		}
+		for from < to {
+			chunkEnd := from + replayChunkBlocks
+			if chunkEnd > to {
+				chunkEnd = to
+			}
+			tx, err := api.db.BeginRo(ctx)
+			if err != nil {
+				return err
+			}
+			for ; from < chunkEnd; from++ {
+				h := rawdb.ReadHeaderByNumber(tx, from)
+				if h == nil {
+					continue
+				}
+				if err = notifier.Notify(rpcSub.ID, h); err != nil {
+					break
+				}
+			}
+			tx.Rollback()
+			if err != nil {
+				return err
+			}
+		}
+		if head != nil {
+			return notifier.Notify(rpcSub.ID, head)
+		}
+		return nil
+	}
+}
+
+// replayLogs notifies logs of blocks matching the criteria, reading them from the database
+func (api *APIImpl) replayLogs(notifier *rpc.Notifier, rpcSub *rpc.Subscription, crit filters.FilterCriteria) replayFunc {
+	return func(ctx context.Context, from, to uint64, _ *types.Header) error {
+		if from == 0 {
+			from = 1 // genesis has no logs, and toBlock 0 of the criteria means the latest block
+		}
+		for from < to {
+			chunkEnd := from + replayChunkBlocks
+			if chunkEnd > to {
+				chunkEnd = to
+			}
+			crit.FromBlock, crit.ToBlock = new(big.Int).SetUint64(from), new(big.Int).SetUint64(chunkEnd-1)
+			logs, _, err := api.getLogs(ctx, crit, nil, logsLimits{})
+			if err != nil {
+				return err
+			}
+			for _, l := range logs {
+				if err = notifier.Notify(rpcSub.ID, l); err != nil {
+					return err
+				}
+			}
+			from = chunkEnd
+		}
+		return nil
+	}
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/filters"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+	ethFilters "github.com/ledgerwatch/erigon/eth/filters"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func nextNotification(t *testing.T, ch chan json.RawMessage, v interface{}) {
+	select {
+	case msg := <-ch:
+		require.NoError(t, json.Unmarshal(msg, v))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+}
+
+func TestSubscriptionReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := rpcdaemontest.CreateTestKV(t)
+	ff := filters.New(ctx, nil, nil, nil)
+	api := NewEthAPI(NewBaseApi(ff), db, nil, nil, nil, 5000000)
+	server := rpc.NewServer(50)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	allLogs, err := api.GetLogs(ctx, ethFilters.FilterCriteria{})
+	require.NoError(t, err)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	latest, err := getLatestBlockNumber(tx)
+	tx.Rollback()
+	require.NoError(t, err)
+
+	heads := make(chan json.RawMessage, 100)
+	headsSub, err := client.EthSubscribe(ctx, heads, "newHeads", map[string]interface{}{"fromBlock": "0x3"})
+	require.NoError(t, err)
+	defer headsSub.Unsubscribe()
+	for n := uint64(3); n <= latest; n++ {
+		var h types.Header
+		nextNotification(t, heads, &h)
+		require.Equal(t, n, h.Number.Uint64())
+	}
+	var caughtUp SubscriptionCaughtUp
+	nextNotification(t, heads, &caughtUp)
+	require.Equal(t, SubscriptionCaughtUp{CaughtUp: true, BlockNumber: hexutil.Uint64(latest)}, caughtUp)
+
+	logs := make(chan json.RawMessage, 100)
+	logsSub, err := client.EthSubscribe(ctx, logs, "logs", map[string]interface{}{"fromBlock": "earliest"})
+	require.NoError(t, err)
+	defer logsSub.Unsubscribe()
+	for _, expected := range allLogs {
+		var l types.Log
+		nextNotification(t, logs, &l)
+		require.Equal(t, expected.TxHash, l.TxHash)
+		require.Equal(t, expected.Index, l.Index)
+	}
+	nextNotification(t, logs, &caughtUp)
+	require.Equal(t, SubscriptionCaughtUp{CaughtUp: true, BlockNumber: hexutil.Uint64(latest)}, caughtUp)
+
+	// new heads are live, the header of the reorged block is delivered again
+	for _, n := range []uint64{latest + 1, latest} {
+		var buf bytes.Buffer
+		require.NoError(t, rlp.Encode(&buf, &types.Header{Number: new(big.Int).SetUint64(n), Difficulty: big.NewInt(1)}))
+		ff.OnNewEvent(&remote.SubscribeReply{Type: remote.Event_HEADER, Data: buf.Bytes()})
+		var h types.Header
+		nextNotification(t, heads, &h)
+		require.Equal(t, n, h.Number.Uint64())
+	}
+}
@@ -3,12 +3,13 @@ package commands
 import (
 	"context"
 	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
 
 	"github.com/ledgerwatch/erigon/common/hexutil"
 	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/types"
-	"github.com/ledgerwatch/erigon/eth/ethconfig"
-	"github.com/ledgerwatch/erigon/eth/gasprice"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rpc"
@@ -29,6 +30,13 @@ func (api *APIImpl) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
 	return hexutil.Uint64(execution), nil
 }
 
+// SyncStageProgress is the progress of a stage of the sync in eth_syncing
+type SyncStageProgress struct {
+	SyncStageInfo
+	BlockNumber hexutil.Uint64 `json:"block_number"`
+	Done        bool           `json:"done"` // the stage reached the highest known block
+}
+
 // Syncing implements eth_syncing. Returns a data object detaling the status of the sync process or false if not syncing.
 func (api *APIImpl) Syncing(ctx context.Context) (interface{}, error) {
 	tx, err := api.db.BeginRo(ctx)
@@ -51,18 +59,17 @@ func (api *APIImpl) Syncing(ctx context.Context) (interface{}, error) {
 	}
 
 	// Otherwise gather the block sync stats
-	type S struct {
-		StageName   string         `json:"stage_name"`
-		BlockNumber hexutil.Uint64 `json:"block_number"`
-	}
-	stagesMap := make([]S, len(stages.AllStages))
+	stagesMap := make([]SyncStageProgress, len(stages.AllStages))
 	for i, stage := range stages.AllStages {
 		progress, err := stages.GetStageProgress(tx, stage)
 		if err != nil {
 			return nil, err
 		}
-		stagesMap[i].StageName = string(stage)
-		stagesMap[i].BlockNumber = hexutil.Uint64(progress)
+		stagesMap[i] = SyncStageProgress{
+			SyncStageInfo: syncStageInfo(stage),
+			BlockNumber:   hexutil.Uint64(progress),
+			Done:          progress >= highestBlock,
+		}
 	}
 
 	return map[string]interface{}{
@@ -92,20 +99,77 @@ func (api *APIImpl) ChainID(ctx context.Context) (hexutil.Uint64, error) {
 	return api.ChainId(ctx)
 }
 
-// ProtocolVersion implements eth_protocolVersion. Returns the current ethereum protocol version.
+// ProtocolVersion implements eth_protocolVersion. Returns the highest version of the eth protocol served by the node.
+// Deprecated: kept for compatibility, erigon_capabilities reports all versions.
 func (api *APIImpl) ProtocolVersion(ctx context.Context) (hexutil.Uint, error) {
-	ver, err := api.ethBackend.ProtocolVersion(ctx)
+	versions, err := api.ethBackend.EthProtocols(ctx)
 	if err != nil {
 		return 0, err
 	}
-	return hexutil.Uint(ver), nil
+	if len(versions) == 0 { // no sentry is connected yet
+		ver, err := api.ethBackend.ProtocolVersion(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return hexutil.Uint(ver), nil
+	}
+	return hexutil.Uint(versions[0]), nil
 }
 
+// gasPriceSampleBlocks - samples of so many recent blocks are kept by the gas price oracle
+const gasPriceSampleBlocks = 1024
+
 // GasPrice implements eth_gasPrice. Returns the current price per gas in wei.
+// When the txpool is congested the suggestion is never lower than the cheapest executable pending transaction.
+// The suggestion is computed once per head, blocks are sampled once and kept until they get old or reorged.
 func (api *APIImpl) GasPrice(ctx context.Context) (*hexutil.Big, error) {
-	oracle := gasprice.NewOracle(api, ethconfig.Defaults.GPO)
-	price, err := oracle.SuggestPrice(ctx)
-	return (*hexutil.Big)(price), err
+	price, err := api.gasPriceOracle.SuggestPrice(ctx)
+	if err != nil {
+		return (*hexutil.Big)(price), err
+	}
+	if floor := api.pendingGasPriceFloor(ctx); floor != nil && floor.Cmp(price) > 0 {
+		price = floor
+	}
+	return (*hexutil.Big)(price), nil
+}
+
+// pendingGasPriceFloor returns min executable pending tip plus the next block's base fee if the pool is congested, nil otherwise.
+// The floor is best-effort: if the txpool can't be reached, suggestions fall back to the block based oracle.
+func (api *APIImpl) pendingGasPriceFloor(ctx context.Context) *big.Int {
+	if api.txPool == nil {
+		return nil
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil
+	}
+	defer tx.Rollback()
+	cc, err := api.chainConfig(tx)
+	if err != nil {
+		return nil
+	}
+	head := rawdb.ReadCurrentHeader(tx)
+	if head == nil {
+		return nil
+	}
+	var baseFee *uint256.Int
+	next := nextBaseFee(cc, head)
+	if next != nil {
+		baseFee, _ = uint256.FromBig(next)
+	}
+	stats, err := api.poolStats.get(ctx, api.txPool, head, baseFee)
+	if err != nil {
+		log.Debug("could not read txpool for gas price floor", "err", err)
+		return nil
+	}
+	if !stats.congested() || stats.minTip() == nil {
+		return nil
+	}
+	floor := stats.minTip().ToBig()
+	if next != nil {
+		floor.Add(floor, next)
+	}
+	return floor
 }
 
 // HeaderByNumber is necessary for gasprice.OracleBackend implementation
@@ -121,7 +185,10 @@ func (api *APIImpl) HeaderByNumber(ctx context.Context, number rpc.BlockNumber)
 		return nil, err
 	}
 
-	header := rawdb.ReadHeaderByNumber(tx, blockNum)
+	header, err := api.canonical.HeaderByNumber(tx, blockNum)
+	if err != nil {
+		return nil, err
+	}
 	if header == nil {
 		return nil, fmt.Errorf("header not found: %d", blockNum)
 	}
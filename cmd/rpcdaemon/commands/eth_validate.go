@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/consensus/ethash"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// Reasons of failures of eth_validateRawTransaction
+const (
+	TxFailureDecode           = "decode"             // not a valid encoding of a transaction
+	TxFailureNotProtected     = "not_protected"      // not replay-protected (EIP-155), refused over RPC
+	TxFailureType             = "type"               // type of the transaction is not activated yet
+	TxFailureSignature        = "signature"          // sender can't be recovered, f.e. signed for another chain
+	TxFailureGasLimit         = "gas_limit"          // gas above the gas limit of the block
+	TxFailureIntrinsicGas     = "intrinsic_gas"      // gas below the intrinsic gas of the transaction
+	TxFailureTipAboveFeeCap   = "tip_above_fee_cap"  // max priority fee above max fee
+	TxFailureFeeCapTooLow     = "fee_cap_too_low"    // max fee below the base fee of the next block
+	TxFailureFeeCapExceeded   = "fee_cap_exceeded"   // fee above the cap of fees of transactions sent over RPC
+	TxFailureNonceTooLow      = "nonce_too_low"      // nonce is already used by the sender
+	TxFailureInsufficientFund = "insufficient_funds" // balance below gas * price + value
+)
+
+// TxValidation is the result of eth_validateRawTransaction
+type TxValidation struct {
+	Valid      bool                  `json:"valid"` // the pool would accept the transaction
+	Hash       *common.Hash          `json:"hash,omitempty"`
+	From       *common.Address       `json:"from,omitempty"`
+	Nonce      *hexutil.Uint64       `json:"nonce,omitempty"`
+	Account    *TxValidationAccount  `json:"account,omitempty"` // state of the sender in the latest block
+	Failures   []TxValidationFailure `json:"failures,omitempty"`
+	Simulation *TxSimulation         `json:"simulation,omitempty"`
+}
+
+// TxValidationAccount is the state of the sender, against which the transaction is validated
+type TxValidationAccount struct {
+	Nonce   hexutil.Uint64 `json:"nonce"`
+	Balance *hexutil.Big   `json:"balance"`
+}
+
+// TxValidationFailure is one of the reasons, why the pool would refuse the transaction
+type TxValidationFailure struct {
+	Reason  string `json:"reason"` // one of TxFailure... constants
+	Message string `json:"message"`
+}
+
+// TxSimulation is the result of execution of the transaction on top of the latest block, as the first one of the next block
+type TxSimulation struct {
+	Error      string          `json:"error,omitempty"` // why the transaction can't be included in the block
+	Status     *hexutil.Uint64 `json:"status,omitempty"`
+	GasUsed    hexutil.Uint64  `json:"gasUsed"`
+	ReturnData hexutil.Bytes   `json:"returnData,omitempty"`
+	Logs       []*types.Log    `json:"logs,omitempty"`
+}
+
+// ValidateRawTransaction implements eth_validateRawTransaction. Decodes a signed transaction and checks it like the pool
+// does before admitting it (signature, nonce, balance, fees and gas) against the latest state, without sending it
+// anywhere. All failed checks are returned. With simulate=true the transaction is also executed on top of the latest
+// block. A transaction with a nonce above the nonce of the sender is valid, it waits in the pool for the gap to be filled.
+func (api *APIImpl) ValidateRawTransaction(ctx context.Context, encodedTx hexutil.Bytes, simulate *bool) (*TxValidation, error) {
+	result := &TxValidation{}
+	fail := func(reason string, format string, args ...interface{}) {
+		result.Failures = append(result.Failures, TxValidationFailure{Reason: reason, Message: fmt.Sprintf(format, args...)})
+	}
+	txn, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(encodedTx), uint64(len(encodedTx))))
+	if err != nil {
+		fail(TxFailureDecode, "%v", err)
+		return result, nil
+	}
+	hash := txn.Hash()
+	nonce := hexutil.Uint64(txn.GetNonce())
+	result.Hash, result.Nonce = &hash, &nonce
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	// plain state is the state after the last executed block
+	parentNum, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	parent := rawdb.ReadHeaderByNumber(tx, parentNum)
+	if parent == nil {
+		return nil, fmt.Errorf("header %d not found", parentNum)
+	}
+	header := buildBlockHeader(chainConfig, parent, BuildBlockArgs{Coinbase: parent.Coinbase})
+	blockNum := header.Number.Uint64()
+
+	if !txn.Protected() {
+		fail(TxFailureNotProtected, "only replay-protected (EIP-155) transactions allowed over RPC")
+	}
+	switch txn.Type() {
+	case types.AccessListTxType:
+		if !chainConfig.IsBerlin(blockNum) {
+			fail(TxFailureType, "%v: access list transactions before Berlin", core.ErrTxTypeNotSupported)
+		}
+	case types.DynamicFeeTxType:
+		if !chainConfig.IsLondon(blockNum) {
+			fail(TxFailureType, "%v: dynamic fee transactions before London", core.ErrTxTypeNotSupported)
+		}
+	}
+	if txn.GetGas() > header.GasLimit {
+		fail(TxFailureGasLimit, "%v: gas %d, block gas limit %d", core.ErrGasLimit, txn.GetGas(), header.GasLimit)
+	}
+	intrinsicGas, err := core.IntrinsicGas(txn.GetData(), txn.GetAccessList(), txn.GetTo() == nil, chainConfig.IsHomestead(blockNum), chainConfig.IsIstanbul(blockNum))
+	if err != nil {
+		fail(TxFailureIntrinsicGas, "%v", err)
+	} else if txn.GetGas() < intrinsicGas {
+		fail(TxFailureIntrinsicGas, "%v: gas %d, minimum needed %d", core.ErrIntrinsicGas, txn.GetGas(), intrinsicGas)
+	}
+	if txn.GetTip().Cmp(txn.GetFeeCap()) > 0 {
+		fail(TxFailureTipAboveFeeCap, "%v: tip %d, fee cap %d", core.ErrTipAboveFeeCap, txn.GetTip(), txn.GetFeeCap())
+	}
+	if header.BaseFee != nil {
+		baseFee, _ := uint256.FromBig(header.BaseFee)
+		if txn.GetFeeCap().Lt(baseFee) {
+			fail(TxFailureFeeCapTooLow, "%v: fee cap %d, base fee %d", core.ErrFeeCapTooLow, txn.GetFeeCap(), baseFee)
+		}
+	}
+	if err := checkTxFee(txn.GetPrice().ToBig(), txn.GetGas(), ethconfig.Defaults.RPCTxFeeCap); err != nil {
+		fail(TxFailureFeeCapExceeded, "%v", err)
+	}
+
+	signer := types.MakeSigner(chainConfig, blockNum)
+	from, err := txn.Sender(*signer)
+	if err != nil {
+		fail(TxFailureSignature, "%v: %v", core.ErrInvalidSender, err)
+		return result, nil
+	}
+	result.From = &from
+	ibs := state.New(state.NewPlainStateReader(tx))
+	account := &TxValidationAccount{Nonce: hexutil.Uint64(ibs.GetNonce(from)), Balance: (*hexutil.Big)(ibs.GetBalance(from).ToBig())}
+	result.Account = account
+	if uint64(account.Nonce) > txn.GetNonce() {
+		fail(TxFailureNonceTooLow, "%v: nonce %d, sender nonce %d", core.ErrNonceTooLow, txn.GetNonce(), account.Nonce)
+	}
+	if cost := txn.Cost(); ibs.GetBalance(from).Lt(cost) {
+		fail(TxFailureInsufficientFund, "%v: balance %d, cost %d", core.ErrInsufficientFunds, ibs.GetBalance(from), cost)
+	}
+	result.Valid = len(result.Failures) == 0
+
+	if simulate != nil && *simulate {
+		result.Simulation = api.simulateTx(tx, chainConfig, ibs, header, txn)
+	}
+	return result, nil
+}
+
+// simulateTx executes the transaction as the first one of the block on top of the latest state
+func (api *APIImpl) simulateTx(tx kv.Tx, chainConfig *params.ChainConfig, ibs *state.IntraBlockState, header *types.Header, txn types.Transaction) *TxSimulation {
+	getHeader := func(hash common.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+	ibs.Prepare(txn.Hash(), common.Hash{}, 0)
+	receipt, returnData, err := core.ApplyTransaction(chainConfig, getHeader, ethash.NewFaker(), &header.Coinbase, gasPool, ibs, state.NewNoopWriter(), header, txn, &header.GasUsed, api.evmLimits.Apply(vm.Config{}), ethdb.GetCheckTEVM(tx))
+	if err != nil {
+		return &TxSimulation{Error: err.Error()}
+	}
+	status := hexutil.Uint64(receipt.Status)
+	return &TxSimulation{Status: &status, GasUsed: hexutil.Uint64(receipt.GasUsed), ReturnData: returnData, Logs: receipt.Logs}
+}
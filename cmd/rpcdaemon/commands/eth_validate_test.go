@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRawTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	acc, err := state.NewPlainStateReader(tx).ReadAccountData(sender)
+	tx.Rollback()
+	require.NoError(t, err)
+
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	price := uint256.NewInt(params.GWei * 100)
+	encode := func(txn types.Transaction) hexutil.Bytes {
+		signed, err := types.SignTx(txn, *signer, key)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, signed.MarshalBinary(&buf))
+		return buf.Bytes()
+	}
+	reasons := func(v *TxValidation) []string {
+		var r []string
+		for _, f := range v.Failures {
+			r = append(r, f.Reason)
+		}
+		return r
+	}
+	simulate := true
+
+	v, err := api.ValidateRawTransaction(ctx, encode(types.NewTransaction(acc.Nonce, common.Address{1}, uint256.NewInt(1), params.TxGas, price, nil)), &simulate)
+	require.NoError(t, err)
+	require.True(t, v.Valid, "%v", v.Failures)
+	require.Equal(t, sender, *v.From)
+	require.Equal(t, acc.Nonce, uint64(v.Account.Nonce))
+	require.NotNil(t, v.Simulation)
+	require.Empty(t, v.Simulation.Error)
+	require.Equal(t, hexutil.Uint64(1), *v.Simulation.Status)
+	require.Equal(t, hexutil.Uint64(params.TxGas), v.Simulation.GasUsed)
+
+	// a gap of nonces is valid, but the transaction can't be executed yet
+	v, err = api.ValidateRawTransaction(ctx, encode(types.NewTransaction(acc.Nonce+2, common.Address{1}, uint256.NewInt(1), params.TxGas, price, nil)), &simulate)
+	require.NoError(t, err)
+	require.True(t, v.Valid)
+	require.NotEmpty(t, v.Simulation.Error)
+
+	v, err = api.ValidateRawTransaction(ctx, encode(types.NewTransaction(acc.Nonce-1, common.Address{1}, acc.Balance.Clone(), params.TxGas-1, price, nil)), nil)
+	require.NoError(t, err)
+	require.False(t, v.Valid)
+	require.Equal(t, []string{TxFailureIntrinsicGas, TxFailureNonceTooLow, TxFailureInsufficientFund}, reasons(v))
+	require.Nil(t, v.Simulation)
+
+	v, err = api.ValidateRawTransaction(ctx, hexutil.Bytes{0x01, 0x02}, nil)
+	require.NoError(t, err)
+	require.False(t, v.Valid)
+	require.Equal(t, []string{TxFailureDecode}, reasons(v))
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+)
+
+// Accounts implements eth_accounts. Returns a list of addresses owned by the client, the same as wallet_accounts.
+func (api *APIImpl) Accounts(ctx context.Context) ([]common.Address, error) {
+	if api.wallet == nil {
+		return []common.Address{}, fmt.Errorf(NotAvailableWallet, "eth_accounts")
+	}
+	return api.wallet.Accounts(ctx)
+}
+
+// Sign implements eth_sign. Calculates an Ethereum specific signature with: sign(keccak256('\\x19Ethereum Signed Message:\\n' + len(message) + message))).
+func (api *APIImpl) Sign(ctx context.Context, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if api.wallet == nil {
+		return nil, fmt.Errorf(NotAvailableWallet, "eth_sign")
+	}
+	return api.wallet.Sign(ctx, address, data)
+}
+
+// SignTransaction implements eth_signTransaction. Signs the transaction without submitting it, missing fields are filled as for eth_sendTransaction.
+func (api *APIImpl) SignTransaction(ctx context.Context, args ethapi.SendTxArgs) (*ethapi.SignTransactionResult, error) {
+	if api.wallet == nil {
+		return nil, fmt.Errorf(NotAvailableWallet, "eth_signTransaction")
+	}
+	return api.wallet.SignTransaction(ctx, args)
+}
+
+// SendTransaction implements eth_sendTransaction. Creates new message call transaction or a contract creation if the data field contains code.
+func (api *APIImpl) SendTransaction(ctx context.Context, args ethapi.SendTxArgs) (common.Hash, error) {
+	if api.wallet == nil {
+		return common.Hash{}, fmt.Errorf(NotAvailableWallet, "eth_sendTransaction")
+	}
+	return api.wallet.SendTransaction(ctx, args)
+}
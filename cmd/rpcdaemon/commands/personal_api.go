@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/accounts/external"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+)
+
+// PersonalAPI is the personal_* namespace for legacy tooling. Keys are never kept by rpcdaemon: all methods forward
+// to the external signer, which asks its user to approve every request. Passwords are ignored and not sent anywhere.
+type PersonalAPI interface {
+	ListAccounts(ctx context.Context) ([]common.Address, error)
+	NewAccount(ctx context.Context, password string) (common.Address, error)
+	ImportRawKey(ctx context.Context, privkey string, password string) (common.Address, error)
+	UnlockAccount(ctx context.Context, addr common.Address, password string, duration *uint64) (bool, error)
+	LockAccount(ctx context.Context, addr common.Address) (bool, error)
+	SendTransaction(ctx context.Context, args ethapi.SendTxArgs, password string) (common.Hash, error)
+	SignTransaction(ctx context.Context, args ethapi.SendTxArgs, password string) (*ethapi.SignTransactionResult, error)
+	Sign(ctx context.Context, data hexutil.Bytes, addr common.Address, password string) (hexutil.Bytes, error)
+	EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.Address, error)
+}
+
+// PersonalAPIImpl is implementation of the PersonalAPI interface based on wallet_* methods
+type PersonalAPIImpl struct {
+	wallet *WalletAPIImpl
+	signer *external.Signer
+}
+
+// NewPersonalAPI returns PersonalAPIImpl instance, wallet must sign by the external signer
+func NewPersonalAPI(wallet *WalletAPIImpl, signer *external.Signer) *PersonalAPIImpl {
+	return &PersonalAPIImpl{wallet: wallet, signer: signer}
+}
+
+// ListAccounts implements personal_listAccounts. Returns accounts of the signer.
+func (api *PersonalAPIImpl) ListAccounts(ctx context.Context) ([]common.Address, error) {
+	return api.signer.Accounts(ctx)
+}
+
+// NewAccount implements personal_newAccount. The signer creates the account and asks its user for the password.
+func (api *PersonalAPIImpl) NewAccount(ctx context.Context, _ string) (common.Address, error) {
+	return api.signer.NewAccount(ctx)
+}
+
+// ImportRawKey implements personal_importRawKey. Not supported: the key would pass through rpcdaemon.
+func (api *PersonalAPIImpl) ImportRawKey(_ context.Context, _ string, _ string) (common.Address, error) {
+	return common.Address{}, errors.New("personal_importRawKey is not supported, keys must be imported into the external signer")
+}
+
+// UnlockAccount implements personal_unlockAccount. There is nothing to unlock: the signer approves every request,
+// so it only checks that the signer has the account.
+func (api *PersonalAPIImpl) UnlockAccount(ctx context.Context, addr common.Address, _ string, _ *uint64) (bool, error) {
+	all, err := api.signer.Accounts(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, account := range all {
+		if account == addr {
+			return true, nil
+		}
+	}
+	return false, accounts.ErrUnknownAccount
+}
+
+// LockAccount implements personal_lockAccount. Accounts are never unlocked, see UnlockAccount.
+func (api *PersonalAPIImpl) LockAccount(_ context.Context, _ common.Address) (bool, error) {
+	return true, nil
+}
+
+// SendTransaction implements personal_sendTransaction. The same as wallet_sendTransaction, the password is ignored.
+func (api *PersonalAPIImpl) SendTransaction(ctx context.Context, args ethapi.SendTxArgs, _ string) (common.Hash, error) {
+	return api.wallet.SendTransaction(ctx, args)
+}
+
+// SignTransaction implements personal_signTransaction. The same as wallet_signTransaction, the password is ignored.
+func (api *PersonalAPIImpl) SignTransaction(ctx context.Context, args ethapi.SendTxArgs, _ string) (*ethapi.SignTransactionResult, error) {
+	return api.wallet.SignTransaction(ctx, args)
+}
+
+// Sign implements personal_sign. The same as wallet_sign with swapped params, the password is ignored.
+func (api *PersonalAPIImpl) Sign(ctx context.Context, data hexutil.Bytes, addr common.Address, _ string) (hexutil.Bytes, error) {
+	return api.wallet.Sign(ctx, addr, data)
+}
+
+// EcRecover implements personal_ecRecover. Returns the account, which signed the data with personal_sign.
+func (api *PersonalAPIImpl) EcRecover(_ context.Context, data, sig hexutil.Bytes) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)
+	}
+	if sig[crypto.RecoveryIDOffset] != 27 && sig[crypto.RecoveryIDOffset] != 28 {
+		return common.Address{}, errors.New("invalid Ethereum signature (V is not 27 or 28)")
+	}
+	sig = common.CopyBytes(sig)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash(data), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
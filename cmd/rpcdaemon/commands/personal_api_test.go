@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/accounts/external"
+	"github.com/ledgerwatch/erigon/accounts/keystore"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner serves account_* methods of clef with keys of the keystore
+type testSigner struct {
+	ks *keystore.KeyStore
+}
+
+func (s *testSigner) List(ctx context.Context) ([]common.Address, error) {
+	return s.ks.Accounts(ctx)
+}
+
+func (s *testSigner) SignData(ctx context.Context, contentType string, addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	return s.ks.SignText(ctx, addr, data)
+}
+
+func (s *testSigner) SignTransaction(ctx context.Context, args ethapi.SendTxArgs) (*ethapi.SignTransactionResult, error) {
+	signed, err := s.ks.SignTx(ctx, args.From, args.ToTransaction(), args.ChainID.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = signed.MarshalBinary(&buf); err != nil {
+		return nil, err
+	}
+	return &ethapi.SignTransactionResult{Raw: buf.Bytes(), Tx: signed}, nil
+}
+
+func TestPersonal(t *testing.T) {
+	ctx := context.Background()
+	ks, err := keystore.Open(t.TempDir())
+	require.NoError(t, err)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from, err := ks.StoreKey(key, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	require.NoError(t, ks.Unlock(from, "password"))
+
+	server := rpc.NewServer(50)
+	require.NoError(t, server.RegisterName("account", &testSigner{ks: ks}))
+	signer := external.NewSigner("test", rpc.DialInProc(server))
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := external.OpenAudit(auditPath)
+	require.NoError(t, err)
+	signer.SetAudit(audit)
+	defer signer.Close()
+
+	eth := NewEthAPI(NewBaseApi(nil), rpcdaemontest.CreateTestKV(t), nil, nil, nil, 5000000)
+	api := NewPersonalAPI(NewWalletAPI(eth, signer), signer)
+
+	all, err := api.ListAccounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{from}, all)
+
+	ok, err := api.UnlockAccount(ctx, from, "wrong password is fine", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = api.UnlockAccount(ctx, common.Address{1}, "", nil)
+	require.Equal(t, accounts.ErrUnknownAccount, err)
+
+	sig, err := api.Sign(ctx, hexutil.Bytes("hello"), from, "password")
+	require.NoError(t, err)
+	signedBy, err := api.EcRecover(ctx, hexutil.Bytes("hello"), sig)
+	require.NoError(t, err)
+	require.Equal(t, from, signedBy)
+
+	to := common.HexToAddress("0x0d3ab14bbad3d99f4203bd7a11acb94882050e7e")
+	res, err := api.SignTransaction(ctx, ethapi.SendTxArgs{From: from, To: &to, Value: (*hexutil.Big)(common.Big1)}, "password")
+	require.NoError(t, err)
+	signed, err := types.UnmarshalTransactionFromBinary(res.Raw)
+	require.NoError(t, err)
+	sender, err := signed.Sender(*types.LatestSignerForChainID(eth._chainConfig.ChainID))
+	require.NoError(t, err)
+	require.Equal(t, from, sender)
+
+	_, err = api.ImportRawKey(ctx, "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291", "password")
+	require.Error(t, err)
+
+	// every request to the signer is audited, and passwords are not there
+	content, err := ioutil.ReadFile(auditPath)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "password")
+	var methods []string
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var r external.AuditRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		require.Empty(t, r.Error)
+		methods = append(methods, r.Method)
+	}
+	require.Equal(t, []string{"account_list", "account_list", "account_list", "account_signData", "account_signTransaction"}, methods)
+}
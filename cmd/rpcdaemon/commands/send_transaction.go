@@ -41,7 +41,10 @@ func (api *APIImpl) SendRawTransaction(ctx context.Context, encodedTx hexutil.By
 	}
 
 	if res.Imported[0] != txpool.ImportResult_SUCCESS {
-		return hash, fmt.Errorf("%s: %s", txpool.ImportResult_name[int32(res.Imported[0])], res.Errors[0])
+		return hash, api.txRejectedError(ctx, txn, res.Imported[0], res.Errors[0])
+	}
+	if api.txBroadcaster != nil {
+		api.txBroadcaster.Broadcast(encodedTx)
 	}
 
 	tx, err := api.db.BeginRo(ctx)
@@ -66,15 +69,13 @@ func (api *APIImpl) SendRawTransaction(ctx context.Context, encodedTx hexutil.By
 	} else {
 		log.Info("Submitted transaction", "hash", txn.Hash().Hex(), "from", from, "nonce", txn.GetNonce(), "recipient", txn.GetTo(), "value", txn.GetValue())
 	}
+	if api.txMonitor != nil {
+		api.txMonitor.Track(txn, from, *blockNum)
+	}
 
 	return txn.Hash(), nil
 }
 
-// SendTransaction implements eth_sendTransaction. Creates new message call transaction or a contract creation if the data field contains code.
-func (api *APIImpl) SendTransaction(_ context.Context, txObject interface{}) (common.Hash, error) {
-	return common.Hash{0}, fmt.Errorf(NotImplemented, "eth_sendTransaction")
-}
-
 // checkTxFee is an internal function used to check whether the fee of
 // the given transaction is _reasonable_(under the cap).
 func checkTxFee(gasPrice *big.Int, gas uint64, cap float64) error {
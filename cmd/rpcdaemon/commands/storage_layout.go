@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+)
+
+// StorageLayout is the storage layout of a contract, as given by solc --storage-layout
+type StorageLayout struct {
+	Storage []StorageLayoutVar           `json:"storage"`
+	Types   map[string]StorageLayoutType `json:"types"`
+}
+
+// StorageLayoutVar is a state variable or a member of a struct, slot is relative to the struct
+type StorageLayoutVar struct {
+	Label  string `json:"label"`
+	Offset int    `json:"offset"` // in bytes from the lowest-order byte of the slot
+	Slot   string `json:"slot"`   // decimal
+	Type   string `json:"type"`
+}
+
+type StorageLayoutType struct {
+	Encoding      string             `json:"encoding"` // inplace, mapping, dynamic_array or bytes
+	Label         string             `json:"label"`
+	NumberOfBytes string             `json:"numberOfBytes"`
+	Base          string             `json:"base,omitempty"`    // element type of arrays
+	Members       []StorageLayoutVar `json:"members,omitempty"` // of structs
+}
+
+// StorageVar is a variable, which value is (partly) in the slot
+type StorageVar struct {
+	Label  string          `json:"label"`
+	Type   string          `json:"type"`
+	Value  interface{}     `json:"value,omitempty"`
+	Length *hexutil.Uint64 `json:"length,omitempty"` // of dynamic arrays, and of strings and bytes too long to be in the slot
+}
+
+// maxLayoutArrayElements limits expansion of static arrays, the rest of the elements are not labeled
+const maxLayoutArrayElements = 1024
+
+// maxLayoutVars limits the number of variables, struct members and array elements of the layout: nested arrays
+// multiply their elements
+const maxLayoutVars = 1 << 16
+
+// maxStorageLayouts limits the number of contracts with layouts
+const maxStorageLayouts = 1024
+
+// layoutVar is a variable of the compiled layout, placed in an absolute slot
+type layoutVar struct {
+	label    string
+	typ      StorageLayoutType
+	offset   int
+	size     int
+	encoding string
+}
+
+// compiledLayout maps slots to the variables in them. Only variables at fixed slots are there,
+// elements of mappings and dynamic arrays are at hashed slots and are not labeled.
+type compiledLayout map[common.Hash][]layoutVar
+
+func compileStorageLayout(layout *StorageLayout) (compiledLayout, error) {
+	cl, placed := compiledLayout{}, 0
+	for _, v := range layout.Storage {
+		slot, ok := new(big.Int).SetString(v.Slot, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid slot %q of %s", v.Slot, v.Label)
+		}
+		if err := cl.place(layout, v.Label, slot, v.Offset, v.Type, 0, &placed); err != nil {
+			return nil, err
+		}
+	}
+	return cl, nil
+}
+
+func (cl compiledLayout) place(layout *StorageLayout, label string, slot *big.Int, offset int, typeName string, depth int, placed *int) error {
+	if depth > 16 {
+		return fmt.Errorf("%s: types are nested too deep", label)
+	}
+	if *placed++; *placed > maxLayoutVars {
+		return fmt.Errorf("more than %d variables", maxLayoutVars)
+	}
+	if offset < 0 || offset >= 32 {
+		return fmt.Errorf("%s: invalid offset %d", label, offset)
+	}
+	typ, ok := layout.Types[typeName]
+	if !ok {
+		return fmt.Errorf("%s: unknown type %s", label, typeName)
+	}
+	size, err := strconv.Atoi(typ.NumberOfBytes)
+	if err != nil {
+		return fmt.Errorf("%s: invalid numberOfBytes %q", label, typ.NumberOfBytes)
+	}
+	switch {
+	case typ.Encoding == "inplace" && len(typ.Members) > 0: // struct
+		for _, m := range typ.Members {
+			memberSlot, ok := new(big.Int).SetString(m.Slot, 10)
+			if !ok {
+				return fmt.Errorf("invalid slot %q of %s.%s", m.Slot, label, m.Label)
+			}
+			if err := cl.place(layout, label+"."+m.Label, memberSlot.Add(memberSlot, slot), m.Offset, m.Type, depth+1, placed); err != nil {
+				return err
+			}
+		}
+		return nil
+	case typ.Encoding == "inplace" && typ.Base != "": // static array
+		base, ok := layout.Types[typ.Base]
+		if !ok {
+			return fmt.Errorf("%s: unknown type %s", label, typ.Base)
+		}
+		baseSize, err := strconv.Atoi(base.NumberOfBytes)
+		if err != nil || baseSize == 0 {
+			return fmt.Errorf("%s: invalid numberOfBytes %q", label, base.NumberOfBytes)
+		}
+		n := staticArrayLength(typ.Label)
+		if n > maxLayoutArrayElements {
+			n = maxLayoutArrayElements
+		}
+		elemSlot, elemOffset := new(big.Int).Set(slot), 0
+		for i := 0; i < n; i++ {
+			if baseSize >= 32 { // element takes whole slots
+				if i > 0 {
+					elemSlot = new(big.Int).Add(elemSlot, big.NewInt(int64((baseSize+31)/32)))
+				}
+			} else if elemOffset+baseSize > 32 {
+				elemSlot, elemOffset = new(big.Int).Add(elemSlot, common.Big1), 0
+			}
+			if err := cl.place(layout, fmt.Sprintf("%s[%d]", label, i), elemSlot, elemOffset, typ.Base, depth+1, placed); err != nil {
+				return err
+			}
+			if baseSize < 32 {
+				elemOffset += baseSize
+			}
+		}
+		return nil
+	case typ.Encoding == "mapping":
+		return nil // nothing is stored in the slot of a mapping
+	}
+	if size <= 0 || offset+size > 32 {
+		size = 32 - offset // dynamic types take the whole slot
+	}
+	h := common.BigToHash(slot)
+	cl[h] = append(cl[h], layoutVar{label: label, typ: typ, offset: offset, size: size, encoding: typ.Encoding})
+	return nil
+}
+
+// staticArrayLength parses N from labels like "uint256[N]"
+func staticArrayLength(label string) int {
+	i := strings.LastIndex(label, "[")
+	if i < 0 || !strings.HasSuffix(label, "]") {
+		return 0
+	}
+	n, err := strconv.Atoi(label[i+1 : len(label)-1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// decode returns labeled values of the variables in the slot
+func (cl compiledLayout) decode(slot, value common.Hash) []StorageVar {
+	vars := cl[slot]
+	if len(vars) == 0 {
+		return nil
+	}
+	result := make([]StorageVar, 0, len(vars))
+	for _, v := range vars {
+		raw := value[32-v.offset-v.size : 32-v.offset]
+		sv := StorageVar{Label: v.label, Type: v.typ.Label}
+		switch v.encoding {
+		case "dynamic_array":
+			length := hexutil.Uint64(new(big.Int).SetBytes(raw).Uint64())
+			sv.Length = &length
+		case "bytes":
+			decodeStorageBytes(&sv, value)
+		default:
+			sv.Value = decodeStorageValue(v.typ.Label, raw)
+		}
+		result = append(result, sv)
+	}
+	return result
+}
+
+// decodeStorageBytes decodes strings and bytes: short ones are in the slot with length*2 in the lowest byte,
+// for long ones the slot has length*2+1 and the data is elsewhere
+func decodeStorageBytes(sv *StorageVar, value common.Hash) {
+	if value[31]&1 == 1 {
+		length := hexutil.Uint64(new(big.Int).Rsh(value.Big(), 1).Uint64())
+		sv.Length = &length
+		return
+	}
+	data := value[:value[31]/2]
+	if sv.Type == "string" {
+		sv.Value = string(data)
+	} else {
+		sv.Value = hexutil.Bytes(common.CopyBytes(data))
+	}
+}
+
+func decodeStorageValue(typeLabel string, raw []byte) interface{} {
+	switch {
+	case typeLabel == "bool":
+		return raw[len(raw)-1] != 0
+	case typeLabel == "address" || typeLabel == "address payable" || strings.HasPrefix(typeLabel, "contract "):
+		return common.BytesToAddress(raw)
+	case strings.HasPrefix(typeLabel, "uint") || strings.HasPrefix(typeLabel, "enum "):
+		return new(big.Int).SetBytes(raw).String()
+	case strings.HasPrefix(typeLabel, "int"):
+		v := new(big.Int).SetBytes(raw)
+		if len(raw) > 0 && raw[0]&0x80 != 0 { // two's complement
+			v.Sub(v, new(big.Int).Lsh(common.Big1, uint(len(raw)*8)))
+		}
+		return v.String()
+	default: // bytesN, function pointers and user types are shown as they are
+		return hexutil.Bytes(common.CopyBytes(raw))
+	}
+}
+
+// storageLayouts are the layouts uploaded by debug_setStorageLayout, they are kept in memory
+type storageLayouts struct {
+	lock    sync.RWMutex
+	layouts map[common.Address]compiledLayout
+}
+
+func newStorageLayouts() *storageLayouts {
+	return &storageLayouts{layouts: map[common.Address]compiledLayout{}}
+}
+
+func (l *storageLayouts) get(address common.Address) compiledLayout {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.layouts[address]
+}
+
+// set replaces the layout of the contract, null removes it. Layouts of at most maxStorageLayouts contracts are kept
+func (l *storageLayouts) set(address common.Address, data json.RawMessage) error {
+	var layout *StorageLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return fmt.Errorf("invalid storage layout: %w", err)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if layout == nil {
+		delete(l.layouts, address)
+		return nil
+	}
+	if _, ok := l.layouts[address]; !ok && len(l.layouts) >= maxStorageLayouts {
+		return fmt.Errorf("too many storage layouts, remove some of them first, at most %d are kept", maxStorageLayouts)
+	}
+	cl, err := compileStorageLayout(layout)
+	if err != nil {
+		return fmt.Errorf("invalid storage layout: %w", err)
+	}
+	l.layouts[address] = cl
+	return nil
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// layout of contracts/token.sol
+const tokenStorageLayout = `{
+	"storage": [
+		{"label": "totalSupply", "offset": 0, "slot": "0", "type": "t_uint256"},
+		{"label": "balanceOf", "offset": 0, "slot": "1", "type": "t_mapping(t_address,t_uint256)"},
+		{"label": "minter", "offset": 0, "slot": "2", "type": "t_address"}
+	],
+	"types": {
+		"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+		"t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"}
+	}
+}`
+
+func TestReadStorageRange(t *testing.T) {
+	db := rpcdaemontest.CreateTestKV(t)
+	api := NewPrivateDebugAPI(NewBaseApi(nil), db, 0)
+	ctx := context.Background()
+
+	// the token is deployed in block 3
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	block, err := rawdb.ReadBlockByNumber(tx, 3)
+	require.NoError(t, err)
+	tx.Rollback()
+	txn := block.Transactions()[0]
+	sender, err := txn.Sender(*types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID))
+	require.NoError(t, err)
+	token := crypto.CreateAddress(sender, txn.GetNonce())
+
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	result, err := api.ReadStorageRange(ctx, token, common.Hash{}, 2, latest)
+	require.NoError(t, err)
+	require.Len(t, result.Slots, 2)
+	require.Equal(t, common.BigToHash(big.NewInt(0)), result.Slots[0].Slot)
+	require.Equal(t, common.BigToHash(big.NewInt(10)), result.Slots[0].Value)
+	require.Equal(t, common.BigToHash(big.NewInt(2)), result.Slots[1].Slot)
+	require.Nil(t, result.Slots[0].Vars)
+	require.NotNil(t, result.NextSlot)
+
+	// balances of the mapping are after the variables
+	rest, err := api.ReadStorageRange(ctx, token, *result.NextSlot, 0, latest)
+	require.NoError(t, err)
+	require.Len(t, rest.Slots, 2)
+	require.Nil(t, rest.NextSlot)
+	require.Equal(t, *result.NextSlot, rest.Slots[0].Slot)
+
+	// before the mint
+	before, err := api.ReadStorageRange(ctx, token, common.Hash{}, 0, rpc.BlockNumberOrHashWithNumber(3))
+	require.NoError(t, err)
+	require.Len(t, before.Slots, 1)
+
+	require.NoError(t, api.SetStorageLayout(ctx, token, json.RawMessage(tokenStorageLayout)))
+	result, err = api.ReadStorageRange(ctx, token, common.Hash{}, 2, latest)
+	require.NoError(t, err)
+	require.Equal(t, []StorageVar{{Label: "totalSupply", Type: "uint256", Value: "10"}}, result.Slots[0].Vars)
+	require.Len(t, result.Slots[1].Vars, 1)
+	require.Equal(t, "minter", result.Slots[1].Vars[0].Label)
+	require.Equal(t, common.BytesToAddress(result.Slots[1].Value[12:]), result.Slots[1].Vars[0].Value)
+
+	require.NoError(t, api.SetStorageLayout(ctx, token, json.RawMessage("null")))
+	result, err = api.ReadStorageRange(ctx, token, common.Hash{}, 2, latest)
+	require.NoError(t, err)
+	require.Nil(t, result.Slots[0].Vars)
+	require.Error(t, api.SetStorageLayout(ctx, token, json.RawMessage(`{"storage": [{"label": "x", "slot": "0", "type": "t_unknown"}]}`)))
+}
+
+func TestDecodeStorageLayout(t *testing.T) {
+	var layout StorageLayout
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"storage": [
+			{"label": "flag", "offset": 0, "slot": "0", "type": "t_bool"},
+			{"label": "delta", "offset": 1, "slot": "0", "type": "t_int16"},
+			{"label": "name", "offset": 0, "slot": "1", "type": "t_string_storage"},
+			{"label": "small", "offset": 0, "slot": "2", "type": "t_array(t_uint64)3_storage"},
+			{"label": "items", "offset": 0, "slot": "3", "type": "t_array(t_struct(Item)1_storage)dyn_storage"},
+			{"label": "item", "offset": 0, "slot": "4", "type": "t_struct(Item)1_storage"}
+		],
+		"types": {
+			"t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+			"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+			"t_int16": {"encoding": "inplace", "label": "int16", "numberOfBytes": "2"},
+			"t_uint64": {"encoding": "inplace", "label": "uint64", "numberOfBytes": "8"},
+			"t_bytes4": {"encoding": "inplace", "label": "bytes4", "numberOfBytes": "4"},
+			"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+			"t_array(t_uint64)3_storage": {"base": "t_uint64", "encoding": "inplace", "label": "uint64[3]", "numberOfBytes": "32"},
+			"t_array(t_struct(Item)1_storage)dyn_storage": {"base": "t_struct(Item)1_storage", "encoding": "dynamic_array", "label": "struct Item[]", "numberOfBytes": "32"},
+			"t_struct(Item)1_storage": {"encoding": "inplace", "label": "struct Item", "numberOfBytes": "64", "members": [
+				{"label": "id", "offset": 0, "slot": "0", "type": "t_bytes4"},
+				{"label": "owner", "offset": 4, "slot": "0", "type": "t_address"},
+				{"label": "name", "offset": 0, "slot": "1", "type": "t_string_storage"}
+			]}
+		}
+	}`), &layout))
+	cl, err := compileStorageLayout(&layout)
+	require.NoError(t, err)
+
+	slot := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+	require.Equal(t, []StorageVar{
+		{Label: "flag", Type: "bool", Value: true},
+		{Label: "delta", Type: "int16", Value: "-2"},
+	}, cl.decode(slot(0), common.HexToHash("0xfffe01")))
+
+	var short common.Hash
+	copy(short[:], "erigon")
+	short[31] = 6 * 2
+	require.Equal(t, []StorageVar{{Label: "name", Type: "string", Value: "erigon"}}, cl.decode(slot(1), short))
+	long := hexutil.Uint64(100)
+	require.Equal(t, []StorageVar{{Label: "name", Type: "string", Length: &long}}, cl.decode(slot(1), slot(201)))
+
+	require.Equal(t, []StorageVar{
+		{Label: "small[0]", Type: "uint64", Value: "1"},
+		{Label: "small[1]", Type: "uint64", Value: "2"},
+		{Label: "small[2]", Type: "uint64", Value: "3"},
+	}, cl.decode(slot(2), common.HexToHash("0x000000000000000300000000000000020000000000000001")))
+
+	length := hexutil.Uint64(5)
+	require.Equal(t, []StorageVar{{Label: "items", Type: "struct Item[]", Length: &length}}, cl.decode(slot(3), slot(5)))
+
+	owner := common.HexToAddress("0x1234")
+	var item common.Hash
+	copy(item[8:28], owner[:])
+	copy(item[28:], []byte{0xde, 0xad, 0xbe, 0xef})
+	require.Equal(t, []StorageVar{
+		{Label: "item.id", Type: "bytes4", Value: hexutil.Bytes{0xde, 0xad, 0xbe, 0xef}},
+		{Label: "item.owner", Type: "address", Value: owner},
+	}, cl.decode(slot(4), item))
+	require.Len(t, cl.decode(slot(5), short), 1)
+	require.Nil(t, cl.decode(slot(6), short))
+
+	// offsets out of the slot and layouts too big to expand are refused
+	layout.Storage = []StorageLayoutVar{{Label: "flag", Offset: 32, Slot: "0", Type: "t_bool"}}
+	_, err = compileStorageLayout(&layout)
+	require.Error(t, err)
+	layout.Types["t_array(t_bool)1024_storage"] = StorageLayoutType{Base: "t_bool", Encoding: "inplace", Label: "bool[1024]", NumberOfBytes: "1024"}
+	layout.Types["t_array(t_array(t_bool)1024_storage)1024_storage"] = StorageLayoutType{Base: "t_array(t_bool)1024_storage", Encoding: "inplace", Label: "bool[1024][1024]", NumberOfBytes: "1048576"}
+	layout.Storage = []StorageLayoutVar{{Label: "flags", Slot: "0", Type: "t_array(t_array(t_bool)1024_storage)1024_storage"}}
+	_, err = compileStorageLayout(&layout)
+	require.Error(t, err)
+
+	layouts := newStorageLayouts()
+	for i := 0; i < maxStorageLayouts; i++ {
+		require.NoError(t, layouts.set(common.BigToAddress(big.NewInt(int64(i))), json.RawMessage(`{"storage": [], "types": {}}`)))
+	}
+	require.Error(t, layouts.set(common.HexToAddress("0xffff"), json.RawMessage(`{"storage": [], "types": {}}`)))
+	require.NoError(t, layouts.set(common.BigToAddress(common.Big0), json.RawMessage(`null`)))
+	require.NoError(t, layouts.set(common.HexToAddress("0xffff"), json.RawMessage(`{"storage": [], "types": {}}`)))
+}
@@ -1,11 +1,15 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
 )
 
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
@@ -40,3 +44,58 @@ func StorageRangeAt(stateReader *state.PlainState, contractAddress common.Addres
 	}
 	return result, nil
 }
+
+// StorageSlotsResult is the result of a debug_readStorageRange API call
+type StorageSlotsResult struct {
+	Slots    []StorageSlot `json:"slots"`
+	NextSlot *common.Hash  `json:"nextSlot"` // nil if there are no more slots
+}
+
+// StorageSlot is a non-zero slot of the storage, with values of the variables in it if the storage layout is known
+type StorageSlot struct {
+	Slot  common.Hash  `json:"slot"`
+	Value common.Hash  `json:"value"`
+	Vars  []StorageVar `json:"vars,omitempty"`
+}
+
+// readStorageRangeMaxResults is the limit of debug_readStorageRange page
+const readStorageRangeMaxResults = 1024
+
+// ReadStorageRange implements debug_readStorageRange. Returns non-zero storage slots of the contract at the block,
+// starting from startSlot in the order of slot numbers (not their hashes, unlike debug_storageRangeAt). If the storage
+// layout of the contract was uploaded by debug_setStorageLayout, variables in the slots are decoded too.
+func (api *PrivateDebugAPIImpl) ReadStorageRange(ctx context.Context, address common.Address, startSlot common.Hash, maxResults int, blockNrOrHash rpc.BlockNumberOrHash) (*StorageSlotsResult, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNumber, _, err := rpchelper.GetBlockNumber(blockNrOrHash, tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+	if maxResults <= 0 || maxResults > readStorageRangeMaxResults {
+		maxResults = readStorageRangeMaxResults
+	}
+	layout := api.layouts.get(address)
+	result := &StorageSlotsResult{Slots: []StorageSlot{}}
+	if err := state.NewPlainState(tx, blockNumber).ForEachStorage(address, startSlot, func(key, _ common.Hash, value uint256.Int) bool {
+		if len(result.Slots) == maxResults {
+			result.NextSlot = &key
+			return false
+		}
+		v := value.Bytes32()
+		result.Slots = append(result.Slots, StorageSlot{Slot: key, Value: v, Vars: layout.decode(key, v)})
+		return true
+	}, maxResults+1); err != nil {
+		return nil, fmt.Errorf("error walking over storage: %w", err)
+	}
+	return result, nil
+}
+
+// SetStorageLayout implements debug_setStorageLayout. Sets the storage layout of the contract (as given by
+// solc --storage-layout) to decode its slots in debug_readStorageRange, null removes it. Layouts are kept in memory.
+func (api *PrivateDebugAPIImpl) SetStorageLayout(_ context.Context, address common.Address, layout json.RawMessage) error {
+	return api.layouts.set(address, layout)
+}
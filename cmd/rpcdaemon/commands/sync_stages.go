@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+)
+
+// SyncStageID identifies a stage of the sync in eth_syncing and erigon_syncStages. Values are stable: they don't follow
+// renames of stages inside Erigon, new stages get new values, values of removed stages are never reused.
+type SyncStageID string
+
+const (
+	SyncStageHeaders             SyncStageID = "headers"
+	SyncStageBlockHashes         SyncStageID = "block_hashes"
+	SyncStageBodies              SyncStageID = "bodies"
+	SyncStageSenders             SyncStageID = "senders"
+	SyncStageExecution           SyncStageID = "execution"
+	SyncStageTranslation         SyncStageID = "translation"
+	SyncStageHashState           SyncStageID = "hash_state"
+	SyncStageIntermediateHashes  SyncStageID = "intermediate_hashes"
+	SyncStageCrossCheck          SyncStageID = "cross_check"
+	SyncStageAccountHistoryIndex SyncStageID = "account_history_index"
+	SyncStageStorageHistoryIndex SyncStageID = "storage_history_index"
+	SyncStageHistoryFiles        SyncStageID = "history_files"
+	SyncStageLogIndex            SyncStageID = "log_index"
+	SyncStageLogArchive          SyncStageID = "log_archive"
+	SyncStageCallTraces          SyncStageID = "call_traces"
+	SyncStageTxLookup            SyncStageID = "tx_lookup"
+	SyncStageFeeSeries           SyncStageID = "fee_series"
+	SyncStageDataGasStats        SyncStageID = "data_gas_stats"
+	SyncStageTxPool              SyncStageID = "tx_pool"
+	SyncStageFinish              SyncStageID = "finish"
+)
+
+// SyncStageInfo describes a stage of the sync for monitoring tools
+type SyncStageInfo struct {
+	ID    SyncStageID `json:"stage"`
+	Label string      `json:"label"` // for humans, may change
+}
+
+// syncStages maps stages of Erigon to the stable identifiers
+var syncStages = map[stages.SyncStage]SyncStageInfo{
+	stages.Headers:             {SyncStageHeaders, "Download headers"},
+	stages.BlockHashes:         {SyncStageBlockHashes, "Index block hashes"},
+	stages.Bodies:              {SyncStageBodies, "Download bodies"},
+	stages.Senders:             {SyncStageSenders, "Recover senders"},
+	stages.Execution:           {SyncStageExecution, "Execute blocks"},
+	stages.Translation:         {SyncStageTranslation, "Translate contracts to TEVM"},
+	stages.HashState:           {SyncStageHashState, "Hash state"},
+	stages.IntermediateHashes:  {SyncStageIntermediateHashes, "Compute state root"},
+	stages.CrossCheck:          {SyncStageCrossCheck, "Cross-check with the trusted node"},
+	stages.AccountHistoryIndex: {SyncStageAccountHistoryIndex, "Index history of accounts"},
+	stages.StorageHistoryIndex: {SyncStageStorageHistoryIndex, "Index history of storage"},
+	stages.HistoryFiles:        {SyncStageHistoryFiles, "Move history into files"},
+	stages.LogIndex:            {SyncStageLogIndex, "Index logs"},
+	stages.LogArchive:          {SyncStageLogArchive, "Archive logs"},
+	stages.CallTraces:          {SyncStageCallTraces, "Index call traces"},
+	stages.TxLookup:            {SyncStageTxLookup, "Index transactions"},
+	stages.FeeSeries:           {SyncStageFeeSeries, "Aggregate fees"},
+	stages.DataGasStats:        {SyncStageDataGasStats, "Count data gas"},
+	stages.TxPool:              {SyncStageTxPool, "Update transaction pool"},
+	stages.Finish:              {SyncStageFinish, "Finish"},
+}
+
+// syncStageInfo returns the description of the stage, stages missing in syncStages are described by their names
+func syncStageInfo(stage stages.SyncStage) SyncStageInfo {
+	if info, ok := syncStages[stage]; ok {
+		return info
+	}
+	return SyncStageInfo{ID: SyncStageID(strings.ToLower(string(stage))), Label: string(stage)}
+}
+
+// SyncStagesSchema lists stages of the sync in the order of running, as reported by eth_syncing
+func SyncStagesSchema() []SyncStageInfo {
+	schema := make([]SyncStageInfo, len(stages.AllStages))
+	for i, stage := range stages.AllStages {
+		schema[i] = syncStageInfo(stage)
+	}
+	return schema
+}
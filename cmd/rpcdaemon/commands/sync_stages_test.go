@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncStagesSchema(t *testing.T) {
+	ids := map[SyncStageID]struct{}{}
+	for _, stage := range stages.AllStages {
+		info, ok := syncStages[stage]
+		require.True(t, ok, "stage %s has no stable identifier", stage)
+		require.NotEmpty(t, info.Label)
+		_, dup := ids[info.ID]
+		require.False(t, dup, "identifier %s is not unique", info.ID)
+		ids[info.ID] = struct{}{}
+	}
+	require.Len(t, SyncStagesSchema(), len(stages.AllStages))
+}
+
+func TestSyncing(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		if err := stages.SaveStageProgress(tx, stages.Headers, 10); err != nil {
+			return err
+		}
+		if err := stages.SaveStageProgress(tx, stages.Execution, 10); err != nil {
+			return err
+		}
+		return stages.SaveStageProgress(tx, stages.Finish, 5)
+	}))
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+
+	reply, err := api.Syncing(context.Background())
+	require.NoError(t, err)
+	progress := reply.(map[string]interface{})["stages"].([]SyncStageProgress)
+	require.Len(t, progress, len(stages.AllStages))
+	require.Equal(t, SyncStageProgress{SyncStageInfo: syncStages[stages.Headers], BlockNumber: 10, Done: true}, progress[0])
+	for _, p := range progress {
+		switch p.ID {
+		case SyncStageExecution:
+			require.True(t, p.Done)
+		case SyncStageFinish:
+			require.Equal(t, hexutil.Uint64(5), p.BlockNumber)
+			require.False(t, p.Done)
+		}
+	}
+}
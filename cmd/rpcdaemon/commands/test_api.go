@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	types2 "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/services"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/privateapi"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// TestAPIConfig configures NewTestAPI, zero values are fine
+type TestAPIConfig struct {
+	Genesis *core.Genesis       // committed into the database, nil - empty genesis of params.AllEthashProtocolChanges
+	Head    uint64              // progress of all stages, blocks up to it aren't written - seed them into the database
+	Backend services.ApiBackend // nil - TestBackend{}
+	TxPool  txpool.TxpoolClient // nil - TestTxPool of the chain of the genesis
+	Mining  txpool.MiningClient // nil - mining methods fail
+	GasCap  uint64
+}
+
+// NewTestAPI assembles APIImpl over the new in-memory database, for unit tests of projects embedding rpcdaemon: without
+// Erigon, gRPC services and synced chains. The database holds the genesis and the fake progress of stages, the caller
+// seeds it further and closes it.
+func NewTestAPI(cfg TestAPIConfig) (*APIImpl, kv.RwDB, error) {
+	genesis := cfg.Genesis
+	if genesis == nil {
+		genesis = &core.Genesis{Config: params.AllEthashProtocolChanges, GasLimit: params.GenesisGasLimit}
+	}
+	db := memdb.New()
+	chainConfig, _, err := core.CommitGenesisBlock(db, genesis)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	if err = db.Update(context.Background(), func(tx kv.RwTx) error {
+		for _, stage := range stages.AllStages {
+			if err := stages.SaveStageProgress(tx, stage, cfg.Head); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	backend, txPool := cfg.Backend, cfg.TxPool
+	if backend == nil {
+		backend = &TestBackend{}
+	}
+	if txPool == nil {
+		txPool = NewTestTxPool(*types.LatestSignerForChainID(chainConfig.ChainID))
+	}
+	return NewEthAPI(NewBaseApi(nil), db, backend, txPool, cfg.Mining, cfg.GasCap), db, nil
+}
+
+// TestBackend is services.ApiBackend, which replies by its fields
+type TestBackend struct {
+	Coinbase  common.Address
+	NetworkID uint64 // net_version
+	Peers     uint64
+	Version   string // web3_clientVersion
+}
+
+var _ services.ApiBackend = (*TestBackend)(nil)
+
+func (b *TestBackend) Etherbase(context.Context) (common.Address, error) { return b.Coinbase, nil }
+func (b *TestBackend) NetVersion(context.Context) (uint64, error)        { return b.NetworkID, nil }
+func (b *TestBackend) NetPeerCount(context.Context) (uint64, error)      { return b.Peers, nil }
+func (b *TestBackend) ProtocolVersion(context.Context) (uint64, error)   { return 66, nil }
+func (b *TestBackend) EthProtocols(context.Context) ([]uint, error)      { return []uint{66}, nil }
+func (b *TestBackend) ClientVersion(context.Context) (string, error)     { return b.Version, nil }
+
+// Subscribe doesn't send events, the subscription lasts until the context is done
+func (b *TestBackend) Subscribe(ctx context.Context, _ func(*remote.SubscribeReply)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestTxPool is txpool.TxpoolClient, which keeps added transactions in memory. All of them are pending,
+// nothing is validated except signatures, OnAdd is not implemented.
+type TestTxPool struct {
+	signer types.Signer
+
+	mu      sync.Mutex
+	txs     [][]byte // in the order of adding
+	senders []common.Address
+	byHash  map[common.Hash]int // index of txs
+}
+
+var _ txpool.TxpoolClient = (*TestTxPool)(nil)
+
+// NewTestTxPool creates the pool, signer recovers senders of transactions
+func NewTestTxPool(signer types.Signer) *TestTxPool {
+	return &TestTxPool{signer: signer, byHash: map[common.Hash]int{}}
+}
+
+func (p *TestTxPool) Version(context.Context, *emptypb.Empty, ...grpc.CallOption) (*types2.VersionReply, error) {
+	return privateapi.TxPoolAPIVersion, nil
+}
+
+func (p *TestTxPool) FindUnknown(_ context.Context, in *txpool.TxHashes, _ ...grpc.CallOption) (*txpool.TxHashes, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply := &txpool.TxHashes{}
+	for _, h := range in.Hashes {
+		if _, ok := p.byHash[gointerfaces.ConvertH256ToHash(h)]; !ok {
+			reply.Hashes = append(reply.Hashes, h)
+		}
+	}
+	return reply, nil
+}
+
+func (p *TestTxPool) Add(_ context.Context, in *txpool.AddRequest, _ ...grpc.CallOption) (*txpool.AddReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply := &txpool.AddReply{Imported: make([]txpool.ImportResult, len(in.RlpTxs)), Errors: make([]string, len(in.RlpTxs))}
+	for i, rlpTx := range in.RlpTxs {
+		txn, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(rlpTx), uint64(len(rlpTx))))
+		if err != nil {
+			reply.Imported[i], reply.Errors[i] = txpool.ImportResult_INVALID, err.Error()
+			continue
+		}
+		sender, err := txn.Sender(p.signer)
+		if err != nil {
+			reply.Imported[i], reply.Errors[i] = txpool.ImportResult_INVALID, err.Error()
+			continue
+		}
+		if _, ok := p.byHash[txn.Hash()]; ok {
+			reply.Imported[i] = txpool.ImportResult_ALREADY_EXISTS
+			continue
+		}
+		p.byHash[txn.Hash()] = len(p.txs)
+		p.txs = append(p.txs, common.CopyBytes(rlpTx))
+		p.senders = append(p.senders, sender)
+	}
+	return reply, nil
+}
+
+func (p *TestTxPool) Transactions(_ context.Context, in *txpool.TransactionsRequest, _ ...grpc.CallOption) (*txpool.TransactionsReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply := &txpool.TransactionsReply{RlpTxs: make([][]byte, len(in.Hashes))}
+	for i, h := range in.Hashes {
+		if j, ok := p.byHash[gointerfaces.ConvertH256ToHash(h)]; ok {
+			reply.RlpTxs[i] = p.txs[j]
+		}
+	}
+	return reply, nil
+}
+
+func (p *TestTxPool) All(context.Context, *txpool.AllRequest, ...grpc.CallOption) (*txpool.AllReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply := &txpool.AllReply{Txs: make([]*txpool.AllReply_Tx, len(p.txs))}
+	for i := range p.txs {
+		reply.Txs[i] = &txpool.AllReply_Tx{Type: txpool.AllReply_PENDING, Sender: p.senders[i].Bytes(), RlpTx: p.txs[i]}
+	}
+	return reply, nil
+}
+
+func (p *TestTxPool) OnAdd(context.Context, *txpool.OnAddRequest, ...grpc.CallOption) (txpool.Txpool_OnAddClient, error) {
+	return nil, status.Error(codes.Unimplemented, "OnAdd is not implemented by TestTxPool")
+}
+
+func (p *TestTxPool) Status(context.Context, *txpool.StatusRequest, ...grpc.CallOption) (*txpool.StatusReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &txpool.StatusReply{PendingCount: uint32(len(p.txs))}, nil
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestAPI(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	api, db, err := NewTestAPI(TestAPIConfig{
+		Genesis: &core.Genesis{
+			Config:   params.AllEthashProtocolChanges,
+			Alloc:    core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+			GasLimit: params.GenesisGasLimit,
+		},
+		Backend: &TestBackend{NetworkID: 1337},
+	})
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	head, err := api.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), uint64(head))
+	balance, err := api.GetBalance(ctx, addr, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(params.Ether), balance.ToInt())
+	version, err := NewNetAPIImpl(api.ethBackend).Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1337", version)
+
+	// submitted transactions are in the pool
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	txn, err := types.SignTx(types.NewTransaction(0, common.Address{1}, uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei), nil), *signer, key)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, txn.MarshalBinary(&buf))
+	hash, err := api.SendRawTransaction(ctx, buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash(), hash)
+	pending, err := api.GetTransactionByHash(ctx, hash)
+	require.NoError(t, err)
+	require.Equal(t, addr, pending.From)
+	require.Nil(t, pending.BlockNumber)
+}
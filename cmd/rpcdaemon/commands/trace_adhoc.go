@@ -677,11 +677,12 @@ func (api *TraceAPIImpl) Call(ctx context.Context, args TraceCallParam, traceTyp
 		return nil, err
 	}
 	var stateReader state.StateReader
-	if num, ok := blockNrOrHash.Number(); ok && num == rpc.LatestBlockNumber {
+	if rpchelper.PlainStateAt(*blockNrOrHash, tx) {
 		stateReader = state.NewPlainStateReader(tx)
 	} else {
 		stateReader = state.NewPlainState(tx, blockNumber)
 	}
+	stateReader = api.profiledReader(stateReader)
 	ibs := state.New(stateReader)
 
 	header := rawdb.ReadHeader(tx, hash, blockNumber)
@@ -741,7 +742,7 @@ func (api *TraceAPIImpl) Call(ctx context.Context, args TraceCallParam, traceTyp
 	blockCtx.GasLimit = math.MaxUint64
 	blockCtx.MaxGasLimit = true
 
-	evm := vm.NewEVM(blockCtx, txCtx, ibs, chainConfig, vm.Config{Debug: traceTypeTrace, Tracer: &ot})
+	evm := vm.NewEVM(blockCtx, txCtx, ibs, chainConfig, api.evmLimits.Apply(vm.Config{Debug: traceTypeTrace, Tracer: &ot}))
 
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -875,11 +876,12 @@ func (api *TraceAPIImpl) doCallMany(ctx context.Context, dbtx kv.Tx, msgs []type
 		return nil, err
 	}
 	var stateReader state.StateReader
-	if num, ok := parentNrOrHash.Number(); ok && num == rpc.LatestBlockNumber {
+	if rpchelper.PlainStateAt(*parentNrOrHash, dbtx) {
 		stateReader = state.NewPlainStateReader(dbtx)
 	} else {
 		stateReader = state.NewPlainState(dbtx, blockNumber)
 	}
+	stateReader = api.profiledReader(stateReader)
 	stateCache := shards.NewStateCache(32, 0 /* no limit */)
 	cachedReader := state.NewCachedReader(stateReader, stateCache)
 	noop := state.NewNoopWriter()
@@ -923,7 +925,7 @@ func (api *TraceAPIImpl) doCallMany(ctx context.Context, dbtx kv.Tx, msgs []type
 				return nil, fmt.Errorf("unrecognized trace type: %s", traceType)
 			}
 		}
-		vmConfig := vm.Config{}
+		vmConfig := api.evmLimits.Apply(vm.Config{})
 		if traceTypeTrace && (txIndexNeeded == -1 || txIndex == txIndexNeeded) {
 			var ot OeTracer
 			ot.compat = api.compatibility
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+)
+
+const (
+	// traceCacheEntries is the max amount of traces kept in the cache
+	traceCacheEntries = 1024
+	// traceCacheMaxBytes is the total size of traces kept in the cache
+	traceCacheMaxBytes = 256 * 1024 * 1024
+	// traceCacheMaxEntryBytes - bigger traces are not cached, they would evict everything else
+	traceCacheMaxEntryBytes = 32 * 1024 * 1024
+)
+
+var (
+	traceCacheHit  = metrics.GetOrCreateCounter(`rpc_trace_cache_hit`)
+	traceCacheMiss = metrics.GetOrCreateCounter(`rpc_trace_cache_miss`)
+)
+
+// traceCacheKey identifies output of debug_traceTransaction. Block hash is part of the key because after reorg
+// the same transaction may be included into a different block and produce a different trace.
+type traceCacheKey struct {
+	txHash     common.Hash
+	blockHash  common.Hash
+	tracer     string
+	configHash common.Hash
+}
+
+// newTraceCacheKey returns false if the output of given config must not be cached: custom JavaScript tracers
+// are not cached, because their code is arbitrary and may be changed by the user between calls
+func newTraceCacheKey(txHash, blockHash common.Hash, config *tracers.TraceConfig) (traceCacheKey, bool) {
+	key := traceCacheKey{txHash: txHash, blockHash: blockHash}
+	if config == nil {
+		return key, true
+	}
+	if config.Tracer != nil {
+		if !tracers.IsBuiltin(*config.Tracer) {
+			return key, false
+		}
+		key.tracer = *config.Tracer
+	}
+	// Timeout does not affect output of successful traces, only successful traces are cached
+	enc, err := json.Marshal(struct {
+		LogConfig interface{}
+		NoRefunds *bool
+	}{config.LogConfig, config.NoRefunds})
+	if err != nil {
+		return key, false
+	}
+	key.configHash = sha256.Sum256(enc)
+	return key, true
+}
+
+// traceCache keeps serialized outputs of recently traced transactions,
+// debugging sessions tend to trace the same transaction again and again
+type traceCache struct {
+	mu    sync.Mutex
+	cache *lru.Cache // traceCacheKey -> []byte
+	size  int
+}
+
+func newTraceCache() *traceCache {
+	c := &traceCache{}
+	c.cache, _ = lru.NewWithEvict(traceCacheEntries, func(_, v interface{}) {
+		c.size -= len(v.([]byte))
+	})
+	return c
+}
+
+func (c *traceCache) get(key traceCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.cache.Get(key)
+	if !ok {
+		traceCacheMiss.Inc()
+		return nil, false
+	}
+	traceCacheHit.Inc()
+	return v.([]byte), true
+}
+
+func (c *traceCache) add(key traceCacheKey, trace []byte) {
+	if len(trace) > traceCacheMaxEntryBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Remove(key)
+	c.cache.Add(key, trace)
+	c.size += len(trace)
+	for c.size > traceCacheMaxBytes {
+		c.cache.RemoveOldest()
+	}
+}
+
+// traceCapture passes everything written to the RPC response stream and keeps a copy of it
+// until it grows over traceCacheMaxEntryBytes
+type traceCapture struct {
+	out      io.Writer
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *traceCapture) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.buf.Len()+len(p) > traceCacheMaxEntryBytes {
+			w.overflow = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return w.out.Write(p)
+}
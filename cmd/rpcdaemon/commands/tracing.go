@@ -37,6 +37,16 @@ func (api *PrivateDebugAPIImpl) TraceTransaction(ctx context.Context, hash commo
 		return fmt.Errorf("transaction %#x not found", hash)
 	}
 
+	cacheKey, cacheable := newTraceCacheKey(hash, blockHash, config)
+	// frames are sent to the client as they are produced, bypassing the cache
+	cacheable = cacheable && !transactions.StreamsFrames(ctx, config)
+	if cacheable {
+		if trace, ok := api.traceCache.get(cacheKey); ok {
+			stream.Write(trace)
+			return nil
+		}
+	}
+
 	chainConfig, err := api.chainConfig(tx)
 	if err != nil {
 		stream.WriteNil()
@@ -59,7 +69,19 @@ func (api *PrivateDebugAPIImpl) TraceTransaction(ctx context.Context, hash commo
 		return err
 	}
 	// Trace the transaction and return
-	return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, stream)
+	if !cacheable {
+		return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, api.evmLimits, stream)
+	}
+	capture := &traceCapture{out: stream}
+	captureStream := jsoniter.NewStream(jsoniter.ConfigDefault, capture, 4096)
+	err = transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, api.evmLimits, captureStream)
+	if flushErr := captureStream.Flush(); err == nil {
+		err = flushErr
+	}
+	if err == nil && !capture.overflow {
+		api.traceCache.add(cacheKey, capture.buf.Bytes())
+	}
+	return err
 }
 
 func (api *PrivateDebugAPIImpl) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *tracers.TraceConfig, stream *jsoniter.Stream) error {
@@ -82,11 +104,12 @@ func (api *PrivateDebugAPIImpl) TraceCall(ctx context.Context, args ethapi.CallA
 		return err
 	}
 	var stateReader state.StateReader
-	if num, ok := blockNrOrHash.Number(); ok && num == rpc.LatestBlockNumber {
+	if rpchelper.PlainStateAt(blockNrOrHash, dbtx) {
 		stateReader = state.NewPlainStateReader(dbtx)
 	} else {
 		stateReader = state.NewPlainState(dbtx, blockNumber)
 	}
+	stateReader = api.profiledReader(stateReader)
 	header := rawdb.ReadHeader(dbtx, hash, blockNumber)
 	if header == nil {
 		stream.WriteNil()
@@ -108,5 +131,5 @@ func (api *PrivateDebugAPIImpl) TraceCall(ctx context.Context, args ethapi.CallA
 	}
 	blockCtx, txCtx := transactions.GetEvmContext(msg, header, blockNrOrHash.RequireCanonical, dbtx)
 	// Trace the transaction and return
-	return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, stream)
+	return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, api.evmLimits, stream)
 }
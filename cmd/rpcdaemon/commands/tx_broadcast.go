@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// txBroadcastQueue - transactions queued for one endpoint above the limit are dropped
+	txBroadcastQueue = 1024
+	// txBroadcastTimeout is the timeout of one attempt to send the transaction to an endpoint
+	txBroadcastTimeout = 10 * time.Second
+	// txBroadcastRetryDelay is the delay before the first retry, doubled by every next one
+	txBroadcastRetryDelay = 500 * time.Millisecond
+)
+
+// TxBroadcaster forwards transactions submitted via eth_sendRawTransaction or eth_sendTransaction of this rpcdaemon
+// to external endpoints (relays, RPC of other nodes) by their eth_sendRawTransaction, in addition to the pool of
+// Erigon. Forwarding happens in the background, failures don't affect results of the calls. Every endpoint has
+// its own queue, so a slow one doesn't delay the others. Results are counted in rpc_broadcast_txs metrics by endpoint.
+type TxBroadcaster struct {
+	endpoints []*broadcastEndpoint
+	retries   int // of failed attempts to reach an endpoint, rejections of the transaction are not retried
+}
+
+type broadcastEndpoint struct {
+	name   string // host of the URL, the path may contain secrets
+	client *rpc.Client
+	queue  chan hexutil.Bytes
+
+	sent, rejected, failed, retried, dropped *metrics.Counter
+}
+
+func NewTxBroadcaster(urls []string, retries int) (*TxBroadcaster, error) {
+	b := &TxBroadcaster{retries: retries}
+	for _, rawurl := range urls {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("broadcast endpoint %s: %w", rawurl, err)
+		}
+		client, err := rpc.DialHTTP(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("broadcast endpoint %s: %w", u.Host, err)
+		}
+		counter := func(result string) *metrics.Counter {
+			return metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_broadcast_txs{endpoint=%q,result=%q}`, u.Host, result))
+		}
+		b.endpoints = append(b.endpoints, &broadcastEndpoint{
+			name:     u.Host,
+			client:   client,
+			queue:    make(chan hexutil.Bytes, txBroadcastQueue),
+			sent:     counter("sent"),
+			rejected: counter("rejected"),
+			failed:   counter("failed"),
+			retried:  counter("retried"),
+			dropped:  counter("dropped"),
+		})
+	}
+	return b, nil
+}
+
+// Broadcast queues the transaction to all endpoints, it doesn't wait for them
+func (b *TxBroadcaster) Broadcast(encodedTx hexutil.Bytes) {
+	for _, e := range b.endpoints {
+		select {
+		case e.queue <- encodedTx:
+		default:
+			e.dropped.Inc()
+		}
+	}
+}
+
+// Run sends queued transactions until ctx is done
+func (b *TxBroadcaster) Run(ctx context.Context) {
+	for _, e := range b.endpoints {
+		go b.run(ctx, e)
+	}
+	<-ctx.Done()
+	for _, e := range b.endpoints {
+		e.client.Close()
+	}
+}
+
+func (b *TxBroadcaster) run(ctx context.Context, e *broadcastEndpoint) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case encodedTx := <-e.queue:
+			b.send(ctx, e, encodedTx)
+		}
+	}
+}
+
+func (b *TxBroadcaster) send(ctx context.Context, e *broadcastEndpoint, encodedTx hexutil.Bytes) {
+	delay := txBroadcastRetryDelay
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, txBroadcastTimeout)
+		var hash common.Hash
+		err := e.client.CallContext(callCtx, &hash, "eth_sendRawTransaction", encodedTx)
+		cancel()
+		var rpcErr rpc.Error
+		switch {
+		case err == nil:
+			e.sent.Inc()
+			return
+		case errors.As(err, &rpcErr):
+			// the endpoint refused the transaction (f.e. it knows it already), it would refuse it again
+			e.rejected.Inc()
+			log.Debug("Broadcast transaction rejected", "endpoint", e.name, "err", err)
+			return
+		case attempt >= b.retries || ctx.Err() != nil:
+			e.failed.Inc()
+			log.Warn("Broadcast of transaction failed", "endpoint", e.name, "attempts", attempt+1, "err", err)
+			return
+		}
+		e.retried.Inc()
+		select {
+		case <-ctx.Done():
+			e.failed.Inc()
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+type relayService struct {
+	mu       sync.Mutex
+	received []hexutil.Bytes
+}
+
+func (s *relayService) SendRawTransaction(encodedTx hexutil.Bytes) (common.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(encodedTx) == "known" {
+		return common.Hash{}, errors.New("already known")
+	}
+	s.received = append(s.received, encodedTx)
+	return common.Hash{1}, nil
+}
+
+func (s *relayService) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+func TestTxBroadcaster(t *testing.T) {
+	relay := &relayService{}
+	server := rpc.NewServer(0)
+	require.NoError(t, server.RegisterName("eth", relay))
+	defer server.Stop()
+	var unavailable sync.Once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed := false
+		unavailable.Do(func() { failed = true })
+		if failed { // the first attempt fails, the retry succeeds
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	b, err := NewTxBroadcaster([]string{ts.URL}, 2)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+	e := b.endpoints[0]
+	sent, rejected, retried := e.sent.Get(), e.rejected.Get(), e.retried.Get()
+
+	b.Broadcast(hexutil.Bytes("tx1"))
+	b.Broadcast(hexutil.Bytes("known"))
+	require.Eventually(t, func() bool { return e.sent.Get() == sent+1 && e.rejected.Get() == rejected+1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, retried+1, e.retried.Get())
+	require.Equal(t, 1, relay.count())
+	require.Equal(t, hexutil.Bytes("tx1"), relay.received[0])
+}
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	types2 "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/filters"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/debug"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// maxMonitoredTxs - transactions submitted above the limit are not monitored
+	maxMonitoredTxs = 10_000
+	// txMonitorRetentionBlocks is for how many blocks transactions are kept in the monitor after they are mined
+	// or dropped from the pool, so erigon_localTransactions shows what happened to them
+	txMonitorRetentionBlocks = 128
+	// txMonitorHeadersBuffer is the amount of new headers queued while the monitor checks the pool
+	txMonitorHeadersBuffer = 128
+)
+
+// Statuses of local transactions
+const (
+	LocalTxPending  = "pending"  // waits in the pool
+	LocalTxMined    = "mined"    // included into a canonical block
+	LocalTxReplaced = "replaced" // dropped from the pool, another transaction of the sender with the same nonce is mined
+	LocalTxEvicted  = "evicted"  // dropped from the pool and not mined
+)
+
+var (
+	localTxsSubmitted = metrics.GetOrCreateCounter(`rpc_local_txs_submitted`)
+	localTxsMined     = metrics.GetOrCreateCounter(`rpc_local_txs_mined`)
+	localTxsReorged   = metrics.GetOrCreateCounter(`rpc_local_txs_reorged`)
+	localTxsReplaced  = metrics.GetOrCreateCounter(`rpc_local_txs_replaced`)
+	localTxsEvicted   = metrics.GetOrCreateCounter(`rpc_local_txs_evicted`)
+	localTxsStuck     = metrics.GetOrCreateCounter(`rpc_local_txs_stuck`)
+	localTxsPending   = metrics.GetOrCreateCounter(`rpc_local_txs_pending`)
+)
+
+// LocalTx is the transaction submitted via eth_sendRawTransaction or eth_sendTransaction of this rpcdaemon
+type LocalTx struct {
+	Hash           common.Hash     `json:"hash"`
+	From           common.Address  `json:"from"`
+	Nonce          hexutil.Uint64  `json:"nonce"`
+	Submitted      time.Time       `json:"submitted"`
+	SubmittedBlock hexutil.Uint64  `json:"submittedBlock"` // head at the moment of submission
+	Status         string          `json:"status"`
+	Stuck          bool            `json:"stuck"`               // pending for more blocks than the limit
+	Block          *hexutil.Uint64 `json:"block,omitempty"`     // block, in which the transaction is mined, or in which it was found dropped
+	BlockHash      *common.Hash    `json:"blockHash,omitempty"` // hash of the block, in which the transaction is mined
+}
+
+// TxMonitor watches transactions submitted by clients of this rpcdaemon on every new head: which ones are mined,
+// which are dropped from the pool and which stay pending for too long. The last are usually not propagated to
+// miners or underpriced. Problems are logged and counted in rpc_local_txs_* metrics.
+type TxMonitor struct {
+	db        kv.RoDB
+	txPool    txpool.TxpoolClient
+	maxBlocks uint64 // pending transactions are reported as stuck after so many blocks
+
+	lock sync.Mutex
+	txs  map[common.Hash]*LocalTx
+}
+
+func NewTxMonitor(db kv.RoDB, txPool txpool.TxpoolClient, maxBlocks uint64) *TxMonitor {
+	return &TxMonitor{db: db, txPool: txPool, maxBlocks: maxBlocks, txs: map[common.Hash]*LocalTx{}}
+}
+
+// Track starts monitoring of the transaction just added to the pool
+func (m *TxMonitor) Track(txn types.Transaction, from common.Address, head uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	hash := txn.Hash()
+	if _, ok := m.txs[hash]; ok {
+		return
+	}
+	if len(m.txs) >= maxMonitoredTxs {
+		log.Debug("Too many local transactions, not monitoring", "hash", hash)
+		return
+	}
+	m.txs[hash] = &LocalTx{
+		Hash:           hash,
+		From:           from,
+		Nonce:          hexutil.Uint64(txn.GetNonce()),
+		Submitted:      time.Now().UTC(),
+		SubmittedBlock: hexutil.Uint64(head),
+		Status:         LocalTxPending,
+	}
+	localTxsSubmitted.Inc()
+	localTxsPending.Inc()
+}
+
+// Txs returns all monitored transactions ordered by submission time
+func (m *TxMonitor) Txs() []LocalTx {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := make([]LocalTx, 0, len(m.txs))
+	for _, t := range m.txs {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Submitted.Before(res[j].Submitted) })
+	return res
+}
+
+// Run checks transactions on every new head until the context is cancelled
+func (m *TxMonitor) Run(ctx context.Context, ff *filters.Filters) {
+	defer debug.LogPanic()
+	headers := make(chan *types.Header, txMonitorHeadersBuffer)
+	id := ff.SubscribeNewHeads(headers)
+	defer ff.UnsubscribeHeads(id)
+	for {
+		select {
+		case h := <-headers:
+			if err := m.OnNewHead(ctx, h.Number.Uint64()); err != nil {
+				log.Warn("Could not check local transactions", "block", h.Number, "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// OnNewHead updates statuses of pending transactions, reports stuck ones and forgets old finished ones.
+// Mined transactions, whose blocks are not canonical anymore, are pending again.
+func (m *TxMonitor) OnNewHead(ctx context.Context, head uint64) error {
+	var pending, mined []LocalTx
+	m.lock.Lock()
+	for hash, t := range m.txs {
+		switch {
+		case t.Status == LocalTxPending:
+			pending = append(pending, *t)
+		case uint64(*t.Block)+txMonitorRetentionBlocks < head:
+			delete(m.txs, hash)
+		case t.Status == LocalTxMined:
+			mined = append(mined, *t)
+		}
+	}
+	m.lock.Unlock()
+	if len(mined) > 0 {
+		reorged, err := m.reorged(ctx, mined)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, reorged...)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// check the pool first: otherwise a transaction mined between the two checks would be reported as evicted
+	hashes := make([]*types2.H256, len(pending))
+	for i := range pending {
+		hashes[i] = gointerfaces.ConvertHashToH256(pending[i].Hash)
+	}
+	reply, err := m.txPool.Transactions(ctx, &txpool.TransactionsRequest{Hashes: hashes})
+	if err != nil {
+		return err
+	}
+	tx, err := m.db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range pending {
+		t := &pending[i]
+		inPool := i < len(reply.RlpTxs) && len(reply.RlpTxs[i]) > 0
+		status, block, blockHash, err := localTxStatus(tx, t, inPool)
+		if err != nil {
+			return err
+		}
+		if status == LocalTxPending {
+			if head >= uint64(t.SubmittedBlock)+m.maxBlocks && !t.Stuck {
+				t.Stuck = true
+				localTxsStuck.Inc()
+				log.Warn("Local transaction is not mined", "hash", t.Hash, "from", t.From, "nonce", uint64(t.Nonce), "blocks", head-uint64(t.SubmittedBlock), "submitted", t.Submitted)
+			}
+		} else {
+			if block == 0 {
+				block = head
+			}
+			localTxsPending.Dec()
+			switch status {
+			case LocalTxMined:
+				localTxsMined.Inc()
+				log.Debug("Local transaction is mined", "hash", t.Hash, "block", block, "blocks", block-uint64(t.SubmittedBlock))
+			case LocalTxReplaced:
+				localTxsReplaced.Inc()
+				log.Warn("Local transaction is replaced by another one with the same nonce", "hash", t.Hash, "from", t.From, "nonce", uint64(t.Nonce))
+			case LocalTxEvicted:
+				localTxsEvicted.Inc()
+				log.Warn("Local transaction is evicted from the pool", "hash", t.Hash, "from", t.From, "nonce", uint64(t.Nonce), "blocks", head-uint64(t.SubmittedBlock))
+			}
+			b := hexutil.Uint64(block)
+			t.Status, t.Block = status, &b
+			if status == LocalTxMined {
+				t.BlockHash = &blockHash
+			}
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i := range pending {
+		if t, ok := m.txs[pending[i].Hash]; ok {
+			*t = pending[i]
+		}
+	}
+	return nil
+}
+
+// reorged returns the mined transactions, whose blocks are not canonical anymore, as pending ones
+func (m *TxMonitor) reorged(ctx context.Context, mined []LocalTx) ([]LocalTx, error) {
+	tx, err := m.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var reorged []LocalTx
+	for _, t := range mined {
+		canonical, err := rawdb.ReadCanonicalHash(tx, uint64(*t.Block))
+		if err != nil {
+			return nil, err
+		}
+		if canonical == *t.BlockHash {
+			continue
+		}
+		localTxsReorged.Inc()
+		localTxsPending.Inc()
+		log.Warn("Local transaction is not in the canonical chain anymore", "hash", t.Hash, "block", uint64(*t.Block), "blockHash", *t.BlockHash)
+		t.Status, t.Block, t.BlockHash = LocalTxPending, nil, nil
+		reorged = append(reorged, t)
+	}
+	return reorged, nil
+}
+
+// localTxStatus returns status of the transaction, which was pending, and the block, in which it's mined.
+// The transaction is mined only if it's in the canonical block, the lookup may still point to a reorged one.
+func localTxStatus(tx kv.Tx, t *LocalTx, inPool bool) (string, uint64, common.Hash, error) {
+	txn, blockHash, block, _, err := rawdb.ReadTransaction(tx, t.Hash)
+	if err != nil {
+		return "", 0, common.Hash{}, err
+	}
+	if txn != nil {
+		return LocalTxMined, block, blockHash, nil
+	}
+	if inPool {
+		return LocalTxPending, 0, common.Hash{}, nil
+	}
+	acc, err := state.NewPlainStateReader(tx).ReadAccountData(t.From)
+	if err != nil {
+		return "", 0, common.Hash{}, err
+	}
+	if acc != nil && acc.Nonce > uint64(t.Nonce) {
+		return LocalTxReplaced, 0, common.Hash{}, nil
+	}
+	return LocalTxEvicted, 0, common.Hash{}, nil
+}
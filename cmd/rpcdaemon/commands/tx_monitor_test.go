@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testTxPool has the transactions with given hashes
+type testTxPool struct {
+	txpool.TxpoolClient
+	pooled map[common.Hash]struct{}
+	all    []*txpool.AllReply_Tx
+}
+
+func (p *testTxPool) All(context.Context, *txpool.AllRequest, ...grpc.CallOption) (*txpool.AllReply, error) {
+	return &txpool.AllReply{Txs: p.all}, nil
+}
+
+func (p *testTxPool) Transactions(_ context.Context, in *txpool.TransactionsRequest, _ ...grpc.CallOption) (*txpool.TransactionsReply, error) {
+	reply := &txpool.TransactionsReply{RlpTxs: make([][]byte, len(in.Hashes))}
+	for i, h := range in.Hashes {
+		if _, ok := p.pooled[gointerfaces.ConvertH256ToHash(h)]; ok {
+			reply.RlpTxs[i] = []byte{1}
+		}
+	}
+	return reply, nil
+}
+
+func TestTxMonitor(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	block, err := rawdb.ReadBlockByNumber(tx, 1)
+	tx.Rollback()
+	require.NoError(t, err)
+	mined := block.Transactions()[0]
+
+	sender := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7") // has sent transactions in the test chain
+	pending := types.NewTransaction(100, common.Address{1}, uint256.NewInt(1), 21000, uint256.NewInt(1), nil)
+	replaced := types.NewTransaction(0, common.Address{1}, uint256.NewInt(2), 21000, uint256.NewInt(1), nil)
+	evicted := types.NewTransaction(0, common.Address{1}, uint256.NewInt(3), 21000, uint256.NewInt(1), nil)
+
+	pool := &testTxPool{pooled: map[common.Hash]struct{}{pending.Hash(): {}}}
+	m := NewTxMonitor(db, pool, 10)
+	m.Track(mined, sender, 0)
+	m.Track(pending, sender, 0)
+	m.Track(replaced, sender, 0)
+	m.Track(evicted, common.Address{9}, 0)
+
+	require.NoError(t, m.OnNewHead(ctx, 5))
+	statuses := map[common.Hash]LocalTx{}
+	for _, lt := range m.Txs() {
+		statuses[lt.Hash] = lt
+	}
+	require.Len(t, statuses, 4)
+	require.Equal(t, LocalTxMined, statuses[mined.Hash()].Status)
+	require.Equal(t, uint64(1), uint64(*statuses[mined.Hash()].Block))
+	require.Equal(t, block.Hash(), *statuses[mined.Hash()].BlockHash)
+	require.Equal(t, LocalTxPending, statuses[pending.Hash()].Status)
+	require.False(t, statuses[pending.Hash()].Stuck)
+	require.Equal(t, LocalTxReplaced, statuses[replaced.Hash()].Status)
+	require.Equal(t, LocalTxEvicted, statuses[evicted.Hash()].Status)
+	require.Equal(t, uint64(5), uint64(*statuses[evicted.Hash()].Block))
+
+	// stuck after 10 blocks
+	require.NoError(t, m.OnNewHead(ctx, 10))
+	for _, lt := range m.Txs() {
+		if lt.Hash == pending.Hash() {
+			require.True(t, lt.Stuck)
+		}
+	}
+
+	// the block of the mined transaction is reorged out, the transaction is back in the pool
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		return rawdb.WriteCanonicalHash(tx, common.Hash{1}, 1)
+	}))
+	pool.pooled[mined.Hash()] = struct{}{}
+	require.NoError(t, m.OnNewHead(ctx, 11))
+	for _, lt := range m.Txs() {
+		if lt.Hash == mined.Hash() {
+			require.Equal(t, LocalTxPending, lt.Status)
+			require.Nil(t, lt.Block)
+			require.Nil(t, lt.BlockHash)
+		}
+	}
+	// and mined again
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		return rawdb.WriteCanonicalHash(tx, block.Hash(), 1)
+	}))
+	require.NoError(t, m.OnNewHead(ctx, 12))
+	for _, lt := range m.Txs() {
+		if lt.Hash == mined.Hash() {
+			require.Equal(t, LocalTxMined, lt.Status)
+			require.Equal(t, block.Hash(), *lt.BlockHash)
+		}
+	}
+
+	// finished transactions are forgotten after the retention period
+	require.NoError(t, m.OnNewHead(ctx, 5+txMonitorRetentionBlocks+1))
+	txs := m.Txs()
+	require.Len(t, txs, 1)
+	require.Equal(t, pending.Hash(), txs[0].Hash)
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+// txRejectedErrorCode is the code of errors of transactions refused by the pool, the same as of other nodes
+const txRejectedErrorCode = -32000
+
+// Categories of transactions refused by the pool, in the data of the error of eth_sendRawTransaction
+const (
+	TxRejectedFeeTooLow         = "fee_too_low"        // underpriced, replacement underpriced or fee cap below the base fee
+	TxRejectedNonceTooLow       = "nonce_too_low"      // nonce is already used by the sender
+	TxRejectedNonceGap          = "nonce_gap"          // nonce is too far above the nonce of the sender
+	TxRejectedInsufficientFunds = "insufficient_funds" // balance below gas * fee cap + value
+	TxRejectedPoolFull          = "pool_full"          // the pool is full of transactions paying more
+	TxRejectedAlreadyKnown      = "already_known"      // the pool has the transaction already
+	TxRejectedInvalid           = "invalid"            // the transaction can never be included (sender, gas, size)
+	TxRejectedInternal          = "internal"           // the pool failed, the transaction may be accepted later
+)
+
+// txRejections maps errors of the pool, which come over gRPC as strings, to categories. Prefixes are matched, as some
+// errors are wrapped with details.
+var txRejections = []struct {
+	err      error
+	category string
+}{
+	{core.ErrAlreadyKnown, TxRejectedAlreadyKnown},
+	{core.ErrReplaceUnderpriced, TxRejectedFeeTooLow},
+	{core.ErrUnderpriced, TxRejectedFeeTooLow},
+	{core.ErrFeeCapTooLow, TxRejectedFeeTooLow},
+	{core.ErrTxPoolOverflow, TxRejectedPoolFull},
+	{core.ErrNonceTooLow, TxRejectedNonceTooLow},
+	{core.ErrNonceTooHigh, TxRejectedNonceGap},
+	{core.ErrInsufficientFunds, TxRejectedInsufficientFunds},
+	{core.ErrInvalidSender, TxRejectedInvalid},
+	{core.ErrGasLimit, TxRejectedInvalid},
+	{core.ErrIntrinsicGas, TxRejectedInvalid},
+	{core.ErrNegativeValue, TxRejectedInvalid},
+	{core.ErrOversizedData, TxRejectedInvalid},
+	{core.ErrTipAboveFeeCap, TxRejectedInvalid},
+	{core.ErrTxTypeNotSupported, TxRejectedInvalid},
+}
+
+// txRejectionCategory returns the category of the error of the pool
+func txRejectionCategory(result txpool.ImportResult, poolErr string) string {
+	for _, r := range txRejections {
+		if strings.HasPrefix(poolErr, r.err.Error()) {
+			return r.category
+		}
+	}
+	switch result {
+	case txpool.ImportResult_ALREADY_EXISTS:
+		return TxRejectedAlreadyKnown
+	case txpool.ImportResult_FEE_TOO_LOW:
+		return TxRejectedFeeTooLow
+	case txpool.ImportResult_INVALID:
+		return TxRejectedInvalid
+	}
+	return TxRejectedInternal
+}
+
+// TxThresholds are the limits, against which the transaction was checked, in the latest state
+type TxThresholds struct {
+	BaseFee *hexutil.Big    `json:"baseFee,omitempty"` // of the next block, fee caps below it are too low
+	Nonce   *hexutil.Uint64 `json:"nonce,omitempty"`   // the next nonce of the sender
+	Balance *hexutil.Big    `json:"balance,omitempty"` // of the sender
+	Cost    *hexutil.Big    `json:"cost"`              // of the transaction: gas * fee cap + value, must not exceed the balance
+}
+
+// TxRejectedError is returned by eth_sendRawTransaction when the pool refuses the transaction. The message is the
+// same as without it, the data tells the category of the refusal and the current thresholds, so that wallets can
+// react without parsing messages.
+type TxRejectedError struct {
+	Result     string        `json:"result"` // txpool.ImportResult
+	Reason     string        `json:"reason"` // one of TxRejected... constants
+	Message    string        `json:"message"`
+	Thresholds *TxThresholds `json:"thresholds,omitempty"` // missing if the state can't be read
+}
+
+func (e *TxRejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Result, e.Message)
+}
+
+func (e *TxRejectedError) ErrorCode() int { return txRejectedErrorCode }
+
+func (e *TxRejectedError) ErrorData() interface{} { return e }
+
+// txRejectedError describes the transaction refused by the pool
+func (api *APIImpl) txRejectedError(ctx context.Context, txn types.Transaction, result txpool.ImportResult, poolErr string) *TxRejectedError {
+	e := &TxRejectedError{
+		Result:  txpool.ImportResult_name[int32(result)],
+		Reason:  txRejectionCategory(result, poolErr),
+		Message: poolErr,
+	}
+	thresholds, err := api.txThresholds(ctx, txn)
+	if err == nil {
+		e.Thresholds = thresholds
+	}
+	return e
+}
+
+// txThresholds reads the thresholds of the transaction from the latest state
+func (api *APIImpl) txThresholds(ctx context.Context, txn types.Transaction) (*TxThresholds, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	parentNum, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	parent := rawdb.ReadHeaderByNumber(tx, parentNum)
+	if parent == nil {
+		return nil, fmt.Errorf("header %d not found", parentNum)
+	}
+	header := buildBlockHeader(chainConfig, parent, BuildBlockArgs{Coinbase: parent.Coinbase})
+	thresholds := &TxThresholds{Cost: (*hexutil.Big)(txn.Cost().ToBig())}
+	if header.BaseFee != nil {
+		thresholds.BaseFee = (*hexutil.Big)(header.BaseFee)
+	}
+	from, err := txn.Sender(*types.MakeSigner(chainConfig, header.Number.Uint64()))
+	if err != nil {
+		return thresholds, nil // the sender is invalid, there is no state of it
+	}
+	ibs := state.New(state.NewPlainStateReader(tx))
+	nonce := hexutil.Uint64(ibs.GetNonce(from))
+	thresholds.Nonce = &nonce
+	thresholds.Balance = (*hexutil.Big)(ibs.GetBalance(from).ToBig())
+	return thresholds, nil
+}
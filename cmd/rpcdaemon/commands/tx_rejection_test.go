@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxRejectionCategory(t *testing.T) {
+	for _, tc := range []struct {
+		result   txpool.ImportResult
+		err      string
+		category string
+	}{
+		{txpool.ImportResult_FEE_TOO_LOW, "transaction underpriced", TxRejectedFeeTooLow},
+		{txpool.ImportResult_FEE_TOO_LOW, "replacement transaction underpriced", TxRejectedFeeTooLow},
+		{txpool.ImportResult_INVALID, "fee cap less than block base fee: address 0x01, gasFeeCap: 1 baseFee: 7", TxRejectedFeeTooLow},
+		{txpool.ImportResult_INVALID, "txpool is full", TxRejectedPoolFull},
+		{txpool.ImportResult_INVALID, "nonce too low", TxRejectedNonceTooLow},
+		{txpool.ImportResult_INVALID, "nonce too high", TxRejectedNonceGap},
+		{txpool.ImportResult_INVALID, "insufficient funds for gas * price + value: address 0x01 have 0 want 1", TxRejectedInsufficientFunds},
+		{txpool.ImportResult_ALREADY_EXISTS, "already known", TxRejectedAlreadyKnown},
+		{txpool.ImportResult_INVALID, "invalid sender", TxRejectedInvalid},
+		// unknown messages fall back to the result
+		{txpool.ImportResult_FEE_TOO_LOW, "fee too low", TxRejectedFeeTooLow},
+		{txpool.ImportResult_INVALID, "bad", TxRejectedInvalid},
+		{txpool.ImportResult_INTERNAL_ERROR, "db closed", TxRejectedInternal},
+	} {
+		require.Equal(t, tc.category, txRejectionCategory(tc.result, tc.err), tc.err)
+	}
+}
+
+func TestTxRejectedError(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	acc, err := state.NewPlainStateReader(tx).ReadAccountData(sender)
+	tx.Rollback()
+	require.NoError(t, err)
+
+	api := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	signer := types.LatestSignerForChainID(params.AllEthashProtocolChanges.ChainID)
+	txn, err := types.SignTx(types.NewTransaction(acc.Nonce+5, common.Address{1}, uint256.NewInt(1), params.TxGas, uint256.NewInt(params.GWei), nil), *signer, key)
+	require.NoError(t, err)
+
+	e := api.txRejectedError(ctx, txn, txpool.ImportResult_INVALID, "nonce too high")
+	require.Equal(t, "INVALID: nonce too high", e.Error())
+	require.Equal(t, txRejectedErrorCode, e.ErrorCode())
+	require.Equal(t, TxRejectedNonceGap, e.Reason)
+	require.NotNil(t, e.Thresholds)
+	require.Equal(t, acc.Nonce, uint64(*e.Thresholds.Nonce))
+	require.Equal(t, acc.Balance.ToBig(), e.Thresholds.Balance.ToInt())
+	require.Equal(t, txn.Cost().ToBig(), e.Thresholds.Cost.ToInt())
+}
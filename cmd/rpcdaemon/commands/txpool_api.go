@@ -14,19 +14,21 @@ import (
 	"github.com/ledgerwatch/erigon/rlp"
 )
 
-// NetAPI the interface for the net_ RPC commands
+// TxPoolAPI the interface for the txpool_ RPC commands
 type TxPoolAPI interface {
 	Content(ctx context.Context) (map[string]map[string]map[string]*RPCTransaction, error)
+	Status(ctx context.Context) (map[string]hexutil.Uint, error)
+	Inspect(ctx context.Context) (map[string]map[string]map[string]string, error)
 }
 
-// TxPoolAPIImpl data structure to store things needed for net_ commands
+// TxPoolAPIImpl data structure to store things needed for txpool_ commands
 type TxPoolAPIImpl struct {
 	*BaseAPI
 	pool proto_txpool.TxpoolClient
 	db   kv.RoDB
 }
 
-// NewTxPoolAPI returns NetAPIImplImpl instance
+// NewTxPoolAPI returns TxPoolAPIImpl instance
 func NewTxPoolAPI(base *BaseAPI, db kv.RoDB, pool proto_txpool.TxpoolClient) *TxPoolAPIImpl {
 	return &TxPoolAPIImpl{
 		BaseAPI: base,
@@ -35,24 +37,20 @@ func NewTxPoolAPI(base *BaseAPI, db kv.RoDB, pool proto_txpool.TxpoolClient) *Tx
 	}
 }
 
-func (api *TxPoolAPIImpl) Content(ctx context.Context) (map[string]map[string]map[string]*RPCTransaction, error) {
+// all returns pending and queued transactions of the remote pool by sender
+func (api *TxPoolAPIImpl) all(ctx context.Context) (pending, queued map[common.Address][]types.Transaction, err error) {
 	reply, err := api.pool.All(ctx, &proto_txpool.AllRequest{})
 	if err != nil {
-		return nil, err
-	}
-
-	content := map[string]map[string]map[string]*RPCTransaction{
-		"pending": make(map[string]map[string]*RPCTransaction),
-		"queued":  make(map[string]map[string]*RPCTransaction),
+		return nil, nil, err
 	}
 
-	pending := make(map[common.Address][]types.Transaction, 8)
-	queued := make(map[common.Address][]types.Transaction, 8)
+	pending = make(map[common.Address][]types.Transaction, 8)
+	queued = make(map[common.Address][]types.Transaction, 8)
 	for i := range reply.Txs {
 		stream := rlp.NewStream(bytes.NewReader(reply.Txs[i].RlpTx), 0)
 		txn, err := types.DecodeTransaction(stream)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		addr := common.BytesToAddress(reply.Txs[i].Sender)
 		switch reply.Txs[i].Type {
@@ -68,6 +66,19 @@ func (api *TxPoolAPIImpl) Content(ctx context.Context) (map[string]map[string]ma
 			queued[addr] = append(queued[addr], txn)
 		}
 	}
+	return pending, queued, nil
+}
+
+func (api *TxPoolAPIImpl) Content(ctx context.Context) (map[string]map[string]map[string]*RPCTransaction, error) {
+	pending, queued, err := api.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	content := map[string]map[string]map[string]*RPCTransaction{
+		"pending": make(map[string]map[string]*RPCTransaction),
+		"queued":  make(map[string]map[string]*RPCTransaction),
+	}
 
 	tx, err := api.db.BeginRo(ctx)
 	if err != nil {
@@ -111,40 +122,40 @@ func (api *TxPoolAPIImpl) Status(ctx context.Context) (map[string]hexutil.Uint,
 	}, nil
 }
 
-/*
-
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
-func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
+func (api *TxPoolAPIImpl) Inspect(ctx context.Context) (map[string]map[string]map[string]string, error) {
+	pending, queued, err := api.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	content := map[string]map[string]map[string]string{
 		"pending": make(map[string]map[string]string),
 		"queued":  make(map[string]map[string]string),
 	}
-	pending, queue := s.b.TxPoolContent()
-
-	// Define a formatter to flatten a transaction into a string
-	var format = func(tx *types.Transaction) string {
-		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+	// Define a formatter to flatten a transaction into a string, the price of gas is the fee cap of EIP-1559 transactions
+	var format = func(txn types.Transaction) string {
+		if to := txn.GetTo(); to != nil {
+			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", to.Hex(), txn.GetValue(), txn.GetGas(), txn.GetFeeCap())
 		}
-		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", txn.GetValue(), txn.GetGas(), txn.GetFeeCap())
 	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
 		dump := make(map[string]string)
-		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+		for _, txn := range txs {
+			dump[fmt.Sprintf("%d", txn.GetNonce())] = format(txn)
 		}
 		content["pending"][account.Hex()] = dump
 	}
 	// Flatten the queued transactions
-	for account, txs := range queue {
+	for account, txs := range queued {
 		dump := make(map[string]string)
-		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+		for _, txn := range txs {
+			dump[fmt.Sprintf("%d", txn.GetNonce())] = format(txn)
 		}
 		content["queued"][account.Hex()] = dump
 	}
-	return content
+	return content, nil
 }
-*/
@@ -86,4 +86,9 @@ func TestTxPoolContent(t *testing.T) {
 	require.Len(status, 2)
 	require.Equal(status["pending"], hexutil.Uint(1))
 	require.Equal(status["queued"], hexutil.Uint(0))
+
+	inspect, err := api.Inspect(ctx)
+	require.NoError(err)
+	require.Empty(inspect["queued"])
+	require.Equal(map[string]string{"0": "0x0100000000000000000000000000000000000000: 1234 wei + 21000 gas × 1 wei"}, inspect["pending"][sender])
 }
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/rpc"
+)
+
+// WalletAPI is the wallet_* namespace of signing by keys of --wallet.keystore or --wallet.signer. eth_accounts,
+// eth_sign, eth_signTransaction and eth_sendTransaction are served by the same wallet, the namespace allows to serve
+// signing without the rest of eth, e.g. on a separate listener.
+type WalletAPI interface {
+	Accounts(ctx context.Context) ([]common.Address, error)
+	Sign(ctx context.Context, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error)
+	SignTransaction(ctx context.Context, args ethapi.SendTxArgs) (*ethapi.SignTransactionResult, error)
+	SendTransaction(ctx context.Context, args ethapi.SendTxArgs) (common.Hash, error)
+}
+
+// WalletAPIImpl is implementation of the WalletAPI interface, transactions are filled and sent by eth
+type WalletAPIImpl struct {
+	eth    *APIImpl
+	wallet accounts.Wallet
+}
+
+// NewWalletAPI returns WalletAPIImpl instance
+func NewWalletAPI(eth *APIImpl, wallet accounts.Wallet) *WalletAPIImpl {
+	return &WalletAPIImpl{eth: eth, wallet: wallet}
+}
+
+// Accounts implements wallet_accounts. Returns a list of addresses owned by the wallet.
+func (api *WalletAPIImpl) Accounts(ctx context.Context) ([]common.Address, error) {
+	return api.wallet.Accounts(ctx)
+}
+
+// Sign implements wallet_sign, the same as eth_sign of geth. Calculates an Ethereum specific signature with: sign(keccak256('\\x19Ethereum Signed Message:\\n' + len(message) + message))).
+func (api *WalletAPIImpl) Sign(ctx context.Context, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	return api.wallet.SignText(ctx, address, data)
+}
+
+// SignTransaction implements wallet_signTransaction. Signs the transaction without submitting it, missing fields are filled as for wallet_sendTransaction.
+func (api *WalletAPIImpl) SignTransaction(ctx context.Context, args ethapi.SendTxArgs) (*ethapi.SignTransactionResult, error) {
+	txn, err := api.signTransaction(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = txn.MarshalBinary(&buf); err != nil {
+		return nil, err
+	}
+	return &ethapi.SignTransactionResult{Raw: buf.Bytes(), Tx: txn}, nil
+}
+
+// SendTransaction implements wallet_sendTransaction. Creates new message call transaction or a contract creation if the data field contains code.
+func (api *WalletAPIImpl) SendTransaction(ctx context.Context, args ethapi.SendTxArgs) (common.Hash, error) {
+	txn, err := api.signTransaction(ctx, args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var buf bytes.Buffer
+	if err = txn.MarshalBinary(&buf); err != nil {
+		return common.Hash{}, err
+	}
+	return api.eth.SendRawTransaction(ctx, buf.Bytes())
+}
+
+func (api *WalletAPIImpl) signTransaction(ctx context.Context, args ethapi.SendTxArgs) (types.Transaction, error) {
+	tx, err := api.eth.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chainConfig, err := api.eth.chainConfig(tx)
+	tx.Rollback()
+	if err != nil {
+		return nil, err
+	}
+	if err = api.eth.setTxDefaults(ctx, &args, chainConfig.ChainID); err != nil {
+		return nil, err
+	}
+	return api.wallet.SignTx(ctx, args.From, args.ToTransaction(), chainConfig.ChainID)
+}
+
+// setTxDefaults fills in default values for unspecified fields: legacy gas price as eth_gasPrice suggests it,
+// the next nonce of the sender including its transactions in the pool, and estimated gas
+func (api *APIImpl) setTxDefaults(ctx context.Context, args *ethapi.SendTxArgs, chainID *big.Int) error {
+	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
+		return errors.New(`both "data" and "input" are set and not equal. Please use "input" to pass transaction call data`)
+	}
+	input := args.Input
+	if input == nil {
+		input = args.Data
+	}
+	if args.To == nil && (input == nil || len(*input) == 0) {
+		return errors.New(`contract creation without any data provided`)
+	}
+	if args.ChainID == nil {
+		args.ChainID = (*hexutil.Big)(chainID)
+	} else if args.ChainID.ToInt().Cmp(chainID) != 0 {
+		return fmt.Errorf("chainId does not match node's (have=%v, want=%v)", args.ChainID.ToInt(), chainID)
+	}
+	if args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil) {
+		return errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+	if args.MaxPriorityFeePerGas != nil && args.MaxFeePerGas == nil {
+		return errors.New("maxPriorityFeePerGas is specified without maxFeePerGas")
+	}
+	if args.GasPrice == nil && args.MaxFeePerGas == nil {
+		price, err := api.GasPrice(ctx)
+		if err != nil {
+			return err
+		}
+		args.GasPrice = price
+	}
+	if args.Value == nil {
+		args.Value = new(hexutil.Big)
+	}
+	if args.Nonce == nil {
+		nonce, err := api.pendingNonce(ctx, args.From)
+		if err != nil {
+			return err
+		}
+		args.Nonce = (*hexutil.Uint64)(&nonce)
+	}
+	if args.Gas == nil {
+		gas, err := api.EstimateGas(ctx, ethapi.CallArgs{
+			From:                 &args.From,
+			To:                   args.To,
+			GasPrice:             args.GasPrice,
+			MaxFeePerGas:         args.MaxFeePerGas,
+			MaxPriorityFeePerGas: args.MaxPriorityFeePerGas,
+			Value:                args.Value,
+			Data:                 input,
+			AccessList:           args.AccessList,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		args.Gas = &gas
+	}
+	return nil
+}
+
+// pendingNonce returns the nonce after the last transaction of the account, which is mined or waits in the pool
+// without gaps before it
+func (api *APIImpl) pendingNonce(ctx context.Context, address common.Address) (uint64, error) {
+	latest, err := api.GetTransactionCount(ctx, address, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	if err != nil {
+		return 0, err
+	}
+	nonce := uint64(*latest)
+	if api.txPool == nil {
+		return nonce, nil
+	}
+	reply, err := api.txPool.All(ctx, &txpool.AllRequest{})
+	if err != nil {
+		return 0, err
+	}
+	pooled := map[uint64]struct{}{}
+	for _, t := range reply.Txs {
+		if common.BytesToAddress(t.Sender) != address {
+			continue
+		}
+		txn, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(t.RlpTx), 0))
+		if err != nil {
+			return 0, err
+		}
+		pooled[txn.GetNonce()] = struct{}{}
+	}
+	for {
+		if _, ok := pooled[nonce]; !ok {
+			return nonce, nil
+		}
+		nonce++
+	}
+}
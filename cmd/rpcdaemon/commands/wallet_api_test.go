@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/accounts"
+	"github.com/ledgerwatch/erigon/accounts/keystore"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/internal/ethapi"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallet(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	eth := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	_, err := eth.Accounts(ctx)
+	require.EqualError(t, err, "the method eth_accounts is not available, please use --wallet.keystore or --wallet.signer option")
+	_, err = eth.Sign(ctx, common.Address{}, hexutil.Bytes("hello"))
+	require.Error(t, err)
+
+	ks, err := keystore.Open(t.TempDir())
+	require.NoError(t, err)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from, err := ks.StoreKey(key, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	require.NoError(t, ks.Unlock(from, "password"))
+	api := NewWalletAPI(eth, ks)
+	eth.wallet = api
+
+	all, err := api.Accounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{from}, all)
+
+	sig, err := api.Sign(ctx, from, hexutil.Bytes("hello"))
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), sig)
+	require.NoError(t, err)
+	require.Equal(t, from, crypto.PubkeyToAddress(*pub))
+
+	// nonce, gas and gas price are filled in
+	to := common.HexToAddress("0x0d3ab14bbad3d99f4203bd7a11acb94882050e7e")
+	res, err := api.SignTransaction(ctx, ethapi.SendTxArgs{From: from, To: &to, Value: (*hexutil.Big)(common.Big1)})
+	require.NoError(t, err)
+	nonce, err := eth.GetTransactionCount(ctx, from, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	require.Equal(t, uint64(*nonce), res.Tx.GetNonce())
+	require.Equal(t, uint64(21000), res.Tx.GetGas())
+	signed, err := types.UnmarshalTransactionFromBinary(res.Raw)
+	require.NoError(t, err)
+	require.Equal(t, res.Tx.Hash(), signed.Hash())
+	sender, err := signed.Sender(*types.LatestSignerForChainID(eth._chainConfig.ChainID))
+	require.NoError(t, err)
+	require.Equal(t, from, sender)
+
+	gas := hexutil.Uint64(21000)
+	_, err = api.SignTransaction(ctx, ethapi.SendTxArgs{From: common.Address{1}, To: &to, Gas: &gas})
+	require.Equal(t, accounts.ErrUnknownAccount, err)
+}
+
+func TestEthWallet(t *testing.T) {
+	ctx := context.Background()
+	db := rpcdaemontest.CreateTestKV(t)
+	eth := NewEthAPI(NewBaseApi(nil), db, nil, nil, nil, 5000000)
+	ks, err := keystore.Open(t.TempDir())
+	require.NoError(t, err)
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	from, err := ks.StoreKey(key, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	require.NoError(t, ks.Unlock(from, "password"))
+	eth.wallet = NewWalletAPI(eth, ks)
+
+	// eth_* methods are served by the wallet, the same as wallet_* ones
+	all, err := eth.Accounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{from}, all)
+
+	sig, err := eth.Sign(ctx, from, hexutil.Bytes("hello"))
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), sig)
+	require.NoError(t, err)
+	require.Equal(t, from, crypto.PubkeyToAddress(*pub))
+
+	to := common.HexToAddress("0x0d3ab14bbad3d99f4203bd7a11acb94882050e7e")
+	res, err := eth.SignTransaction(ctx, ethapi.SendTxArgs{From: from, To: &to, Value: (*hexutil.Big)(common.Big1)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(21000), res.Tx.GetGas())
+	signed, err := types.UnmarshalTransactionFromBinary(res.Raw)
+	require.NoError(t, err)
+	sender, err := signed.Sender(*types.LatestSignerForChainID(eth._chainConfig.ChainID))
+	require.NoError(t, err)
+	require.Equal(t, from, sender)
+
+	gas := hexutil.Uint64(21000)
+	_, err = eth.SendTransaction(ctx, ethapi.SendTxArgs{From: common.Address{1}, To: &to, Gas: &gas})
+	require.Equal(t, accounts.ErrUnknownAccount, err)
+}
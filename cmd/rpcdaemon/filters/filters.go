@@ -38,6 +38,10 @@ type Filters struct {
 	pendingLogsSubs  map[PendingLogsSubID]chan types.Logs
 	pendingBlockSubs map[PendingBlockSubID]chan *types.Block
 	pendingTxsSubs   map[PendingTxsSubID]chan []types.Transaction
+
+	lagMu     sync.Mutex
+	headLag   uint64
+	heldHeads []*types.Header // headers after the lagged head, in order of numbers
 }
 
 func New(ctx context.Context, ethBackend services.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient) *Filters {
@@ -273,6 +277,36 @@ func (ff *Filters) HandlePendingLogs(reply *txpool.OnPendingLogsReply) {
 	}
 }
 
+// SetHeadLag holds back new heads until the chain is lag blocks longer, so subscribers see the same head as
+// the database lagged by rpc.headlag
+func (ff *Filters) SetHeadLag(lag uint64) {
+	ff.lagMu.Lock()
+	defer ff.lagMu.Unlock()
+	ff.headLag = lag
+}
+
+// lagHeads returns the headers to deliver after the new head h
+func (ff *Filters) lagHeads(h *types.Header) []*types.Header {
+	ff.lagMu.Lock()
+	defer ff.lagMu.Unlock()
+	if ff.headLag == 0 {
+		return []*types.Header{h}
+	}
+	n := h.Number.Uint64()
+	// on reorg the new head replaces held headers of the same and higher numbers
+	i := len(ff.heldHeads)
+	for i > 0 && ff.heldHeads[i-1].Number.Uint64() >= n {
+		i--
+	}
+	ff.heldHeads = append(ff.heldHeads[:i], h)
+	var out []*types.Header
+	for len(ff.heldHeads) > 0 && ff.heldHeads[0].Number.Uint64()+ff.headLag <= n {
+		out = append(out, ff.heldHeads[0])
+		ff.heldHeads = ff.heldHeads[1:]
+	}
+	return out
+}
+
 func (ff *Filters) SubscribeNewHeads(out chan *types.Header) HeadsSubID {
 	ff.mu.Lock()
 	defer ff.mu.Unlock()
@@ -343,8 +377,10 @@ func (ff *Filters) OnNewEvent(event *remote.SubscribeReply) {
 			// ignoring what we can't unmarshal
 			log.Warn("OnNewEvent rpc filters (header), unprocessable payload", "err", err)
 		} else {
-			for _, v := range ff.headsSubs {
-				v <- &header
+			for _, h := range ff.lagHeads(&header) {
+				for _, v := range ff.headsSubs {
+					v <- h
+				}
 			}
 		}
 	//case remote.Event_PENDING_LOGS:
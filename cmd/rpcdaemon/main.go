@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli"
@@ -8,6 +9,8 @@ import (
 	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/filters"
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/common/fdlimit"
+	"github.com/ledgerwatch/erigon/internal/flags"
+	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/log/v3"
 	"github.com/spf13/cobra"
 )
@@ -28,18 +31,29 @@ func main() {
 		var ff *filters.Filters
 		if backend != nil {
 			ff = filters.New(rootCtx, backend, txPool, mining)
+			ff.SetHeadLag(cfg.HeadLag)
 		} else {
 			log.Info("filters are not supported in chaindata mode")
 		}
 
-		if err := cli.StartRpcServer(cmd.Context(), *cfg, commands.APIList(cmd.Context(), db, backend, txPool, mining, ff, *cfg, nil)); err != nil {
+		if cfg.RpcSessionTTL > 0 {
+			cfg.RpcSessions = rpc.NewSessions(cfg.RpcSessionTTL)
+		}
+
+		wallet, err := cli.OpenWallet(cmd.Context(), *cfg)
+		if err != nil {
+			log.Error("Could not open wallet", "error", err)
+			return nil
+		}
+
+		if err := cli.StartRpcServer(cmd.Context(), *cfg, commands.APIList(cmd.Context(), db, backend, txPool, mining, ff, wallet, *cfg, nil)); err != nil {
 			log.Error(err.Error())
 			return nil
 		}
 		return nil
 	}
 
-	if err := cmd.ExecuteContext(rootCtx); err != nil {
+	if err := cmd.ExecuteContext(rootCtx); err != nil && !errors.Is(err, flags.ErrEffectiveConfigPrinted) {
 		log.Error(err.Error())
 		os.Exit(1)
 	}
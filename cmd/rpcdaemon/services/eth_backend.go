@@ -10,8 +10,10 @@ import (
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/ethdb/privateapi"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
 	"github.com/ledgerwatch/log/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -24,6 +26,7 @@ type ApiBackend interface {
 	NetVersion(ctx context.Context) (uint64, error)
 	NetPeerCount(ctx context.Context) (uint64, error)
 	ProtocolVersion(ctx context.Context) (uint64, error)
+	EthProtocols(ctx context.Context) ([]uint, error)
 	ClientVersion(ctx context.Context) (string, error)
 	Subscribe(ctx context.Context, cb func(*remote.SubscribeReply)) error
 }
@@ -32,6 +35,7 @@ type RemoteBackend struct {
 	remoteEthBackend remote.ETHBACKENDClient
 	log              log.Logger
 	version          gointerfaces.Version
+	features         remoteapi.Features // negotiated in EnsureVersionCompatibility
 }
 
 func NewRemoteBackend(cc grpc.ClientConnInterface) *RemoteBackend {
@@ -43,22 +47,31 @@ func NewRemoteBackend(cc grpc.ClientConnInterface) *RemoteBackend {
 }
 
 func (back *RemoteBackend) EnsureVersionCompatibility() bool {
-	versionReply, err := back.remoteEthBackend.Version(context.Background(), &emptypb.Empty{}, grpc.WaitForReady(true))
+	var header metadata.MD
+	ctx := remoteapi.WithFeatures(context.Background(), privateapi.EthBackendFeatures)
+	versionReply, err := back.remoteEthBackend.Version(ctx, &emptypb.Empty{}, grpc.WaitForReady(true), grpc.Header(&header))
 	if err != nil {
 
 		back.log.Error("getting Version", "error", err)
 		return false
 	}
+	features, _ := remoteapi.NegotiateFeatures(privateapi.EthBackendFeatures, header)
 	if !gointerfaces.EnsureVersion(back.version, versionReply) {
 		back.log.Error("incompatible interface versions", "client", back.version.String(),
 			"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch))
 		return false
 	}
+	back.features = features
 	back.log.Info("interfaces compatible", "client", back.version.String(),
-		"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch))
+		"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch), "features", back.features)
 	return true
 }
 
+// Features returns optional features supported by both sides, known only after EnsureVersionCompatibility
+func (back *RemoteBackend) Features() remoteapi.Features {
+	return back.features
+}
+
 func (back *RemoteBackend) Etherbase(ctx context.Context) (common.Address, error) {
 	res, err := back.remoteEthBackend.Etherbase(ctx, &remote.EtherbaseRequest{})
 	if err != nil {
@@ -107,6 +120,23 @@ func (back *RemoteBackend) ProtocolVersion(ctx context.Context) (uint64, error)
 	return res.Id, nil
 }
 
+// EthProtocols returns versions of the eth protocol served by Erigon, highest first.
+// Erigon without privateapi.FeatureEthProtocols tells only the highest one.
+func (back *RemoteBackend) EthProtocols(ctx context.Context) ([]uint, error) {
+	var header metadata.MD
+	res, err := back.remoteEthBackend.ProtocolVersion(ctx, &remote.ProtocolVersionRequest{}, grpc.Header(&header))
+	if err != nil {
+		if s, ok := status.FromError(err); ok {
+			return nil, errors.New(s.Message())
+		}
+		return nil, err
+	}
+	if !back.features.Has(privateapi.FeatureEthProtocols) {
+		return []uint{uint(res.Id)}, nil
+	}
+	return privateapi.ParseEthProtocols(header)
+}
+
 func (back *RemoteBackend) ClientVersion(ctx context.Context) (string, error) {
 	res, err := back.remoteEthBackend.ClientVersion(ctx, &remote.ClientVersionRequest{})
 	if err != nil {
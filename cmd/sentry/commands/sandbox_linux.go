@@ -0,0 +1,111 @@
+//go:build (linux && amd64) || (linux && arm64)
+// +build linux,amd64 linux,arm64
+
+package commands
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Constants missing in golang.org/x/sys/unix, see linux/audit.h and linux/seccomp.h
+const (
+	auditArchX86_64  = 0xc000003e
+	auditArchAARCH64 = 0xc00000b7
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+	seccompRetAllow        = 0x7fff0000
+	seccompRetErrno        = 0x00050000
+	seccompRetKillProcess  = 0x80000000
+
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+
+	x32SyscallBit = 0x40000000
+)
+
+// sandboxDeniedSyscalls are syscalls the sentry never needs: starting other programs, inspecting other processes,
+// changing mounts, namespaces, kernel modules and keyrings. They fail with EPERM once the sandbox is entered.
+var sandboxDeniedSyscalls = []uintptr{
+	unix.SYS_EXECVE,
+	unix.SYS_EXECVEAT,
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_SETNS,
+	unix.SYS_UNSHARE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_KEYCTL,
+	unix.SYS_REBOOT,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_ACCT,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_CLOCK_SETTIME,
+}
+
+// enterSandbox restricts the whole process (all threads) irreversibly: privileges can't be gained via setuid binaries,
+// core dumps (which would contain the node key) are disabled, and sandboxDeniedSyscalls are filtered by seccomp.
+func enterSandbox() error {
+	var arch uint32
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = auditArchX86_64
+	case "arm64":
+		arch = auditArchAARCH64
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return fmt.Errorf("disabling core dumps: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	filter := seccompFilter(arch, sandboxDeniedSyscalls)
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("installing seccomp filter: %w", errno)
+	}
+	return nil
+}
+
+// seccompFilter builds BPF program which kills the process on syscalls of a foreign ABI, returns EPERM for denied
+// syscalls and allows everything else
+func seccompFilter(arch uint32, denied []uintptr) []unix.SockFilter {
+	stmt := func(code uint16, k uint32) unix.SockFilter { return unix.SockFilter{Code: code, K: k} }
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArchOffset),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNrOffset),
+		// x32 syscalls share the x86_64 arch, but have own numbers
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, uint8(len(denied)+1), 0),
+	}
+	for i, nr := range denied {
+		// on match jump to the EPERM return after the list
+		filter = append(filter, jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), uint8(len(denied)-i), 0))
+	}
+	return append(filter,
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow),
+		stmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EPERM)),
+	)
+}
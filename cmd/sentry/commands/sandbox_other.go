@@ -0,0 +1,13 @@
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package commands
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func enterSandbox() error {
+	return fmt.Errorf("sandbox is not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+}
@@ -27,6 +27,9 @@ var (
 	nodiscover   bool // disable sentry's discovery mechanism
 	protocol     string
 	netRestrict  string // CIDR to restrict peering to
+
+	limits  = download.DefaultLimits
+	sandbox bool // restrict the process with seccomp after start
 )
 
 func init() {
@@ -47,6 +50,10 @@ func init() {
 	rootCmd.Flags().StringSliceVar(&discoveryDNS, utils.DNSDiscoveryFlag.Name, []string{}, utils.DNSDiscoveryFlag.Usage)
 	rootCmd.Flags().BoolVar(&nodiscover, utils.NoDiscoverFlag.Name, false, utils.NoDiscoverFlag.Usage)
 	rootCmd.Flags().StringVar(&netRestrict, "netrestrict", "", "CIDR range to accept peers from <CIDR>")
+	rootCmd.Flags().Uint32Var(&limits.MaxMsgSize, "p2p.msg.maxsize", limits.MaxMsgSize, "max size of a message from a peer, peers sending bigger messages are dropped")
+	rootCmd.Flags().DurationVar(&limits.HandshakeTimeout, "p2p.handshake.timeout", limits.HandshakeTimeout, "time for a peer to complete eth handshake")
+	rootCmd.Flags().DurationVar(&limits.IdleTimeout, "p2p.idle.timeout", limits.IdleTimeout, "drop peers which send no eth messages for this long, 0 - never")
+	rootCmd.Flags().BoolVar(&sandbox, "sandbox", false, "after start, forbid executing programs, tracing processes, mounts, namespaces, kernel modules and core dumps (linux amd64/arm64 only)")
 	rootCmd.Flags().StringVar(&datadir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
 	if err := rootCmd.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
 		panic(err)
@@ -83,7 +90,12 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return download.Sentry(datadir, sentryAddr, discoveryDNS, p2pConfig, uint(p))
+		if sandbox {
+			if err = enterSandbox(); err != nil {
+				return err
+			}
+		}
+		return download.Sentry(datadir, sentryAddr, discoveryDNS, p2pConfig, uint(p), limits)
 	},
 }
 
@@ -109,6 +109,26 @@ func RecvUploadMessage(ctx context.Context,
 		}
 		return err
 	}
+	handle := func(ctx context.Context, req *proto_sentry.InboundMessage) {
+		if err := handleInboundMessage(ctx, req, sentry); err != nil {
+			log.Error("RecvUploadMessage: Handling incoming message", "error", err)
+		}
+	}
+	done := func() {
+		if wg != nil {
+			wg.Done()
+		}
+	}
+	// headers are answered right here, bodies and receipts wait for the upload budget in own queues
+	queues := newServingQueues(streamCtx, []proto_sentry.MessageId{
+		eth.ToProto[eth.ETH65][eth.GetBlockBodiesMsg],
+		eth.ToProto[eth.ETH65][eth.GetReceiptsMsg],
+		eth.ToProto[eth.ETH66][eth.GetBlockBodiesMsg],
+		eth.ToProto[eth.ETH66][eth.GetReceiptsMsg],
+	}, handle, done)
+	defer queues.close()
+	defer cancel()
+
 	var req *proto_sentry.InboundMessage
 	for req, err = stream.Recv(); ; req, err = stream.Recv() {
 		if err != nil {
@@ -128,13 +148,11 @@ func RecvUploadMessage(ctx context.Context,
 		if req == nil {
 			return
 		}
-		if err = handleInboundMessage(ctx, req, sentry); err != nil {
-			log.Error("RecvUploadMessage: Handling incoming message", "error", err)
-		}
-		if wg != nil {
-			wg.Done()
+		if queues.push(req, done) {
+			continue
 		}
-
+		handle(ctx, req)
+		done()
 	}
 }
 
@@ -255,9 +273,10 @@ type ControlServerImpl struct {
 	networkId   uint64
 	db          kv.RwDB
 	Engine      consensus.Engine
+	serving     *servingThrottle
 }
 
-func NewControlServer(db kv.RwDB, nodeName string, chainConfig *params.ChainConfig, genesisHash common.Hash, engine consensus.Engine, networkID uint64, sentries []remote.SentryClient, window int) (*ControlServerImpl, error) {
+func NewControlServer(db kv.RwDB, nodeName string, chainConfig *params.ChainConfig, genesisHash common.Hash, engine consensus.Engine, networkID uint64, sentries []remote.SentryClient, window int, servingLimits ServingLimits) (*ControlServerImpl, error) {
 	hd := headerdownload.NewHeaderDownload(
 		512,       /* anchorLimit */
 		1024*1024, /* linkLimit */
@@ -278,6 +297,7 @@ func NewControlServer(db kv.RwDB, nodeName string, chainConfig *params.ChainConf
 		sentries: sentries,
 		db:       db,
 		Engine:   engine,
+		serving:  newServingThrottle(servingLimits),
 	}
 	cs.ChainConfig = chainConfig
 	cs.forks = forkid.GatherForks(cs.ChainConfig)
@@ -436,6 +456,12 @@ func (cs *ControlServerImpl) blockHeaders66(ctx context.Context, in *proto_sentr
 					}
 				}
 				cs.Penalize(ctx, penalties)
+				for _, fillReq := range cs.Hd.RequestSegmentFills(currentTime, 5 /* timeout */) {
+					if cs.SendHeaderRequest(ctx, fillReq) == nil {
+						break
+					}
+					log.Debug("Sent fill request", "height", fillReq.Number)
+				}
 			}
 		} else {
 			outreq := proto_sentry.PenalizePeerRequest{
@@ -511,6 +537,12 @@ func (cs *ControlServerImpl) blockHeaders65(ctx context.Context, in *proto_sentr
 					}
 				}
 				cs.Penalize(ctx, penalties)
+				for _, fillReq := range cs.Hd.RequestSegmentFills(currentTime, 5 /* timeout */) {
+					if cs.SendHeaderRequest(ctx, fillReq) == nil {
+						break
+					}
+					log.Debug("Sent fill request", "height", fillReq.Number)
+				}
 			}
 		} else {
 			outreq := proto_sentry.PenalizePeerRequest{
@@ -700,6 +732,9 @@ func (cs *ControlServerImpl) getBlockBodies66(ctx context.Context, inreq *proto_
 	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
 		return fmt.Errorf("decoding getBlockBodies66: %v, data: %x", err, inreq.Data)
 	}
+	if !cs.serving.allowRequest(inreq.PeerId) {
+		return nil
+	}
 	tx, err := cs.db.BeginRo(ctx)
 	if err != nil {
 		return err
@@ -714,6 +749,9 @@ func (cs *ControlServerImpl) getBlockBodies66(ctx context.Context, inreq *proto_
 	if err != nil {
 		return fmt.Errorf("encode header response: %v", err)
 	}
+	if err = cs.serving.waitUpload(ctx, len(b)); err != nil {
+		return err
+	}
 	outreq := proto_sentry.SendMessageByIdRequest{
 		PeerId: inreq.PeerId,
 		Data: &proto_sentry.OutboundMessageData{
@@ -737,6 +775,9 @@ func (cs *ControlServerImpl) getBlockBodies65(ctx context.Context, inreq *proto_
 	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
 		return fmt.Errorf("decoding getBlockBodies65: %v, data: %x", err, inreq.Data)
 	}
+	if !cs.serving.allowRequest(inreq.PeerId) {
+		return nil
+	}
 	tx, err := cs.db.BeginRo(ctx)
 	if err != nil {
 		return err
@@ -748,6 +789,9 @@ func (cs *ControlServerImpl) getBlockBodies65(ctx context.Context, inreq *proto_
 	if err != nil {
 		return fmt.Errorf("encode header response: %v", err)
 	}
+	if err = cs.serving.waitUpload(ctx, len(b)); err != nil {
+		return err
+	}
 	outreq := proto_sentry.SendMessageByIdRequest{
 		PeerId: inreq.PeerId,
 		Data: &proto_sentry.OutboundMessageData{
@@ -771,6 +815,9 @@ func (cs *ControlServerImpl) getReceipts66(ctx context.Context, inreq *proto_sen
 	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
 		return fmt.Errorf("decoding getReceipts66: %v, data: %x", err, inreq.Data)
 	}
+	if !cs.serving.allowRequest(inreq.PeerId) {
+		return nil
+	}
 	tx, err := cs.db.BeginRo(ctx)
 	if err != nil {
 		return err
@@ -788,6 +835,9 @@ func (cs *ControlServerImpl) getReceipts66(ctx context.Context, inreq *proto_sen
 	if err != nil {
 		return fmt.Errorf("encode header response: %v", err)
 	}
+	if err = cs.serving.waitUpload(ctx, len(b)); err != nil {
+		return err
+	}
 	outreq := proto_sentry.SendMessageByIdRequest{
 		PeerId: inreq.PeerId,
 		Data: &proto_sentry.OutboundMessageData{
@@ -811,6 +861,9 @@ func (cs *ControlServerImpl) getReceipts65(ctx context.Context, inreq *proto_sen
 	if err := rlp.DecodeBytes(inreq.Data, &query); err != nil {
 		return fmt.Errorf("decoding getReceipts65: %v, data: %x", err, inreq.Data)
 	}
+	if !cs.serving.allowRequest(inreq.PeerId) {
+		return nil
+	}
 	tx, err := cs.db.BeginRo(ctx)
 	if err != nil {
 		return err
@@ -825,6 +878,9 @@ func (cs *ControlServerImpl) getReceipts65(ctx context.Context, inreq *proto_sen
 	if err != nil {
 		return fmt.Errorf("encode header response: %v", err)
 	}
+	if err = cs.serving.waitUpload(ctx, len(b)); err != nil {
+		return err
+	}
 	outreq := proto_sentry.SendMessageByIdRequest{
 		PeerId: inreq.PeerId,
 		Data: &proto_sentry.OutboundMessageData{
@@ -0,0 +1,78 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// forkMismatchLogInterval - how often the summary of peers rejected because of fork ID mismatch is printed
+const forkMismatchLogInterval = time.Minute
+
+var (
+	forkMismatchRemoteStale  = metrics.GetOrCreateCounter(`sentry_peers_dropped{reason="fork_id_remote_stale"}`)
+	forkMismatchIncompatible = metrics.GetOrCreateCounter(`sentry_peers_dropped{reason="fork_id_incompatible"}`)
+)
+
+// forkIDMismatchError is returned by the handshake when the fork ID advertised by the peer is rejected by our fork filter
+type forkIDMismatchError struct {
+	ours   forkid.ID
+	theirs forkid.ID
+	reason error
+}
+
+func (e *forkIDMismatchError) Error() string {
+	return fmt.Sprintf("%v: %v, theirs %x/%d, ours %x/%d", eth.ErrForkIDRejected, e.reason, e.theirs.Hash, e.theirs.Next, e.ours.Hash, e.ours.Next)
+}
+
+func (e *forkIDMismatchError) Unwrap() error { return eth.ErrForkIDRejected }
+
+// forkMismatchStats accumulates peers dropped because of fork ID mismatch, grouped by the fork ID they advertised,
+// so it's possible to tell from the logs whether we are on the wrong side of a fork or peers are simply outdated
+type forkMismatchStats struct {
+	lock    sync.Mutex
+	counts  map[forkid.ID]uint64
+	lastLog time.Time
+}
+
+func (s *forkMismatchStats) record(peerID string, peerName string, e *forkIDMismatchError) {
+	if errors.Is(e.reason, forkid.ErrRemoteStale) {
+		forkMismatchRemoteStale.Inc()
+	} else {
+		forkMismatchIncompatible.Inc()
+	}
+	log.Debug(fmt.Sprintf("[%s] Peer dropped due to fork ID mismatch", peerID), "name", peerName, "err", e)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.counts == nil {
+		s.counts = map[forkid.ID]uint64{}
+	}
+	s.counts[e.theirs]++
+	if time.Since(s.lastLog) < forkMismatchLogInterval {
+		return
+	}
+	s.lastLog = time.Now()
+
+	ids := make([]forkid.ID, 0, len(s.counts))
+	for id := range s.counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.counts[ids[i]] > s.counts[ids[j]] })
+	if len(ids) > 5 {
+		ids = ids[:5]
+	}
+	ctx := []interface{}{"ours", fmt.Sprintf("%x/%d", e.ours.Hash, e.ours.Next)}
+	for _, id := range ids {
+		ctx = append(ctx, fmt.Sprintf("%x/%d", id.Hash, id.Next), s.counts[id])
+	}
+	log.Info("Peers dropped due to fork ID mismatch, by advertised fork ID", ctx...)
+	s.counts = map[forkid.ID]uint64{}
+}
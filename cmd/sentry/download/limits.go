@@ -0,0 +1,59 @@
+package download
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/erigon/p2p"
+)
+
+// Limits bound resources a single remote peer can make the sentry (and the core behind it) spend.
+// Messages bigger than the limit of their code are discarded before decoding and the peer is dropped,
+// so decoders downstream never see input bigger than these limits. The transport still reads the whole
+// frame, which is bounded by MaxMsgSize.
+type Limits struct {
+	MaxMsgSize       uint32        // limit of messages without a specific limit in msgSizeLimits
+	HandshakeTimeout time.Duration // time for the peer to complete `eth` handshake
+	IdleTimeout      time.Duration // peer is dropped if it sends no `eth` messages for this long, 0 - never
+}
+
+// DefaultLimits are used by the sentry embedded into Erigon and by the standalone sentry unless overridden
+var DefaultLimits = Limits{
+	MaxMsgSize:       eth.ProtocolMaxMsgSize,
+	HandshakeTimeout: handshakeTimeout,
+}
+
+// msgSizeLimits are limits of messages which are small by construction: requests carrying at most a few
+// thousands of hashes, and announcements. They apply in addition to Limits.MaxMsgSize.
+var msgSizeLimits = map[uint64]uint32{
+	eth.StatusMsg:                     1024,
+	eth.GetBlockHeadersMsg:            1024,
+	eth.GetBlockBodiesMsg:             64 * 1024,
+	eth.GetNodeDataMsg:                64 * 1024,
+	eth.GetReceiptsMsg:                64 * 1024,
+	eth.GetPooledTransactionsMsg:      256 * 1024,
+	eth.NewBlockHashesMsg:             256 * 1024,
+	eth.NewPooledTransactionHashesMsg: 256 * 1024,
+}
+
+// checkSize returns an error if the message exceeds the limit of its code
+func (l Limits) checkSize(msg p2p.Msg) error {
+	limit := l.MaxMsgSize
+	if codeLimit, ok := msgSizeLimits[msg.Code]; ok && codeLimit < limit {
+		limit = codeLimit
+	}
+	if msg.Size > limit {
+		return fmt.Errorf("message 0x%x is too large %d, limit %d", msg.Code, msg.Size, limit)
+	}
+	return nil
+}
+
+// idleWatchdog calls onIdle unless reset within IdleTimeout, the caller resets it after every message
+// and stops it when done with the peer. Returns nil if IdleTimeout is 0.
+func (l Limits) idleWatchdog(onIdle func()) *time.Timer {
+	if l.IdleTimeout == 0 {
+		return nil
+	}
+	return time.AfterFunc(l.IdleTimeout, onIdle)
+}
@@ -0,0 +1,45 @@
+package download
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsCheckSize(t *testing.T) {
+	l := Limits{MaxMsgSize: 100 * 1024}
+	require.NoError(t, l.checkSize(p2p.Msg{Code: eth.BlockBodiesMsg, Size: 100 * 1024}))
+	require.Error(t, l.checkSize(p2p.Msg{Code: eth.BlockBodiesMsg, Size: 100*1024 + 1}))
+	// requests have own, smaller limits
+	require.NoError(t, l.checkSize(p2p.Msg{Code: eth.GetBlockHeadersMsg, Size: 1024}))
+	require.Error(t, l.checkSize(p2p.Msg{Code: eth.GetBlockHeadersMsg, Size: 1025}))
+	// but never above the overall limit
+	l.MaxMsgSize = 100
+	require.Error(t, l.checkSize(p2p.Msg{Code: eth.GetPooledTransactionsMsg, Size: 101}))
+}
+
+func TestLimitsIdleWatchdog(t *testing.T) {
+	require.Nil(t, Limits{}.idleWatchdog(func() { t.Fatal("no idle timeout") }))
+
+	l := Limits{IdleTimeout: 50 * time.Millisecond}
+	idle := make(chan struct{})
+	watchdog := l.idleWatchdog(func() { close(idle) })
+	defer watchdog.Stop()
+	for i := 0; i < 3; i++ {
+		time.Sleep(20 * time.Millisecond)
+		watchdog.Reset(l.IdleTimeout)
+	}
+	select {
+	case <-idle:
+		t.Fatal("reset watchdog fired")
+	default:
+	}
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("watchdog didn't fire")
+	}
+}
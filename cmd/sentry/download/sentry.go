@@ -3,6 +3,7 @@ package download
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -138,6 +139,7 @@ func handShake(
 	status *proto_sentry.StatusData,
 	peerID string,
 	rw p2p.MsgReadWriter,
+	limits Limits,
 	version uint,
 	minVersion uint,
 	startSync func(bestHash common.Hash) error,
@@ -152,6 +154,7 @@ func handShake(
 	ourTD := gointerfaces.ConvertH256ToUint256Int(status.TotalDifficulty)
 	// Convert proto status data into the one required by devp2p
 	genesisHash := gointerfaces.ConvertH256ToHash(status.ForkData.Genesis)
+	ourForkID := forkid.NewIDFromForks(status.ForkData.Forks, genesisHash, status.MaxBlock)
 	go func() {
 		defer debug.LogPanic()
 		s := &eth.StatusPacket{
@@ -160,7 +163,7 @@ func handShake(
 			TD:              ourTD.ToBig(),
 			Head:            gointerfaces.ConvertH256ToHash(status.BestHash),
 			Genesis:         genesisHash,
-			ForkID:          forkid.NewIDFromForks(status.ForkData.Forks, genesisHash, status.MaxBlock),
+			ForkID:          ourForkID,
 		}
 		errc <- p2p.Send(rw, eth.StatusMsg, s)
 	}()
@@ -177,9 +180,9 @@ func handShake(
 			msg.Discard()
 			return fmt.Errorf("first msg has code %x (!= %x)", msg.Code, eth.StatusMsg)
 		}
-		if msg.Size > eth.ProtocolMaxMsgSize {
+		if err1 = limits.checkSize(msg); err1 != nil {
 			msg.Discard()
-			return fmt.Errorf("message is too large %d, limit %d", msg.Size, eth.ProtocolMaxMsgSize)
+			return err1
 		}
 		// Decode the handshake and make sure everything matches
 		var reply eth.StatusPacket
@@ -198,7 +201,7 @@ func handShake(
 			return fmt.Errorf("genesis hash does not match: theirs %x, ours %x", reply.Genesis, genesisHash)
 		}
 		if err1 = forkFilter(reply.ForkID); err1 != nil {
-			return fmt.Errorf("%v", err1)
+			return &forkIDMismatchError{ours: ourForkID, theirs: reply.ForkID, reason: err1}
 		}
 
 		td, overflow := uint256.FromBig(reply.TD)
@@ -219,7 +222,7 @@ func handShake(
 		errc <- readStatus()
 	}()
 
-	timeout := time.NewTimer(handshakeTimeout)
+	timeout := time.NewTimer(limits.HandshakeTimeout)
 	defer timeout.Stop()
 	for i := 0; i < 2; i++ {
 		select {
@@ -242,6 +245,7 @@ func runPeer(
 	peerID string,
 	protocol uint,
 	rw p2p.MsgReadWriter,
+	limits Limits,
 	peerInfo *PeerInfo,
 	send func(msgId proto_sentry.MessageId, peerID string, b []byte),
 	hasSubscribers func(msgId proto_sentry.MessageId) bool,
@@ -258,6 +262,10 @@ func runPeer(
 			log.Info(fmt.Sprintf("Peer %s [%s] disconnected", peerID, peerInfo.peer.Fullname()), "proto", protocol)
 		}
 	}()
+	idle := limits.idleWatchdog(func() { peerInfo.peer.Disconnect(p2p.DiscReadTimeout) })
+	if idle != nil {
+		defer idle.Stop()
+	}
 	for {
 		if !peerPrinted {
 			if time.Now().After(printTime) {
@@ -275,9 +283,12 @@ func runPeer(
 		if err != nil {
 			return fmt.Errorf("reading message: %v", err)
 		}
-		if msg.Size > eth.ProtocolMaxMsgSize {
+		if idle != nil {
+			idle.Reset(limits.IdleTimeout)
+		}
+		if err = limits.checkSize(msg); err != nil {
 			msg.Discard()
-			return fmt.Errorf("message is too large %d, limit %d", msg.Size, eth.ProtocolMaxMsgSize)
+			return err
 		}
 		givePermit := false
 		switch msg.Code {
@@ -474,6 +485,7 @@ func NewSentryServer(ctx context.Context, dialCandidates enode.Iterator, readNod
 		ctx:          ctx,
 		p2p:          cfg,
 		peersStreams: NewPeersStreams(),
+		limits:       DefaultLimits,
 	}
 
 	if protocol != eth.ETH65 && protocol != eth.ETH66 {
@@ -499,13 +511,17 @@ func NewSentryServer(ctx context.Context, dialCandidates enode.Iterator, readNod
 			}
 
 			defer ss.GoodPeers.Delete(peerID)
-			err := handShake(ctx, ss.GetStatus(), peerID, rw, protocol, protocol, func(bestHash common.Hash) error {
+			err := handShake(ctx, ss.GetStatus(), peerID, rw, ss.limits, protocol, protocol, func(bestHash common.Hash) error {
 				ss.GoodPeers.Store(peerID, peerInfo)
 				ss.sendNewPeerToClients(gointerfaces.ConvertBytesToH512([]byte(peerID)))
 				return ss.startSync(ctx, bestHash, peerID)
 			})
 			if err != nil {
-				return fmt.Errorf("handshake to peer %s: %v", peerID, err)
+				var forkErr *forkIDMismatchError
+				if errors.As(err, &forkErr) {
+					ss.forkMismatches.record(peerID, peer.Fullname(), forkErr)
+				}
+				return fmt.Errorf("handshake to peer %s: %w", peerID, err)
 			}
 			log.Debug(fmt.Sprintf("[%s] Received status message OK", peerID), "name", peer.Name())
 
@@ -514,6 +530,7 @@ func NewSentryServer(ctx context.Context, dialCandidates enode.Iterator, readNod
 				peerID,
 				protocol,
 				rw,
+				ss.limits,
 				peerInfo,
 				ss.send,
 				ss.hasSubscribers,
@@ -539,12 +556,13 @@ func NewSentryServer(ctx context.Context, dialCandidates enode.Iterator, readNod
 }
 
 // Sentry creates and runs standalone sentry
-func Sentry(datadir string, sentryAddr string, discoveryDNS []string, cfg *p2p.Config, protocolVersion uint) error {
+func Sentry(datadir string, sentryAddr string, discoveryDNS []string, cfg *p2p.Config, protocolVersion uint, limits Limits) error {
 	if err := os.MkdirAll(path.Join(datadir, "erigon"), 0744); err != nil {
 		return fmt.Errorf("could not create dir: %s, %w", datadir, err)
 	}
 	ctx := rootContext()
 	sentryServer := NewSentryServer(ctx, nil, func() *eth.NodeInfo { return nil }, cfg, protocolVersion)
+	sentryServer.limits = limits
 
 	err := grpcSentryServer(ctx, sentryAddr, sentryServer)
 	if err != nil {
@@ -570,6 +588,8 @@ type SentryServerImpl struct {
 	messageStreamsLock sync.RWMutex
 	peersStreams       *PeersStreams
 	p2p                *p2p.Config
+	forkMismatches     forkMismatchStats
+	limits             Limits
 }
 
 func (ss *SentryServerImpl) startSync(ctx context.Context, bestHash common.Hash, peerID string) error {
@@ -634,6 +654,33 @@ func (ss *SentryServerImpl) PenalizePeer(_ context.Context, req *proto_sentry.Pe
 	return &empty.Empty{}, nil
 }
 
+// DropPeers disconnects the given share (0..1) of good peers, so that discovery replaces them by new ones.
+// Shares out of the range are clamped to it. Returns the amount of dropped peers.
+func (ss *SentryServerImpl) DropPeers(share float64) int {
+	if !(share > 0) { // also NaN
+		return 0
+	}
+	if share > 1 {
+		share = 1
+	}
+	var peerIDs []string
+	ss.GoodPeers.Range(func(key, value interface{}) bool {
+		peerIDs = append(peerIDs, key.(string))
+		return true
+	})
+	rand.Shuffle(len(peerIDs), func(i, j int) { peerIDs[i], peerIDs[j] = peerIDs[j], peerIDs[i] })
+	peerIDs = peerIDs[:int(float64(len(peerIDs))*share)]
+	for _, peerID := range peerIDs {
+		if x, ok := ss.GoodPeers.Load(peerID); ok {
+			if peerInfo, _ := x.(*PeerInfo); peerInfo != nil {
+				peerInfo.Remove()
+			}
+		}
+		ss.GoodPeers.Delete(peerID)
+	}
+	return len(peerIDs)
+}
+
 func (ss *SentryServerImpl) PeerMinBlock(_ context.Context, req *proto_sentry.PeerMinBlockRequest) (*empty.Empty, error) {
 	peerID := string(gointerfaces.ConvertH512ToBytes(req.PeerId))
 	x, _ := ss.GoodPeers.Load(peerID)
@@ -2,6 +2,7 @@ package download
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"testing"
 	"time"
@@ -93,8 +94,8 @@ func testForkIDSplit(t *testing.T, protocol uint) {
 	defer p2pProFork.Close()
 
 	errc := make(chan error, 2)
-	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, protocol, protocol, nil) }()
-	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, DefaultLimits, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, DefaultLimits, protocol, protocol, nil) }()
 
 	for i := 0; i < 2; i++ {
 		select {
@@ -111,8 +112,8 @@ func testForkIDSplit(t *testing.T, protocol uint) {
 	s1.statusData.MaxBlock = 1
 	s2.statusData.MaxBlock = 1
 
-	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, protocol, protocol, nil) }()
-	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, DefaultLimits, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, DefaultLimits, protocol, protocol, nil) }()
 
 	for i := 0; i < 2; i++ {
 		select {
@@ -130,8 +131,8 @@ func testForkIDSplit(t *testing.T, protocol uint) {
 	s2.statusData.MaxBlock = 2
 
 	// Both nodes should allow the other to connect (same genesis, next fork is the same)
-	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, protocol, protocol, nil) }()
-	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s1.GetStatus(), "1", p2pNoFork, DefaultLimits, protocol, protocol, nil) }()
+	go func() { errc <- handShake(ctx, s2.GetStatus(), "2", p2pProFork, DefaultLimits, protocol, protocol, nil) }()
 
 	var successes int
 	for i := 0; i < 2; i++ {
@@ -142,9 +143,23 @@ func testForkIDSplit(t *testing.T, protocol uint) {
 				if successes == 2 { // Only one side disconnects
 					t.Fatalf("fork ID rejection didn't happen")
 				}
+			} else if !errors.Is(err, eth.ErrForkIDRejected) {
+				t.Fatalf("unexpected rejection reason: %v", err)
 			}
 		case <-time.After(250 * time.Millisecond):
 			t.Fatalf("split peers not rejected")
 		}
 	}
 }
+
+func TestDropPeers(t *testing.T) {
+	ss := &SentryServerImpl{}
+	for _, id := range []string{"1", "2", "3", "4"} {
+		ss.GoodPeers.Store(id, &PeerInfo{})
+	}
+	require.Equal(t, 0, ss.DropPeers(-1))
+	require.Equal(t, 2, ss.DropPeers(0.5))
+	// out of range shares are clamped
+	require.Equal(t, 2, ss.DropPeers(1.5))
+	require.Equal(t, 0, ss.DropPeers(1))
+}
@@ -0,0 +1,138 @@
+package download
+
+import (
+	"context"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/c2h5oh/datasize"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	proto_sentry "github.com/ledgerwatch/erigon-lib/gointerfaces/sentry"
+	proto_types "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
+	"golang.org/x/time/rate"
+)
+
+// ServingLimits throttle serving of historical data (block bodies and receipts) to peers, so leechers
+// can't saturate the disk of an archive node at the expense of its local users. Headers are not throttled,
+// peers need them to follow the chain.
+type ServingLimits struct {
+	UploadRate  datasize.ByteSize // bytes per second of bodies and receipts sent to all peers, 0 - no limit
+	RequestRate float64           // GetBlockBodies and GetReceipts requests per second accepted from one peer, 0 - no limit
+}
+
+var (
+	servingBytes             = metrics.GetOrCreateCounter("p2p_serving_bytes")
+	servingThrottledRequests = metrics.GetOrCreateCounter("p2p_serving_throttled_requests")
+)
+
+// servingPeersLimit - amount of peers, request rate of which is tracked
+const servingPeersLimit = 1024
+
+type servingThrottle struct {
+	upload      *rate.Limiter // nil if not limited
+	requestRate rate.Limit
+	peers       *lru.Cache // peer id -> *rate.Limiter, nil if not limited
+}
+
+func newServingThrottle(limits ServingLimits) *servingThrottle {
+	t := &servingThrottle{}
+	if limits.UploadRate > 0 {
+		// responses are soft-limited to 2MB, burst must fit the biggest of them
+		burst := int(limits.UploadRate)
+		if burst < 4*int(datasize.MB) {
+			burst = 4 * int(datasize.MB)
+		}
+		t.upload = rate.NewLimiter(rate.Limit(limits.UploadRate), burst)
+	}
+	if limits.RequestRate > 0 {
+		t.requestRate = rate.Limit(limits.RequestRate)
+		t.peers, _ = lru.New(servingPeersLimit)
+	}
+	return t
+}
+
+// allowRequest returns false if the peer sends requests for bodies or receipts too often,
+// such requests are left unanswered before they touch the database
+func (t *servingThrottle) allowRequest(peerID *proto_types.H512) bool {
+	if t.peers == nil {
+		return true
+	}
+	key := string(gointerfaces.ConvertH512ToBytes(peerID))
+	limiter, ok := t.peers.Get(key)
+	if !ok {
+		limiter = rate.NewLimiter(t.requestRate, 1+int(t.requestRate))
+		t.peers.Add(key, limiter)
+	}
+	if !limiter.(*rate.Limiter).Allow() {
+		servingThrottledRequests.Inc()
+		return false
+	}
+	return true
+}
+
+// waitUpload blocks until the response of the given size can be sent without exceeding the upload rate.
+// Requests of one type are served one by one, so it also slows down reading of the database for peers.
+func (t *servingThrottle) waitUpload(ctx context.Context, size int) error {
+	servingBytes.Add(size)
+	if t.upload == nil {
+		return nil
+	}
+	if size > t.upload.Burst() {
+		size = t.upload.Burst()
+	}
+	return t.upload.WaitN(ctx, size)
+}
+
+// servingQueueSize - amount of requests of one type waiting for the upload budget, requests beyond it are dropped
+const servingQueueSize = 128
+
+// servingQueues serve requests of bodies and receipts in own goroutines, one per message id, so waiting
+// for the upload budget doesn't delay requests of headers, which are served by the receiving loop itself
+type servingQueues struct {
+	queues map[proto_sentry.MessageId]chan *proto_sentry.InboundMessage
+	wg     sync.WaitGroup
+}
+
+func newServingQueues(ctx context.Context, ids []proto_sentry.MessageId, handle func(ctx context.Context, req *proto_sentry.InboundMessage), done func()) *servingQueues {
+	q := &servingQueues{queues: make(map[proto_sentry.MessageId]chan *proto_sentry.InboundMessage, len(ids))}
+	for _, id := range ids {
+		ch := make(chan *proto_sentry.InboundMessage, servingQueueSize)
+		q.queues[id] = ch
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for req := range ch {
+				// requests left in the queue after the stream ended are not answered
+				if ctx.Err() == nil {
+					handle(ctx, req)
+				}
+				done()
+			}
+		}()
+	}
+	return q
+}
+
+// push returns false if the message is not served by the queues, and must be handled by the caller
+func (q *servingQueues) push(req *proto_sentry.InboundMessage, done func()) bool {
+	ch, ok := q.queues[req.Id]
+	if !ok {
+		return false
+	}
+	select {
+	case ch <- req:
+	default:
+		servingThrottledRequests.Inc()
+		done()
+	}
+	return true
+}
+
+// close waits for the goroutines to finish, the context given to newServingQueues must be cancelled before
+func (q *servingQueues) close() {
+	for _, ch := range q.queues {
+		close(ch)
+	}
+	q.wg.Wait()
+}
@@ -0,0 +1,81 @@
+package download
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	proto_sentry "github.com/ledgerwatch/erigon-lib/gointerfaces/sentry"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServingRequestRate(t *testing.T) {
+	throttle := newServingThrottle(ServingLimits{RequestRate: 2})
+	peer1 := gointerfaces.ConvertBytesToH512(common.FromHex("0x01"))
+	peer2 := gointerfaces.ConvertBytesToH512(common.FromHex("0x02"))
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if throttle.allowRequest(peer1) {
+			allowed++
+		}
+	}
+	require.Equal(t, 3, allowed) // burst
+	// other peers have own limits
+	require.True(t, throttle.allowRequest(peer2))
+
+	unlimited := newServingThrottle(ServingLimits{})
+	for i := 0; i < 10; i++ {
+		require.True(t, unlimited.allowRequest(peer1))
+	}
+}
+
+func TestServingUploadRate(t *testing.T) {
+	ctx := context.Background()
+	throttle := newServingThrottle(ServingLimits{UploadRate: 4 * datasize.MB})
+	// the burst is spent at once, then the rate applies
+	require.NoError(t, throttle.waitUpload(ctx, 4*int(datasize.MB)))
+	start := time.Now()
+	require.NoError(t, throttle.waitUpload(ctx, int(datasize.MB)))
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+
+	// responses bigger than the burst don't fail
+	require.NoError(t, newServingThrottle(ServingLimits{UploadRate: 4 * datasize.MB}).waitUpload(ctx, 100*int(datasize.MB)))
+	require.NoError(t, newServingThrottle(ServingLimits{}).waitUpload(ctx, 100*int(datasize.MB)))
+	// waiting stops with the context
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, throttle.waitUpload(ctx, 4*int(datasize.MB)))
+}
+
+func TestServingQueues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// bodies wait for the upload budget until the test releases them
+	release := make(chan struct{})
+	handled := make(chan proto_sentry.MessageId, 2*servingQueueSize)
+	dropped := 0
+	queues := newServingQueues(ctx, []proto_sentry.MessageId{proto_sentry.MessageId_GET_BLOCK_BODIES_66, proto_sentry.MessageId_GET_RECEIPTS_66}, func(ctx context.Context, req *proto_sentry.InboundMessage) {
+		if req.Id == proto_sentry.MessageId_GET_BLOCK_BODIES_66 {
+			<-release
+		}
+		handled <- req.Id
+	}, func() {})
+
+	// the receiving loop is not blocked by waiting bodies, overflow of the queue is dropped
+	for i := 0; i < servingQueueSize+10; i++ {
+		require.True(t, queues.push(&proto_sentry.InboundMessage{Id: proto_sentry.MessageId_GET_BLOCK_BODIES_66}, func() { dropped++ }))
+	}
+	require.GreaterOrEqual(t, dropped, 9)
+	// headers are left to the caller
+	require.False(t, queues.push(&proto_sentry.InboundMessage{Id: proto_sentry.MessageId_GET_BLOCK_HEADERS_66}, func() {}))
+	// receipts have own queue
+	require.True(t, queues.push(&proto_sentry.InboundMessage{Id: proto_sentry.MessageId_GET_RECEIPTS_66}, func() {}))
+	require.Equal(t, proto_sentry.MessageId_GET_RECEIPTS_66, <-handled)
+
+	close(release)
+	require.Equal(t, proto_sentry.MessageId_GET_BLOCK_BODIES_66, <-handled)
+	cancel()
+	queues.close()
+}
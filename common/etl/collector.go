@@ -32,6 +32,9 @@ type Collector struct {
 	allFlushed      bool
 	autoClean       bool
 	bufType         int
+
+	flushes []*backgroundFlush // of full buffers, in the order of collection
+	workers chan struct{}      // semaphore of SortWorkers, nil - buffers are flushed by the collecting goroutine
 }
 
 // NewCollectorFromFiles creates collector from existing files (left over from previous unsuccessful loading)
@@ -67,16 +70,26 @@ func NewCriticalCollector(tmpdir string, sortableBuffer Buffer) *Collector {
 
 func NewCollector(tmpdir string, sortableBuffer Buffer) *Collector {
 	c := &Collector{autoClean: true, bufType: getTypeByBuffer(sortableBuffer)}
+	if SortWorkers > 0 {
+		c.workers = make(chan struct{}, SortWorkers)
+	}
 	encoder := codec.NewEncoder(nil, &cbor)
 
 	c.flushBuffer = func(currentKey []byte, canStoreInRam bool) error {
 		if sortableBuffer.Len() == 0 {
 			return nil
 		}
+		canStoreInRam = canStoreInRam && len(c.dataProviders) == 0 && len(c.flushes) == 0
+		if !canStoreInRam && c.workers != nil {
+			full := sortableBuffer
+			sortableBuffer = newBufferLike(full)
+			c.flushInBackground(full, tmpdir)
+			return nil
+		}
 		var provider dataProvider
 		var err error
 		sortableBuffer.Sort()
-		if canStoreInRam && len(c.dataProviders) == 0 {
+		if canStoreInRam {
 			provider = KeepInRAM(sortableBuffer)
 			c.allFlushed = true
 		} else {
@@ -118,6 +131,9 @@ func (c *Collector) Load(logPrefix string, db kv.RwTx, toBucket string, loadFunc
 			return e
 		}
 	}
+	if err := c.waitFlushes(); err != nil {
+		return err
+	}
 	if err := loadFilesIntoBucket(logPrefix, db, toBucket, c.bufType, c.dataProviders, loadFunc, args); err != nil {
 		return err
 	}
@@ -125,6 +141,7 @@ func (c *Collector) Load(logPrefix string, db kv.RwTx, toBucket string, loadFunc
 }
 
 func (c *Collector) Close(logPrefix string) {
+	_ = c.waitFlushes() // errors are returned by Load, here files of the flushes are only collected to be removed
 	totalSize := uint64(0)
 	for _, p := range c.dataProviders {
 		totalSize += p.Dispose()
@@ -138,6 +155,13 @@ func loadFilesIntoBucket(logPrefix string, db kv.RwTx, bucket string, bufType in
 	decoder := codec.NewDecoder(nil, &cbor)
 	var m runtime.MemStats
 
+	if LoadReadAhead > 0 {
+		for i, provider := range providers {
+			if file, ok := provider.(*fileDataProvider); ok {
+				providers[i] = readAhead(file, LoadReadAhead)
+			}
+		}
+	}
 	h := &Heap{comparator: args.Comparator}
 	heap.Init(h)
 	for i, provider := range providers {
@@ -153,7 +153,10 @@ func extractBucketIntoFiles(
 	}); err != nil {
 		return err
 	}
-	return collector.flushBuffer(nil, true)
+	if err := collector.flushBuffer(nil, true); err != nil {
+		return err
+	}
+	return collector.waitFlushes()
 }
 
 type currentTableReader struct {
@@ -357,3 +357,29 @@ func compareBucketsDouble(t *testing.T, db kv.Tx, b1, b2 string) {
 	assert.NoError(t, err)
 	assert.Equal(t, b1Map, b2Map)
 }
+
+func TestParallelSortAndLoad(t *testing.T) {
+	defer func(workers, readAhead int) { SortWorkers, LoadReadAhead = workers, readAhead }(SortWorkers, LoadReadAhead)
+	for _, tc := range []struct{ workers, readAhead int }{{0, 0}, {3, 0}, {3, 5}, {1, 2000}} {
+		SortWorkers, LoadReadAhead = tc.workers, tc.readAhead
+		_, tx := memdb.NewTestTx(t)
+		bucket := kv.ChaindataTables[0]
+		// every key is collected twice, the oldest value must win over flushes of buffers
+		collector := NewCollector("", NewOldestEntryBuffer(100))
+		for round := 0; round < 2; round++ {
+			for i := 999; i >= 0; i-- {
+				assert.NoError(t, collector.Collect([]byte(fmt.Sprintf("%04d", i)), []byte(fmt.Sprintf("%d-%d", i, round))))
+			}
+		}
+		assert.NoError(t, collector.Load("logPrefix", tx, bucket, IdentityLoadFunc, TransformArgs{}))
+
+		i := 0
+		assert.NoError(t, tx.ForEach(bucket, nil, func(k, v []byte) error {
+			assert.Equal(t, fmt.Sprintf("%04d", i), string(k))
+			assert.Equal(t, fmt.Sprintf("%d-0", i), string(v), "workers=%d readAhead=%d", tc.workers, tc.readAhead)
+			i++
+			return nil
+		}))
+		assert.Equal(t, 1000, i)
+	}
+}
@@ -0,0 +1,159 @@
+package etl
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ugorji/go/codec"
+)
+
+// SortWorkers - the number of full buffers of a collector sorted and flushed to disk in the background, while
+// the collector fills the next one. Every worker holds a buffer, so collectors take up to (SortWorkers+1) buffers
+// of memory. 0 - buffers are sorted and flushed by the collecting goroutine.
+var SortWorkers = 1 /* var because we want to sometimes change it from tests or command-line flags */
+
+// LoadReadAhead - the number of entries of every file decoded in the background during loading, so that reading
+// of files overlaps with their merge and with writes into the database. 0 - files are decoded by the loading goroutine.
+var LoadReadAhead = 4096
+
+// readAheadBatch - the max number of entries passed by one message of the decoding goroutine
+const readAheadBatch = 1024
+
+// backgroundFlush is the buffer being sorted and flushed to disk by one of SortWorkers
+type backgroundFlush struct {
+	done     chan struct{}
+	provider dataProvider
+	err      error
+}
+
+// flushInBackground sorts and flushes the buffer to disk by one of SortWorkers, waiting while all of them are busy
+func (c *Collector) flushInBackground(b Buffer, tmpdir string) {
+	f := &backgroundFlush{done: make(chan struct{})}
+	c.flushes = append(c.flushes, f)
+	c.workers <- struct{}{}
+	go func() {
+		defer func() {
+			<-c.workers
+			close(f.done)
+		}()
+		b.Sort()
+		f.provider, f.err = FlushToDisk(codec.NewEncoder(nil, &cbor), nil, b, tmpdir)
+	}()
+}
+
+// waitFlushes waits for background flushes and adds their files to data providers in the order of collection,
+// which the merge relies on to order equal keys
+func (c *Collector) waitFlushes() error {
+	var err error
+	for _, f := range c.flushes {
+		<-f.done
+		if f.provider != nil {
+			c.dataProviders = append(c.dataProviders, f.provider)
+		}
+		if f.err != nil && err == nil {
+			err = f.err
+		}
+	}
+	c.flushes = nil
+	return err
+}
+
+// newBufferLike returns an empty buffer of the same type, size and comparator as b
+func newBufferLike(b Buffer) Buffer {
+	switch b := b.(type) {
+	case *sortableBuffer:
+		nb := NewSortableBuffer(datasize.ByteSize(b.optimalSize))
+		nb.comparator = b.comparator
+		return nb
+	case *appendSortableBuffer:
+		nb := NewAppendBuffer(datasize.ByteSize(b.optimalSize))
+		nb.comparator = b.comparator
+		return nb
+	case *oldestEntrySortableBuffer:
+		nb := NewOldestEntryBuffer(datasize.ByteSize(b.optimalSize))
+		nb.comparator = b.comparator
+		return nb
+	default:
+		panic(fmt.Sprintf("unknown buffer type: %T ", b))
+	}
+}
+
+type readAheadEntries struct {
+	keys, values [][]byte
+	err          error // of the decoding after the entries, io.EOF at the end of the file
+}
+
+// readAheadProvider decodes entries of the file by its own goroutine, up to LoadReadAhead entries ahead of Next
+type readAheadProvider struct {
+	file     *fileDataProvider
+	batches  chan readAheadEntries
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	batch readAheadEntries
+	pos   int
+}
+
+func readAhead(file *fileDataProvider, entries int) *readAheadProvider {
+	batchSize := readAheadBatch
+	if entries < batchSize {
+		batchSize = entries
+	}
+	p := &readAheadProvider{
+		file:    file,
+		batches: make(chan readAheadEntries, entries/batchSize),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go p.decode(batchSize)
+	return p
+}
+
+func (p *readAheadProvider) decode(batchSize int) {
+	defer close(p.stopped)
+	decoder := codec.NewDecoder(nil, &cbor)
+	for {
+		var batch readAheadEntries
+		for len(batch.keys) < batchSize && batch.err == nil {
+			k, v, err := p.file.Next(decoder)
+			if err != nil {
+				batch.err = err
+				break
+			}
+			batch.keys = append(batch.keys, k)
+			batch.values = append(batch.values, v)
+		}
+		select {
+		case p.batches <- batch:
+		case <-p.stop:
+			return
+		}
+		if batch.err != nil {
+			return
+		}
+	}
+}
+
+func (p *readAheadProvider) Next(_ Decoder) ([]byte, []byte, error) {
+	for p.pos >= len(p.batch.keys) {
+		if p.batch.err != nil {
+			return nil, nil, p.batch.err
+		}
+		p.batch, p.pos = <-p.batches, 0
+	}
+	k, v := p.batch.keys[p.pos], p.batch.values[p.pos]
+	p.pos++
+	return k, v, nil
+}
+
+func (p *readAheadProvider) Dispose() uint64 {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.stopped
+	return p.file.Dispose()
+}
+
+func (p *readAheadProvider) String() string {
+	return fmt.Sprintf("%T(file: %s)", p, p.file.file.Name())
+}
@@ -0,0 +1,71 @@
+// Package keccakcache remembers keccak hashes of addresses and storage keys. The same keys are hashed again and
+// again: by HashState and IntermediateHashes for every changed key of every block (see
+// stagedsync.transformPlainStateKey), by readers of hashed state (state.DbStateReader), eth_getProof and
+// debug_intermediateRoots.
+package keccakcache
+
+import (
+	"sync"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon/common"
+)
+
+// DefaultSize of the Shared cache, it keeps about 1.2M of hashes
+const DefaultSize = 64 * datasize.MB
+
+var (
+	addressHit  = metrics.GetOrCreateCounter(`keccak_cache_hit{kind="address"}`)
+	addressMiss = metrics.GetOrCreateCounter(`keccak_cache_miss{kind="address"}`)
+	storageHit  = metrics.GetOrCreateCounter(`keccak_cache_hit{kind="storage"}`)
+	storageMiss = metrics.GetOrCreateCounter(`keccak_cache_miss{kind="storage"}`)
+)
+
+var (
+	shared     *Cache
+	sharedOnce sync.Once
+)
+
+// Shared returns the process-wide cache of DefaultSize, created on first use
+func Shared() *Cache {
+	sharedOnce.Do(func() {
+		shared = New(DefaultSize)
+	})
+	return shared
+}
+
+// Cache of keccak hashes, bounded by size. Safe for concurrent use.
+type Cache struct {
+	hashes *fastcache.Cache // keys of different kinds differ by length, so they share the cache
+}
+
+func New(size datasize.ByteSize) *Cache {
+	return &Cache{hashes: fastcache.New(int(size))}
+}
+
+// HashAddress returns the hashed key of the account
+func (c *Cache) HashAddress(address []byte) (common.Hash, error) {
+	return c.hash(address, addressHit, addressMiss)
+}
+
+// HashStorageKey returns the hashed storage key (the location in storage of a contract)
+func (c *Cache) HashStorageKey(key []byte) (common.Hash, error) {
+	return c.hash(key, storageHit, storageMiss)
+}
+
+func (c *Cache) hash(data []byte, hit, miss *metrics.Counter) (common.Hash, error) {
+	var h common.Hash
+	if v := c.hashes.Get(h[:0], data); len(v) == common.HashLength { // appended right into h
+		hit.Inc()
+		return h, nil
+	}
+	miss.Inc()
+	h, err := common.HashData(data)
+	if err != nil {
+		return h, err
+	}
+	c.hashes.Set(data, h[:])
+	return h, nil
+}
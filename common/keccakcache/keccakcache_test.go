@@ -0,0 +1,28 @@
+package keccakcache
+
+import (
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	c := New(32 * datasize.MB)
+	address := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	key := common.HexToHash("0x01")
+	hits, misses := addressHit.Get(), addressMiss.Get()
+	for i := 0; i < 2; i++ {
+		h, err := c.HashAddress(address[:])
+		require.NoError(t, err)
+		require.Equal(t, crypto.Keccak256Hash(address[:]), h)
+		h, err = c.HashStorageKey(key[:])
+		require.NoError(t, err)
+		require.Equal(t, crypto.Keccak256Hash(key[:]), h)
+	}
+	require.Equal(t, hits+1, addressHit.Get())
+	require.Equal(t, misses+1, addressMiss.Get())
+	require.Same(t, Shared(), Shared())
+}
@@ -0,0 +1,39 @@
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb"
+)
+
+// ReadAddressActivity retrieves the first and the last block in which the address took part in a call.
+// found is false if the address was never seen.
+func ReadAddressActivity(db kv.Getter, addr common.Address) (first, last uint64, found bool, err error) {
+	v, err := db.GetOne(ethdb.AddressActivity, addr[:])
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if len(v) == 0 {
+		return 0, 0, false, nil
+	}
+	if len(v) != 16 {
+		return 0, 0, false, fmt.Errorf("invalid address activity of %x: %x", addr, v)
+	}
+	return binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(v[8:]), true, nil
+}
+
+// WriteAddressActivity stores the first and the last block in which the address took part in a call
+func WriteAddressActivity(db kv.Putter, addr common.Address, first, last uint64) error {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v, first)
+	binary.BigEndian.PutUint64(v[8:], last)
+	return db.Put(ethdb.AddressActivity, common.CopyBytes(addr[:]), v)
+}
+
+// DeleteAddressActivity removes activity of the address
+func DeleteAddressActivity(db kv.Deleter, addr common.Address) error {
+	return db.Delete(ethdb.AddressActivity, addr[:], nil)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/common/dbutils"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/ethdb/cbor"
 	"github.com/ledgerwatch/erigon/rlp"
 	"github.com/ledgerwatch/log/v3"
@@ -395,9 +396,9 @@ func ReadSenders(db kv.Getter, hash common.Hash, number uint64) ([]common.Addres
 	if err != nil {
 		return nil, fmt.Errorf("readSenders failed: %w", err)
 	}
-	senders := make([]common.Address, len(data)/common.AddressLength)
-	for i := 0; i < len(senders); i++ {
-		copy(senders[i][:], data[i*common.AddressLength:])
+	senders, err := DecodeSenders(data)
+	if err != nil {
+		return nil, fmt.Errorf("readSenders failed: %w", err)
 	}
 	return senders, nil
 }
@@ -447,11 +448,7 @@ func WriteBody(db kv.RwTx, hash common.Hash, number uint64, body *types.Body) er
 }
 
 func WriteSenders(db kv.RwTx, hash common.Hash, number uint64, senders []common.Address) error {
-	data := make([]byte, common.AddressLength*len(senders))
-	for i, sender := range senders {
-		copy(data[i*common.AddressLength:], sender[:])
-	}
-	if err := db.Put(kv.Senders, dbutils.BlockBodyKey(number, hash), data); err != nil {
+	if err := db.Put(kv.Senders, dbutils.BlockBodyKey(number, hash), EncodeSenders(senders)); err != nil {
 		return fmt.Errorf("failed to store block senders: %w", err)
 	}
 	return nil
@@ -822,6 +819,45 @@ func ReadHeaderByNumber(db kv.Getter, number uint64) *types.Header {
 	return ReadHeader(db, hash, number)
 }
 
+// ReadHeadersByNumbers returns canonical headers of blocks [from, to], nil for missing ones.
+// Hashes and headers are read by two batches, which is faster for remote DB.
+func ReadHeadersByNumbers(db kv.Getter, from, to uint64) ([]*types.Header, error) {
+	if to < from {
+		return nil, nil
+	}
+	keys := make([][]byte, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		keys = append(keys, dbutils.EncodeBlockNumber(n))
+	}
+	hashes, err := ethdb.GetMany(db, kv.HeaderCanonical, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed ReadHeadersByNumbers: %w", err)
+	}
+	keys = keys[:0]
+	for i, hash := range hashes {
+		if len(hash) == 0 {
+			continue
+		}
+		keys = append(keys, dbutils.HeaderKey(from+uint64(i), common.BytesToHash(hash)))
+	}
+	data, err := ethdb.GetMany(db, kv.Headers, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed ReadHeadersByNumbers: %w", err)
+	}
+	headers := make([]*types.Header, len(hashes))
+	for i, k := range keys {
+		if len(data[i]) == 0 {
+			continue
+		}
+		header := new(types.Header)
+		if err := rlp.Decode(bytes.NewReader(data[i]), header); err != nil {
+			return nil, fmt.Errorf("invalid block header RLP %x: %w", k, err)
+		}
+		headers[binary.BigEndian.Uint64(k)-from] = header
+	}
+	return headers, nil
+}
+
 func ReadHeaderByHash(db kv.Getter, hash common.Hash) (*types.Header, error) {
 	number := ReadHeaderNumber(db, hash)
 	if number == nil {
@@ -0,0 +1,66 @@
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// DataGasStats is the data attached to transactions of one block and the gas paid for it.
+// Blobs and BlobGasUsed stay 0 while the chain has no blob transactions.
+type DataGasStats struct {
+	Txs               uint64
+	CalldataBytes     uint64
+	CalldataZeroBytes uint64
+	CalldataGas       uint64 // intrinsic gas of calldata, by the rules of the block
+	Blobs             uint64
+	BlobGasUsed       uint64
+}
+
+// ReadDataGasStats retrieves the stats of the given block, nil if they are not computed (yet)
+func ReadDataGasStats(db kv.Getter, blockNum uint64) (*DataGasStats, error) {
+	data, err := db.GetOne(ethdb.DataGasStats, dbutils.EncodeBlockNumber(blockNum))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	s := new(DataGasStats)
+	if err := rlp.DecodeBytes(data, s); err != nil {
+		return nil, fmt.Errorf("invalid data gas stats %d RLP: %w", blockNum, err)
+	}
+	return s, nil
+}
+
+// WriteDataGasStats stores the stats of the given block
+func WriteDataGasStats(db kv.Putter, blockNum uint64, s *DataGasStats) error {
+	data, err := rlp.EncodeToBytes(s)
+	if err != nil {
+		return fmt.Errorf("failed to RLP encode data gas stats: %w", err)
+	}
+	return db.Put(ethdb.DataGasStats, dbutils.EncodeBlockNumber(blockNum), data)
+}
+
+// TruncateDataGasStats removes the stats of all blocks starting from the given one
+func TruncateDataGasStats(tx kv.RwTx, from uint64) error {
+	c, err := tx.RwCursor(ethdb.DataGasStats)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, _, err := c.Seek(dbutils.EncodeBlockNumber(from)); ; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			return nil
+		}
+		if err = c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package rawdb
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// FeeEpochSize is the number of consecutive blocks aggregated into one FeeEpoch
+const FeeEpochSize = 128
+
+// FeeEpochTipPercentiles are the percentiles of effective tips stored in FeeEpoch.TipPercentiles
+var FeeEpochTipPercentiles = []int{10, 25, 50, 75, 90}
+
+// FeeEpoch is the aggregated fee market data of blocks [Epoch*FeeEpochSize, (Epoch+1)*FeeEpochSize).
+// The last epoch may be incomplete, Blocks tells how many blocks it covers.
+type FeeEpoch struct {
+	Blocks         uint64
+	Txs            uint64
+	GasUsed        uint64
+	GasLimit       uint64
+	BaseFeeBlocks  uint64   // amount of blocks with base fee, i.e. after London
+	BaseFeeSum     *big.Int // sum of base fees of BaseFeeBlocks blocks
+	MinBaseFee     *big.Int
+	MaxBaseFee     *big.Int
+	TipPercentiles []*big.Int // effective tips of all transactions of the epoch at FeeEpochTipPercentiles, empty if there are no transactions
+}
+
+// FeeEpochOf returns number of the epoch which includes given block
+func FeeEpochOf(blockNum uint64) uint64 {
+	return blockNum / FeeEpochSize
+}
+
+// ReadFeeEpoch retrieves the aggregate of the given epoch, nil if it's not computed (yet)
+func ReadFeeEpoch(db kv.Getter, epoch uint64) (*FeeEpoch, error) {
+	data, err := db.GetOne(ethdb.FeeEpoch, dbutils.EncodeBlockNumber(epoch))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	e := new(FeeEpoch)
+	if err := rlp.DecodeBytes(data, e); err != nil {
+		return nil, fmt.Errorf("invalid fee epoch %d RLP: %w", epoch, err)
+	}
+	return e, nil
+}
+
+// WriteFeeEpoch stores the aggregate of the given epoch
+func WriteFeeEpoch(db kv.Putter, epoch uint64, e *FeeEpoch) error {
+	data, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		return fmt.Errorf("failed to RLP encode fee epoch: %w", err)
+	}
+	return db.Put(ethdb.FeeEpoch, dbutils.EncodeBlockNumber(epoch), data)
+}
+
+// TruncateFeeEpochs removes all epochs starting from the given one
+func TruncateFeeEpochs(tx kv.RwTx, from uint64) error {
+	c, err := tx.RwCursor(ethdb.FeeEpoch)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, _, err := c.Seek(dbutils.EncodeBlockNumber(from)); ; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			return nil
+		}
+		if err = c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+}
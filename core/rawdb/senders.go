@@ -0,0 +1,129 @@
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/common"
+)
+
+// Senders of a block are stored as a packed array: every distinct sender once (in order of the first transaction
+// of it), followed by index of the sender of each transaction if some sender has more than one transaction in the
+// block. Addresses are incompressible, but blocks are full of transactions of the same senders (exchanges, bots).
+//
+//	sendersUnique:  version | uvarint(len) | addresses
+//	sendersIndexed: version | uvarint(len) | uvarint(unique) | addresses | uvarint(index)...
+//
+// Legacy format is concatenated addresses of all transactions, its length is a multiple of common.AddressLength,
+// so the encoding is padded by one byte when the length of it happens to be a multiple too.
+const (
+	sendersUnique  byte = 1
+	sendersIndexed byte = 2
+)
+
+// EncodeSenders packs senders of transactions of a block, empty list is encoded as empty value
+func EncodeSenders(senders []common.Address) []byte {
+	if len(senders) == 0 {
+		return nil
+	}
+	positions := make(map[common.Address]uint64, len(senders))
+	unique := make([]common.Address, 0, len(senders))
+	indexes := make([]uint64, len(senders))
+	for i, sender := range senders {
+		pos, ok := positions[sender]
+		if !ok {
+			pos = uint64(len(unique))
+			positions[sender] = pos
+			unique = append(unique, sender)
+		}
+		indexes[i] = pos
+	}
+
+	version := sendersUnique
+	if len(unique) < len(senders) {
+		version = sendersIndexed
+	}
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(unique)*common.AddressLength+len(indexes)*binary.MaxVarintLen32+1)
+	buf = append(buf, version)
+	buf = appendUvarint(buf, uint64(len(senders)))
+	if version == sendersIndexed {
+		buf = appendUvarint(buf, uint64(len(unique)))
+	}
+	for i := range unique {
+		buf = append(buf, unique[i][:]...)
+	}
+	if version == sendersIndexed {
+		for _, idx := range indexes {
+			buf = appendUvarint(buf, idx)
+		}
+	}
+	if len(buf)%common.AddressLength == 0 {
+		buf = append(buf, 0) // not to be confused with the legacy format
+	}
+	return buf
+}
+
+// DecodeSenders reads senders in both packed and legacy formats
+func DecodeSenders(data []byte) ([]common.Address, error) {
+	if len(data)%common.AddressLength == 0 || len(data) < common.AddressLength { // empty values may be read as 1 byte
+		senders := make([]common.Address, len(data)/common.AddressLength)
+		for i := 0; i < len(senders); i++ {
+			copy(senders[i][:], data[i*common.AddressLength:])
+		}
+		return senders, nil
+	}
+
+	version, rest := data[0], data[1:]
+	if version != sendersUnique && version != sendersIndexed {
+		return nil, fmt.Errorf("unknown version of senders encoding: %d", version)
+	}
+	n, rest, err := readUvarint(rest)
+	if err != nil {
+		return nil, err
+	}
+	unique := n
+	if version == sendersIndexed {
+		if unique, rest, err = readUvarint(rest); err != nil {
+			return nil, err
+		}
+	}
+	if unique > n || unique > uint64(len(rest)/common.AddressLength) {
+		return nil, fmt.Errorf("senders are truncated: %d of %d addresses", len(rest)/common.AddressLength, unique)
+	}
+	addresses := rest[:unique*common.AddressLength]
+	rest = rest[unique*common.AddressLength:]
+	if version == sendersIndexed && uint64(len(rest)) < n {
+		return nil, fmt.Errorf("senders are truncated: %d indexes of %d", len(rest), n)
+	}
+
+	senders := make([]common.Address, n)
+	for i := range senders {
+		pos := uint64(i)
+		if version == sendersIndexed {
+			if pos, rest, err = readUvarint(rest); err != nil {
+				return nil, err
+			}
+			if pos >= unique {
+				return nil, fmt.Errorf("index of sender %d is out of range %d", pos, unique)
+			}
+		}
+		copy(senders[i][:], addresses[pos*common.AddressLength:])
+	}
+	if len(rest) > 1 {
+		return nil, fmt.Errorf("%d unexpected bytes after senders", len(rest))
+	}
+	return senders, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("senders are truncated: bad varint")
+	}
+	return v, data[n:], nil
+}
@@ -0,0 +1,49 @@
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendersEncoding(t *testing.T) {
+	a, b, c := common.Address{1}, common.Address{2}, common.Address{3}
+	for _, senders := range [][]common.Address{
+		{a},
+		{a, b, c},
+		{a, a, a, a},
+		{a, b, a, c, b, a},
+		{a, b, a, b, a, b, a, b, a, b, a, b, a, b, a, b, a}, // padded
+	} {
+		data := EncodeSenders(senders)
+		require.NotZero(t, len(data)%common.AddressLength, "senders %x", senders)
+		decoded, err := DecodeSenders(data)
+		require.NoError(t, err)
+		require.Equal(t, senders, decoded)
+	}
+
+	require.Empty(t, EncodeSenders(nil))
+	decoded, err := DecodeSenders(nil)
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	// repeated senders are stored once
+	many := make([]common.Address, 100)
+	for i := range many {
+		many[i] = common.Address{byte(i % 3)}
+	}
+	require.Less(t, len(EncodeSenders(many)), 4*common.AddressLength+len(many))
+
+	// legacy format: concatenated addresses
+	legacy := append(append(append([]byte{}, a[:]...), b[:]...), a[:]...)
+	decoded, err = DecodeSenders(legacy)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{a, b, a}, decoded)
+
+	data := EncodeSenders([]common.Address{a, b, a})
+	_, err = DecodeSenders(data[:len(data)-2])
+	require.Error(t, err)
+	_, err = DecodeSenders(append([]byte{7}, data[1:]...))
+	require.Error(t, err)
+}
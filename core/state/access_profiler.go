@@ -0,0 +1,278 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+)
+
+// Sources of state reads counted by AccessProfiler
+const (
+	AccessSourceExecution = "execution" // execution of blocks by the sync
+	AccessSourceRPC       = "rpc"       // calls and traces of RPC
+)
+
+// AccessProfilerConfig - sampling and aggregation of AccessProfiler
+type AccessProfilerConfig struct {
+	SampleRate int           // every SampleRate-th read is sampled, 0 - the profiler is disabled
+	Window     time.Duration // samples are aggregated by windows of this duration
+	Windows    int           // the last windows kept
+	MaxKeys    int           // accounts and slots counted in one window, samples of other ones are only counted as dropped
+}
+
+// DefaultAccessProfilerConfig keeps the last hour by 10 minutes, the profiler is disabled until SampleRate is set
+var DefaultAccessProfilerConfig = AccessProfilerConfig{
+	Window:  10 * time.Minute,
+	Windows: 6,
+	MaxKeys: 100_000,
+}
+
+// DefaultAccessProfileLimit - accounts and slots returned by AccessProfiler.Profile without the limit
+const DefaultAccessProfileLimit = 100
+
+// AccessProfiler samples reads of accounts and storage slots through the readers made by Reader and aggregates
+// them by time windows, to find the hottest contracts and slots for sizing of caches and for research of contention.
+// Only every SampleRate-th read takes the lock, others cost one atomic increment. Reads of code and incarnations
+// are counted as reads of the account.
+//
+// nil AccessProfiler is a valid disabled profiler.
+type AccessProfiler struct {
+	cfg   AccessProfilerConfig
+	reads uint64 // all reads, atomic
+
+	mu      sync.Mutex
+	windows []*accessWindow // the oldest first, the last one is being filled
+	now     func() time.Time
+}
+
+type accessSlot struct {
+	address common.Address
+	slot    common.Hash
+}
+
+type accessWindow struct {
+	start    time.Time
+	samples  uint64
+	dropped  uint64
+	sources  map[string]uint64
+	accounts map[common.Address]uint64
+	slots    map[accessSlot]uint64
+}
+
+// NewAccessProfiler returns nil if cfg.SampleRate is 0
+func NewAccessProfiler(cfg AccessProfilerConfig) *AccessProfiler {
+	if cfg.SampleRate <= 0 {
+		return nil
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultAccessProfilerConfig.Window
+	}
+	if cfg.Windows <= 0 {
+		cfg.Windows = DefaultAccessProfilerConfig.Windows
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = DefaultAccessProfilerConfig.MaxKeys
+	}
+	return &AccessProfiler{cfg: cfg, now: time.Now}
+}
+
+// Reader returns r, reads by which are sampled as reads of the source
+func (p *AccessProfiler) Reader(source string, r StateReader) StateReader {
+	if p == nil {
+		return r
+	}
+	return &profiledStateReader{p: p, source: source, r: r}
+}
+
+func (p *AccessProfiler) sample() bool {
+	return atomic.AddUint64(&p.reads, 1)%uint64(p.cfg.SampleRate) == 0
+}
+
+// record counts the sample of the account, or of its slot if slot is not nil
+func (p *AccessProfiler) record(source string, address common.Address, slot *common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.window(p.now())
+	w.samples++
+	w.sources[source]++
+	if slot == nil {
+		if _, ok := w.accounts[address]; ok || len(w.accounts)+len(w.slots) < p.cfg.MaxKeys {
+			w.accounts[address]++
+			return
+		}
+	} else {
+		key := accessSlot{address: address, slot: *slot}
+		if _, ok := w.slots[key]; ok || len(w.accounts)+len(w.slots) < p.cfg.MaxKeys {
+			w.slots[key]++
+			return
+		}
+	}
+	w.dropped++
+}
+
+// window returns the window of now, starting the next one when the current one ends. Windows older than the last
+// cfg.Windows ones are forgotten, also when there were no reads for some of them.
+func (p *AccessProfiler) window(now time.Time) *accessWindow {
+	if n := len(p.windows); n > 0 && now.Before(p.windows[n-1].start.Add(p.cfg.Window)) {
+		return p.windows[n-1]
+	}
+	w := &accessWindow{
+		start:    now.Truncate(p.cfg.Window),
+		sources:  map[string]uint64{},
+		accounts: map[common.Address]uint64{},
+		slots:    map[accessSlot]uint64{},
+	}
+	oldest := p.windowsStart(w.start, p.cfg.Windows)
+	for len(p.windows) > 0 && p.windows[0].start.Before(oldest) {
+		p.windows[0] = nil
+		p.windows = p.windows[1:]
+	}
+	p.windows = append(p.windows, w)
+	return w
+}
+
+// windowsStart returns the start of the first of n windows ending with the one started at start
+func (p *AccessProfiler) windowsStart(start time.Time, n int) time.Time {
+	return start.Add(-time.Duration(n-1) * p.cfg.Window)
+}
+
+// AccessProfile is the aggregate of the last windows of AccessProfiler. All counts are of samples: multiplied
+// by SampleRate they estimate the reads.
+type AccessProfile struct {
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	SampleRate int               `json:"sampleRate"`
+	Samples    uint64            `json:"samples"`
+	Dropped    uint64            `json:"dropped"` // samples of accounts and slots above MaxKeys of their window
+	Sources    map[string]uint64 `json:"sources"`
+	Accounts   []AccountAccess   `json:"accounts"`
+	Slots      []SlotAccess      `json:"slots"`
+}
+
+type AccountAccess struct {
+	Address common.Address `json:"address"`
+	Samples uint64         `json:"samples"`
+}
+
+type SlotAccess struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Samples uint64         `json:"samples"`
+}
+
+// Profile merges the last windows (0 - all kept ones) and returns the top limit accounts and slots
+// (0 - DefaultAccessProfileLimit) by samples
+func (p *AccessProfiler) Profile(windows, limit int) *AccessProfile {
+	if limit <= 0 {
+		limit = DefaultAccessProfileLimit
+	}
+	profile := &AccessProfile{Sources: map[string]uint64{}, Accounts: []AccountAccess{}, Slots: []SlotAccess{}}
+	if p == nil {
+		return profile
+	}
+	profile.SampleRate = p.cfg.SampleRate
+	accountSamples := map[common.Address]uint64{}
+	slotSamples := map[accessSlot]uint64{}
+
+	p.mu.Lock()
+	now := p.now()
+	p.window(now) // the current window is empty if there were no reads in it
+	merged := p.windows
+	if windows > 0 {
+		oldest := p.windowsStart(merged[len(merged)-1].start, windows)
+		for merged[0].start.Before(oldest) {
+			merged = merged[1:]
+		}
+	}
+	profile.From, profile.To = merged[0].start, now
+	for _, w := range merged {
+		profile.Samples += w.samples
+		profile.Dropped += w.dropped
+		for source, n := range w.sources {
+			profile.Sources[source] += n
+		}
+		for address, n := range w.accounts {
+			accountSamples[address] += n
+		}
+		for key, n := range w.slots {
+			slotSamples[key] += n
+		}
+	}
+	p.mu.Unlock()
+
+	for address, n := range accountSamples {
+		profile.Accounts = append(profile.Accounts, AccountAccess{Address: address, Samples: n})
+	}
+	sort.Slice(profile.Accounts, func(i, j int) bool {
+		a, b := profile.Accounts[i], profile.Accounts[j]
+		if a.Samples != b.Samples {
+			return a.Samples > b.Samples
+		}
+		return string(a.Address[:]) < string(b.Address[:])
+	})
+	if len(profile.Accounts) > limit {
+		profile.Accounts = profile.Accounts[:limit]
+	}
+	for key, n := range slotSamples {
+		profile.Slots = append(profile.Slots, SlotAccess{Address: key.address, Slot: key.slot, Samples: n})
+	}
+	sort.Slice(profile.Slots, func(i, j int) bool {
+		a, b := profile.Slots[i], profile.Slots[j]
+		if a.Samples != b.Samples {
+			return a.Samples > b.Samples
+		}
+		if a.Address != b.Address {
+			return string(a.Address[:]) < string(b.Address[:])
+		}
+		return string(a.Slot[:]) < string(b.Slot[:])
+	})
+	if len(profile.Slots) > limit {
+		profile.Slots = profile.Slots[:limit]
+	}
+	return profile
+}
+
+type profiledStateReader struct {
+	p      *AccessProfiler
+	source string
+	r      StateReader
+}
+
+func (pr *profiledStateReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	if pr.p.sample() {
+		pr.p.record(pr.source, address, nil)
+	}
+	return pr.r.ReadAccountData(address)
+}
+
+func (pr *profiledStateReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	if pr.p.sample() {
+		pr.p.record(pr.source, address, key)
+	}
+	return pr.r.ReadAccountStorage(address, incarnation, key)
+}
+
+func (pr *profiledStateReader) ReadAccountCode(address common.Address, incarnation uint64, codeHash common.Hash) ([]byte, error) {
+	if pr.p.sample() {
+		pr.p.record(pr.source, address, nil)
+	}
+	return pr.r.ReadAccountCode(address, incarnation, codeHash)
+}
+
+func (pr *profiledStateReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	if pr.p.sample() {
+		pr.p.record(pr.source, address, nil)
+	}
+	return pr.r.ReadAccountCodeSize(address, incarnation, codeHash)
+}
+
+func (pr *profiledStateReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	if pr.p.sample() {
+		pr.p.record(pr.source, address, nil)
+	}
+	return pr.r.ReadAccountIncarnation(address)
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessProfiler(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	require.Nil(t, NewAccessProfiler(AccessProfilerConfig{}))
+	var disabled *AccessProfiler
+	db := NewPlainStateReader(tx)
+	require.Equal(t, StateReader(db), disabled.Reader(AccessSourceRPC, db))
+
+	p := NewAccessProfiler(AccessProfilerConfig{SampleRate: 2, Window: time.Minute, Windows: 2, MaxKeys: 3})
+	now := time.Date(2021, 9, 1, 0, 0, 30, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	hot, cold, other := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	slot := common.HexToHash("0x5")
+
+	exec, rpc := p.Reader(AccessSourceExecution, db), p.Reader(AccessSourceRPC, db)
+	for i := 0; i < 8; i++ {
+		_, err := exec.ReadAccountData(hot)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 4; i++ {
+		_, err := rpc.ReadAccountStorage(hot, 1, &slot)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := rpc.ReadAccountCodeSize(cold, 1, common.Hash{})
+		require.NoError(t, err)
+	}
+	// the window has 3 keys already
+	for i := 0; i < 2; i++ {
+		_, err := exec.ReadAccountData(other)
+		require.NoError(t, err)
+	}
+
+	profile := p.Profile(0, 0)
+	require.Equal(t, 2, profile.SampleRate)
+	require.Equal(t, uint64(8), profile.Samples)
+	require.Equal(t, uint64(1), profile.Dropped)
+	require.Equal(t, map[string]uint64{AccessSourceExecution: 5, AccessSourceRPC: 3}, profile.Sources)
+	require.Equal(t, []AccountAccess{{Address: hot, Samples: 4}, {Address: cold, Samples: 1}}, profile.Accounts)
+	require.Equal(t, []SlotAccess{{Address: hot, Slot: slot, Samples: 2}}, profile.Slots)
+	require.Equal(t, now.Add(-30*time.Second), profile.From)
+
+	// the next window, the first one is kept in the profile of all windows
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err := exec.ReadAccountData(other)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []AccountAccess{{Address: other, Samples: 1}}, p.Profile(1, 0).Accounts)
+	require.Equal(t, []AccountAccess{{Address: hot, Samples: 4}}, p.Profile(0, 1).Accounts)
+
+	// the first window is forgotten after 2 next ones
+	now = now.Add(time.Minute)
+	profile = p.Profile(0, 0)
+	require.Equal(t, uint64(1), profile.Samples)
+	require.Equal(t, []AccountAccess{{Address: other, Samples: 1}}, profile.Accounts)
+
+	// windows of an idle gap are forgotten as well, even though they were never started
+	now = now.Add(5 * time.Minute)
+	profile = p.Profile(0, 0)
+	require.Equal(t, uint64(0), profile.Samples)
+	require.Empty(t, profile.Accounts)
+	require.Equal(t, now.Add(-30*time.Second), profile.From)
+	require.Len(t, p.windows, 1)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/common/keccakcache"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
 )
 
@@ -50,7 +51,7 @@ func (dbr *DbStateReader) ReadAccountData(address common.Address) (*accounts.Acc
 	}
 	if !ok {
 		var err error
-		if addrHash, err1 := common.HashData(address[:]); err1 == nil {
+		if addrHash, err1 := keccakcache.Shared().HashAddress(address[:]); err1 == nil {
 			enc, err = dbr.db.GetOne(kv.HashedAccounts, addrHash[:])
 		} else {
 			return nil, err1
@@ -73,11 +74,11 @@ func (dbr *DbStateReader) ReadAccountData(address common.Address) (*accounts.Acc
 }
 
 func (dbr *DbStateReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
-	addrHash, err := common.HashData(address[:])
+	addrHash, err := keccakcache.Shared().HashAddress(address[:])
 	if err != nil {
 		return nil, err
 	}
-	seckey, err1 := common.HashData(key[:])
+	seckey, err1 := keccakcache.Shared().HashStorageKey(key[:])
 	if err1 != nil {
 		return nil, err1
 	}
@@ -0,0 +1,413 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+)
+
+// Eviction policies of SharedCache
+const (
+	SharedCacheLRU = "lru" // least recently used
+	SharedCacheARC = "arc" // adaptive replacement, keeps entries read often during scans of many cold ones
+)
+
+// SharedCacheConfig - limits of SharedCache, in entries of every bucket. 0 - the bucket is not cached.
+type SharedCacheConfig struct {
+	Policy   string // SharedCacheLRU or SharedCacheARC
+	Accounts int
+	Storage  int
+	Code     int // by code hash
+}
+
+// DefaultSharedCacheConfig takes about 500MB at most, with the code of the max size
+var DefaultSharedCacheConfig = SharedCacheConfig{
+	Policy:   SharedCacheLRU,
+	Accounts: 256 * 1024,
+	Storage:  1024 * 1024,
+	Code:     8 * 1024,
+}
+
+// sharedCacheBucket is one of the buckets of SharedCache, the cache of the chosen policy and metrics of it
+type sharedCacheBucket struct {
+	get    func(key interface{}) (interface{}, bool)
+	add    func(key, value interface{})
+	remove func(key interface{})
+	purge  func()
+	hit    *metrics.Counter
+	miss   *metrics.Counter
+}
+
+func newSharedCacheBucket(name, policy string, size int) (*sharedCacheBucket, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	b := &sharedCacheBucket{
+		hit:  metrics.GetOrCreateCounter(fmt.Sprintf(`state_cache{bucket=%q,result="hit"}`, name)),
+		miss: metrics.GetOrCreateCounter(fmt.Sprintf(`state_cache{bucket=%q,result="miss"}`, name)),
+	}
+	switch policy {
+	case SharedCacheLRU, "":
+		c, err := lru.New(size)
+		if err != nil {
+			return nil, err
+		}
+		b.get, b.purge = c.Get, c.Purge
+		b.add = func(key, value interface{}) { c.Add(key, value) }
+		b.remove = func(key interface{}) { c.Remove(key) }
+	case SharedCacheARC:
+		c, err := lru.NewARC(size)
+		if err != nil {
+			return nil, err
+		}
+		b.get, b.add, b.remove, b.purge = c.Get, c.Add, c.Remove, c.Purge
+	default:
+		return nil, fmt.Errorf("unknown policy of the state cache: %s, expected %s or %s", policy, SharedCacheLRU, SharedCacheARC)
+	}
+	return b, nil
+}
+
+func (b *sharedCacheBucket) lookup(key interface{}) (interface{}, bool) {
+	if b == nil {
+		return nil, false
+	}
+	v, ok := b.get(key)
+	if ok {
+		b.hit.Inc()
+	} else {
+		b.miss.Inc()
+	}
+	return v, ok
+}
+
+func (b *sharedCacheBucket) set(key, value interface{}) {
+	if b != nil {
+		b.add(key, value)
+	}
+}
+
+func (b *sharedCacheBucket) evict(key interface{}) {
+	if b != nil {
+		b.remove(key)
+	}
+}
+
+type sharedStorageKey struct {
+	address     common.Address
+	incarnation uint64
+	key         common.Hash
+}
+
+// SharedCache keeps accounts, storage slots and contract code of PlainState at one block - the head of the cache,
+// for execution of blocks and for calls at the latest block, so that the pages of hot contracts are not read from
+// the database again and again. Entries of every bucket are evicted by the chosen policy, hits and misses are
+// counted by bucket in the state_cache metric.
+//
+// The state is changed only between BeginWrite and EndWrite: the writer from Writer puts changes into the cache
+// as they are written, and readers, which do not take part in the change, bypass the cache until EndWrite. Any
+// reader or writer opened for another state, or not finished by EndWrite (on errors, rollbacks), makes the cache
+// forget all entries, so unwinds and reorgs, which change the head to another block, never leave stale entries.
+// Code is kept by its hash and never becomes stale.
+//
+// nil SharedCache is a valid disabled cache.
+type SharedCache struct {
+	accounts *sharedCacheBucket // common.Address -> *accounts.Account, nil - the account doesn't exist
+	storage  *sharedCacheBucket // sharedStorageKey -> []byte
+	code     *sharedCacheBucket // common.Hash -> []byte
+
+	mu         sync.RWMutex
+	number     uint64      // of the head
+	hash       common.Hash // of the head
+	known      bool        // whether the entries are of the head
+	writing    bool        // between BeginWrite and EndWrite
+	generation uint64      // changed with the head, entries are added only by readers of the current generation
+}
+
+// NewSharedCache returns nil if no bucket is cached
+func NewSharedCache(cfg SharedCacheConfig) (*SharedCache, error) {
+	if cfg.Accounts <= 0 && cfg.Storage <= 0 && cfg.Code <= 0 {
+		return nil, nil
+	}
+	c := &SharedCache{}
+	var err error
+	if c.accounts, err = newSharedCacheBucket("accounts", cfg.Policy, cfg.Accounts); err != nil {
+		return nil, err
+	}
+	if c.storage, err = newSharedCacheBucket("storage", cfg.Policy, cfg.Storage); err != nil {
+		return nil, err
+	}
+	if c.code, err = newSharedCacheBucket("code", cfg.Policy, cfg.Code); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// purge forgets accounts and storage, must be called under the lock
+func (c *SharedCache) purge() {
+	for _, b := range []*sharedCacheBucket{c.accounts, c.storage} {
+		if b != nil {
+			b.purge()
+		}
+	}
+	c.known = false
+	c.generation++
+}
+
+// Reader wraps the reader of the state at the block (number, hash), the head of the chain. The reader goes
+// through the cache if the head of the cache is this block, or if the block is above it (the cache forgets entries
+// of the old head then), otherwise r is returned.
+func (c *SharedCache) Reader(r StateReader, number uint64, hash common.Hash) StateReader {
+	if c == nil {
+		return r
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writing {
+		return r
+	}
+	if !c.known || c.number != number || c.hash != hash {
+		if c.known && number < c.number {
+			return r // the reader is behind
+		}
+		c.purge()
+		c.number, c.hash, c.known = number, hash, true
+	}
+	return &sharedCacheReader{c: c, r: r, generation: c.generation}
+}
+
+// BeginWrite starts changes of the state at the block (number, hash). If the head of the cache is another block,
+// the cache forgets all entries.
+func (c *SharedCache) BeginWrite(number uint64, hash common.Hash) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.known || c.writing || c.number != number || c.hash != hash {
+		c.purge()
+	}
+	c.known, c.writing = false, true
+	c.generation++
+}
+
+// WriteReader wraps the reader of the state, which is changed between BeginWrite and EndWrite
+func (c *SharedCache) WriteReader(r StateReader) StateReader {
+	if c == nil {
+		return r
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.writing {
+		return r
+	}
+	return &sharedCacheReader{c: c, r: r, generation: c.generation}
+}
+
+// EndWrite finishes changes, which made the state of the block (number, hash)
+func (c *SharedCache) EndWrite(number uint64, hash common.Hash) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.writing {
+		return
+	}
+	c.number, c.hash, c.known, c.writing = number, hash, true, false
+}
+
+// Writer wraps the writer of changes between BeginWrite and EndWrite
+func (c *SharedCache) Writer(w WriterWithChangeSets) WriterWithChangeSets {
+	if c == nil {
+		return w
+	}
+	return &sharedCacheWriter{c: c, w: w}
+}
+
+// Evict removes the entry of the key of PlainState, changed between BeginWrite and EndWrite without Writer
+func (c *SharedCache) Evict(key []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case len(key) == common.AddressLength:
+		c.accounts.evict(common.BytesToAddress(key))
+	case len(key) >= common.AddressLength+common.IncarnationLength+common.HashLength:
+		k := sharedStorageKey{incarnation: binary.BigEndian.Uint64(key[common.AddressLength:])}
+		copy(k.address[:], key)
+		copy(k.key[:], key[common.AddressLength+common.IncarnationLength:])
+		c.storage.evict(k)
+	}
+}
+
+// lookup returns the entry only for readers of the current generation
+func (c *SharedCache) lookup(generation uint64, b *sharedCacheBucket, key interface{}) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if generation != c.generation {
+		return nil, false
+	}
+	return b.lookup(key)
+}
+
+// fill adds the entry read from the database by the reader of the current generation
+func (c *SharedCache) fill(generation uint64, b *sharedCacheBucket, key, value interface{}) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if generation == c.generation {
+		b.set(key, value)
+	}
+}
+
+// change sets the entry written between BeginWrite and EndWrite
+func (c *SharedCache) change(b *sharedCacheBucket, key, value interface{}) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.writing {
+		b.set(key, value)
+	}
+}
+
+type sharedCacheReader struct {
+	c          *SharedCache
+	r          StateReader
+	generation uint64
+}
+
+func (cr *sharedCacheReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	if cr.c.accounts == nil {
+		return cr.r.ReadAccountData(address)
+	}
+	if v, ok := cr.c.lookup(cr.generation, cr.c.accounts, address); ok {
+		return v.(*accounts.Account), nil
+	}
+	a, err := cr.r.ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	cr.c.fill(cr.generation, cr.c.accounts, address, a)
+	return a, nil
+}
+
+func (cr *sharedCacheReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	if cr.c.storage == nil {
+		return cr.r.ReadAccountStorage(address, incarnation, key)
+	}
+	k := sharedStorageKey{address: address, incarnation: incarnation, key: *key}
+	if v, ok := cr.c.lookup(cr.generation, cr.c.storage, k); ok {
+		return v.([]byte), nil
+	}
+	v, err := cr.r.ReadAccountStorage(address, incarnation, key)
+	if err != nil {
+		return nil, err
+	}
+	cr.c.fill(cr.generation, cr.c.storage, k, common.CopyBytes(v)) // values of the database are valid only in the transaction
+	return v, nil
+}
+
+func (cr *sharedCacheReader) ReadAccountCode(address common.Address, incarnation uint64, codeHash common.Hash) ([]byte, error) {
+	if cr.c.code == nil || bytes.Equal(codeHash[:], emptyCodeHash) {
+		return cr.r.ReadAccountCode(address, incarnation, codeHash)
+	}
+	// Code never changes, so it's read by readers of any generation
+	if v, ok := cr.c.code.lookup(codeHash); ok {
+		return v.([]byte), nil
+	}
+	c, err := cr.r.ReadAccountCode(address, incarnation, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) > 0 {
+		cr.c.code.set(codeHash, common.CopyBytes(c))
+	}
+	return c, nil
+}
+
+func (cr *sharedCacheReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	c, err := cr.ReadAccountCode(address, incarnation, codeHash)
+	return len(c), err
+}
+
+// ReadAccountIncarnation is only needed to create contracts, which is rare enough to not be cached
+func (cr *sharedCacheReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	return cr.r.ReadAccountIncarnation(address)
+}
+
+type sharedCacheWriter struct {
+	c *SharedCache
+	w WriterWithChangeSets
+}
+
+func (cw *sharedCacheWriter) UpdateAccountData(address common.Address, original, account *accounts.Account) error {
+	if err := cw.w.UpdateAccountData(address, original, account); err != nil {
+		return err
+	}
+	if cw.c.accounts != nil {
+		var a accounts.Account
+		a.Copy(account)
+		cw.c.change(cw.c.accounts, address, &a)
+	}
+	return nil
+}
+
+func (cw *sharedCacheWriter) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	if err := cw.w.UpdateAccountCode(address, incarnation, codeHash, code); err != nil {
+		return err
+	}
+	if len(code) > 0 {
+		cw.c.code.set(codeHash, common.CopyBytes(code))
+	}
+	return nil
+}
+
+func (cw *sharedCacheWriter) DeleteAccount(address common.Address, original *accounts.Account) error {
+	if err := cw.w.DeleteAccount(address, original); err != nil {
+		return err
+	}
+	cw.c.change(cw.c.accounts, address, (*accounts.Account)(nil))
+	return nil
+}
+
+func (cw *sharedCacheWriter) WriteAccountStorage(address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	if err := cw.w.WriteAccountStorage(address, incarnation, key, original, value); err != nil {
+		return err
+	}
+	if *original == *value {
+		return nil
+	}
+	var v []byte
+	if !value.IsZero() {
+		v = value.Bytes()
+	}
+	cw.c.change(cw.c.storage, sharedStorageKey{address: address, incarnation: incarnation, key: *key}, v)
+	return nil
+}
+
+func (cw *sharedCacheWriter) CreateContract(address common.Address) error {
+	return cw.w.CreateContract(address)
+}
+
+func (cw *sharedCacheWriter) WriteChangeSets() error {
+	return cw.w.WriteChangeSets()
+}
+
+func (cw *sharedCacheWriter) WriteHistory() error {
+	return cw.w.WriteHistory()
+}
+
+// ChangeSetWriter is needed by ChangeSetHook of the execution
+func (cw *sharedCacheWriter) ChangeSetWriter() *ChangeSetWriter {
+	if csw, ok := cw.w.(interface{ ChangeSetWriter() *ChangeSetWriter }); ok {
+		return csw.ChangeSetWriter()
+	}
+	return nil
+}
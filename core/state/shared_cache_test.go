@@ -0,0 +1,116 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts reads, which reach the database
+type countingReader struct {
+	StateReader
+	reads int
+}
+
+func (r *countingReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	r.reads++
+	return r.StateReader.ReadAccountData(address)
+}
+
+func (r *countingReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	r.reads++
+	return r.StateReader.ReadAccountStorage(address, incarnation, key)
+}
+
+func TestSharedCache(t *testing.T) {
+	for _, policy := range []string{SharedCacheLRU, SharedCacheARC} {
+		t.Run(policy, func(t *testing.T) {
+			testSharedCache(t, policy)
+		})
+	}
+}
+
+func testSharedCache(t *testing.T, policy string) {
+	_, tx := memdb.NewTestTx(t)
+	c, err := NewSharedCache(SharedCacheConfig{Policy: policy, Accounts: 16, Storage: 16, Code: 16})
+	require.NoError(t, err)
+	addr, key := common.HexToAddress("0x1"), common.HexToHash("0x2")
+	hash1, hash2, fork := common.HexToHash("0xb1"), common.HexToHash("0xb2"), common.HexToHash("0xf2")
+
+	// Execution of the block 1
+	c.BeginWrite(0, common.Hash{})
+	db := &countingReader{StateReader: NewPlainStateReader(tx)}
+	r, w := c.WriteReader(db), c.Writer(NewPlainStateWriterNoHistory(tx))
+	a, err := r.ReadAccountData(addr)
+	require.NoError(t, err)
+	require.Nil(t, a)
+	acc := accounts.NewAccount()
+	acc.Nonce, acc.Incarnation = 1, 1
+	require.NoError(t, w.UpdateAccountData(addr, &accounts.Account{}, &acc))
+	require.NoError(t, w.WriteAccountStorage(addr, 1, &key, uint256.NewInt(0), uint256.NewInt(7)))
+	a, err = r.ReadAccountData(addr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), a.Nonce)
+	v, err := r.ReadAccountStorage(addr, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, []byte{7}, v)
+	require.Equal(t, 1, db.reads)
+
+	// Readers of the latest state bypass the cache during changes
+	latest := &countingReader{StateReader: NewPlainStateReader(tx)}
+	require.Equal(t, latest, c.Reader(latest, 0, common.Hash{}))
+	c.EndWrite(1, hash1)
+
+	// Readers of the head share entries, readers behind bypass the cache
+	r = c.Reader(latest, 1, hash1)
+	a, err = r.ReadAccountData(addr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), a.Nonce)
+	require.Equal(t, 0, latest.reads)
+	require.Equal(t, latest, c.Reader(latest, 0, common.Hash{}))
+
+	// Unwind of the block 1 evicts its changes
+	c.BeginWrite(1, hash1)
+	require.NoError(t, tx.Delete(kv.PlainState, addr[:], nil))
+	c.Evict(addr[:])
+	c.EndWrite(0, common.Hash{})
+	r = c.Reader(latest, 0, common.Hash{})
+	a, err = r.ReadAccountData(addr)
+	require.NoError(t, err)
+	require.Nil(t, a)
+	require.Equal(t, 1, latest.reads)
+	v, err = r.ReadAccountStorage(addr, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, []byte{7}, v)
+	require.Equal(t, 1, latest.reads)
+
+	// Another block at the same height, or a failed change, makes the cache forget entries
+	c.BeginWrite(1, hash2)
+	c.BeginWrite(1, fork)
+	c.EndWrite(2, hash2)
+	r = c.Reader(latest, 2, hash2)
+	_, err = r.ReadAccountStorage(addr, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, 2, latest.reads)
+
+	// Readers of a newer head take the cache over from the old one
+	r = c.Reader(latest, 3, common.HexToHash("0xb3"))
+	_, err = r.ReadAccountStorage(addr, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, 3, latest.reads)
+	stale := r
+	c.BeginWrite(3, common.HexToHash("0xb3"))
+	_, err = stale.ReadAccountStorage(addr, 1, &key)
+	require.NoError(t, err)
+	require.Equal(t, 4, latest.reads, "readers of the previous generation must not see changes")
+
+	var disabled *SharedCache
+	require.Equal(t, latest, disabled.Reader(latest, 3, common.Hash{}))
+	_, err = NewSharedCache(SharedCacheConfig{Policy: "fifo", Accounts: 1})
+	require.Error(t, err)
+}
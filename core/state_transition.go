@@ -337,6 +337,10 @@ func (st *StateTransition) TransitionDb(refunds bool, gasBailout bool) (*Executi
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value, bailout)
 	}
+	if err := st.evm.LimitErr(); err != nil {
+		// The execution exceeded a limit of RPC, which is stricter than the consensus one, its result is meaningless
+		return nil, err
+	}
 	if refunds {
 		if london {
 			// After EIP-3529: refunds are capped to gasUsed / 5
@@ -20,6 +20,7 @@ import (
 	"container/heap"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon/common"
@@ -450,6 +451,55 @@ func (h *priceHeap) Pop() interface{} {
 	return x
 }
 
+// tipItem is a remote transaction with its effective tip under the base fee of the tipHeap
+type tipItem struct {
+	tx    types.Transaction
+	tip   *uint256.Int
+	added time.Time
+}
+
+// tipHeap is a heap.Interface implementation over transactions for retrieving the ones
+// with the lowest effective tip under the base fee, then the oldest, to evict when the pool
+// is over its memory limit. It's rebuilt when the base fee changes.
+type tipHeap struct {
+	baseFee *uint256.Int // nil before London
+	items   []tipItem
+}
+
+func (h *tipHeap) Len() int      { return len(h.items) }
+func (h *tipHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *tipHeap) Less(i, j int) bool {
+	switch h.items[i].tip.Cmp(h.items[j].tip) {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	return h.items[i].added.Before(h.items[j].added)
+}
+
+func (h *tipHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(tipItem))
+}
+
+func (h *tipHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = tipItem{}
+	h.items = old[0 : n-1]
+	return x
+}
+
+// sameBaseFee reports whether the heap is ordered by the given base fee
+func (h *tipHeap) sameBaseFee(baseFee *uint256.Int) bool {
+	if h.baseFee == nil || baseFee == nil {
+		return h.baseFee == nil && baseFee == nil
+	}
+	return h.baseFee.Eq(baseFee)
+}
+
 // txPricedList is a price-sorted heap to allow operating on transactions pool
 // contents in a price-incrementing way. It's built opon the all transactions
 // in txpool but only interested in the remote part. It means only remote transactions
@@ -457,6 +507,7 @@ func (h *priceHeap) Pop() interface{} {
 type txPricedList struct {
 	all     *txLookup  // Pointer to the map of all transactions
 	remotes *priceHeap // Heap of prices of all the stored **remote** transactions
+	tips    *tipHeap   // Heap of effective tips of the same transactions, for DiscardBytes
 	stales  int        // Number of stale price points to (re-heap trigger)
 }
 
@@ -465,6 +516,7 @@ func newTxPricedList(all *txLookup) *txPricedList {
 	return &txPricedList{
 		all:     all,
 		remotes: new(priceHeap),
+		tips:    new(tipHeap),
 	}
 }
 
@@ -474,6 +526,7 @@ func (l *txPricedList) Put(tx types.Transaction, local bool) {
 		return
 	}
 	heap.Push(l.remotes, tx)
+	heap.Push(l.tips, tipItem{tx: tx, tip: tx.GetEffectiveGasTip(l.tips.baseFee), added: l.all.Added(tx.Hash())})
 }
 
 // Removed notifies the prices transaction list that an old transaction dropped
@@ -563,6 +616,48 @@ func (l *txPricedList) Discard(slots int, force bool) (types.Transactions, bool)
 	return drop, true
 }
 
+// DiscardBytes finds remote transactions to free at least the given amount of bytes,
+// the lowest effective tip under the given base fee first, then the oldest, removes them
+// from the tip heap and returns them for further removal from the entire pool.
+// Transactions paying more than maxTip are not discarded (nil - any are). Unless forced,
+// nothing is discarded if not enough bytes can be freed: ErrTxPoolOverflow if the
+// remotes are too small, ErrUnderpriced if they pay more than maxTip.
+//
+// Note local transaction won't be considered for eviction.
+func (l *txPricedList) DiscardBytes(bytes int, baseFee, maxTip *uint256.Int, force bool) (types.Transactions, error) {
+	if !l.tips.sameBaseFee(baseFee) {
+		l.reheapTips(baseFee)
+	}
+	var drop []tipItem
+	err := ErrTxPoolOverflow
+	for l.tips.Len() > 0 && bytes > 0 {
+		cheapest := l.tips.items[0]
+		if l.all.GetRemote(cheapest.tx.Hash()) == nil { // Removed or migrated
+			heap.Pop(l.tips)
+			continue
+		}
+		if maxTip != nil && cheapest.tip.Gt(maxTip) {
+			err = ErrUnderpriced
+			break
+		}
+		heap.Pop(l.tips)
+		drop = append(drop, cheapest)
+		bytes -= int(cheapest.tx.Size())
+	}
+	// If we still can't make enough room for the new transaction
+	if bytes > 0 && !force {
+		for _, item := range drop {
+			heap.Push(l.tips, item)
+		}
+		return nil, err
+	}
+	txs := make(types.Transactions, len(drop))
+	for i, item := range drop {
+		txs[i] = item.tx
+	}
+	return txs, nil
+}
+
 // Reheap forcibly rebuilds the heap based on the current remote transaction set.
 func (l *txPricedList) Reheap() {
 	reheap := make(priceHeap, 0, l.all.RemoteCount())
@@ -573,4 +668,14 @@ func (l *txPricedList) Reheap() {
 		return true
 	}, false, true) // Only iterate remotes
 	heap.Init(l.remotes)
+	l.reheapTips(l.tips.baseFee)
+}
+
+// reheapTips rebuilds the tip heap of the current remote transactions under the given base fee.
+func (l *txPricedList) reheapTips(baseFee *uint256.Int) {
+	l.tips = &tipHeap{baseFee: baseFee, items: make([]tipItem, 0, l.all.RemoteCount())}
+	l.all.RangeRemotesAdded(func(tx types.Transaction, added time.Time) {
+		l.tips.items = append(l.tips.items, tipItem{tx: tx, tip: tx.GetEffectiveGasTip(baseFee), added: added})
+	})
+	heap.Init(l.tips)
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
 )
 
 // Tests that transactions can be added to strict lists and list contents and
@@ -69,3 +70,36 @@ func BenchmarkTxListAdd(t *testing.B) {
 		list.Filter(priceLimit, DefaultTxPoolConfig.PriceBump)
 	}
 }
+
+// Tests that transactions are discarded by bytes the lowest effective tip first,
+// under the current base fee, and that nothing is discarded if not enough can be.
+func TestPricedListDiscardBytes(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	all := newTxLookup()
+	priced := newTxPricedList(all)
+	a := dynamicFeeTx(0, 100000, uint256.NewInt(10), uint256.NewInt(1), key)
+	b := dynamicFeeTx(1, 100000, uint256.NewInt(20), uint256.NewInt(5), key)
+	c := dynamicFeeTx(2, 100000, uint256.NewInt(6), uint256.NewInt(6), key)
+	for _, tx := range []types.Transaction{a, b, c} {
+		all.Add(tx, false)
+		priced.Put(tx, false)
+	}
+
+	_, err := priced.DiscardBytes(1, nil, uint256.NewInt(0), false)
+	require.Equal(t, ErrUnderpriced, err)
+	_, err = priced.DiscardBytes(all.Bytes()+1, nil, nil, false)
+	require.Equal(t, ErrTxPoolOverflow, err)
+	require.Equal(t, 3, priced.tips.Len())
+
+	// under the base fee of 4 c pays the tip of 2, less than b
+	drop, err := priced.DiscardBytes(int(a.Size())+1, uint256.NewInt(4), nil, false)
+	require.NoError(t, err)
+	require.Equal(t, types.Transactions{a, c}, drop)
+
+	// removed transactions are skipped
+	all.Remove(b.Hash())
+	drop, err = priced.DiscardBytes(1, uint256.NewInt(4), nil, true)
+	require.NoError(t, err)
+	require.Empty(t, drop)
+	require.Equal(t, 0, priced.tips.Len())
+}
@@ -19,6 +19,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"sort"
 	"sync"
 	"time"
@@ -115,10 +116,15 @@ var (
 	underpricedTxMeter = metrics.GetOrCreateCounter("txpool_underpriced")
 	overflowedTxMeter  = metrics.GetOrCreateCounter("txpool_overflowed")
 
+	// Metrics of eviction under memory pressure (GlobalBytes)
+	memoryEvictionMeter      = metrics.GetOrCreateCounter("txpool_memory_eviction")
+	memoryEvictionBytesMeter = metrics.GetOrCreateCounter("txpool_memory_eviction_bytes")
+
 	pendingGauge = metrics.GetOrCreateCounter("txpool_pending")
 	queuedGauge  = metrics.GetOrCreateCounter("txpool_queued")
 	localGauge   = metrics.GetOrCreateCounter("txpool_local")
 	slotsGauge   = metrics.GetOrCreateCounter("txpool_slots")
+	bytesGauge   = metrics.GetOrCreateCounter("txpool_bytes")
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -138,6 +144,9 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	Snapshot         string        // Snapshot of all pool transactions to quickly refill the pool after restart
+	SnapshotInterval time.Duration // Time interval to regenerate the snapshot
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -145,6 +154,7 @@ type TxPoolConfig struct {
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+	GlobalBytes  uint64 // Maximum total size of all transactions, 0 - no limit
 
 	Lifetime    time.Duration // Maximum amount of time non-executable transaction are queued
 	StartOnInit bool
@@ -156,6 +166,9 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	Snapshot:         "txpool.rlp",
+	SnapshotInterval: 5 * time.Minute,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -163,6 +176,7 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalSlots:  4096,
 	AccountQueue: 64,
 	GlobalQueue:  1024,
+	GlobalBytes:  64 * 1024 * 1024,
 
 	Lifetime: 3 * time.Hour,
 }
@@ -175,6 +189,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.SnapshotInterval < time.Second {
+		log.Warn("Sanitizing invalid txpool snapshot interval", "provided", conf.SnapshotInterval, "updated", time.Second)
+		conf.SnapshotInterval = time.Second
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -199,6 +217,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool global queue", "provided", conf.GlobalQueue, "updated", DefaultTxPoolConfig.GlobalQueue)
 		conf.GlobalQueue = DefaultTxPoolConfig.GlobalQueue
 	}
+	if conf.GlobalBytes != 0 && conf.GlobalBytes < txMaxSize {
+		log.Warn("Sanitizing invalid txpool global bytes", "provided", conf.GlobalBytes, "updated", txMaxSize)
+		conf.GlobalBytes = txMaxSize
+	}
 	if conf.Lifetime < 1 {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
@@ -230,9 +252,11 @@ type TxPool struct {
 	pendingNonces *txNoncer              // Pending state tracking virtual nonces
 	currentState  *state.IntraBlockState // Current state in the blockchain head
 	currentMaxGas uint64                 // Current gas limit for transaction caps
+	baseFee       *uint256.Int           // Base fee of the head block, nil before London
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals   *accountSet // Set of local transaction to exempt from eviction rules
+	journal  *txJournal  // Journal of local transaction to back up to disk
+	snapshot *txSnapshot // Snapshot of all transactions to back up to disk
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -305,6 +329,16 @@ func (pool *TxPool) Start(gasLimit uint64, headNumber uint64) error {
 	pool.wg.Add(1)
 	go pool.scheduleReorgLoop()
 
+	// Snapshot is loaded before the journal, so journaled local transactions are not reported as dropped duplicates
+	if pool.config.Snapshot != "" {
+		pool.snapshot = newTxSnapshot(pool.config.Snapshot)
+		// transactions of the snapshot were validated when they entered the pool
+		addLocals := func(txs []types.Transaction) []error { return pool.addTxs(txs, !pool.config.NoLocals, true, true) }
+		addRemotes := func(txs []types.Transaction) []error { return pool.addTxs(txs, false, true, true) }
+		if err := pool.snapshot.load(addLocals, addRemotes); err != nil {
+			log.Warn("Failed to load transaction pool snapshot", "err", err)
+		}
+	}
 	// If local transactions and journaling is enabled, load from disk
 	if !pool.config.NoLocals && pool.config.Journal != "" {
 		pool.journal = newTxJournal(pool.config.Journal)
@@ -336,13 +370,15 @@ func (pool *TxPool) loop() {
 	var (
 		prevPending, prevQueued, prevStales int
 		// Start the stats reporting and transaction eviction tickers
-		report  = time.NewTicker(statsReportInterval)
-		evict   = time.NewTicker(evictionInterval)
-		journal = time.NewTicker(pool.config.Rejournal)
+		report   = time.NewTicker(statsReportInterval)
+		evict    = time.NewTicker(evictionInterval)
+		journal  = time.NewTicker(pool.config.Rejournal)
+		snapshot = time.NewTicker(pool.config.SnapshotInterval)
 	)
 	defer report.Stop()
 	defer evict.Stop()
 	defer journal.Stop()
+	defer snapshot.Stop()
 
 	for {
 		select {
@@ -392,10 +428,38 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+
+		// Handle transaction pool snapshot regeneration
+		case <-snapshot.C:
+			pool.writeSnapshot()
 		}
 	}
 }
 
+// writeSnapshot dumps all pending and queued transactions to the snapshot file
+func (pool *TxPool) writeSnapshot() {
+	if pool.snapshot == nil {
+		return
+	}
+	pool.mu.RLock()
+	all := make(map[common.Address]types.Transactions, len(pool.pending)+len(pool.queue))
+	for addr, list := range pool.pending {
+		all[addr] = list.Flatten()
+	}
+	for addr, list := range pool.queue {
+		all[addr] = append(all[addr], list.Flatten()...)
+	}
+	locals := make(map[common.Address]bool, len(pool.locals.accounts))
+	for addr := range pool.locals.accounts {
+		locals[addr] = true
+	}
+	pool.mu.RUnlock()
+
+	if err := pool.snapshot.write(all, func(addr common.Address) bool { return locals[addr] }); err != nil {
+		log.Warn("Failed to write transaction pool snapshot", "err", err)
+	}
+}
+
 func (pool *TxPool) resetHead(blockGasLimit uint64, blockNumber uint64) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
@@ -409,6 +473,18 @@ func (pool *TxPool) resetHead(blockGasLimit uint64, blockNumber uint64) {
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
 }
 
+// SetBaseFee sets base fee of the head block, it's used to compute effective tips of transactions
+// when the pool has to evict them under memory pressure
+func (pool *TxPool) SetBaseFee(baseFee *big.Int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if baseFee == nil {
+		pool.baseFee = nil
+		return
+	}
+	pool.baseFee, _ = uint256.FromBig(baseFee)
+}
+
 func (pool *TxPool) ResetHead(blockGasLimit uint64, blockNumber uint64) {
 	pool.resetHead(blockGasLimit, blockNumber)
 	<-pool.requestReset(nil, nil)
@@ -428,6 +504,7 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	pool.writeSnapshot()
 
 	pool.isStarted = false
 
@@ -589,32 +666,36 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
-func (pool *TxPool) validateTx(tx types.Transaction, local bool) error {
-	// Accept only legacy transactions until EIP-2718/2930 activates.
-	if !pool.eip2718 && tx.Type() != types.LegacyTxType {
-		return ErrTxTypeNotSupported
-	}
-	// Reject transactions over defined size to prevent DOS attacks
-	if uint64(tx.Size()) > txMaxSize {
-		return ErrOversizedData
-	}
-	// Transactions can't be negative. This may never happen using RLP decoded
-	// transactions but may occur if you create a transaction using the RPC.
-	if tx.GetValue().Sign() < 0 {
-		return ErrNegativeValue
+func (pool *TxPool) validateTx(tx types.Transaction, local, validated bool) error {
+	if !validated {
+		// Accept only legacy transactions until EIP-2718/2930 activates.
+		if !pool.eip2718 && tx.Type() != types.LegacyTxType {
+			return ErrTxTypeNotSupported
+		}
+		// Reject transactions over defined size to prevent DOS attacks
+		if uint64(tx.Size()) > txMaxSize {
+			return ErrOversizedData
+		}
+		// Transactions can't be negative. This may never happen using RLP decoded
+		// transactions but may occur if you create a transaction using the RPC.
+		if tx.GetValue().Sign() < 0 {
+			return ErrNegativeValue
+		}
 	}
 	// Ensure the transaction doesn't exceed the current block limit gas.
 	if pool.currentMaxGas < tx.GetGas() {
 		return ErrGasLimit
 	}
-	// Sanity check for extremely large numbers
-	if tx.GetFeeCap().BitLen() > 256 {
-		return ErrFeeCapVeryHigh
-	}
-	if tx.GetTip().BitLen() > 256 {
-		return ErrTipVeryHigh
+	if !validated {
+		// Sanity check for extremely large numbers
+		if tx.GetFeeCap().BitLen() > 256 {
+			return ErrFeeCapVeryHigh
+		}
+		if tx.GetTip().BitLen() > 256 {
+			return ErrTipVeryHigh
+		}
 	}
-	// Make sure the transaction is signed properly.
+	// Make sure the transaction is signed properly. Validated transactions have the sender set.
 	from, err := tx.Sender(*pool.signer)
 	if err != nil {
 		return ErrInvalidSender
@@ -632,6 +713,9 @@ func (pool *TxPool) validateTx(tx types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	if validated {
+		return nil
+	}
 	// Ensure the transaction has more gas than the basic tx fee.
 	intrGas, err := IntrinsicGas(tx.GetData(), tx.GetAccessList(), tx.GetTo() == nil, true, pool.istanbul)
 	if err != nil {
@@ -651,6 +735,12 @@ func (pool *TxPool) validateTx(tx types.Transaction, local bool) error {
 // whitelisted, preventing any associated transaction from being dropped out of the pool
 // due to pricing constraints.
 func (pool *TxPool) add(tx types.Transaction, local bool) (replaced bool, err error) {
+	return pool.addValidated(tx, local, false)
+}
+
+// addValidated is add, which skips the checks independent of the head and the state (size, signature, intrinsic
+// gas and so on) if the transaction passed them before, see addTxs.
+func (pool *TxPool) addValidated(tx types.Transaction, local, validated bool) (replaced bool, err error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
@@ -664,7 +754,7 @@ func (pool *TxPool) add(tx types.Transaction, local bool) (replaced bool, err er
 
 	// If the transaction fails basic validation, discard it
 	if pool.currentState != nil {
-		if err = pool.validateTx(tx, isLocal); err != nil {
+		if err = pool.validateTx(tx, isLocal, validated); err != nil {
 			log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 			invalidTxMeter.Set(1)
 			return false, err
@@ -696,6 +786,31 @@ func (pool *TxPool) add(tx types.Transaction, local bool) (replaced bool, err er
 			pool.removeTxLocked(tx.Hash(), false)
 		}
 	}
+	// If the pool is over its memory limit, evict transactions with the lowest effective tip
+	if limit := pool.config.GlobalBytes; limit > 0 && uint64(pool.all.Bytes())+uint64(tx.Size()) > limit {
+		// Remote transaction can't push out transactions, which pay more than it
+		var maxTip *uint256.Int
+		if !isLocal {
+			maxTip = tx.GetEffectiveGasTip(pool.baseFee)
+		}
+		drop, err := pool.priced.DiscardBytes(int(uint64(pool.all.Bytes())+uint64(tx.Size())-limit), pool.baseFee, maxTip, isLocal)
+		switch err {
+		case ErrTxPoolOverflow:
+			log.Trace("Discarding overflown transaction", "hash", hash, "poolBytes", pool.all.Bytes())
+			overflowedTxMeter.Set(1)
+			return false, err
+		case ErrUnderpriced:
+			log.Trace("Discarding underpriced transaction", "hash", hash, "tip", maxTip)
+			underpricedTxMeter.Set(1)
+			return false, err
+		}
+		for _, tx := range drop {
+			log.Trace("Evicting transaction under memory pressure", "hash", tx.Hash(), "tip", tx.GetEffectiveGasTip(pool.baseFee), "size", tx.Size())
+			memoryEvictionMeter.Inc()
+			memoryEvictionBytesMeter.Add(int(tx.Size()))
+			pool.removeTxLocked(tx.Hash(), true)
+		}
+	}
 	// Try to replace an existing transaction in the pending pool
 	from, _ := tx.Sender(*pool.signer) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
@@ -835,7 +950,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx types.Tr
 // This method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, !pool.config.NoLocals, true, false)
 }
 
 func (pool *TxPool) IsLocalTx(txHash common.Hash) bool {
@@ -855,12 +970,12 @@ func (pool *TxPool) AddLocal(tx types.Transaction) error {
 // This method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, false, false, false)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this method.
 func (pool *TxPool) AddRemotesSync(txs []types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, false, true, false)
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this method.
@@ -878,8 +993,9 @@ func (pool *TxPool) AddRemote(tx types.Transaction) error {
 	return errs[0]
 }
 
-// addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []types.Transaction, local, sync bool) []error {
+// addTxs attempts to queue a batch of transactions if they are valid. Transactions, which were validated when they
+// entered the pool before (reloaded from the snapshot), are checked only against the current head and state.
+func (pool *TxPool) addTxs(txs []types.Transaction, local, sync, validated bool) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -910,7 +1026,7 @@ func (pool *TxPool) addTxs(txs []types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, local, validated)
 	pool.mu.Unlock()
 	var nilSlot = 0
 	for _, err := range newErrs {
@@ -930,11 +1046,11 @@ func (pool *TxPool) addTxs(txs []types.Transaction, local, sync bool) []error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []types.Transaction, local, validated bool) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
-		replaced, err := pool.add(tx, local)
+		replaced, err := pool.addValidated(tx, local, validated)
 		errs[i] = err
 		if err == nil && !replaced {
 			dirty.addTx(tx)
@@ -1615,9 +1731,11 @@ func (as *accountSet) merge(other *accountSet) {
 // to build upper-level structure.
 type txLookup struct {
 	slots   int
+	bytes   int
 	lock    sync.RWMutex
 	locals  map[common.Hash]types.Transaction
 	remotes map[common.Hash]types.Transaction
+	added   map[common.Hash]time.Time // when transactions were added, to evict the oldest first
 }
 
 // newTxLookup returns a new txLookup structure.
@@ -1625,6 +1743,7 @@ func newTxLookup() *txLookup {
 	return &txLookup{
 		locals:  make(map[common.Hash]types.Transaction),
 		remotes: make(map[common.Hash]types.Transaction),
+		added:   make(map[common.Hash]time.Time),
 	}
 }
 
@@ -1710,6 +1829,32 @@ func (t *txLookup) Slots() int {
 	return t.slots
 }
 
+// Bytes returns the current total size of transactions in the lookup.
+func (t *txLookup) Bytes() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.bytes
+}
+
+// Added returns the time the transaction was added to the lookup.
+func (t *txLookup) Added(hash common.Hash) time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.added[hash]
+}
+
+// RangeRemotesAdded calls f on each remote transaction with the time it was added to the lookup.
+func (t *txLookup) RangeRemotesAdded(f func(tx types.Transaction, added time.Time)) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for hash, tx := range t.remotes {
+		f(tx, t.added[hash])
+	}
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx types.Transaction, local bool) {
 	t.lock.Lock()
@@ -1717,6 +1862,9 @@ func (t *txLookup) Add(tx types.Transaction, local bool) {
 
 	t.slots += numSlots(tx)
 	slotsGauge.Set(uint64(t.slots))
+	t.bytes += int(tx.Size())
+	bytesGauge.Set(uint64(t.bytes))
+	t.added[tx.Hash()] = time.Now()
 
 	if local {
 		t.locals[tx.Hash()] = tx
@@ -1740,9 +1888,12 @@ func (t *txLookup) Remove(hash common.Hash) {
 	}
 	t.slots -= numSlots(tx)
 	slotsGauge.Set(uint64(t.slots))
+	t.bytes -= int(tx.Size())
+	bytesGauge.Set(uint64(t.bytes))
 
 	delete(t.locals, hash)
 	delete(t.remotes, hash)
+	delete(t.added, hash)
 }
 
 // RemoteToLocals migrates the transactions belongs to the given locals to locals
@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -49,6 +50,7 @@ var eip1559Config *params.ChainConfig
 func init() {
 	TestTxPoolConfig = DefaultTxPoolConfig
 	TestTxPoolConfig.Journal = ""
+	TestTxPoolConfig.Snapshot = ""
 	TestTxPoolConfig.StartOnInit = true
 
 	cpy := *params.TestChainConfig
@@ -266,6 +268,25 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that transactions reloaded from the snapshot skip the stateless checks,
+// but are still checked against the current state.
+func TestValidatedTransactions(t *testing.T) {
+	pool, key := setupTxPool(t)
+
+	tx := transaction(0, 100, key) // below the intrinsic gas, never passes the full validation
+	from, _ := deriveSender(tx)
+	pool.currentState.AddBalance(from, uint256.NewInt(0xffffffffffffff))
+	require.Equal(t, ErrIntrinsicGas, pool.addTxs([]types.Transaction{tx}, false, true, false)[0])
+
+	pool.currentState.SetNonce(from, 1)
+	require.Equal(t, ErrNonceTooLow, pool.addTxs([]types.Transaction{tx}, false, true, true)[0])
+
+	tx = transaction(1, 100, key)
+	require.NoError(t, pool.addTxs([]types.Transaction{tx}, false, true, true)[0])
+	pending, _ := pool.Stats()
+	require.Equal(t, 1, pending)
+}
+
 func newInt(value int64) *uint256.Int {
 	v, _ := uint256.FromBig(big.NewInt(value))
 	return v
@@ -1551,6 +1572,69 @@ func TestTransactionPoolStableUnderpricing(t *testing.T) {
 	}
 }
 
+// Tests that when the pool is over its memory limit, transactions with the lowest
+// effective tip are evicted first, then the oldest ones, and that cheaper remote
+// transactions can't push out the better paying ones.
+func TestTransactionPoolMemoryEviction(t *testing.T) {
+	db := memdb.NewTestDB(t)
+
+	// Three transactions with 40KB of data fit into the pool, the fourth doesn't
+	config := TestTxPoolConfig
+	config.GlobalBytes = 128 * 1024
+
+	pool := NewTxPool(config, params.TestChainConfig, db)
+	if err := pool.Start(1000000000, 0); err != nil {
+		t.Fatalf("starting tx pool: %v", err)
+	}
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 7)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), uint256.NewInt(1000000000))
+	}
+	txs := make([]types.Transaction, len(keys))
+	for i, price := range []uint64{1, 2, 3, 1, 2, 1, 1} {
+		txs[i] = pricedDataTransaction(0, 1000000, uint256.NewInt(price), keys[i], 40000)
+	}
+	for i := 0; i < 3; i++ {
+		if err := pool.addRemoteSync(txs[i]); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// same tip as the cheapest one, pushes out the older one
+	if err := pool.addRemoteSync(txs[3]); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if pool.Has(txs[0].Hash()) || !pool.Has(txs[3].Hash()) {
+		t.Fatalf("oldest cheapest transaction is not evicted")
+	}
+	// the cheapest one goes first
+	if err := pool.addRemoteSync(txs[4]); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if pool.Has(txs[3].Hash()) || !pool.Has(txs[1].Hash()) {
+		t.Fatalf("cheapest transaction is not evicted")
+	}
+	// all remaining transactions pay more
+	if err := pool.addRemoteSync(txs[5]); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// but local transactions are always accepted
+	if err := pool.AddLocal(txs[6]); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if pool.Has(txs[1].Hash()) || !pool.Has(txs[4].Hash()) || !pool.Has(txs[2].Hash()) {
+		t.Fatalf("oldest cheapest transaction is not evicted for the local one")
+	}
+	if uint64(pool.all.Bytes()) > config.GlobalBytes {
+		t.Fatalf("pool is over the limit: have %d bytes, limit %d", pool.all.Bytes(), config.GlobalBytes)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that the pool rejects duplicate transactions.
 func TestTransactionDeduplication(t *testing.T) {
 	db := memdb.NewTestDB(t)
@@ -1846,6 +1930,64 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	}
 }
 
+// Tests that the transaction pool snapshot restores both local and remote transactions
+// after restart, and drops the ones which became invalid in the meantime.
+func TestTransactionSnapshot(t *testing.T) {
+	t.Parallel()
+
+	db := memdb.NewTestDB(t)
+	config := TestTxPoolConfig
+	config.Snapshot = filepath.Join(t.TempDir(), "txpool.rlp")
+
+	pool := NewTxPool(config, params.TestChainConfig, db)
+	if err := pool.Start(1000000000, 0); err != nil {
+		t.Fatalf("starting tx pool: %v", err)
+	}
+	defer pool.Stop()
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		stateWriter := state.NewPlainStateWriter(tx, nil, 1)
+		ibs := state.New(state.NewPlainStateReader(tx))
+		ibs.AddBalance(crypto.PubkeyToAddress(local.PublicKey), uint256.NewInt(1000000000))
+		ibs.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), uint256.NewInt(1000000000))
+		return ibs.CommitBlock(params.Rules{}, stateWriter)
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, pool.AddLocal(pricedTransaction(0, 100000, u256.Num1, local)))
+	require.NoError(t, pool.addRemoteSync(pricedTransaction(0, 100000, u256.Num1, remote)))
+	require.NoError(t, pool.addRemoteSync(pricedTransaction(1, 100000, u256.Num1, remote)))
+	require.NoError(t, pool.addRemoteSync(pricedTransaction(3, 100000, u256.Num1, remote)))
+	pending, queued := pool.Stats()
+	require.Equal(t, 3, pending)
+	require.Equal(t, 1, queued)
+
+	// Terminate the old pool, include first remote transaction, create a new pool and ensure the rest survive
+	pool.Stop()
+	err = db.Update(context.Background(), func(tx kv.RwTx) error {
+		stateWriter := state.NewPlainStateWriter(tx, nil, 1)
+		ibs := state.New(state.NewPlainStateReader(tx))
+		ibs.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+		return ibs.CommitBlock(params.Rules{}, stateWriter)
+	})
+	require.NoError(t, err)
+
+	pool = NewTxPool(config, params.TestChainConfig, db)
+	if err := pool.Start(1000000000, 0); err != nil {
+		t.Fatalf("starting tx pool: %v", err)
+	}
+	pending, queued = pool.Stats()
+	require.Equal(t, 2, pending)
+	require.Equal(t, 1, queued)
+	require.True(t, pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)))
+	require.False(t, pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)))
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// txSnapshotVersion is written at the beginning of the snapshot, snapshots of other versions are ignored
+const txSnapshotVersion = 1
+
+// txSnapshotEntry is a validated transaction together with the metadata needed to re-add it without validation
+type txSnapshotEntry struct {
+	Hash   common.Hash
+	Sender common.Address
+	Local  bool
+	Tx     []byte
+}
+
+// txSnapshot periodically dumps the whole content of the pool (unlike txJournal - not only local transactions),
+// so busy nodes don't restart with empty pools. Senders are stored along with transactions,
+// so on load signatures of unchanged transactions don't need to be recovered again.
+type txSnapshot struct {
+	path string // Filesystem path to store the snapshot at
+}
+
+func newTxSnapshot(path string) *txSnapshot {
+	return &txSnapshot{path: path}
+}
+
+// load reads the snapshot and adds its transactions to the pool, locals and remotes separately
+func (snapshot *txSnapshot) load(addLocals, addRemotes func([]types.Transaction) []error) error {
+	input, err := os.Open(snapshot.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(bufio.NewReader(input), 0)
+	version, err := stream.Uint()
+	if err != nil {
+		return err
+	}
+	if version != txSnapshotVersion {
+		log.Warn("Ignoring transaction pool snapshot of unsupported version", "version", version)
+		return nil
+	}
+
+	var locals, remotes []types.Transaction
+	total, corrupted := 0, 0
+	for {
+		var entry txSnapshotEntry
+		if err = stream.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		total++
+		tx, err := types.DecodeTransaction(rlp.NewStream(bytes.NewReader(entry.Tx), uint64(len(entry.Tx))))
+		if err != nil || tx.Hash() != entry.Hash {
+			corrupted++
+			continue
+		}
+		tx.SetSender(entry.Sender)
+		if entry.Local {
+			locals = append(locals, tx)
+		} else {
+			remotes = append(remotes, tx)
+		}
+	}
+
+	dropped := 0
+	for _, errs := range [][]error{addLocals(locals), addRemotes(remotes)} {
+		for _, err := range errs {
+			if err != nil {
+				log.Debug("Failed to add transaction from snapshot", "err", err)
+				dropped++
+			}
+		}
+	}
+	log.Info("Loaded transaction pool snapshot", "transactions", total, "corrupted", corrupted, "dropped", dropped)
+	return nil
+}
+
+// write replaces the snapshot on disk with given transactions, grouped by sender
+func (snapshot *txSnapshot) write(all map[common.Address]types.Transactions, isLocal func(common.Address) bool) error {
+	tmp := snapshot.path + ".new"
+	output, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(output)
+	written, err := snapshot.encode(w, all, isLocal)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, snapshot.path); err != nil {
+		return err
+	}
+	log.Debug("Written transaction pool snapshot", "transactions", written, "accounts", len(all))
+	return nil
+}
+
+func (snapshot *txSnapshot) encode(w io.Writer, all map[common.Address]types.Transactions, isLocal func(common.Address) bool) (int, error) {
+	if err := rlp.Encode(w, uint(txSnapshotVersion)); err != nil {
+		return 0, err
+	}
+	written := 0
+	for sender, txs := range all {
+		local := isLocal(sender)
+		for _, tx := range txs {
+			enc, err := rlp.EncodeToBytes(tx)
+			if err != nil {
+				return written, fmt.Errorf("encoding transaction %x: %w", tx.Hash(), err)
+			}
+			if err = rlp.Encode(w, &txSnapshotEntry{Hash: tx.Hash(), Sender: sender, Local: local, Tx: enc}); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	return written, nil
+}
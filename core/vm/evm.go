@@ -60,6 +60,32 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 	return p, ok
 }
 
+// depthExceeded checks the consensus call depth limit, and the stricter one of the config. Exceeding the latter
+// aborts the whole execution, see limitExceeded
+func (evm *EVM) depthExceeded() bool {
+	if evm.Config.MaxCallDepth > 0 && evm.depth > evm.Config.MaxCallDepth && evm.depth <= int(params.CallCreateDepth) {
+		evm.limitExceeded(ErrDepth)
+		return true
+	}
+	return evm.depth > int(params.CallCreateDepth)
+}
+
+// limitExceeded aborts the execution, because it exceeds a limit of the config, which is stricter than the consensus
+// one. Otherwise the parent frame would see an ordinary failed call and go on, and the result would differ from the
+// consensus execution.
+func (evm *EVM) limitExceeded(err error) {
+	if evm.limitErr == nil {
+		evm.limitErr = err
+	}
+	evm.Cancel()
+}
+
+// LimitErr returns ErrMemoryLimit or ErrDepth if the execution was aborted, because it exceeded MaxMemory or
+// MaxCallDepth of the config, nil otherwise
+func (evm *EVM) LimitErr() error {
+	return evm.limitErr
+}
+
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
 	interpreter := evm.interpreter
@@ -143,6 +169,10 @@ type EVM struct {
 	// abort is used to abort the EVM calling operations
 	// NOTE: must be set atomically
 	abort int32
+	// memory is the total size of memory of active call frames, tracked if Config.MaxMemory is set
+	memory uint64
+	// limitErr is set, when the execution exceeds a limit of the config, see limitExceeded
+	limitErr error
 	// callGasTemp holds the gas available for the current call. This is needed because the
 	// available gas is calculated in gasCall* according to the 63/64 rule and later
 	// applied in opCall*.
@@ -201,8 +231,12 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if evm.Config.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	// The execution is being aborted, because it exceeded a limit of the config
+	if evm.limitErr != nil {
+		return nil, gas, evm.limitErr
+	}
 	// Fail if we're trying to execute above the call depth limit
-	if evm.depth > int(params.CallCreateDepth) {
+	if evm.depthExceeded() {
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -283,8 +317,12 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	if evm.Config.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	// The execution is being aborted, because it exceeded a limit of the config
+	if evm.limitErr != nil {
+		return nil, gas, evm.limitErr
+	}
 	// Fail if we're trying to execute above the call depth limit
-	if evm.depth > int(params.CallCreateDepth) {
+	if evm.depthExceeded() {
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -343,8 +381,12 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	if evm.Config.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	// The execution is being aborted, because it exceeded a limit of the config
+	if evm.limitErr != nil {
+		return nil, gas, evm.limitErr
+	}
 	// Fail if we're trying to execute above the call depth limit
-	if evm.depth > int(params.CallCreateDepth) {
+	if evm.depthExceeded() {
 		return nil, gas, ErrDepth
 	}
 	p, isPrecompile := evm.precompile(addr)
@@ -391,8 +433,12 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	if evm.Config.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	// The execution is being aborted, because it exceeded a limit of the config
+	if evm.limitErr != nil {
+		return nil, gas, evm.limitErr
+	}
 	// Fail if we're trying to execute above the call depth limit
-	if evm.depth > int(params.CallCreateDepth) {
+	if evm.depthExceeded() {
 		return nil, gas, ErrDepth
 	}
 	p, isPrecompile := evm.precompile(addr)
@@ -464,9 +510,13 @@ func (c *codeAndHash) Hash() common.Hash {
 func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *uint256.Int, address common.Address, calltype CallType) ([]byte, common.Address, uint64, error) {
 	var ret []byte
 	var err error
+	// The execution is being aborted, because it exceeded a limit of the config
+	if evm.limitErr != nil {
+		return nil, common.Address{}, gas, evm.limitErr
+	}
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
-	if evm.depth > int(params.CallCreateDepth) {
+	if evm.depthExceeded() {
 		return nil, common.Address{}, gas, ErrDepth
 	}
 	if !evm.Context.CanTransfer(evm.IntraBlockState, caller.Address(), value) {
@@ -38,6 +38,10 @@ type Config struct {
 	ReadOnly      bool   // Do no perform any block finalisation
 	EnableTEMV    bool   // true if execution with TEVM enable flag
 
+	// Limits for execution initiated by RPC, stricter than the consensus ones. Zero means no limit.
+	MaxMemory    uint64 // Total size of memory of all active call frames, in bytes
+	MaxCallDepth int    // Call depth, can only lower params.CallCreateDepth
+
 	ExtraEips []int // Additional EIPS that are to be enabled
 }
 
@@ -174,6 +178,9 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	defer func() {
 		stack.ReturnNormalStack(locStack)
 	}()
+	if in.cfg.MaxMemory > 0 {
+		defer func() { in.evm.memory -= uint64(mem.Len()) }()
+	}
 	contract.Input = input
 
 	if in.cfg.Debug {
@@ -261,6 +268,14 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 		}
 		if memorySize > 0 {
+			if in.cfg.MaxMemory > 0 && memorySize > uint64(mem.Len()) {
+				growth := memorySize - uint64(mem.Len())
+				if in.evm.memory+growth > in.cfg.MaxMemory {
+					in.evm.limitExceeded(ErrMemoryLimit)
+					return nil, ErrMemoryLimit
+				}
+				in.evm.memory += growth
+			}
 			mem.Resize(memorySize)
 		}
 
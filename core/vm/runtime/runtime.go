@@ -144,6 +144,10 @@ func Execute(code, input []byte, cfg *Config, blockNr uint64) ([]byte, *state.In
 		false, /* bailout */
 	)
 
+	if limitErr := vmenv.LimitErr(); limitErr != nil {
+		// Exceeded MaxMemory or MaxCallDepth of the config somewhere deeper, the whole execution is aborted
+		err = limitErr
+	}
 	return ret, cfg.State, err
 }
 
@@ -176,6 +180,10 @@ func Create(input []byte, cfg *Config, blockNr uint64) ([]byte, common.Address,
 		cfg.GasLimit,
 		cfg.Value,
 	)
+	if limitErr := vmenv.LimitErr(); limitErr != nil {
+		// Exceeded MaxMemory or MaxCallDepth of the config somewhere deeper, the whole execution is aborted
+		err = limitErr
+	}
 	return code, address, leftOverGas, err
 }
 
@@ -205,5 +213,9 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, er
 		false, /* bailout */
 	)
 
+	if limitErr := vmenv.LimitErr(); limitErr != nil {
+		// Exceeded MaxMemory or MaxCallDepth of the config somewhere deeper, the whole execution is aborted
+		err = limitErr
+	}
 	return ret, leftOverGas, err
 }
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv/memdb"
 	"github.com/ledgerwatch/erigon/accounts/abi"
 	"github.com/ledgerwatch/erigon/common"
@@ -126,6 +127,57 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// recursiveCalls runs contract, which expands its memory to memSize bytes, increments the counter in storage and calls itself,
+// and returns the counter and the error of the call
+func recursiveCalls(t *testing.T, memSize byte, evmConfig vm.Config) (uint64, error) {
+	_, tx := memdb.NewTestTx(t)
+	state := state.New(state.NewDbStateReader(tx))
+	address := common.HexToAddress("0x0a")
+	state.SetCode(address, []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH2), memSize, 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+		byte(vm.PUSH1), 1,
+		byte(vm.ADD),
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.ADDRESS),
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.STOP),
+	})
+	_, _, err := Call(address, nil, &Config{State: state, GasLimit: 100_000_000, EVMConfig: evmConfig})
+	var counter uint256.Int
+	var key common.Hash
+	state.GetState(address, &key, &counter)
+	return counter.Uint64(), err
+}
+
+func TestCallLimits(t *testing.T) {
+	// exceeding the limits aborts the whole call, not only the frame
+	if _, err := recursiveCalls(t, 0x40, vm.Config{MaxCallDepth: 5}); err != vm.ErrDepth {
+		t.Errorf("expected %v with depth limit, got %v", vm.ErrDepth, err)
+	}
+	// every frame takes 16K+32 bytes of memory
+	if _, err := recursiveCalls(t, 0x40, vm.Config{MaxMemory: 50 * 1024}); err != vm.ErrMemoryLimit {
+		t.Errorf("expected %v with memory limit, got %v", vm.ErrMemoryLimit, err)
+	}
+	if _, err := recursiveCalls(t, 0x40, vm.Config{MaxCallDepth: 5, MaxMemory: 200 * 1024}); err != vm.ErrDepth {
+		t.Errorf("expected %v with both limits, got %v", vm.ErrDepth, err)
+	}
+	// without the limits, the innermost call runs out of gas and only fails its frame
+	if counter, err := recursiveCalls(t, 0x01, vm.Config{}); err != nil || counter <= 6 {
+		t.Errorf("expected more calls without limits, got %d, %v", counter, err)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
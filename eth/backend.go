@@ -23,11 +23,14 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -44,6 +47,7 @@ import (
 	"github.com/ledgerwatch/erigon/consensus/ethash"
 	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/core/vm"
 	"github.com/ledgerwatch/erigon/crypto"
@@ -53,15 +57,22 @@ import (
 	"github.com/ledgerwatch/erigon/eth/protocols/eth"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
 	"github.com/ledgerwatch/erigon/ethdb/privateapi"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
 	remotedbserver2 "github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/ethdb/replication"
+	"github.com/ledgerwatch/erigon/ethdb/snapshotdb"
 	"github.com/ledgerwatch/erigon/node"
 	"github.com/ledgerwatch/erigon/p2p"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/alerts"
+	"github.com/ledgerwatch/erigon/turbo/diagnostics"
 	"github.com/ledgerwatch/erigon/turbo/remote"
 	"github.com/ledgerwatch/erigon/turbo/shards"
+	"github.com/ledgerwatch/erigon/turbo/shutdown"
 	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
 	stages2 "github.com/ledgerwatch/erigon/turbo/stages"
 	"github.com/ledgerwatch/erigon/turbo/stages/txpropagate"
@@ -95,6 +106,7 @@ type Ethereum struct {
 	networkID uint64
 
 	torrentClient *snapshotsync.Client
+	snapshotKV    *snapshotdb.SnapshotKV // nil - snapshots are disabled
 
 	lock              sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 	chainConfig       *params.ChainConfig
@@ -117,8 +129,18 @@ type Ethereum struct {
 
 	waitForStageLoopStop chan struct{}
 	waitForMiningStop    chan struct{}
+
+	diagnostics   *diagnostics.Collector
+	stateProfiler *state.AccessProfiler // nil - disabled
+	alerts        *alerts.Alerter       // nil - disabled
 }
 
+// diagnosticsLogLines - amount of the last log lines written into diagnostics bundles
+const diagnosticsLogLines = 1000
+
+// alertsCheckInterval is the interval of checks of free disk space and peers for alerts
+const alertsCheckInterval = time.Minute
+
 // New creates a new Ethereum object (including the
 // initialisation of the common Ethereum object)
 func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethereum, error) {
@@ -139,6 +161,7 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 	}
 
 	var torrentClient *snapshotsync.Client
+	var snapshotKV *snapshotdb.SnapshotKV
 	config.Snapshot.Dir = stack.Config().ResolvePath("snapshots")
 	if config.Snapshot.Enabled {
 		var peerID string
@@ -169,12 +192,39 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 		if err != nil {
 			return nil, err
 		}
+		snapshotKV, _ = chainKv.(*snapshotdb.SnapshotKV)
 		err = snapshotsync.SnapshotSeeding(chainKv, torrentClient, "headers", config.Snapshot.Dir)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	var replicationLog *replication.Log
+	if stack.Config().PrivateApiAddr != "" && stack.Config().PrivateApiReplicationLog > 0 {
+		replicationLog = replication.NewLog(stack.Config().PrivateApiReplicationLog)
+		chainKv = replication.WrapDB(chainKv, replicationLog, replication.Tables)
+	}
+
+	var openTxs *diagnostics.OpenTxs
+	if config.DiagnosticsTxs {
+		openTxs = diagnostics.NewOpenTxs()
+		chainKv = diagnostics.TrackTxs(chainKv, openTxs)
+	}
+
+	if config.HistoryFiles.Enabled {
+		config.HistoryFiles.Dir = stack.Config().ResolvePath("history")
+		historyFiles, err := historyfiles.Open(config.HistoryFiles.Dir)
+		if err != nil {
+			return nil, err
+		}
+		// must be the outermost wrapper, stages and readers type-assert transactions to historyfiles.Tx
+		chainKv = historyfiles.WrapDB(chainKv, historyFiles)
+	}
+
+	if config.LogArchive.Enabled {
+		config.LogArchive.Dir = stack.Config().ResolvePath("logs")
+	}
+
 	chainConfig, genesis, genesisErr := core.CommitGenesisBlock(chainKv, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -188,6 +238,7 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 		networkID:            config.NetworkID,
 		etherbase:            config.Miner.Etherbase,
 		torrentClient:        torrentClient,
+		snapshotKV:           snapshotKV,
 		chainConfig:          chainConfig,
 		genesisHash:          genesis.Hash(),
 		waitForStageLoopStop: make(chan struct{}),
@@ -246,6 +297,9 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = stack.ResolvePath(config.TxPool.Snapshot)
+	}
 
 	backend.txPool = core.NewTxPool(config.TxPool, chainConfig, chainKv)
 
@@ -286,12 +340,32 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 		ethashApi = casted.APIs(nil)[1].Service.(*ethash.API)
 	}
 
-	kvRPC := remotedbserver2.NewKvServer(backend.chainKV)
+	kvRPC := remotedbserver2.NewKvServer(backend.chainKV).WithLimits(remotedbserver2.Limits{
+		MaxCursors:     stack.Config().PrivateApiMaxCursors,
+		MaxTxLifetime:  stack.Config().PrivateApiTxLifetime,
+		IdleTimeout:    stack.Config().PrivateApiTxIdleTimeout,
+		MaxPinLifetime: stack.Config().PrivateApiPinLifetime,
+	}).WithBucketACL(remoteapi.NewBucketACL(stack.Config().PrivateApiAllowBuckets, stack.Config().PrivateApiDenyBuckets)).
+		WithAdminToken(stack.Config().PrivateApiAdminToken)
 	ethBackendRPC := privateapi.NewEthBackendServer(backend, backend.notifications.Events)
 	txPoolRPC := privateapi.NewTxPoolServer(context.Background(), backend.txPool)
 	miningRPC := privateapi.NewMiningServer(context.Background(), backend, ethashApi)
+	var replicationRPC *replication.Server
+	if replicationLog != nil {
+		replicationRPC = replication.NewServer(replicationLog)
+	}
 
 	if stack.Config().PrivateApiAddr != "" {
+		connParams := privateapi.ConnParams{
+			KeepaliveTime:    stack.Config().PrivateApiKeepaliveTime,
+			KeepaliveTimeout: stack.Config().PrivateApiKeepaliveTimeout,
+			MinPingInterval:  stack.Config().PrivateApiKeepaliveMinTime,
+			MaxConnIdle:      stack.Config().PrivateApiConnIdle,
+			MaxRecvMsgSize:   stack.Config().PrivateApiMaxRecvMsg,
+			MaxSendMsgSize:   stack.Config().PrivateApiMaxSendMsg,
+			WindowSize:       stack.Config().PrivateApiWindowSize,
+			ConnWindowSize:   stack.Config().PrivateApiConnWindowSize,
+		}
 
 		if stack.Config().TLSConnection {
 			// load peer cert/key, ca cert
@@ -330,8 +404,10 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 				ethBackendRPC,
 				txPoolRPC,
 				miningRPC,
+				replicationRPC,
 				stack.Config().PrivateApiAddr,
 				stack.Config().PrivateApiRateLimit,
+				connParams,
 				&creds)
 			if err != nil {
 				return nil, err
@@ -342,8 +418,10 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 				ethBackendRPC,
 				txPoolRPC,
 				miningRPC,
+				replicationRPC,
 				stack.Config().PrivateApiAddr,
 				stack.Config().PrivateApiRateLimit,
+				connParams,
 				nil)
 			if err != nil {
 				return nil, err
@@ -411,10 +489,13 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 			}
 		}()
 	}
-	backend.downloadServer, err = download.NewControlServer(chainKv, stack.Config().NodeName(), chainConfig, genesis.Hash(), backend.engine, backend.config.NetworkID, backend.sentries, config.BlockDownloaderWindow)
+	backend.downloadServer, err = download.NewControlServer(chainKv, stack.Config().NodeName(), chainConfig, genesis.Hash(), backend.engine, backend.config.NetworkID, backend.sentries, config.BlockDownloaderWindow, download.ServingLimits{UploadRate: config.P2PServingUploadRate, RequestRate: config.P2PServingRequestRate})
 	if err != nil {
 		return nil, err
 	}
+	if config.HeaderSegmentFillers > 0 {
+		backend.downloadServer.Hd.SetSegmentFillers(config.HeaderSegmentFillers)
+	}
 	backend.txPoolP2PServer, err = txpool.NewP2PServer(backend.downloadCtx, backend.sentries, backend.txPool)
 	if err != nil {
 		return nil, err
@@ -428,6 +509,7 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 	backend.txPoolP2PServer.TxFetcher = fetcher.NewTxFetcher(backend.txPool.Has, backend.txPool.AddRemotes, fetchTx)
 	config.BodyDownloadTimeoutSeconds = 30
 
+	backend.stateProfiler = state.NewAccessProfiler(config.StateProfile)
 	backend.stagedSync, err = stages2.NewStagedSync(
 		backend.downloadCtx,
 		backend.logger,
@@ -439,11 +521,22 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 		backend.txPool,
 		backend.txPoolP2PServer,
 
-		torrentClient, mg, backend.notifications.Accumulator,
+		torrentClient, mg, backend.notifications.Accumulator, backend.stateProfiler,
 	)
 	if err != nil {
 		return nil, err
 	}
+	backend.diagnostics = diagnostics.NewCollector(filepath.Join(stack.Config().DataDir, "diagnostics"))
+	backend.diagnostics.AddReport("sync.txt", func(w io.Writer) error {
+		return backend.chainKV.View(context.Background(), func(tx kv.Tx) error {
+			return backend.stagedSync.PrintStatus(w, tx)
+		})
+	})
+	if openTxs != nil {
+		backend.diagnostics.AddReport("txs.txt", openTxs.Report)
+	}
+	backend.diagnostics.AddReport("logs.txt", diagnostics.InstallLogRing(diagnosticsLogLines).Report)
+
 	if config.BadBlock != 0 {
 		var badHash common.Hash
 		if err = chainKv.View(context.Background(), func(tx kv.Tx) error {
@@ -456,6 +549,17 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 		backend.stagedSync.UnwindTo(config.BadBlock-1, badHash)
 	}
 
+	if config.Alerts.Webhook != "" || config.Alerts.Command != "" {
+		backend.alerts = alerts.New(config.Alerts.Webhook, config.Alerts.Command, config.Alerts.Events)
+		backend.notifications.Events.AddHeaderSubscription(backend.alerts.ReorgSubscription(config.Alerts.ReorgDepth))
+		backend.stagedSync.OnBadBlock(backend.alerts.OnBadBlock)
+		backend.alerts.AddCheck(alerts.EventDisk, alerts.DiskCheck(stack.Config().DataDir, config.Alerts.DiskFree))
+		backend.alerts.AddCheck(alerts.EventPeers, alerts.PeersCheck(func() (int, error) {
+			count, err := backend.NetPeerCount()
+			return int(count), err
+		}, config.Alerts.MinPeers))
+	}
+
 	go txpropagate.BroadcastPendingTxsToNetwork(backend.downloadCtx, backend.txPool, backend.txPoolP2PServer.RecentPeers, backend.downloadServer)
 
 	go func() {
@@ -498,7 +602,18 @@ func New(stack *node.Node, config *ethconfig.Config, logger log.Logger) (*Ethere
 }
 
 func (s *Ethereum) APIs() []rpc.API {
-	return []rpc.API{}
+	return []rpc.API{
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   diagnostics.NewAPI(s.diagnostics),
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   diagnostics.NewStateProfileAPI(s.stateProfiler),
+		},
+	}
 }
 
 func (s *Ethereum) Etherbase() (eb common.Address, err error) {
@@ -590,6 +705,7 @@ func (s *Ethereum) StartMining(ctx context.Context, kv kv.RwDB, mining *stagedsy
 			if err := s.txPool.Start(hh.GasLimit, execution); err != nil {
 				return err
 			}
+			s.txPool.SetBaseFee(hh.BaseFee)
 		}
 	}
 
@@ -656,6 +772,25 @@ func (s *Ethereum) NetPeerCount() (uint64, error) {
 	return sentryPc, nil
 }
 
+// EthProtocols returns versions of the eth protocol of the connected sentries, highest first
+func (s *Ethereum) EthProtocols() []uint {
+	var versions []uint
+	for _, sc := range s.sentries {
+		if !sc.Ready() {
+			continue
+		}
+		known := false
+		for _, v := range versions {
+			known = known || v == sc.Protocol()
+		}
+		if !known {
+			versions = append(versions, sc.Protocol())
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	return versions
+}
+
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
@@ -684,42 +819,154 @@ func (s *Ethereum) Start() error {
 		s.notifications, s.downloadServer.UpdateHead, s.waitForStageLoopStop,
 		s.config.SyncLoopThrottle,
 	)
+	s.diagnostics.WriteOnSignal(s.downloadCtx.Done())
+	if s.config.Watchdog.Timeout > 0 {
+		go s.newWatchdog().Run(s.downloadCtx)
+	}
+	if s.alerts != nil {
+		go s.alerts.Run(s.downloadCtx, alertsCheckInterval)
+	}
 
 	return nil
 }
 
+// newWatchdog returns the watchdog of the progress of the Finish stage with the configured actions
+func (s *Ethereum) newWatchdog() *diagnostics.Watchdog {
+	w := diagnostics.NewWatchdog(s.config.Watchdog.Timeout, func() (head uint64, err error) {
+		err = s.chainKV.View(s.downloadCtx, func(tx kv.Tx) error {
+			head, err = stages.GetStageProgress(tx, stages.Finish)
+			return err
+		})
+		return head, err
+	})
+	for _, name := range s.config.Watchdog.Actions {
+		switch name {
+		case diagnostics.ActionBundle:
+			w.AddAction(name, diagnostics.BundleAction(s.diagnostics))
+		case diagnostics.ActionRestartSync:
+			// the running step of the sync isn't interrupted, the next one starts from the headers in the database
+			w.AddAction(name, func(context.Context, *diagnostics.Stall) error {
+				return s.downloadServer.Hd.RecoverFromDb(s.chainKV)
+			})
+		case diagnostics.ActionRotatePeers:
+			// only peers of the embedded sentries, peers of the external ones are not known here
+			w.AddAction(name, func(context.Context, *diagnostics.Stall) error {
+				var dropped int
+				for _, srv := range s.sentryServers {
+					dropped += srv.DropPeers(s.config.Watchdog.Peers)
+				}
+				log.Info("Watchdog dropped peers", "count", dropped)
+				return nil
+			})
+		case diagnostics.ActionWebhook:
+			w.AddAction(name, diagnostics.WebhookAction(s.config.Watchdog.Webhook))
+		}
+	}
+	if s.alerts != nil && s.alerts.Enabled(alerts.EventStall) {
+		w.AddAction("alert", func(_ context.Context, stall *diagnostics.Stall) error {
+			s.alerts.Send(alerts.Alert{
+				Event:   alerts.EventStall,
+				Message: fmt.Sprintf("chain head %d didn't advance for %s", stall.Head, stall.Duration.Round(time.Second)),
+				Data:    map[string]interface{}{"head": stall.Head, "since": stall.Since, "attempt": stall.Attempt, "bundle": stall.Bundle},
+			})
+			return nil
+		})
+	}
+	return w
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
-	// Stop all the peer-related stuff first.
-	s.downloadCancel()
-	s.txPoolP2PServer.TxFetcher.Stop()
-	s.txPool.Stop()
-	if s.quitMining != nil {
-		close(s.quitMining)
-	}
-
-	if s.privateAPI != nil {
+	c := shutdown.New(s.config.ShutdownTimeout)
+	// The sync stops first, while peers are still connected - headers and bodies stages may wait for them
+	c.Add("sync", func(ctx context.Context) error {
+		s.stagedSync.Interrupt()
+		select {
+		case <-s.waitForStageLoopStop:
+			return nil
+		case <-ctx.Done():
+		}
+		log.Warn("Sync didn't stop at a stage boundary before the deadline, aborting the running stage")
+		s.downloadCancel()
+		<-s.waitForStageLoopStop
+		return nil
+	})
+	c.Add("p2p", func(ctx context.Context) error {
+		s.downloadCancel()
+		s.txPoolP2PServer.TxFetcher.Stop()
+		if s.txPool != nil {
+			s.txPool.Stop()
+		}
+		if s.quitMining != nil {
+			close(s.quitMining)
+		}
+		return nil
+	})
+	c.Add("private api", func(ctx context.Context) error {
+		if s.privateAPI == nil {
+			return nil
+		}
 		shutdownDone := make(chan bool)
 		go func() {
 			defer close(shutdownDone)
 			s.privateAPI.GracefulStop()
 		}()
 		select {
-		case <-time.After(1 * time.Second): // shutdown deadline
+		case <-time.After(1 * time.Second): // streams of rpcdaemons don't end by themselves
+			s.privateAPI.Stop()
+		case <-ctx.Done():
 			s.privateAPI.Stop()
 		case <-shutdownDone:
 		}
-	}
-
-	//s.miner.Stop()
-	s.engine.Close()
-	if s.txPool != nil {
-		s.txPool.Stop()
-	}
-	<-s.waitForStageLoopStop
-	if s.config.Miner.Enabled {
-		<-s.waitForMiningStop
-	}
-	return nil
+		return nil
+	})
+	c.Add("mining", func(ctx context.Context) error {
+		//s.miner.Stop()
+		s.engine.Close()
+		if s.config.Miner.Enabled {
+			<-s.waitForMiningStop
+		}
+		return nil
+	})
+	c.Add("snapshots", func(ctx context.Context) error {
+		// UpdateSnapshots takes the snapshots away from the readers, so they are closed only after the stage loop
+		// and the mining loop, which read them, have stopped. If they didn't stop in time, the snapshots are left
+		// open to the exit of the process
+		stopped := func(loop <-chan struct{}) bool {
+			select {
+			case <-loop:
+				return true
+			default:
+			}
+			select {
+			case <-loop:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !stopped(s.waitForStageLoopStop) {
+			return fmt.Errorf("sync is still running, snapshots are left open: %w", ctx.Err())
+		}
+		if s.config.Miner.Enabled && !stopped(s.waitForMiningStop) {
+			return fmt.Errorf("mining is still running, snapshots are left open: %w", ctx.Err())
+		}
+		if s.snapshotKV != nil {
+			for _, tp := range []string{"headers", "bodies", "state"} {
+				closed := make(chan struct{}, 1)
+				s.snapshotKV.UpdateSnapshots(tp, nil, closed) // waits for open transactions of the snapshot
+				select {
+				case <-closed:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if s.torrentClient != nil {
+			s.torrentClient.Close()
+		}
+		return nil
+	})
+	return c.Run()
 }
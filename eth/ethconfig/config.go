@@ -37,6 +37,7 @@ import (
 	"github.com/ledgerwatch/erigon/consensus/db"
 	"github.com/ledgerwatch/erigon/consensus/ethash"
 	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/eth/gasprice"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
@@ -71,16 +72,42 @@ var Defaults = Config{
 	},
 	NetworkID: 1,
 	Prune:     prune.DefaultMode,
+	HistoryFiles: HistoryFiles{
+		Keep: params.FullImmutabilityThreshold,
+	},
+	LogArchive: LogArchive{
+		Keep: params.FullImmutabilityThreshold,
+	},
+	CrossCheck: CrossCheck{
+		Every: 10_000,
+	},
+	Commit: Commit{
+		DirtyShare: 0.75,
+		Every:      10 * time.Minute,
+	},
+	Watchdog: Watchdog{
+		Actions: []string{"diagnostics", "restart-sync", "rotate-peers"},
+		Peers:   0.5,
+	},
+	Alerts: Alerts{
+		Events:     []string{"reorg", "stall", "disk", "badblock", "peers"},
+		ReorgDepth: 3,
+		DiskFree:   10 * datasize.GB,
+		MinPeers:   3,
+	},
+	ShutdownTimeout: 2 * time.Minute,
 	Miner: params.MiningConfig{
 		GasFloor: 8000000,
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
 	},
-	TxPool:      core.DefaultTxPoolConfig,
-	RPCGasCap:   50000000,
-	GPO:         FullNodeGPO,
-	RPCTxFeeCap: 1, // 1 ether
+	StateCache:   state.DefaultSharedCacheConfig,
+	StateProfile: state.DefaultAccessProfilerConfig,
+	TxPool:       core.DefaultTxPoolConfig,
+	RPCGasCap:    50000000,
+	GPO:          FullNodeGPO,
+	RPCTxFeeCap:  1, // 1 ether
 
 	BodyDownloadTimeoutSeconds: 30,
 }
@@ -118,6 +145,57 @@ type Snapshot struct {
 	Seeding bool
 }
 
+type HistoryFiles struct {
+	Enabled bool
+	Dir     string
+	Keep    uint64 // amount of recent blocks, history of which stays in the DB
+}
+
+type LogArchive struct {
+	Enabled bool
+	Dir     string
+	Keep    uint64 // amount of recent blocks, which are not archived yet because they may be reorged
+}
+
+// CrossCheck compares every N-th block with the block of the trusted node
+type CrossCheck struct {
+	URL   string // JSON-RPC endpoint of the trusted node, empty - disabled
+	Every uint64
+	Halt  bool // stop the sync at the mismatching block, otherwise only report it
+}
+
+// Commit tells the execution stage when to commit its transaction. The in-memory batch is flushed into the
+// transaction every BatchSize, but the transaction is committed only when its dirty pages take DirtyShare of the
+// mdbx limit of dirty pages (which is proportional to RAM), or Every passed since the previous commit.
+type Commit struct {
+	DirtyShare float64       // 0 - commit with every flushed batch
+	Every      time.Duration // 0 - no limit
+}
+
+// SyncSource is the local source of blocks for the sync instead of peers
+type SyncSource struct {
+	Path string // datadir or chaindata of another node, or directory of headers and bodies snapshots
+	To   uint64 // last block to take from the source, 0 - all of them
+}
+
+// Watchdog executes recovery actions when the chain head doesn't advance for Timeout
+type Watchdog struct {
+	Timeout time.Duration // 0 - disabled
+	Actions []string      // names of the actions in the order of execution, see diagnostics.WatchdogActions
+	Webhook string        // URL of the webhook action
+	Peers   float64       // share of the peers dropped by the rotate-peers action
+}
+
+// Alerts are delivered to Webhook and by Command, alerting is disabled if both are empty
+type Alerts struct {
+	Webhook    string
+	Command    string
+	Events     []string // see alerts.Events
+	ReorgDepth uint64   // reorgs of more blocks are alerted
+	DiskFree   datasize.ByteSize
+	MinPeers   int
+}
+
 // Config contains configuration options for ETH protocol.
 type Config struct {
 	// The genesis block, which is inserted if the database is empty.
@@ -139,8 +217,45 @@ type Config struct {
 
 	Snapshot Snapshot
 
+	HistoryFiles HistoryFiles
+
+	LogArchive LogArchive
+
+	CrossCheck CrossCheck
+
+	Commit Commit
+
+	SyncSource SyncSource
+
+	Watchdog Watchdog
+
+	// Track open transactions of the chain DB with their callers for txs.txt of the diagnostics bundle,
+	// costs a walk of the stack per transaction
+	DiagnosticsTxs bool
+
+	Alerts Alerts
+
+	// Don't maintain first and last activity blocks of addresses, served by erigon_getAddressActivity
+	NoAddressActivity bool
+
+	// The sync stops at a stage boundary within it, after it the running stage is aborted (see shutdown.Coordinator)
+	ShutdownTimeout time.Duration
+
+	// Cache of PlainState for execution of blocks
+	StateCache state.SharedCacheConfig
+
+	// Sampling of reads of accounts and storage slots by execution of blocks, served by debug_stateAccessProfile
+	StateProfile state.AccessProfilerConfig
+
 	BlockDownloaderWindow int
 
+	// Requests filling the gap below one anchor of the header download in parallel, 0 - default
+	HeaderSegmentFillers int
+
+	// Throttles of serving block bodies and receipts to peers, 0 - no limit
+	P2PServingUploadRate  datasize.ByteSize // bytes per second to all peers
+	P2PServingRequestRate float64           // requests per second from one peer
+
 	// Address to connect to external snapshot downloader
 	// empty if you want to use internal bittorrent snapshot downloader
 	ExternalSnapshotDownloaderAddr string
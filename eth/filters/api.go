@@ -471,6 +471,8 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		ToBlock   *rpc.BlockNumber `json:"toBlock"`
 		Addresses interface{}      `json:"address"`
 		Topics    []interface{}    `json:"topics"`
+
+		Continuation string `json:"continuation"`
 	}
 
 	var raw input
@@ -494,6 +496,7 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	args.Continuation = raw.Continuation
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
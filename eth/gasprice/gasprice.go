@@ -23,6 +23,8 @@ import (
 	"math/big"
 	"sync"
 
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/core/types"
@@ -38,6 +40,11 @@ var (
 	DefaultIgnorePrice = big.NewInt(2 * params.Wei)
 )
 
+var (
+	sampleCacheHits   = metrics.GetOrCreateCounter(`gasprice_sample_cache{result="hit"}`)
+	sampleCacheMisses = metrics.GetOrCreateCounter(`gasprice_sample_cache{result="miss"}`)
+)
+
 type Config struct {
 	Blocks      int
 	Percentile  int
@@ -65,6 +72,8 @@ type Oracle struct {
 
 	checkBlocks int
 	percentile  int
+
+	samples *lru.Cache // block hash -> []*uint256.Int, nil - blocks are read by every SuggestPrice
 }
 
 // NewOracle returns a new gasprice oracle which can recommend suitable
@@ -104,6 +113,17 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 	}
 }
 
+// WithSampleCache keeps samples of the last blocks, so that oracles, which live longer than one call, read only
+// blocks appeared since the previous SuggestPrice. Samples are keyed by hashes of blocks, blocks of the chain
+// replaced by a reorg are read again.
+func (gpo *Oracle) WithSampleCache(blocks int) *Oracle {
+	if blocks < gpo.checkBlocks {
+		blocks = gpo.checkBlocks
+	}
+	gpo.samples, _ = lru.New(blocks) // fails only for non-positive sizes
+	return gpo
+}
+
 // SuggestPrice returns a TipCap so that newly created transaction can
 // have a very high chance to be included in the following blocks.
 // NODE: if caller wants legacy tx SuggestedPrice, we need to add
@@ -136,7 +156,10 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 		}
 		number--
 	}
-	price := lastPrice
+	var price *big.Int
+	if lastPrice != nil {
+		price = new(big.Int).Set(lastPrice) // the base fee is added below
+	}
 	if txPrices.Len() > 0 {
 		// Item with this position needs to be extracted from the sorting heap
 		// so we pop all the items before it
@@ -205,6 +228,40 @@ func (t *transactionsByGasPrice) Pop() interface{} {
 // itself(it doesn't make any sense to include this kind of transaction prices for sampling),
 // nil gasprice is returned.
 func (gpo *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, limit int,
+	ingoreUnderBig *big.Int, s *sortingHeap) error {
+	if gpo.samples == nil {
+		return gpo.readBlockPrices(ctx, blockNum, limit, ingoreUnderBig, s)
+	}
+	header, err := gpo.backend.HeaderByNumber(ctx, rpc.BlockNumber(blockNum))
+	if err != nil {
+		return err
+	}
+	hash := header.Hash()
+	var tips []*uint256.Int // the lowest tips of the block in ascending order, pushed in the same order
+	if cached, ok := gpo.samples.Get(hash); ok {
+		sampleCacheHits.Inc()
+		tips = cached.([]*uint256.Int)
+	} else {
+		sampleCacheMisses.Inc()
+		var block sortingHeap
+		if err := gpo.readBlockPrices(ctx, blockNum, limit, ingoreUnderBig, &block); err != nil {
+			return err
+		}
+		tips = block // pushed in ascending order, so the heap is sorted
+		gpo.samples.Add(hash, tips)
+	}
+	// the same limit as of readBlockPrices: by the size of the whole heap
+	for _, tip := range tips {
+		heap.Push(s, tip)
+		if s.Len() >= limit {
+			break
+		}
+	}
+	return nil
+}
+
+// readBlockPrices reads the block and pushes up to limit its lowest tips into the heap
+func (gpo *Oracle) readBlockPrices(ctx context.Context, blockNum uint64, limit int,
 	ingoreUnderBig *big.Int, s *sortingHeap) error {
 	ignoreUnder, overflow := uint256.FromBig(ingoreUnderBig)
 	if overflow {
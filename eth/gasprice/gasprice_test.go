@@ -140,3 +140,57 @@ func TestSuggestPrice(t *testing.T) {
 		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
 	}
 }
+
+// movingHeadBackend counts reads of blocks, its head is at block head
+type movingHeadBackend struct {
+	*testBackend
+	head   uint64
+	blocks int
+}
+
+func (b *movingHeadBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		number = rpc.BlockNumber(b.head)
+	}
+	return b.testBackend.HeaderByNumber(ctx, number)
+}
+
+func (b *movingHeadBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	b.blocks++
+	return b.testBackend.BlockByNumber(ctx, number)
+}
+
+func TestSuggestPriceSampleCache(t *testing.T) {
+	config := gasprice.Config{
+		Blocks:     2,
+		Percentile: 60,
+		Default:    big.NewInt(params.GWei),
+	}
+	backend := &movingHeadBackend{testBackend: newTestBackend(t), head: 31}
+	oracle := gasprice.NewOracle(backend, config).WithSampleCache(16)
+
+	// The gas price sampled is: 31G, 30G, 29G, 28G, 27G, 26G
+	got, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve recommended gas price: %v", err)
+	}
+	if expect := big.NewInt(params.GWei * int64(29)); got.Cmp(expect) != 0 {
+		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
+	}
+	if backend.blocks != 6 {
+		t.Fatalf("Blocks read: want 6, got %d", backend.blocks)
+	}
+
+	// only the new head is read, the rest of samples are cached
+	backend.head, backend.blocks = 32, 0
+	got, err = oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve recommended gas price: %v", err)
+	}
+	if expect := big.NewInt(params.GWei * int64(30)); got.Cmp(expect) != 0 {
+		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
+	}
+	if backend.blocks != 1 {
+		t.Fatalf("Blocks read: want 1, got %d", backend.blocks)
+	}
+}
@@ -84,6 +84,7 @@ func newTestBackendWithGenerator(t *testing.T, blocks int, generator func(int, *
 	}
 	txconfig := core.DefaultTxPoolConfig
 	txconfig.Journal = "" // Don't litter the disk with test journals
+	txconfig.Snapshot = ""
 
 	b := &testBackend{
 		db:          m.DB,
@@ -0,0 +1,130 @@
+package stagedsync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
+)
+
+// updateAddressActivity records the block as the last activity of every address which took part in its calls
+// (including block and uncle beneficiaries), and as the first activity of addresses never seen before.
+func updateAddressActivity(db ethdb.Database, block *types.Block, callTracer *CallTracer) error {
+	blockNum := block.NumberU64()
+	touched := make(map[common.Address]struct{}, len(callTracer.froms)+len(callTracer.tos)+1)
+	for addr := range callTracer.froms {
+		touched[addr] = struct{}{}
+	}
+	for addr := range callTracer.tos {
+		touched[addr] = struct{}{}
+	}
+	touched[block.Coinbase()] = struct{}{}
+	for _, uncle := range block.Uncles() {
+		touched[uncle.Coinbase] = struct{}{}
+	}
+	for addr := range touched {
+		first, last, found, err := rawdb.ReadAddressActivity(db, addr)
+		if err != nil {
+			return err
+		}
+		if !found {
+			first = blockNum
+		} else if last >= blockNum {
+			continue
+		}
+		if err = rawdb.WriteAddressActivity(db, addr, first, blockNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwindAddressActivity reverts activity of addresses touched by blocks (unwindPoint, from], which are found in CallTraceSet.
+// Must be called before CallTraceSet is truncated and after CallTraces stage is unwound: the new last activity
+// is looked up in CallFromIndex/CallToIndex, and in CallTraceSet for blocks not indexed yet.
+func unwindAddressActivity(tx kv.RwTx, from, unwindPoint uint64) error {
+	indexed, err := stages.GetStageProgress(tx, stages.CallTraces)
+	if err != nil {
+		return err
+	}
+	if indexed > unwindPoint {
+		indexed = unwindPoint
+	}
+	c, err := tx.CursorDupSort(kv.CallTraceSet)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	touched := map[common.Address]struct{}{}
+	for k, v, err := c.Seek(dbutils.EncodeBlockNumber(unwindPoint + 1)); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(k) > from {
+			break
+		}
+		touched[common.BytesToAddress(v[:common.AddressLength])] = struct{}{}
+	}
+
+	for addr := range touched {
+		first, last, found, err := rawdb.ReadAddressActivity(tx, addr)
+		if err != nil {
+			return err
+		}
+		if !found || last <= unwindPoint {
+			continue
+		}
+		if first > unwindPoint {
+			if err = rawdb.DeleteAddressActivity(tx, addr); err != nil {
+				return err
+			}
+			continue
+		}
+		last, err = lastAddressActivity(tx, c, addr, indexed, unwindPoint)
+		if err != nil {
+			return err
+		}
+		if last < first { // history is pruned
+			last = first
+		}
+		if err = rawdb.WriteAddressActivity(tx, addr, first, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastAddressActivity returns the last block <= to in which the address took part in a call, 0 if there is none.
+// Blocks above indexed are looked up in CallTraceSet, the rest in the call traces index.
+func lastAddressActivity(tx kv.Tx, traces kv.CursorDupSort, addr common.Address, indexed, to uint64) (uint64, error) {
+	for blockNum := to; blockNum > indexed; blockNum-- {
+		v, err := traces.SeekBothRange(dbutils.EncodeBlockNumber(blockNum), addr[:])
+		if err != nil {
+			return 0, err
+		}
+		if v != nil && bytes.HasPrefix(v, addr[:]) {
+			return blockNum, nil
+		}
+	}
+	var last uint64
+	for _, table := range []string{kv.CallFromIndex, kv.CallToIndex} {
+		// Index is unwound already, so the chunk at or after `indexed` is the last one
+		bm, err := bitmapdb.Get64(tx, table, addr[:], indexed, indexed)
+		if err != nil {
+			return 0, fmt.Errorf("reading %s of %x: %w", table, addr, err)
+		}
+		if !bm.IsEmpty() && bm.Maximum() > last {
+			last = bm.Maximum()
+		}
+	}
+	return last, nil
+}
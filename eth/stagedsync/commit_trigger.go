@@ -0,0 +1,76 @@
+package stagedsync
+
+import (
+	"time"
+
+	metrics2 "github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/ethdb"
+)
+
+var (
+	commitsOnBatch    = metrics2.GetOrCreateCounter(`stage_commits{reason="batch"}`)
+	commitsOnDirty    = metrics2.GetOrCreateCounter(`stage_commits{reason="dirty"}`)
+	commitsOnInterval = metrics2.GetOrCreateCounter(`stage_commits{reason="interval"}`)
+	commitDirtyBytes  = metrics2.GetOrCreateCounter(`stage_commit_dirty_bytes`) // pending size at the last check
+	commitDuration    = metrics2.GetOrCreateSummary(`stage_commit_seconds`)
+)
+
+// spaceDirty is implemented by mdbx transactions (under the wrappers, see ethdb.UnwrapTx), returns size of dirty
+// pages and the limit of it
+type spaceDirty interface {
+	SpaceDirty() (uint64, uint64, error)
+}
+
+// commitTrigger decides when a long running stage commits its transaction: machines with more RAM have higher
+// limit of dirty pages, so they commit bigger chunks, and small machines commit before they run out of memory.
+// Bigger commits are more efficient, but take longer - see stage_commit_seconds.
+type commitTrigger struct {
+	cfg        ethconfig.Commit
+	lastCommit time.Time
+	reason     *metrics2.Counter
+}
+
+func newCommitTrigger(cfg ethconfig.Commit) *commitTrigger {
+	return &commitTrigger{cfg: cfg, lastCommit: time.Now()}
+}
+
+// due returns true if the transaction has to be committed, pending is the size of writes not flushed into
+// the transaction yet, flush is true if they are going to be flushed now
+func (t *commitTrigger) due(tx kv.RwTx, pending int, flush bool) (bool, error) {
+	if t.cfg.Every > 0 && time.Since(t.lastCommit) >= t.cfg.Every {
+		t.reason = commitsOnInterval
+		return true, nil
+	}
+	if !flush {
+		return false, nil
+	}
+	sd, ok := ethdb.UnwrapTx(tx).(spaceDirty)
+	if t.cfg.DirtyShare == 0 || !ok {
+		t.reason = commitsOnBatch
+		return true, nil
+	}
+	dirty, limit, err := sd.SpaceDirty()
+	if err != nil {
+		return false, err
+	}
+	dirty += uint64(pending)
+	commitDirtyBytes.Set(dirty)
+	if float64(dirty) < t.cfg.DirtyShare*float64(limit) {
+		return false, nil
+	}
+	t.reason = commitsOnDirty
+	return true, nil
+}
+
+func (t *commitTrigger) commit(tx kv.RwTx) error {
+	start := time.Now()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	commitDuration.UpdateDuration(start)
+	t.reason.Inc()
+	t.lastCommit = time.Now()
+	return nil
+}
@@ -0,0 +1,59 @@
+package stagedsync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/ledgerwatch/erigon/ethdb/replication"
+	"github.com/ledgerwatch/erigon/turbo/diagnostics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitTrigger(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	due := func(cfg ethconfig.Commit, pending int, flush bool) bool {
+		ok, err := newCommitTrigger(cfg).due(tx, pending, flush)
+		require.NoError(t, err)
+		return ok
+	}
+	// commit with every flushed batch
+	require.False(t, due(ethconfig.Commit{}, 1024, false))
+	require.True(t, due(ethconfig.Commit{}, 1024, true))
+
+	// commit when dirty pages are close to the limit
+	require.False(t, due(ethconfig.Commit{DirtyShare: 1}, 1024, true))
+	require.True(t, due(ethconfig.Commit{DirtyShare: 1}, 1<<40, true))
+	require.False(t, due(ethconfig.Commit{DirtyShare: 1}, 1<<40, false))
+
+	// commit by time even without flushes
+	trigger := newCommitTrigger(ethconfig.Commit{DirtyShare: 1, Every: time.Minute})
+	ok, err := trigger.due(tx, 0, false)
+	require.NoError(t, err)
+	require.False(t, ok)
+	trigger.lastCommit = time.Now().Add(-time.Hour)
+	ok, err = trigger.due(tx, 0, false)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+// dirty pages are seen through the wrappers of the database of the node
+func TestCommitTriggerWrappedDB(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	db = diagnostics.TrackTxs(db, diagnostics.NewOpenTxs())
+	db = replication.WrapDB(db, replication.NewLog(1<<20), replication.Tables)
+	db = historyfiles.WrapDB(db, nil)
+	tx, err := db.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	ok, err := newCommitTrigger(ethconfig.Commit{DirtyShare: 1}).due(tx, 1024, true)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = newCommitTrigger(ethconfig.Commit{DirtyShare: 1}).due(tx, 1<<40, true)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
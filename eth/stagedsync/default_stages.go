@@ -21,10 +21,15 @@ func DefaultStages(ctx context.Context,
 	snapshotState SnapshotStateCfg,
 	hashState HashStateCfg,
 	trieCfg TrieCfg,
+	crossCheck CrossCheckCfg,
 	history HistoryCfg,
+	historyFiles HistoryFilesCfg,
 	logIndex LogIndexCfg,
+	logArchive LogArchiveCfg,
 	callTraces CallTracesCfg,
 	txLookup TxLookupCfg,
+	feeSeries FeeSeriesCfg,
+	dataGasStats DataGasStatsCfg,
 	txPool TxPoolCfg,
 	finish FinishCfg,
 	test bool,
@@ -182,6 +187,19 @@ func DefaultStages(ctx context.Context,
 				return PruneIntermediateHashesStage(p, tx, trieCfg, ctx)
 			},
 		},
+		{
+			ID:          stages.CrossCheck,
+			Description: "Cross-check with the trusted node",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return SpawnCrossCheck(s, tx, crossCheck, ctx)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, tx kv.RwTx) error {
+				return UnwindCrossCheck(u, s, tx, crossCheck, ctx)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx) error {
+				return PruneCrossCheck(p, tx, crossCheck, ctx)
+			},
+		},
 		{
 			ID:                  stages.CallTraces,
 			Description:         "Generate call traces index",
@@ -222,6 +240,19 @@ func DefaultStages(ctx context.Context,
 				return PruneStorageHistoryIndex(p, tx, history, ctx)
 			},
 		},
+		{
+			ID:          stages.HistoryFiles,
+			Description: "Move old history into files",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return SpawnHistoryFiles(s, tx, historyFiles, ctx)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, tx kv.RwTx) error {
+				return UnwindHistoryFiles(u, s, tx, historyFiles, ctx)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx) error {
+				return PruneHistoryFiles(p, tx, historyFiles, ctx)
+			},
+		},
 		{
 			ID:          stages.LogIndex,
 			Description: "Generate receipt logs index",
@@ -235,6 +266,19 @@ func DefaultStages(ctx context.Context,
 				return PruneLogIndex(p, tx, logIndex, ctx)
 			},
 		},
+		{
+			ID:          stages.LogArchive,
+			Description: "Append old logs to the archive",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return SpawnLogArchive(s, tx, logArchive, ctx)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, tx kv.RwTx) error {
+				return UnwindLogArchive(u, s, tx, logArchive, ctx)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx) error {
+				return PruneLogArchive(p, tx, logArchive, ctx)
+			},
+		},
 		{
 			ID:          stages.TxLookup,
 			Description: "Generate tx lookup index",
@@ -248,6 +292,32 @@ func DefaultStages(ctx context.Context,
 				return PruneTxLookup(p, tx, txLookup, ctx)
 			},
 		},
+		{
+			ID:          stages.FeeSeries,
+			Description: "Aggregate fee series",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return SpawnFeeSeries(s, tx, feeSeries, ctx)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, tx kv.RwTx) error {
+				return UnwindFeeSeries(u, s, tx, feeSeries, ctx)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx) error {
+				return PruneFeeSeries(p, tx, feeSeries, ctx)
+			},
+		},
+		{
+			ID:          stages.DataGasStats,
+			Description: "Count data gas",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return SpawnDataGasStats(s, tx, dataGasStats, ctx)
+			},
+			Unwind: func(firstCycle bool, u *UnwindState, s *StageState, tx kv.RwTx) error {
+				return UnwindDataGasStats(u, s, tx, dataGasStats, ctx)
+			},
+			Prune: func(firstCycle bool, p *PruneState, tx kv.RwTx) error {
+				return PruneDataGasStats(p, tx, dataGasStats, ctx)
+			},
+		},
 		{
 			ID:          stages.TxPool,
 			Description: "Update transaction pool",
@@ -291,11 +361,16 @@ var DefaultForwardOrder = UnwindOrder{
 	stages.CreateStateSnapshot,
 	stages.HashState,
 	stages.IntermediateHashes,
+	stages.CrossCheck,
 	stages.CallTraces,
 	stages.AccountHistoryIndex,
 	stages.StorageHistoryIndex,
+	stages.HistoryFiles,
 	stages.LogIndex,
+	stages.LogArchive,
 	stages.TxLookup,
+	stages.FeeSeries,
+	stages.DataGasStats,
 	stages.TxPool,
 	stages.Finish,
 }
@@ -309,11 +384,16 @@ type PruneOrder []stages.SyncStage
 
 var DefaultUnwindOrder = UnwindOrder{
 	stages.Finish,
+	stages.DataGasStats,
+	stages.FeeSeries,
 	stages.TxLookup,
+	stages.LogArchive,
 	stages.LogIndex,
+	stages.HistoryFiles,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
+	stages.CrossCheck,
 
 	// Unwinding of IHashes needs to happen after unwinding HashState
 	stages.HashState,
@@ -337,11 +417,16 @@ var DefaultUnwindOrder = UnwindOrder{
 
 var DefaultPruneOrder = PruneOrder{
 	stages.Finish,
+	stages.DataGasStats,
+	stages.FeeSeries,
 	stages.TxLookup,
+	stages.LogArchive,
 	stages.LogIndex,
+	stages.HistoryFiles,
 	stages.StorageHistoryIndex,
 	stages.AccountHistoryIndex,
 	stages.CallTraces,
+	stages.CrossCheck,
 
 	// Unwinding of IHashes needs to happen after unwinding HashState
 	stages.HashState,
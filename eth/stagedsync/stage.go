@@ -1,6 +1,8 @@
 package stagedsync
 
 import (
+	"errors"
+
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
@@ -46,6 +48,48 @@ type StageState struct {
 
 func (s *StageState) LogPrefix() string { return s.state.LogPrefix() }
 
+// Interrupted is closed when the sync is asked to stop at a safe boundary (see Sync.Interrupt). Long stages check it
+// at their commit points, save the progress and return nil.
+func (s *StageState) Interrupted() <-chan struct{} { return s.state.Interrupted() }
+
+// IsInterrupted tells whether Interrupted is closed. Loops of ETL stages over tables ordered by block number check
+// it when they move to the next block: they stop there, load what is collected for the previous blocks and save
+// the progress up to them.
+func (s *StageState) IsInterrupted() bool {
+	select {
+	case <-s.Interrupted():
+		return true
+	default:
+		return false
+	}
+}
+
+// quitOrInterrupted returns the channel, which is closed on quit or on Interrupted, for stages which can't save their
+// progress in the middle (the trie root is known only at the end): they drop their work and return nil then, without
+// updating the progress. release must be called when the stage is done.
+func (s *StageState) quitOrInterrupted(quit <-chan struct{}) (stop <-chan struct{}, release func()) {
+	stopCh, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		select {
+		case <-quit:
+		case <-s.Interrupted():
+		case <-done:
+			return
+		}
+		close(stopCh)
+	}()
+	return stopCh, func() { close(done) }
+}
+
+// droppedByInterrupt tells whether err is the stop of the work by quitOrInterrupted because of Interrupted, and not
+// because of quit
+func (s *StageState) droppedByInterrupt(err error, quit <-chan struct{}) bool {
+	if !errors.Is(err, common.ErrStopped) || !s.IsInterrupted() {
+		return false
+	}
+	return common.Stopped(quit) == nil
+}
+
 // Update updates the stage state (current block number) in the database. Can be called multiple times during stage execution.
 func (s *StageState) Update(db kv.Putter, newBlockNum uint64) error {
 	return stages.SaveStageProgress(db, s.ID, newBlockNum)
@@ -143,6 +143,9 @@ Loop:
 		if err != nil {
 			return err
 		}
+		if penalties := cfg.bd.GetPenalties(); len(penalties) > 0 {
+			cfg.penalise(ctx, penalties)
+		}
 		d4 += time.Since(start)
 		start = time.Now()
 		cr := ChainReader{Cfg: cfg.chanConfig, Db: tx}
@@ -179,6 +182,9 @@ Loop:
 		select {
 		case <-ctx.Done():
 			stopped = true
+		case <-s.Interrupted():
+			log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "highest", bodyProgress)
+			break Loop
 		case <-logEvery.C:
 			deliveredCount, wastedCount := cfg.bd.DeliveryCounts()
 			logProgressBodies(logPrefix, bodyProgress, prevDeliveredCount, deliveredCount, prevWastedCount, wastedCount)
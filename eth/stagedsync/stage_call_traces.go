@@ -69,9 +69,14 @@ func SpawnCallTraces(s *StageState, tx kv.RwTx, cfg CallTracesCfg, ctx context.C
 		return nil
 	}
 
-	if err := promoteCallTraces(logPrefix, tx, s.BlockNumber+1, endBlock, bitmapsBufLimit, bitmapsFlushEvery, quit, cfg.tmpdir); err != nil {
+	interruptedAt, err := promoteCallTraces(logPrefix, tx, s.BlockNumber+1, endBlock, bitmapsBufLimit, bitmapsFlushEvery, quit, s.Interrupted(), cfg.tmpdir)
+	if err != nil {
 		return err
 	}
+	if interruptedAt > 0 {
+		endBlock = interruptedAt - 1
+		log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", endBlock)
+	}
 
 	if err := s.Update(tx, endBlock); err != nil {
 		return err
@@ -85,7 +90,9 @@ func SpawnCallTraces(s *StageState, tx kv.RwTx, cfg CallTracesCfg, ctx context.C
 	return nil
 }
 
-func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64, bufLimit datasize.ByteSize, flushEvery time.Duration, quit <-chan struct{}, tmpdir string) error {
+// promoteCallTraces indexes call traces of blocks [startBlock, endBlock]. On interrupted it stops before the next block
+// and indexes the blocks before it, interruptedAt is that block then (0 - not interrupted).
+func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64, bufLimit datasize.ByteSize, flushEvery time.Duration, quit, interrupted <-chan struct{}, tmpdir string) (interruptedAt uint64, err error) {
 	logEvery := time.NewTicker(logInterval)
 	defer logEvery.Stop()
 
@@ -100,22 +107,30 @@ func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64
 
 	traceCursor, err := tx.RwCursorDupSort(kv.CallTraceSet)
 	if err != nil {
-		return fmt.Errorf("failed to create cursor: %w", err)
+		return 0, fmt.Errorf("failed to create cursor: %w", err)
 	}
 	defer traceCursor.Close()
 
 	var k, v []byte
 	prev := startBlock
+	currentBlock := startBlock
 	for k, v, err = traceCursor.Seek(dbutils.EncodeBlockNumber(startBlock)); k != nil; k, v, err = traceCursor.Next() {
 		if err != nil {
-			return err
+			return 0, err
 		}
 		blockNum := binary.BigEndian.Uint64(k)
 		if blockNum > endBlock {
 			break
 		}
+		if blockNum > currentBlock {
+			if common.Stopped(interrupted) != nil {
+				interruptedAt = blockNum
+				break
+			}
+			currentBlock = blockNum
+		}
 		if len(v) != common.AddressLength+1 {
-			return fmt.Errorf(" wrong size of value in CallTraceSet: %x (size %d)", v, len(v))
+			return 0, fmt.Errorf(" wrong size of value in CallTraceSet: %x (size %d)", v, len(v))
 		}
 		mapKey := string(v[:common.AddressLength])
 		if v[common.AddressLength]&1 > 0 {
@@ -149,7 +164,7 @@ func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64
 		case <-checkFlushEvery.C:
 			if needFlush64(froms, bufLimit) {
 				if err := flushBitmaps64(collectorFrom, froms); err != nil {
-					return err
+					return 0, err
 				}
 
 				froms = map[string]*roaring64.Bitmap{}
@@ -157,7 +172,7 @@ func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64
 
 			if needFlush64(tos, bufLimit) {
 				if err := flushBitmaps64(collectorTo, tos); err != nil {
-					return err
+					return 0, err
 				}
 
 				tos = map[string]*roaring64.Bitmap{}
@@ -165,17 +180,17 @@ func promoteCallTraces(logPrefix string, tx kv.RwTx, startBlock, endBlock uint64
 		}
 	}
 	if err = flushBitmaps64(collectorFrom, froms); err != nil {
-		return err
+		return 0, err
 	}
 	if err = flushBitmaps64(collectorTo, tos); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err := finaliseCallTraces(collectorFrom, collectorTo, logPrefix, tx, quit); err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return interruptedAt, nil
 }
 
 func finaliseCallTraces(collectorFrom, collectorTo *etl.Collector, logPrefix string, tx kv.RwTx, quit <-chan struct{}) error {
@@ -281,7 +296,7 @@ func DoUnwindCallTraces(logPrefix string, db kv.RwTx, from, to uint64, ctx conte
 			return err
 		}
 		blockNum := binary.BigEndian.Uint64(k)
-		if blockNum >= from {
+		if blockNum > from {
 			break
 		}
 		if len(v) != common.AddressLength+1 {
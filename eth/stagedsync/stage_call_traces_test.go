@@ -46,25 +46,43 @@ func TestCallTrace(t *testing.T) {
 		assert.NoError(err)
 		return b
 	}
+	// calls from this address are in the last block of the unwound range too
+	addr0 := [20]byte{}
+	froms0 := func() *roaring64.Bitmap {
+		b, err := bitmapdb.Get64(tx, kv.CallFromIndex, addr0[:], 0, 30)
+		assert.NoError(err)
+		return b
+	}
 
 	err := stages.SaveStageProgress(tx, stages.Execution, 30)
 	assert.NoError(err)
 
 	// forward 0->20
-	err = promoteCallTraces("test", tx, 0, 20, 0, time.Nanosecond, ctx.Done(), "")
+	_, err = promoteCallTraces("test", tx, 0, 20, 0, time.Nanosecond, ctx.Done(), nil, "")
 	assert.NoError(err)
 	assert.Equal([]uint64{6, 16}, froms().ToArray())
 	assert.Equal([]uint64{1, 11}, tos().ToArray())
+	assert.Equal([]uint64{0, 10, 20}, froms0().ToArray())
 
 	// unwind 20->10
 	err = DoUnwindCallTraces("test", tx, 20, 10, ctx, "")
 	assert.NoError(err)
 	assert.Equal([]uint64{6}, froms().ToArray())
 	assert.Equal([]uint64{1}, tos().ToArray())
+	assert.Equal([]uint64{0, 10}, froms0().ToArray())
+
+	// forward 10->30, interrupted after block 10
+	interrupted := make(chan struct{})
+	close(interrupted)
+	interruptedAt, err := promoteCallTraces("test", tx, 10, 30, 0, time.Nanosecond, ctx.Done(), interrupted, "")
+	assert.NoError(err)
+	assert.Equal(uint64(11), interruptedAt)
+	assert.Equal([]uint64{1}, tos().ToArray())
 
-	// forward 10->30
-	err = promoteCallTraces("test", tx, 10, 30, 0, time.Nanosecond, ctx.Done(), "")
+	// forward 11->30
+	interruptedAt, err = promoteCallTraces("test", tx, 11, 30, 0, time.Nanosecond, ctx.Done(), nil, "")
 	assert.NoError(err)
+	assert.Zero(interruptedAt)
 	assert.Equal([]uint64{6, 16, 26}, froms().ToArray())
 	assert.Equal([]uint64{1, 11, 21}, tos().ToArray())
 }
@@ -0,0 +1,163 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type CrossCheckCfg struct {
+	db     kv.RwDB
+	client *rpc.Client
+	every  uint64
+	halt   bool
+}
+
+// StageCrossCheckCfg - client is nil if the cross-check is disabled, every is the distance between the checked blocks,
+// halt makes the sync stop at the mismatching block instead of only reporting it
+func StageCrossCheckCfg(db kv.RwDB, client *rpc.Client, every uint64, halt bool) CrossCheckCfg {
+	return CrossCheckCfg{
+		db:     db,
+		client: client,
+		every:  every,
+		halt:   halt,
+	}
+}
+
+// trustedBlock - fields of the block returned by eth_getBlockByNumber of the trusted node
+type trustedBlock struct {
+	Hash         common.Hash `json:"hash"`
+	StateRoot    common.Hash `json:"stateRoot"`
+	ReceiptsRoot common.Hash `json:"receiptsRoot"`
+}
+
+// SpawnCrossCheck compares the state root and the receipts root of every N-th block with the block of the trusted node.
+// Execution and IntermediateHashes already verified that the roots computed by this node are the ones in the header,
+// so a mismatch means a corrupted database, or a consensus bug which made this node accept a wrong chain.
+func SpawnCrossCheck(s *StageState, tx kv.RwTx, cfg CrossCheckCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	to, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	if cfg.client != nil && s.BlockNumber < to {
+		if to, err = crossCheck(s.LogPrefix(), tx, cfg, s.BlockNumber+1, to, ctx); err != nil {
+			return err
+		}
+	}
+	if err = s.Update(tx, to); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crossCheck checks blocks [from, to], and returns the last block checked, which is before to
+// if the trusted node doesn't have the next block yet or is unavailable
+func crossCheck(logPrefix string, tx kv.Tx, cfg CrossCheckCfg, from, to uint64, ctx context.Context) (uint64, error) {
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	blockNum := (from + cfg.every - 1) / cfg.every * cfg.every
+	for ; blockNum <= to; blockNum += cfg.every {
+		select {
+		case <-ctx.Done():
+			return 0, common.ErrStopped
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", blockNum)
+		default:
+		}
+		header := rawdb.ReadHeaderByNumber(tx, blockNum)
+		if header == nil {
+			return 0, fmt.Errorf("[%s] header %d not found", logPrefix, blockNum)
+		}
+		var trusted *trustedBlock
+		if err := cfg.client.CallContext(ctx, &trusted, "eth_getBlockByNumber", hexutil.EncodeUint64(blockNum), false); err != nil {
+			log.Warn(fmt.Sprintf("[%s] Trusted node is unavailable", logPrefix), "block", blockNum, "err", err)
+			return blockNum - 1, nil
+		}
+		if trusted == nil {
+			return blockNum - 1, nil
+		}
+		if err := compareWithTrusted(header, trusted); err != nil {
+			if cfg.halt {
+				return 0, fmt.Errorf("[%s] block %d: %w", logPrefix, blockNum, err)
+			}
+			log.Error(fmt.Sprintf("[%s] Block differs from the trusted node", logPrefix), "block", blockNum, "err", err)
+		}
+	}
+	return to, nil
+}
+
+func compareWithTrusted(header *types.Header, trusted *trustedBlock) error {
+	if header.Root != trusted.StateRoot {
+		return fmt.Errorf("state root %x, trusted node: %x", header.Root, trusted.StateRoot)
+	}
+	if header.ReceiptHash != trusted.ReceiptsRoot {
+		return fmt.Errorf("receipts root %x, trusted node: %x", header.ReceiptHash, trusted.ReceiptsRoot)
+	}
+	if hash := header.Hash(); hash != trusted.Hash {
+		return fmt.Errorf("block hash %x, trusted node: %x", hash, trusted.Hash)
+	}
+	return nil
+}
+
+func UnwindCrossCheck(u *UnwindState, s *StageState, tx kv.RwTx, cfg CrossCheckCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if err = u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneCrossCheck(p *PruneState, tx kv.RwTx, cfg CrossCheckCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
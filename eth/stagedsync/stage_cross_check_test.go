@@ -0,0 +1,63 @@
+package stagedsync
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+type trustedAPI struct {
+	headers map[uint64]*types.Header
+}
+
+func (api *trustedAPI) GetBlockByNumber(number hexutil.Uint64, fullTx bool) (*trustedBlock, error) {
+	h, ok := api.headers[uint64(number)]
+	if !ok {
+		return nil, nil
+	}
+	return &trustedBlock{Hash: h.Hash(), StateRoot: h.Root, ReceiptsRoot: h.ReceiptHash}, nil
+}
+
+func TestCrossCheck(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	trusted := &trustedAPI{headers: map[uint64]*types.Header{}}
+	for i := uint64(0); i <= 25; i++ {
+		h := &types.Header{Number: big.NewInt(int64(i)), Root: common.BytesToHash([]byte{byte(i)}), Difficulty: big.NewInt(1)}
+		rawdb.WriteHeader(tx, h)
+		require.NoError(t, rawdb.WriteCanonicalHash(tx, h.Hash(), i))
+		trusted.headers[i] = h
+	}
+	srv := rpc.NewServer(1)
+	require.NoError(t, srv.RegisterName("eth", trusted))
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+	ctx := context.Background()
+
+	cfg := StageCrossCheckCfg(nil, client, 10, true)
+	checked, err := crossCheck("test", tx, cfg, 1, 25, ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(25), checked)
+
+	// the trusted node is behind
+	delete(trusted.headers, 20)
+	checked, err = crossCheck("test", tx, cfg, 11, 25, ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(19), checked)
+
+	// mismatch stops the sync only in the halt mode
+	trusted.headers[20] = &types.Header{Number: big.NewInt(20), Root: common.HexToHash("0xbad"), Difficulty: big.NewInt(1)}
+	_, err = crossCheck("test", tx, cfg, 11, 25, ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "state root")
+	checked, err = crossCheck("test", tx, StageCrossCheckCfg(nil, client, 10, false), 11, 25, ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(25), checked)
+}
@@ -0,0 +1,147 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type DataGasStatsCfg struct {
+	db          kv.RwDB
+	chainConfig *params.ChainConfig
+}
+
+func StageDataGasStatsCfg(db kv.RwDB, chainConfig *params.ChainConfig) DataGasStatsCfg {
+	return DataGasStatsCfg{
+		db:          db,
+		chainConfig: chainConfig,
+	}
+}
+
+// SpawnDataGasStats writes calldata (and blob) usage of every block (see rawdb.DataGasStats), which is served by
+// erigon_getDataGasStats, so that analytics of data costs don't need to read bodies of blocks
+func SpawnDataGasStats(s *StageState, tx kv.RwTx, cfg DataGasStatsCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	logPrefix := s.LogPrefix()
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return err
+	}
+	if s.BlockNumber == endBlock {
+		return nil
+	}
+
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	startBlock := s.BlockNumber + 1
+	if s.BlockNumber == 0 {
+		startBlock = 0
+	}
+	for n := startBlock; n <= endBlock; n++ {
+		select {
+		case <-ctx.Done():
+			return common.ErrStopped
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", n)
+		default:
+		}
+		hash, err := rawdb.ReadCanonicalHash(tx, n)
+		if err != nil {
+			return err
+		}
+		body := rawdb.ReadBody(tx, hash, n)
+		if body == nil {
+			return fmt.Errorf("body of block %d not found", n)
+		}
+		if err = rawdb.WriteDataGasStats(tx, n, dataGasStats(body.Transactions, cfg.chainConfig.IsIstanbul(n))); err != nil {
+			return err
+		}
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataGasStats counts calldata of the transactions, isIstanbul - non-zero bytes cost by EIP-2028
+func dataGasStats(txs []types.Transaction, isIstanbul bool) *rawdb.DataGasStats {
+	nonZeroGas := params.TxDataNonZeroGasFrontier
+	if isIstanbul {
+		nonZeroGas = params.TxDataNonZeroGasEIP2028
+	}
+	stats := &rawdb.DataGasStats{Txs: uint64(len(txs))}
+	for _, txn := range txs {
+		data := txn.GetData()
+		stats.CalldataBytes += uint64(len(data))
+		for _, b := range data {
+			if b == 0 {
+				stats.CalldataZeroBytes++
+			}
+		}
+	}
+	stats.CalldataGas = stats.CalldataZeroBytes*params.TxDataZeroGas + (stats.CalldataBytes-stats.CalldataZeroBytes)*nonZeroGas
+	return stats
+}
+
+func UnwindDataGasStats(u *UnwindState, s *StageState, tx kv.RwTx, cfg DataGasStatsCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if err = rawdb.TruncateDataGasStats(tx, u.UnwindPoint+1); err != nil {
+		return err
+	}
+	if err = u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneDataGasStats(p *PruneState, tx kv.RwTx, cfg DataGasStatsCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
 	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/params"
@@ -47,6 +48,7 @@ type ChangeSetHook func(blockNum uint64, wr *state.ChangeSetWriter)
 type ExecuteBlockCfg struct {
 	db            kv.RwDB
 	batchSize     datasize.ByteSize
+	commit        ethconfig.Commit
 	prune         prune.Mode
 	changeSetHook ChangeSetHook
 	chainConfig   *params.ChainConfig
@@ -54,32 +56,43 @@ type ExecuteBlockCfg struct {
 	vmConfig      *vm.Config
 	tmpdir        string
 	stateStream   bool
+	noActivity    bool // don't maintain first and last activity of addresses
 	accumulator   *shards.Accumulator
+	stateCache    *state.SharedCache
+	profiler      *state.AccessProfiler
 }
 
 func StageExecuteBlocksCfg(
 	kv kv.RwDB,
 	prune prune.Mode,
 	batchSize datasize.ByteSize,
+	commit ethconfig.Commit,
 	changeSetHook ChangeSetHook,
 	chainConfig *params.ChainConfig,
 	engine consensus.Engine,
 	vmConfig *vm.Config,
 	accumulator *shards.Accumulator,
+	stateCache *state.SharedCache,
+	profiler *state.AccessProfiler,
 	stateStream bool,
+	noAddressActivity bool,
 	tmpdir string,
 ) ExecuteBlockCfg {
 	return ExecuteBlockCfg{
 		db:            kv,
 		prune:         prune,
 		batchSize:     batchSize,
+		commit:        commit,
 		changeSetHook: changeSetHook,
 		chainConfig:   chainConfig,
 		engine:        engine,
 		vmConfig:      vmConfig,
 		tmpdir:        tmpdir,
 		accumulator:   accumulator,
+		stateCache:    stateCache,
+		profiler:      profiler,
 		stateStream:   stateStream,
+		noActivity:    noAddressActivity,
 	}
 }
 
@@ -105,7 +118,7 @@ func executeBlock(
 	initialCycle bool,
 ) error {
 	blockNum := block.NumberU64()
-	stateReader, stateWriter := newStateReaderWriter(batch, tx, blockNum, block.Hash(), writeChangesets, cfg.accumulator, initialCycle, cfg.stateStream)
+	stateReader, stateWriter := newStateReaderWriter(batch, tx, blockNum, block.Hash(), writeChangesets, cfg.accumulator, cfg.stateCache, cfg.profiler, initialCycle, cfg.stateStream)
 
 	// where the magic happens
 	getHeader := func(hash common.Hash, number uint64) *types.Header { return rawdb.ReadHeader(tx, hash, number) }
@@ -124,6 +137,12 @@ func executeBlock(
 		}
 	}
 
+	if !cfg.noActivity {
+		if err = updateAddressActivity(batch, block, callTracer); err != nil {
+			return fmt.Errorf("updating address activity: %w", err)
+		}
+	}
+
 	if cfg.changeSetHook != nil {
 		if hasChangeSet, ok := stateWriter.(HasChangeSetWriter); ok {
 			cfg.changeSetHook(blockNum, hasChangeSet.ChangeSetWriter())
@@ -192,6 +211,8 @@ func newStateReaderWriter(
 	blockHash common.Hash,
 	writeChangesets bool,
 	accumulator *shards.Accumulator,
+	stateCache *state.SharedCache,
+	profiler *state.AccessProfiler,
 	initialCycle bool,
 	stateStream bool,
 ) (state.StateReader, state.WriterWithChangeSets) {
@@ -212,7 +233,7 @@ func newStateReaderWriter(
 		stateWriter = state.NewPlainStateWriterNoHistory(batch).SetAccumulator(accumulator)
 	}
 
-	return stateReader, stateWriter
+	return profiler.Reader(state.AccessSourceExecution, stateCache.WriteReader(stateReader)), stateCache.Writer(stateWriter)
 }
 
 func SpawnExecuteBlocksStage(s *StageState, u Unwinder, tx kv.RwTx, toBlock uint64, ctx context.Context, cfg ExecuteBlockCfg, initialCycle bool) (err error) {
@@ -248,6 +269,14 @@ func SpawnExecuteBlocksStage(s *StageState, u Unwinder, tx kv.RwTx, toBlock uint
 		log.Info(fmt.Sprintf("[%s] Blocks execution", logPrefix), "from", s.BlockNumber, "to", to)
 	}
 
+	// The state cache follows the state of the stage, it forgets all entries if the stage fails before EndWrite
+	stageHash, err := rawdb.ReadCanonicalHash(tx, s.BlockNumber)
+	if err != nil {
+		return err
+	}
+	cfg.stateCache.BeginWrite(s.BlockNumber, stageHash)
+	executed := true
+
 	var batch ethdb.DbWithPendingMutations
 	batch = olddb.NewBatch(tx, quit)
 	defer batch.Rollback()
@@ -256,6 +285,7 @@ func SpawnExecuteBlocksStage(s *StageState, u Unwinder, tx kv.RwTx, toBlock uint
 	defer logEvery.Stop()
 	stageProgress := s.BlockNumber
 	logBlock := stageProgress
+	trigger := newCommitTrigger(cfg.commit)
 	logTx, lastLogTx := uint64(0), uint64(0)
 	logTime := time.Now()
 	var gas uint64
@@ -266,6 +296,12 @@ Loop:
 		if stoppedErr = common.Stopped(quit); stoppedErr != nil {
 			break
 		}
+		select {
+		case <-s.Interrupted():
+			log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", stageProgress)
+			break Loop
+		default:
+		}
 		var err error
 		var block *types.Block
 		if block, err = readBlock(blockNum, tx); err != nil {
@@ -291,20 +327,27 @@ Loop:
 		if err = executeBlock(block, tx, batch, cfg, *cfg.vmConfig, writeChangeSets, writeReceipts, writeCallTraces, checkTEVMCode, initialCycle); err != nil {
 			log.Error(fmt.Sprintf("[%s] Execution failed", logPrefix), "block", blockNum, "hash", block.Hash().String(), "error", err)
 			u.UnwindTo(blockNum-1, block.Hash())
+			executed = false // the cache has changes of the failed block
 			break Loop
 		}
-		stageProgress = blockNum
+		stageProgress, stageHash = blockNum, block.Hash()
 
-		updateProgress := batch.BatchSize() >= int(cfg.batchSize)
-		if updateProgress {
+		flush := batch.BatchSize() >= int(cfg.batchSize)
+		commit := false
+		if !useExternalTx {
+			if commit, err = trigger.due(tx, batch.BatchSize(), flush); err != nil {
+				return err
+			}
+		}
+		if flush || commit {
 			if err = batch.Commit(); err != nil {
 				return err
 			}
-			if !useExternalTx {
+			if commit {
 				if err = s.Update(tx, stageProgress); err != nil {
 					return err
 				}
-				if err = tx.Commit(); err != nil {
+				if err = trigger.commit(tx); err != nil {
 					return err
 				}
 				tx, err = cfg.db.BeginRw(context.Background())
@@ -343,6 +386,9 @@ Loop:
 			return err
 		}
 	}
+	if executed {
+		cfg.stateCache.EndWrite(stageProgress, stageHash)
+	}
 
 	log.Info(fmt.Sprintf("[%s] Completed on", logPrefix), "block", stageProgress)
 	return stoppedErr
@@ -416,18 +462,29 @@ func UnwindExecutionStage(u *UnwindState, s *StageState, tx kv.RwTx, ctx context
 	logPrefix := u.LogPrefix()
 	log.Info(fmt.Sprintf("[%s] Unwind Execution", logPrefix), "from", s.BlockNumber, "to", u.UnwindPoint)
 
+	// If canonical hashes are already replaced by the new fork, the state cache forgets all entries
+	stageHash, err := rawdb.ReadCanonicalHash(tx, s.BlockNumber)
+	if err != nil {
+		return err
+	}
+	cfg.stateCache.BeginWrite(s.BlockNumber, stageHash)
 	if err = unwindExecutionStage(u, s, tx, quit, cfg, initialCycle); err != nil {
 		return err
 	}
 	if err = u.Done(tx); err != nil {
 		return err
 	}
+	unwindHash, err := rawdb.ReadCanonicalHash(tx, u.UnwindPoint)
+	if err != nil {
+		return err
+	}
 
 	if !useExternalTx {
 		if err = tx.Commit(); err != nil {
 			return err
 		}
 	}
+	cfg.stateCache.EndWrite(u.UnwindPoint, unwindHash)
 	return nil
 }
 
@@ -453,6 +510,7 @@ func unwindExecutionStage(u *UnwindState, s *StageState, tx kv.RwTx, quit <-chan
 	}
 
 	if err := changes.Load(logPrefix, tx, stateBucket, func(k, v []byte, table etl.CurrentTableReader, next etl.LoadNextFunc) error {
+		cfg.stateCache.Evict(k)
 		if len(k) == 20 {
 			if len(v) > 0 {
 				var acc accounts.Account
@@ -535,6 +593,12 @@ func unwindExecutionStage(u *UnwindState, s *StageState, tx kv.RwTx, quit <-chan
 		return fmt.Errorf("walking epoch: %w", err)
 	}
 
+	if !cfg.noActivity {
+		if err := unwindAddressActivity(tx, s.BlockNumber, u.UnwindPoint); err != nil {
+			return fmt.Errorf("unwinding address activity: %w", err)
+		}
+	}
+
 	// Truncate CallTraceSet
 	keyStart := dbutils.EncodeBlockNumber(u.UnwindPoint + 1)
 	c, err := tx.RwCursorDupSort(kv.CallTraceSet)
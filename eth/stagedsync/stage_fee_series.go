@@ -0,0 +1,171 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type FeeSeriesCfg struct {
+	db kv.RwDB
+}
+
+func StageFeeSeriesCfg(db kv.RwDB) FeeSeriesCfg {
+	return FeeSeriesCfg{
+		db: db,
+	}
+}
+
+// SpawnFeeSeries maintains per-epoch aggregates of base fee, gas usage and transaction tips (see rawdb.FeeEpoch),
+// which are served by erigon_getFeeSeries. The epoch containing the stage progress is incomplete
+// and gets recomputed from its first block on the next run.
+func SpawnFeeSeries(s *StageState, tx kv.RwTx, cfg FeeSeriesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	logPrefix := s.LogPrefix()
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return err
+	}
+	if s.BlockNumber == endBlock {
+		return nil
+	}
+
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	for epoch := rawdb.FeeEpochOf(s.BlockNumber + 1); epoch <= rawdb.FeeEpochOf(endBlock); epoch++ {
+		select {
+		case <-ctx.Done():
+			return common.ErrStopped
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", epoch*rawdb.FeeEpochSize)
+		default:
+		}
+		from, to := epoch*rawdb.FeeEpochSize, (epoch+1)*rawdb.FeeEpochSize-1
+		if to > endBlock {
+			to = endBlock
+		}
+		e, err := aggregateFeeEpoch(tx, from, to)
+		if err != nil {
+			return err
+		}
+		if err = rawdb.WriteFeeEpoch(tx, epoch, e); err != nil {
+			return err
+		}
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateFeeEpoch reads canonical blocks [from, to] and aggregates their fee data
+func aggregateFeeEpoch(tx kv.Tx, from, to uint64) (*rawdb.FeeEpoch, error) {
+	e := &rawdb.FeeEpoch{
+		BaseFeeSum: new(big.Int),
+		MinBaseFee: new(big.Int),
+		MaxBaseFee: new(big.Int),
+	}
+	var tips []*uint256.Int
+	for n := from; n <= to; n++ {
+		hash, err := rawdb.ReadCanonicalHash(tx, n)
+		if err != nil {
+			return nil, err
+		}
+		block := rawdb.ReadBlock(tx, hash, n)
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found", n)
+		}
+		e.Blocks++
+		e.GasUsed += block.GasUsed()
+		e.GasLimit += block.GasLimit()
+		var baseFee *uint256.Int
+		if bf := block.BaseFee(); bf != nil {
+			if e.BaseFeeBlocks == 0 || bf.Cmp(e.MinBaseFee) < 0 {
+				e.MinBaseFee.Set(bf)
+			}
+			if bf.Cmp(e.MaxBaseFee) > 0 {
+				e.MaxBaseFee.Set(bf)
+			}
+			e.BaseFeeSum.Add(e.BaseFeeSum, bf)
+			e.BaseFeeBlocks++
+			baseFee, _ = uint256.FromBig(bf)
+		}
+		for _, txn := range block.Transactions() {
+			tips = append(tips, txn.GetEffectiveGasTip(baseFee))
+		}
+	}
+	e.Txs = uint64(len(tips))
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Lt(tips[j]) })
+		e.TipPercentiles = make([]*big.Int, len(rawdb.FeeEpochTipPercentiles))
+		for i, p := range rawdb.FeeEpochTipPercentiles {
+			e.TipPercentiles[i] = tips[(len(tips)-1)*p/100].ToBig()
+		}
+	}
+	return e, nil
+}
+
+func UnwindFeeSeries(u *UnwindState, s *StageState, tx kv.RwTx, cfg FeeSeriesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	// Epoch containing the unwind point (if it's not its last block) is recomputed by the next forward run
+	if err = rawdb.TruncateFeeEpochs(tx, rawdb.FeeEpochOf(u.UnwindPoint+1)); err != nil {
+		return err
+	}
+	if err = u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneFeeSeries(p *PruneState, tx kv.RwTx, cfg FeeSeriesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
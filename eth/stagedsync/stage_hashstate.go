@@ -12,6 +12,7 @@ import (
 	"github.com/ledgerwatch/erigon/common/changeset"
 	"github.com/ledgerwatch/erigon/common/dbutils"
 	"github.com/ledgerwatch/erigon/common/etl"
+	"github.com/ledgerwatch/erigon/common/keccakcache"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
 	"github.com/ledgerwatch/log/v3"
 )
@@ -57,14 +58,20 @@ func SpawnHashStateStage(s *StageState, tx kv.RwTx, cfg HashStateCfg, ctx contex
 	if to > s.BlockNumber+16 {
 		log.Info(fmt.Sprintf("[%s] Promoting plain state", logPrefix), "from", s.BlockNumber, "to", to)
 	}
+	// the promotion is idempotent, so when it's interrupted the next run repeats it from the saved progress
+	quit, release := s.quitOrInterrupted(ctx.Done())
+	defer release()
 	if s.BlockNumber == 0 { // Initial hashing of the state is performed at the previous stage
-		if err := PromoteHashedStateCleanly(logPrefix, tx, cfg, ctx.Done()); err != nil {
-			return err
-		}
+		err = PromoteHashedStateCleanly(logPrefix, tx, cfg, quit)
 	} else {
-		if err := promoteHashedStateIncrementally(logPrefix, s, s.BlockNumber, to, tx, cfg, ctx.Done()); err != nil {
-			return err
-		}
+		err = promoteHashedStateIncrementally(logPrefix, s, s.BlockNumber, to, tx, cfg, quit)
+	}
+	if s.droppedByInterrupt(err, ctx.Done()) {
+		log.Info(fmt.Sprintf("[%s] Interrupted, the promotion is dropped", logPrefix), "from", s.BlockNumber, "to", to)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
 	if err = s.Update(tx, to); err != nil {
@@ -208,16 +215,16 @@ func transformPlainStateKey(key []byte) ([]byte, error) {
 	switch len(key) {
 	case common.AddressLength:
 		// account
-		hash, err := common.HashData(key)
+		hash, err := keccakcache.Shared().HashAddress(key)
 		return hash[:], err
 	case common.AddressLength + common.IncarnationLength + common.HashLength:
 		// storage
-		addrHash, err := common.HashData(key[:common.AddressLength])
+		addrHash, err := keccakcache.Shared().HashAddress(key[:common.AddressLength])
 		if err != nil {
 			return nil, err
 		}
 		inc := binary.BigEndian.Uint64(key[common.AddressLength:])
-		secKey, err := common.HashData(key[common.AddressLength+common.IncarnationLength:])
+		secKey, err := keccakcache.Shared().HashStorageKey(key[common.AddressLength+common.IncarnationLength:])
 		if err != nil {
 			return nil, err
 		}
@@ -235,7 +242,7 @@ func transformContractCodeKey(key []byte) ([]byte, error) {
 	}
 	address, incarnation := dbutils.PlainParseStoragePrefix(key)
 
-	addrHash, err := common.HashData(address[:])
+	addrHash, err := keccakcache.Shared().HashAddress(address[:])
 	if err != nil {
 		return nil, err
 	}
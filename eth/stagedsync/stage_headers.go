@@ -145,6 +145,13 @@ func HeadersForward(
 			cfg.penalize(ctx, penalties)
 			maxRequests--
 		}
+		// Fill gaps below anchors by segments from other peers, in parallel with the requests above
+		for _, fillReq := range cfg.hd.RequestSegmentFills(currentTime, 5 /* timeout */) {
+			if cfg.headerReqSend(ctx, fillReq) == nil {
+				break
+			}
+			log.Debug("Sent fill request", "height", fillReq.Number)
+		}
 
 		// Send skeleton request if required
 		req = cfg.hd.RequestSkeleton()
@@ -177,9 +184,13 @@ func HeadersForward(
 			break
 		}
 		timer := time.NewTimer(1 * time.Second)
+		interrupted := false
 		select {
 		case <-ctx.Done():
 			stopped = true
+		case <-s.Interrupted():
+			log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "highest", headerInserter.GetHighest())
+			interrupted = true
 		case <-logEvery.C:
 			progress := cfg.hd.Progress()
 			logProgressHeaders(logPrefix, prevProgress, progress)
@@ -190,6 +201,9 @@ func HeadersForward(
 			log.Debug("headerLoop woken up by the incoming request")
 		}
 		timer.Stop()
+		if interrupted {
+			break
+		}
 	}
 	if headerInserter.Unwind() {
 		u.UnwindTo(headerInserter.UnwindPoint(), common.Hash{})
@@ -0,0 +1,151 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type HistoryFilesCfg struct {
+	db     kv.RwDB
+	keep   uint64
+	tmpdir string
+}
+
+// StageHistoryFilesCfg - keep is the amount of recent blocks, changesets of which stay in the DB to allow unwinds
+func StageHistoryFilesCfg(db kv.RwDB, keep uint64, tmpdir string) HistoryFilesCfg {
+	return HistoryFilesCfg{
+		db:     db,
+		keep:   keep,
+		tmpdir: tmpdir,
+	}
+}
+
+// SpawnHistoryFiles moves changesets and history indices of old blocks into immutable history files,
+// by steps of historyfiles.StepSize blocks. It does nothing unless the DB is wrapped by historyfiles.WrapDB.
+func SpawnHistoryFiles(s *StageState, tx kv.RwTx, cfg HistoryFilesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	ftx, ok := tx.(historyfiles.Tx)
+	if !ok {
+		return nil
+	}
+	files := ftx.HistoryFiles()
+	logPrefix := s.LogPrefix()
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return err
+	}
+
+	// files are built from the changesets and replace the history indices, so both indices must be ready
+	to := endBlock
+	for _, stage := range []stages.SyncStage{stages.AccountHistoryIndex, stages.StorageHistoryIndex} {
+		progress, err := stages.GetStageProgress(tx, stage)
+		if err != nil {
+			return err
+		}
+		if progress < to {
+			to = progress
+		}
+	}
+	if to > cfg.keep {
+		to = (to - cfg.keep) / historyfiles.StepSize * historyfiles.StepSize
+	} else {
+		to = 0
+	}
+	from, err := historyfiles.ReadEnd(tx)
+	if err != nil {
+		return err
+	}
+
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+	for ; from+historyfiles.StepSize <= to; from += historyfiles.StepSize {
+		stepEnd := from + historyfiles.StepSize
+		log.Info(fmt.Sprintf("[%s] Building history files", logPrefix), "from", from, "to", stepEnd)
+		if err = files.Build(ctx, tx, from, stepEnd, logPrefix, cfg.tmpdir); err != nil {
+			return err
+		}
+		// indices are pruned first, keys to prune are taken from the changesets
+		for _, table := range []string{kv.AccountChangeSet, kv.StorageChangeSet} {
+			if err = pruneHistoryIndex(tx, table, logPrefix, cfg.tmpdir, stepEnd, ctx); err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{kv.AccountChangeSet, kv.StorageChangeSet} {
+			if err = pruneChangeSets(tx, logPrefix, table, stepEnd, logEvery, ctx); err != nil {
+				return err
+			}
+		}
+		if err = historyfiles.WriteEnd(tx, stepEnd); err != nil {
+			return err
+		}
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func UnwindHistoryFiles(u *UnwindState, s *StageState, tx kv.RwTx, cfg HistoryFilesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	end, err := historyfiles.ReadEnd(tx)
+	if err != nil {
+		return err
+	}
+	if u.UnwindPoint < end {
+		return fmt.Errorf("[%s] can't unwind to block %d, changesets before block %d were moved to history files", u.LogPrefix(), u.UnwindPoint, end)
+	}
+	if err = u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneHistoryFiles(p *PruneState, tx kv.RwTx, cfg HistoryFilesCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
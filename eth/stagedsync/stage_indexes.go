@@ -73,9 +73,14 @@ func SpawnAccountHistoryIndex(s *StageState, tx kv.RwTx, cfg HistoryCfg, ctx con
 		startBlock = pruneTo
 	}
 
-	if err := promoteHistory(logPrefix, tx, kv.AccountChangeSet, startBlock, stopChangeSetsLookupAt, cfg, quitCh); err != nil {
+	interruptedAt, err := promoteHistory(logPrefix, tx, kv.AccountChangeSet, startBlock, stopChangeSetsLookupAt, cfg, quitCh, s.Interrupted())
+	if err != nil {
 		return err
 	}
+	if interruptedAt > 0 {
+		endBlock = interruptedAt - 1
+		log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", endBlock)
+	}
 
 	if err := s.Update(tx, endBlock); err != nil {
 		return err
@@ -116,9 +121,14 @@ func SpawnStorageHistoryIndex(s *StageState, tx kv.RwTx, cfg HistoryCfg, ctx con
 	}
 	stopChangeSetsLookupAt := executionAt + 1
 
-	if err := promoteHistory(logPrefix, tx, kv.StorageChangeSet, startChangeSetsLookupAt, stopChangeSetsLookupAt, cfg, quitCh); err != nil {
+	interruptedAt, err := promoteHistory(logPrefix, tx, kv.StorageChangeSet, startChangeSetsLookupAt, stopChangeSetsLookupAt, cfg, quitCh, s.Interrupted())
+	if err != nil {
 		return err
 	}
+	if interruptedAt > 0 {
+		executionAt = interruptedAt - 1
+		log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", executionAt)
+	}
 
 	if err := s.Update(tx, executionAt); err != nil {
 		return err
@@ -131,7 +141,9 @@ func SpawnStorageHistoryIndex(s *StageState, tx kv.RwTx, cfg HistoryCfg, ctx con
 	return nil
 }
 
-func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start, stop uint64, cfg HistoryCfg, quit <-chan struct{}) error {
+// promoteHistory indexes changes of blocks [start, stop). On interrupted it stops before the next block and indexes
+// the blocks before it, interruptedAt is that block then (0 - not interrupted).
+func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start, stop uint64, cfg HistoryCfg, quit, interrupted <-chan struct{}) (interruptedAt uint64, err error) {
 	logEvery := time.NewTicker(30 * time.Second)
 	defer logEvery.Stop()
 
@@ -142,6 +154,7 @@ func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start,
 	collectorUpdates := etl.NewCollector(cfg.tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
 	defer collectorUpdates.Close(logPrefix)
 
+	currentBlock := start
 	if err := changeset.Walk(tx, changesetBucket, dbutils.EncodeBlockNumber(start), 0, func(blockN uint64, k, v []byte) (bool, error) {
 		if blockN >= stop {
 			return false, nil
@@ -149,6 +162,13 @@ func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start,
 		if err := common.Stopped(quit); err != nil {
 			return false, err
 		}
+		if blockN > currentBlock {
+			if common.Stopped(interrupted) != nil {
+				interruptedAt = blockN
+				return false, nil
+			}
+			currentBlock = blockN
+		}
 
 		k = dbutils.CompositeKeyWithoutIncarnation(k)
 
@@ -177,11 +197,11 @@ func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start,
 
 		return true, nil
 	}); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err := flushBitmaps64(collectorUpdates, updates); err != nil {
-		return err
+		return 0, err
 	}
 
 	var currentBitmap = roaring64.New()
@@ -223,9 +243,9 @@ func promoteHistory(logPrefix string, tx kv.RwTx, changesetBucket string, start,
 	}
 
 	if err := collectorUpdates.Load(logPrefix, tx, changeset.Mapper[changesetBucket].IndexBucket, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return interruptedAt, nil
 }
 
 func UnwindAccountHistoryIndex(u *UnwindState, s *StageState, tx kv.RwTx, cfg HistoryCfg, ctx context.Context) (err error) {
@@ -40,9 +40,9 @@ func TestIndexGenerator_GenerateIndex_SimpleCase(t *testing.T) {
 			cfgCopy := cfg
 			cfgCopy.bufLimit = 10
 			cfgCopy.flushEvery = time.Microsecond
-			err = promoteHistory("logPrefix", tx, csBucket, 0, uint64(blocksNum/2), cfgCopy, nil)
+			_, err = promoteHistory("logPrefix", tx, csBucket, 0, uint64(blocksNum/2), cfgCopy, nil, nil)
 			require.NoError(t, err)
-			err = promoteHistory("logPrefix", tx, csBucket, uint64(blocksNum/2), uint64(blocksNum), cfgCopy, nil)
+			_, err = promoteHistory("logPrefix", tx, csBucket, uint64(blocksNum/2), uint64(blocksNum), cfgCopy, nil, nil)
 			require.NoError(t, err)
 
 			checkIndex(t, tx, csInfo.IndexBucket, addrs[0], expecedIndexes[string(addrs[0])])
@@ -75,7 +75,7 @@ func TestIndexGenerator_Truncate(t *testing.T) {
 		cfgCopy := cfg
 		cfgCopy.bufLimit = 10
 		cfgCopy.flushEvery = time.Microsecond
-		err = promoteHistory("logPrefix", tx, csbucket, 0, uint64(2100), cfgCopy, nil)
+		_, err = promoteHistory("logPrefix", tx, csbucket, 0, uint64(2100), cfgCopy, nil, nil)
 		require.NoError(t, err)
 
 		reduceSlice := func(arr []uint64, timestamtTo uint64) []uint64 {
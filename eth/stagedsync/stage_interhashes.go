@@ -76,15 +76,21 @@ func SpawnIntermediateHashesStage(s *StageState, u Unwinder, tx kv.RwTx, cfg Tri
 	if to > s.BlockNumber+16 {
 		log.Info(fmt.Sprintf("[%s] Generating intermediate hashes", logPrefix), "from", s.BlockNumber, "to", to)
 	}
+	// the calculation of the root stops on interrupt, not the load of the trie after it
+	stop, release := s.quitOrInterrupted(quit)
+	defer release()
 	var root common.Hash
 	if s.BlockNumber == 0 {
-		if root, err = RegenerateIntermediateHashes(logPrefix, tx, cfg, expectedRootHash, quit); err != nil {
-			return trie.EmptyRoot, err
-		}
+		root, err = regenerateIntermediateHashes(logPrefix, tx, cfg, expectedRootHash, quit, stop)
 	} else {
-		if root, err = incrementIntermediateHashes(logPrefix, s, tx, to, cfg, expectedRootHash, quit); err != nil {
-			return trie.EmptyRoot, err
-		}
+		root, err = incrementIntermediateHashes(logPrefix, s, tx, to, cfg, expectedRootHash, quit, stop)
+	}
+	if s.droppedByInterrupt(err, quit) {
+		log.Info(fmt.Sprintf("[%s] Interrupted, the trie is dropped", logPrefix), "from", s.BlockNumber, "to", to)
+		return trie.EmptyRoot, nil
+	}
+	if err != nil {
+		return trie.EmptyRoot, err
 	}
 
 	if err == nil {
@@ -111,6 +117,11 @@ func SpawnIntermediateHashesStage(s *StageState, u Unwinder, tx kv.RwTx, cfg Tri
 }
 
 func RegenerateIntermediateHashes(logPrefix string, db kv.RwTx, cfg TrieCfg, expectedRootHash common.Hash, quit <-chan struct{}) (common.Hash, error) {
+	return regenerateIntermediateHashes(logPrefix, db, cfg, expectedRootHash, quit, quit)
+}
+
+// regenerateIntermediateHashes stops the calculation of the root on stop, and the load of the trie on quit
+func regenerateIntermediateHashes(logPrefix string, db kv.RwTx, cfg TrieCfg, expectedRootHash common.Hash, quit, stop <-chan struct{}) (common.Hash, error) {
 	log.Info(fmt.Sprintf("[%s] Regeneration trie hashes started", logPrefix))
 	defer log.Info(fmt.Sprintf("[%s] Regeneration ended", logPrefix))
 	_ = db.ClearBucket(kv.TrieOfAccounts)
@@ -128,7 +139,7 @@ func RegenerateIntermediateHashes(logPrefix string, db kv.RwTx, cfg TrieCfg, exp
 	if err := loader.Reset(trie.NewRetainList(0), accTrieCollectorFunc, stTrieCollectorFunc, false); err != nil {
 		return trie.EmptyRoot, err
 	}
-	hash, err := loader.CalcTrieRoot(db, []byte{}, quit)
+	hash, err := loader.CalcTrieRoot(db, []byte{}, stop)
 	if err != nil {
 		return trie.EmptyRoot, err
 	}
@@ -335,8 +346,9 @@ func (p *HashPromoter) Unwind(logPrefix string, s *StageState, u *UnwindState, s
 	return nil
 }
 
-func incrementIntermediateHashes(logPrefix string, s *StageState, db kv.RwTx, to uint64, cfg TrieCfg, expectedRootHash common.Hash, quit <-chan struct{}) (common.Hash, error) {
-	p := NewHashPromoter(db, quit)
+// incrementIntermediateHashes stops the calculation of the root on stop, and the load of the trie on quit
+func incrementIntermediateHashes(logPrefix string, s *StageState, db kv.RwTx, to uint64, cfg TrieCfg, expectedRootHash common.Hash, quit, stop <-chan struct{}) (common.Hash, error) {
+	p := NewHashPromoter(db, stop)
 	p.TempDir = cfg.tmpDir
 	rl := trie.NewRetainList(0)
 	collect := func(k, v []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
@@ -362,7 +374,7 @@ func incrementIntermediateHashes(logPrefix string, s *StageState, db kv.RwTx, to
 	if err := loader.Reset(rl, accTrieCollectorFunc, stTrieCollectorFunc, false); err != nil {
 		return trie.EmptyRoot, err
 	}
-	hash, err := loader.CalcTrieRoot(db, []byte{}, quit)
+	hash, err := loader.CalcTrieRoot(db, []byte{}, stop)
 	if err != nil {
 		return trie.EmptyRoot, err
 	}
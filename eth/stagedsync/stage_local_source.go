@@ -0,0 +1,235 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// BlockSource gives canonical blocks from the local disk instead of peers, see turbo/stages/blocksource
+type BlockSource interface {
+	View(ctx context.Context, f func(r BlockReader) error) error
+}
+
+// BlockReader reads blocks of the source within one transaction
+type BlockReader interface {
+	// Head is the highest block, which has both header and body
+	Head() (uint64, error)
+	// Header returns canonical header, nil if there is no such block
+	Header(number uint64) (*types.Header, error)
+	// Body returns the body of the block, nil if there is no such body
+	Body(number uint64, hash common.Hash) (*types.Body, error)
+}
+
+type LocalSourceCfg struct {
+	db     kv.RwDB
+	source BlockSource
+	to     uint64 // blocks after it are not taken from the source, 0 - all of them
+}
+
+func StageLocalSourceCfg(db kv.RwDB, source BlockSource, to uint64) LocalSourceCfg {
+	return LocalSourceCfg{db: db, source: source, to: to}
+}
+
+// UseLocalSource makes Headers and Bodies stages read blocks from the source instead of downloading them.
+// All other stages run as usual, so the blocks are executed and indexed from scratch.
+func UseLocalSource(ctx context.Context, stagesList []*Stage, cfg LocalSourceCfg) []*Stage {
+	for _, s := range stagesList {
+		switch s.ID {
+		case stages.Headers:
+			s.Description = "Read headers from local source"
+			s.Forward = func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return HeadersFromSourceForward(s, ctx, tx, cfg)
+			}
+		case stages.Bodies:
+			s.Description = "Read block bodies from local source"
+			s.Forward = func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				return BodiesFromSourceForward(s, ctx, tx, cfg)
+			}
+		}
+	}
+	return stagesList
+}
+
+// sourceHead is the last block to take from the source
+func (cfg LocalSourceCfg) sourceHead(r BlockReader) (uint64, error) {
+	head, err := r.Head()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.to != 0 && cfg.to < head {
+		return cfg.to, nil
+	}
+	return head, nil
+}
+
+// HeadersFromSourceForward inserts canonical headers of the source after the current progress. Headers are trusted,
+// only their linkage to our chain is checked: the source must be the same chain, starting with the same genesis.
+func HeadersFromSourceForward(s *StageState, ctx context.Context, tx kv.RwTx, cfg LocalSourceCfg) error {
+	var err error
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	logPrefix := s.LogPrefix()
+	headerProgress := s.BlockNumber
+	hash, err := rawdb.ReadCanonicalHash(tx, headerProgress)
+	if err != nil {
+		return err
+	}
+	localTd, err := rawdb.ReadTd(tx, hash, headerProgress)
+	if err != nil {
+		return err
+	}
+	if localTd == nil {
+		return fmt.Errorf("[%s] total difficulty of block %d not found", logPrefix, headerProgress)
+	}
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+	headerInserter := headerdownload.NewHeaderInserter(logPrefix, localTd, headerProgress)
+
+	if err = cfg.source.View(ctx, func(r BlockReader) error {
+		to, err := cfg.sourceHead(r)
+		if err != nil {
+			return err
+		}
+		if to <= headerProgress {
+			return nil
+		}
+		log.Info(fmt.Sprintf("[%s] Reading headers from local source...", logPrefix), "from", headerProgress, "to", to)
+		header, err := r.Header(headerProgress)
+		if err != nil {
+			return err
+		}
+		if header == nil || header.Hash() != hash {
+			return fmt.Errorf("[%s] block %d %x is not in the local source, is it another chain?", logPrefix, headerProgress, hash)
+		}
+		parentHash := hash
+		for blockNum := headerProgress + 1; blockNum <= to; blockNum++ {
+			if header, err = r.Header(blockNum); err != nil {
+				return err
+			}
+			if header == nil {
+				return fmt.Errorf("[%s] header %d not found in the local source", logPrefix, blockNum)
+			}
+			if header.ParentHash != parentHash {
+				return fmt.Errorf("[%s] header %d of the local source has parent %x, expected %x", logPrefix, blockNum, header.ParentHash, parentHash)
+			}
+			if err = headerInserter.FeedHeader(tx, header, blockNum); err != nil {
+				return err
+			}
+			parentHash = header.Hash()
+
+			select {
+			case <-ctx.Done():
+				return common.ErrStopped
+			case <-logEvery.C:
+				log.Info(fmt.Sprintf("[%s] Read headers", logPrefix), "number", blockNum)
+			default:
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if headerInserter.GetHighest() != 0 {
+		if err = fixCanonicalChain(logPrefix, logEvery, headerInserter.GetHighest(), headerInserter.GetHighestHash(), tx); err != nil {
+			return fmt.Errorf("fix canonical chain: %w", err)
+		}
+		log.Info(fmt.Sprintf("[%s] Processed", logPrefix), "highest inserted", headerInserter.GetHighest(), "age", common.PrettyAge(time.Unix(int64(headerInserter.GetHighestTimestamp()), 0)))
+		stageHeadersGauge.Set(headerInserter.GetHighest())
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BodiesFromSourceForward writes bodies of the source for all canonical headers after the current progress.
+// Bodies are checked against transaction and uncle roots of our headers.
+func BodiesFromSourceForward(s *StageState, ctx context.Context, tx kv.RwTx, cfg LocalSourceCfg) error {
+	var err error
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	logPrefix := s.LogPrefix()
+	headerProgress, err := stages.GetStageProgress(tx, stages.Headers)
+	if err != nil {
+		return err
+	}
+	bodyProgress := s.BlockNumber
+	if bodyProgress >= headerProgress {
+		return nil
+	}
+	log.Info(fmt.Sprintf("[%s] Reading bodies from local source...", logPrefix), "from", bodyProgress, "to", headerProgress)
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	if err = cfg.source.View(ctx, func(r BlockReader) error {
+		for blockNum := bodyProgress + 1; blockNum <= headerProgress; blockNum++ {
+			hash, err := rawdb.ReadCanonicalHash(tx, blockNum)
+			if err != nil {
+				return err
+			}
+			header := rawdb.ReadHeader(tx, hash, blockNum)
+			if header == nil {
+				return fmt.Errorf("[%s] canonical header %d not found", logPrefix, blockNum)
+			}
+			body, err := r.Body(blockNum, hash)
+			if err != nil {
+				return err
+			}
+			if body == nil {
+				return fmt.Errorf("[%s] body %d %x not found in the local source", logPrefix, blockNum, hash)
+			}
+			if txHash := types.DeriveSha(types.Transactions(body.Transactions)); txHash != header.TxHash {
+				return fmt.Errorf("[%s] body %d of the local source has transactions root %x, expected %x", logPrefix, blockNum, txHash, header.TxHash)
+			}
+			if uncleHash := types.CalcUncleHash(body.Uncles); uncleHash != header.UncleHash {
+				return fmt.Errorf("[%s] body %d of the local source has uncles hash %x, expected %x", logPrefix, blockNum, uncleHash, header.UncleHash)
+			}
+			if err = rawdb.WriteBody(tx, hash, blockNum, body); err != nil {
+				return fmt.Errorf("[%s] writing body %d: %w", logPrefix, blockNum, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return common.ErrStopped
+			case <-logEvery.C:
+				log.Info(fmt.Sprintf("[%s] Read bodies", logPrefix), "number", blockNum)
+			default:
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err = s.Update(tx, headerProgress); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
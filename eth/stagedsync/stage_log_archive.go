@@ -0,0 +1,183 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/ethdb/cbor"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type LogArchiveCfg struct {
+	db      kv.RwDB
+	archive *logarchive.Archive
+	keep    uint64
+}
+
+// StageLogArchiveCfg - archive is nil if the log archive is disabled, keep is the amount of recent blocks,
+// which are not archived yet because they may be reorged
+func StageLogArchiveCfg(db kv.RwDB, archive *logarchive.Archive, keep uint64) LogArchiveCfg {
+	return LogArchiveCfg{
+		db:      db,
+		archive: archive,
+		keep:    keep,
+	}
+}
+
+// SpawnLogArchive appends logs of the blocks older than keep to the log archive. The archive is append-only
+// and lives outside of the DB: its progress is the end of the archive itself, not the progress of the stage.
+func SpawnLogArchive(s *StageState, tx kv.RwTx, cfg LogArchiveCfg, ctx context.Context) (err error) {
+	if cfg.archive == nil {
+		return nil
+	}
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+	logPrefix := s.LogPrefix()
+	endBlock, err := s.ExecutionAt(tx)
+	if err != nil {
+		return err
+	}
+
+	// blocks [from, to) are archived
+	var to uint64
+	if endBlock+1 > cfg.keep {
+		to = endBlock + 1 - cfg.keep
+	}
+	from, err := cfg.archive.End()
+	if err != nil {
+		return err
+	}
+	if from < to {
+		if err = archiveLogs(logPrefix, tx, cfg.archive, from, to, ctx); err != nil {
+			return err
+		}
+	}
+	if err = s.Update(tx, endBlock); err != nil {
+		return err
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archiveLogs(logPrefix string, tx kv.Tx, archive *logarchive.Archive, from, to uint64, ctx context.Context) error {
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+	logs, err := tx.Cursor(kv.Log)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	k, v, err := logs.Seek(dbutils.LogKey(from, 0))
+	for blockNum := from; blockNum < to; blockNum++ {
+		select {
+		case <-ctx.Done():
+			return common.ErrStopped
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", blockNum)
+		default:
+		}
+		var txs []logarchive.Tx
+		for ; k != nil && binary.BigEndian.Uint64(k) == blockNum; k, v, err = logs.Next() {
+			if err != nil {
+				return err
+			}
+			var txLogs types.Logs
+			if err = cbor.Unmarshal(&txLogs, bytes.NewReader(v)); err != nil {
+				return fmt.Errorf("logs of block %d: %w", blockNum, err)
+			}
+			txs = append(txs, logarchive.Tx{Index: binary.BigEndian.Uint32(k[8:]), Logs: txLogs})
+		}
+		if err != nil {
+			return err
+		}
+		hash, err := rawdb.ReadCanonicalHash(tx, blockNum)
+		if err != nil {
+			return err
+		}
+		if len(txs) > 0 {
+			body := rawdb.ReadBody(tx, hash, blockNum)
+			if body == nil {
+				return fmt.Errorf("body of block %d not found", blockNum)
+			}
+			for i := range txs {
+				if int(txs[i].Index) >= len(body.Transactions) {
+					return fmt.Errorf("logs of unknown tx %d in block %d", txs[i].Index, blockNum)
+				}
+				txs[i].Hash = body.Transactions[txs[i].Index].Hash()
+			}
+		}
+		if err = archive.AppendBlock(blockNum, hash, txs); err != nil {
+			return err
+		}
+	}
+	return archive.Flush()
+}
+
+func UnwindLogArchive(u *UnwindState, s *StageState, tx kv.RwTx, cfg LogArchiveCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if cfg.archive != nil {
+		end, err := cfg.archive.End()
+		if err != nil {
+			return err
+		}
+		if u.UnwindPoint+1 < end {
+			return fmt.Errorf("[%s] can't unwind to block %d, logs before block %d are in the log archive", u.LogPrefix(), u.UnwindPoint, end)
+		}
+	}
+	if err = u.Done(tx); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PruneLogArchive(p *PruneState, tx kv.RwTx, cfg LogArchiveCfg, ctx context.Context) (err error) {
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -74,9 +74,14 @@ func SpawnLogIndex(s *StageState, tx kv.RwTx, cfg LogIndexCfg, ctx context.Conte
 		startBlock++
 	}
 
-	if err = promoteLogIndex(logPrefix, tx, startBlock, cfg, ctx); err != nil {
+	interruptedAt, err := promoteLogIndex(logPrefix, tx, startBlock, cfg, ctx, s.Interrupted())
+	if err != nil {
 		return err
 	}
+	if interruptedAt > 0 {
+		endBlock = interruptedAt - 1
+		log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", endBlock)
+	}
 	if err = s.Update(tx, endBlock); err != nil {
 		return err
 	}
@@ -90,7 +95,9 @@ func SpawnLogIndex(s *StageState, tx kv.RwTx, cfg LogIndexCfg, ctx context.Conte
 	return nil
 }
 
-func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg, ctx context.Context) error {
+// promoteLogIndex indexes logs of blocks from start. On interrupted it stops before the next block and indexes the blocks
+// before it, interruptedAt is that block then (0 - not interrupted).
+func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg, ctx context.Context, interrupted <-chan struct{}) (interruptedAt uint64, err error) {
 	quit := ctx.Done()
 	logEvery := time.NewTicker(30 * time.Second)
 	defer logEvery.Stop()
@@ -99,7 +106,7 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 	addresses := map[string]*roaring.Bitmap{}
 	logs, err := tx.Cursor(kv.Log)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer logs.Close()
 	checkFlushEvery := time.NewTicker(cfg.flushEvery)
@@ -112,15 +119,23 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 
 	reader := bytes.NewReader(nil)
 
+	currentBlock := start
 	for k, v, err := logs.Seek(dbutils.LogKey(start, 0)); k != nil; k, v, err = logs.Next() {
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		if err := common.Stopped(quit); err != nil {
-			return err
+			return 0, err
 		}
 		blockNum := binary.BigEndian.Uint64(k[:8])
+		if blockNum > currentBlock {
+			if common.Stopped(interrupted) != nil {
+				interruptedAt = blockNum
+				break
+			}
+			currentBlock = blockNum
+		}
 
 		select {
 		default:
@@ -131,14 +146,14 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 		case <-checkFlushEvery.C:
 			if needFlush(topics, cfg.bufLimit) {
 				if err := flushBitmaps(collectorTopics, topics); err != nil {
-					return err
+					return 0, err
 				}
 				topics = map[string]*roaring.Bitmap{}
 			}
 
 			if needFlush(addresses, cfg.bufLimit) {
 				if err := flushBitmaps(collectorAddrs, addresses); err != nil {
-					return err
+					return 0, err
 				}
 				addresses = map[string]*roaring.Bitmap{}
 			}
@@ -147,7 +162,7 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 		var ll types.Logs
 		reader.Reset(v)
 		if err := cbor.Unmarshal(&ll, reader); err != nil {
-			return fmt.Errorf("receipt unmarshal failed: %w, blocl=%d", err, blockNum)
+			return 0, fmt.Errorf("receipt unmarshal failed: %w, blocl=%d", err, blockNum)
 		}
 
 		for _, l := range ll {
@@ -172,10 +187,10 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 	}
 
 	if err := flushBitmaps(collectorTopics, topics); err != nil {
-		return err
+		return 0, err
 	}
 	if err := flushBitmaps(collectorAddrs, addresses); err != nil {
-		return err
+		return 0, err
 	}
 
 	var currentBitmap = roaring.New()
@@ -213,14 +228,14 @@ func promoteLogIndex(logPrefix string, tx kv.RwTx, start uint64, cfg LogIndexCfg
 	}
 
 	if err := collectorTopics.Load(logPrefix, tx, kv.LogTopicIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
-		return err
+		return 0, err
 	}
 
 	if err := collectorAddrs.Load(logPrefix, tx, kv.LogAddressIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return interruptedAt, nil
 }
 
 func UnwindLogIndex(u *UnwindState, s *StageState, tx kv.RwTx, cfg LogIndexCfg, ctx context.Context) (err error) {
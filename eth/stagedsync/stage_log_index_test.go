@@ -99,7 +99,7 @@ func TestLogIndex(t *testing.T) {
 	cfgCopy := cfg
 	cfgCopy.bufLimit = 10
 	cfgCopy.flushEvery = time.Nanosecond
-	err := promoteLogIndex("logPrefix", tx, 0, cfgCopy, ctx)
+	_, err := promoteLogIndex("logPrefix", tx, 0, cfgCopy, ctx, nil)
 	require.NoError(err)
 
 	// Check indices GetCardinality (in how many blocks they meet)
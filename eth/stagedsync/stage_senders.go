@@ -206,6 +206,12 @@ Loop:
 			// non-canonical case
 			continue
 		}
+		if blockNumber > s.BlockNumber+1 && s.IsInterrupted() {
+			// senders of the blocks sent to the workers are recovered and saved
+			to = blockNumber - 1
+			log.Info(fmt.Sprintf("[%s] Interrupted", logPrefix), "block", to)
+			break
+		}
 		body := rawdb.ReadBody(tx, blockHash, blockNumber)
 
 		select {
@@ -299,15 +305,16 @@ func recoverSenders(ctx context.Context, logPrefix string, cryptoContext *secp25
 
 		body := job.body
 		signer := types.MakeSigner(config, job.blockNumber)
-		job.senders = make([]byte, len(body.Transactions)*common.AddressLength)
+		senders := make([]common.Address, len(body.Transactions))
 		for i, tx := range body.Transactions {
 			from, err := signer.SenderWithContext(cryptoContext, tx)
 			if err != nil {
 				job.err = fmt.Errorf("%s: error recovering sender for tx=%x, %w", logPrefix, tx.Hash(), err)
 				break
 			}
-			copy(job.senders[i*common.AddressLength:], from[:])
+			senders[i] = from
 		}
+		job.senders = rawdb.EncodeSenders(senders)
 
 		// prevent sending to close channel
 		if err := common.Stopped(quit); err != nil {
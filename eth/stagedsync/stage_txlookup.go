@@ -15,6 +15,7 @@ import (
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
 	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/log/v3"
 )
 
 type TxLookupCfg struct {
@@ -60,7 +61,15 @@ func SpawnTxLookup(s *StageState, tx kv.RwTx, cfg TxLookupCfg, ctx context.Conte
 		startBlock++
 	}
 	startKey := dbutils.EncodeBlockNumber(startBlock)
-	if err = TxLookupTransform(logPrefix, tx, startKey, dbutils.EncodeBlockNumber(endBlock), quitCh, cfg); err != nil {
+	// the lookups are idempotent, so when it's interrupted the next run repeats it from the saved progress
+	stop, release := s.quitOrInterrupted(quitCh)
+	defer release()
+	err = TxLookupTransform(logPrefix, tx, startKey, dbutils.EncodeBlockNumber(endBlock), stop, cfg)
+	if s.droppedByInterrupt(err, quitCh) {
+		log.Info(fmt.Sprintf("[%s] Interrupted, the lookups are dropped", logPrefix), "from", startBlock, "to", endBlock)
+		return nil
+	}
+	if err != nil {
 		return err
 	}
 	if err = s.Update(tx, endBlock); err != nil {
@@ -61,6 +61,7 @@ func SpawnTxPool(s *StageState, tx kv.RwTx, cfg TxPoolCfg, ctx context.Context)
 		if err := cfg.pool.Start(headHeader.GasLimit, to); err != nil {
 			return fmt.Errorf(" start pool phase 1: %w", err)
 		}
+		cfg.pool.SetBaseFee(headHeader.BaseFee)
 		if cfg.startFunc != nil {
 			cfg.startFunc()
 		}
@@ -90,6 +91,7 @@ func incrementalTxPoolUpdate(logPrefix string, from, to uint64, pool *core.TxPoo
 	}
 
 	headHeader := rawdb.ReadHeader(tx, headHash, to)
+	pool.SetBaseFee(headHeader.BaseFee)
 	pool.ResetHead(headHeader.GasLimit, to)
 	canonical := make([]common.Hash, to-from)
 	currentHeaderIdx := uint64(0)
@@ -187,6 +189,7 @@ func unwindTxPoolUpdate(logPrefix string, from, to uint64, pool *core.TxPool, tx
 		return err
 	}
 	headHeader := rawdb.ReadHeader(tx, headHash, from)
+	pool.SetBaseFee(headHeader.BaseFee)
 	pool.ResetHead(headHeader.GasLimit, from)
 	canonical := make([]common.Hash, to-from)
 
@@ -235,9 +238,9 @@ func unwindTxPoolUpdate(logPrefix string, from, to uint64, pool *core.TxPool, tx
 			// non-canonical case
 			continue
 		}
-		sendersArray := make([]common.Address, len(v)/common.AddressLength)
-		for i := 0; i < len(sendersArray); i++ {
-			copy(sendersArray[i][:], v[i*common.AddressLength:])
+		sendersArray, err := rawdb.DecodeSenders(v)
+		if err != nil {
+			return fmt.Errorf("%s: senders of block %d: %w", logPrefix, blockNumber, err)
 		}
 		senders[blockNumber-from-1] = sendersArray
 	}
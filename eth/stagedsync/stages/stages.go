@@ -37,11 +37,16 @@ var (
 	Translation         SyncStage = "Translation"         // Translation each marked for translation contract (from EVM to TEVM)
 	IntermediateHashes  SyncStage = "IntermediateHashes"  // Generate intermediate hashes, calculate the state root hash
 	HashState           SyncStage = "HashState"           // Apply Keccak256 to all the keys in the state
+	CrossCheck          SyncStage = "CrossCheck"          // Comparing state and receipts roots of every N-th block with the trusted node
 	AccountHistoryIndex SyncStage = "AccountHistoryIndex" // Generating history index for accounts
 	StorageHistoryIndex SyncStage = "StorageHistoryIndex" // Generating history index for storage
+	HistoryFiles        SyncStage = "HistoryFiles"        // Moving changesets and history indices of old blocks into files
 	LogIndex            SyncStage = "LogIndex"            // Generating logs index (from receipts)
+	LogArchive          SyncStage = "LogArchive"          // Appending logs of old blocks to the log archive files
 	CallTraces          SyncStage = "CallTraces"          // Generating call traces index
 	TxLookup            SyncStage = "TxLookup"            // Generating transactions lookup index
+	FeeSeries           SyncStage = "FeeSeries"           // Aggregating base fee, gas usage and tips per epoch of blocks
+	DataGasStats        SyncStage = "DataGasStats"        // Counting calldata and blobs of every block
 	TxPool              SyncStage = "TxPoolDB"            // Starts Backend
 	Finish              SyncStage = "Finish"              // Nominal stage after all other stages
 
@@ -63,11 +68,16 @@ var AllStages = []SyncStage{
 	Translation,
 	HashState,
 	IntermediateHashes,
+	CrossCheck,
 	AccountHistoryIndex,
 	StorageHistoryIndex,
+	HistoryFiles,
 	LogIndex,
+	LogArchive,
 	CallTraces,
 	TxLookup,
+	FeeSeries,
+	DataGasStats,
 	TxPool,
 	Finish,
 }
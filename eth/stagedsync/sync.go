@@ -3,7 +3,9 @@ package stagedsync
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -23,6 +25,29 @@ type Sync struct {
 	pruningOrder []*Stage
 	currentStage uint
 	timings      []Timing
+
+	statusLock sync.Mutex
+	status     Status
+
+	onBadBlock func(unwindPoint uint64, badBlock common.Hash)
+
+	interruptOnce sync.Once
+	interrupt     chan struct{} // closed by Interrupt
+}
+
+// ErrInterrupted is returned by Run, which stopped at a stage boundary after Interrupt. Stages which ran have
+// saved their progress, the next run continues from it.
+var ErrInterrupted = fmt.Errorf("%w at a stage boundary", common.ErrStopped)
+
+// Status describes what the sync is doing, it's read by diagnostics from other goroutines
+type Status struct {
+	Running      bool
+	CycleStarted time.Time
+	Stage        stages.SyncStage
+	Action       string // forward, unwind or prune
+	StageStarted time.Time
+	From         uint64  // progress of the stage (or of its pruning) when it was started
+	UnwindPoint  *uint64 // set during unwinds
 }
 type Timing struct {
 	isUnwind bool
@@ -107,6 +132,14 @@ func (s *Sync) UnwindTo(unwindPoint uint64, badBlock common.Hash) {
 	log.Info("UnwindTo", "block", unwindPoint, "bad_block_hash", badBlock.String())
 	s.unwindPoint = &unwindPoint
 	s.badBlock = badBlock
+	if s.onBadBlock != nil && badBlock != (common.Hash{}) {
+		s.onBadBlock(unwindPoint, badBlock)
+	}
+}
+
+// OnBadBlock sets the hook called when the sync is unwound because of the bad block, it must not block
+func (s *Sync) OnBadBlock(f func(unwindPoint uint64, badBlock common.Hash)) {
+	s.onBadBlock = f
 }
 
 func (s *Sync) IsDone() bool {
@@ -155,9 +188,26 @@ func New(stagesList []*Stage, unwindOrder UnwindOrder, pruneOrder PruneOrder) *S
 		currentStage: 0,
 		unwindOrder:  unwindStages,
 		pruningOrder: pruneStages,
+		interrupt:    make(chan struct{}),
 	}
 }
 
+// Interrupt asks the sync to stop at the next safe boundary: the running stage returns at its next commit point
+// (stages which don't check Interrupted return when they are done), Run returns ErrInterrupted before the next
+// stage. Unwinds are never interrupted, they are completed first. Cancellation of the context of stages is still
+// the hard stop, which can lose the uncommitted work of the running stage.
+func (s *Sync) Interrupt() {
+	s.interruptOnce.Do(func() { close(s.interrupt) })
+}
+
+// Interrupted returns the channel, which is closed by Interrupt
+func (s *Sync) Interrupted() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.interrupt
+}
+
 func (s *Sync) StageState(stage stages.SyncStage, tx kv.Tx, db kv.RoDB) (*StageState, error) {
 	var blockNum uint64
 	var err error
@@ -182,7 +232,63 @@ func (s *Sync) StageState(stage stages.SyncStage, tx kv.Tx, db kv.RoDB) (*StageS
 	return &StageState{s, stage, blockNum}, nil
 }
 
+// Status returns copy of the current status
+func (s *Sync) Status() Status {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	return s.status
+}
+
+func (s *Sync) setStageStatus(id stages.SyncStage, action string, from uint64, unwindPoint *uint64) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.status.Stage, s.status.Action, s.status.StageStarted, s.status.From, s.status.UnwindPoint = id, action, time.Now(), from, unwindPoint
+}
+
+// PrintStatus writes the current status and committed progress of all stages, which are boundaries
+// of the last committed batches when stages commit by themselves
+func (s *Sync) PrintStatus(w io.Writer, tx kv.Tx) error {
+	status := s.Status()
+	now := time.Now()
+	if status.Running {
+		fmt.Fprintf(w, "cycle running for %s\n", now.Sub(status.CycleStarted).Truncate(time.Millisecond))
+		fmt.Fprintf(w, "stage %s: %s from %d for %s\n", status.Stage, status.Action, status.From, now.Sub(status.StageStarted).Truncate(time.Millisecond))
+		if status.UnwindPoint != nil {
+			fmt.Fprintf(w, "unwind point %d\n", *status.UnwindPoint)
+		}
+	} else {
+		fmt.Fprintf(w, "cycle is not running\n")
+	}
+	fmt.Fprintf(w, "\n%-22s %12s %12s\n", "stage", "progress", "pruned")
+	for _, stage := range s.stages {
+		progress, err := stages.GetStageProgress(tx, stage.ID)
+		if err != nil {
+			return err
+		}
+		pruned, err := stages.GetStagePruneProgress(tx, stage.ID)
+		if err != nil {
+			return err
+		}
+		disabled := ""
+		if stage.Disabled {
+			disabled = " (disabled)"
+		}
+		if _, err = fmt.Fprintf(w, "%-22s %12d %12d%s\n", stage.ID, progress, pruned, disabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Sync) Run(db kv.RwDB, tx kv.RwTx, firstCycle bool) error {
+	s.statusLock.Lock()
+	s.status = Status{Running: true, CycleStarted: time.Now()}
+	s.statusLock.Unlock()
+	defer func() {
+		s.statusLock.Lock()
+		s.status.Running = false
+		s.statusLock.Unlock()
+	}()
 	s.prevUnwindPoint = nil
 	s.timings = s.timings[:0]
 	for !s.IsDone() {
@@ -220,6 +326,14 @@ func (s *Sync) Run(db kv.RwDB, tx kv.RwTx, firstCycle bool) error {
 			continue
 		}
 
+		select {
+		case <-s.interrupt:
+			log.Info("Sync interrupted", "before", stage.ID)
+			s.currentStage = 0
+			return ErrInterrupted
+		default:
+		}
+
 		if err := s.runStage(stage, db, tx, firstCycle); err != nil {
 			return err
 		}
@@ -302,6 +416,7 @@ func (s *Sync) runStage(stage *Stage, db kv.RwDB, tx kv.RwTx, firstCycle bool) (
 	if err != nil {
 		return err
 	}
+	s.setStageStatus(stage.ID, "forward", stageState.BlockNumber, nil)
 
 	if err = stage.Forward(firstCycle, stageState, s, tx); err != nil {
 		return fmt.Errorf("[%s] %w", s.LogPrefix(), err)
@@ -333,6 +448,7 @@ func (s *Sync) unwindStage(firstCycle bool, stage *Stage, db kv.RwDB, tx kv.RwTx
 	if err = s.SetCurrentStage(stage.ID); err != nil {
 		return err
 	}
+	s.setStageStatus(stage.ID, "unwind", stageState.BlockNumber, &unwind.UnwindPoint)
 
 	err = stage.Unwind(firstCycle, unwind, stageState, tx)
 	if err != nil {
@@ -364,6 +480,7 @@ func (s *Sync) pruneStage(firstCycle bool, stage *Stage, db kv.RwDB, tx kv.RwTx)
 	if err = s.SetCurrentStage(stage.ID); err != nil {
 		return err
 	}
+	s.setStageStatus(stage.ID, "prune", prune.PruneProgress, nil)
 
 	err = stage.Prune(firstCycle, prune, tx)
 	if err != nil {
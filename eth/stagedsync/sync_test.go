@@ -616,3 +616,55 @@ func TestSyncInterruptLongUnwind(t *testing.T) {
 func unwindOf(s stages.SyncStage) stages.SyncStage {
 	return stages.SyncStage(append([]byte(s), 0xF0))
 }
+
+func TestInterrupt(t *testing.T) {
+	flow := make([]stages.SyncStage, 0)
+	var state *Sync
+	s := []*Stage{
+		{
+			ID:          stages.Headers,
+			Description: "Downloading headers",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				flow = append(flow, stages.Headers)
+				return nil
+			},
+		},
+		{
+			ID:          stages.Bodies,
+			Description: "Downloading block bodiess",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				flow = append(flow, stages.Bodies)
+				state.Interrupt()
+				// the running stage finishes its work
+				select {
+				case <-s.Interrupted():
+				default:
+					t.Error("stage is not notified")
+				}
+				return s.Update(tx, 10)
+			},
+		},
+		{
+			ID:          stages.Senders,
+			Description: "Recovering senders from tx signatures",
+			Forward: func(firstCycle bool, s *StageState, u Unwinder, tx kv.RwTx) error {
+				flow = append(flow, stages.Senders)
+				return nil
+			},
+		},
+	}
+	state = New(s, nil, nil)
+	db, tx := memdb.NewTestTx(t)
+	err := state.Run(db, tx, true)
+	assert.ErrorIs(t, err, ErrInterrupted)
+	assert.ErrorIs(t, err, common.ErrStopped)
+	assert.Equal(t, []stages.SyncStage{stages.Headers, stages.Bodies}, flow)
+	progress, err := stages.GetStageProgress(tx, stages.Bodies)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), progress)
+
+	// once interrupted, the sync doesn't start stages anymore
+	err = state.Run(db, tx, true)
+	assert.ErrorIs(t, err, ErrInterrupted)
+	assert.Equal(t, []stages.SyncStage{stages.Headers, stages.Bodies}, flow)
+}
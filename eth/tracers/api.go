@@ -8,5 +8,6 @@ type TraceConfig struct {
 	Tracer    *string
 	Timeout   *string
 	Reexec    *uint64
-	NoRefunds *bool // Turns off gas refunds when tracing
+	NoRefunds *bool   // Turns off gas refunds when tracing
+	Format    *string // json (default), jsonl or cbor - frames sent as they are produced, see rpc.StreamFormat
 }
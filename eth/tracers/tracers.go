@@ -51,3 +51,9 @@ func tracer(name string) (string, bool) {
 	}
 	return "", false
 }
+
+// IsBuiltin returns true if name refers to one of the built in JavaScript tracers
+func IsBuiltin(name string) bool {
+	_, ok := all[name]
+	return ok
+}
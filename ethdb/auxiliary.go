@@ -0,0 +1,34 @@
+package ethdb
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// OpenAuxiliaryTables registers tables of the database, which are not in its configuration of tables (f.e. created
+// by admin ops of the remote KV after the start of the node), so that transactions can read them. The configuration
+// is shared by all transactions of the database without locks, so call it only right after the database is opened,
+// before other goroutines use it.
+func OpenAuxiliaryTables(db kv.RwDB) error {
+	registered := db.AllBuckets()
+	return db.Update(context.Background(), func(tx kv.RwTx) error {
+		migrator, ok := tx.(kv.BucketMigrator)
+		if !ok {
+			return nil
+		}
+		names, err := migrator.ListBuckets()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if _, ok := registered[name]; ok {
+				continue
+			}
+			if err := migrator.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
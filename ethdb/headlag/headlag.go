@@ -0,0 +1,188 @@
+// Package headlag makes the database look as if the chain ends N blocks behind its real head.
+// RPC daemons behind one load balancer sync at slightly different speeds, so a client may see the head
+// going back and forth between requests. Serving a lagged head gives all of them the same view of the chain,
+// as long as none of them is more than N blocks behind.
+package headlag
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+)
+
+// WrapDB returns the database, in which the head is lag blocks behind the Finish stage of db:
+// stage progress, head header and head block are moved back to the lagged head, and lookups of blocks after it
+// by number, by hash and by hash of transaction find nothing. Iteration by cursors is not affected.
+func WrapDB(db kv.RoDB, lag uint64) kv.RoDB {
+	return &lagDB{RoDB: db, lag: lag}
+}
+
+type lagDB struct {
+	kv.RoDB
+	lag uint64
+}
+
+func (db *lagDB) UnwrapDB() kv.RoDB { return db.RoDB }
+
+func (db *lagDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	tx, err := db.RoDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ltx := &lagTx{Tx: tx, lag: db.lag}
+	// history readers type-assert transactions to get to the files
+	if ftx, ok := tx.(historyfiles.Tx); ok {
+		return &lagFilesTx{lagTx: ltx, files: ftx}, nil
+	}
+	// remote transactions read the history by their own ops, the history is not affected by the lag
+	if ttx, ok := tx.(temporal.Tx); ok {
+		return &lagTemporalTx{lagTx: ltx, temporal: ttx}, nil
+	}
+	return ltx, nil
+}
+
+func (db *lagDB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+// Lagged returns true if tx (or a transaction under its wrappers) is of the lagged database. The plain state of such
+// transaction is ahead of its head, state of the head is read from the history.
+func Lagged(tx kv.Tx) bool {
+	for {
+		switch tx.(type) {
+		case *lagTx, *lagFilesTx, *lagTemporalTx:
+			return true
+		}
+		w, ok := tx.(ethdb.TxWrapper)
+		if !ok {
+			return false
+		}
+		tx = w.UnwrapTx()
+	}
+}
+
+type lagTx struct {
+	kv.Tx
+	lag uint64
+
+	headRead bool
+	head     uint64 // lagged head
+}
+
+func (tx *lagTx) UnwrapTx() kv.Tx { return tx.Tx }
+
+type lagFilesTx struct {
+	*lagTx
+	files historyfiles.Tx
+}
+
+func (tx *lagFilesTx) HistoryFiles() *historyfiles.Files { return tx.files.HistoryFiles() }
+
+type lagTemporalTx struct {
+	*lagTx
+	temporal temporal.Tx
+}
+
+func (tx *lagTemporalTx) GetAsOf(table string, key []byte, block uint64) ([]byte, error) {
+	return tx.temporal.GetAsOf(table, key, block)
+}
+
+func (tx *lagTemporalTx) HistorySeek(table string, key []byte, block uint64) ([]byte, error) {
+	return tx.temporal.HistorySeek(table, key, block)
+}
+
+func (tx *lagTemporalTx) HistoryRange(table string, from, to uint64, walker func(block uint64, k, v []byte) (bool, error)) error {
+	return tx.temporal.HistoryRange(table, from, to, walker)
+}
+
+func (tx *lagTemporalTx) IndexRange(table string, key []byte, from, to uint64) (*roaring64.Bitmap, error) {
+	return tx.temporal.IndexRange(table, key, from, to)
+}
+
+func (tx *lagTemporalTx) WalkAsOf(table string, prefix, fromKey []byte, block uint64, walker func(k, v []byte) (bool, error)) error {
+	return tx.temporal.WalkAsOf(table, prefix, fromKey, block, walker)
+}
+
+// laggedHead is read once per transaction, the transaction sees a consistent snapshot anyway
+func (tx *lagTx) laggedHead() (uint64, error) {
+	if tx.headRead {
+		return tx.head, nil
+	}
+	head, err := stages.GetStageProgress(tx.Tx, stages.Finish)
+	if err != nil {
+		return 0, err
+	}
+	if head > tx.lag {
+		tx.head = head - tx.lag
+	} else {
+		tx.head = 0
+	}
+	tx.headRead = true
+	return tx.head, nil
+}
+
+func (tx *lagTx) GetOne(bucket string, key []byte) ([]byte, error) {
+	switch bucket {
+	case kv.SyncStageProgress, kv.HeadHeaderKey, kv.HeadBlockKey, kv.HeaderCanonical, kv.HeaderNumber, kv.TxLookup:
+	default:
+		return tx.Tx.GetOne(bucket, key)
+	}
+	head, err := tx.laggedHead()
+	if err != nil {
+		return nil, err
+	}
+	switch bucket {
+	case kv.HeadHeaderKey, kv.HeadBlockKey:
+		return tx.Tx.GetOne(kv.HeaderCanonical, dbutils.EncodeBlockNumber(head))
+	case kv.HeaderCanonical:
+		if len(key) == 8 && binary.BigEndian.Uint64(key) > head {
+			return nil, nil
+		}
+		return tx.Tx.GetOne(bucket, key)
+	}
+	v, err := tx.Tx.GetOne(bucket, key)
+	if err != nil || len(v) == 0 {
+		return v, err
+	}
+	switch bucket {
+	case kv.SyncStageProgress:
+		// progress of pruning is in the same table, it's not a block of the chain
+		if bytes.HasPrefix(key, []byte("prune_")) || len(v) < 8 || binary.BigEndian.Uint64(v) <= head {
+			return v, nil
+		}
+		return dbutils.EncodeBlockNumber(head), nil
+	case kv.HeaderNumber:
+		if len(v) == 8 && binary.BigEndian.Uint64(v) > head {
+			return nil, nil
+		}
+	case kv.TxLookup:
+		if new(big.Int).SetBytes(v).Uint64() > head {
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+func (tx *lagTx) Has(bucket string, key []byte) (bool, error) {
+	switch bucket {
+	case kv.SyncStageProgress, kv.HeadHeaderKey, kv.HeadBlockKey, kv.HeaderCanonical, kv.HeaderNumber, kv.TxLookup:
+		v, err := tx.GetOne(bucket, key)
+		return len(v) > 0, err
+	default:
+		return tx.Tx.Has(bucket, key)
+	}
+}
@@ -0,0 +1,87 @@
+package headlag_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/headlag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadLag(t *testing.T) {
+	ctx := context.Background()
+	rawDB := memdb.NewTestDB(t)
+	hash := func(n uint64) common.Hash { return common.BigToHash(new(big.Int).SetUint64(n + 1)) }
+	txHash5, txHash9 := common.HexToHash("0x55"), common.HexToHash("0x99")
+	require.NoError(t, rawDB.Update(ctx, func(tx kv.RwTx) error {
+		for n := uint64(0); n <= 10; n++ {
+			if err := rawdb.WriteCanonicalHash(tx, hash(n), n); err != nil {
+				return err
+			}
+			rawdb.WriteHeaderNumber(tx, hash(n), n)
+		}
+		rawdb.WriteHeadHeaderHash(tx, hash(10))
+		rawdb.WriteHeadBlockHash(tx, hash(10))
+		for _, s := range []stages.SyncStage{stages.Headers, stages.Execution, stages.Finish} {
+			if err := stages.SaveStageProgress(tx, s, 10); err != nil {
+				return err
+			}
+		}
+		if err := stages.SaveStagePruneProgress(tx, stages.Execution, 10); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.TxLookup, txHash5.Bytes(), big.NewInt(5).Bytes()); err != nil {
+			return err
+		}
+		return tx.Put(kv.TxLookup, txHash9.Bytes(), big.NewInt(9).Bytes())
+	}))
+
+	require.NoError(t, rawDB.View(ctx, func(tx kv.Tx) error {
+		require.False(t, headlag.Lagged(tx))
+		return nil
+	}))
+	db := headlag.WrapDB(rawDB, 3)
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		// the plain state is of the real head
+		require.True(t, headlag.Lagged(tx))
+		require.Equal(t, uint64(7), *rawdb.ReadCurrentBlockNumber(tx))
+		require.Equal(t, hash(7), rawdb.ReadHeadBlockHash(tx))
+		for _, s := range []stages.SyncStage{stages.Headers, stages.Execution, stages.Finish} {
+			progress, err := stages.GetStageProgress(tx, s)
+			require.NoError(t, err)
+			require.Equal(t, uint64(7), progress, s)
+		}
+		pruned, err := stages.GetStagePruneProgress(tx, stages.Execution)
+		require.NoError(t, err)
+		require.Equal(t, uint64(10), pruned)
+
+		canonical, err := rawdb.ReadCanonicalHash(tx, 7)
+		require.NoError(t, err)
+		require.Equal(t, hash(7), canonical)
+		canonical, err = rawdb.ReadCanonicalHash(tx, 8)
+		require.NoError(t, err)
+		require.Equal(t, common.Hash{}, canonical)
+		require.Equal(t, uint64(5), *rawdb.ReadHeaderNumber(tx, hash(5)))
+		require.Nil(t, rawdb.ReadHeaderNumber(tx, hash(9)))
+
+		block, err := rawdb.ReadTxLookupEntry(tx, txHash5)
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), *block)
+		block, err = rawdb.ReadTxLookupEntry(tx, txHash9)
+		require.NoError(t, err)
+		require.Nil(t, block)
+		return nil
+	}))
+
+	// node is not synced yet
+	require.NoError(t, headlag.WrapDB(rawDB, 20).View(ctx, func(tx kv.Tx) error {
+		require.Equal(t, uint64(0), *rawdb.ReadCurrentBlockNumber(tx))
+		return nil
+	}))
+}
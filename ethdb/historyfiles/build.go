@@ -0,0 +1,92 @@
+package historyfiles
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/changeset"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/common/etl"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Build writes changes of blocks [from, to) of both changeset tables into new files and adds them to the set.
+// Changesets are not removed from the DB here, that's up to the caller, together with the update of the end marker.
+func (f *Files) Build(ctx context.Context, tx kv.RwTx, from, to uint64, logPrefix, tmpdir string) error {
+	for _, table := range []string{kv.AccountChangeSet, kv.StorageChangeSet} {
+		file, err := f.build(ctx, tx, table, from, to, logPrefix, tmpdir)
+		if err != nil {
+			return fmt.Errorf("building %s file for blocks %d-%d: %w", table, from, to, err)
+		}
+		f.lock.Lock()
+		f.add(file)
+		f.lock.Unlock()
+	}
+	return nil
+}
+
+func (f *Files) build(ctx context.Context, tx kv.RwTx, table string, from, to uint64, logPrefix, tmpdir string) (*File, error) {
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+
+	// changesets are sorted by block, files are sorted by key
+	collector := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+	defer collector.Close(logPrefix)
+	if err := changeset.Walk(tx, table, dbutils.EncodeBlockNumber(from), 0, func(blockN uint64, k, v []byte) (bool, error) {
+		if blockN >= to {
+			return false, nil
+		}
+		select {
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Collecting changes", logPrefix), "table", table, "block", blockN)
+		case <-ctx.Done():
+			return false, common.ErrStopped
+		default:
+		}
+		newK := make([]byte, len(k)+8)
+		copy(newK, k)
+		binary.BigEndian.PutUint64(newK[len(k):], blockN)
+		return true, collector.Collect(newK, v)
+	}); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(f.dir, fileName(table, from, to))
+	tmpPath := path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+	buf := bufio.NewWriterSize(out, 1024*1024)
+	w := newWriter(buf)
+	if err = collector.Load(logPrefix, tx, "", func(k, v []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		return w.add(k[:len(k)-8], binary.BigEndian.Uint64(k[len(k)-8:]), v)
+	}, etl.TransformArgs{Quit: ctx.Done()}); err != nil {
+		return nil, err
+	}
+	if err = w.finish(); err != nil {
+		return nil, err
+	}
+	if err = buf.Flush(); err != nil {
+		return nil, err
+	}
+	if err = out.Sync(); err != nil {
+		return nil, err
+	}
+	if err = out.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return nil, err
+	}
+	return OpenFile(path, table, from, to)
+}
@@ -0,0 +1,265 @@
+package historyfiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
+)
+
+// Tx is implemented by transactions of the DB returned by WrapDB, history readers use it to get to the files
+type Tx interface {
+	HistoryFiles() *Files
+}
+
+// WrapDB returns database, transactions of which give access to the history files.
+// It must be the outermost wrapper of the DB, otherwise transactions can't be type-asserted to Tx.
+func WrapDB(db kv.RwDB, files *Files) kv.RwDB {
+	return &filesDB{RwDB: db, files: files}
+}
+
+type filesDB struct {
+	kv.RwDB
+	files *Files
+}
+
+func (db *filesDB) UnwrapDB() kv.RoDB { return db.RwDB }
+
+type filesTx struct {
+	kv.Tx
+	files *Files
+}
+
+func (tx *filesTx) HistoryFiles() *Files { return tx.files }
+func (tx *filesTx) UnwrapTx() kv.Tx      { return tx.Tx }
+
+type filesRwTx struct {
+	kv.RwTx
+	files *Files
+}
+
+func (tx *filesRwTx) HistoryFiles() *Files { return tx.files }
+func (tx *filesRwTx) UnwrapTx() kv.Tx      { return tx.RwTx }
+
+func (db *filesDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	tx, err := db.RwDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &filesTx{Tx: tx, files: db.files}, nil
+}
+
+func (db *filesDB) BeginRw(ctx context.Context) (kv.RwTx, error) {
+	tx, err := db.RwDB.BeginRw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &filesRwTx{RwTx: tx, files: db.files}, nil
+}
+
+func (db *filesDB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+func (db *filesDB) Update(ctx context.Context, f func(tx kv.RwTx) error) error {
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *filesDB) Close() {
+	db.RwDB.Close()
+	db.files.Close()
+}
+
+// Lookup serves history reads of blocks, which were moved to the files. ok is false if the key was not changed
+// at or after the block in the files, then the read goes on in the DB from the returned dbBlock.
+func Lookup(tx kv.Tx, table string, key []byte, block uint64) (v []byte, ok bool, dbBlock uint64, err error) {
+	end, err := ReadEnd(tx)
+	if err != nil || block >= end {
+		return nil, false, block, err
+	}
+	files, err := filesOf(tx, end)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	v, ok, err = files.Lookup(table, key, block)
+	return v, ok, end, err
+}
+
+// WalkChangedKeys calls walker for the keys of the changeset table, which were changed in blocks [from, to]
+// moved to the files. A key is passed once per file, in which it was changed.
+func WalkChangedKeys(tx kv.Tx, table string, from, to uint64, walker func(k []byte) error) error {
+	end, err := ReadEnd(tx)
+	if err != nil || from >= end {
+		return err
+	}
+	files, err := filesOf(tx, end)
+	if err != nil {
+		return err
+	}
+	return files.Walk(table, from, to+1, func(file *File) (bool, error) {
+		return true, file.Walk(func(k []byte, blocks *roaring64.Bitmap) (bool, error) {
+			if changed, ok := bitmapdb.SeekInBitmap64(blocks, from); ok && changed <= to {
+				return true, walker(k)
+			}
+			return true, nil
+		})
+	})
+}
+
+// WalkChanges calls walker for the changes of the changeset table in blocks [from, to) moved to the files, with values
+// before the changes. Changes are walked file by file, in the order of keys.
+func WalkChanges(tx kv.Tx, table string, from, to uint64, walker func(block uint64, k, v []byte) (bool, error)) error {
+	end, err := ReadEnd(tx)
+	if err != nil || from >= end {
+		return err
+	}
+	files, err := filesOf(tx, end)
+	if err != nil {
+		return err
+	}
+	return files.Walk(table, from, to, func(file *File) (bool, error) {
+		goOn := true
+		err := file.WalkChanges(func(k []byte, blocks *roaring64.Bitmap, values [][]byte) (bool, error) {
+			for i, it := 0, blocks.Iterator(); it.HasNext() && goOn; i++ {
+				block := it.Next()
+				if block < from {
+					continue
+				}
+				if block >= to {
+					break
+				}
+				var err error
+				if goOn, err = walker(block, k, values[i]); err != nil {
+					return false, err
+				}
+			}
+			return goOn, nil
+		})
+		return goOn, err
+	})
+}
+
+// ChangedBlocks returns the blocks in [from, to) moved to the files, in which the key of the changeset table was changed
+func ChangedBlocks(tx kv.Tx, table string, key []byte, from, to uint64) (*roaring64.Bitmap, error) {
+	result := roaring64.New()
+	end, err := ReadEnd(tx)
+	if err != nil || from >= end {
+		return result, err
+	}
+	files, err := filesOf(tx, end)
+	if err != nil {
+		return nil, err
+	}
+	if err = files.Walk(table, from, to, func(file *File) (bool, error) {
+		blocks, err := file.Blocks(key)
+		if blocks != nil {
+			result.Or(blocks)
+		}
+		return true, err
+	}); err != nil {
+		return nil, err
+	}
+	result.RemoveRange(0, from)
+	if to < end {
+		result.RemoveRange(to, end)
+	}
+	return result, nil
+}
+
+// AsOfCursor iterates over the keys of the changeset table, which were changed at or after the block in the files,
+// in the order of keys, with values before the first of these changes. Together with the state as of the end of
+// the files, it gives the state as of the block.
+type AsOfCursor struct {
+	block   uint64
+	cursors []*fileCursor // in the order of blocks of their files
+}
+
+// NewAsOfCursor returns the cursor positioned at the first key >= from. Blocks at or after the end of the files
+// have no changes in them, the cursor is empty then.
+func NewAsOfCursor(tx kv.Tx, table string, from []byte, block uint64) (*AsOfCursor, error) {
+	c := &AsOfCursor{block: block}
+	end, err := ReadEnd(tx)
+	if err != nil || block >= end {
+		return c, err
+	}
+	files, err := filesOf(tx, end)
+	if err != nil {
+		return nil, err
+	}
+	if err = files.Walk(table, block, end, func(file *File) (bool, error) {
+		fc := &fileCursor{file: file}
+		c.cursors = append(c.cursors, fc)
+		return true, fc.seek(from)
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Next returns the next key and its value before the first change at or after the block, nil key at the end.
+// An empty value means that the key didn't exist.
+func (c *AsOfCursor) Next() (k, v []byte, err error) {
+	for {
+		k = nil
+		for _, fc := range c.cursors {
+			if fc.k != nil && (k == nil || bytes.Compare(fc.k, k) < 0) {
+				k = fc.k
+			}
+		}
+		if k == nil {
+			return nil, nil, nil
+		}
+		found := false
+		for _, fc := range c.cursors {
+			if !bytes.Equal(fc.k, k) {
+				continue
+			}
+			if !found {
+				if changed, ok := bitmapdb.SeekInBitmap64(fc.blocks, c.block); ok {
+					v, found = fc.values[fc.blocks.Rank(changed)-1], true
+				}
+			}
+			if err = fc.next(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if found {
+			return k, v, nil
+		}
+	}
+}
+
+// filesOf returns files of the transaction, which must have all blocks before end
+func filesOf(tx kv.Tx, end uint64) (*Files, error) {
+	ftx, ok := tx.(Tx)
+	if !ok {
+		return nil, ErrNotAvailable
+	}
+	files := ftx.HistoryFiles()
+	if files.End() < end {
+		// files were built by another process
+		if err := files.Reopen(); err != nil {
+			return nil, err
+		}
+		if files.End() < end {
+			return nil, fmt.Errorf("%w: files in %s end at block %d, expected %d", ErrNotAvailable, files.Dir(), files.End(), end)
+		}
+	}
+	return files, nil
+}
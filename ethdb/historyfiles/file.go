@@ -0,0 +1,411 @@
+package historyfiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/golang/snappy"
+	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
+)
+
+// File layout:
+//
+//	page* index footer
+//
+//	page   - snappy compressed records, sorted by key
+//	record - uvarint(len(key)) key uvarint(len(bitmap)) bitmap (uvarint(len(value)) value)*
+//	         bitmap is serialized roaring64 bitmap of blocks, in which the key was changed,
+//	         followed by values of the key before each of these blocks, in the same order
+//	index  - (uvarint(len(firstKey)) firstKey uvarint(offset) uvarint(size))* for every page
+//	footer - offset of index (8 bytes) + count of pages (8 bytes) + magic (8 bytes)
+const (
+	magic      = uint64(0x657269686973_0001) // "erihis" + version
+	footerSize = 24
+	// pageSize - uncompressed size after which the page is flushed
+	pageSize = 64 * 1024
+)
+
+type pageRef struct {
+	firstKey     []byte
+	offset, size uint64
+}
+
+// File is immutable file with history of one changeset table in the block range [From, To)
+type File struct {
+	Table    string
+	From, To uint64
+	path     string
+
+	f     *os.File
+	pages []pageRef
+}
+
+// OpenFile opens the file and reads its index into memory
+func OpenFile(path, table string, from, to uint64) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{Table: table, From: from, To: to, path: path, f: f}
+	if err = file.readIndex(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+func (f *File) readIndex() error {
+	st, err := f.f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() < footerSize {
+		return errors.New("file is too short")
+	}
+	var footer [footerSize]byte
+	if _, err = f.f.ReadAt(footer[:], st.Size()-footerSize); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint64(footer[16:]) != magic {
+		return errors.New("wrong magic, file is corrupted or of unknown version")
+	}
+	indexOffset, count := binary.BigEndian.Uint64(footer[:]), binary.BigEndian.Uint64(footer[8:])
+	if indexOffset > uint64(st.Size())-footerSize {
+		return errors.New("index offset is out of file")
+	}
+	index := make([]byte, uint64(st.Size())-footerSize-indexOffset)
+	if _, err = f.f.ReadAt(index, int64(indexOffset)); err != nil {
+		return err
+	}
+	r := bytes.NewReader(index)
+	f.pages = make([]pageRef, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var p pageRef
+		if p.firstKey, err = readBytes(r); err != nil {
+			return err
+		}
+		if p.offset, err = binary.ReadUvarint(r); err != nil {
+			return err
+		}
+		if p.size, err = binary.ReadUvarint(r); err != nil {
+			return err
+		}
+		f.pages = append(f.pages, p)
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+func (f *File) readPage(i int) ([]byte, error) {
+	compressed := make([]byte, f.pages[i].size)
+	if _, err := f.f.ReadAt(compressed, int64(f.pages[i].offset)); err != nil {
+		return nil, err
+	}
+	return snappy.Decode(nil, compressed)
+}
+
+// Lookup finds the first change of the key at or after the given block, and returns the value before it.
+// ok is false if the key was not changed in [block, To).
+func (f *File) Lookup(key []byte, block uint64) (v []byte, ok bool, err error) {
+	if block >= f.To {
+		return nil, false, nil
+	}
+	bm, values, err := f.seek(key)
+	if err != nil || bm == nil {
+		return nil, false, err
+	}
+	changed, has := bitmapdb.SeekInBitmap64(bm, block)
+	if !has {
+		return nil, false, nil
+	}
+	return values[bm.Rank(changed)-1], true, nil
+}
+
+// Blocks returns the blocks, in which the key was changed, nil if it's not in the file
+func (f *File) Blocks(key []byte) (*roaring64.Bitmap, error) {
+	bm, _, err := f.seek(key)
+	return bm, err
+}
+
+// seek returns the record of the key, nil bitmap if there is no such key
+func (f *File) seek(key []byte) (blocks *roaring64.Bitmap, values [][]byte, err error) {
+	// the last page, which starts at or before the key
+	i := sort.Search(len(f.pages), func(i int) bool { return bytes.Compare(f.pages[i].firstKey, key) > 0 }) - 1
+	if i < 0 {
+		return nil, nil, nil
+	}
+	page, err := f.readPage(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = walkPage(page, func(k []byte, bm *roaring64.Bitmap, v [][]byte) (bool, error) {
+		switch c := bytes.Compare(k, key); {
+		case c < 0:
+			return true, nil
+		case c == 0:
+			blocks, values = bm, v
+		}
+		return false, nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+	return blocks, values, nil
+}
+
+// Walk iterates over all keys of the file, with blocks in which they were changed
+func (f *File) Walk(walker func(k []byte, blocks *roaring64.Bitmap) (bool, error)) error {
+	for i := range f.pages {
+		page, err := f.readPage(i)
+		if err != nil {
+			return err
+		}
+		goOn := true
+		if err = walkPage(page, func(k []byte, bm *roaring64.Bitmap, _ [][]byte) (bool, error) {
+			goOn, err = walker(k, bm)
+			return goOn, err
+		}); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		if !goOn {
+			return nil
+		}
+	}
+	return nil
+}
+
+// WalkChanges iterates over all keys of the file, with blocks in which they were changed and values before the changes
+func (f *File) WalkChanges(walker func(k []byte, blocks *roaring64.Bitmap, values [][]byte) (bool, error)) error {
+	for i := range f.pages {
+		page, err := f.readPage(i)
+		if err != nil {
+			return err
+		}
+		goOn := true
+		if err = walkPage(page, func(k []byte, bm *roaring64.Bitmap, values [][]byte) (bool, error) {
+			goOn, err = walker(k, bm, values)
+			return goOn, err
+		}); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		if !goOn {
+			return nil
+		}
+	}
+	return nil
+}
+
+func walkPage(page []byte, walker func(k []byte, bm *roaring64.Bitmap, values [][]byte) (bool, error)) error {
+	r := bytes.NewReader(page)
+	for r.Len() > 0 {
+		k, bm, values, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		goOn, err := walker(k, bm, values)
+		if err != nil || !goOn {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecord(r *bytes.Reader) (k []byte, bm *roaring64.Bitmap, values [][]byte, err error) {
+	if k, err = readBytes(r); err != nil {
+		return nil, nil, nil, err
+	}
+	encoded, err := readBytes(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bm = roaring64.New()
+	if _, err = bm.ReadFrom(bytes.NewReader(encoded)); err != nil {
+		return nil, nil, nil, err
+	}
+	values = make([][]byte, bm.GetCardinality())
+	for j := range values {
+		if values[j], err = readBytes(r); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return k, bm, values, nil
+}
+
+// fileCursor iterates over records of the file in the order of keys, reading one page at a time
+type fileCursor struct {
+	file *File
+	page int // index of the page in r
+	r    *bytes.Reader
+
+	k      []byte // nil at the end of the file
+	blocks *roaring64.Bitmap
+	values [][]byte
+}
+
+// seek positions the cursor at the first key >= key
+func (c *fileCursor) seek(key []byte) error {
+	c.page = sort.Search(len(c.file.pages), func(i int) bool { return bytes.Compare(c.file.pages[i].firstKey, key) > 0 }) - 1
+	if c.page < 0 {
+		c.page = 0
+	}
+	c.r = nil
+	if c.page < len(c.file.pages) {
+		page, err := c.file.readPage(c.page)
+		if err != nil {
+			return err
+		}
+		c.r = bytes.NewReader(page)
+	}
+	for {
+		if err := c.next(); err != nil || c.k == nil || bytes.Compare(c.k, key) >= 0 {
+			return err
+		}
+	}
+}
+
+func (c *fileCursor) next() error {
+	for c.r == nil || c.r.Len() == 0 {
+		if c.r != nil {
+			c.page++
+		}
+		if c.page >= len(c.file.pages) {
+			c.k, c.blocks, c.values = nil, nil, nil
+			return nil
+		}
+		page, err := c.file.readPage(c.page)
+		if err != nil {
+			return err
+		}
+		c.r = bytes.NewReader(page)
+	}
+	var err error
+	if c.k, c.blocks, c.values, err = readRecord(c.r); err != nil {
+		return fmt.Errorf("%s: %w", c.file.path, err)
+	}
+	return nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, l)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// writer writes records, which must be added in the order of keys, and blocks of each key in ascending order
+type writer struct {
+	w      io.Writer
+	offset uint64
+	pages  []pageRef
+
+	page     []byte
+	firstKey []byte
+
+	key    []byte
+	blocks *roaring64.Bitmap
+	values [][]byte
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: w, blocks: roaring64.New()}
+}
+
+func (w *writer) add(k []byte, block uint64, v []byte) error {
+	if w.key != nil && !bytes.Equal(k, w.key) {
+		if err := w.flushRecord(); err != nil {
+			return err
+		}
+	}
+	if w.key == nil {
+		w.key = append(w.key[:0], k...)
+	}
+	w.blocks.Add(block)
+	w.values = append(w.values, append([]byte{}, v...))
+	return nil
+}
+
+func (w *writer) flushRecord() error {
+	if w.key == nil {
+		return nil
+	}
+	if len(w.page) == 0 {
+		w.firstKey = append(w.firstKey[:0], w.key...)
+	}
+	var buf bytes.Buffer
+	if _, err := w.blocks.WriteTo(&buf); err != nil {
+		return err
+	}
+	w.page = appendBytes(w.page, w.key)
+	w.page = appendBytes(w.page, buf.Bytes())
+	for _, v := range w.values {
+		w.page = appendBytes(w.page, v)
+	}
+	w.key, w.values = nil, w.values[:0]
+	w.blocks.Clear()
+	if len(w.page) >= pageSize {
+		return w.flushPage()
+	}
+	return nil
+}
+
+func (w *writer) flushPage() error {
+	if len(w.page) == 0 {
+		return nil
+	}
+	compressed := snappy.Encode(nil, w.page)
+	if _, err := w.w.Write(compressed); err != nil {
+		return err
+	}
+	w.pages = append(w.pages, pageRef{firstKey: append([]byte{}, w.firstKey...), offset: w.offset, size: uint64(len(compressed))})
+	w.offset += uint64(len(compressed))
+	w.page = w.page[:0]
+	return nil
+}
+
+func (w *writer) finish() error {
+	if err := w.flushRecord(); err != nil {
+		return err
+	}
+	if err := w.flushPage(); err != nil {
+		return err
+	}
+	var index []byte
+	for _, p := range w.pages {
+		index = appendBytes(index, p.firstKey)
+		index = appendUvarint(index, p.offset)
+		index = appendUvarint(index, p.size)
+	}
+	var footer [footerSize]byte
+	binary.BigEndian.PutUint64(footer[:], w.offset)
+	binary.BigEndian.PutUint64(footer[8:], uint64(len(w.pages)))
+	binary.BigEndian.PutUint64(footer[16:], magic)
+	if _, err := w.w.Write(index); err != nil {
+		return err
+	}
+	_, err := w.w.Write(footer[:])
+	return err
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+func appendBytes(buf, b []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
@@ -0,0 +1,191 @@
+package historyfiles
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// StepSize - amount of blocks in one file
+const StepSize = 100_000
+
+var endKey = []byte("historyFilesEnd")
+
+// ErrNotAvailable is returned by history readers for blocks, which were moved to the files,
+// if the files can't be accessed (f.e. by rpcdaemon connected to the remote DB)
+var ErrNotAvailable = errors.New("history of this block was moved to history files, which are not available")
+
+// ReadEnd returns the block, before which changesets and history indices were moved from the DB into the files.
+// Blocks [0, end) are served from the files, 0 means that there are no files.
+func ReadEnd(tx kv.Getter) (uint64, error) {
+	v, err := tx.GetOne(kv.DatabaseInfo, endKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// WriteEnd moves the end marker, it's done in the same transaction which removes changesets of the moved blocks
+func WriteEnd(tx kv.Putter, end uint64) error {
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], end)
+	return tx.Put(kv.DatabaseInfo, endKey, v[:])
+}
+
+// tableNames are prefixes of file names
+var tableNames = map[string]string{
+	kv.AccountChangeSet: "accounts",
+	kv.StorageChangeSet: "storage",
+}
+
+func fileName(table string, from, to uint64) string {
+	return fmt.Sprintf("%s-%09d-%09d.hist", tableNames[table], from, to)
+}
+
+// Files is the set of history files in the directory. Files are only added to the set, by the HistoryFiles
+// stage of Erigon, readers in other processes pick them up with Reopen.
+type Files struct {
+	dir string
+
+	lock  sync.RWMutex
+	files map[string][]*File // changeset table -> files sorted by From
+}
+
+func Open(dir string) (*Files, error) {
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, err
+	}
+	f := &Files{dir: dir, files: map[string][]*File{}}
+	if err := f.Reopen(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Files) Dir() string { return f.dir }
+
+// Reopen picks up files which appeared in the directory since the last scan
+func (f *Files) Reopen() error {
+	entries, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".hist" {
+			continue
+		}
+		for table, name := range tableNames {
+			var from, to uint64
+			if n, _ := fmt.Sscanf(e.Name(), name+"-%09d-%09d.hist", &from, &to); n != 2 || e.Name() != fileName(table, from, to) {
+				continue
+			}
+			if f.find(table, from) != nil {
+				continue
+			}
+			file, err := OpenFile(filepath.Join(f.dir, e.Name()), table, from, to)
+			if err != nil {
+				return err
+			}
+			f.add(file)
+		}
+	}
+	return nil
+}
+
+func (f *Files) find(table string, from uint64) *File {
+	for _, file := range f.files[table] {
+		if file.From == from {
+			return file
+		}
+	}
+	return nil
+}
+
+// add inserts the file into the set, replacing the file of the same range
+func (f *Files) add(file *File) {
+	files := f.files[file.Table]
+	for i, existing := range files {
+		if existing.From == file.From {
+			_ = existing.Close()
+			files[i] = file
+			return
+		}
+	}
+	files = append(files, file)
+	sort.Slice(files, func(i, j int) bool { return files[i].From < files[j].From })
+	f.files[file.Table] = files
+}
+
+// End returns the block, up to which files of both tables are contiguous
+func (f *Files) End() uint64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	end := uint64(0)
+	for i, table := range []string{kv.AccountChangeSet, kv.StorageChangeSet} {
+		tableEnd := uint64(0)
+		for _, file := range f.files[table] {
+			if file.From != tableEnd {
+				break
+			}
+			tableEnd = file.To
+		}
+		if i == 0 || tableEnd < end {
+			end = tableEnd
+		}
+	}
+	return end
+}
+
+// Lookup finds the first change of the key at or after the given block in the files of the changeset table,
+// and returns the value before it. ok is false if there are no such changes in the files.
+func (f *Files) Lookup(table string, key []byte, block uint64) (v []byte, ok bool, err error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	files := f.files[table]
+	for i := sort.Search(len(files), func(i int) bool { return files[i].To > block }); i < len(files); i++ {
+		if v, ok, err = files[i].Lookup(key, block); err != nil || ok {
+			return v, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Walk iterates over the files of the changeset table, which intersect with [from, to)
+func (f *Files) Walk(table string, from, to uint64, walker func(file *File) (bool, error)) error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for _, file := range f.files[table] {
+		if file.To <= from || file.From >= to {
+			continue
+		}
+		goOn, err := walker(file)
+		if err != nil || !goOn {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Files) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, files := range f.files {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}
+	f.files = map[string][]*File{}
+}
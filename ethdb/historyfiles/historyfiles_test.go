@@ -0,0 +1,175 @@
+package historyfiles_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	files, err := historyfiles.Open(dir)
+	require.NoError(t, err)
+	rawDB := memdb.NewTestDB(t)
+	db := historyfiles.WrapDB(rawDB, files)
+
+	addr1, addr2, addr3 := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	storageKey := dbutils.PlainGenerateCompositeStorageKey(addr1.Bytes(), 1, common.HexToHash("0x3").Bytes())
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		for _, c := range []struct {
+			block uint64
+			addr  common.Address
+			v     string
+		}{{5, addr1, "a"}, {7, addr2, ""}, {10, addr1, "b"}, {historyfiles.StepSize + 10, addr1, "c"}} {
+			if err := tx.Put(kv.AccountChangeSet, dbutils.EncodeBlockNumber(c.block), append(c.addr.Bytes(), c.v...)); err != nil {
+				return err
+			}
+		}
+		csKey := append(dbutils.EncodeBlockNumber(3), storageKey[:common.AddressLength+common.IncarnationLength]...)
+		if err := tx.Put(kv.StorageChangeSet, csKey, append(common.CopyBytes(storageKey[common.AddressLength+common.IncarnationLength:]), "s1"...)); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.PlainState, storageKey, []byte("current")); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.PlainState, addr3.Bytes(), []byte("x")); err != nil {
+			return err
+		}
+		if err := files.Build(ctx, tx, 0, historyfiles.StepSize, "test", t.TempDir()); err != nil {
+			return err
+		}
+		return historyfiles.WriteEnd(tx, historyfiles.StepSize)
+	}))
+	require.Equal(t, uint64(historyfiles.StepSize), files.End())
+
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		for _, c := range []struct {
+			block uint64
+			v     string
+			ok    bool
+		}{{0, "a", true}, {5, "a", true}, {6, "b", true}, {10, "b", true}, {11, "", false}} {
+			v, ok, dbBlock, err := historyfiles.Lookup(tx, kv.AccountChangeSet, addr1.Bytes(), c.block)
+			require.NoError(t, err)
+			require.Equal(t, c.ok, ok, c.block)
+			if ok {
+				require.Equal(t, c.v, string(v), c.block)
+			} else {
+				require.Equal(t, uint64(historyfiles.StepSize), dbBlock)
+			}
+		}
+		v, ok, _, err := historyfiles.Lookup(tx, kv.AccountChangeSet, addr2.Bytes(), 0)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Empty(t, v)
+
+		// blocks after the end are read from the DB
+		_, ok, dbBlock, err := historyfiles.Lookup(tx, kv.AccountChangeSet, addr1.Bytes(), historyfiles.StepSize+1)
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.Equal(t, uint64(historyfiles.StepSize+1), dbBlock)
+
+		v, err = state.GetAsOf(tx, true, storageKey, 0)
+		require.NoError(t, err)
+		require.Equal(t, "s1", string(v))
+		v, err = state.GetAsOf(tx, true, storageKey, 4)
+		require.NoError(t, err)
+		require.Equal(t, "current", string(v))
+
+		// walks merge the files with the state
+		walkAccounts := func(block uint64) map[common.Address]string {
+			found := map[common.Address]string{}
+			require.NoError(t, state.WalkAsOfAccounts(tx, common.Address{}, block, func(k, v []byte) (bool, error) {
+				found[common.BytesToAddress(k)] = string(v)
+				return true, nil
+			}))
+			return found
+		}
+		require.Equal(t, map[common.Address]string{addr1: "a", addr3: "x"}, walkAccounts(0))
+		require.Equal(t, map[common.Address]string{addr1: "b", addr3: "x"}, walkAccounts(6))
+		require.Equal(t, map[common.Address]string{addr3: "x"}, walkAccounts(11))
+		walkStorage := func(block uint64) (found []string) {
+			require.NoError(t, state.WalkAsOfStorage(tx, addr1, 1, common.Hash{}, block, func(_, _, v []byte) (bool, error) {
+				found = append(found, string(v))
+				return true, nil
+			}))
+			return found
+		}
+		require.Equal(t, []string{"s1"}, walkStorage(0))
+		require.Equal(t, []string{"current"}, walkStorage(4))
+
+		var changed []common.Address
+		require.NoError(t, historyfiles.WalkChangedKeys(tx, kv.AccountChangeSet, 6, 9, func(k []byte) error {
+			changed = append(changed, common.BytesToAddress(k))
+			return nil
+		}))
+		require.Equal(t, []common.Address{addr2}, changed)
+		return nil
+	}))
+
+	// transactions without access to the files can't read moved history
+	require.NoError(t, rawDB.View(ctx, func(tx kv.Tx) error {
+		_, _, _, err := historyfiles.Lookup(tx, kv.AccountChangeSet, addr1.Bytes(), 0)
+		require.ErrorIs(t, err, historyfiles.ErrNotAvailable)
+		_, err = state.GetAsOf(tx, true, storageKey, 0)
+		require.ErrorIs(t, err, historyfiles.ErrNotAvailable)
+		return nil
+	}))
+
+	// files built by another process are picked up
+	reader, err := historyfiles.Open(dir)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, uint64(historyfiles.StepSize), reader.End())
+}
+
+func TestHistoryFilesPages(t *testing.T) {
+	ctx := context.Background()
+	files, err := historyfiles.Open(t.TempDir())
+	require.NoError(t, err)
+	db := historyfiles.WrapDB(memdb.NewTestDB(t), files)
+
+	// enough keys for several pages
+	const keys = 10_000
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		for block := uint64(1); block <= 3; block++ {
+			for i := uint64(0); i < keys; i++ {
+				addr := common.BytesToAddress(dbutils.EncodeBlockNumber(i))
+				if err := tx.Put(kv.AccountChangeSet, dbutils.EncodeBlockNumber(block), append(addr.Bytes(), dbutils.EncodeBlockNumber(i*10+block)...)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := files.Build(ctx, tx, 0, historyfiles.StepSize, "test", t.TempDir()); err != nil {
+			return err
+		}
+		return historyfiles.WriteEnd(tx, historyfiles.StepSize)
+	}))
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		for i := uint64(0); i < keys; i += 7 {
+			addr := common.BytesToAddress(dbutils.EncodeBlockNumber(i))
+			v, ok, _, err := historyfiles.Lookup(tx, kv.AccountChangeSet, addr.Bytes(), 2)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, dbutils.EncodeBlockNumber(i*10+2), v)
+		}
+
+		// the walk goes over pages from the start key
+		i := uint64(keys / 3)
+		require.NoError(t, state.WalkAsOfAccounts(tx, common.BytesToAddress(dbutils.EncodeBlockNumber(i)), 2, func(k, v []byte) (bool, error) {
+			require.Equal(t, common.BytesToAddress(dbutils.EncodeBlockNumber(i)), common.BytesToAddress(k))
+			require.Equal(t, dbutils.EncodeBlockNumber(i*10+2), v)
+			i++
+			return true, nil
+		}))
+		require.Equal(t, uint64(keys), i)
+		return nil
+	}))
+}
@@ -31,6 +31,27 @@ func Walk(c kv.Cursor, startkey []byte, fixedbits int, walker func(k, v []byte)
 	return nil
 }
 
+// MultiGetter is implemented by transactions, which read many keys faster than one by one (f.e. remote ones)
+type MultiGetter interface {
+	GetMany(bucket string, keys [][]byte) ([][]byte, error)
+}
+
+// GetMany returns values of the keys, nil for missing ones. Keys are read in a batch if the tx supports it.
+func GetMany(tx kv.Getter, bucket string, keys [][]byte) ([][]byte, error) {
+	if mg, ok := tx.(MultiGetter); ok {
+		return mg.GetMany(bucket, keys)
+	}
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		v, err := tx.GetOne(bucket, k)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
 // todo: return TEVM code and use it
 func GetCheckTEVM(db kv.Getter) func(contractHash common.Hash) (bool, error) {
 	checked := map[common.Hash]struct{}{}
@@ -0,0 +1,259 @@
+package logarchive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+const (
+	// PartitionSize - amount of blocks in one partition of the archive
+	PartitionSize = 100_000
+	// SectionSize - amount of blocks covered by one bloom filter of the partition index
+	SectionSize = 1_000
+	// flushSize - appended records are flushed to the file when the buffer gets this big
+	flushSize = 16 * 1024 * 1024
+)
+
+// Tx - logs of the transaction
+type Tx struct {
+	Index uint32
+	Hash  common.Hash
+	Logs  types.Logs // as in kv.Log, without the fields derived from the position of the log
+}
+
+// Archive is the append-only copy of logs of all blocks, partitioned by block ranges (and so by time) into files
+// with bloom indices of addresses and topics. Erigon appends blocks which can't be reorged anymore, so partitions
+// never change once written and can be backed up or moved to the cold storage as they are. Readers in other processes
+// (rpcdaemon) pick up the appended blocks on the fly.
+type Archive struct {
+	dir      string
+	writable bool
+
+	lock       sync.RWMutex
+	partitions []*partition // contiguous from block 0, all sealed except the last one
+
+	// writer state
+	next    uint64 // number of the next block to append
+	records bytes.Buffer
+}
+
+// Open opens the archive for appending, only one process may have the archive open for appending
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, err
+	}
+	return open(dir, true)
+}
+
+// OpenReadOnly opens the archive for reading, blocks appended by the writer become visible on the next read
+func OpenReadOnly(dir string) (*Archive, error) {
+	return open(dir, false)
+}
+
+func open(dir string, writable bool) (*Archive, error) {
+	a := &Archive{dir: dir, writable: writable}
+	if err := a.refresh(); err != nil {
+		a.Close()
+		return nil, err
+	}
+	a.next = a.end()
+	return a, nil
+}
+
+func partitionPath(dir string, from uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("logs-%09d-%09d", from, from+PartitionSize))
+}
+
+// refresh opens the partitions which appeared since the last refresh, and picks up the appended blocks
+func (a *Archive) refresh() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for {
+		var from uint64
+		if last := len(a.partitions) - 1; last >= 0 {
+			p := a.partitions[last]
+			if err := p.refresh(); err != nil {
+				return err
+			}
+			if a.writable && !p.sealed() && p.end == p.to { // the writer stopped between the last append and sealing
+				if err := p.seal(); err != nil {
+					return err
+				}
+			}
+			if !p.sealed() {
+				return nil
+			}
+			from = p.to
+		}
+		path := partitionPath(a.dir, from)
+		if _, err := os.Stat(path + ".log"); os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p, err := openPartition(path, from, a.writable)
+		if err != nil {
+			return err
+		}
+		a.partitions = append(a.partitions, p)
+	}
+}
+
+func (a *Archive) Dir() string { return a.dir }
+
+// End returns the block before which all blocks are in the archive
+func (a *Archive) End() (uint64, error) {
+	if !a.writable {
+		if err := a.refresh(); err != nil {
+			return 0, err
+		}
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.end(), nil
+}
+
+func (a *Archive) end() uint64 {
+	if len(a.partitions) == 0 {
+		return 0
+	}
+	return a.partitions[len(a.partitions)-1].end
+}
+
+// AppendBlock adds logs of the next block. Appended blocks become visible to readers after Flush.
+func (a *Archive) AppendBlock(num uint64, hash common.Hash, txs []Tx) error {
+	if !a.writable {
+		return errors.New("log archive is open read-only")
+	}
+	if num != a.next {
+		return fmt.Errorf("can't append block %d to the log archive, next block is %d", num, a.next)
+	}
+	if last := len(a.partitions) - 1; last < 0 || num >= a.partitions[last].to {
+		if err := a.Flush(); err != nil {
+			return err
+		}
+		if err := a.createPartition(num); err != nil {
+			return err
+		}
+	}
+	if len(txs) > 0 {
+		appendBlockRecord(&a.records, num, hash)
+		for i := range txs {
+			if err := appendTxRecord(&a.records, &txs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	a.next++
+	if a.records.Len() >= flushSize {
+		return a.Flush()
+	}
+	return nil
+}
+
+func (a *Archive) createPartition(from uint64) error {
+	path := partitionPath(a.dir, from)
+	f, err := os.OpenFile(path+".log", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	p, err := openPartition(path, from, true)
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	a.partitions = append(a.partitions, p)
+	a.lock.Unlock()
+	return nil
+}
+
+// Flush writes the appended blocks, and seals the partition if it's complete
+func (a *Archive) Flush() error {
+	if len(a.partitions) == 0 {
+		return nil
+	}
+	p := a.partitions[len(a.partitions)-1]
+	if p.sealed() || a.next == p.end {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if err := p.append(&a.records, a.next); err != nil {
+		return err
+	}
+	a.records.Reset()
+	if p.end == p.to {
+		return p.seal()
+	}
+	return nil
+}
+
+// Walk calls walker for the transactions of blocks [from, to) which may have logs matching the addresses and topics,
+// as in eth_getLogs, in the order of blocks. All transactions with logs of such block are passed, but most
+// of the blocks without matching logs are skipped by the bloom indices.
+func (a *Archive) Walk(from, to uint64, addresses []common.Address, topics [][]common.Hash, walker func(blockNum uint64, blockHash common.Hash, tx *Tx) (bool, error)) error {
+	if !a.writable {
+		if err := a.refresh(); err != nil {
+			return err
+		}
+	}
+	f := newFilter(addresses, topics)
+	var chunks []chunk
+	a.lock.RLock()
+	for _, p := range a.partitions {
+		chunks = p.match(from, to, f, chunks)
+	}
+	a.lock.RUnlock()
+
+	// log files are append-only, the chunks can be read without the lock
+	for _, c := range chunks {
+		r := bufio.NewReaderSize(io.NewSectionReader(c.f, c.offset, c.size), readBufSize)
+		var blockNum uint64
+		var blockHash common.Hash
+		for {
+			rec, _, err := readRecord(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.f.Name(), err)
+			}
+			switch rec.kind {
+			case recordBlock:
+				blockNum, blockHash = rec.block, rec.hash
+			case recordTx:
+				if blockNum < from {
+					continue
+				}
+				if blockNum >= to {
+					return nil
+				}
+				if goOn, err := walker(blockNum, blockHash, &rec.tx); err != nil || !goOn {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Archive) Close() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, p := range a.partitions {
+		p.close()
+	}
+	a.partitions = nil
+}
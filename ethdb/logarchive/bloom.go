@@ -0,0 +1,94 @@
+package logarchive
+
+import (
+	"encoding/binary"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+const (
+	bloomHashes = 4
+	// partitionBloomSize - bytes in the bloom filter of the whole partition
+	partitionBloomSize = 1024 * 1024
+	// sectionBloomSize - bytes in the bloom filter of one section of the partition
+	sectionBloomSize = 64 * 1024
+)
+
+// bloom is a bloom filter of addresses and topics of logs. Unlike types.Bloom, it's big enough
+// to stay selective for thousands of blocks.
+type bloom []byte
+
+// bloomKey - positions of the item in the filter, before reducing them to its size
+type bloomKey [bloomHashes]uint64
+
+func keyOf(item []byte) bloomKey {
+	h := crypto.Keccak256(item)
+	var key bloomKey
+	for i := range key {
+		key[i] = binary.BigEndian.Uint64(h[i*8:])
+	}
+	return key
+}
+
+func (b bloom) add(key bloomKey) {
+	bits := uint64(len(b)) * 8
+	for _, p := range key {
+		p %= bits
+		b[p/8] |= 1 << (p % 8)
+	}
+}
+
+func (b bloom) has(key bloomKey) bool {
+	bits := uint64(len(b)) * 8
+	for _, p := range key {
+		p %= bits
+		if b[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// filter is the criteria of eth_getLogs in the form of bloom keys
+type filter struct {
+	addresses []bloomKey
+	topics    [][]bloomKey // by position, empty - any topic
+}
+
+func newFilter(addresses []common.Address, topics [][]common.Hash) *filter {
+	f := &filter{}
+	for _, addr := range addresses {
+		f.addresses = append(f.addresses, keyOf(addr[:]))
+	}
+	for _, alternatives := range topics {
+		keys := make([]bloomKey, 0, len(alternatives))
+		for _, topic := range alternatives {
+			keys = append(keys, keyOf(topic[:]))
+		}
+		f.topics = append(f.topics, keys)
+	}
+	return f
+}
+
+// mayMatch - logs matching the filter may be among the logs added to the bloom
+func (f *filter) mayMatch(b bloom) bool {
+	if len(f.addresses) > 0 && !hasAny(b, f.addresses) {
+		return false
+	}
+	for _, keys := range f.topics {
+		if len(keys) > 0 && !hasAny(b, keys) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAny(b bloom, keys []bloomKey) bool {
+	for _, key := range keys {
+		if b.has(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package logarchive_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
+	"github.com/stretchr/testify/require"
+)
+
+type walked struct {
+	block   uint64
+	txIndex uint32
+	logs    int
+}
+
+func blockHash(n uint64) common.Hash {
+	return common.BytesToHash(dbutils.EncodeBlockNumber(n))
+}
+
+func walk(t *testing.T, a *logarchive.Archive, from, to uint64, addresses []common.Address, topics [][]common.Hash) []walked {
+	var result []walked
+	require.NoError(t, a.Walk(from, to, addresses, topics, func(blockNum uint64, hash common.Hash, tx *logarchive.Tx) (bool, error) {
+		require.Equal(t, blockHash(blockNum), hash)
+		require.Equal(t, common.BytesToHash([]byte{byte(tx.Index)}), tx.Hash)
+		result = append(result, walked{blockNum, tx.Index, len(tx.Logs)})
+		return true, nil
+	}))
+	return result
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := logarchive.Open(dir)
+	require.NoError(t, err)
+	defer a.Close()
+
+	token, other := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	transfer, approval := common.HexToHash("0xdd"), common.HexToHash("0x8c")
+	logsAt := map[uint64][]logarchive.Tx{
+		5: {
+			{Index: 0, Logs: types.Logs{{Address: token, Topics: []common.Hash{transfer}}}},
+			{Index: 2, Logs: types.Logs{{Address: other, Topics: []common.Hash{approval}}, {Address: token, Data: []byte{1}}}},
+		},
+		4_321:                           {{Index: 1, Logs: types.Logs{{Address: other, Topics: []common.Hash{transfer}}}}},
+		logarchive.PartitionSize + 7:    {{Index: 0, Logs: types.Logs{{Address: token, Topics: []common.Hash{approval, transfer}}}}},
+		logarchive.PartitionSize + 5000: {{Index: 3, Logs: types.Logs{{Address: token, Topics: []common.Hash{transfer}}}}},
+	}
+	appendBlocks := func(from, to uint64) {
+		for n := from; n < to; n++ {
+			txs := logsAt[n]
+			for i := range txs {
+				txs[i].Hash = common.BytesToHash([]byte{byte(txs[i].Index)})
+			}
+			require.NoError(t, a.AppendBlock(n, blockHash(n), txs))
+		}
+	}
+
+	appendBlocks(0, logarchive.PartitionSize+1000)
+	require.Error(t, a.AppendBlock(1, common.Hash{}, nil))
+
+	// blocks are visible to readers after flush
+	reader, err := logarchive.OpenReadOnly(dir)
+	require.NoError(t, err)
+	defer reader.Close()
+	end, err := reader.End()
+	require.NoError(t, err)
+	require.Equal(t, uint64(logarchive.PartitionSize), end) // the first partition was flushed and sealed
+	_, err = os.Stat(filepath.Join(dir, "logs-000000000-000100000.idx"))
+	require.NoError(t, err)
+
+	appendBlocks(logarchive.PartitionSize+1000, logarchive.PartitionSize+6000)
+	require.NoError(t, a.Flush())
+	end, err = reader.End()
+	require.NoError(t, err)
+	require.Equal(t, uint64(logarchive.PartitionSize+6000), end)
+
+	all := []walked{{5, 0, 1}, {5, 2, 2}, {4_321, 1, 1}, {logarchive.PartitionSize + 7, 0, 1}, {logarchive.PartitionSize + 5000, 3, 1}}
+	for _, archive := range []*logarchive.Archive{a, reader} {
+		require.Equal(t, all, walk(t, archive, 0, end, nil, nil))
+		require.Equal(t, all[2:4], walk(t, archive, 6, logarchive.PartitionSize+8, nil, nil))
+		require.Equal(t, []walked{{5, 0, 1}, {5, 2, 2}, {logarchive.PartitionSize + 7, 0, 1}, {logarchive.PartitionSize + 5000, 3, 1}},
+			walk(t, archive, 0, end, []common.Address{token}, nil))
+		// {approval} in the first position: blooms don't know positions, so the block with approval in
+		// the second position is passed too
+		require.Equal(t, []walked{{5, 0, 1}, {5, 2, 2}, {logarchive.PartitionSize + 7, 0, 1}},
+			walk(t, archive, 0, end, []common.Address{token}, [][]common.Hash{{approval}}))
+		require.Empty(t, walk(t, archive, 0, end, []common.Address{common.HexToAddress("0x3")}, nil))
+	}
+
+	// incomplete append is dropped on reopen
+	appendBlocks(logarchive.PartitionSize+6000, logarchive.PartitionSize+6001)
+	a.Close()
+	f, err := os.OpenFile(filepath.Join(dir, "logs-000100000-000200000.log"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{'B', 0, 0, 1})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	a, err = logarchive.Open(dir)
+	require.NoError(t, err)
+	defer a.Close()
+	end, err = a.End()
+	require.NoError(t, err)
+	require.Equal(t, uint64(logarchive.PartitionSize+6000), end)
+	require.NoError(t, a.AppendBlock(end, blockHash(end), []logarchive.Tx{{Hash: common.BytesToHash([]byte{0}), Logs: types.Logs{{Address: other}}}}))
+	require.NoError(t, a.Flush())
+	require.Equal(t, []walked{{end, 0, 1}}, walk(t, reader, end, end+1, nil, nil))
+}
@@ -0,0 +1,370 @@
+package logarchive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/edsrzf/mmap-go"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb/cbor"
+)
+
+// Files of the partition:
+//
+//	<name>.log - records, appended block by block
+//
+//	block      - 'B' number (8 bytes) hash (32 bytes)
+//	tx         - 'T' index (4 bytes) hash (32 bytes) uvarint(len(logs)) logs
+//	             logs of the transaction in CBOR, as in kv.Log, follow the block record
+//	checkpoint - 'C' end (8 bytes)
+//	             all blocks before end are in the file, records after the last checkpoint are incomplete
+//
+//	<name>.idx - bloom index of the sealed partition: bloom of the partition, blooms of its sections, offsets of
+//	             the first records of sections (8 bytes each), size of the log file (8 bytes), magic (8 bytes)
+const (
+	recordBlock      = 'B'
+	recordTx         = 'T'
+	recordCheckpoint = 'C'
+
+	magic         = uint64(0x6572696c6f67_0001) // "erilog" + version
+	sections      = PartitionSize / SectionSize
+	indexSize     = partitionBloomSize + sections*(sectionBloomSize+8) + 16
+	readBufSize   = 64 * 1024
+	maxLogsLength = 256 * 1024 * 1024
+)
+
+type record struct {
+	kind  byte
+	block uint64 // number of the block record, end of the checkpoint
+	hash  common.Hash
+	tx    Tx
+}
+
+// readRecord returns io.EOF or io.ErrUnexpectedEOF at the end of the file, including the incomplete last record
+func readRecord(r *bufio.Reader) (*record, int64, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	rec := &record{kind: kind}
+	var fixed [44]byte
+	switch kind {
+	case recordBlock:
+		if _, err = io.ReadFull(r, fixed[:40]); err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		rec.block = binary.BigEndian.Uint64(fixed[:])
+		rec.hash.SetBytes(fixed[8:40])
+		return rec, 41, nil
+	case recordCheckpoint:
+		if _, err = io.ReadFull(r, fixed[:8]); err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		rec.block = binary.BigEndian.Uint64(fixed[:])
+		return rec, 9, nil
+	case recordTx:
+		if _, err = io.ReadFull(r, fixed[:36]); err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		rec.tx.Index = binary.BigEndian.Uint32(fixed[:])
+		rec.tx.Hash.SetBytes(fixed[4:36])
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		if l > maxLogsLength {
+			return nil, 0, fmt.Errorf("logs of tx %x too long: %d", rec.tx.Hash, l)
+		}
+		data := make([]byte, l)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		if err = cbor.Unmarshal(&rec.tx.Logs, bytes.NewReader(data)); err != nil {
+			return nil, 0, fmt.Errorf("logs of tx %x: %w", rec.tx.Hash, err)
+		}
+		var lenBuf [binary.MaxVarintLen64]byte
+		return rec, 37 + int64(binary.PutUvarint(lenBuf[:], l)) + int64(l), nil
+	default:
+		return nil, 0, fmt.Errorf("unknown record %q", kind)
+	}
+}
+
+func appendBlockRecord(buf *bytes.Buffer, num uint64, hash common.Hash) {
+	var fixed [41]byte
+	fixed[0] = recordBlock
+	binary.BigEndian.PutUint64(fixed[1:], num)
+	copy(fixed[9:], hash[:])
+	buf.Write(fixed[:])
+}
+
+func appendTxRecord(buf *bytes.Buffer, tx *Tx) error {
+	var logs bytes.Buffer
+	if err := cbor.Marshal(&logs, tx.Logs); err != nil {
+		return err
+	}
+	var fixed [37 + binary.MaxVarintLen64]byte
+	fixed[0] = recordTx
+	binary.BigEndian.PutUint32(fixed[1:], tx.Index)
+	copy(fixed[5:], tx.Hash[:])
+	n := binary.PutUvarint(fixed[37:], uint64(logs.Len()))
+	buf.Write(fixed[:37+n])
+	buf.Write(logs.Bytes())
+	return nil
+}
+
+func appendCheckpointRecord(buf *bytes.Buffer, end uint64) {
+	var fixed [9]byte
+	fixed[0] = recordCheckpoint
+	binary.BigEndian.PutUint64(fixed[1:], end)
+	buf.Write(fixed[:])
+}
+
+type section struct {
+	offset int64 // of the first record
+	bloom  bloom
+}
+
+// partition - logs of blocks [from, to). It's open while blocks are appended to it, the index of the open partition is
+// kept in memory and updated by scanning of the appended records. Once all blocks are appended, the partition gets
+// sealed: its index is written into the file.
+type partition struct {
+	from, to uint64
+	path     string // without extension
+	f        *os.File
+
+	size     int64  // of the log file up to the last checkpoint
+	end      uint64 // blocks before end are in the partition
+	bloom    bloom
+	sections []section
+
+	idxFile *os.File
+	idx     mmap.MMap // index of the sealed partition, blooms are its slices
+}
+
+func (p *partition) sealed() bool { return p.idx != nil }
+
+// openPartition opens the log file of the partition, which must exist. Index is read if the partition is sealed,
+// otherwise it's built by scanning the file.
+func openPartition(path string, from uint64, writable bool) (*partition, error) {
+	flag := os.O_RDONLY
+	if writable {
+		flag = os.O_RDWR | os.O_APPEND
+	}
+	f, err := os.OpenFile(path+".log", flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &partition{from: from, to: from + PartitionSize, path: path, f: f, end: from, bloom: make(bloom, partitionBloomSize)}
+	if err = p.refresh(); err != nil {
+		p.close()
+		return nil, err
+	}
+	if writable && !p.sealed() {
+		// drop incomplete records of the last append
+		if err = f.Truncate(p.size); err != nil {
+			p.close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// refresh picks up the records appended since the last refresh, and the index once it's written
+func (p *partition) refresh() error {
+	if p.sealed() {
+		return nil
+	}
+	if _, err := os.Stat(p.path + ".idx"); err == nil {
+		return p.openIndex()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return p.scan()
+}
+
+// scan reads the records after the last checkpoint up to the new last checkpoint
+func (p *partition) scan() error {
+	stat, err := p.f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() == p.size {
+		return nil
+	}
+	type pendingKey struct {
+		section int
+		key     bloomKey
+	}
+	var pendingKeys []pendingKey
+	var pendingSections []section
+	current := -1
+
+	r := bufio.NewReaderSize(io.NewSectionReader(p.f, p.size, stat.Size()-p.size), readBufSize)
+	for offset := p.size; ; {
+		rec, n, err := readRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s.log at %d: %w", p.path, offset, err)
+		}
+		switch rec.kind {
+		case recordBlock:
+			if rec.block < p.end || rec.block >= p.to {
+				return fmt.Errorf("%s.log at %d: block %d out of order", p.path, offset, rec.block)
+			}
+			current = int((rec.block - p.from) / SectionSize)
+			for len(p.sections)+len(pendingSections) <= current {
+				pendingSections = append(pendingSections, section{offset: offset, bloom: make(bloom, sectionBloomSize)})
+			}
+		case recordTx:
+			if current < 0 {
+				return fmt.Errorf("%s.log at %d: logs without block", p.path, offset)
+			}
+			for _, l := range rec.tx.Logs {
+				pendingKeys = append(pendingKeys, pendingKey{current, keyOf(l.Address[:])})
+				for _, topic := range l.Topics {
+					pendingKeys = append(pendingKeys, pendingKey{current, keyOf(topic[:])})
+				}
+			}
+		case recordCheckpoint:
+			p.sections = append(p.sections, pendingSections...)
+			for _, k := range pendingKeys {
+				p.sections[k.section].bloom.add(k.key)
+				p.bloom.add(k.key)
+			}
+			pendingKeys, pendingSections = pendingKeys[:0], nil
+			p.size, p.end = offset+n, rec.block
+		}
+		offset += n
+	}
+}
+
+// append writes the records and the checkpoint, and scans them
+func (p *partition) append(records *bytes.Buffer, end uint64) error {
+	appendCheckpointRecord(records, end)
+	if _, err := p.f.Write(records.Bytes()); err != nil {
+		return err
+	}
+	if err := p.f.Sync(); err != nil {
+		return err
+	}
+	return p.scan()
+}
+
+// seal writes the index of the complete partition
+func (p *partition) seal() error {
+	if p.end != p.to {
+		return fmt.Errorf("%s.log: can't seal partition, it ends at %d", p.path, p.end)
+	}
+	for len(p.sections) < sections {
+		p.sections = append(p.sections, section{offset: p.size, bloom: make(bloom, sectionBloomSize)})
+	}
+	buf := make([]byte, 0, indexSize)
+	buf = append(buf, p.bloom...)
+	for _, s := range p.sections {
+		buf = append(buf, s.bloom...)
+	}
+	var num [8]byte
+	for _, s := range p.sections {
+		binary.BigEndian.PutUint64(num[:], uint64(s.offset))
+		buf = append(buf, num[:]...)
+	}
+	binary.BigEndian.PutUint64(num[:], uint64(p.size))
+	buf = append(buf, num[:]...)
+	binary.BigEndian.PutUint64(num[:], magic)
+	buf = append(buf, num[:]...)
+
+	tmp := p.path + ".idx.tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, p.path+".idx"); err != nil {
+		return err
+	}
+	return p.openIndex()
+}
+
+func (p *partition) openIndex() error {
+	f, err := os.Open(p.path + ".idx")
+	if err != nil {
+		return err
+	}
+	idx, err := mmap.Map(f, mmap.RDONLY, 0)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if len(idx) != indexSize || binary.BigEndian.Uint64(idx[indexSize-8:]) != magic {
+		_ = idx.Unmap()
+		f.Close()
+		return fmt.Errorf("%s.idx: corrupted index", p.path)
+	}
+	p.bloom = bloom(idx[:partitionBloomSize])
+	p.sections = make([]section, sections)
+	offsets := idx[partitionBloomSize+sections*sectionBloomSize:]
+	for i := range p.sections {
+		bloomStart := partitionBloomSize + i*sectionBloomSize
+		p.sections[i] = section{
+			offset: int64(binary.BigEndian.Uint64(offsets[i*8:])),
+			bloom:  bloom(idx[bloomStart : bloomStart+sectionBloomSize]),
+		}
+	}
+	p.size = int64(binary.BigEndian.Uint64(offsets[sections*8:]))
+	p.end = p.to
+	p.idxFile, p.idx = f, idx
+	return nil
+}
+
+// chunk - part of the log file with the records of some blocks
+type chunk struct {
+	f            *os.File
+	offset, size int64
+}
+
+// match returns the parts of the log file, which may have logs of blocks [from, to) matching the filter
+func (p *partition) match(from, to uint64, f *filter, chunks []chunk) []chunk {
+	if p.end <= from || p.from >= to || !f.mayMatch(p.bloom) {
+		return chunks
+	}
+	for i, s := range p.sections {
+		sectionFrom := p.from + uint64(i)*SectionSize
+		if sectionFrom >= to || sectionFrom+SectionSize <= from || !f.mayMatch(s.bloom) {
+			continue
+		}
+		end := p.size
+		if i+1 < len(p.sections) {
+			end = p.sections[i+1].offset
+		}
+		if last := len(chunks) - 1; last >= 0 && chunks[last].f == p.f && chunks[last].offset+chunks[last].size == s.offset {
+			chunks[last].size = end - chunks[last].offset
+			continue
+		}
+		chunks = append(chunks, chunk{f: p.f, offset: s.offset, size: end - s.offset})
+	}
+	return chunks
+}
+
+func (p *partition) close() {
+	if p.idx != nil {
+		_ = p.idx.Unmap()
+		_ = p.idxFile.Close()
+	}
+	_ = p.f.Close()
+}
@@ -3,14 +3,18 @@ package privateapi
 import (
 	"fmt"
 	"net"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/c2h5oh/datasize"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
 	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/ethdb/replication"
 	"github.com/ledgerwatch/erigon/metrics"
 	"github.com/ledgerwatch/log/v3"
 	"google.golang.org/grpc"
@@ -18,9 +22,52 @@ import (
 	"google.golang.org/grpc/keepalive"
 )
 
-func StartGrpc(kv *remotedbserver.KvServer, ethBackendSrv *EthBackendServer, txPoolServer *TxPoolServer, miningServer *MiningServer, addr string, rateLimit uint32, creds *credentials.TransportCredentials) (*grpc.Server, error) {
+// ConnParams tune connections of the private API, zero values keep defaults of gRPC
+type ConnParams struct {
+	KeepaliveTime    time.Duration     // the server pings connections idle for so long, 0 - 2h
+	KeepaliveTimeout time.Duration     // and closes them, if the ping is not acknowledged in time, 0 - 20s
+	MinPingInterval  time.Duration     // clients pinging more often are disconnected, 0 - 5m
+	MaxConnIdle      time.Duration     // connections without calls for so long are closed gracefully, 0 - never
+	MaxRecvMsgSize   datasize.ByteSize // of requests, 0 - 4MB
+	MaxSendMsgSize   datasize.ByteSize // of replies, 0 - no limit
+	WindowSize       datasize.ByteSize // initial flow control window of streams, 0 - dynamic
+	ConnWindowSize   datasize.ByteSize // initial flow control window of connections, 0 - dynamic
+}
+
+// Don't drop the connection, settings accordign to this comment on GitHub
+// https://github.com/grpc/grpc-go/issues/3171#issuecomment-552796779
+var DefaultConnParams = ConnParams{MinPingInterval: 10 * time.Second}
+
+func (p ConnParams) serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             p.MinPingInterval,
+			PermitWithoutStream: true,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              p.KeepaliveTime,
+			Timeout:           p.KeepaliveTimeout,
+			MaxConnectionIdle: p.MaxConnIdle,
+		}),
+	}
+	if p.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(p.MaxRecvMsgSize)))
+	}
+	if p.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(int(p.MaxSendMsgSize)))
+	}
+	if p.WindowSize > 0 {
+		opts = append(opts, grpc.InitialWindowSize(int32(p.WindowSize)))
+	}
+	if p.ConnWindowSize > 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(int32(p.ConnWindowSize)))
+	}
+	return opts
+}
+
+func StartGrpc(kv *remotedbserver.KvServer, ethBackendSrv *EthBackendServer, txPoolServer *TxPoolServer, miningServer *MiningServer, replicationServer *replication.Server, addr string, rateLimit uint32, connParams ConnParams, creds *credentials.TransportCredentials) (*grpc.Server, error) {
 	log.Info("Starting private RPC server", "on", addr)
-	lis, err := net.Listen("tcp", addr)
+	lis, err := listen(addr)
 	if err != nil {
 		return nil, fmt.Errorf("could not create listener: %w, addr=%s", err, addr)
 	}
@@ -44,15 +91,10 @@ func StartGrpc(kv *remotedbserver.KvServer, ethBackendSrv *EthBackendServer, txP
 		grpc.WriteBufferSize(1024), // reduce buffers to save mem
 		grpc.ReadBufferSize(1024),
 		grpc.MaxConcurrentStreams(rateLimit), // to force clients reduce concurrency level
-		// Don't drop the connection, settings accordign to this comment on GitHub
-		// https://github.com/grpc/grpc-go/issues/3171#issuecomment-552796779
-		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
-			MinTime:             10 * time.Second,
-			PermitWithoutStream: true,
-		}),
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)),
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
 	}
+	opts = append(opts, connParams.serverOptions()...)
 	if creds == nil {
 		// no specific opts
 	} else {
@@ -63,6 +105,9 @@ func StartGrpc(kv *remotedbserver.KvServer, ethBackendSrv *EthBackendServer, txP
 	txpool.RegisterTxpoolServer(grpcServer, txPoolServer)
 	txpool.RegisterMiningServer(grpcServer, miningServer)
 	remote.RegisterKVServer(grpcServer, kv)
+	if replicationServer != nil {
+		replication.Register(grpcServer, replicationServer)
+	}
 
 	if metrics.Enabled {
 		grpc_prometheus.Register(grpcServer)
@@ -76,3 +121,16 @@ func StartGrpc(kv *remotedbserver.KvServer, ethBackendSrv *EthBackendServer, txP
 
 	return grpcServer, nil
 }
+
+// listen listens on host:port, or on the unix socket unix:///path/to/socket for clients on the same host. The stale
+// socket file of the previous run is removed.
+func listen(addr string) (net.Listener, error) {
+	path := strings.TrimPrefix(addr, "unix://")
+	if path == addr {
+		return net.Listen("tcp", addr)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
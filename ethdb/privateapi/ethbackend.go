@@ -3,15 +3,21 @@ package privateapi
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/ledgerwatch/erigon-lib/gointerfaces"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
 	types2 "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/rlp"
 	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -20,6 +26,18 @@ import (
 // 2.1.0 - add NetPeerCount function
 var EthBackendAPIVersion = &types2.VersionReply{Major: 2, Minor: 1, Patch: 0}
 
+// EthBackendFeatures - optional features of the ETHBACKEND service supported by this version, see remoteapi.Features
+var EthBackendFeatures = FeatureEthProtocols
+
+const (
+	// FeatureEthProtocols - the reply to ProtocolVersion lists all versions of the eth protocol in EthProtocolsHeader
+	FeatureEthProtocols remoteapi.Features = 1 << iota
+)
+
+// EthProtocolsHeader is the gRPC header of the ProtocolVersion reply with comma-separated versions of the eth protocol
+// served by sentries, highest first. ProtocolVersionReply has room for one version only.
+const EthProtocolsHeader = "x-erigon-eth-protocols"
+
 type EthBackendServer struct {
 	remote.UnimplementedETHBACKENDServer // must be embedded to have forward compatible implementations.
 
@@ -31,13 +49,15 @@ type EthBackend interface {
 	Etherbase() (common.Address, error)
 	NetVersion() (uint64, error)
 	NetPeerCount() (uint64, error)
+	EthProtocols() []uint
 }
 
 func NewEthBackendServer(eth EthBackend, events *Events) *EthBackendServer {
 	return &EthBackendServer{eth: eth, events: events}
 }
 
-func (s *EthBackendServer) Version(context.Context, *emptypb.Empty) (*types2.VersionReply, error) {
+func (s *EthBackendServer) Version(ctx context.Context, _ *emptypb.Empty) (*types2.VersionReply, error) {
+	remoteapi.SendFeatures(ctx, EthBackendFeatures)
 	return EthBackendAPIVersion, nil
 }
 
@@ -107,9 +127,38 @@ func (s *EthBackendServer) Subscribe(r *remote.SubscribeRequest, subscribeServer
 	return nil
 }
 
-func (s *EthBackendServer) ProtocolVersion(_ context.Context, _ *remote.ProtocolVersionRequest) (*remote.ProtocolVersionReply, error) {
-	// Hardcoding to avoid import cycle
-	return &remote.ProtocolVersionReply{Id: 66}, nil
+// ProtocolVersion returns the highest version of the eth protocol, all versions are in EthProtocolsHeader
+func (s *EthBackendServer) ProtocolVersion(ctx context.Context, _ *remote.ProtocolVersionRequest) (*remote.ProtocolVersionReply, error) {
+	versions := s.eth.EthProtocols()
+	encoded := make([]string, len(versions))
+	for i, v := range versions {
+		encoded[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	// fails only when called outside of gRPC server (f.e. directly in tests)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(EthProtocolsHeader, strings.Join(encoded, ",")))
+	if len(versions) == 0 {
+		// no sentry is connected yet. Hardcoding to avoid import cycle
+		return &remote.ProtocolVersionReply{Id: 66}, nil
+	}
+	return &remote.ProtocolVersionReply{Id: uint64(versions[0])}, nil
+}
+
+// ParseEthProtocols parses versions of the eth protocol from the headers of the ProtocolVersion reply
+func ParseEthProtocols(md metadata.MD) ([]uint, error) {
+	values := md.Get(EthProtocolsHeader)
+	if len(values) == 0 || values[0] == "" {
+		return nil, nil
+	}
+	parts := strings.Split(values[0], ",")
+	versions := make([]uint, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", EthProtocolsHeader, err)
+		}
+		versions[i] = uint(v)
+	}
+	return versions, nil
 }
 
 func (s *EthBackendServer) ClientVersion(_ context.Context, _ *remote.ClientVersionRequest) (*remote.ClientVersionReply, error) {
@@ -0,0 +1,127 @@
+package privateapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type ethBackendMock struct{}
+
+func (ethBackendMock) Etherbase() (common.Address, error) {
+	return common.HexToAddress("0x67b1d87101671b127f5f8714789c7192f7ad340e"), nil
+}
+func (ethBackendMock) NetVersion() (uint64, error)   { return 1, nil }
+func (ethBackendMock) NetPeerCount() (uint64, error) { return 25, nil }
+func (ethBackendMock) EthProtocols() []uint          { return []uint{66, 65} }
+
+// recordedCalls is a sequence of unary calls made by a client of the given version and replies it expected,
+// kept in protobuf wire format, so the test also catches incompatible changes of the .proto files
+type recordedCalls struct {
+	Client gointerfaces.Version `json:"client"`
+	Calls  []struct {
+		Method  string `json:"method"`
+		Request string `json:"request"`
+		Reply   string `json:"reply"`
+	} `json:"calls"`
+}
+
+// ethBackendMessages returns empty request and reply messages of the given ETHBACKEND method
+func ethBackendMessages(method string) (proto.Message, proto.Message) {
+	switch method {
+	case "Etherbase":
+		return &remote.EtherbaseRequest{}, &remote.EtherbaseReply{}
+	case "NetVersion":
+		return &remote.NetVersionRequest{}, &remote.NetVersionReply{}
+	case "NetPeerCount":
+		return &remote.NetPeerCountRequest{}, &remote.NetPeerCountReply{}
+	case "ProtocolVersion":
+		return &remote.ProtocolVersionRequest{}, &remote.ProtocolVersionReply{}
+	default:
+		return nil, nil
+	}
+}
+
+func dialEthBackend(t *testing.T) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	remote.RegisterETHBACKENDServer(server, NewEthBackendServer(ethBackendMock{}, NewEvents()))
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestEthBackendCompatibility(t *testing.T) {
+	files, err := filepath.Glob("testdata/ethbackend_*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	conn := dialEthBackend(t)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var recorded recordedCalls
+		require.NoError(t, json.Unmarshal(data, &recorded))
+
+		t.Run(recorded.Client.String(), func(t *testing.T) {
+			versionReply, err := remote.NewETHBACKENDClient(conn).Version(context.Background(), &emptypb.Empty{})
+			require.NoError(t, err)
+			// streams of older minor versions are replayed too: clients refuse such servers, but the server must
+			// still answer them the same
+			require.Equal(t, recorded.Client.Major, versionReply.Major)
+
+			for _, call := range recorded.Calls {
+				req, expected := ethBackendMessages(call.Method)
+				require.NotNil(t, req, call.Method)
+				reply := proto.Clone(expected)
+				require.NoError(t, proto.Unmarshal(decodeHex(t, call.Request), req))
+				require.NoError(t, proto.Unmarshal(decodeHex(t, call.Reply), expected))
+				require.NoError(t, conn.Invoke(context.Background(), "/remote.ETHBACKEND/"+call.Method, req, reply))
+				require.True(t, proto.Equal(expected, reply), "%s: expected %v, got %v", call.Method, expected, reply)
+			}
+		})
+	}
+}
+
+func TestEthBackendFeatures(t *testing.T) {
+	defer func(f remoteapi.Features) { EthBackendFeatures = f }(EthBackendFeatures)
+	EthBackendFeatures = 0b11
+
+	var header metadata.MD
+	_, err := remote.NewETHBACKENDClient(dialEthBackend(t)).Version(context.Background(), &emptypb.Empty{}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Equal(t, EthBackendFeatures, remoteapi.ReceiveFeatures(header))
+
+	// clients which send their features get the common ones
+	ctx := remoteapi.WithFeatures(context.Background(), 0b110)
+	_, err = remote.NewETHBACKENDClient(dialEthBackend(t)).Version(ctx, &emptypb.Empty{}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Equal(t, remoteapi.Features(0b10), remoteapi.ReceiveFeatures(header))
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
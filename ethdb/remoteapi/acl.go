@@ -0,0 +1,40 @@
+package remoteapi
+
+// BucketACL restricts buckets, which clients can read. nil - all buckets are allowed.
+type BucketACL struct {
+	allow map[string]struct{} // nil - all buckets, except denied ones
+	deny  map[string]struct{}
+}
+
+// NewBucketACL allows only the allow buckets, if there are any, except the deny ones. Without both it returns nil.
+func NewBucketACL(allow, deny []string) *BucketACL {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	acl := &BucketACL{deny: map[string]struct{}{}}
+	if len(allow) > 0 {
+		acl.allow = map[string]struct{}{}
+		for _, bucket := range allow {
+			acl.allow[bucket] = struct{}{}
+		}
+	}
+	for _, bucket := range deny {
+		acl.deny[bucket] = struct{}{}
+	}
+	return acl
+}
+
+// Allowed tells if the bucket can be read
+func (acl *BucketACL) Allowed(bucket string) bool {
+	if acl == nil {
+		return true
+	}
+	if _, ok := acl.deny[bucket]; ok {
+		return false
+	}
+	if acl.allow == nil {
+		return true
+	}
+	_, ok := acl.allow[bucket]
+	return ok
+}
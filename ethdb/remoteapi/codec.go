@@ -0,0 +1,117 @@
+package remoteapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// EncodeStat encodes the number of the statistics ops (OpCount and others) and of arguments of some ops
+func EncodeStat(n uint64) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, n)
+	return v
+}
+
+func DecodeStat(v []byte) (uint64, error) {
+	if len(v) != 8 {
+		return 0, fmt.Errorf("invalid statistics reply of %d bytes", len(v))
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// EncodeMultiGetKeys encodes keys as a sequence of uvarint length and bytes
+func EncodeMultiGetKeys(keys [][]byte) []byte {
+	size := 0
+	for _, k := range keys {
+		size += binary.MaxVarintLen32 + len(k)
+	}
+	buf := make([]byte, 0, size)
+	var l [binary.MaxVarintLen64]byte
+	for _, k := range keys {
+		buf = append(buf, l[:binary.PutUvarint(l[:], uint64(len(k)))]...)
+		buf = append(buf, k...)
+	}
+	return buf
+}
+
+func DecodeMultiGetKeys(buf []byte) ([][]byte, error) {
+	var keys [][]byte
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, fmt.Errorf("invalid MultiGet keys")
+		}
+		keys = append(keys, buf[n:n+int(l)])
+		buf = buf[n+int(l):]
+	}
+	return keys, nil
+}
+
+// EncodeMultiGetValues encodes values as a sequence of uvarint length+1 and bytes, 0 is a missing key
+func EncodeMultiGetValues(buf []byte, v []byte, found bool) []byte {
+	var l [binary.MaxVarintLen64]byte
+	if !found {
+		return append(buf, 0)
+	}
+	buf = append(buf, l[:binary.PutUvarint(l[:], uint64(len(v))+1)]...)
+	return append(buf, v...)
+}
+
+// DecodeMultiGetValues returns values of the reply, nil for missing keys
+func DecodeMultiGetValues(buf []byte) ([][]byte, error) {
+	var values [][]byte
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || (l > 0 && uint64(len(buf)-n) < l-1) {
+			return nil, fmt.Errorf("invalid MultiGet values")
+		}
+		buf = buf[n:]
+		if l == 0 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, buf[:l-1:l-1])
+		buf = buf[l-1:]
+	}
+	return values, nil
+}
+
+// EncodeBatchPair appends the pair as uvarint length+1 and bytes of the key, uvarint length and bytes of the value
+func EncodeBatchPair(buf []byte, k, v []byte) []byte {
+	var l [binary.MaxVarintLen64]byte
+	buf = append(buf, l[:binary.PutUvarint(l[:], uint64(len(k))+1)]...)
+	buf = append(buf, k...)
+	buf = append(buf, l[:binary.PutUvarint(l[:], uint64(len(v)))]...)
+	return append(buf, v...)
+}
+
+// EncodeBatchEnd appends the mark of the end of the bucket
+func EncodeBatchEnd(buf []byte) []byte {
+	return append(buf, 0)
+}
+
+// DecodeBatch returns pairs of the reply and true if the end of the bucket is reached after them
+func DecodeBatch(buf []byte) (keys, values [][]byte, end bool, err error) {
+	for len(buf) > 0 {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || (l > 0 && uint64(len(buf)-n) < l-1) {
+			return nil, nil, false, fmt.Errorf("invalid NextBatch pairs")
+		}
+		buf = buf[n:]
+		if l == 0 {
+			if len(buf) > 0 {
+				return nil, nil, false, fmt.Errorf("invalid NextBatch pairs: %d bytes after the end", len(buf))
+			}
+			return keys, values, true, nil
+		}
+		keys = append(keys, buf[:l-1:l-1])
+		buf = buf[l-1:]
+		l, n = binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, nil, false, fmt.Errorf("invalid NextBatch pairs")
+		}
+		values = append(values, buf[n:n+int(l):n+int(l)])
+		buf = buf[n+int(l):]
+	}
+	return keys, values, false, nil
+}
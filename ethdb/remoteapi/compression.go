@@ -0,0 +1,130 @@
+package remoteapi
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// Compressors of the Tx stream, clients choose one of those supported by the server (FeatureSnappy, FeatureZstd)
+// and the server replies with the same one. Snappy is cheap enough for LAN, zstd saves more of WAN bandwidth.
+const (
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+// CompressionFeature returns the feature, which the server must support to accept the compression
+func CompressionFeature(name string) (Features, error) {
+	switch name {
+	case CompressionSnappy:
+		return FeatureSnappy, nil
+	case CompressionZstd:
+		return FeatureZstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression of remote KV: %q, supported: %s, %s", name, CompressionSnappy, CompressionZstd)
+	}
+}
+
+func init() {
+	encoding.RegisterCompressor(&snappyCompressor{})
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+type snappyCompressor struct {
+	writers sync.Pool
+}
+
+func (c *snappyCompressor) Name() string { return CompressionSnappy }
+
+func (c *snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	sw, ok := c.writers.Get().(*snappyWriter)
+	if !ok {
+		sw = &snappyWriter{Writer: snappy.NewBufferedWriter(w), pool: &c.writers}
+	} else {
+		sw.Reset(w)
+	}
+	return sw, nil
+}
+
+func (c *snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+// snappyWriter returns itself to the pool on Close, as grpc never uses the writer after that
+type snappyWriter struct {
+	*snappy.Writer
+	pool *sync.Pool
+}
+
+func (w *snappyWriter) Close() error {
+	defer w.pool.Put(w)
+	return w.Writer.Close()
+}
+
+// zstdCompressor encodes and decodes each message in the calling goroutine, the messages are small
+// and gRPC streams are many
+type zstdCompressor struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func (c *zstdCompressor) Name() string { return CompressionZstd }
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	zw, ok := c.writers.Get().(*zstdWriter)
+	if !ok {
+		enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &zstdWriter{Encoder: enc, pool: &c.writers}, nil
+	}
+	zw.Reset(w)
+	return zw, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	zr, ok := c.readers.Get().(*zstdReader)
+	if !ok {
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &zstdReader{Decoder: dec, pool: &c.readers}, nil
+	}
+	if err := zr.Reset(r); err != nil {
+		c.readers.Put(zr)
+		return nil, err
+	}
+	return zr, nil
+}
+
+// zstdWriter returns itself to the pool on Close, as grpc never uses the writer after that
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	defer w.pool.Put(w)
+	return w.Encoder.Close()
+}
+
+// zstdReader returns itself to the pool at the end of the message, as grpc reads it to io.EOF and
+// never uses the reader after that. Readers of failed messages are left to GC.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r)
+	}
+	return n, err
+}
@@ -0,0 +1,49 @@
+package remoteapi
+
+import (
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DeadlineField is the number of the field of remote.Cursor, in which clients send the time left until the deadline
+// of their request, in microseconds. The field is not in the .proto, protobuf passes it as an unknown field, so old
+// servers ignore it. Long ops (OpMultiGet, OpNextBatch) stop at the deadline, and when the client
+// cancels the stream.
+const DeadlineField protowire.Number = 100
+
+// SetDeadline sets the time left until the deadline of the op, replacing the previous one
+func SetDeadline(in *remote.Cursor, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = time.Microsecond // passed already, but 0 means no deadline
+	}
+	b := protowire.AppendTag(nil, DeadlineField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(timeout/time.Microsecond))
+	in.ProtoReflect().SetUnknown(b)
+}
+
+// Deadline returns the time left until the deadline of the op, false - the client didn't set it
+func Deadline(in *remote.Cursor) (time.Duration, bool) {
+	b := in.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, false
+		}
+		b = b[n:]
+		if num == DeadlineField && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 || v == 0 {
+				return 0, false
+			}
+			return time.Duration(v) * time.Microsecond, true
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return 0, false
+		}
+		b = b[n:]
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+// Package remoteapi has definitions shared by clients and servers of the remote gRPC services (KV, ETHBACKEND)
+package remoteapi
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// FeaturesHeader is the gRPC header in which the server advertises its Features as a reply to the Version call.
+// Features are passed in the header and not in VersionReply, so old clients simply ignore them
+// and old servers are seen as supporting no optional features. Clients send their Features in the metadata
+// of the Version call under the same key, the server replies with the features supported by both sides then.
+const FeaturesHeader = "x-erigon-features"
+
+// Features is a bitmask of optional protocol capabilities. Peers use the intersection of features
+// supported by both sides, so newer clients degrade against older servers and vice versa. Features gate only
+// optional ops, they don't replace the version check: peers still need the same major and minor version
+// (gointerfaces.EnsureVersion).
+type Features uint64
+
+const (
+	// FeatureMultiGet - OpMultiGet reads many keys in one message
+	FeatureMultiGet Features = 1 << iota
+	// FeatureStats - OpCount, OpCountDuplicates and OpBucketSize return statistics of buckets
+	FeatureStats
+	// FeatureSequence - OpReadSequence reads sequences of buckets
+	FeatureSequence
+	// FeatureSnappy - the Tx stream may be compressed by CompressionSnappy
+	FeatureSnappy
+	// FeatureZstd - the Tx stream may be compressed by CompressionZstd
+	FeatureZstd
+	// FeatureViews - OpPinView pins transactions at their views, Tx streams can be opened at pinned views by ViewHeader
+	FeatureViews
+	// FeatureNextBatch - OpNextBatch reads many pairs by one move of the cursor forward
+	FeatureNextBatch
+	// FeatureTemporal - OpDomainGet, OpHistorySeek and OpIndexRange read the history of the state
+	FeatureTemporal
+	// FeatureWriteTx - write transactions (kv.RwTx) over the Tx stream. Reserved, not supported by this version:
+	// servers don't advertise it and BeginRw of clients fails by remotedb.ErrWriteTxNotSupported
+	FeatureWriteTx
+	// FeatureHints - OpHint declares the upcoming access pattern, the server reads pages of the bucket ahead of cursors
+	FeatureHints
+	// FeatureAdmin - OpCreateBucket, OpDropBucket, OpClearBucket and OpListBuckets manage tables, advertised only by
+	// servers with the admin token (see remotedbserver.KvServer.WithAdminToken) and negotiated only by clients with it
+	FeatureAdmin
+	// FeatureOpRange - values of remote.Op from OpReservedFirst to OpReservedLast are the ops of this package,
+	// OpFeatures are used only with it
+	FeatureOpRange
+)
+
+// KvServiceFeatures - optional features of the KV service supported by this version
+var KvServiceFeatures = FeatureMultiGet | FeatureStats | FeatureSequence | FeatureSnappy | FeatureZstd | FeatureViews | FeatureNextBatch | FeatureTemporal | FeatureHints | FeatureOpRange
+
+// Has returns true if all features of f2 are present in f
+func (f Features) Has(f2 Features) bool { return f&f2 == f2 }
+
+func (f Features) String() string { return "0x" + strconv.FormatUint(uint64(f), 16) }
+
+// SendFeatures attaches features to the headers of the current gRPC call. If the client sent its features,
+// only the features supported by both sides are attached.
+func SendFeatures(ctx context.Context, f Features) {
+	if client, ok := ClientFeatures(ctx); ok {
+		f &= client
+	}
+	// fails only when called outside of gRPC server (f.e. directly in tests), nothing to attach features to then
+	_ = grpc.SetHeader(ctx, metadata.Pairs(FeaturesHeader, strconv.FormatUint(uint64(f), 16)))
+}
+
+// WithFeatures returns the context of the client call, which sends the features of the client to the server
+func WithFeatures(ctx context.Context, f Features) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, FeaturesHeader, strconv.FormatUint(uint64(f), 16))
+}
+
+// ClientFeatures returns the features sent by the client of the current gRPC call, false for old clients,
+// which don't send them
+func ClientFeatures(ctx context.Context) (Features, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return parseFeatures(md)
+}
+
+// ReceiveFeatures parses features from headers received with the Version reply
+func ReceiveFeatures(md metadata.MD) Features {
+	f, _ := parseFeatures(md)
+	return f
+}
+
+// NegotiateFeatures returns the features supported by both sides by headers received with the Version reply,
+// and whether the server negotiates features at all
+func NegotiateFeatures(local Features, md metadata.MD) (Features, bool) {
+	f, ok := parseFeatures(md)
+	return f & local, ok
+}
+
+func parseFeatures(md metadata.MD) (Features, bool) {
+	values := md.Get(FeaturesHeader)
+	if len(values) == 0 {
+		return 0, false
+	}
+	f, err := strconv.ParseUint(values[0], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return Features(f), true
+}
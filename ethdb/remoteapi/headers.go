@@ -0,0 +1,34 @@
+package remoteapi
+
+import "time"
+
+// MaxTxTTL - the server renews the read transaction of the Tx stream so often, unless the view is pinned by OpPinView
+const MaxTxTTL = 30 * time.Second
+
+// ViewHeader is the gRPC header, in which the server sends the view of the database the Tx stream reads: ID of the last
+// transaction committed before it. A client which lost the connection sends the view in the metadata of the new Tx
+// stream to continue at the same view: the server refuses with codes.FailedPrecondition if the database changed since.
+const ViewHeader = "x-erigon-view"
+
+// AdminHeader is the gRPC metadata key, in which clients send the admin token of the server with Tx streams of admin
+// ops (OpCreateBucket and others). Streams without the token are closed with codes.PermissionDenied and
+// LimitAdmin in LimitTrailer. Tables of the schema of the node, except deprecated ones, can't be changed, tables not
+// allowed by remotedbserver.KvServer.WithBucketACL can't be touched at all.
+// Changes are done by a separate write transaction, committed before the reply, so they are not seen by the
+// read transaction of the stream - only by transactions begun after the reply. The configuration of tables of the node
+// is not changed: tables created by admin ops are listed at once, but can be read only after restart of the node
+// (see ethdb.OpenAuxiliaryTables); transactions, which read dropped tables, fail.
+const AdminHeader = "x-erigon-admin-token"
+
+// LimitTrailer is the gRPC trailer, in which the server tells which of its remotedbserver.Limits closed the stream
+const LimitTrailer = "x-erigon-limit"
+
+// Values of LimitTrailer
+const (
+	LimitCursors    = "cursors"
+	LimitTxLifetime = "tx-lifetime"
+	LimitIdle       = "idle"
+	LimitBucket     = "bucket"   // the bucket is not allowed by the BucketACL of the server
+	LimitDeadline   = "deadline" // the op stopped at the deadline sent by the client, see DeadlineField
+	LimitAdmin      = "admin"    // the admin op is sent without the admin token of the server, see AdminHeader
+)
@@ -0,0 +1,62 @@
+package remoteapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// HintScanLimit - the max number of pairs read by one scan hint
+const HintScanLimit = 100_000
+
+const (
+	hintScan byte = 1
+	hintKeys byte = 2
+)
+
+// Hint is the access pattern declared by the client: a sequential scan of N pairs from the key From, or a set of Keys
+type Hint struct {
+	From []byte
+	N    uint64
+	Keys [][]byte // not nil for hints of keys
+}
+
+// EncodeScanHint encodes the hint of the sequential scan of n pairs from the key from, 0 - HintScanLimit
+func EncodeScanHint(from []byte, n uint64) []byte {
+	buf := make([]byte, 9, 9+len(from))
+	buf[0] = hintScan
+	binary.BigEndian.PutUint64(buf[1:], n)
+	return append(buf, from...)
+}
+
+// EncodeKeysHint encodes the hint of reads of the keys
+func EncodeKeysHint(keys [][]byte) []byte {
+	return append([]byte{hintKeys}, EncodeMultiGetKeys(keys)...)
+}
+
+func DecodeHint(buf []byte) (*Hint, error) {
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("empty hint")
+	}
+	switch buf[0] {
+	case hintScan:
+		if len(buf) < 9 {
+			return nil, fmt.Errorf("invalid scan hint of %d bytes", len(buf))
+		}
+		n := binary.BigEndian.Uint64(buf[1:])
+		if n == 0 || n > HintScanLimit {
+			n = HintScanLimit
+		}
+		return &Hint{From: buf[9:], N: n}, nil
+	case hintKeys:
+		keys, err := DecodeMultiGetKeys(buf[1:])
+		if err != nil {
+			return nil, err
+		}
+		if keys == nil {
+			keys = [][]byte{}
+		}
+		return &Hint{Keys: keys}, nil
+	default:
+		return nil, fmt.Errorf("unknown hint %d", buf[0])
+	}
+}
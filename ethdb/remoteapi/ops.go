@@ -0,0 +1,107 @@
+package remoteapi
+
+import "github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+
+// Ops of the KV Tx stream, which are not in the .proto enum of erigon-lib: protobuf passes unknown values of enums
+// as is, so they are added here without changing the pinned erigon-lib. All of them are optional, every one is used
+// only if its feature is negotiated (see Features). Values from OpReservedFirst to OpReservedLast are reserved for
+// them, above all values of the .proto enum. The range itself is negotiated by FeatureOpRange, so a peer, which
+// gives the values another meaning, never gets them. New ops take the next free value of the range and are declared
+// only in this file, so two ops never share a value.
+const (
+	// OpReservedFirst - the first value of the range reserved for ops of this file
+	OpReservedFirst remote.Op = 64
+	// OpReservedLast - the last value of the range reserved for ops of this file
+	OpReservedLast remote.Op = 127
+)
+
+// The build fails if the reserved range is not above remote.Op_CLOSE, the largest value of the .proto enum.
+// TestOpsReserved checks the rest of the enum.
+var _ = [OpReservedFirst - remote.Op_CLOSE - 1]struct{}{}
+
+// OpFeatures - features, which use ops of the reserved range. They are negotiated only together with FeatureOpRange.
+const OpFeatures = FeatureMultiGet | FeatureStats | FeatureSequence | FeatureViews | FeatureNextBatch | FeatureTemporal |
+	FeatureHints | FeatureAdmin
+
+// WithOpRange returns the negotiated features without OpFeatures, if the reserved range of ops is not negotiated
+func WithOpRange(f Features) Features {
+	if !f.Has(FeatureOpRange) {
+		return f &^ OpFeatures
+	}
+	return f
+}
+
+// OpMultiGet reads values of many keys of the bucket in one message, it's used only if FeatureMultiGet is negotiated.
+// Request: BucketName and K - keys encoded by EncodeMultiGetKeys.
+// Reply: V - values of the first keys encoded by EncodeMultiGetValues. The reply is limited by
+// remotedbserver.MultiGetReplyLimit, values of the rest of the keys are asked by the next request.
+const OpMultiGet remote.Op = 64
+
+// Statistics ops, used only if FeatureStats is negotiated.
+// Reply: V - the number, 8 bytes big-endian.
+const (
+	// OpCount - amount of entries in the bucket of the cursor (kv.Cursor.Count)
+	OpCount remote.Op = 65
+	// OpCountDuplicates - amount of values of the current key of the DupSort cursor (kv.CursorDupSort.CountDuplicates)
+	OpCountDuplicates remote.Op = 66
+	// OpBucketSize - size of the bucket BucketName in bytes (kv.Tx.BucketSize)
+	OpBucketSize remote.Op = 67
+)
+
+// OpReadSequence - current value of the sequence of BucketName (kv.Tx.ReadSequence), used only if FeatureSequence
+// is negotiated. Reply: V - the value, 8 bytes big-endian.
+const OpReadSequence remote.Op = 68
+
+// OpPinView pins the transaction at its view: it's not renewed every MaxTxTTL anymore, and while it's
+// alive, other Tx streams can be opened at the view by ViewHeader even after the database changed -
+// they share the transaction. Used only if FeatureViews is negotiated.
+// Reply: V - the view, 8 bytes big-endian, 0 - unknown, not pinned then.
+const OpPinView remote.Op = 69
+
+// OpNextBatch moves the cursor forward by up to N pairs and returns all of them, it's used only if FeatureNextBatch
+// is negotiated. The cursor stays at the last returned pair, or past the last key if the end is reached.
+// Request: Cursor and K - N encoded by EncodeStat.
+// Reply: V - pairs encoded by EncodeBatchPair, followed by EncodeBatchEnd if the end is
+// reached. The reply is limited by remotedbserver.MultiGetReplyLimit, at least one pair is returned.
+const OpNextBatch remote.Op = 70
+
+// Ops of the history of the state (temporal.Tx), used only if FeatureTemporal is negotiated. They let clients read
+// the state of old blocks by one round trip, instead of walking history indices and changesets by cursors.
+// Request: BucketName - the changeset table (kv.AccountChangeSet or kv.StorageChangeSet), K - the key of kv.PlainState.
+const (
+	// OpDomainGet - the value of the key before the block (temporal.Tx.GetAsOf).
+	// Request: V - the block, 8 bytes big-endian. Reply: V - the value encoded by EncodeMultiGetValues.
+	OpDomainGet remote.Op = 71
+	// OpHistorySeek - the value of the key before its first change at or after the block (temporal.Tx.HistorySeek).
+	// Request: V - the block, 8 bytes big-endian. Reply: V - the value encoded by EncodeMultiGetValues,
+	// missing if there are no such changes.
+	OpHistorySeek remote.Op = 72
+	// OpIndexRange - the blocks in [from, to), in which the key was changed (temporal.Tx.IndexRange).
+	// Request: V - from and to, 8 bytes big-endian each. Reply: V - the end of the range covered by the reply
+	// (8 bytes big-endian) and the blocks before it as a serialized roaring64 bitmap. The reply is limited by
+	// remotedbserver.MultiGetReplyLimit, the rest of the range is asked by the next request.
+	OpIndexRange remote.Op = 73
+)
+
+// OpHint declares the upcoming access pattern of the client, so that the server reads pages of the bucket into
+// the cache ahead of cursors of the client, it's used only if FeatureHints is negotiated. The reply is sent at once,
+// pages are read in the background by a separate read transaction, until the Tx stream ends. Hints are dropped
+// if remotedbserver.HintWorkers hints are read already.
+// Request: BucketName and K - the hint encoded by EncodeScanHint or EncodeKeysHint.
+// Reply: empty.
+const OpHint remote.Op = 74
+
+// Admin ops manage auxiliary tables of the database for maintenance tools, they are used only if FeatureAdmin is
+// negotiated, see AdminHeader for the rules of their use.
+// Request: BucketName, empty for OpListBuckets.
+// Reply: empty, or names of tables encoded by EncodeMultiGetKeys for OpListBuckets.
+const (
+	// OpCreateBucket creates the table, if it doesn't exist
+	OpCreateBucket remote.Op = 75
+	// OpDropBucket drops the table, if it exists
+	OpDropBucket remote.Op = 76
+	// OpClearBucket deletes all pairs of the table, if it exists
+	OpClearBucket remote.Op = 77
+	// OpListBuckets lists tables of the database allowed by BucketACL
+	OpListBuckets remote.Op = 78
+)
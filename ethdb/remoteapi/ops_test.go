@@ -0,0 +1,29 @@
+package remoteapi
+
+import (
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsReserved(t *testing.T) {
+	ops := []remote.Op{OpMultiGet, OpCount, OpCountDuplicates, OpBucketSize, OpReadSequence, OpPinView, OpNextBatch,
+		OpDomainGet, OpHistorySeek, OpIndexRange, OpHint, OpCreateBucket, OpDropBucket, OpClearBucket, OpListBuckets}
+	seen := map[remote.Op]bool{}
+	for _, op := range ops {
+		require.True(t, op >= OpReservedFirst && op <= OpReservedLast, "op %d", op)
+		require.False(t, seen[op], "op %d is declared twice", op)
+		seen[op] = true
+	}
+	for op := range remote.Op_name {
+		require.Less(t, remote.Op(op), OpReservedFirst, "op %d of the .proto enum", op)
+	}
+}
+
+func TestOpRangeNegotiated(t *testing.T) {
+	require.True(t, KvServiceFeatures.Has(FeatureOpRange))
+	require.Equal(t, KvServiceFeatures, WithOpRange(KvServiceFeatures))
+	// peers, which don't reserve the range, get no ops of it
+	require.Equal(t, FeatureSnappy|FeatureZstd, WithOpRange(KvServiceFeatures&^FeatureOpRange))
+}
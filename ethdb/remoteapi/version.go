@@ -0,0 +1,10 @@
+package remoteapi
+
+import "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
+
+// KvServiceAPIVersion - use it to track changes in API
+// 1.1.0 - added pending transactions, add methods eth_getRawTransactionByHash, eth_retRawTransactionByBlockHashAndIndex, eth_retRawTransactionByBlockNumberAndIndex| Yes     |                                            |
+// 1.2.0 - Added separated services for mining and txpool methods
+// 2.0.0 - Rename all buckets
+// 3.1.0 - Senders of a block are packed (see rawdb.EncodeSenders), clients of 3.0 can't decode them
+var KvServiceAPIVersion = &types.VersionReply{Major: 3, Minor: 1, Patch: 0}
@@ -0,0 +1,96 @@
+package remotedb
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithAdminToken sends the admin token of the server (see remotedbserver.KvServer.WithAdminToken) with admin ops of
+// Migrator. Use it only over TLS, the token is sent in plain text otherwise.
+func (opts remoteOpts) WithAdminToken(token string) remoteOpts {
+	opts.adminToken = token
+	return opts
+}
+
+// Migrator returns kv.BucketMigrator of auxiliary tables of the remote database, for maintenance tools. Every call
+// is done by a separate transaction, changes are committed by the server before the call returns and are seen by
+// transactions begun afterwards. Calls go to DialAddress, never to replicas. They fail by ErrAdminNotSupported
+// if remoteapi.FeatureAdmin is not negotiated, by ErrAdminDenied if the admin token is wrong and by
+// ErrBucketNotAllowed for tables of the schema of the server (see remoteapi.OpCreateBucket).
+func (db *RemoteKV) Migrator(ctx context.Context) kv.BucketMigrator {
+	return &remoteMigrator{db: db, ctx: ctx}
+}
+
+type remoteMigrator struct {
+	db  *RemoteKV
+	ctx context.Context
+}
+
+func (m *remoteMigrator) CreateBucket(name string) error {
+	_, err := m.do(remoteapi.OpCreateBucket, name)
+	return err
+}
+
+func (m *remoteMigrator) DropBucket(name string) error {
+	_, err := m.do(remoteapi.OpDropBucket, name)
+	return err
+}
+
+func (m *remoteMigrator) ClearBucket(name string) error {
+	_, err := m.do(remoteapi.OpClearBucket, name)
+	return err
+}
+
+func (m *remoteMigrator) ExistsBucket(name string) (bool, error) {
+	names, err := m.ListBuckets()
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *remoteMigrator) ListBuckets() ([]string, error) {
+	pair, err := m.do(remoteapi.OpListBuckets, "")
+	if err != nil {
+		return nil, err
+	}
+	keys, err := remoteapi.DecodeMultiGetKeys(pair.V)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = string(k)
+	}
+	return names, nil
+}
+
+// do sends the admin op by a new transaction to DialAddress
+func (m *remoteMigrator) do(op remote.Op, bucket string) (*remote.Pair, error) {
+	if !m.db.features.Has(remoteapi.FeatureAdmin) {
+		return nil, ErrAdminNotSupported
+	}
+	client := m.db.remoteKV
+	if m.db.pool != nil {
+		client = m.db.pool.endpoints[0].client
+	}
+	ctx := metadata.AppendToOutgoingContext(m.ctx, remoteapi.AdminHeader, m.db.opts.adminToken) // also of reconnects
+	streamCtx, streamCancelFn := context.WithCancel(ctx)
+	stream, err := client.Tx(streamCtx, m.db.txOpts...)
+	if err != nil {
+		streamCancelFn()
+		return nil, err
+	}
+	tx := &remoteTx{ctx: ctx, db: m.db, client: client, stream: limitsStream{stream}, streamCancelFn: streamCancelFn}
+	defer tx.Rollback()
+	return tx.roundTrip(&remote.Cursor{Op: op, BucketName: bucket}, nil)
+}
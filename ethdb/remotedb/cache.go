@@ -0,0 +1,136 @@
+package remotedb
+
+import (
+	"context"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// readCache keeps values read by GetOne, so hot keys (chain config, canonical hashes, recent headers) are not
+// asked from the server again. Entries are keyed by the view of the database, in which they were read (see
+// remoteapi.ViewHeader): a transaction sees only values of its own view, shared with other transactions of
+// the same view, so it never mixes values of different blocks. Transactions, for which the server doesn't tell
+// the view, are not cached. Entries of old views are useless: they are dropped on state changes streamed by
+// the server, after ttl if it's not 0, and by LRU anyway.
+type readCache struct {
+	entries *lru.Cache
+	ttl     time.Duration // 0 - entries live until the next state change
+}
+
+type cacheKey struct {
+	view   cacheView
+	bucket string
+	key    string
+}
+
+// cacheView is the view of the database on the server, views of different servers (replicas) are not related
+type cacheView struct {
+	server string
+	view   string
+}
+
+type cacheEntry struct {
+	v       []byte // nil - the key is not in the bucket
+	expires time.Time
+}
+
+func newReadCache(size int, ttl time.Duration) (*readCache, error) {
+	entries, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &readCache{entries: entries, ttl: ttl}, nil
+}
+
+func (c *readCache) get(view cacheView, bucket string, key []byte) ([]byte, bool) {
+	e, ok := c.entries.Get(cacheKey{view: view, bucket: bucket, key: string(key)})
+	if !ok {
+		return nil, false
+	}
+	entry := e.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.v, true
+}
+
+func (c *readCache) put(view cacheView, bucket string, key, v []byte) {
+	entry := &cacheEntry{v: v}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	c.entries.Add(cacheKey{view: view, bucket: bucket, key: string(key)}, entry)
+}
+
+// invalidate drops all entries, they are of the old views after the state change
+func (c *readCache) invalidate() {
+	c.entries.Purge()
+}
+
+// cacheView returns the view of the transaction in the read cache, false - values of the transaction are not
+// cached: the server doesn't tell the view, or it could renew the transaction at a newer view (see
+// remoteapi.MaxTxTTL), so the transaction doesn't read the view it was started at anymore
+func (tx *remoteTx) cacheView() (cacheView, bool) {
+	if time.Since(tx.begun) >= remoteapi.MaxTxTTL {
+		return cacheView{}, false
+	}
+	tx.streamMu.Lock()
+	defer tx.streamMu.Unlock()
+	// servers with FeatureViews send the header right away, older ones - with the first reply
+	if !tx.replied && !tx.db.features.Has(remoteapi.FeatureViews) {
+		return cacheView{}, false
+	}
+	view, err := tx.view()
+	if err != nil {
+		return cacheView{}, false
+	}
+	var server string
+	if e, ok := tx.client.(*endpoint); ok {
+		server = e.address
+	}
+	return cacheView{server: server, view: view}, true
+}
+
+// InvalidateCache drops all values of the read cache, it's done automatically when the server streams state changes
+func (db *RemoteKV) InvalidateCache() {
+	if db.cache != nil {
+		db.cache.invalidate()
+	}
+}
+
+// invalidateOnStateChanges invalidates the cache on every state change streamed by the server, until ctx is done.
+// Notifications missed while the stream is broken invalidate the cache too, when it's restored.
+func (db *RemoteKV) invalidateOnStateChanges(ctx context.Context) {
+	for {
+		stream, err := db.remoteKV.ReceiveStateChanges(ctx, &emptypb.Empty{}, grpc.WaitForReady(true))
+		if err == nil {
+			db.cache.invalidate()
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+				db.cache.invalidate()
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			db.log.Debug("server doesn't stream state changes, entries of read cache of old views are dropped by LRU and TTL", "ttl", db.cache.ttl)
+			return
+		}
+		db.log.Debug("state changes stream broken, read cache is reset", "err", err)
+		db.cache.invalidate()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
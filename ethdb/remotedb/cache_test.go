@@ -0,0 +1,131 @@
+package remotedb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// stateChangesServer streams the state changes sent to the channel
+type stateChangesServer struct {
+	*remotedbserver.KvServer
+	changes chan *remote.StateChange
+}
+
+func (s *stateChangesServer) ReceiveStateChanges(_ *emptypb.Empty, stream remote.KV_ReceiveStateChangesServer) error {
+	for {
+		select {
+		case change := <-s.changes:
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func openCachedRemote(t *testing.T, server remote.KVServer, ttl time.Duration) *RemoteKV {
+	return openInMemRemote(t, server, 16, ttl)
+}
+
+// openInMemRemote opens the remote database served by the server, with the read cache of cacheSize entries, 0 - disabled
+func openInMemRemote(t *testing.T, server remote.KVServer, cacheSize int, ttl time.Duration) *RemoteKV {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	remote.RegisterKVServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+	db, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).WithReadCache(cacheSize, ttl).Open("", "", "")
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	require.True(t, db.EnsureVersionCompatibility())
+	return db
+}
+
+func getOne(t *testing.T, db kv.RoDB, key string) []byte {
+	var v []byte
+	require.NoError(t, db.View(context.Background(), func(tx kv.Tx) (err error) {
+		v, err = tx.GetOne(kv.HeaderCanonical, []byte(key))
+		return err
+	}))
+	return v
+}
+
+func put(t *testing.T, db kv.RwDB, key, value string) {
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Put(kv.HeaderCanonical, []byte(key), []byte(value))
+	}))
+}
+
+func TestReadCacheViews(t *testing.T) {
+	ctx := context.Background()
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	// the server doesn't stream state changes, nothing invalidates the cache
+	remoteDB := openCachedRemote(t, remotedbserver.NewKvServer(db), 0)
+
+	tx, err := remoteDB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	v, err := tx.GetOne(kv.HeaderCanonical, []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	// transactions of the same view share the entries
+	require.Equal(t, []byte("1"), getOne(t, remoteDB, "a"))
+	require.Nil(t, getOne(t, remoteDB, "b"))
+	require.Equal(t, 2, remoteDB.cache.entries.Len())
+
+	// new transactions see the change even without notification, older ones keep their view
+	put(t, db, "a", "2")
+	require.Equal(t, []byte("2"), getOne(t, remoteDB, "a"))
+	require.Equal(t, 3, remoteDB.cache.entries.Len())
+	v, err = tx.GetOne(kv.HeaderCanonical, []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	v, err = tx.GetOne(kv.HeaderCanonical, []byte("b"))
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	remoteDB.InvalidateCache()
+	require.Equal(t, 0, remoteDB.cache.entries.Len())
+}
+
+func TestReadCacheInvalidation(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	server := &stateChangesServer{KvServer: remotedbserver.NewKvServer(db), changes: make(chan *remote.StateChange)}
+	remoteDB := openCachedRemote(t, server, 0)
+
+	require.Equal(t, []byte("1"), getOne(t, remoteDB, "a"))
+	// the change is taken by the server once the client is subscribed
+	server.changes <- &remote.StateChange{BlockHeight: 1}
+	require.Eventually(t, func() bool { return remoteDB.cache.entries.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReadCacheTTL(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	remoteDB := openCachedRemote(t, remotedbserver.NewKvServer(db), 50*time.Millisecond)
+	view := cacheView{view: "1"}
+	remoteDB.cache.put(view, kv.HeaderCanonical, []byte("a"), []byte("1"))
+	v, ok := remoteDB.cache.get(view, kv.HeaderCanonical, []byte("a"))
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), v)
+	require.Eventually(t, func() bool {
+		_, ok := remoteDB.cache.get(view, kv.HeaderCanonical, []byte("a"))
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond, "expired by TTL")
+}
@@ -0,0 +1,97 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+)
+
+const (
+	// cloneBatch - pairs read by one round trip of CloneBucket, if the server supports remoteapi.OpNextBatch
+	cloneBatch = 4096
+	// cloneCommitEvery - pairs written into the local database by one transaction of CloneBucket
+	cloneCommitEvery = 1_000_000
+)
+
+// CloneBucket copies the bucket of the remote database into the local mdbx database at localPath (created if it
+// doesn't exist), for offline analysis of tables of remote nodes. The bucket is read by one transaction, so the copy
+// is consistent, and written by Append in the order of keys, so it must be empty in the local database.
+// Returns the amount of copied pairs, on errors - of pairs committed into the local database before.
+func (db *RemoteKV) CloneBucket(ctx context.Context, bucket, localPath string) (uint64, error) {
+	cfg, ok := db.buckets[bucket]
+	if !ok {
+		return 0, fmt.Errorf("unknown bucket %s", bucket)
+	}
+	local, err := mdbx.NewMDBX(db.log).Path(localPath).WithTablessCfg(func(kv.TableCfg) kv.TableCfg { return db.buckets }).Open()
+	if err != nil {
+		return 0, err
+	}
+	defer local.Close()
+	if err = local.View(ctx, func(tx kv.Tx) error {
+		c, err := tx.Cursor(bucket)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		k, _, err := c.First()
+		if err != nil {
+			return err
+		}
+		if k != nil {
+			return fmt.Errorf("bucket %s is not empty in %s", bucket, localPath)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.beginRo(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	c, err := tx.Cursor(bucket)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	c.(*remoteCursor).batch = cloneBatch
+
+	// the same rule as of loading of etl.Collector
+	dupSort := cfg.Flags&kv.DupSort != 0 && !cfg.AutoDupSortKeysConversion
+	var copied uint64
+	k, v, err := c.First()
+	for k != nil && err == nil {
+		var n uint64
+		err = local.Update(ctx, func(rwTx kv.RwTx) error {
+			w, err := rwTx.RwCursorDupSort(bucket)
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			for ; k != nil && n < cloneCommitEvery; n++ {
+				if dupSort {
+					err = w.AppendDup(k, v)
+				} else {
+					err = w.Append(k, v)
+				}
+				if err != nil {
+					return fmt.Errorf("append %x: %w", k, err)
+				}
+				if k, v, err = c.Next(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			copied += n
+			if k != nil {
+				db.log.Info("Cloning bucket", "bucket", bucket, "copied", copied)
+			}
+		}
+	}
+	return copied, err
+}
@@ -0,0 +1,70 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneBucket(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := 0; i < 10_000; i++ {
+			if err := tx.Put(kv.HeaderCanonical, []byte(fmt.Sprintf("%05d", i)), []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		for _, v := range []string{"x1", "x2", "x3"} {
+			for _, k := range []string{"k1", "k2"} {
+				if err := tx.Put(kv.AccountChangeSet, []byte(k), []byte(v)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	server := startRestartableServer(t, db)
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+
+	path := filepath.Join(t.TempDir(), "clone")
+	for bucket, amount := range map[string]uint64{kv.HeaderCanonical: 10_000, kv.AccountChangeSet: 6} {
+		copied, err := remoteDB.CloneBucket(context.Background(), bucket, path)
+		require.NoError(t, err)
+		require.Equal(t, amount, copied)
+	}
+	// the bucket is cloned already
+	_, err = remoteDB.CloneBucket(context.Background(), kv.HeaderCanonical, path)
+	require.Error(t, err)
+
+	local := mdbx.NewMDBX(log.New()).Path(path).MustOpen()
+	defer local.Close()
+	require.NoError(t, local.View(context.Background(), func(localTx kv.Tx) error {
+		return db.View(context.Background(), func(tx kv.Tx) error {
+			for _, bucket := range []string{kv.HeaderCanonical, kv.AccountChangeSet} {
+				var expected, cloned [][2]string
+				require.NoError(t, tx.ForEach(bucket, nil, func(k, v []byte) error {
+					expected = append(expected, [2]string{string(k), string(v)})
+					return nil
+				}))
+				require.NoError(t, localTx.ForEach(bucket, nil, func(k, v []byte) error {
+					cloned = append(cloned, [2]string{string(k), string(v)})
+					return nil
+				}))
+				require.Equal(t, expected, cloned, bucket)
+			}
+			return nil
+		})
+	}))
+}
@@ -0,0 +1,38 @@
+package remotedb
+
+import (
+	"net"
+	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// inProcessBufSize is the buffer of in-process connections, see InProcess
+const inProcessBufSize = 4 * 1024 * 1024
+
+// UnixSocket connects to the server on the same host by the unix socket at the path, avoiding the TCP stack.
+// The same as Path("unix://" + absolute path).
+func (opts remoteOpts) UnixSocket(path string) remoteOpts {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	opts.DialAddress = "unix://" + path
+	return opts
+}
+
+// InProcess connects to the gRPC server of the same process (with the KV service registered), without sockets
+// at all: the server serves an in-memory listener of the database until it's closed.
+func (opts remoteOpts) InProcess(server *grpc.Server) remoteOpts {
+	opts.inProcess = server
+	opts.DialAddress = "in-process"
+	return opts
+}
+
+// listenInProcess makes the in-process server serve the in-memory listener, which is dialled by the database
+func (opts *remoteOpts) listenInProcess() net.Listener {
+	listener := bufconn.Listen(inProcessBufSize)
+	go func() { _ = opts.inProcess.Serve(listener) }()
+	opts.inMemConn = listener
+	return listener
+}
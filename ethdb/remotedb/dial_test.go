@@ -0,0 +1,90 @@
+package remotedb
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnixSocket(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	path := filepath.Join(t.TempDir(), "private.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db))
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	opts := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New())
+	for _, opts := range []remoteOpts{opts.UnixSocket(path), opts.Path("unix://" + path)} {
+		remoteDB, err := opts.Open("", "", "")
+		require.NoError(t, err)
+		require.True(t, remoteDB.EnsureVersionCompatibility())
+		require.Equal(t, []byte("1"), getOne(t, remoteDB, "a"))
+		remoteDB.Close()
+	}
+}
+
+func TestInProcess(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	server := grpc.NewServer()
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db))
+	defer server.Stop()
+
+	opts := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InProcess(server)
+	for i := 0; i < 2; i++ { // the server outlives databases
+		remoteDB, err := opts.Open("", "", "")
+		require.NoError(t, err)
+		require.True(t, remoteDB.EnsureVersionCompatibility())
+		require.Equal(t, []byte("1"), getOne(t, remoteDB, "a"))
+		remoteDB.Close()
+	}
+}
+
+func TestConnTuning(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	path := filepath.Join(t.TempDir(), "private.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 10 * time.Millisecond, PermitWithoutStream: true}))
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db))
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).UnixSocket(path).
+		MaxSendMsgSize(datasize.KB).WindowSize(datasize.MB, 4*datasize.MB).
+		Keepalive(keepalive.ClientParameters{Time: 10 * time.Millisecond, PermitWithoutStream: true}).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+
+	// Pings of the idle connection are permitted by the server, so it is not dropped
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, []byte("1"), getOne(t, remoteDB, "a"))
+
+	err = remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		_, err := tx.GetOne(kv.HeaderCanonical, make([]byte, 2*datasize.KB))
+		return err
+	})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "%v", err)
+}
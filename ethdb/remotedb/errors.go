@@ -0,0 +1,89 @@
+package remotedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc/status"
+)
+
+// Errors of transactions closed by the server because of its remotedbserver.Limits
+var (
+	ErrTooManyCursors     = errors.New("too many cursors in remote transaction")
+	ErrTxLifetimeExceeded = errors.New("remote transaction is open too long")
+	ErrTxIdle             = errors.New("remote transaction is idle too long")
+)
+
+// ErrBucketNotAllowed - the bucket is not allowed by the remoteapi.BucketACL of the client (see WithBucketACL)
+// or of the server
+var ErrBucketNotAllowed = errors.New("bucket is not allowed")
+
+// ErrStatsNotSupported - the server is too old to return statistics of buckets (remoteapi.FeatureStats)
+var ErrStatsNotSupported = errors.New("statistics of buckets are not supported by the remote server")
+
+// ErrSequenceNotSupported - the server is too old to read sequences (remoteapi.FeatureSequence)
+var ErrSequenceNotSupported = errors.New("sequences are not supported by the remote server")
+
+// ErrWriteTxNotSupported - the server doesn't accept write transactions (remoteapi.FeatureWriteTx), no server
+// of this version does
+var ErrWriteTxNotSupported = errors.New("write transactions are not supported by the remote server")
+
+// ErrViewsNotSupported - the server is too old to pin views of transactions (remoteapi.FeatureViews)
+var ErrViewsNotSupported = errors.New("views of transactions are not supported by the remote server")
+
+// ErrAdminNotSupported - the server doesn't accept admin ops of Migrator (remoteapi.FeatureAdmin): it's too old,
+// it has no admin token, or the client has none (see WithAdminToken)
+var ErrAdminNotSupported = errors.New("admin ops are not supported by the remote server")
+
+// ErrAdminDenied - the server refused admin ops of Migrator, the admin token is wrong
+var ErrAdminDenied = errors.New("admin ops are denied by the remote server")
+
+// limitsStream maps errors of the streams closed because of the server limits to the typed errors
+type limitsStream struct {
+	remote.KV_TxClient
+}
+
+func (s limitsStream) Send(m *remote.Cursor) error {
+	err := s.KV_TxClient.Send(m)
+	if errors.Is(err, io.EOF) { // the server closed the stream, the reason is returned by Recv
+		if _, recvErr := s.KV_TxClient.Recv(); recvErr != nil {
+			return s.mapError(recvErr)
+		}
+	}
+	return err
+}
+
+func (s limitsStream) Recv() (*remote.Pair, error) {
+	pair, err := s.KV_TxClient.Recv()
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return pair, nil
+}
+
+func (s limitsStream) mapError(err error) error {
+	var limitErr error
+	switch limit := s.Trailer().Get(remoteapi.LimitTrailer); {
+	case len(limit) == 0:
+		return err
+	case limit[0] == remoteapi.LimitCursors:
+		limitErr = ErrTooManyCursors
+	case limit[0] == remoteapi.LimitTxLifetime:
+		limitErr = ErrTxLifetimeExceeded
+	case limit[0] == remoteapi.LimitIdle:
+		limitErr = ErrTxIdle
+	case limit[0] == remoteapi.LimitBucket:
+		limitErr = ErrBucketNotAllowed
+	case limit[0] == remoteapi.LimitDeadline:
+		limitErr = context.DeadlineExceeded
+	case limit[0] == remoteapi.LimitAdmin:
+		limitErr = ErrAdminDenied
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %s", limitErr, status.Convert(err).Message())
+}
@@ -0,0 +1,273 @@
+package remotedb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/types"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// FailoverPolicy chooses the server of new transactions among healthy ones, see WithReplicas
+type FailoverPolicy int
+
+const (
+	FailoverPriority    FailoverPolicy = iota // the first healthy server in the order of addresses
+	FailoverLeastLoaded                       // the healthy server with the fewest open transactions
+)
+
+func (p FailoverPolicy) String() string {
+	switch p {
+	case FailoverPriority:
+		return "priority"
+	case FailoverLeastLoaded:
+		return "least-loaded"
+	default:
+		return fmt.Sprintf("FailoverPolicy(%d)", int(p))
+	}
+}
+
+// ParseFailoverPolicy parses the name of the policy: priority or least-loaded
+func ParseFailoverPolicy(name string) (FailoverPolicy, error) {
+	switch name {
+	case "priority":
+		return FailoverPriority, nil
+	case "least-loaded":
+		return FailoverLeastLoaded, nil
+	default:
+		return 0, fmt.Errorf("unknown failover policy %q, expected priority or least-loaded", name)
+	}
+}
+
+// WithReplicas adds servers of the same chain (f.e. a fleet of Erigon nodes), which back DialAddress: new transactions
+// go to the server chosen by the policy among healthy ones. A server is unhealthy if it's not reachable, or if its head
+// (progress of the last stage) is more than maxLag blocks behind the best one, 0 - lag is not checked. Transactions,
+// which read something already, stay on their server: its view of the database doesn't exist on others.
+func (opts remoteOpts) WithReplicas(policy FailoverPolicy, maxLag uint64, addresses ...string) remoteOpts {
+	opts.DialAddresses = append(append([]string{}, opts.DialAddresses...), addresses...)
+	opts.failoverPolicy = policy
+	opts.maxLag = maxLag
+	return opts
+}
+
+// endpoint is one of the servers of the pool, its client counts open transactions
+type endpoint struct {
+	address string
+	conn    *grpc.ClientConn
+	client  remote.KVClient
+
+	open    int64  // Tx streams, accessed atomically
+	down    int32  // 1 - not reachable, accessed atomically
+	head    uint64 // accessed atomically
+	checked int32  // 1 - the head is known, accessed atomically
+}
+
+func (e *endpoint) Version(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*types.VersionReply, error) {
+	return e.client.Version(ctx, in, opts...)
+}
+
+// Tx counts the stream as open until its context is done: it's always cancelled when the transaction ends
+func (e *endpoint) Tx(ctx context.Context, opts ...grpc.CallOption) (remote.KV_TxClient, error) {
+	stream, err := e.client.Tx(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&e.open, 1)
+	go func() {
+		<-ctx.Done()
+		atomic.AddInt64(&e.open, -1)
+	}()
+	return stream, nil
+}
+
+func (e *endpoint) ReceiveStateChanges(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (remote.KV_ReceiveStateChangesClient, error) {
+	return e.client.ReceiveStateChanges(ctx, in, opts...)
+}
+
+// readHead reads the progress of the last stage of the server
+func (e *endpoint) readHead(ctx context.Context) (uint64, error) {
+	stream, err := e.client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err = stream.Send(&remote.Cursor{Op: remote.Op_OPEN, BucketName: kv.SyncStageProgress}); err != nil {
+		return 0, err
+	}
+	pair, err := stream.Recv()
+	if err != nil {
+		return 0, err
+	}
+	if err = stream.Send(&remote.Cursor{Op: remote.Op_SEEK_EXACT, Cursor: pair.CursorID, K: []byte(stages.Finish)}); err != nil {
+		return 0, err
+	}
+	if pair, err = stream.Recv(); err != nil {
+		return 0, err
+	}
+	if len(pair.V) < 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(pair.V), nil
+}
+
+// endpointPool is the client of the servers, which sends every call to the server chosen by the policy.
+// endpoints[0] is DialAddress, its connection is owned by RemoteKV.
+type endpointPool struct {
+	endpoints []*endpoint
+	policy    FailoverPolicy
+	maxLag    uint64
+	log       log.Logger
+
+	mu      sync.Mutex // guards current
+	current *endpoint  // the last chosen one, to log switches
+	stop    context.CancelFunc
+}
+
+func newEndpointPool(conns []*grpc.ClientConn, addresses []string, policy FailoverPolicy, maxLag uint64, logger log.Logger) *endpointPool {
+	p := &endpointPool{policy: policy, maxLag: maxLag, log: logger}
+	for i, conn := range conns {
+		p.endpoints = append(p.endpoints, &endpoint{address: addresses[i], conn: conn, client: remote.NewKVClient(conn)})
+	}
+	p.current = p.endpoints[0]
+	return p
+}
+
+// pick returns the server of the next call. If no server is healthy, it's the first one - calls wait for it or fail
+// as without replicas.
+func (p *endpointPool) pick() *endpoint {
+	var best uint64
+	for _, e := range p.endpoints {
+		if atomic.LoadInt32(&e.down) == 0 && atomic.LoadInt32(&e.checked) == 1 {
+			if head := atomic.LoadUint64(&e.head); head > best {
+				best = head
+			}
+		}
+	}
+	var chosen *endpoint
+	for _, e := range p.endpoints {
+		if atomic.LoadInt32(&e.down) == 1 {
+			continue
+		}
+		if p.maxLag > 0 && atomic.LoadInt32(&e.checked) == 1 && atomic.LoadUint64(&e.head)+p.maxLag < best {
+			continue
+		}
+		if chosen == nil {
+			chosen = e
+			if p.policy == FailoverPriority {
+				break
+			}
+			continue
+		}
+		if atomic.LoadInt64(&e.open) < atomic.LoadInt64(&chosen.open) {
+			chosen = e
+		}
+	}
+	if chosen == nil {
+		chosen = p.endpoints[0]
+	}
+	if p.policy == FailoverPriority {
+		p.mu.Lock()
+		if p.current != chosen {
+			p.log.Warn("switched to another KV server", "from", p.current.address, "to", chosen.address)
+			p.current = chosen
+		}
+		p.mu.Unlock()
+	}
+	return chosen
+}
+
+// markDown excludes the server until the next health check finds it reachable
+func (p *endpointPool) markDown(e *endpoint, err error) {
+	if atomic.CompareAndSwapInt32(&e.down, 0, 1) {
+		p.log.Warn("KV server is down", "address", e.address, "err", err)
+	}
+}
+
+// openTx opens the Tx stream on the chosen server, trying other ones while servers are unavailable
+func (p *endpointPool) openTx(ctx context.Context, opts ...grpc.CallOption) (*endpoint, remote.KV_TxClient, error) {
+	var err error
+	for range p.endpoints {
+		e := p.pick()
+		var stream remote.KV_TxClient
+		if stream, err = e.Tx(ctx, opts...); err == nil {
+			return e, stream, nil
+		}
+		if status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		p.markDown(e, err)
+	}
+	return nil, nil, err
+}
+
+func (p *endpointPool) Version(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*types.VersionReply, error) {
+	return p.pick().Version(ctx, in, opts...)
+}
+
+func (p *endpointPool) Tx(ctx context.Context, opts ...grpc.CallOption) (remote.KV_TxClient, error) {
+	_, stream, err := p.openTx(ctx, opts...)
+	return stream, err
+}
+
+func (p *endpointPool) ReceiveStateChanges(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (remote.KV_ReceiveStateChangesClient, error) {
+	return p.pick().ReceiveStateChanges(ctx, in, opts...)
+}
+
+// checkHealth reads heads of all servers every interval, until the context is done
+func (p *endpointPool) checkHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, e := range p.endpoints {
+			wg.Add(1)
+			go func(e *endpoint) {
+				defer wg.Done()
+				p.check(ctx, e, interval)
+			}(e)
+		}
+		wg.Wait()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *endpointPool) check(ctx context.Context, e *endpoint, timeout time.Duration) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	head, err := e.readHead(checkCtx)
+	if err != nil {
+		if ctx.Err() == nil { // otherwise the pool is closed
+			p.markDown(e, err)
+		}
+		return
+	}
+	atomic.StoreUint64(&e.head, head)
+	atomic.StoreInt32(&e.checked, 1)
+	if atomic.CompareAndSwapInt32(&e.down, 1, 0) {
+		p.log.Info("KV server is up", "address", e.address, "head", head)
+	}
+}
+
+// close stops health checks and closes connections of the replicas
+func (p *endpointPool) close() {
+	p.stop()
+	for _, e := range p.endpoints[1:] {
+		if err := e.conn.Close(); err != nil {
+			p.log.Warn("failed to close connection to KV server", "address", e.address, "err", err)
+		}
+	}
+}
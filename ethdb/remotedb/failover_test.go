@@ -0,0 +1,85 @@
+package remotedb
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// startNamedServer serves the database, which tells its name by the key "server", at the head
+func startNamedServer(t *testing.T, name string, head uint64) *restartableServer {
+	db := memdb.NewTestDB(t)
+	put(t, db, "server", name)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], head)
+		return tx.Put(kv.SyncStageProgress, []byte(stages.Finish), v[:])
+	}))
+	return startRestartableServer(t, db)
+}
+
+func openReplicated(t *testing.T, policy FailoverPolicy, maxLag uint64, primary string, replicas ...string) *RemoteKV {
+	opts := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(primary).WithReplicas(policy, maxLag, replicas...)
+	opts.healthInterval = 50 * time.Millisecond
+	db, err := opts.Open("", "", "")
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestFailover(t *testing.T) {
+	a, b := startNamedServer(t, "a", 100), startNamedServer(t, "b", 100)
+	db := openReplicated(t, FailoverPriority, 0, a.addr, b.addr)
+	require.Equal(t, []byte("a"), getOne(t, db, "server"))
+
+	a.stop()
+	require.Eventually(t, func() bool {
+		return string(getOne(t, db, "server")) == "b"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.start()
+	require.Eventually(t, func() bool {
+		return string(getOne(t, db, "server")) == "a"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFailoverLag(t *testing.T) {
+	a, b := startNamedServer(t, "a", 90), startNamedServer(t, "b", 100)
+	db := openReplicated(t, FailoverPriority, 5, a.addr, b.addr)
+	require.Eventually(t, func() bool {
+		return string(getOne(t, db, "server")) == "b"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	lagging := openReplicated(t, FailoverPriority, 20, a.addr, b.addr)
+	time.Sleep(200 * time.Millisecond) // heads are checked
+	require.Equal(t, []byte("a"), getOne(t, lagging, "server"))
+}
+
+func TestFailoverLeastLoaded(t *testing.T) {
+	a, b := startNamedServer(t, "a", 100), startNamedServer(t, "b", 100)
+	db := openReplicated(t, FailoverLeastLoaded, 0, a.addr, b.addr)
+
+	tx1, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx1.Rollback()
+	tx2, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx2.Rollback()
+	v1, err := tx1.GetOne(kv.HeaderCanonical, []byte("server"))
+	require.NoError(t, err)
+	v2, err := tx2.GetOne(kv.HeaderCanonical, []byte("server"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, []string{string(v1), string(v2)})
+
+	_, err = ParseFailoverPolicy("random")
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package remotedb
+
+import (
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// Hinter declares the upcoming access pattern of the transaction, so that the server reads pages of the bucket into
+// the cache ahead of cursors (remoteapi.OpHint). Hints don't change cursors and results of reads, they are
+// ignored if the server doesn't support remoteapi.FeatureHints.
+type Hinter interface {
+	// HintScan declares the sequential scan of n pairs of the bucket from the key from, 0 - as many as the server reads
+	// for one hint (remoteapi.HintScanLimit)
+	HintScan(bucket string, from []byte, n uint64) error
+	// HintKeys declares reads of the keys of the bucket
+	HintKeys(bucket string, keys [][]byte) error
+}
+
+var _ Hinter = (*remoteTx)(nil)
+
+func (tx *remoteTx) HintScan(bucket string, from []byte, n uint64) error {
+	return tx.hint(bucket, remoteapi.EncodeScanHint(from, n))
+}
+
+func (tx *remoteTx) HintKeys(bucket string, keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return tx.hint(bucket, remoteapi.EncodeKeysHint(keys))
+}
+
+func (tx *remoteTx) hint(bucket string, hint []byte) error {
+	if !tx.db.features.Has(remoteapi.FeatureHints) {
+		return nil
+	}
+	_, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpHint, BucketName: bucket, K: hint}, nil)
+	return err
+}
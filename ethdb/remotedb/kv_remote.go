@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/c2h5oh/datasize"
@@ -17,22 +18,55 @@ import (
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
 	"github.com/ledgerwatch/log/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // generate the messages and services
 type remoteOpts struct {
-	bucketsCfg  mdbx.TableCfgFunc
-	inMemConn   *bufconn.Listener // for tests
-	DialAddress string
-	version     gointerfaces.Version
-	log         log.Logger
+	bucketsCfg     mdbx.TableCfgFunc
+	inMemConn      *bufconn.Listener // for tests
+	inProcess      *grpc.Server      // of the same process, see InProcess
+	DialAddress    string
+	DialAddresses  []string       // replicas of DialAddress, see WithReplicas
+	failoverPolicy FailoverPolicy // of choosing among DialAddress and DialAddresses
+	maxLag         uint64         // of the head of a server behind the best one, 0 - not checked
+	healthInterval time.Duration  // of checks of heads and reachability of servers
+	version        gointerfaces.Version
+	log            log.Logger
+	cacheSize      int           // of the read cache in entries, 0 - no cache
+	cacheTTL       time.Duration // of entries of the read cache, 0 - until the next state change
+
+	reconnectTimeout time.Duration // of waiting for the server to re-establish transactions, 0 - disabled
+	compression      string        // of the Tx stream, "" - disabled
+	cursorShards     int           // Tx streams of one transaction, cursors are spread over them, <= 1 - one stream
+	tracer           Tracer        // of round trips, nil - only metrics
+	prefetch         int           // pairs read ahead by Next of cursors, <= 1 - disabled
+
+	acl        *remoteapi.BucketACL // of buckets, which can be read, nil - all
+	adminToken string               // sent with admin ops of Migrator, "" - admin ops are not negotiated
+
+	md                 metadata.MD // attached to every call
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+
+	dialTimeout    time.Duration // of the first connection in Open
+	maxRecvMsgSize int           // of replies, the biggest value read from the DB must fit into it
+	maxSendMsgSize int           // of requests, 0 - no limit
+	windowSize     int32         // initial flow control window of streams, 0 - default of gRPC
+	connWindowSize int32         // initial flow control window of the connection, 0 - default of gRPC
+	keepalive      keepalive.ClientParameters
+	backoff        backoff.Config // of reconnects
+
+	verifyServer bool   // verify the certificate of the server by the CA certificate, otherwise only encrypt
+	serverName   string // expected in the certificate of the server, "" - host of DialAddress
 }
 
 type RemoteKV struct {
@@ -41,25 +75,46 @@ type RemoteKV struct {
 	log      log.Logger
 	buckets  kv.TableCfg
 	opts     remoteOpts
+	features remoteapi.Features // negotiated in EnsureVersionCompatibility
+	txOpts   []grpc.CallOption  // of the Tx stream, negotiated in EnsureVersionCompatibility
+	cache    *readCache         // nil if disabled
+	stopSubs context.CancelFunc // stops the subscription to state changes
+	pool     *endpointPool      // nil - no replicas
+	listener net.Listener       // served by the in-process server, nil - not InProcess
 }
 
 type remoteTx struct {
 	stream             remote.KV_TxClient
+	client             remote.KVClient // of the server of the transaction
 	ctx                context.Context
 	streamCancelFn     context.CancelFunc
 	db                 *RemoteKV
+	begun              time.Time // if the read cache is enabled
+	replied            bool      // the server replied something, so the transaction has the view
 	cursors            []*remoteCursor
 	statelessCursors   map[string]kv.Cursor
 	streamingRequested bool
+
+	streamMu  sync.Mutex // serializes round trips of the stream, cursors of shards may be used concurrently
+	cursorsMu sync.Mutex // guards cursors and statelessCursors, never held while waiting for other locks
+	shardsMu  sync.Mutex // guards the fields below
+	shards    []*cursorShard
+	nextShard int    // round-robin counter of new cursors
+	shardView string // the view of the transaction, shards are opened at
+	noShards  bool   // shards can't be opened at the view of the transaction, the database changed since
 }
 
 type remoteCursor struct {
 	ctx        context.Context
 	stream     remote.KV_TxClient
 	tx         *remoteTx
+	shard      *cursorShard // nil - the stream of the transaction
 	bucketName string
 	bucketCfg  kv.TableCfgItem
 	id         uint32
+	k, v       []byte     // position, to restore it on reconnect; k == nil - not positioned
+	ahead      prefetched // pairs read ahead by Next, see WithPrefetch
+	batch      int        // pairs read ahead by one round trip, 0 - opts.prefetch
 }
 
 type remoteCursorDupSort struct {
@@ -85,16 +140,118 @@ func (opts remoteOpts) InMem(listener *bufconn.Listener) remoteOpts {
 	return opts
 }
 
+// WithBucketACL restricts buckets, which transactions can read: reads of other buckets fail with ErrBucketNotAllowed
+// without asking the server
+func (opts remoteOpts) WithBucketACL(acl *remoteapi.BucketACL) remoteOpts {
+	opts.acl = acl
+	return opts
+}
+
+// WithReadCache enables the cache of values read by GetOne, keeping up to size entries. Values are shared by
+// transactions of the same view of the database, so they are never stale. Entries are dropped on every state change
+// streamed by the server, after ttl if it's not 0, and by LRU.
+func (opts remoteOpts) WithReadCache(size int, ttl time.Duration) remoteOpts {
+	opts.cacheSize = size
+	opts.cacheTTL = ttl
+	return opts
+}
+
+// WithCompression compresses the Tx stream by remoteapi.CompressionSnappy or remoteapi.CompressionZstd,
+// if the server supports it. It pays off when the server is far away: values of blocks and receipts are big
+// and compress well. "" - disabled.
+func (opts remoteOpts) WithCompression(name string) remoteOpts {
+	opts.compression = name
+	return opts
+}
+
+// WithMetadata attaches the key-value pairs (f.e. auth tokens of a proxy in front of the server) to every call
+func (opts remoteOpts) WithMetadata(kv ...string) remoteOpts {
+	opts.md = metadata.Join(opts.md, metadata.Pairs(kv...))
+	return opts
+}
+
+// WithUnaryInterceptors adds interceptors of unary calls (f.e. tracing), they are called in the given order
+func (opts remoteOpts) WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) remoteOpts {
+	opts.unaryInterceptors = append(append([]grpc.UnaryClientInterceptor{}, opts.unaryInterceptors...), interceptors...)
+	return opts
+}
+
+// WithStreamInterceptors adds interceptors of streams, including the Tx stream, they are called in the given order
+func (opts remoteOpts) WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) remoteOpts {
+	opts.streamInterceptors = append(append([]grpc.StreamClientInterceptor{}, opts.streamInterceptors...), interceptors...)
+	return opts
+}
+
+// DialTimeout limits waiting for the connection in Open (default: 5s)
+func (opts remoteOpts) DialTimeout(timeout time.Duration) remoteOpts {
+	opts.dialTimeout = timeout
+	return opts
+}
+
+// MaxRecvMsgSize limits the size of one reply of the server (default: 15MB). Reads of bigger values (f.e. receipts
+// of huge blocks) fail with ResourceExhausted.
+func (opts remoteOpts) MaxRecvMsgSize(size datasize.ByteSize) remoteOpts {
+	opts.maxRecvMsgSize = int(size)
+	return opts
+}
+
+// MaxSendMsgSize limits the size of one request to the server (default: no limit)
+func (opts remoteOpts) MaxSendMsgSize(size datasize.ByteSize) remoteOpts {
+	opts.maxSendMsgSize = int(size)
+	return opts
+}
+
+// WindowSize sets initial flow control windows of streams and of the connection, which turns off their dynamic
+// growth by gRPC. Bigger windows speed up reads of big values over links with a high latency. Sizes below 64KB
+// are ignored.
+func (opts remoteOpts) WindowSize(stream, conn datasize.ByteSize) remoteOpts {
+	opts.windowSize = int32(stream)
+	opts.connWindowSize = int32(conn)
+	return opts
+}
+
+// Keepalive sets pings of idle connections, so proxies and NATs don't drop them (default: no pings)
+func (opts remoteOpts) Keepalive(params keepalive.ClientParameters) remoteOpts {
+	opts.keepalive = params
+	return opts
+}
+
+// Backoff sets delays between attempts to reconnect (default: from 500ms up to 10s)
+func (opts remoteOpts) Backoff(cfg backoff.Config) remoteOpts {
+	opts.backoff = cfg
+	return opts
+}
+
+// VerifyServerName turns on verification of the certificate of the server by the CA certificate, including the name
+// of the server (Subject Alternative Names or Common Name): serverName, or the host of the dial address if it's "".
+// Without it the connection is encrypted, but any certificate of the server is accepted.
+func (opts remoteOpts) VerifyServerName(serverName string) remoteOpts {
+	opts.verifyServer = true
+	opts.serverName = serverName
+	return opts
+}
+
 func (opts remoteOpts) Open(certFile, keyFile, caCert string) (*RemoteKV, error) {
+	if opts.compression != "" {
+		if _, err := remoteapi.CompressionFeature(opts.compression); err != nil {
+			return nil, err
+		}
+	}
 	var dialOpts []grpc.DialOption
 
-	backoffCfg := backoff.DefaultConfig
-	backoffCfg.BaseDelay = 500 * time.Millisecond
-	backoffCfg.MaxDelay = 10 * time.Second
 	dialOpts = []grpc.DialOption{
-		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoffCfg, MinConnectTimeout: 10 * time.Minute}),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(15 * datasize.MB))),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{}),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: opts.backoff, MinConnectTimeout: 10 * time.Minute}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(opts.maxRecvMsgSize)),
+		grpc.WithKeepaliveParams(opts.keepalive),
+	}
+	if opts.maxSendMsgSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(opts.maxSendMsgSize)))
+	}
+	if opts.windowSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithInitialWindowSize(opts.windowSize))
+	}
+	if opts.connWindowSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithInitialConnWindowSize(opts.connWindowSize))
 	}
 	if certFile == "" {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
@@ -102,7 +259,7 @@ func (opts remoteOpts) Open(certFile, keyFile, caCert string) (*RemoteKV, error)
 		var creds credentials.TransportCredentials
 		var err error
 		if caCert == "" {
-			creds, err = credentials.NewClientTLSFromFile(certFile, "")
+			creds, err = credentials.NewClientTLSFromFile(certFile, opts.serverName)
 
 			if err != nil {
 				return nil, err
@@ -120,30 +277,60 @@ func (opts remoteOpts) Open(certFile, keyFile, caCert string) (*RemoteKV, error)
 				return nil, err
 			}
 			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM(caCert)
-			creds = credentials.NewTLS(&tls.Config{
+			if !caCertPool.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("no certificates in the CA certificate file")
+			}
+			tlsCfg := &tls.Config{
 				Certificates: []tls.Certificate{peerCert},
 				ClientCAs:    caCertPool,
 				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}
+			if opts.verifyServer {
+				tlsCfg.RootCAs = caCertPool
+				tlsCfg.ServerName = opts.serverName
+			} else {
 				//nolint:gosec
-				InsecureSkipVerify: true, // This is to make it work when Common Name does not match - remove when procedure is updated for common name
-			})
+				tlsCfg.InsecureSkipVerify = true // This is to make it work when Common Name does not match, see VerifyServerName
+			}
+			creds = credentials.NewTLS(tlsCfg)
 		}
 
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
 	}
 
+	unaryInterceptors, streamInterceptors := opts.unaryInterceptors, opts.streamInterceptors
+	if opts.md.Len() > 0 {
+		unaryInterceptors = append([]grpc.UnaryClientInterceptor{metadataUnaryInterceptor(opts.md)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamClientInterceptor{metadataStreamInterceptor(opts.md)}, streamInterceptors...)
+	}
+	dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(unaryInterceptors...), grpc.WithChainStreamInterceptor(streamInterceptors...))
+
+	var cache *readCache
+	if opts.cacheSize > 0 {
+		var err error
+		if cache, err = newReadCache(opts.cacheSize, opts.cacheTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	var listener net.Listener
+	if opts.inProcess != nil {
+		listener = opts.listenInProcess()
+	}
 	if opts.inMemConn != nil {
 		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
 			return opts.inMemConn.Dial()
 		}))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.dialTimeout)
 	defer cancel()
 
 	conn, err := grpc.DialContext(ctx, opts.DialAddress, dialOpts...)
 	if err != nil {
+		if listener != nil {
+			listener.Close()
+		}
 		return nil, err
 	}
 
@@ -151,18 +338,66 @@ func (opts remoteOpts) Open(certFile, keyFile, caCert string) (*RemoteKV, error)
 	db := &RemoteKV{
 		opts:     opts,
 		conn:     conn,
+		listener: listener,
 		remoteKV: kvClient,
 		log:      log.New("remote_db", opts.DialAddress),
 		buckets:  kv.TableCfg{},
 	}
+	if len(opts.DialAddresses) > 0 {
+		conns := []*grpc.ClientConn{conn}
+		for _, address := range opts.DialAddresses {
+			replicaConn, err := grpc.DialContext(ctx, address, dialOpts...)
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+				if listener != nil {
+					listener.Close()
+				}
+				return nil, err
+			}
+			conns = append(conns, replicaConn)
+		}
+		db.pool = newEndpointPool(conns, append([]string{opts.DialAddress}, opts.DialAddresses...), opts.failoverPolicy, opts.maxLag, db.log)
+		db.remoteKV = db.pool
+		var healthCtx context.Context
+		healthCtx, db.pool.stop = context.WithCancel(context.Background())
+		go db.pool.checkHealth(healthCtx, opts.healthInterval)
+	}
 	customBuckets := opts.bucketsCfg(kv.ChaindataTablesCfg)
 	for name, cfg := range customBuckets { // copy map to avoid changing global variable
 		db.buckets[name] = cfg
 	}
+	if cache != nil {
+		db.cache = cache
+		var subsCtx context.Context
+		subsCtx, db.stopSubs = context.WithCancel(context.Background())
+		go db.invalidateOnStateChanges(subsCtx)
+	}
 
 	return db, nil
 }
 
+func metadataUnaryInterceptor(md metadata.MD) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withMetadata(ctx, md), method, req, reply, cc, opts...)
+	}
+}
+
+func metadataStreamInterceptor(md metadata.MD) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withMetadata(ctx, md), desc, cc, method, opts...)
+	}
+}
+
+// withMetadata adds md to the outgoing metadata of ctx, keeping the metadata set by the caller (f.e. the view of the transaction)
+func withMetadata(ctx context.Context, md metadata.MD) context.Context {
+	if outgoing, ok := metadata.FromOutgoingContext(ctx); ok {
+		return metadata.NewOutgoingContext(ctx, metadata.Join(outgoing, md))
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
 func (opts remoteOpts) MustOpen() kv.RwDB {
 	db, err := opts.Open("", "", "")
 	if err != nil {
@@ -175,34 +410,80 @@ func (opts remoteOpts) MustOpen() kv.RwDB {
 // version parameters represent the version the KV client is expecting,
 // compatibility check will be performed when the KV connection opens
 func NewRemote(v gointerfaces.Version, logger log.Logger) remoteOpts {
-	return remoteOpts{bucketsCfg: mdbx.WithChaindataTables, version: v, log: logger}
+	backoffCfg := backoff.DefaultConfig
+	backoffCfg.BaseDelay = 500 * time.Millisecond
+	backoffCfg.MaxDelay = 10 * time.Second
+	return remoteOpts{
+		bucketsCfg:     mdbx.WithChaindataTables,
+		version:        v,
+		log:            logger,
+		dialTimeout:    5 * time.Second,
+		maxRecvMsgSize: int(15 * datasize.MB),
+		backoff:        backoffCfg,
+		healthInterval: 2 * time.Second,
+	}
 }
 
 func (db *RemoteKV) AllBuckets() kv.TableCfg {
 	return db.buckets
 }
 
+// GrpcConn returns the connection to DialAddress, or, with replicas, to the server chosen for new transactions
 func (db *RemoteKV) GrpcConn() *grpc.ClientConn {
+	if db.pool != nil {
+		return db.pool.pick().conn
+	}
 	return db.conn
 }
 
 func (db *RemoteKV) EnsureVersionCompatibility() bool {
-	versionReply, err := db.remoteKV.Version(context.Background(), &emptypb.Empty{}, grpc.WaitForReady(true))
+	var header metadata.MD
+	local := remoteapi.KvServiceFeatures
+	if db.opts.adminToken != "" {
+		local |= remoteapi.FeatureAdmin
+	}
+	ctx := remoteapi.WithFeatures(context.Background(), local)
+	versionReply, err := db.remoteKV.Version(ctx, &emptypb.Empty{}, grpc.WaitForReady(true), grpc.Header(&header))
 	if err != nil {
 		db.log.Error("getting Version", "error", err)
 		return false
 	}
+	features, _ := remoteapi.NegotiateFeatures(local, header)
+	features = remoteapi.WithOpRange(features)
 	if !gointerfaces.EnsureVersion(db.opts.version, versionReply) {
 		db.log.Error("incompatible interface versions", "client", db.opts.version.String(),
 			"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch))
 		return false
 	}
+	db.features = features
+	if missing := local &^ features; missing != 0 {
+		db.log.Warn("features are not supported by the server, degraded", "missing", missing)
+	}
+	db.txOpts = nil
+	if db.opts.compression != "" {
+		if feature, _ := remoteapi.CompressionFeature(db.opts.compression); db.features.Has(feature) {
+			db.txOpts = append(db.txOpts, grpc.UseCompressor(db.opts.compression))
+		} else {
+			db.log.Warn("compression is not supported by the server, disabled", "compression", db.opts.compression)
+		}
+	}
 	db.log.Info("interfaces compatible", "client", db.opts.version.String(),
-		"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch))
+		"server", fmt.Sprintf("%d.%d.%d", versionReply.Major, versionReply.Minor, versionReply.Patch), "features", db.features)
 	return true
 }
 
+// Features returns optional features supported by both sides, known only after EnsureVersionCompatibility
+func (db *RemoteKV) Features() remoteapi.Features {
+	return db.features
+}
+
 func (db *RemoteKV) Close() {
+	if db.stopSubs != nil {
+		db.stopSubs()
+	}
+	if db.pool != nil {
+		db.pool.close()
+	}
 	if db.conn != nil {
 		if err := db.conn.Close(); err != nil {
 			db.log.Warn("failed to close remote DB", "err", err)
@@ -211,20 +492,47 @@ func (db *RemoteKV) Close() {
 		}
 		db.conn = nil
 	}
+	if db.listener != nil {
+		db.listener.Close()
+		db.listener = nil
+	}
 }
 
 func (db *RemoteKV) BeginRo(ctx context.Context) (kv.Tx, error) {
+	tx, err := db.beginRo(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// beginRo begins the transaction at the view, "" - at the latest data
+func (db *RemoteKV) beginRo(ctx context.Context, view string) (*remoteTx, error) {
 	streamCtx, streamCancelFn := context.WithCancel(ctx) // We create child context for the stream so we can cancel it to prevent leak
-	stream, err := db.remoteKV.Tx(streamCtx)
+	if view != "" {
+		streamCtx = metadata.AppendToOutgoingContext(streamCtx, remoteapi.ViewHeader, view)
+	}
+	var client remote.KVClient = db.remoteKV
+	var stream remote.KV_TxClient
+	var err error
+	if db.pool != nil {
+		client, stream, err = db.pool.openTx(streamCtx, db.txOpts...)
+	} else {
+		stream, err = db.remoteKV.Tx(streamCtx, db.txOpts...)
+	}
 	if err != nil {
 		streamCancelFn()
 		return nil, err
 	}
-	return &remoteTx{ctx: ctx, db: db, stream: stream, streamCancelFn: streamCancelFn}, nil
+	tx := &remoteTx{ctx: ctx, db: db, client: client, stream: limitsStream{stream}, streamCancelFn: streamCancelFn}
+	if db.cache != nil {
+		tx.begun = time.Now()
+	}
+	return tx, nil
 }
 
 func (db *RemoteKV) BeginRw(ctx context.Context) (kv.RwTx, error) {
-	return nil, fmt.Errorf("remote db provider doesn't support .BeginRw method")
+	return nil, ErrWriteTxNotSupported
 }
 
 func (db *RemoteKV) View(ctx context.Context, f func(tx kv.Tx) error) (err error) {
@@ -238,15 +546,22 @@ func (db *RemoteKV) View(ctx context.Context, f func(tx kv.Tx) error) (err error
 }
 
 func (db *RemoteKV) Update(ctx context.Context, f func(tx kv.RwTx) error) (err error) {
-	return fmt.Errorf("remote db provider doesn't support .Update method")
+	return ErrWriteTxNotSupported
 }
 
 func (tx *remoteTx) CollectMetrics() {}
 func (tx *remoteTx) IncrementSequence(bucket string, amount uint64) (uint64, error) {
-	panic("not implemented yet")
+	return 0, fmt.Errorf("remote db provider doesn't support .IncrementSequence method")
 }
 func (tx *remoteTx) ReadSequence(bucket string) (uint64, error) {
-	panic("not implemented yet")
+	if !tx.db.features.Has(remoteapi.FeatureSequence) {
+		return 0, ErrSequenceNotSupported
+	}
+	pair, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpReadSequence, BucketName: bucket}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return remoteapi.DecodeStat(pair.V)
 }
 func (tx *remoteTx) Append(bucket string, k, v []byte) error    { panic("no write methods") }
 func (tx *remoteTx) AppendDup(bucket string, k, v []byte) error { panic("no write methods") }
@@ -259,26 +574,40 @@ func (tx *remoteTx) Rollback() {
 	for _, c := range tx.cursors {
 		c.Close()
 	}
+	tx.closeShards()
 	tx.closeGrpcStream()
 }
 
 func (tx *remoteTx) statelessCursor(bucket string) (kv.Cursor, error) {
+	tx.cursorsMu.Lock()
+	c, ok := tx.statelessCursors[bucket]
+	tx.cursorsMu.Unlock()
+	if ok {
+		return c, nil
+	}
+	c, err := tx.Cursor(bucket)
+	if err != nil {
+		return nil, err
+	}
+	tx.cursorsMu.Lock()
+	defer tx.cursorsMu.Unlock()
 	if tx.statelessCursors == nil {
 		tx.statelessCursors = make(map[string]kv.Cursor)
 	}
-	c, ok := tx.statelessCursors[bucket]
-	if !ok {
-		var err error
-		c, err = tx.Cursor(bucket)
-		if err != nil {
-			return nil, err
-		}
-		tx.statelessCursors[bucket] = c
-	}
+	tx.statelessCursors[bucket] = c
 	return c, nil
 }
 
-func (tx *remoteTx) BucketSize(name string) (uint64, error) { panic("not implemented") }
+func (tx *remoteTx) BucketSize(name string) (uint64, error) {
+	if !tx.db.features.Has(remoteapi.FeatureStats) {
+		return 0, ErrStatsNotSupported
+	}
+	pair, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpBucketSize, BucketName: name}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return remoteapi.DecodeStat(pair.V)
+}
 
 // TODO: this must be optimized - and implemented as single command on server, with server-side buffered streaming
 func (tx *remoteTx) ForEach(bucket string, fromPrefix []byte, walker func(k, v []byte) error) error {
@@ -341,14 +670,69 @@ func (tx *remoteTx) ForAmount(bucket string, fromPrefix []byte, amount uint32, w
 }
 
 func (tx *remoteTx) GetOne(bucket string, key []byte) (val []byte, err error) {
+	cache := tx.db.cache
+	var view cacheView
+	if cache != nil {
+		var ok bool
+		if view, ok = tx.cacheView(); !ok {
+			cache = nil
+		}
+	}
+	if cache != nil {
+		if v, ok := cache.get(view, bucket, key); ok {
+			return v, nil
+		}
+	}
 	c, err := tx.statelessCursor(bucket)
 	if err != nil {
 		return nil, err
 	}
 	_, val, err = c.SeekExact(key)
+	if err == nil && cache != nil {
+		cache.put(view, bucket, key, val)
+	}
 	return val, err
 }
 
+// multiGetMaxKeys limits size of the request, the server doesn't accept messages bigger than 4MB
+const multiGetMaxKeys = 4096
+
+// GetMany returns values of the keys, nil for missing ones. If the server supports FeatureMultiGet, the keys are read
+// in one round trip (more if there are many of them or the values are big), otherwise one by one.
+func (tx *remoteTx) GetMany(bucket string, keys [][]byte) ([][]byte, error) {
+	if !tx.db.features.Has(remoteapi.FeatureMultiGet) {
+		values := make([][]byte, len(keys))
+		for i, k := range keys {
+			v, err := tx.GetOne(bucket, k)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+	values := make([][]byte, 0, len(keys))
+	for len(values) < len(keys) {
+		batch := keys[len(values):]
+		if len(batch) > multiGetMaxKeys {
+			batch = batch[:multiGetMaxKeys]
+		}
+		pair, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpMultiGet, BucketName: bucket, K: remoteapi.EncodeMultiGetKeys(batch)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		batchValues, err := remoteapi.DecodeMultiGetValues(pair.V)
+		if err != nil {
+			return nil, err
+		}
+		if len(batchValues) == 0 || len(batchValues) > len(batch) {
+			return nil, fmt.Errorf("MultiGet: %d values for %d keys", len(batchValues), len(batch))
+		}
+		values = append(values, batchValues...)
+	}
+	return values, nil
+}
+
 func (tx *remoteTx) Has(bucket string, key []byte) (bool, error) {
 	c, err := tx.statelessCursor(bucket)
 	if err != nil {
@@ -371,16 +755,26 @@ func (c *remoteCursor) Prev() ([]byte, []byte, error) {
 
 func (tx *remoteTx) Cursor(bucket string) (kv.Cursor, error) {
 	b := tx.db.buckets[bucket]
-	c := &remoteCursor{tx: tx, ctx: tx.ctx, bucketName: bucket, bucketCfg: b, stream: tx.stream}
-	tx.cursors = append(tx.cursors, c)
-	if err := c.stream.Send(&remote.Cursor{Op: remote.Op_OPEN, BucketName: c.bucketName}); err != nil {
-		return nil, err
+	c := &remoteCursor{tx: tx, ctx: tx.ctx, bucketName: bucket, bucketCfg: b, shard: tx.pickShard()}
+	msg, err := tx.roundTrip(&remote.Cursor{Op: remote.Op_OPEN, BucketName: bucket}, c)
+	if c.shard != nil && errors.Is(err, ErrTxViewChanged) { // the shard can't be opened at the view of the transaction
+		tx.disableShards()
+		c.shard = nil
+		msg, err = tx.roundTrip(&remote.Cursor{Op: remote.Op_OPEN, BucketName: bucket}, c)
 	}
-	msg, err := c.stream.Recv()
 	if err != nil {
 		return nil, err
 	}
-	c.id = msg.CursorID
+	mu := c.streamMu()
+	mu.Lock()
+	c.id, c.stream = msg.CursorID, tx.stream
+	if c.shard != nil {
+		c.stream = c.shard.stream
+	}
+	mu.Unlock()
+	tx.cursorsMu.Lock()
+	tx.cursors = append(tx.cursors, c)
+	tx.cursorsMu.Unlock()
 	return c, nil
 }
 
@@ -389,154 +783,119 @@ func (c *remoteCursor) PutNoOverwrite(key []byte, value []byte) error { panic("n
 func (c *remoteCursor) Append(key []byte, value []byte) error         { panic("not supported") }
 func (c *remoteCursor) Delete(k, v []byte) error                      { panic("not supported") }
 func (c *remoteCursor) DeleteCurrent() error                          { panic("not supported") }
-func (c *remoteCursor) Count() (uint64, error)                        { panic("not supported") }
+func (c *remoteCursor) Count() (uint64, error)                        { return c.stat(remoteapi.OpCount) }
 
-func (c *remoteCursor) first() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_FIRST}); err != nil {
-		return []byte{}, nil, err
+func (c *remoteCursor) stat(op remote.Op) (uint64, error) {
+	if !c.tx.db.features.Has(remoteapi.FeatureStats) {
+		return 0, ErrStatsNotSupported
 	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(op, nil, nil)
 	if err != nil {
-		return []byte{}, nil, err
+		return 0, err
 	}
-	return pair.K, pair.V, nil
+	return remoteapi.DecodeStat(pair.V)
 }
 
-func (c *remoteCursor) next() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_NEXT}); err != nil {
+func (c *remoteCursor) first() ([]byte, []byte, error) {
+	pair, err := c.roundTrip(remote.Op_FIRST, nil, nil)
+	if err != nil {
 		return []byte{}, nil, err
 	}
-	pair, err := c.stream.Recv()
+	return pair.K, pair.V, nil
+}
+func (c *remoteCursor) next() ([]byte, []byte, error) {
+	pair, err := c.roundTrip(remote.Op_NEXT, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) nextDup() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_NEXT_DUP}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_NEXT_DUP, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) nextNoDup() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_NEXT_NO_DUP}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_NEXT_NO_DUP, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) prev() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_PREV}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_PREV, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) prevDup() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_PREV_DUP}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_PREV_DUP, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) prevNoDup() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_PREV_NO_DUP}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_PREV_NO_DUP, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) last() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_LAST}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_LAST, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) setRange(k []byte) ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_SEEK, K: k}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_SEEK, k, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) seekExact(k []byte) ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_SEEK_EXACT, K: k}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_SEEK_EXACT, k, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) getBothRange(k, v []byte) ([]byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_SEEK_BOTH, K: k, V: v}); err != nil {
-		return nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_SEEK_BOTH, k, v)
 	if err != nil {
 		return nil, err
 	}
 	return pair.V, nil
 }
 func (c *remoteCursor) seekBothExact(k, v []byte) ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_SEEK_BOTH_EXACT, K: k, V: v}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_SEEK_BOTH_EXACT, k, v)
 	if err != nil {
 		return []byte{}, nil, err
 	}
 	return pair.K, pair.V, nil
 }
 func (c *remoteCursor) firstDup() ([]byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_FIRST_DUP}); err != nil {
-		return nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_FIRST_DUP, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	return pair.V, nil
 }
 func (c *remoteCursor) lastDup() ([]byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_LAST_DUP}); err != nil {
-		return nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_LAST_DUP, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	return pair.V, nil
 }
 func (c *remoteCursor) getCurrent() ([]byte, []byte, error) {
-	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_CURRENT}); err != nil {
-		return []byte{}, nil, err
-	}
-	pair, err := c.stream.Recv()
+	pair, err := c.roundTrip(remote.Op_CURRENT, nil, nil)
 	if err != nil {
 		return []byte{}, nil, err
 	}
@@ -547,8 +906,8 @@ func (c *remoteCursor) Current() ([]byte, []byte, error) {
 	return c.getCurrent()
 }
 
-// Seek - doesn't start streaming (because much of code does only several .Seek calls without reading sequence of data)
-// .Next() - does request streaming (if configured by user)
+// Seek - doesn't read ahead (because much of code does only several .Seek calls without reading sequence of data)
+// .Next() - does read ahead (if configured by user, see WithPrefetch)
 func (c *remoteCursor) Seek(seek []byte) ([]byte, []byte, error) {
 	return c.setRange(seek)
 }
@@ -557,7 +916,7 @@ func (c *remoteCursor) First() ([]byte, []byte, error) {
 	return c.first()
 }
 
-// Next - returns next data element from server, request streaming (if configured by user)
+// Next - returns next data element from server, or read ahead by previous calls (if configured by user)
 func (c *remoteCursor) Next() ([]byte, []byte, error) {
 	return c.next()
 }
@@ -596,6 +955,9 @@ func (tx *remoteTx) closeGrpcStream() {
 }
 
 func (c *remoteCursor) Close() {
+	mu := c.streamMu()
+	mu.Lock()
+	defer mu.Unlock()
 	if c.stream == nil {
 		return
 	}
@@ -626,7 +988,9 @@ func (c *remoteCursorDupSort) DeleteExact(k1, k2 []byte) error      { panic("not
 func (c *remoteCursorDupSort) AppendDup(k []byte, v []byte) error   { panic("not supported") }
 func (c *remoteCursorDupSort) PutNoDupData(key, value []byte) error { panic("not supported") }
 func (c *remoteCursorDupSort) DeleteCurrentDuplicates() error       { panic("not supported") }
-func (c *remoteCursorDupSort) CountDuplicates() (uint64, error)     { panic("not supported") }
+func (c *remoteCursorDupSort) CountDuplicates() (uint64, error) {
+	return c.stat(remoteapi.OpCountDuplicates)
+}
 
 func (c *remoteCursorDupSort) FirstDup() ([]byte, error) {
 	return c.firstDup()
@@ -0,0 +1,115 @@
+package remotedb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// issueCert signs a certificate for the given DNS names by the CA, or self-signs it if ca is nil
+func issueCert(t *testing.T, ca *tls.Certificate, commonName string, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca == nil,
+	}
+	parent, signer := template, interface{}(key)
+	if ca != nil {
+		parent, signer = ca.Leaf, ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeCert writes the certificate and its key in PEM, returns paths of the files
+func writeCert(t *testing.T, dir, name string, cert tls.Certificate) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600))
+	return certFile, keyFile
+}
+
+func TestVerifyServerName(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCert(t, nil, "CA")
+	caFile, _ := writeCert(t, dir, "CA", ca)
+	serverCert := issueCert(t, &ca, "erigon", "erigon.internal")
+	clientFile, clientKeyFile := writeCert(t, dir, "rpcdaemon", issueCert(t, &ca, "rpcdaemon"))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(memdb.NewTestDB(t)))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	connect := func(verify bool, serverName string) error {
+		opts := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener)
+		if verify {
+			opts = opts.VerifyServerName(serverName)
+		}
+		db, err := opts.Open(clientFile, clientKeyFile, caFile)
+		require.NoError(t, err)
+		defer db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = db.remoteKV.Version(ctx, &emptypb.Empty{})
+		return err
+	}
+	require.NoError(t, connect(false, ""))
+	require.NoError(t, connect(true, "erigon.internal"))
+	require.Error(t, connect(true, "erigon.example"))
+	require.Error(t, connect(true, "")) // the host of the dial address isn't in the certificate
+
+	// the certificate of the server must be signed by the CA
+	ca2 := issueCert(t, nil, "CA")
+	ca2File, _ := writeCert(t, dir, "CA2", ca2)
+	db, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).
+		VerifyServerName("erigon.internal").Open(clientFile, clientKeyFile, ca2File)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = db.remoteKV.Version(ctx, &emptypb.Empty{})
+	require.Error(t, err)
+}
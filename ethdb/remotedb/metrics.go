@@ -0,0 +1,108 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// names of ops which are not in the proto
+var opNames = map[remote.Op]string{
+	remoteapi.OpMultiGet:        "MULTI_GET",
+	remoteapi.OpCount:           "COUNT",
+	remoteapi.OpCountDuplicates: "COUNT_DUPLICATES",
+	remoteapi.OpBucketSize:      "BUCKET_SIZE",
+	remoteapi.OpReadSequence:    "READ_SEQUENCE",
+	remoteapi.OpPinView:         "PIN_VIEW",
+	remoteapi.OpNextBatch:       "NEXT_BATCH",
+	remoteapi.OpDomainGet:       "DOMAIN_GET",
+	remoteapi.OpHistorySeek:     "HISTORY_SEEK",
+	remoteapi.OpIndexRange:      "INDEX_RANGE",
+	remoteapi.OpHint:            "HINT",
+	remoteapi.OpCreateBucket:    "CREATE_BUCKET",
+	remoteapi.OpDropBucket:      "DROP_BUCKET",
+	remoteapi.OpClearBucket:     "CLEAR_BUCKET",
+	remoteapi.OpListBuckets:     "LIST_BUCKETS",
+}
+
+func opName(op remote.Op) string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return op.String()
+}
+
+// Tracer is told about every round trip of remote transactions, f.e. to record them as spans of OpenTelemetry
+type Tracer interface {
+	// StartOp is called before the op is sent, the returned function - when the reply is received, size is the
+	// size of the key and the value of it
+	StartOp(ctx context.Context, op, bucket string) func(size int, err error)
+}
+
+// WithTracer traces round trips of transactions, metrics of them are collected anyway: see kv_remote_op_seconds
+// and kv_remote_op_bytes
+func (opts remoteOpts) WithTracer(tracer Tracer) remoteOpts {
+	opts.tracer = tracer
+	return opts
+}
+
+type opKey struct {
+	op     remote.Op
+	bucket string
+}
+
+// opMetrics of one op on one bucket
+type opMetrics struct {
+	latency *metrics.Histogram
+	size    *metrics.Histogram // of the reply
+	errors  *metrics.Counter
+}
+
+var opMetricsByKey sync.Map // opKey -> *opMetrics, formatting names of metrics on every round trip is expensive
+
+func metricsOf(op remote.Op, bucket string) *opMetrics {
+	key := opKey{op: op, bucket: bucket}
+	if m, ok := opMetricsByKey.Load(key); ok {
+		return m.(*opMetrics)
+	}
+	labels := fmt.Sprintf(`{op=%q,bucket=%q}`, opName(op), bucket)
+	m, _ := opMetricsByKey.LoadOrStore(key, &opMetrics{
+		latency: metrics.GetOrCreateHistogram(`kv_remote_op_seconds` + labels),
+		size:    metrics.GetOrCreateHistogram(`kv_remote_op_bytes` + labels),
+		errors:  metrics.GetOrCreateCounter(`kv_remote_op_errors` + labels),
+	})
+	return m.(*opMetrics)
+}
+
+// observeOp starts measuring the round trip of the request, the returned function finishes it
+func (tx *remoteTx) observeOp(req *remote.Cursor, c *remoteCursor) func(*remote.Pair, error) {
+	bucket := req.BucketName
+	if bucket == "" && c != nil {
+		bucket = c.bucketName
+	}
+	m := metricsOf(req.Op, bucket)
+	var traceEnd func(int, error)
+	if tx.db.opts.tracer != nil {
+		traceEnd = tx.db.opts.tracer.StartOp(tx.ctx, opName(req.Op), bucket)
+	}
+	start := time.Now()
+	return func(pair *remote.Pair, err error) {
+		m.latency.UpdateDuration(start)
+		var size int
+		if pair != nil {
+			size = len(pair.K) + len(pair.V)
+		}
+		m.size.Update(float64(size))
+		if err != nil {
+			m.errors.Inc()
+		}
+		if traceEnd != nil {
+			traceEnd(size, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package remotedb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type tracedOp struct {
+	op, bucket string
+	size       int
+	err        error
+}
+
+type recordingTracer struct {
+	mu  sync.Mutex
+	ops []tracedOp
+}
+
+func (r *recordingTracer) StartOp(_ context.Context, op, bucket string) func(int, error) {
+	return func(size int, err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ops = append(r.ops, tracedOp{op: op, bucket: bucket, size: size, err: err})
+	}
+}
+
+func TestOpMetrics(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "12")
+	server := startRestartableServer(t, db)
+	tracer := &recordingTracer{}
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).
+		WithTracer(tracer).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+
+	seekExact := metricsOf(remote.Op_SEEK_EXACT, kv.HeaderCanonical)
+	before := observations(seekExact.latency)
+	require.Equal(t, []byte("12"), getOne(t, remoteDB, "a"))
+	require.Equal(t, before+1, observations(seekExact.latency))
+	require.Equal(t, before+1, observations(seekExact.size))
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	require.Equal(t, []tracedOp{
+		{op: "OPEN", bucket: kv.HeaderCanonical, size: 0},
+		{op: "SEEK_EXACT", bucket: kv.HeaderCanonical, size: 3},
+	}, tracer.ops)
+}
+
+func observations(h *metrics.Histogram) uint64 {
+	var n uint64
+	h.VisitNonZeroBuckets(func(_ string, count uint64) { n += count })
+	return n
+}
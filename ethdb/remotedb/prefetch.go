@@ -0,0 +1,90 @@
+package remotedb
+
+import (
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// WithPrefetch makes Next of cursors read ahead: one round trip returns up to n next pairs (remoteapi.OpNextBatch)
+// and following calls of Next are served from them. Any other op drops pairs read ahead and goes to the server, so
+// Seek and friends keep their single-step semantics. <= 1 - disabled, also if the server doesn't support it.
+func (opts remoteOpts) WithPrefetch(n int) remoteOpts {
+	opts.prefetch = n
+	return opts
+}
+
+// prefetched pairs of the cursor, the cursor on the server is at the last of them, or past the last key if end
+type prefetched struct {
+	keys, values [][]byte
+	end          bool // the end of the bucket is reached after the pairs
+}
+
+func (p *prefetched) pending() bool {
+	return len(p.keys) > 0 || p.end
+}
+
+func (c *remoteCursor) prefetching() bool {
+	return c.prefetch() > 1 && c.tx.db.features.Has(remoteapi.FeatureNextBatch)
+}
+
+func (c *remoteCursor) prefetch() int {
+	if c.batch > 0 {
+		return c.batch
+	}
+	return c.tx.db.opts.prefetch
+}
+
+// nextPrefetched serves Next from pairs read ahead, reading the next batch of them if there are none.
+// Called under the lock of the stream.
+func (c *remoteCursor) nextPrefetched() (*remote.Pair, error) {
+	if !c.ahead.pending() {
+		req := &remote.Cursor{Op: remoteapi.OpNextBatch, K: remoteapi.EncodeStat(uint64(c.prefetch()))}
+		pair, err := c.tx.lockedRoundTrip(req, c)
+		if err != nil {
+			return nil, err
+		}
+		keys, values, end, err := remoteapi.DecodeBatch(pair.V)
+		if err != nil {
+			return nil, err
+		}
+		c.ahead = prefetched{keys: keys, values: values, end: end || len(keys) == 0}
+	}
+	pair := &remote.Pair{}
+	if len(c.ahead.keys) > 0 {
+		pair.K, pair.V = c.ahead.keys[0], c.ahead.values[0]
+		c.ahead.keys, c.ahead.values = c.ahead.keys[1:], c.ahead.values[1:]
+	} else {
+		c.ahead.end = false
+	}
+	c.k, c.v = pair.K, pair.V
+	return pair, nil
+}
+
+// dropPrefetched drops pairs read ahead before the op. Ops relative to the position of the cursor need the cursor
+// on the server moved back to the position seen by the caller. Called under the lock of the stream.
+func (c *remoteCursor) dropPrefetched(op remote.Op) error {
+	if !c.ahead.pending() {
+		return nil
+	}
+	c.ahead = prefetched{}
+	switch op {
+	case remote.Op_FIRST, remote.Op_LAST, remote.Op_SEEK, remote.Op_SEEK_EXACT, remote.Op_SEEK_BOTH, remote.Op_SEEK_BOTH_EXACT,
+		remoteapi.OpCount:
+		return nil
+	}
+	if c.k == nil {
+		return nil
+	}
+	_, err := c.tx.lockedRoundTrip(c.positionRequest(), c)
+	return err
+}
+
+// positionRequest returns the request, which moves the cursor to its position seen by the caller
+func (c *remoteCursor) positionRequest() *remote.Cursor {
+	req := &remote.Cursor{Cursor: c.id, Op: remote.Op_SEEK_EXACT, K: c.k}
+	if c.bucketCfg.Flags&kv.DupSort != 0 {
+		req.Op, req.V = remote.Op_SEEK_BOTH_EXACT, c.v
+	}
+	return req
+}
@@ -0,0 +1,104 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := 0; i < 10; i++ {
+			if err := tx.Put(kv.HeaderCanonical, []byte(fmt.Sprintf("%03d", i)), []byte{byte(i)}); err != nil {
+				return err
+			}
+			for j := 0; j < 3; j++ {
+				if err := tx.Put(kv.AccountChangeSet, []byte(fmt.Sprintf("%03d", i)), []byte{byte(j)}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	server := startRestartableServer(t, db)
+	tracer := &recordingTracer{}
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).
+		WithTracer(tracer).WithPrefetch(4).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	c, err := tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	n := 0
+	for k, v, err := c.First(); k != nil; k, v, err = c.Next() {
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("%03d", n), string(k))
+		require.Equal(t, []byte{byte(n)}, v)
+		n++
+	}
+	require.Equal(t, 10, n)
+	tracer.mu.Lock()
+	var batches int
+	for _, op := range tracer.ops {
+		require.NotEqual(t, "NEXT", op.op)
+		if op.op == "NEXT_BATCH" {
+			batches++
+		}
+	}
+	tracer.mu.Unlock()
+	require.Equal(t, 3, batches) // 9 pairs after First and the end
+
+	// other ops see the position of the cursor, not of pairs read ahead
+	_, _, err = c.Seek([]byte("002"))
+	require.NoError(t, err)
+	k, _, err := c.Next()
+	require.NoError(t, err)
+	require.Equal(t, "003", string(k))
+	k, _, err = c.Current()
+	require.NoError(t, err)
+	require.Equal(t, "003", string(k))
+	k, _, err = c.Next()
+	require.NoError(t, err)
+	require.Equal(t, "004", string(k))
+	k, _, err = c.Prev()
+	require.NoError(t, err)
+	require.Equal(t, "003", string(k))
+	k, _, err = c.Seek([]byte("008"))
+	require.NoError(t, err)
+	require.Equal(t, "008", string(k))
+
+	dc, err := tx.CursorDupSort(kv.AccountChangeSet)
+	require.NoError(t, err)
+	k, v, err := dc.Seek([]byte("005"))
+	require.NoError(t, err)
+	require.Equal(t, "005", string(k))
+	require.Equal(t, []byte{0}, v)
+	k, v, err = dc.Next()
+	require.NoError(t, err)
+	require.Equal(t, "005", string(k))
+	require.Equal(t, []byte{1}, v)
+	k, _, err = dc.NextNoDup()
+	require.NoError(t, err)
+	require.Equal(t, "006", string(k))
+	_, v, err = dc.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, v)
+	count, err := dc.CountDuplicates()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), count)
+	_, v, err = dc.NextDup()
+	require.NoError(t, err)
+	require.Equal(t, []byte{2}, v)
+}
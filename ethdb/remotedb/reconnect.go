@@ -0,0 +1,235 @@
+package remotedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrTxViewChanged - the connection was lost and the transaction can't be re-established at the same view,
+// because the database changed since
+var ErrTxViewChanged = errors.New("remote transaction lost, database changed since")
+
+// WithReconnect makes transactions survive lost connections (f.e. restarts of Erigon): the transaction is re-opened
+// at the same view and its cursors are re-positioned, waiting for the server up to timeout. If the database changed
+// in the meantime, reads fail with ErrTxViewChanged. 0 - disabled.
+func (opts remoteOpts) WithReconnect(timeout time.Duration) remoteOpts {
+	opts.reconnectTimeout = timeout
+	return opts
+}
+
+// roundTrip sends the request of the transaction and returns the reply, re-establishing the transaction if
+// the connection is lost. c is the cursor of the request, nil for requests of the transaction itself.
+func (tx *remoteTx) roundTrip(req *remote.Cursor, c *remoteCursor) (*remote.Pair, error) {
+	mu := &tx.streamMu
+	if c != nil {
+		mu = c.streamMu()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return tx.lockedRoundTrip(req, c)
+}
+
+// lockedRoundTrip is roundTrip under the lock of the stream
+func (tx *remoteTx) lockedRoundTrip(req *remote.Cursor, c *remoteCursor) (*remote.Pair, error) {
+	if req.BucketName != "" && !tx.db.opts.acl.Allowed(req.BucketName) {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotAllowed, req.BucketName)
+	}
+	done := tx.observeOp(req, c)
+	pair, err := tx.doRoundTrip(req, c)
+	done(pair, err)
+	return pair, err
+}
+
+func (tx *remoteTx) doRoundTrip(req *remote.Cursor, c *remoteCursor) (*remote.Pair, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, err
+	}
+	// the server stops long ops at the deadline of the request, cancellation is propagated by the stream
+	if deadline, ok := tx.ctx.Deadline(); ok {
+		remoteapi.SetDeadline(req, time.Until(deadline))
+	}
+	if c != nil {
+		req.Cursor = c.id // the cursor could be re-opened by the reconnect of another one
+	}
+	if c != nil && c.shard != nil {
+		return tx.shardRoundTrip(c.shard, req, c)
+	}
+	pair, err := tx.send(req)
+	if err == nil || !tx.canReconnect(err) {
+		return pair, viewError(err)
+	}
+	tx.db.log.Warn("connection lost, re-establishing transaction", "err", err)
+	if err := tx.reconnect(); err != nil {
+		return nil, err
+	}
+	if c != nil {
+		req.Cursor = c.id
+	}
+	pair, err = tx.send(req)
+	return pair, viewError(err)
+}
+
+// viewError maps the refusal of the server to re-open the transaction at the view
+func viewError(err error) error {
+	if status.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("%w: %s", ErrTxViewChanged, status.Convert(err).Message())
+	}
+	return err
+}
+
+func (tx *remoteTx) send(req *remote.Cursor) (*remote.Pair, error) {
+	if err := tx.stream.Send(req); err != nil {
+		return nil, err
+	}
+	pair, err := tx.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	tx.replied = true
+	return pair, nil
+}
+
+func (tx *remoteTx) canReconnect(err error) bool {
+	return tx.db.opts.reconnectTimeout > 0 && status.Code(err) == codes.Unavailable && tx.ctx.Err() == nil
+}
+
+// reconnect opens the new Tx stream at the view of the lost one and re-opens all cursors at their positions.
+// Cursors, which went past the first or the last key, are re-opened unpositioned.
+func (tx *remoteTx) reconnect() error {
+	var view string
+	if tx.replied { // something was read already, the new transaction must read the same data
+		var err error
+		if view, err = tx.view(); err != nil {
+			return err
+		}
+	}
+	tx.streamCancelFn()
+	if e, ok := tx.client.(*endpoint); ok && view == "" { // nothing is read yet, the transaction may move to a replica
+		tx.db.pool.markDown(e, errors.New("connection lost"))
+		tx.client = tx.db.pool.pick()
+	}
+
+	stream, streamCancelFn, err := tx.openStream(view, tx.db.opts.reconnectTimeout)
+	if err != nil {
+		return err
+	}
+	tx.stream, tx.streamCancelFn = stream, streamCancelFn
+	cursors := tx.cursorsOf(nil)
+	if err := reopenCursors(stream, cursors, tx.send); err != nil {
+		return viewError(err)
+	}
+	tx.db.log.Info("transaction re-established", "view", view, "cursors", len(cursors))
+	return nil
+}
+
+// view returns the view of the transaction told by the server, call it only after the server replied something
+func (tx *remoteTx) view() (string, error) {
+	header, err := tx.stream.Header()
+	if views := header.Get(remoteapi.ViewHeader); err == nil && len(views) > 0 {
+		return views[0], nil
+	}
+	return "", fmt.Errorf("%w: the server doesn't tell the view", ErrTxViewChanged)
+}
+
+// openStream opens the new Tx stream at the view ("" - at the latest data), waiting up to timeout for the server
+// to become reachable, 0 - fails fast
+func (tx *remoteTx) openStream(view string, timeout time.Duration) (remote.KV_TxClient, context.CancelFunc, error) {
+	streamCtx, streamCancelFn := context.WithCancel(tx.ctx)
+	if view != "" {
+		streamCtx = metadata.AppendToOutgoingContext(streamCtx, remoteapi.ViewHeader, view)
+	}
+	if timeout == 0 {
+		stream, err := tx.client.Tx(streamCtx, tx.db.txOpts...)
+		if err != nil {
+			streamCancelFn()
+			return nil, nil, err
+		}
+		return limitsStream{stream}, streamCancelFn, nil
+	}
+	timer := time.AfterFunc(timeout, streamCancelFn)
+	stream, err := tx.client.Tx(streamCtx, append([]grpc.CallOption{grpc.WaitForReady(true)}, tx.db.txOpts...)...)
+	if !timer.Stop() {
+		err = fmt.Errorf("server is not reachable for %s", timeout)
+	}
+	if err != nil {
+		streamCancelFn()
+		return nil, nil, err
+	}
+	return limitsStream{stream}, streamCancelFn, nil
+}
+
+// cursorsOf returns the cursors of the shard, nil - of the stream of the transaction
+func (tx *remoteTx) cursorsOf(shard *cursorShard) []*remoteCursor {
+	tx.cursorsMu.Lock()
+	defer tx.cursorsMu.Unlock()
+	var cursors []*remoteCursor
+	for _, c := range tx.cursors {
+		if c.shard == shard {
+			cursors = append(cursors, c)
+		}
+	}
+	return cursors
+}
+
+// reopenCursors opens the cursors on the new stream at their positions, send is the round trip of the stream
+func reopenCursors(stream remote.KV_TxClient, cursors []*remoteCursor, send func(*remote.Cursor) (*remote.Pair, error)) error {
+	for _, c := range cursors {
+		if c.stream == nil { // closed
+			continue
+		}
+		c.stream = stream
+		c.ahead = prefetched{} // the cursor is re-positioned at the pair seen by the caller
+		pair, err := send(&remote.Cursor{Op: remote.Op_OPEN, BucketName: c.bucketName})
+		if err != nil {
+			return err
+		}
+		c.id = pair.CursorID
+		if c.k == nil {
+			continue
+		}
+		if _, err := send(c.positionRequest()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip sends the operation of the cursor and remembers the position of the cursor, to restore it on reconnect
+func (c *remoteCursor) roundTrip(op remote.Op, k, v []byte) (*remote.Pair, error) {
+	mu := c.streamMu()
+	mu.Lock()
+	defer mu.Unlock()
+	if op == remote.Op_NEXT && c.prefetching() {
+		return c.nextPrefetched()
+	}
+	if err := c.dropPrefetched(op); err != nil {
+		return nil, err
+	}
+	pair, err := c.tx.lockedRoundTrip(&remote.Cursor{Op: op, K: k, V: v}, c)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case remote.Op_CURRENT, remoteapi.OpCount, remoteapi.OpCountDuplicates:
+	case remote.Op_FIRST_DUP, remote.Op_LAST_DUP, remote.Op_SEEK_BOTH: // only the value is returned
+		if op == remote.Op_SEEK_BOTH {
+			c.k = k
+		}
+		c.v = pair.V
+		if pair.V == nil {
+			c.k = nil
+		}
+	default:
+		c.k, c.v = pair.K, pair.V
+	}
+	return pair, nil
+}
@@ -0,0 +1,142 @@
+package remotedb
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/turbo/diagnostics"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// restartableServer serves the database on the same TCP address after restarts. The database is wrapped as the one
+// of the node, so features of mdbx (views) are reached through the wrappers.
+type restartableServer struct {
+	t      *testing.T
+	db     kv.RwDB
+	addr   string
+	server *grpc.Server
+}
+
+func (s *restartableServer) start() {
+	addr := s.addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(s.t, err)
+	s.addr = listener.Addr().String()
+	s.server = grpc.NewServer()
+	remote.RegisterKVServer(s.server, remotedbserver.NewKvServer(diagnostics.TrackTxs(s.db, diagnostics.NewOpenTxs())))
+	go func() { _ = s.server.Serve(listener) }()
+}
+
+func (s *restartableServer) stop() {
+	s.server.Stop()
+}
+
+func startRestartableServer(t *testing.T, db kv.RwDB) *restartableServer {
+	s := &restartableServer{t: t, db: db}
+	s.start()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func TestReconnect(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	for _, k := range []string{"a", "b", "c"} {
+		put(t, db, k, k+"1")
+	}
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for _, v := range []string{"x1", "x2", "x3"} {
+			if err := tx.Put(kv.AccountChangeSet, []byte("k"), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	server := startRestartableServer(t, db)
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).WithReconnect(2*time.Second).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	c, err := tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	k, _, err := c.First()
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), k)
+	dc, err := tx.CursorDupSort(kv.AccountChangeSet)
+	require.NoError(t, err)
+	_, v, err := dc.First()
+	require.NoError(t, err)
+	require.Equal(t, []byte("x1"), v)
+
+	// restart of the server, the database didn't change
+	server.stop()
+	server.start()
+	k, v, err = c.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), k)
+	require.Equal(t, []byte("b1"), v)
+	_, v, err = dc.NextDup()
+	require.NoError(t, err)
+	require.Equal(t, []byte("x2"), v)
+	v, err = tx.GetOne(kv.HeaderCanonical, []byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("c1"), v)
+
+	// the database changed while the server was down
+	server.stop()
+	put(t, db, "b", "b2")
+	server.start()
+	_, _, err = c.Next()
+	require.ErrorIs(t, err, ErrTxViewChanged)
+
+	// transactions which didn't read anything yet don't care about the view
+	tx2, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx2.Rollback()
+	server.stop()
+	put(t, db, "b", "b3")
+	server.start()
+	v, err = tx2.GetOne(kv.HeaderCanonical, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b3"), v)
+
+	// the server doesn't come back
+	server.stop()
+	_, err = tx2.GetOne(kv.HeaderCanonical, []byte("a"))
+	require.Error(t, err)
+	server.start()
+}
+
+func TestNoReconnect(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	server := startRestartableServer(t, db)
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.GetOne(kv.HeaderCanonical, []byte("a"))
+	require.NoError(t, err)
+	server.stop()
+	server.start()
+	_, err = tx.GetOne(kv.HeaderCanonical, []byte("a"))
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package remotedb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+)
+
+// WithCursorShards spreads cursors of a transaction over n Tx streams (the stream of the transaction and n-1 extra
+// ones), so cursors used concurrently, f.e. iterators over logs, receipts and headers of one request, don't wait for
+// each other: round trips of one stream are serialized. Extra streams are opened on demand at the view of the
+// transaction; if the database changed since, new cursors stay on the stream of the transaction. <= 1 - disabled.
+func (opts remoteOpts) WithCursorShards(n int) remoteOpts {
+	opts.cursorShards = n
+	return opts
+}
+
+// cursorShard is the extra Tx stream of the transaction, at the same view
+type cursorShard struct {
+	mu     sync.Mutex // serializes round trips of the stream
+	stream remote.KV_TxClient
+	cancel context.CancelFunc
+	view   string
+}
+
+func (s *cursorShard) send(req *remote.Cursor) (*remote.Pair, error) {
+	if err := s.stream.Send(req); err != nil {
+		return nil, err
+	}
+	return s.stream.Recv()
+}
+
+// streamMu returns the lock of round trips of the stream of the cursor
+func (c *remoteCursor) streamMu() *sync.Mutex {
+	if c.shard != nil {
+		return &c.shard.mu
+	}
+	return &c.tx.streamMu
+}
+
+// pickShard returns the shard of the new cursor in round-robin order, nil - the stream of the transaction
+func (tx *remoteTx) pickShard() *cursorShard {
+	n := tx.db.opts.cursorShards
+	if n <= 1 {
+		return nil
+	}
+	tx.shardsMu.Lock()
+	defer tx.shardsMu.Unlock()
+	if tx.noShards {
+		return nil
+	}
+	slot := tx.nextShard % n
+	tx.nextShard++
+	if slot == 0 {
+		return nil
+	}
+	if slot <= len(tx.shards) {
+		return tx.shards[slot-1]
+	}
+
+	if tx.shardView == "" {
+		tx.streamMu.Lock()
+		if tx.replied { // otherwise the view isn't known yet, the cursor stays on the stream of the transaction
+			view, err := tx.view()
+			if err != nil {
+				tx.noShards = true
+			}
+			tx.shardView = view
+		}
+		tx.streamMu.Unlock()
+		if tx.shardView == "" {
+			tx.nextShard = 0
+			return nil
+		}
+	}
+	stream, cancel, err := tx.openStream(tx.shardView, 0)
+	if err != nil {
+		tx.db.log.Debug("cursor shard is not opened", "err", err)
+		tx.noShards = true
+		return nil
+	}
+	shard := &cursorShard{stream: stream, cancel: cancel, view: tx.shardView}
+	tx.shards = append(tx.shards, shard)
+	return shard
+}
+
+// disableShards makes new cursors stay on the stream of the transaction
+func (tx *remoteTx) disableShards() {
+	tx.shardsMu.Lock()
+	defer tx.shardsMu.Unlock()
+	tx.noShards = true
+}
+
+// shardRoundTrip sends the request of the cursor of the shard, re-opening the shard at the view of the transaction
+// if the connection is lost. Called under the lock of the shard.
+func (tx *remoteTx) shardRoundTrip(shard *cursorShard, req *remote.Cursor, c *remoteCursor) (*remote.Pair, error) {
+	pair, err := shard.send(req)
+	if err == nil || !tx.canReconnect(err) {
+		return pair, viewError(err)
+	}
+	tx.db.log.Warn("connection lost, re-establishing cursors", "err", err)
+	shard.cancel()
+	stream, cancel, err := tx.openStream(shard.view, tx.db.opts.reconnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	shard.stream, shard.cancel = stream, cancel
+	if err := reopenCursors(stream, tx.cursorsOf(shard), shard.send); err != nil {
+		return nil, viewError(err)
+	}
+	req.Cursor = c.id
+	pair, err = shard.send(req)
+	return pair, viewError(err)
+}
+
+func (tx *remoteTx) closeShards() {
+	for _, shard := range tx.shards {
+		if err := shard.stream.CloseSend(); err == nil {
+			if _, err = shard.stream.Recv(); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, context.Canceled) {
+				tx.db.log.Warn("received unexpected error from server after CloseSend", "err", err)
+			}
+		}
+		shard.cancel()
+	}
+	tx.shards = nil
+}
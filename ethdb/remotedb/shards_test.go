@@ -0,0 +1,105 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestCursorShards(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := 0; i < 100; i++ {
+			if err := tx.Put(kv.HeaderCanonical, []byte(fmt.Sprintf("%03d", i)), []byte("1")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	server := startRestartableServer(t, db)
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).
+		WithCursorShards(3).WithReconnect(2*time.Second).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	cursors := make([]kv.Cursor, 4)
+	for i := range cursors {
+		cursors[i], err = tx.Cursor(kv.HeaderCanonical)
+		require.NoError(t, err)
+	}
+	shards := tx.(*remoteTx).shards
+	require.Len(t, shards, 2)
+	require.Nil(t, cursors[0].(*remoteCursor).shard)
+	require.Equal(t, shards[0], cursors[1].(*remoteCursor).shard)
+	require.Equal(t, shards[1], cursors[2].(*remoteCursor).shard)
+	require.Nil(t, cursors[3].(*remoteCursor).shard)
+
+	walk := func() error {
+		var g errgroup.Group
+		for _, c := range cursors {
+			c := c
+			g.Go(func() error {
+				n := 0
+				for k, v, err := c.First(); k != nil; k, v, err = c.Next() {
+					if err != nil {
+						return err
+					}
+					if string(v) != "1" {
+						return fmt.Errorf("%s: %s", k, v)
+					}
+					n++
+				}
+				if n != 100 {
+					return fmt.Errorf("%d keys", n)
+				}
+				return nil
+			})
+		}
+		return g.Wait()
+	}
+	// cursors are used concurrently
+	require.NoError(t, walk())
+
+	// cursors of all streams survive restarts of the server
+	server.stop()
+	server.start()
+	require.NoError(t, walk())
+
+	// all of them read the view of the transaction
+	put(t, db, "050", "2")
+	require.NoError(t, walk())
+	server.stop()
+	server.start()
+	for _, c := range cursors {
+		_, _, err = c.Seek([]byte("010"))
+		require.ErrorIs(t, err, ErrTxViewChanged)
+	}
+
+	// new shards can't be opened at the view of the transaction after the database changed
+	tx2, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx2.Rollback()
+	c, err := tx2.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	_, _, err = c.First()
+	require.NoError(t, err)
+	put(t, db, "050", "3")
+	c, err = tx2.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	require.Nil(t, c.(*remoteCursor).shard)
+	_, v, err := c.Seek([]byte("050"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), v)
+}
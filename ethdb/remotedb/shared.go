@@ -0,0 +1,114 @@
+package remotedb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"google.golang.org/grpc"
+)
+
+// VersionChecker is the client of a service of Erigon, which checks that the server implements the expected
+// version of the service
+type VersionChecker interface {
+	EnsureVersionCompatibility() bool
+}
+
+type namedClient struct {
+	service string
+	client  VersionChecker
+}
+
+// SharedConn is the connection of clients of all services of Erigon (ETHBACKEND, TxPool, Mining) besides KV.
+// It implements grpc.ClientConnInterface over the connections of RemoteKV, so the clients share them with KV:
+// one ClientConn per server with the same backoff, keepalive, TLS, metadata and interceptors. With replicas,
+// every call and stream goes to the server, which would be picked for a new transaction of KV, so the clients
+// follow the failover of KV. Calls are measured by service in remote_grpc_call_seconds and remote_grpc_call_errors
+// metrics (errors of streams - only of their opening).
+type SharedConn struct {
+	db      *RemoteKV
+	mu      sync.Mutex
+	clients []namedClient
+}
+
+// NewSharedConn returns the connection shared with db. db is the first client of it, EnsureVersionCompatibility
+// checks it too.
+func NewSharedConn(db *RemoteKV) *SharedConn {
+	return &SharedConn{db: db, clients: []namedClient{{service: "KV", client: db}}}
+}
+
+// Register adds the client of the service, whose version is checked by EnsureVersionCompatibility
+func (c *SharedConn) Register(service string, client VersionChecker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients = append(c.clients, namedClient{service: service, client: client})
+}
+
+// EnsureVersionCompatibility checks versions of all clients, in the order of registration. Incompatible ones
+// are logged by clients themselves.
+func (c *SharedConn) EnsureVersionCompatibility() bool {
+	c.mu.Lock()
+	clients := append([]namedClient(nil), c.clients...)
+	c.mu.Unlock()
+	compatible := true
+	for _, nc := range clients {
+		if !nc.client.EnsureVersionCompatibility() {
+			c.db.log.Error("incompatible version of the service", "service", nc.service)
+			compatible = false
+		}
+	}
+	return compatible
+}
+
+func (c *SharedConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	m, start := callMetricsOf(method), time.Now()
+	err := c.db.GrpcConn().Invoke(ctx, method, args, reply, opts...)
+	m.latency.UpdateDuration(start)
+	if err != nil {
+		m.errors.Inc()
+	}
+	return err
+}
+
+func (c *SharedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := c.db.GrpcConn().NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		callMetricsOf(method).errors.Inc()
+	}
+	return stream, err
+}
+
+// serviceOf returns the service of the full method name "/package.Service/Method", without the package
+func serviceOf(method string) string {
+	service := strings.TrimPrefix(method, "/")
+	if i := strings.IndexByte(service, '/'); i >= 0 {
+		service = service[:i]
+	}
+	if i := strings.LastIndexByte(service, '.'); i >= 0 {
+		service = service[i+1:]
+	}
+	return service
+}
+
+// callMetrics of calls of one service
+type callMetrics struct {
+	latency *metrics.Histogram
+	errors  *metrics.Counter
+}
+
+var callMetricsByMethod sync.Map // method -> *callMetrics
+
+func callMetricsOf(method string) *callMetrics {
+	if m, ok := callMetricsByMethod.Load(method); ok {
+		return m.(*callMetrics)
+	}
+	labels := fmt.Sprintf(`{service=%q}`, serviceOf(method))
+	m, _ := callMetricsByMethod.LoadOrStore(method, &callMetrics{
+		latency: metrics.GetOrCreateHistogram(`remote_grpc_call_seconds` + labels),
+		errors:  metrics.GetOrCreateCounter(`remote_grpc_call_errors` + labels),
+	})
+	return m.(*callMetrics)
+}
@@ -0,0 +1,52 @@
+package remotedb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type versionChecker bool
+
+func (c versionChecker) EnsureVersionCompatibility() bool { return bool(c) }
+
+// serverOf reads the name of the server by the stream opened over the connection
+func serverOf(t *testing.T, conn *SharedConn) string {
+	stream, err := remote.NewKVClient(conn).Tx(context.Background())
+	require.NoError(t, err)
+	defer stream.CloseSend() //nolint:errcheck
+	require.NoError(t, stream.Send(&remote.Cursor{Op: remote.Op_OPEN, BucketName: kv.HeaderCanonical}))
+	opened, err := stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&remote.Cursor{Op: remote.Op_SEEK_EXACT, Cursor: opened.CursorID, K: []byte("server")}))
+	pair, err := stream.Recv()
+	require.NoError(t, err)
+	return string(pair.V)
+}
+
+func TestSharedConn(t *testing.T) {
+	a, b := startNamedServer(t, "a", 100), startNamedServer(t, "b", 100)
+	db := openReplicated(t, FailoverPriority, 0, a.addr, b.addr)
+	conn := NewSharedConn(db)
+	require.True(t, conn.EnsureVersionCompatibility())
+
+	_, err := remote.NewKVClient(conn).Version(context.Background(), &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Equal(t, "a", serverOf(t, conn))
+
+	// the clients follow the failover of KV
+	a.stop()
+	require.Eventually(t, func() bool {
+		return string(getOne(t, db, "server")) == "b"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "b", serverOf(t, conn))
+
+	conn.Register("ETHBACKEND", versionChecker(false))
+	require.False(t, conn.EnsureVersionCompatibility())
+	require.Equal(t, "ETHBACKEND", serviceOf("/remote.ETHBACKEND/Version"))
+}
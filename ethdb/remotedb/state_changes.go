@@ -0,0 +1,187 @@
+package remotedb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// stateChangesBuffer is the number of events a subscriber may lag behind the stream, the stream waits for it then
+const stateChangesBuffer = 1024
+
+// StateChangeEvent is one of NewBlockEvent, AccountChangeEvent, StorageChangeEvent, ResubscribedEvent
+type StateChangeEvent interface {
+	BlockNumber() uint64
+}
+
+// NewBlockEvent comes first for every block the server applied (or unwound), followed by changes of the block
+type NewBlockEvent struct {
+	Number uint64
+	Hash   common.Hash
+	Unwind bool // the block is unwound, changes restore the state of the parent
+}
+
+// AccountChangeEvent is the change of an account in the block, storage changes of it follow
+type AccountChangeEvent struct {
+	Block       uint64
+	Address     common.Address
+	Incarnation uint64
+	Action      remote.Action
+	Account     []byte // encoded for storage, nil if the balance or nonce didn't change
+	Code        []byte // nil if the code didn't change
+}
+
+// StorageChangeEvent is the change of a storage slot of the account in the block
+type StorageChangeEvent struct {
+	Block       uint64
+	Address     common.Address
+	Incarnation uint64
+	Location    common.Hash
+	Value       []byte
+}
+
+// ResubscribedEvent is sent when the broken stream is restored, changes in between are missed: state derived from
+// the events has to be reloaded
+type ResubscribedEvent struct {
+	Block uint64 // the last block seen before the stream broke
+}
+
+func (e NewBlockEvent) BlockNumber() uint64      { return e.Number }
+func (e AccountChangeEvent) BlockNumber() uint64 { return e.Block }
+func (e StorageChangeEvent) BlockNumber() uint64 { return e.Block }
+func (e ResubscribedEvent) BlockNumber() uint64  { return e.Block }
+
+// StateChangesSubscription delivers state changes streamed by the server. The subscription ends when the context
+// is done, Unsubscribe is called, or the server doesn't stream state changes - then Events is closed and Err tells why.
+type StateChangesSubscription struct {
+	events chan StateChangeEvent
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Events returns the channel of events, it's closed when the subscription ends
+func (s *StateChangesSubscription) Events() <-chan StateChangeEvent {
+	return s.events
+}
+
+// Err returns the reason the subscription ended, nil if it was unsubscribed
+func (s *StateChangesSubscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *StateChangesSubscription) Unsubscribe() {
+	s.cancel()
+}
+
+// SubscribeStateChanges streams state changes of the server as typed events. The broken stream is resubscribed
+// automatically, see ResubscribedEvent.
+func (db *RemoteKV) SubscribeStateChanges(ctx context.Context) *StateChangesSubscription {
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &StateChangesSubscription{events: make(chan StateChangeEvent, stateChangesBuffer), cancel: cancel}
+	go func() {
+		defer close(sub.events)
+		err := db.streamStateChanges(ctx, sub.events)
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+		if !errors.Is(err, context.Canceled) {
+			sub.err = err
+		}
+	}()
+	return sub
+}
+
+func (db *RemoteKV) streamStateChanges(ctx context.Context, events chan<- StateChangeEvent) error {
+	var last uint64
+	var broken bool
+	send := func(event StateChangeEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for {
+		stream, err := db.remoteKV.ReceiveStateChanges(ctx, &emptypb.Empty{}, grpc.WaitForReady(true))
+		if err == nil {
+			if broken {
+				if err = send(ResubscribedEvent{Block: last}); err != nil {
+					return err
+				}
+			}
+			for {
+				var change *remote.StateChange
+				if change, err = stream.Recv(); err != nil {
+					break
+				}
+				last = change.BlockHeight
+				if err = sendStateChange(change, send); err != nil {
+					return err
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if status.Code(err) == codes.Unimplemented {
+			return err
+		}
+		db.log.Debug("state changes stream broken, resubscribing", "err", err)
+		broken = true
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// sendStateChange splits the change of the block into events
+func sendStateChange(change *remote.StateChange, send func(StateChangeEvent) error) error {
+	block := change.BlockHeight
+	newBlock := NewBlockEvent{Number: block, Unwind: change.Direction == remote.Direction_UNWIND}
+	if change.BlockHash != nil {
+		newBlock.Hash = gointerfaces.ConvertH256ToHash(change.BlockHash)
+	}
+	if err := send(newBlock); err != nil {
+		return err
+	}
+	for _, ac := range change.Changes {
+		address := common.Address(gointerfaces.ConvertH160toAddress(ac.Address))
+		if err := send(AccountChangeEvent{
+			Block:       block,
+			Address:     address,
+			Incarnation: ac.Incarnation,
+			Action:      ac.Action,
+			Account:     ac.Data,
+			Code:        ac.Code,
+		}); err != nil {
+			return err
+		}
+		for _, sc := range ac.StorageChanges {
+			if err := send(StorageChangeEvent{
+				Block:       block,
+				Address:     address,
+				Incarnation: ac.Incarnation,
+				Location:    gointerfaces.ConvertH256ToHash(sc.Location),
+				Value:       sc.Data,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
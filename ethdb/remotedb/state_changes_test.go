@@ -0,0 +1,130 @@
+package remotedb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func nextEvent(t *testing.T, sub *StateChangesSubscription) StateChangeEvent {
+	select {
+	case event, ok := <-sub.Events():
+		require.True(t, ok, "subscription ended: %v", sub.Err())
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestSubscribeStateChanges(t *testing.T) {
+	server := &stateChangesServer{KvServer: remotedbserver.NewKvServer(memdb.NewTestDB(t)), changes: make(chan *remote.StateChange)}
+	db := openInMemRemote(t, server, 0, 0) // the read cache would take changes of the server too
+	sub := db.SubscribeStateChanges(context.Background())
+	defer sub.Unsubscribe()
+
+	address, location, hash := common.HexToAddress("0x1"), common.HexToHash("0x2"), common.HexToHash("0x3")
+	server.changes <- &remote.StateChange{
+		BlockHeight: 5,
+		BlockHash:   gointerfaces.ConvertHashToH256(hash),
+		Changes: []*remote.AccountChange{{
+			Address:        gointerfaces.ConvertAddressToH160(address),
+			Incarnation:    1,
+			Action:         remote.Action_UPSERT,
+			Data:           []byte{1},
+			StorageChanges: []*remote.StorageChange{{Location: gointerfaces.ConvertHashToH256(location), Data: []byte{2}}},
+		}},
+	}
+	server.changes <- &remote.StateChange{BlockHeight: 5, Direction: remote.Direction_UNWIND}
+
+	require.Equal(t, NewBlockEvent{Number: 5, Hash: hash}, nextEvent(t, sub))
+	require.Equal(t, AccountChangeEvent{Block: 5, Address: address, Incarnation: 1, Action: remote.Action_UPSERT, Account: []byte{1}}, nextEvent(t, sub))
+	require.Equal(t, StorageChangeEvent{Block: 5, Address: address, Incarnation: 1, Location: location, Value: []byte{2}}, nextEvent(t, sub))
+	require.Equal(t, NewBlockEvent{Number: 5, Unwind: true}, nextEvent(t, sub))
+
+	sub.Unsubscribe()
+	for range sub.Events() {
+	}
+	require.NoError(t, sub.Err())
+}
+
+func TestSubscribeStateChangesUnimplemented(t *testing.T) {
+	db := openInMemRemote(t, remotedbserver.NewKvServer(memdb.NewTestDB(t)), 0, 0)
+	sub := db.SubscribeStateChanges(context.Background())
+	for range sub.Events() {
+	}
+	require.Equal(t, codes.Unimplemented, status.Code(sub.Err()))
+}
+
+// broadcastServer sends every state change to all subscribed streams, as Erigon does
+type broadcastServer struct {
+	*remotedbserver.KvServer
+	mu   sync.Mutex
+	subs map[chan *remote.StateChange]struct{}
+}
+
+func (s *broadcastServer) ReceiveStateChanges(_ *emptypb.Empty, stream remote.KV_ReceiveStateChangesServer) error {
+	ch := make(chan *remote.StateChange, 16)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+	for {
+		select {
+		case change := <-ch:
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *broadcastServer) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *broadcastServer) broadcast(change *remote.StateChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		ch <- change
+	}
+}
+
+// Tests that the read cache and subscribers don't take state changes from each other
+func TestSubscribeStateChangesWithReadCache(t *testing.T) {
+	kvDB := memdb.NewTestDB(t)
+	put(t, kvDB, "a", "1")
+	server := &broadcastServer{KvServer: remotedbserver.NewKvServer(kvDB), subs: map[chan *remote.StateChange]struct{}{}}
+	db := openInMemRemote(t, server, 16, 0)
+	sub := db.SubscribeStateChanges(context.Background())
+	defer sub.Unsubscribe()
+	require.Eventually(t, func() bool { return server.subscribers() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	for block := uint64(1); block <= 3; block++ {
+		require.Equal(t, []byte("1"), getOne(t, db, "a"))
+		require.Equal(t, 1, db.cache.entries.Len())
+		server.broadcast(&remote.StateChange{BlockHeight: block})
+		require.Equal(t, NewBlockEvent{Number: block}, nextEvent(t, sub))
+		require.Eventually(t, func() bool { return db.cache.entries.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	}
+}
@@ -0,0 +1,91 @@
+package remotedb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+)
+
+// remote transactions read the history by one round trip per key, if the server supports FeatureTemporal,
+// otherwise - by cursors, as local transactions do
+var _ temporal.Tx = (*remoteTx)(nil)
+
+func (tx *remoteTx) GetAsOf(table string, key []byte, block uint64) ([]byte, error) {
+	if !tx.db.features.Has(remoteapi.FeatureTemporal) {
+		return temporal.ByCursors(tx).GetAsOf(table, key, block)
+	}
+	v, _, err := tx.temporalGet(remoteapi.OpDomainGet, table, key, block)
+	return v, err
+}
+
+func (tx *remoteTx) HistorySeek(table string, key []byte, block uint64) ([]byte, error) {
+	if !tx.db.features.Has(remoteapi.FeatureTemporal) {
+		return temporal.ByCursors(tx).HistorySeek(table, key, block)
+	}
+	v, found, err := tx.temporalGet(remoteapi.OpHistorySeek, table, key, block)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ethdb.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+// HistoryRange has no op of its own, changes of blocks are read by cursors
+func (tx *remoteTx) HistoryRange(table string, from, to uint64, walker func(block uint64, k, v []byte) (bool, error)) error {
+	return temporal.ByCursors(tx).HistoryRange(table, from, to, walker)
+}
+
+// WalkAsOf has no op of its own, the state and its history are walked by cursors
+func (tx *remoteTx) WalkAsOf(table string, prefix, fromKey []byte, block uint64, walker func(k, v []byte) (bool, error)) error {
+	return temporal.ByCursors(tx).WalkAsOf(table, prefix, fromKey, block, walker)
+}
+
+func (tx *remoteTx) IndexRange(table string, key []byte, from, to uint64) (*roaring64.Bitmap, error) {
+	if !tx.db.features.Has(remoteapi.FeatureTemporal) {
+		return temporal.ByCursors(tx).IndexRange(table, key, from, to)
+	}
+	result := roaring64.New()
+	for from < to {
+		pair, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpIndexRange, BucketName: table, K: key, V: append(remoteapi.EncodeStat(from), remoteapi.EncodeStat(to)...)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair.V) < 8 {
+			return nil, fmt.Errorf("IndexRange: invalid reply of %d bytes", len(pair.V))
+		}
+		end, _ := remoteapi.DecodeStat(pair.V[:8])
+		if end <= from {
+			return nil, fmt.Errorf("IndexRange: reply of [%d, %d) ends at %d", from, to, end)
+		}
+		blocks := roaring64.New()
+		if _, err := blocks.ReadFrom(bytes.NewReader(pair.V[8:])); err != nil {
+			return nil, err
+		}
+		result.Or(blocks)
+		from = end
+	}
+	return result, nil
+}
+
+// temporalGet returns the value of the key by the op, found = false - the key is missing
+func (tx *remoteTx) temporalGet(op remote.Op, table string, key []byte, block uint64) (v []byte, found bool, err error) {
+	pair, err := tx.roundTrip(&remote.Cursor{Op: op, BucketName: table, K: key, V: remoteapi.EncodeStat(block)}, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	values, err := remoteapi.DecodeMultiGetValues(pair.V)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) != 1 {
+		return nil, false, fmt.Errorf("%s: %d values for one key", opName(op), len(values))
+	}
+	return values[0], values[0] != nil, nil
+}
@@ -0,0 +1,89 @@
+package remotedb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemporal(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	key := dbutils.PlainGenerateCompositeStorageKey(common.HexToAddress("0x1").Bytes(), 1, common.HexToHash("0x3").Bytes())
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		index := roaring64.New()
+		for block, v := range map[uint64]string{5: "s1", 8: "s2"} {
+			csKey := append(dbutils.EncodeBlockNumber(block), key[:common.AddressLength+common.IncarnationLength]...)
+			if err := tx.Put(kv.StorageChangeSet, csKey, append(common.CopyBytes(key[common.AddressLength+common.IncarnationLength:]), v...)); err != nil {
+				return err
+			}
+			index.Add(block)
+		}
+		var buf bytes.Buffer
+		if _, err := index.WriteTo(&buf); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.StorageHistory, dbutils.StorageIndexChunkKey(key, math.MaxUint64), buf.Bytes()); err != nil {
+			return err
+		}
+		return tx.Put(kv.PlainState, key, []byte("current"))
+	}))
+	server := startRestartableServer(t, db)
+
+	for _, negotiate := range []bool{true, false} {
+		tracer := &recordingTracer{}
+		remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).
+			WithTracer(tracer).Open("", "", "")
+		require.NoError(t, err)
+		if negotiate {
+			require.True(t, remoteDB.EnsureVersionCompatibility())
+		}
+		tx, err := remoteDB.BeginRo(context.Background())
+		require.NoError(t, err)
+		ttx := temporal.New(tx)
+
+		for block, expected := range map[uint64]string{0: "s1", 6: "s2", 9: "current"} {
+			v, err := ttx.GetAsOf(kv.StorageChangeSet, key, block)
+			require.NoError(t, err)
+			require.Equal(t, expected, string(v), block)
+		}
+		v, err := ttx.HistorySeek(kv.StorageChangeSet, key, 6)
+		require.NoError(t, err)
+		require.Equal(t, "s2", string(v))
+		_, err = ttx.HistorySeek(kv.StorageChangeSet, key, 9)
+		require.True(t, errors.Is(err, ethdb.ErrKeyNotFound), err)
+		blocks, err := ttx.IndexRange(kv.StorageChangeSet, key, 0, 8)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{5}, blocks.ToArray())
+
+		tracer.mu.Lock()
+		var temporalOps int
+		for _, op := range tracer.ops {
+			switch op.op {
+			case "DOMAIN_GET", "HISTORY_SEEK", "INDEX_RANGE":
+				temporalOps++
+			}
+		}
+		tracer.mu.Unlock()
+		if negotiate {
+			require.Equal(t, 6, temporalOps) // one round trip per call
+		} else {
+			require.Zero(t, temporalOps)
+		}
+		tx.Rollback()
+		remoteDB.Close()
+	}
+}
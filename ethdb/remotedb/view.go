@@ -0,0 +1,66 @@
+package remotedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// ErrViewNotAvailable - the transaction can't be opened at the view: no transaction pins it anymore and the database
+// changed since
+var ErrViewNotAvailable = errors.New("view of the remote database is not available")
+
+// ViewTx is the transaction of RemoteKV, which tells its view of the database
+type ViewTx interface {
+	kv.Tx
+	// ViewID pins the transaction at its view and returns the view: ID of the last transaction committed before it.
+	// While the transaction is alive, BeginRoAt opens other transactions at the view.
+	ViewID() (uint64, error)
+}
+
+func (tx *remoteTx) ViewID() (uint64, error) {
+	if !tx.db.features.Has(remoteapi.FeatureViews) {
+		return 0, ErrViewsNotSupported
+	}
+	pair, err := tx.roundTrip(&remote.Cursor{Op: remoteapi.OpPinView}, nil)
+	if err != nil {
+		return 0, err
+	}
+	view, err := remoteapi.DecodeStat(pair.V)
+	if err != nil {
+		return 0, err
+	}
+	if view == 0 {
+		return 0, fmt.Errorf("%w: the server doesn't know the view of the transaction", ErrViewNotAvailable)
+	}
+	return view, nil
+}
+
+// BeginRoAt begins the read transaction at the view of another one (see ViewTx.ViewID), so they read the same data
+// even if the database changes in between, f.e. to read different buckets by concurrent transactions.
+// The new transaction is pinned at the view too.
+func (db *RemoteKV) BeginRoAt(ctx context.Context, viewID uint64) (ViewTx, error) {
+	if !db.features.Has(remoteapi.FeatureViews) {
+		return nil, ErrViewsNotSupported
+	}
+	tx, err := db.beginRo(ctx, strconv.FormatUint(viewID, 10))
+	if err != nil {
+		return nil, err
+	}
+	view, err := tx.ViewID()
+	if errors.Is(err, ErrTxViewChanged) {
+		err = fmt.Errorf("%w: %d", ErrViewNotAvailable, viewID)
+	} else if err == nil && view != viewID {
+		err = fmt.Errorf("%w: %d, the server opened %d", ErrViewNotAvailable, viewID, view)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
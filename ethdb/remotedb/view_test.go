@@ -0,0 +1,80 @@
+package remotedb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestBeginRoAt(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	put(t, db, "a", "1")
+	server := startRestartableServer(t, db)
+	remoteDB, err := NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).Path(server.addr).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	ctx := context.Background()
+	get := func(tx kv.Tx) string {
+		v, err := tx.GetOne(kv.HeaderCanonical, []byte("a"))
+		require.NoError(t, err)
+		return string(v)
+	}
+
+	tx, err := remoteDB.BeginRo(ctx)
+	require.NoError(t, err)
+	view, err := tx.(ViewTx).ViewID()
+	require.NoError(t, err)
+	require.NotZero(t, view)
+
+	// the database didn't change yet
+	tx2, err := remoteDB.BeginRoAt(ctx, view)
+	require.NoError(t, err)
+	defer tx2.Rollback()
+	put(t, db, "a", "2")
+	// the database changed, the transaction at the view shares the pinned one
+	tx3, err := remoteDB.BeginRoAt(ctx, view)
+	require.NoError(t, err)
+	view3, err := tx3.ViewID()
+	require.NoError(t, err)
+	require.Equal(t, view, view3)
+
+	var g errgroup.Group
+	for _, tx := range []kv.Tx{tx, tx2, tx3} {
+		tx := tx
+		g.Go(func() error {
+			for i := 0; i < 100; i++ {
+				if err := tx.ForEach(kv.HeaderCanonical, nil, func(k, v []byte) error {
+					require.Equal(t, "1", string(v))
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+	latest, err := remoteDB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer latest.Rollback()
+	require.Equal(t, "2", get(latest))
+
+	// the view is available while any transaction at it is alive
+	tx.Rollback()
+	tx3.Rollback()
+	tx4, err := remoteDB.BeginRoAt(ctx, view)
+	require.NoError(t, err)
+	require.Equal(t, "1", get(tx4))
+	tx4.Rollback()
+	tx2.Rollback()
+	_, err = remoteDB.BeginRoAt(ctx, view)
+	require.ErrorIs(t, err, ErrViewNotAvailable)
+}
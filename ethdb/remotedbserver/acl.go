@@ -0,0 +1,10 @@
+package remotedbserver
+
+import "github.com/ledgerwatch/erigon/ethdb/remoteapi"
+
+// WithBucketACL restricts buckets, which clients can read: transactions, which touch other ones, are closed
+// with codes.PermissionDenied and remoteapi.LimitBucket in remoteapi.LimitTrailer
+func (s *KvServer) WithBucketACL(acl *remoteapi.BucketACL) *KvServer {
+	s.acl = acl
+	return s
+}
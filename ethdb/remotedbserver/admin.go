@@ -0,0 +1,121 @@
+package remotedbserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/torquem-ch/mdbx-go/mdbx"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithAdminToken enables admin ops for clients, which send the token in remoteapi.AdminHeader. "" - admin ops are disabled.
+// Serve the KV service over TLS then, the token is sent in plain text otherwise.
+func (s *KvServer) WithAdminToken(token string) *KvServer {
+	s.adminToken = token
+	return s
+}
+
+// features returns the features advertised by the server
+func (s *KvServer) features() remoteapi.Features {
+	if s.adminToken == "" {
+		return remoteapi.KvServiceFeatures
+	}
+	return remoteapi.KvServiceFeatures | remoteapi.FeatureAdmin
+}
+
+func isAdminOp(op remote.Op) bool {
+	return op == remoteapi.OpCreateBucket || op == remoteapi.OpDropBucket || op == remoteapi.OpClearBucket || op == remoteapi.OpListBuckets
+}
+
+// isAdmin tells if the client of the stream sent the admin token of the server
+func (s *KvServer) isAdmin(ctx context.Context) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(remoteapi.AdminHeader) {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTables can't be changed by admin ops
+var schemaTables = func() map[string]struct{} {
+	tables := make(map[string]struct{}, len(kv.ChaindataTables)+len(ethdb.ErigonTables))
+	for _, name := range kv.ChaindataTables {
+		tables[name] = struct{}{}
+	}
+	for _, name := range ethdb.ErigonTables {
+		tables[name] = struct{}{}
+	}
+	return tables
+}()
+
+// isAuxiliary tells if the table can be changed by admin ops: it's not a table of the schema of the node,
+// deprecated tables are not in the schema already. The configuration of tables of the database can't tell it,
+// tables created by admin ops are added there on restart.
+func isAuxiliary(bucket string) bool {
+	_, ok := schemaTables[bucket]
+	return !ok
+}
+
+// adminOp does the admin op, tx is the read transaction of the stream
+func (s *KvServer) adminOp(ctx context.Context, tx kv.Tx, in *remote.Cursor) ([]byte, error) {
+	if in.Op == remoteapi.OpListBuckets {
+		migrator, ok := tx.(kv.BucketMigrator)
+		if !ok {
+			return nil, fmt.Errorf("tables of the database can't be listed")
+		}
+		names, err := migrator.ListBuckets()
+		if err != nil {
+			return nil, err
+		}
+		allowed := make([][]byte, 0, len(names))
+		for _, name := range names {
+			if s.acl.Allowed(name) {
+				allowed = append(allowed, []byte(name))
+			}
+		}
+		return remoteapi.EncodeMultiGetKeys(allowed), nil
+	}
+	db, ok := ethdb.UnwrapDB(s.kv).(interface{ Env() *mdbx.Env })
+	if !ok {
+		return nil, fmt.Errorf("tables of the database can't be changed")
+	}
+	// Tables are changed by mdbx directly, not by kv.BucketMigrator of the database: it changes the configuration
+	// of tables, which all transactions of the node read without locks
+	if err := db.Env().Update(func(txn *mdbx.Txn) error {
+		if in.Op == remoteapi.OpCreateBucket {
+			_, err := txn.OpenDBI(in.BucketName, mdbx.Create, nil, nil)
+			return err
+		}
+		dbi, err := txn.OpenDBI(in.BucketName, mdbx.DBAccede, nil, nil)
+		if mdbx.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return txn.Drop(dbi, in.Op == remoteapi.OpDropBucket)
+	}); err != nil {
+		return nil, err
+	}
+	metrics.GetOrCreateCounter(fmt.Sprintf(`kv_admin_ops{op="%s"}`, adminOpNames[in.Op])).Inc()
+	log.Info("[kv] table changed by remote admin", "op", adminOpNames[in.Op], "bucket", in.BucketName)
+	return nil, nil
+}
+
+var adminOpNames = map[remote.Op]string{
+	remoteapi.OpCreateBucket: "create",
+	remoteapi.OpDropBucket:   "drop",
+	remoteapi.OpClearBucket:  "clear",
+}
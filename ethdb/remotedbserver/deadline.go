@@ -0,0 +1,30 @@
+package remotedbserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc/codes"
+)
+
+// opContext is the context of the op: it's done when the stream is cancelled or the deadline of the op passes
+func opContext(ctx context.Context, in *remote.Cursor) (context.Context, context.CancelFunc) {
+	if timeout, ok := remoteapi.Deadline(in); ok {
+		if timeout <= time.Microsecond { // passed already, when the client sent the op
+			timeout = 0
+		}
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// opError closes the stream with remoteapi.LimitDeadline, if the op stopped at its deadline
+func opError(stream remote.KV_TxServer, in *remote.Cursor, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && stream.Context().Err() == nil {
+		return limitError(stream, remoteapi.LimitDeadline, codes.DeadlineExceeded, "%s of %s stopped at the deadline of the request", in.Op, in.BucketName)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package remotedbserver
+
+import (
+	"context"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// HintWorkers - the max number of hints read by the server at the same time
+const HintWorkers = 4
+
+var (
+	hintsRead    = metrics.GetOrCreateCounter(`kv_hints{result="read"}`)
+	hintsDropped = metrics.GetOrCreateCounter(`kv_hints{result="dropped"}`)
+	hintsFailed  = metrics.GetOrCreateCounter(`kv_hints{result="failed"}`)
+)
+
+// hint starts to read the bucket by the hint in the background, unless all HintWorkers are busy
+func (s *KvServer) hint(ctx context.Context, bucket string, h *remoteapi.Hint) {
+	select {
+	case s.hints <- struct{}{}:
+	default:
+		hintsDropped.Inc()
+		return
+	}
+	go func() {
+		defer func() { <-s.hints }()
+		if err := s.kv.View(ctx, func(tx kv.Tx) error { return readAhead(ctx, tx, bucket, h) }); err != nil {
+			hintsFailed.Inc()
+			return
+		}
+		hintsRead.Inc()
+	}()
+}
+
+// readAhead reads pairs of the hint, so that their pages get into the cache of the OS
+func readAhead(ctx context.Context, tx kv.Tx, bucket string, h *remoteapi.Hint) error {
+	c, err := tx.Cursor(bucket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if h.Keys != nil {
+		for _, key := range h.Keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if _, _, err := c.SeekExact(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	k, _, err := c.Seek(h.From)
+	for n := uint64(1); err == nil && k != nil && n < h.N; n++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		k, _, err = c.Next()
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package remotedbserver
+
+import (
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Limits are caps on resources one Tx stream can hold, so buggy or abusive clients can't pin database readers forever.
+// 0 - no limit.
+type Limits struct {
+	MaxCursors    int           // open cursors of one transaction
+	MaxTxLifetime time.Duration // of the Tx stream, the read transaction inside is renewed every remoteapi.MaxTxTTL anyway
+	IdleTimeout   time.Duration // the stream is closed if the client sends nothing for so long
+	// MaxPinLifetime limits how long the view stays pinned (see remoteapi.OpPinView): pinned transactions aren't
+	// renewed, so all streams at the view are closed then and the transaction shared by them is rolled back
+	MaxPinLifetime time.Duration
+}
+
+var DefaultLimits = Limits{MaxCursors: 1024, IdleTimeout: 5 * time.Minute, MaxPinLifetime: 10 * time.Minute}
+
+// limitError closes the stream because of the limit: the status tells the reason to humans, the trailer - to clients
+func limitError(stream remote.KV_TxServer, limit string, code codes.Code, format string, args ...interface{}) error {
+	stream.SetTrailer(metadata.Pairs(remoteapi.LimitTrailer, limit))
+	return status.Errorf(code, format, args...)
+}
+
+// receive reads messages of the stream in the background, so the handler can wait for them with timeouts.
+// Reading stops on the first error or when done is closed.
+func receive(stream remote.KV_TxServer, done <-chan struct{}) (<-chan *remote.Cursor, <-chan error) {
+	msgs, errs := make(chan *remote.Cursor), make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- in:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return msgs, errs
+}
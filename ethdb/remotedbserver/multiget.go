@@ -0,0 +1,39 @@
+package remotedbserver
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// MultiGetReplyLimit is the size of values after which the reply is sent, it's less than the default gRPC message limit
+const MultiGetReplyLimit = 2 * 1024 * 1024
+
+// multiGet reads the keys until the reply reaches MultiGetReplyLimit or ctx is done, at least one key is read
+func multiGet(ctx context.Context, tx kv.Tx, bucket string, encodedKeys []byte) ([]byte, error) {
+	keys, err := remoteapi.DecodeMultiGetKeys(encodedKeys)
+	if err != nil {
+		return nil, err
+	}
+	c, err := tx.Cursor(bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	var reply []byte
+	for _, key := range keys {
+		if len(reply) >= MultiGetReplyLimit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		k, v, err := c.SeekExact(key)
+		if err != nil {
+			return nil, err
+		}
+		reply = remoteapi.EncodeMultiGetValues(reply, v, k != nil)
+	}
+	return reply, nil
+}
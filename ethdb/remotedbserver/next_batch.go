@@ -0,0 +1,32 @@
+package remotedbserver
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+// nextBatch moves the cursor forward until n pairs are read, the reply reaches MultiGetReplyLimit, the end is reached
+// or ctx is done
+func nextBatch(ctx context.Context, c kv.Cursor, encodedN []byte) ([]byte, error) {
+	n, err := remoteapi.DecodeStat(encodedN)
+	if err != nil {
+		return nil, err
+	}
+	var reply []byte
+	for i := uint64(0); i < n && len(reply) < MultiGetReplyLimit; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		k, v, err := c.Next()
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			return remoteapi.EncodeBatchEnd(reply), nil
+		}
+		reply = remoteapi.EncodeBatchPair(reply, k, v)
+	}
+	return reply, nil
+}
@@ -4,60 +4,71 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces/types"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
-const MaxTxTTL = 30 * time.Second
-
-// KvServiceAPIVersion - use it to track changes in API
-// 1.1.0 - added pending transactions, add methods eth_getRawTransactionByHash, eth_retRawTransactionByBlockHashAndIndex, eth_retRawTransactionByBlockNumberAndIndex| Yes     |                                            |
-// 1.2.0 - Added separated services for mining and txpool methods
-// 2.0.0 - Rename all buckets
-var KvServiceAPIVersion = &types.VersionReply{Major: 3, Minor: 0, Patch: 0}
-
 type KvServer struct {
 	remote.UnimplementedKVServer // must be embedded to have forward compatible implementations.
 
-	kv kv.RwDB
+	kv     kv.RwDB
+	limits Limits
+	acl    *remoteapi.BucketACL // nil - all buckets are allowed
+
+	pinnedMu sync.Mutex
+	pinned   map[uint64][]*pinnedTx // by view, see remoteapi.OpPinView
+
+	hints chan struct{} // semaphore of HintWorkers, see remoteapi.OpHint
+
+	adminToken string // "" - admin ops are disabled, see WithAdminToken
 }
 
 func NewKvServer(kv kv.RwDB) *KvServer {
-	return &KvServer{kv: kv}
+	return &KvServer{kv: kv, limits: DefaultLimits, hints: make(chan struct{}, HintWorkers)}
+}
+
+// WithLimits replaces DefaultLimits of resources of Tx streams
+func (s *KvServer) WithLimits(limits Limits) *KvServer {
+	s.limits = limits
+	return s
 }
 
-// Version returns the service-side interface version number
-func (s *KvServer) Version(context.Context, *emptypb.Empty) (*types.VersionReply, error) {
+// Version returns the service-side interface version number, supported features are sent in FeaturesHeader
+func (s *KvServer) Version(ctx context.Context, _ *emptypb.Empty) (*types.VersionReply, error) {
+	remoteapi.SendFeatures(ctx, s.features())
 	dbSchemaVersion := &kv.DBSchemaVersion
-	if KvServiceAPIVersion.Major > dbSchemaVersion.Major {
-		return KvServiceAPIVersion, nil
+	if remoteapi.KvServiceAPIVersion.Major > dbSchemaVersion.Major {
+		return remoteapi.KvServiceAPIVersion, nil
 	}
-	if dbSchemaVersion.Major > KvServiceAPIVersion.Major {
+	if dbSchemaVersion.Major > remoteapi.KvServiceAPIVersion.Major {
 		return dbSchemaVersion, nil
 	}
-	if KvServiceAPIVersion.Minor > dbSchemaVersion.Minor {
-		return KvServiceAPIVersion, nil
+	if remoteapi.KvServiceAPIVersion.Minor > dbSchemaVersion.Minor {
+		return remoteapi.KvServiceAPIVersion, nil
 	}
-	if dbSchemaVersion.Minor > KvServiceAPIVersion.Minor {
+	if dbSchemaVersion.Minor > remoteapi.KvServiceAPIVersion.Minor {
 		return dbSchemaVersion, nil
 	}
 	return dbSchemaVersion, nil
 }
 
 func (s *KvServer) Tx(stream remote.KV_TxServer) error {
-	tx, errBegin := s.kv.BeginRo(stream.Context())
+	tx, view, shared, errBegin := s.beginTx(stream)
+	if status.Code(errBegin) == codes.FailedPrecondition {
+		return errBegin
+	}
 	if errBegin != nil {
 		return fmt.Errorf("server-side error: %w", errBegin)
 	}
-	rollback := func() {
-		tx.Rollback()
-	}
-	defer rollback()
 
 	var CursorID uint32
 	type CursorInfo struct {
@@ -67,23 +78,97 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 	}
 	cursors := map[uint32]*CursorInfo{}
 
-	txTicker := time.NewTicker(MaxTxTTL)
+	rollback := func() {
+		if shared == nil {
+			tx.Rollback()
+			return
+		}
+		shared.Lock()
+		for _, c := range cursors {
+			c.c.Close()
+		}
+		shared.Unlock()
+		s.release(shared)
+	}
+	defer rollback()
+	var locked *pinnedTx // the lock of the shared transaction, held while a message is handled
+	defer func() {
+		if locked != nil {
+			locked.Unlock()
+		}
+	}()
+
+	txTicker := time.NewTicker(remoteapi.MaxTxTTL)
 	defer txTicker.Stop()
+	renew := txTicker.C
+	if shared != nil { // pinned transactions aren't renewed
+		renew = nil
+	}
+
+	var lifetime, idle <-chan time.Time
+	if s.limits.MaxTxLifetime > 0 {
+		lifetimeTimer := time.NewTimer(s.limits.MaxTxLifetime)
+		defer lifetimeTimer.Stop()
+		lifetime = lifetimeTimer.C
+	}
+	var unpin <-chan time.Time // end of the lifetime of the pinned view
+	pinTimer := time.NewTimer(0)
+	defer pinTimer.Stop()
+	<-pinTimer.C
+	expire := func() {
+		if !shared.expires.IsZero() {
+			pinTimer.Reset(time.Until(shared.expires))
+			unpin = pinTimer.C
+		}
+	}
+	if shared != nil {
+		expire()
+	}
+	var idleTimer *time.Timer
+	if s.limits.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(s.limits.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	done := make(chan struct{})
+	defer close(done)
+	msgs, recvErrs := receive(stream, done)
 
 	// send all items to client, if k==nil - still send it to client and break loop
 	for {
-		in, recvErr := stream.Recv()
-		if recvErr != nil {
+		if locked != nil {
+			locked.Unlock()
+			locked = nil
+		}
+		var in *remote.Cursor
+		select {
+		case in = <-msgs:
+		case recvErr := <-recvErrs:
 			if recvErr == io.EOF { // termination
 				return nil
 			}
 			return fmt.Errorf("server-side error: %w", recvErr)
+		case <-lifetime:
+			return limitError(stream, remoteapi.LimitTxLifetime, codes.DeadlineExceeded, "transaction is open longer than %s", s.limits.MaxTxLifetime)
+		case <-idle:
+			return limitError(stream, remoteapi.LimitIdle, codes.DeadlineExceeded, "no requests to transaction for %s", s.limits.IdleTimeout)
+		case <-unpin:
+			return limitError(stream, remoteapi.LimitTxLifetime, codes.DeadlineExceeded, "view is pinned longer than %s", s.limits.MaxPinLifetime)
+		}
+		if idleTimer != nil {
+			if !idleTimer.Stop() {
+				<-idleTimer.C
+			}
+			idleTimer.Reset(s.limits.IdleTimeout)
+		}
+		if shared != nil {
+			shared.Lock()
+			locked = shared
 		}
 
-		//TODO: protect against client - which doesn't send any requests
 		select {
 		default:
-		case <-txTicker.C:
+		case <-renew:
 			for _, c := range cursors { // save positions of cursor, will restore after Tx reopening
 				k, v, err := c.c.Current()
 				if err != nil {
@@ -94,7 +179,7 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 			}
 
 			tx.Rollback()
-			tx, errBegin = s.kv.BeginRo(stream.Context())
+			tx, view, errBegin = s.beginAtView(stream.Context())
 			if errBegin != nil {
 				return fmt.Errorf("server-side error, BeginRo: %w", errBegin)
 			}
@@ -125,8 +210,15 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 			}
 		}
 
+		if in.BucketName != "" && !s.acl.Allowed(in.BucketName) {
+			return limitError(stream, remoteapi.LimitBucket, codes.PermissionDenied, "bucket %s is not allowed", in.BucketName)
+		}
+		if isAdminOp(in.Op) && !s.isAdmin(stream.Context()) {
+			return limitError(stream, remoteapi.LimitAdmin, codes.PermissionDenied, "op %s needs the admin token", in.Op)
+		}
+
 		var c kv.Cursor
-		if in.BucketName == "" {
+		if in.BucketName == "" && in.Op != remoteapi.OpPinView && in.Op != remoteapi.OpListBuckets { // ops of the transaction
 			cInfo, ok := cursors[in.Cursor]
 			if !ok {
 				return fmt.Errorf("server-side error: unknown Cursor=%d, Op=%s", in.Cursor, in.Op)
@@ -136,6 +228,13 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 
 		switch in.Op {
 		case remote.Op_OPEN:
+			if s.limits.MaxCursors > 0 && len(cursors) >= s.limits.MaxCursors {
+				return limitError(stream, remoteapi.LimitCursors, codes.ResourceExhausted, "more than %d cursors are open in transaction", s.limits.MaxCursors)
+			}
+			if _, ok := s.kv.AllBuckets()[in.BucketName]; !ok {
+				// tables created by admin ops are registered on restart, see ethdb.OpenAuxiliaryTables
+				return fmt.Errorf("server-side error: bucket %s is not open by the node", in.BucketName)
+			}
 			CursorID++
 			var err error
 			c, err = tx.Cursor(in.BucketName)
@@ -161,6 +260,103 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 				return fmt.Errorf("server-side error: %w", err)
 			}
 			continue
+		case remoteapi.OpMultiGet:
+			opCtx, cancelOp := opContext(stream.Context(), in)
+			values, err := multiGet(opCtx, tx, in.BucketName, in.K)
+			cancelOp()
+			if err != nil {
+				return opError(stream, in, fmt.Errorf("server-side error: %w", err))
+			}
+			if err := stream.Send(&remote.Pair{V: values}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpBucketSize:
+			size, err := tx.BucketSize(in.BucketName)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			if err := stream.Send(&remote.Pair{V: remoteapi.EncodeStat(size)}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpPinView:
+			if shared == nil && view != 0 {
+				renew = nil
+				shared = s.pin(tx, view) // other streams may use the transaction from now on
+				shared.Lock()
+				locked = shared
+				expire()
+			}
+			if err := stream.Send(&remote.Pair{V: remoteapi.EncodeStat(view)}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpReadSequence:
+			seq, err := tx.ReadSequence(in.BucketName)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			if err := stream.Send(&remote.Pair{V: remoteapi.EncodeStat(seq)}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpNextBatch:
+			opCtx, cancelOp := opContext(stream.Context(), in)
+			pairs, err := nextBatch(opCtx, c, in.K)
+			cancelOp()
+			if err != nil {
+				return opError(stream, in, fmt.Errorf("server-side error: %w", err))
+			}
+			if err := stream.Send(&remote.Pair{V: pairs}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpDomainGet, remoteapi.OpHistorySeek, remoteapi.OpIndexRange:
+			for _, bucket := range TemporalBuckets(in.BucketName) {
+				if !s.acl.Allowed(bucket) {
+					return limitError(stream, remoteapi.LimitBucket, codes.PermissionDenied, "bucket %s is not allowed", bucket)
+				}
+			}
+			v, err := temporalOp(tx, in)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			if err := stream.Send(&remote.Pair{V: v}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpHint:
+			hint, err := remoteapi.DecodeHint(in.K)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			s.hint(stream.Context(), in.BucketName, hint)
+			if err := stream.Send(&remote.Pair{}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpCreateBucket, remoteapi.OpDropBucket, remoteapi.OpClearBucket, remoteapi.OpListBuckets:
+			if in.Op != remoteapi.OpListBuckets && !isAuxiliary(in.BucketName) {
+				return limitError(stream, remoteapi.LimitBucket, codes.PermissionDenied, "bucket %s is a table of the schema", in.BucketName)
+			}
+			v, err := s.adminOp(stream.Context(), tx, in)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			if err := stream.Send(&remote.Pair{V: v}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
+		case remoteapi.OpCount, remoteapi.OpCountDuplicates:
+			v, err := handleStatOp(c, in.Op)
+			if err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			if err := stream.Send(&remote.Pair{V: v}); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
 		default:
 		}
 
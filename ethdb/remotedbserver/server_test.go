@@ -0,0 +1,733 @@
+package remotedbserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedb"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/torquem-ch/mdbx-go/mdbx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// recordedStream is a sequence of messages sent by a client of the given version and replies it expected.
+// Messages are kept in protobuf wire format, so the test also catches incompatible changes of the .proto files.
+type recordedStream struct {
+	Client   gointerfaces.Version `json:"client"`
+	Messages []struct {
+		Request string `json:"request"`
+		Reply   string `json:"reply"`
+	} `json:"messages"`
+}
+
+func readRecordedStream(t *testing.T, file string) recordedStream {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var s recordedStream
+	require.NoError(t, json.Unmarshal(data, &s))
+	return s
+}
+
+// seedCompatDB fills the database with the data the recorded streams were recorded against
+func seedCompatDB(t *testing.T) kv.RwDB {
+	db := memdb.NewTestDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := uint64(0); i < 10; i++ {
+			k := make([]byte, 8)
+			binary.BigEndian.PutUint64(k, i*2)
+			v := make([]byte, 32)
+			v[0] = byte(i)
+			if err := tx.Put(kv.HeaderCanonical, k, v); err != nil {
+				return err
+			}
+		}
+		for block := byte(1); block <= 3; block++ {
+			k := make([]byte, 8)
+			k[7] = block
+			for a := byte(1); a <= 3; a++ {
+				v := make([]byte, 21)
+				v[19] = a * 2
+				v[20] = block * a
+				if err := tx.Put(kv.AccountChangeSet, k, v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	return db
+}
+
+func startKvServer(t *testing.T, db kv.RwDB) *bufconn.Listener {
+	return startKvServerWithLimits(t, db, remotedbserver.DefaultLimits)
+}
+
+func startKvServerWithLimits(t *testing.T, db kv.RwDB, limits remotedbserver.Limits) *bufconn.Listener {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db).WithLimits(limits))
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Error("private RPC server fail", "err", err)
+		}
+	}()
+	t.Cleanup(server.Stop)
+	return listener
+}
+
+func dialKvServer(t *testing.T, listener *bufconn.Listener) *grpc.ClientConn {
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestKvTxCompatibility(t *testing.T) {
+	files, err := filepath.Glob("testdata/kv_tx_*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	client := remote.NewKVClient(dialKvServer(t, startKvServer(t, seedCompatDB(t))))
+	for _, file := range files {
+		recorded := readRecordedStream(t, file)
+		t.Run(recorded.Client.String(), func(t *testing.T) {
+			versionReply, err := client.Version(context.Background(), &emptypb.Empty{})
+			require.NoError(t, err)
+			// streams of older minor versions are replayed too: clients refuse such servers, but the server must
+			// still answer them the same
+			require.Equal(t, recorded.Client.Major, versionReply.Major)
+
+			stream, err := client.Tx(context.Background())
+			require.NoError(t, err)
+			for i, msg := range recorded.Messages {
+				req, expected := &remote.Cursor{}, &remote.Pair{}
+				require.NoError(t, proto.Unmarshal(decodeHex(t, msg.Request), req))
+				require.NoError(t, proto.Unmarshal(decodeHex(t, msg.Reply), expected))
+				require.NoError(t, stream.Send(req))
+				reply, err := stream.Recv()
+				require.NoError(t, err)
+				require.True(t, proto.Equal(expected, reply), "message %d (%s): expected %v, got %v", i, req.Op, expected, reply)
+			}
+			require.NoError(t, stream.CloseSend())
+		})
+	}
+}
+
+func TestKvFeaturesNegotiation(t *testing.T) {
+	defer func(f remoteapi.Features) { remoteapi.KvServiceFeatures = f }(remoteapi.KvServiceFeatures)
+	remoteapi.KvServiceFeatures = 0b101 | remoteapi.FeatureOpRange
+
+	listener := startKvServer(t, seedCompatDB(t))
+	db, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).Open("", "", "")
+	require.NoError(t, err)
+	defer db.Close()
+	require.True(t, db.EnsureVersionCompatibility())
+	require.Equal(t, 0b101|remoteapi.FeatureOpRange, db.Features())
+	require.True(t, db.Features().Has(0b100))
+	require.False(t, db.Features().Has(0b010))
+
+	// servers which don't know about features advertise none
+	require.Equal(t, remoteapi.Features(0), remoteapi.ReceiveFeatures(nil))
+	_, negotiated := remoteapi.NegotiateFeatures(remoteapi.KvServiceFeatures, nil)
+	require.False(t, negotiated)
+
+	// older clients get the common features, newer servers advertise only them
+	var header metadata.MD
+	ctx := remoteapi.WithFeatures(context.Background(), 0b110)
+	_, err = remote.NewKVClient(dialKvServer(t, listener)).Version(ctx, &emptypb.Empty{}, grpc.Header(&header))
+	require.NoError(t, err)
+	features, negotiated := remoteapi.NegotiateFeatures(0b110, header)
+	require.True(t, negotiated)
+	require.Equal(t, remoteapi.Features(0b100), features)
+
+	// features gate only optional ops, they don't make up for another major or minor version
+	for _, version := range []gointerfaces.Version{
+		{Major: remoteapi.KvServiceAPIVersion.Major + 1},
+		{Major: remoteapi.KvServiceAPIVersion.Major, Minor: remoteapi.KvServiceAPIVersion.Minor + 1},
+		{Major: 3, Minor: 0}, // can't decode packed senders
+	} {
+		other, err := remotedb.NewRemote(version, log.New()).InMem(listener).Open("", "", "")
+		require.NoError(t, err)
+		require.False(t, other.EnsureVersionCompatibility(), version.String())
+		other.Close()
+	}
+
+	_, err = db.BeginRw(context.Background())
+	require.ErrorIs(t, err, remotedb.ErrWriteTxNotSupported)
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestKvMultiGet(t *testing.T) {
+	db := seedCompatDB(t)
+	big := make([]byte, remotedbserver.MultiGetReplyLimit/2+1)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := byte(0); i < 3; i++ {
+			if err := tx.Put(kv.Code, []byte{i}, append(big[:len(big)-1:len(big)-1], i)); err != nil {
+				return err
+			}
+		}
+		return tx.Put(kv.Code, []byte{0xff}, []byte{1})
+	}))
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServer(t, db)).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	require.True(t, remoteDB.Features().Has(remoteapi.FeatureMultiGet))
+
+	require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		var keys [][]byte
+		for i := uint64(0); i < 21; i++ {
+			k := make([]byte, 8)
+			binary.BigEndian.PutUint64(k, i)
+			keys = append(keys, k)
+		}
+		values, err := tx.(ethdb.MultiGetter).GetMany(kv.HeaderCanonical, keys)
+		require.NoError(t, err)
+		require.Len(t, values, len(keys))
+		for i, k := range keys {
+			expected, err := tx.GetOne(kv.HeaderCanonical, k)
+			require.NoError(t, err)
+			require.Equal(t, expected, values[i], "key %x", k)
+		}
+		require.Nil(t, values[1])
+
+		// values don't fit into one reply
+		values, err = ethdb.GetMany(tx, kv.Code, [][]byte{{0}, {1}, {2}, {3}, {0xff}})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.Len(t, values[i], len(big))
+			require.Equal(t, byte(i), values[i][len(big)-1])
+		}
+		require.Nil(t, values[3])
+		expected, err := tx.GetOne(kv.Code, []byte{0xff})
+		require.NoError(t, err)
+		require.Equal(t, expected, values[4])
+		return nil
+	}))
+}
+
+func TestKvLimits(t *testing.T) {
+	db := seedCompatDB(t)
+	open := func(limits remotedbserver.Limits) kv.RoDB {
+		remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServerWithLimits(t, db, limits)).Open("", "", "")
+		require.NoError(t, err)
+		t.Cleanup(remoteDB.Close)
+		return remoteDB
+	}
+
+	remoteDB := open(remotedbserver.Limits{MaxCursors: 2})
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	c1, err := tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	c2, err := tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	c2.Close()
+	_, err = tx.Cursor(kv.HeaderCanonical) // closed cursors don't count
+	require.NoError(t, err)
+	_, err = tx.Cursor(kv.HeaderCanonical)
+	require.ErrorIs(t, err, remotedb.ErrTooManyCursors)
+	_, _, err = c1.First()
+	require.ErrorIs(t, err, remotedb.ErrTooManyCursors, "the stream is closed")
+	tx.Rollback()
+
+	remoteDB = open(remotedbserver.Limits{IdleTimeout: 100 * time.Millisecond})
+	tx, err = remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	c, err := tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ { // requests keep the transaction alive
+		_, _, err = c.First()
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	_, _, err = c.First()
+	require.ErrorIs(t, err, remotedb.ErrTxIdle)
+	tx.Rollback()
+
+	remoteDB = open(remotedbserver.Limits{MaxTxLifetime: 100 * time.Millisecond})
+	tx, err = remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	c, err = tx.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, _, err = c.Next()
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, err, remotedb.ErrTxLifetimeExceeded)
+
+	// pinned views are released after their lifetime, even if the transactions are used
+	remoteDB = open(remotedbserver.Limits{MaxPinLifetime: 100 * time.Millisecond})
+	require.True(t, remoteDB.(*remotedb.RemoteKV).EnsureVersionCompatibility())
+	pinned, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer pinned.Rollback()
+	view, err := pinned.(remotedb.ViewTx).ViewID()
+	require.NoError(t, err)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Put(kv.HeaderCanonical, []byte{0xff}, []byte{0xff})
+	}))
+	c, err = pinned.Cursor(kv.HeaderCanonical)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, _, err = c.Next()
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, err, remotedb.ErrTxLifetimeExceeded)
+	_, err = remoteDB.(*remotedb.RemoteKV).BeginRoAt(context.Background(), view)
+	require.ErrorIs(t, err, remotedb.ErrViewNotAvailable)
+}
+
+func TestKvStats(t *testing.T) {
+	db := seedCompatDB(t)
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServer(t, db)).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	require.True(t, remoteDB.Features().Has(remoteapi.FeatureStats))
+
+	localTx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer localTx.Rollback()
+	require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		expected, err := localTx.BucketSize(kv.HeaderCanonical)
+		require.NoError(t, err)
+		size, err := tx.BucketSize(kv.HeaderCanonical)
+		require.NoError(t, err)
+		require.Equal(t, expected, size)
+		require.NotZero(t, size)
+
+		c, err := tx.Cursor(kv.HeaderCanonical)
+		require.NoError(t, err)
+		defer c.Close()
+		count, err := c.Count()
+		require.NoError(t, err)
+		require.Equal(t, uint64(10), count)
+
+		dc, err := tx.CursorDupSort(kv.AccountChangeSet)
+		require.NoError(t, err)
+		defer dc.Close()
+		k, _, err := dc.First()
+		require.NoError(t, err)
+		count, err = dc.CountDuplicates()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), count)
+		// statistics don't move the cursor
+		k2, v, err := dc.NextDup()
+		require.NoError(t, err)
+		require.Equal(t, k, k2)
+		require.Equal(t, byte(4), v[19])
+		return nil
+	}))
+}
+
+func TestKvReadSequence(t *testing.T) {
+	db := seedCompatDB(t)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		_, err := tx.IncrementSequence(kv.EthTx, 5)
+		return err
+	}))
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServer(t, db)).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	require.True(t, remoteDB.Features().Has(remoteapi.FeatureSequence))
+
+	require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		seq, err := tx.ReadSequence(kv.EthTx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), seq)
+		seq, err = tx.ReadSequence(kv.HeaderCanonical)
+		require.NoError(t, err)
+		require.Zero(t, seq)
+		return nil
+	}))
+}
+
+// countingListener counts bytes sent by the server
+type countingListener struct {
+	net.Listener
+	written *int64
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn, l.written}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+func TestKvCompression(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	code := bytes.Repeat([]byte("erigon"), 100_000)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Put(kv.PlainContractCode, []byte("code"), code)
+	}))
+
+	_, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).WithCompression("lz4").Open("", "", "")
+	require.Error(t, err)
+
+	sent := map[string]int64{}
+	for _, compression := range []string{"", remoteapi.CompressionSnappy, remoteapi.CompressionZstd} {
+		listener := bufconn.Listen(1024 * 1024)
+		var written int64
+		server := grpc.NewServer()
+		remote.RegisterKVServer(server, remotedbserver.NewKvServer(db))
+		go server.Serve(countingListener{listener, &written}) //nolint:errcheck
+
+		remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).WithCompression(compression).Open("", "", "")
+		require.NoError(t, err)
+		require.True(t, remoteDB.EnsureVersionCompatibility())
+		before := atomic.LoadInt64(&written)
+		require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+			v, err := tx.GetOne(kv.PlainContractCode, []byte("code"))
+			require.NoError(t, err)
+			require.Equal(t, code, v)
+			return nil
+		}))
+		sent[compression] = atomic.LoadInt64(&written) - before
+		remoteDB.Close()
+		server.Stop()
+	}
+	require.Less(t, sent[remoteapi.CompressionSnappy], sent[""]/10)
+	require.Less(t, sent[remoteapi.CompressionZstd], sent[""]/10)
+}
+
+func TestKvClientInterceptors(t *testing.T) {
+	db := seedCompatDB(t)
+	listener := bufconn.Listen(1024 * 1024)
+	var authorized, unauthorized int32
+	checkAuth := func(ctx context.Context) {
+		if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("authorization")) == 1 && md.Get("authorization")[0] == "Bearer secret" {
+			atomic.AddInt32(&authorized, 1)
+		} else {
+			atomic.AddInt32(&unauthorized, 1)
+		}
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			checkAuth(ctx)
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			checkAuth(ss.Context())
+			return handler(srv, ss)
+		}),
+	)
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db))
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	var unaryCalls, streams []string
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).
+		WithMetadata("authorization", "Bearer secret").
+		WithUnaryInterceptors(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			unaryCalls = append(unaryCalls, method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}).
+		WithStreamInterceptors(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			streams = append(streams, method)
+			return streamer(ctx, desc, cc, method, opts...)
+		}).
+		Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		_, err := tx.GetOne(kv.HeaderCanonical, make([]byte, 8))
+		return err
+	}))
+	require.Equal(t, []string{"/remote.KV/Version"}, unaryCalls)
+	require.Equal(t, []string{"/remote.KV/Tx"}, streams)
+	require.Equal(t, int32(2), atomic.LoadInt32(&authorized))
+	require.Zero(t, atomic.LoadInt32(&unauthorized))
+}
+
+func TestKvMaxRecvMsgSize(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	code := bytes.Repeat([]byte{0x60}, 64*1024)
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Put(kv.PlainContractCode, []byte("code"), code)
+	}))
+	listener := startKvServer(t, db)
+	read := func(maxRecvMsgSize datasize.ByteSize) error {
+		remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).
+			DialTimeout(time.Second).MaxRecvMsgSize(maxRecvMsgSize).Open("", "", "")
+		require.NoError(t, err)
+		defer remoteDB.Close()
+		return remoteDB.View(context.Background(), func(tx kv.Tx) error {
+			_, err := tx.GetOne(kv.PlainContractCode, []byte("code"))
+			return err
+		})
+	}
+	require.NoError(t, read(datasize.MB))
+	err := read(32 * datasize.KB)
+	require.Error(t, err)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), err.Error())
+}
+
+func TestKvBucketACL(t *testing.T) {
+	db := seedCompatDB(t)
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	acl := remoteapi.NewBucketACL([]string{kv.HeaderCanonical, kv.AccountChangeSet}, []string{kv.AccountChangeSet})
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db).WithBucketACL(acl))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	require.True(t, acl.Allowed(kv.HeaderCanonical))
+	require.False(t, acl.Allowed(kv.AccountChangeSet), "denied buckets win")
+	require.False(t, acl.Allowed(kv.Receipts))
+	require.Nil(t, remoteapi.NewBucketACL(nil, nil))
+	require.True(t, remoteapi.NewBucketACL(nil, nil).Allowed(kv.Receipts))
+
+	opts := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener)
+	// the server closes transactions, which touch denied buckets
+	remoteDB, err := opts.Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	tx, err := remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	v, err := tx.GetOne(kv.HeaderCanonical, make([]byte, 8))
+	require.NoError(t, err)
+	require.Len(t, v, 32)
+	_, err = tx.GetOne(kv.Receipts, make([]byte, 8))
+	require.ErrorIs(t, err, remotedb.ErrBucketNotAllowed)
+
+	// the client refuses them itself, the transaction stays usable
+	remoteDB, err = opts.WithBucketACL(remoteapi.NewBucketACL(nil, []string{kv.Receipts})).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	tx, err = remoteDB.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Cursor(kv.Receipts)
+	require.ErrorIs(t, err, remotedb.ErrBucketNotAllowed)
+	_, err = tx.BucketSize(kv.Receipts)
+	require.ErrorIs(t, err, remotedb.ErrBucketNotAllowed)
+	v, err = tx.GetOne(kv.HeaderCanonical, make([]byte, 8))
+	require.NoError(t, err)
+	require.Len(t, v, 32)
+}
+
+func TestKvOpDeadline(t *testing.T) {
+	req := &remote.Cursor{Op: remoteapi.OpNextBatch}
+	_, ok := remoteapi.Deadline(req)
+	require.False(t, ok)
+	remoteapi.SetDeadline(req, time.Hour)
+	remoteapi.SetDeadline(req, time.Second) // replaces the previous one
+	b, err := proto.Marshal(req)
+	require.NoError(t, err)
+	decoded := &remote.Cursor{}
+	require.NoError(t, proto.Unmarshal(b, decoded))
+	timeout, ok := remoteapi.Deadline(decoded)
+	require.True(t, ok)
+	require.Equal(t, time.Second, timeout)
+
+	client := remote.NewKVClient(dialKvServer(t, startKvServer(t, seedCompatDB(t))))
+	stream, err := client.Tx(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&remote.Cursor{Op: remote.Op_OPEN, BucketName: kv.HeaderCanonical}))
+	pair, err := stream.Recv()
+	require.NoError(t, err)
+	batch := &remote.Cursor{Op: remoteapi.OpNextBatch, Cursor: pair.CursorID, K: remoteapi.EncodeStat(10)}
+	remoteapi.SetDeadline(batch, time.Minute)
+	require.NoError(t, stream.Send(batch))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// the deadline passed before the server started the op
+	remoteapi.SetDeadline(batch, -time.Second)
+	require.NoError(t, stream.Send(batch))
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+	require.Equal(t, []string{remoteapi.LimitDeadline}, stream.Trailer().Get(remoteapi.LimitTrailer))
+
+	// clients don't send ops of cancelled requests
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServer(t, seedCompatDB(t))).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := remoteDB.BeginRo(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	cancel()
+	_, err = tx.GetOne(kv.HeaderCanonical, make([]byte, 8))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKvHints(t *testing.T) {
+	hint, err := remoteapi.DecodeHint(remoteapi.EncodeScanHint([]byte{1}, 0))
+	require.NoError(t, err)
+	require.Equal(t, &remoteapi.Hint{From: []byte{1}, N: remoteapi.HintScanLimit}, hint)
+	hint, err = remoteapi.DecodeHint(remoteapi.EncodeKeysHint([][]byte{{1}, {}}))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{1}, {}}, hint.Keys)
+	_, err = remoteapi.DecodeHint([]byte{3})
+	require.Error(t, err)
+
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(startKvServer(t, seedCompatDB(t))).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteDB.Close()
+	require.True(t, remoteDB.EnsureVersionCompatibility())
+	require.True(t, remoteDB.Features().Has(remoteapi.FeatureHints))
+
+	read := metrics.GetOrCreateCounter(`kv_hints{result="read"}`)
+	before := read.Get()
+	require.NoError(t, remoteDB.View(context.Background(), func(tx kv.Tx) error {
+		hinter := tx.(remotedb.Hinter)
+		require.NoError(t, hinter.HintScan(kv.HeaderCanonical, remoteapi.EncodeStat(2), 5))
+		// hints don't move cursors
+		c, err := tx.Cursor(kv.HeaderCanonical)
+		require.NoError(t, err)
+		defer c.Close()
+		k, _, err := c.First()
+		require.NoError(t, err)
+		require.Equal(t, remoteapi.EncodeStat(0), k)
+		require.NoError(t, hinter.HintKeys(kv.HeaderCanonical, [][]byte{remoteapi.EncodeStat(7), remoteapi.EncodeStat(100)}))
+		k, _, err = c.Next()
+		require.NoError(t, err)
+		require.Equal(t, remoteapi.EncodeStat(2), k)
+		return nil
+	}))
+	require.Eventually(t, func() bool { return read.Get() >= before+2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKvAdmin(t *testing.T) {
+	db := seedCompatDB(t)
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	acl := remoteapi.NewBucketACL(nil, []string{"Denied"})
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(db).WithBucketACL(acl).WithAdminToken("secret"))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	ctx := context.Background()
+	opts := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener)
+	open := func(remoteDB *remotedb.RemoteKV, err error) *remotedb.RemoteKV {
+		require.NoError(t, err)
+		t.Cleanup(remoteDB.Close)
+		require.True(t, remoteDB.EnsureVersionCompatibility())
+		return remoteDB
+	}
+	count := func(bucket string) (n int) {
+		require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+			return tx.ForEach(bucket, nil, func(k, v []byte) error {
+				n++
+				return nil
+			})
+		}))
+		return n
+	}
+
+	// clients without the token don't negotiate admin ops, clients with a wrong one are refused
+	require.ErrorIs(t, open(opts.Open("", "", "")).Migrator(ctx).CreateBucket("Aux"), remotedb.ErrAdminNotSupported)
+	require.ErrorIs(t, open(opts.WithAdminToken("guess").Open("", "", "")).Migrator(ctx).CreateBucket("Aux"), remotedb.ErrAdminDenied)
+
+	remoteDB := open(opts.WithAdminToken("secret").Open("", "", ""))
+	require.True(t, remoteDB.Features().Has(remoteapi.FeatureAdmin))
+	migrator := remoteDB.Migrator(ctx)
+	require.NoError(t, migrator.CreateBucket("Aux"))
+	exists, err := migrator.ExistsBucket("Aux")
+	require.NoError(t, err)
+	require.True(t, exists)
+	// the table isn't open by the node until restart, it's written by mdbx directly
+	env := db.(interface{ Env() *mdbx.Env }).Env()
+	require.NoError(t, env.Update(func(txn *mdbx.Txn) error {
+		dbi, err := txn.OpenDBI("Aux", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte{1}, []byte{1}, 0)
+	}))
+	countAux := func() (n int) {
+		require.NoError(t, env.View(func(txn *mdbx.Txn) error {
+			dbi, err := txn.OpenDBI("Aux", 0, nil, nil)
+			if err != nil {
+				return err
+			}
+			st, err := txn.StatDBI(dbi)
+			n = int(st.Entries)
+			return err
+		}))
+		return n
+	}
+	require.Equal(t, 1, countAux())
+	require.NoError(t, migrator.ClearBucket("Aux"))
+	require.Equal(t, 0, countAux())
+	require.Equal(t, 10, count(kv.HeaderCanonical))
+	require.NoError(t, migrator.DropBucket("Aux"))
+	exists, err = migrator.ExistsBucket("Aux")
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.NoError(t, migrator.DropBucket("Aux"))
+
+	// clearing of missing tables doesn't create them
+	require.NoError(t, migrator.ClearBucket("Missing"))
+	exists, err = migrator.ExistsBucket("Missing")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	deprecated := kv.ChaindataDeprecatedTables[0]
+	require.NoError(t, migrator.CreateBucket(deprecated))
+	require.NoError(t, migrator.DropBucket(deprecated))
+	exists, err = migrator.ExistsBucket(deprecated)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// tables of the schema and denied ones can't be changed, denied ones aren't listed
+	require.ErrorIs(t, migrator.ClearBucket(kv.HeaderCanonical), remotedb.ErrBucketNotAllowed)
+	require.Equal(t, 10, count(kv.HeaderCanonical))
+	require.ErrorIs(t, migrator.CreateBucket("Denied"), remotedb.ErrBucketNotAllowed)
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error { return tx.CreateBucket("Denied") }))
+	names, err := migrator.ListBuckets()
+	require.NoError(t, err)
+	require.Contains(t, names, kv.HeaderCanonical)
+	require.NotContains(t, names, "Denied")
+}
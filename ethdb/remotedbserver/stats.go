@@ -0,0 +1,30 @@
+package remotedbserver
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+)
+
+func handleStatOp(c kv.Cursor, op remote.Op) ([]byte, error) {
+	var n uint64
+	var err error
+	switch op {
+	case remoteapi.OpCount:
+		n, err = c.Count()
+	case remoteapi.OpCountDuplicates:
+		dc, ok := c.(kv.CursorDupSort)
+		if !ok {
+			return nil, fmt.Errorf("%s of not DupSort cursor", op)
+		}
+		n, err = dc.CountDuplicates()
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", op)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return remoteapi.EncodeStat(n), nil
+}
@@ -0,0 +1,78 @@
+package remotedbserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common/changeset"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+)
+
+// TemporalBuckets are the buckets read by the ops of the history of the table, all of them must be allowed by remoteapi.BucketACL
+func TemporalBuckets(table string) []string {
+	return []string{table, changeset.Mapper[table].IndexBucket, kv.PlainState, kv.PlainContractCode}
+}
+
+func temporalOp(tx kv.Tx, in *remote.Cursor) ([]byte, error) {
+	if in.BucketName != kv.AccountChangeSet && in.BucketName != kv.StorageChangeSet {
+		return nil, fmt.Errorf("%s of not changeset table %s", in.Op, in.BucketName)
+	}
+	ttx := temporal.New(tx)
+	switch in.Op {
+	case remoteapi.OpDomainGet:
+		block, err := remoteapi.DecodeStat(in.V)
+		if err != nil {
+			return nil, err
+		}
+		v, err := ttx.GetAsOf(in.BucketName, in.K, block)
+		if err != nil {
+			return nil, err
+		}
+		return remoteapi.EncodeMultiGetValues(nil, v, v != nil), nil
+	case remoteapi.OpHistorySeek:
+		block, err := remoteapi.DecodeStat(in.V)
+		if err != nil {
+			return nil, err
+		}
+		v, err := ttx.HistorySeek(in.BucketName, in.K, block)
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return remoteapi.EncodeMultiGetValues(nil, nil, false), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return remoteapi.EncodeMultiGetValues(nil, v, true), nil
+	case remoteapi.OpIndexRange:
+		if len(in.V) != 16 {
+			return nil, fmt.Errorf("invalid range of %d bytes", len(in.V))
+		}
+		from, _ := remoteapi.DecodeStat(in.V[:8])
+		to, _ := remoteapi.DecodeStat(in.V[8:])
+		blocks, err := ttx.IndexRange(in.BucketName, in.K, from, to)
+		if err != nil {
+			return nil, err
+		}
+		// cut the range at a block, before which the bitmap fits into the reply
+		for blocks.GetSerializedSizeInBytes() > MultiGetReplyLimit {
+			cut, err := blocks.Select(blocks.GetCardinality() / 2)
+			if err != nil {
+				return nil, err
+			}
+			blocks.RemoveRange(cut, math.MaxUint64)
+			to = cut
+		}
+		buf := bytes.NewBuffer(remoteapi.EncodeStat(to))
+		if _, err := blocks.WriteTo(buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", in.Op)
+	}
+}
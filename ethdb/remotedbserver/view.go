@@ -0,0 +1,149 @@
+package remotedbserver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/torquem-ch/mdbx-go/mdbx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// pinnedTx is the read transaction of the pinned view, shared by Tx streams at that view. mdbx transactions
+// can't be used concurrently, so the streams handle their messages under the lock. Every pinned stream, which
+// has own transaction, registers it, so the view stays available while any of them is alive.
+type pinnedTx struct {
+	sync.Mutex
+	tx   kv.Tx
+	view uint64
+	refs int
+	// expires is the end of Limits.MaxPinLifetime, zero - never. Streams at the view are closed then, new ones
+	// don't attach to it anymore
+	expires time.Time
+}
+
+// pin registers the transaction of the stream as a transaction of its view
+func (s *KvServer) pin(tx kv.Tx, view uint64) *pinnedTx {
+	s.pinnedMu.Lock()
+	defer s.pinnedMu.Unlock()
+	if s.pinned == nil {
+		s.pinned = map[uint64][]*pinnedTx{}
+	}
+	p := &pinnedTx{tx: tx, view: view, refs: 1}
+	if s.limits.MaxPinLifetime > 0 {
+		p.expires = time.Now().Add(s.limits.MaxPinLifetime)
+	}
+	s.pinned[view] = append(s.pinned[view], p)
+	return p
+}
+
+// attach returns the least shared transaction of the pinned view, nil if the view is not pinned
+func (s *KvServer) attach(view uint64) *pinnedTx {
+	s.pinnedMu.Lock()
+	defer s.pinnedMu.Unlock()
+	var least *pinnedTx
+	now := time.Now()
+	for _, p := range s.pinned[view] {
+		if !p.expires.IsZero() && !now.Before(p.expires) {
+			continue
+		}
+		if least == nil || p.refs < least.refs {
+			least = p
+		}
+	}
+	if least != nil {
+		least.refs++
+	}
+	return least
+}
+
+// release rolls back the transaction of the pinned view after the last stream is done with it
+func (s *KvServer) release(p *pinnedTx) {
+	s.pinnedMu.Lock()
+	defer s.pinnedMu.Unlock()
+	if p.refs--; p.refs > 0 {
+		return
+	}
+	pinned := s.pinned[p.view]
+	for i := range pinned {
+		if pinned[i] == p {
+			pinned = append(pinned[:i], pinned[i+1:]...)
+			break
+		}
+	}
+	if len(pinned) == 0 {
+		delete(s.pinned, p.view)
+	} else {
+		s.pinned[p.view] = pinned
+	}
+	p.tx.Rollback()
+}
+
+// beginAtView begins the read transaction and returns its view, 0 if it's unknown: the database (under the wrappers,
+// see ethdb.UnwrapDB) is not mdbx or it's changed too often to catch the moment between commits.
+func (s *KvServer) beginAtView(ctx context.Context) (kv.Tx, uint64, error) {
+	db, ok := ethdb.UnwrapDB(s.kv).(interface{ Env() *mdbx.Env })
+	if !ok {
+		tx, err := s.kv.BeginRo(ctx)
+		return tx, 0, err
+	}
+	for i := 0; i < 3; i++ {
+		before, err := db.Env().Info(nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		tx, err := s.kv.BeginRo(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		after, err := db.Env().Info(nil)
+		if err != nil {
+			tx.Rollback()
+			return nil, 0, err
+		}
+		if before.LastTxnID == after.LastTxnID { // nothing was committed in between, the transaction sees that view
+			return tx, uint64(after.LastTxnID), nil
+		}
+		tx.Rollback()
+	}
+	tx, err := s.kv.BeginRo(ctx)
+	return tx, 0, err
+}
+
+// beginTx begins the transaction of the Tx stream at the view asked by the client (if any) and tells the view to it.
+// Streams at the asked view are pinned (shared is not nil): they have own transaction, if the database didn't change
+// since, or share the transaction of another pinned stream.
+func (s *KvServer) beginTx(stream remote.KV_TxServer) (tx kv.Tx, view uint64, shared *pinnedTx, err error) {
+	tx, view, err = s.beginAtView(stream.Context())
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if asked := md.Get(remoteapi.ViewHeader); len(asked) > 0 {
+		if asked[0] == strconv.FormatUint(view, 10) {
+			shared = s.pin(tx, view)
+		} else {
+			tx.Rollback()
+			askedView, _ := strconv.ParseUint(asked[0], 10, 64)
+			if shared = s.attach(askedView); shared == nil {
+				return nil, 0, nil, status.Errorf(codes.FailedPrecondition, "view %s is not available, database changed since", asked[0])
+			}
+			tx, view = shared.tx, askedView
+		}
+	}
+	// sent right away (empty if the view is unknown), so the client knows the view before it reads anything.
+	// fails only when called outside of gRPC server (f.e. directly in tests)
+	header := metadata.MD{}
+	if view != 0 {
+		header = metadata.Pairs(remoteapi.ViewHeader, strconv.FormatUint(view, 10))
+	}
+	_ = stream.SendHeader(header)
+	return tx, view, shared, nil
+}
@@ -0,0 +1,263 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+)
+
+// WrapDB returns database which records writes to the given tables of every committed write transaction into the Log
+func WrapDB(db kv.RwDB, log *Log, tables []string) kv.RwDB {
+	recorded := make(map[string]struct{}, len(tables))
+	for _, t := range tables {
+		recorded[t] = struct{}{}
+	}
+	return &recordingDB{RwDB: db, log: log, tables: recorded}
+}
+
+type recordingDB struct {
+	kv.RwDB
+	log    *Log
+	tables map[string]struct{}
+}
+
+func (db *recordingDB) UnwrapDB() kv.RoDB { return db.RwDB }
+
+func (db *recordingDB) BeginRw(ctx context.Context) (kv.RwTx, error) {
+	tx, err := db.RwDB.BeginRw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTx{RwTx: tx, db: db}, nil
+}
+
+func (db *recordingDB) Update(ctx context.Context, f func(tx kv.RwTx) error) error {
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type recordingTx struct {
+	kv.RwTx
+	db       *recordingDB
+	records  []Record
+	size     uint64
+	overflow bool // more than Log can keep was written, records are dropped
+}
+
+func (tx *recordingTx) UnwrapTx() kv.Tx { return tx.RwTx }
+
+func (tx *recordingTx) record(op Op, table string, k, v []byte) {
+	if _, ok := tx.db.tables[table]; !ok || tx.overflow {
+		return
+	}
+	r := Record{Op: op, Table: table, K: common.CopyBytes(k), V: common.CopyBytes(v)}
+	tx.size += uint64(r.size())
+	if tx.size > tx.db.log.limit {
+		tx.overflow, tx.records = true, nil
+		return
+	}
+	tx.records = append(tx.records, r)
+}
+
+func (tx *recordingTx) Commit() error {
+	tx.db.log.commitLock.Lock()
+	defer tx.db.log.commitLock.Unlock()
+	if err := tx.RwTx.Commit(); err != nil {
+		return err
+	}
+	switch {
+	case tx.overflow:
+		tx.db.log.truncate()
+	case len(tx.records) > 0:
+		tx.db.log.append(tx.records, tx.size)
+	}
+	tx.records = nil
+	return nil
+}
+
+func (tx *recordingTx) Put(table string, k, v []byte) error {
+	if err := tx.RwTx.Put(table, k, v); err != nil {
+		return err
+	}
+	tx.record(OpPut, table, k, v)
+	return nil
+}
+
+func (tx *recordingTx) Delete(table string, k, v []byte) error {
+	if err := tx.RwTx.Delete(table, k, v); err != nil {
+		return err
+	}
+	tx.record(OpDelete, table, k, v)
+	return nil
+}
+
+func (tx *recordingTx) Append(table string, k, v []byte) error {
+	if err := tx.RwTx.Append(table, k, v); err != nil {
+		return err
+	}
+	tx.record(OpPut, table, k, v)
+	return nil
+}
+
+func (tx *recordingTx) AppendDup(table string, k, v []byte) error {
+	if err := tx.RwTx.AppendDup(table, k, v); err != nil {
+		return err
+	}
+	tx.record(OpPut, table, k, v)
+	return nil
+}
+
+// IncrementSequence is recorded as Put of the new value into kv.Sequence, so replaying it is idempotent
+func (tx *recordingTx) IncrementSequence(table string, amount uint64) (uint64, error) {
+	current, err := tx.RwTx.IncrementSequence(table, amount)
+	if err != nil {
+		return 0, err
+	}
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], current+amount)
+	tx.record(OpPut, kv.Sequence, []byte(table), v[:])
+	return current, nil
+}
+
+func (tx *recordingTx) ClearBucket(table string) error {
+	if err := tx.RwTx.ClearBucket(table); err != nil {
+		return err
+	}
+	tx.record(OpClear, table, nil, nil)
+	return nil
+}
+
+func (tx *recordingTx) DropBucket(table string) error {
+	if err := tx.RwTx.DropBucket(table); err != nil {
+		return err
+	}
+	tx.record(OpClear, table, nil, nil)
+	return nil
+}
+
+// Cursor of a write transaction is writable (some code relies on that), so it's recorded too
+func (tx *recordingTx) Cursor(table string) (kv.Cursor, error) {
+	return tx.RwCursor(table)
+}
+
+func (tx *recordingTx) CursorDupSort(table string) (kv.CursorDupSort, error) {
+	return tx.RwCursorDupSort(table)
+}
+
+func (tx *recordingTx) RwCursor(table string) (kv.RwCursor, error) {
+	c, err := tx.RwTx.RwCursor(table)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tx.db.tables[table]; !ok {
+		return c, nil
+	}
+	// cursors of DupSort tables are type-asserted to kv.CursorDupSort by callers
+	if dc, ok := c.(kv.RwCursorDupSort); ok {
+		return &recordingCursorDupSort{recordingCursor: recordingCursor{RwCursor: dc, tx: tx, table: table}, c: dc}, nil
+	}
+	return &recordingCursor{RwCursor: c, tx: tx, table: table}, nil
+}
+
+func (tx *recordingTx) RwCursorDupSort(table string) (kv.RwCursorDupSort, error) {
+	c, err := tx.RwTx.RwCursorDupSort(table)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tx.db.tables[table]; !ok {
+		return c, nil
+	}
+	return &recordingCursorDupSort{recordingCursor: recordingCursor{RwCursor: c, tx: tx, table: table}, c: c}, nil
+}
+
+type recordingCursor struct {
+	kv.RwCursor
+	tx    *recordingTx
+	table string
+}
+
+func (c *recordingCursor) Put(k, v []byte) error {
+	if err := c.RwCursor.Put(k, v); err != nil {
+		return err
+	}
+	c.tx.record(OpPut, c.table, k, v)
+	return nil
+}
+
+func (c *recordingCursor) Append(k, v []byte) error {
+	if err := c.RwCursor.Append(k, v); err != nil {
+		return err
+	}
+	c.tx.record(OpPut, c.table, k, v)
+	return nil
+}
+
+func (c *recordingCursor) Delete(k, v []byte) error {
+	if err := c.RwCursor.Delete(k, v); err != nil {
+		return err
+	}
+	c.tx.record(OpDelete, c.table, k, v)
+	return nil
+}
+
+func (c *recordingCursor) DeleteCurrent() error {
+	k, v, err := c.RwCursor.Current()
+	if err != nil {
+		return err
+	}
+	k, v = common.CopyBytes(k), common.CopyBytes(v)
+	if err = c.RwCursor.DeleteCurrent(); err != nil {
+		return err
+	}
+	c.tx.record(OpDelete, c.table, k, v)
+	return nil
+}
+
+type recordingCursorDupSort struct {
+	recordingCursor
+	c kv.RwCursorDupSort
+}
+
+func (c *recordingCursorDupSort) SeekBothExact(key, value []byte) ([]byte, []byte, error) {
+	return c.c.SeekBothExact(key, value)
+}
+
+func (c *recordingCursorDupSort) SeekBothRange(key, value []byte) ([]byte, error) {
+	return c.c.SeekBothRange(key, value)
+}
+
+func (c *recordingCursorDupSort) FirstDup() ([]byte, error)          { return c.c.FirstDup() }
+func (c *recordingCursorDupSort) NextDup() ([]byte, []byte, error)   { return c.c.NextDup() }
+func (c *recordingCursorDupSort) NextNoDup() ([]byte, []byte, error) { return c.c.NextNoDup() }
+func (c *recordingCursorDupSort) LastDup() ([]byte, error)           { return c.c.LastDup() }
+func (c *recordingCursorDupSort) CountDuplicates() (uint64, error)   { return c.c.CountDuplicates() }
+
+func (c *recordingCursorDupSort) AppendDup(k, v []byte) error {
+	if err := c.c.AppendDup(k, v); err != nil {
+		return err
+	}
+	c.tx.record(OpPut, c.table, k, v)
+	return nil
+}
+
+func (c *recordingCursorDupSort) DeleteCurrentDuplicates() error {
+	k, _, err := c.c.Current()
+	if err != nil {
+		return err
+	}
+	k = common.CopyBytes(k)
+	if err = c.c.DeleteCurrentDuplicates(); err != nil {
+		return err
+	}
+	c.tx.record(OpDeleteDuplicates, c.table, k, nil)
+	return nil
+}
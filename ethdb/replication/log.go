@@ -0,0 +1,89 @@
+package replication
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// ErrTruncated is returned to readers of batches which are no longer in the Log,
+// such readers (replicas) have to copy the tables again
+var ErrTruncated = errors.New("replication log is truncated")
+
+// Log keeps recent committed batches in memory, up to the size limit. A write transaction bigger than
+// the limit (f.e. initial sync) truncates the Log entirely: replicas are meant to follow the chain tip.
+type Log struct {
+	epoch uint64
+	limit uint64
+
+	commitLock sync.Mutex // commits of recorded transactions and appending their batches happen in the same order
+
+	lock    sync.Mutex
+	batches []*Batch
+	sizes   []uint64
+	size    uint64
+	first   uint64 // seq of batches[0], or last+1 if there are no batches
+	last    uint64
+	notify  chan struct{} // closed and replaced on every append
+}
+
+func NewLog(limit datasize.ByteSize) *Log {
+	return &Log{
+		epoch:  uint64(time.Now().UnixNano()),
+		limit:  limit.Bytes(),
+		first:  1,
+		notify: make(chan struct{}),
+	}
+}
+
+func (l *Log) Epoch() uint64 { return l.epoch }
+
+// Last returns seq of the last appended batch, 0 if nothing was appended yet
+func (l *Log) Last() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.last
+}
+
+func (l *Log) append(records []Record, size uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.last++
+	l.batches = append(l.batches, &Batch{Epoch: l.epoch, Seq: l.last, Records: records})
+	l.sizes = append(l.sizes, size)
+	l.size += size
+	for l.size > l.limit && len(l.batches) > 0 {
+		l.size -= l.sizes[0]
+		l.batches[0], l.batches, l.sizes = nil, l.batches[1:], l.sizes[1:]
+		l.first++
+	}
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// truncate drops all batches and skips a seq, so every reader gets ErrTruncated
+func (l *Log) truncate() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.last++
+	l.batches, l.sizes, l.size = nil, nil, 0
+	l.first = l.last + 1
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// Read returns batches appended after the given seq, or a channel which is closed when there are new batches
+func (l *Log) Read(after uint64) ([]*Batch, <-chan struct{}, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if after+1 < l.first {
+		return nil, nil, ErrTruncated
+	}
+	if after >= l.last {
+		return nil, l.notify, nil
+	}
+	from := after + 1 - l.first
+	return append([]*Batch(nil), l.batches[from:]...), nil, nil
+}
@@ -0,0 +1,310 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var progressKey = []byte("progress")
+
+const (
+	// bootstrapCommitSize - amount of data copied in one write transaction of the replica during bootstrap
+	bootstrapCommitSize = 512 * datasize.MB
+	retryInterval       = 5 * time.Second
+	// followBufferSize - batches received ahead of applying them, when the replica follows the Log
+	followBufferSize = 64
+)
+
+// Replica maintains local copy of replicated tables: copies them from the source once, and then follows the Log.
+// Readers of DB never see a partial copy: they are served by the source until the copy is complete.
+type Replica struct {
+	db     kv.RwDB
+	source kv.RoDB                  // remote KV of Erigon, used to copy tables
+	conn   grpc.ClientConnInterface // private API connection of the same Erigon
+
+	mu      sync.RWMutex
+	serving bool // the replica has the complete copy, readers are served by it and not by the source
+
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+func NewReplica(db kv.RwDB, source kv.RoDB, conn grpc.ClientConnInterface) *Replica {
+	return &Replica{db: db, source: source, conn: conn, ready: make(chan struct{})}
+}
+
+// Ready is closed when the replica is bootstrapped and follows the Log for the first time
+func (r *Replica) Ready() <-chan struct{} { return r.ready }
+
+// DB returns the database for readers: the replica when it has the complete copy of the tables, the source while
+// they are copied (for the first time, or again after the replica fell out of the Log)
+func (r *Replica) DB() kv.RoDB { return &replicaDB{r: r} }
+
+func (r *Replica) setServing(serving bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serving = serving
+}
+
+type replicaDB struct {
+	r *Replica
+}
+
+// BeginRo takes the snapshot under the lock: transactions begun before the replica starts the copy don't see it,
+// transactions begun after it are served by the source
+func (db *replicaDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	db.r.mu.RLock()
+	defer db.r.mu.RUnlock()
+	if db.r.serving {
+		return db.r.db.BeginRo(ctx)
+	}
+	return db.r.source.BeginRo(ctx)
+}
+
+func (db *replicaDB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+func (db *replicaDB) AllBuckets() kv.TableCfg { return db.r.db.AllBuckets() }
+
+func (db *replicaDB) Close() { db.r.db.Close() }
+
+// Progress returns position of the replica in the Log, zeros if it was never bootstrapped
+func (r *Replica) Progress(ctx context.Context) (epoch, seq uint64, err error) {
+	err = r.db.View(ctx, func(tx kv.Tx) error {
+		v, err := tx.GetOne(ethdb.ReplicationProgress, progressKey)
+		if err != nil {
+			return err
+		}
+		if len(v) == 16 {
+			epoch, seq = binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(v[8:])
+		}
+		return nil
+	})
+	return epoch, seq, err
+}
+
+func writeProgress(tx kv.RwTx, epoch, seq uint64) error {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v, epoch)
+	binary.BigEndian.PutUint64(v[8:], seq)
+	return tx.Put(ethdb.ReplicationProgress, progressKey, v)
+}
+
+// Run keeps the replica up to date until the context is cancelled, bootstrapping it again when it falls out of the Log
+func (r *Replica) Run(ctx context.Context) {
+	for {
+		err := r.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.FailedPrecondition {
+			log.Warn("[replica] fell out of replication log, copying tables again", "reason", err)
+			r.setServing(false)
+			if err = r.db.Update(ctx, func(tx kv.RwTx) error { return writeProgress(tx, 0, 0) }); err == nil {
+				continue
+			}
+		}
+		log.Warn("[replica] replication stream failed, retrying", "err", err, "in", retryInterval)
+		select {
+		case <-time.After(retryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replica) follow(ctx context.Context) error {
+	epoch, seq, err := r.Progress(ctx)
+	if err != nil {
+		return err
+	}
+	// zero epoch - the copy was never completed, or it's being replaced
+	r.setServing(epoch != 0)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := openStream(ctx, r.conn, epoch, seq)
+	if err != nil {
+		return err
+	}
+	position, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	batches := newStreamBuffer(stream)
+	defer batches.close()
+	if epoch == 0 {
+		// copy happens after the stream is positioned, batches committed meanwhile are buffered and applied on top
+		// of the copy
+		if err = r.bootstrap(ctx, position.Epoch, position.Seq); err != nil {
+			return err
+		}
+		r.setServing(true)
+	}
+	batches.setLimit(followBufferSize)
+	epoch, seq = position.Epoch, position.Seq
+	log.Info("[replica] following replication log", "epoch", epoch, "seq", seq)
+	r.readyOnce.Do(func() { close(r.ready) })
+	for {
+		b, err := batches.next()
+		if err != nil {
+			return err
+		}
+		if b.Epoch != epoch || b.Seq != seq+1 {
+			return fmt.Errorf("unexpected batch %d:%d after %d:%d", b.Epoch, b.Seq, epoch, seq)
+		}
+		if err = r.db.Update(ctx, func(tx kv.RwTx) error {
+			if err := Apply(tx, b); err != nil {
+				return err
+			}
+			return writeProgress(tx, b.Epoch, b.Seq)
+		}); err != nil {
+			return err
+		}
+		seq = b.Seq
+	}
+}
+
+// bootstrap replaces replicated tables of the replica with the copy of the source
+func (r *Replica) bootstrap(ctx context.Context, epoch, seq uint64) error {
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+	srcTx, err := r.source.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcTx.Rollback()
+	tx, err := r.db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { tx.Rollback() }()
+
+	var size datasize.ByteSize
+	for _, table := range Tables {
+		log.Info("[replica] copying", "table", table)
+		if err = tx.ClearBucket(table); err != nil {
+			return err
+		}
+		if err = srcTx.ForEach(table, nil, func(k, v []byte) error {
+			if err := tx.Put(table, k, v); err != nil {
+				return err
+			}
+			size += datasize.ByteSize(len(k) + len(v))
+			if size < bootstrapCommitSize {
+				return nil
+			}
+			size = 0
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			tx, err = r.db.BeginRw(ctx)
+			if err != nil {
+				return err
+			}
+			select {
+			case <-logEvery.C:
+				log.Info("[replica] copying", "table", table, "key", fmt.Sprintf("%x", k))
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("copying %s: %w", table, err)
+		}
+	}
+	if err = writeProgress(tx, epoch, seq); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// streamBuffer receives batches of the stream in the background. While the replica copies tables, batches are buffered
+// without a limit: the server keeps reading its Log, so the replica doesn't fall out of it however long the copy takes.
+type streamBuffer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	batches []*Batch
+	limit   int   // batches buffered ahead, 0 - no limit
+	err     error // of the stream, returned after all buffered batches
+	closed  bool
+}
+
+func newStreamBuffer(stream *streamClient) *streamBuffer {
+	b := &streamBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	go b.receive(stream)
+	return b
+}
+
+func (b *streamBuffer) receive(stream *streamClient) {
+	for {
+		batch, err := stream.Recv()
+		b.mu.Lock()
+		for err == nil && b.limit > 0 && len(b.batches) >= b.limit && !b.closed {
+			b.cond.Wait()
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		if err != nil {
+			b.err = err
+		} else {
+			b.batches = append(b.batches, batch)
+		}
+		b.cond.Broadcast()
+		b.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *streamBuffer) setLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+}
+
+// next returns the next batch, waiting for it
+func (b *streamBuffer) next() (*Batch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.batches) == 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	if len(b.batches) == 0 {
+		return nil, b.err
+	}
+	batch := b.batches[0]
+	b.batches[0], b.batches = nil, b.batches[1:]
+	b.cond.Broadcast()
+	return batch, nil
+}
+
+// close stops buffering, the stream must be cancelled too to stop receiving
+func (b *streamBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.batches = nil
+	b.cond.Broadcast()
+}
@@ -0,0 +1,277 @@
+package replication_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/remoteapi"
+	"github.com/ledgerwatch/erigon/ethdb/remotedb"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/ethdb/replication"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestBatchEncoding(t *testing.T) {
+	b := &replication.Batch{Epoch: 7, Seq: 42, Records: []replication.Record{
+		{Op: replication.OpPut, Table: kv.Headers, K: []byte{1}, V: []byte{2, 3}},
+		{Op: replication.OpDelete, Table: kv.PlainState, K: []byte{4}},
+		{Op: replication.OpClear, Table: kv.Log},
+	}}
+	decoded, err := replication.DecodeBatch(replication.EncodeBatch(b))
+	require.NoError(t, err)
+	require.Equal(t, b.Epoch, decoded.Epoch)
+	require.Equal(t, b.Seq, decoded.Seq)
+	require.Len(t, decoded.Records, 3)
+	require.Equal(t, b.Records[0], decoded.Records[0])
+	require.Equal(t, []byte{4}, decoded.Records[1].K)
+	require.Empty(t, decoded.Records[1].V)
+	require.Equal(t, kv.Log, decoded.Records[2].Table)
+
+	_, err = replication.DecodeBatch(replication.EncodeBatch(b)[:20])
+	require.Error(t, err)
+}
+
+func TestTables(t *testing.T) {
+	// tables of Erigon are read by RPC, except the progress of the replica itself
+	for _, table := range ethdb.ErigonTables {
+		if table != ethdb.ReplicationProgress {
+			require.Contains(t, replication.Tables, table)
+		}
+	}
+	require.NotContains(t, replication.Tables, ethdb.ReplicationProgress)
+}
+
+func TestLogTruncation(t *testing.T) {
+	l := replication.NewLog(datasize.KB)
+	db := replication.WrapDB(ethdb.NewTestDB(t), l, replication.Tables)
+	put := func(size int) {
+		require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+			return tx.Put(kv.Headers, []byte{byte(size)}, make([]byte, size))
+		}))
+	}
+	put(100)
+	put(200)
+	batches, _, err := l.Read(0)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	require.Equal(t, uint64(2), batches[1].Seq)
+
+	// not replicated tables are not recorded
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Put(kv.HashedAccounts, []byte{1}, []byte{1})
+	}))
+	require.Equal(t, uint64(2), l.Last())
+
+	// old batches are evicted
+	put(800)
+	_, _, err = l.Read(0)
+	require.ErrorIs(t, err, replication.ErrTruncated)
+	batches, _, err = l.Read(1)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+
+	// transaction bigger than the log truncates it
+	put(2000)
+	_, _, err = l.Read(3)
+	require.ErrorIs(t, err, replication.ErrTruncated)
+	_, wait, err := l.Read(4)
+	require.NoError(t, err)
+	require.NotNil(t, wait)
+}
+
+func TestReplica(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := replication.NewLog(datasize.MB)
+	source := replication.WrapDB(ethdb.NewTestDB(t), l, replication.Tables)
+
+	// written before the replica starts, so it's copied
+	require.NoError(t, source.Update(ctx, func(tx kv.RwTx) error {
+		if err := tx.Put(kv.Headers, []byte{1}, []byte{1}); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.HeaderCanonical, []byte{1}, []byte{1}); err != nil {
+			return err
+		}
+		_, err := tx.IncrementSequence(kv.EthTx, 10)
+		return err
+	}))
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(source))
+	replication.Register(server, replication.NewServer(l))
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+	remoteKv, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteKv.Close()
+
+	replicaDB := ethdb.NewTestDB(t)
+	replica := replication.NewReplica(replicaDB, remoteKv, remoteKv.GrpcConn())
+	go replica.Run(ctx)
+	select {
+	case <-replica.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("replica is not bootstrapped")
+	}
+
+	// streamed changes
+	require.NoError(t, source.Update(ctx, func(tx kv.RwTx) error {
+		if err := tx.Put(kv.Headers, []byte{2}, []byte{2}); err != nil {
+			return err
+		}
+		if err := tx.Delete(kv.HeaderCanonical, []byte{1}, nil); err != nil {
+			return err
+		}
+		c, err := tx.RwCursorDupSort(kv.AccountChangeSet)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if err = c.AppendDup([]byte{1}, []byte{1}); err != nil {
+			return err
+		}
+		if err = c.AppendDup([]byte{1}, []byte{2}); err != nil {
+			return err
+		}
+		_, err = tx.IncrementSequence(kv.EthTx, 5)
+		return err
+	}))
+	last := l.Last()
+	require.Eventually(t, func() bool {
+		_, seq, err := replica.Progress(ctx)
+		require.NoError(t, err)
+		return seq == last
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, replicaDB.View(ctx, func(tx kv.Tx) error {
+		for _, k := range []byte{1, 2} {
+			v, err := tx.GetOne(kv.Headers, []byte{k})
+			require.NoError(t, err)
+			require.Equal(t, []byte{k}, v)
+		}
+		v, err := tx.GetOne(kv.HeaderCanonical, []byte{1})
+		require.NoError(t, err)
+		require.Nil(t, v)
+		c, err := tx.CursorDupSort(kv.AccountChangeSet)
+		require.NoError(t, err)
+		defer c.Close()
+		_, _, err = c.SeekExact([]byte{1})
+		require.NoError(t, err)
+		count, err := c.CountDuplicates()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), count)
+		seq, err := tx.ReadSequence(kv.EthTx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(15), seq)
+		return nil
+	}))
+}
+
+// gatedDB blocks the first BeginRo (the copy by the replica) until release is closed, and counts copies
+type gatedDB struct {
+	kv.RoDB
+	begun            int32
+	copies           int32
+	started, release chan struct{}
+}
+
+func (db *gatedDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	if atomic.CompareAndSwapInt32(&db.begun, 0, 1) {
+		close(db.started)
+		<-db.release
+	}
+	tx, err := db.RoDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gatedTx{Tx: tx, db: db}, nil
+}
+
+type gatedTx struct {
+	kv.Tx
+	db *gatedDB
+}
+
+func (tx *gatedTx) ForEach(table string, fromPrefix []byte, walker func(k, v []byte) error) error {
+	if table == replication.Tables[0] {
+		atomic.AddInt32(&tx.db.copies, 1)
+	}
+	return tx.Tx.ForEach(table, fromPrefix, walker)
+}
+
+func TestReplicaBootstrapWhileWriting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := replication.NewLog(datasize.MB)
+	source := replication.WrapDB(ethdb.NewTestDB(t), l, replication.Tables)
+	require.NoError(t, source.Update(ctx, func(tx kv.RwTx) error {
+		return tx.Put(kv.Headers, []byte{0, 1}, []byte{1})
+	}))
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	remote.RegisterKVServer(server, remotedbserver.NewKvServer(source))
+	replication.Register(server, replication.NewServer(l))
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+	remoteKv, err := remotedb.NewRemote(gointerfaces.VersionFromProto(remoteapi.KvServiceAPIVersion), log.New()).InMem(listener).Open("", "", "")
+	require.NoError(t, err)
+	defer remoteKv.Close()
+
+	replicaDB := ethdb.NewTestDB(t)
+	gated := &gatedDB{RoDB: remoteKv, started: make(chan struct{}), release: make(chan struct{})}
+	replica := replication.NewReplica(replicaDB, gated, remoteKv.GrpcConn())
+	go replica.Run(ctx)
+	<-gated.started
+
+	// readers don't see the replica before the copy is complete
+	require.NoError(t, replica.DB().View(ctx, func(tx kv.Tx) error {
+		v, err := tx.GetOne(kv.Headers, []byte{0, 1})
+		require.NoError(t, err)
+		require.Equal(t, []byte{1}, v)
+		return nil
+	}))
+
+	// much more than the log keeps is written during the copy
+	for i := 2; i < 2000; i++ {
+		require.NoError(t, source.Update(ctx, func(tx kv.RwTx) error {
+			return tx.Put(kv.Headers, []byte{byte(i >> 8), byte(i)}, make([]byte, 10*1024))
+		}))
+		time.Sleep(100 * time.Microsecond) // the log holds about 100 writes, the server keeps up with them
+	}
+	close(gated.release)
+	select {
+	case <-replica.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("replica is not bootstrapped")
+	}
+	last := l.Last()
+	require.Eventually(t, func() bool {
+		epoch, seq, err := replica.Progress(ctx)
+		require.NoError(t, err)
+		return epoch == l.Epoch() && seq == last
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, replicaDB.View(ctx, func(tx kv.Tx) error {
+		c, err := tx.Cursor(kv.Headers)
+		require.NoError(t, err)
+		defer c.Close()
+		count, err := c.Count()
+		require.NoError(t, err)
+		require.Equal(t, uint64(1999), count)
+		return nil
+	}))
+	// batches written during the copy were buffered, the replica didn't fall out of the log
+	require.Equal(t, int32(1), atomic.LoadInt32(&gated.copies))
+}
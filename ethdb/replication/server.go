@@ -0,0 +1,163 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// maxChunkSize is the max size of one streamed message, batches bigger than that are sent in several chunks.
+// Must be below MaxCallRecvMsgSize of remote KV clients.
+const maxChunkSize = 4 * 1024 * 1024
+
+// The service is described by hand and not generated from a .proto file, because interfaces of
+// erigon-lib can't change together with this repository. Messages are wrapperspb.BytesValue:
+//
+//	request - epoch (8 bytes) + seq (8 bytes) of the last batch applied by the replica, zeros if it starts from scratch
+//	reply - chunk of encoded Batch, prefixed by 1 byte which is 1 for the last chunk of the batch.
+//
+// The first batch of a stream is empty and only tells the position in the Log, from which the stream goes on.
+const (
+	serviceName      = "replication.Replication"
+	streamMethodName = "Stream"
+	streamMethod     = "/" + serviceName + "/" + streamMethodName
+)
+
+// Server streams batches of the Log to replicas
+type Server struct {
+	log *Log
+}
+
+func NewServer(log *Log) *Server {
+	return &Server{log: log}
+}
+
+// Register registers the service on the private API gRPC server
+func Register(s *grpc.Server, srv *Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    streamMethodName,
+			Handler:       streamHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "replication",
+}
+
+func streamHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(wrapperspb.BytesValue)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	if len(req.Value) != 16 {
+		return status.Errorf(codes.InvalidArgument, "position must be 16 bytes, got %d", len(req.Value))
+	}
+	epoch, seq := binary.BigEndian.Uint64(req.Value), binary.BigEndian.Uint64(req.Value[8:])
+	return srv.(*Server).stream(stream.Context(), epoch, seq, func(chunk []byte) error {
+		return stream.SendMsg(wrapperspb.Bytes(chunk))
+	})
+}
+
+func (s *Server) stream(ctx context.Context, epoch, seq uint64, send func(chunk []byte) error) error {
+	if epoch == 0 {
+		seq = s.log.Last()
+	} else if epoch != s.log.Epoch() {
+		return status.Error(codes.FailedPrecondition, "replication log was restarted")
+	}
+	if err := sendBatch(&Batch{Epoch: s.log.Epoch(), Seq: seq}, send); err != nil {
+		return err
+	}
+	for {
+		batches, wait, err := s.log.Read(seq)
+		if errors.Is(err, ErrTruncated) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		if wait != nil {
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		for _, b := range batches {
+			if err = sendBatch(b, send); err != nil {
+				return err
+			}
+			seq = b.Seq
+		}
+	}
+}
+
+func sendBatch(b *Batch, send func(chunk []byte) error) error {
+	encoded := EncodeBatch(b)
+	for {
+		n, last := len(encoded), byte(1)
+		if n > maxChunkSize {
+			n, last = maxChunkSize, 0
+		}
+		chunk := make([]byte, 1+n)
+		chunk[0] = last
+		copy(chunk[1:], encoded[:n])
+		if err := send(chunk); err != nil {
+			return err
+		}
+		if last == 1 {
+			return nil
+		}
+		encoded = encoded[n:]
+	}
+}
+
+// streamClient receives batches from the server
+type streamClient struct {
+	stream grpc.ClientStream
+}
+
+func openStream(ctx context.Context, cc grpc.ClientConnInterface, epoch, seq uint64) (*streamClient, error) {
+	stream, err := cc.NewStream(ctx, &serviceDesc.Streams[0], streamMethod)
+	if err != nil {
+		return nil, err
+	}
+	req := make([]byte, 16)
+	binary.BigEndian.PutUint64(req, epoch)
+	binary.BigEndian.PutUint64(req[8:], seq)
+	if err = stream.SendMsg(wrapperspb.Bytes(req)); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &streamClient{stream: stream}, nil
+}
+
+func (c *streamClient) Recv() (*Batch, error) {
+	var encoded []byte
+	for {
+		chunk := new(wrapperspb.BytesValue)
+		if err := c.stream.RecvMsg(chunk); err != nil {
+			return nil, err
+		}
+		if len(chunk.Value) == 0 {
+			return nil, errors.New("empty replication chunk")
+		}
+		encoded = append(encoded, chunk.Value[1:]...)
+		if chunk.Value[0] == 1 {
+			return DecodeBatch(encoded)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package replication streams committed changes of RPC-relevant tables from Erigon to read replicas
+// maintained by rpcdaemon (--replica.dir), so RPC reads are served from a local database on the replica.
+//
+// Erigon records logical writes (Put/Delete/...) of every committed write transaction into an in-memory Log.
+// Replicas copy tables once (bootstrap) over the remote KV interface, and then apply batches of the Log
+// streamed over gRPC, every batch in a single write transaction. Replaying the Log from any position
+// before the copy was taken converges to the same state, because every recorded operation sets
+// the final value of a key (or membership of a value for DupSort tables).
+package replication
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb"
+)
+
+// Tables are replicated tables: everything RPC daemon reads, but not the tables used only
+// to compute state root (hashed state, intermediate trie hashes) or by the sync itself
+var Tables = []string{
+	kv.AccountChangeSet,
+	kv.AccountsHistory,
+	kv.BlockBody,
+	kv.CallFromIndex,
+	kv.CallToIndex,
+	kv.CallTraceSet,
+	kv.Code,
+	kv.ConfigTable,
+	kv.ContractCode,
+	kv.ContractTEVMCode,
+	kv.DatabaseInfo,
+	kv.EthTx,
+	kv.HeadBlockKey,
+	kv.HeadHeaderKey,
+	kv.HeaderCanonical,
+	kv.HeaderNumber,
+	kv.HeaderTD,
+	kv.Headers,
+	kv.IncarnationMap,
+	kv.Log,
+	kv.LogAddressIndex,
+	kv.LogTopicIndex,
+	kv.PlainContractCode,
+	kv.PlainState,
+	kv.Receipts,
+	kv.Senders,
+	kv.Sequence,
+	kv.StorageChangeSet,
+	kv.StorageHistory,
+	kv.SyncStageProgress,
+	kv.TxLookup,
+	ethdb.AddressActivity,
+	ethdb.DataGasStats,
+	ethdb.FeeEpoch,
+}
+
+// Op is a logical write operation, as called by Erigon on kv.RwTx or its cursors
+type Op uint8
+
+const (
+	OpPut              Op = iota + 1 // Put, Append, AppendDup
+	OpDelete                         // Delete(k, v), DeleteCurrent
+	OpDeleteDuplicates               // DeleteCurrentDuplicates - all values of the key of DupSort table
+	OpClear                          // ClearBucket, DropBucket
+)
+
+type Record struct {
+	Op    Op
+	Table string
+	K, V  []byte
+}
+
+func (r Record) size() int { return 1 + len(r.Table) + len(r.K) + len(r.V) }
+
+// Batch is the set of records of one committed write transaction
+type Batch struct {
+	Epoch   uint64 // identifies the Log instance, it changes on every Erigon restart
+	Seq     uint64 // sequential number of the batch in the Log, starting from 1
+	Records []Record
+}
+
+// EncodeBatch serialises the batch as
+// epoch (8 bytes) + seq (8 bytes) + records, every record as op (1 byte) + table, key, value prefixed by uvarint length
+func EncodeBatch(b *Batch) []byte {
+	size := 16
+	for _, r := range b.Records {
+		size += r.size() + 3*binary.MaxVarintLen32
+	}
+	buf := make([]byte, 16, size)
+	binary.BigEndian.PutUint64(buf, b.Epoch)
+	binary.BigEndian.PutUint64(buf[8:], b.Seq)
+	var lenBuf [binary.MaxVarintLen64]byte
+	appendBytes := func(v []byte) {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(v)))
+		buf = append(buf, lenBuf[:n]...)
+		buf = append(buf, v...)
+	}
+	for _, r := range b.Records {
+		buf = append(buf, byte(r.Op))
+		appendBytes([]byte(r.Table))
+		appendBytes(r.K)
+		appendBytes(r.V)
+	}
+	return buf
+}
+
+func DecodeBatch(buf []byte) (*Batch, error) {
+	if len(buf) < 16 {
+		return nil, fmt.Errorf("batch is too short: %d", len(buf))
+	}
+	b := &Batch{Epoch: binary.BigEndian.Uint64(buf), Seq: binary.BigEndian.Uint64(buf[8:])}
+	buf = buf[16:]
+	readBytes := func() ([]byte, error) {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < l {
+			return nil, fmt.Errorf("malformed batch %d", b.Seq)
+		}
+		v := buf[n : n+int(l)]
+		buf = buf[n+int(l):]
+		return v, nil
+	}
+	for len(buf) > 0 {
+		r := Record{Op: Op(buf[0])}
+		buf = buf[1:]
+		table, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		r.Table = string(table)
+		if r.K, err = readBytes(); err != nil {
+			return nil, err
+		}
+		if r.V, err = readBytes(); err != nil {
+			return nil, err
+		}
+		b.Records = append(b.Records, r)
+	}
+	return b, nil
+}
+
+// Apply executes records of the batch in the given transaction
+func Apply(tx kv.RwTx, b *Batch) error {
+	for _, r := range b.Records {
+		var err error
+		switch r.Op {
+		case OpPut:
+			err = tx.Put(r.Table, r.K, r.V)
+		case OpDelete:
+			err = tx.Delete(r.Table, r.K, r.V)
+		case OpDeleteDuplicates:
+			err = deleteDuplicates(tx, r.Table, r.K)
+		case OpClear:
+			err = tx.ClearBucket(r.Table)
+		default:
+			err = fmt.Errorf("unknown op %d", r.Op)
+		}
+		if err != nil {
+			return fmt.Errorf("applying batch %d to %s: %w", b.Seq, r.Table, err)
+		}
+	}
+	return nil
+}
+
+func deleteDuplicates(tx kv.RwTx, table string, k []byte) error {
+	c, err := tx.RwCursorDupSort(table)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	found, _, err := c.SeekExact(k)
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return nil
+	}
+	return c.DeleteCurrentDuplicates()
+}
@@ -179,6 +179,8 @@ func (s *SnapshotKV) StateSnapshot() kv.RoDB {
 func (s *SnapshotKV) snapsthotsTx(ctx context.Context) (kv.Tx, kv.Tx, kv.Tx, error) {
 	var headersTX, bodiesTX, stateTX kv.Tx
 	var err error
+	s.mtx.RLock() // snapshots are replaced by UpdateSnapshots
+	defer s.mtx.RUnlock()
 	defer func() {
 		if err != nil {
 			if headersTX != nil {
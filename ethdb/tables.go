@@ -0,0 +1,81 @@
+package ethdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Tables below are owned by Erigon itself and are not (yet) part of erigon-lib's table list.
+// Chaindata gets them by WithErigonTables passed to the options of mdbx where it's opened.
+const (
+	// FeeEpoch - aggregated fee market data of FeeEpochSize consecutive blocks, maintained by the FeeSeries stage
+	// key - epoch number (block number / FeeEpochSize), encoded as 8 bytes big endian
+	// value - rlp of rawdb.FeeEpoch
+	FeeEpoch = "FeeEpoch"
+
+	// AddressActivity - first and last block in which an address took part in a call, maintained by the Execution stage
+	// key - address
+	// value - first block number (8 bytes big endian) + last block number (8 bytes big endian)
+	AddressActivity = "AddressActivity"
+
+	// ReplicationProgress - position of a read replica (rpcdaemon --replica.dir) in the replication log of Erigon
+	// key - "progress"
+	// value - epoch of the log (8 bytes big endian) + seq of the last applied batch (8 bytes big endian)
+	ReplicationProgress = "ReplicationProgress"
+
+	// DataGasStats - calldata and blob usage of every block, maintained by the DataGasStats stage
+	// key - block number (8 bytes big endian)
+	// value - rlp of rawdb.DataGasStats
+	DataGasStats = "DataGasStats"
+)
+
+// ErigonTables is the list of tables added to chaindata by WithErigonTables
+var ErigonTables = []string{
+	FeeEpoch,
+	AddressActivity,
+	ReplicationProgress,
+	DataGasStats,
+}
+
+// WithErigonTables is the configuration of tables of chaindata: the tables of erigon-lib and ErigonTables.
+// Pass it to mdbx.MdbxOpts.WithTablessCfg (or WithBucketsConfig of remotedb) when opening chaindata.
+func WithErigonTables(defaultBuckets kv.TableCfg) kv.TableCfg {
+	tables := make(kv.TableCfg, len(defaultBuckets)+len(ErigonTables))
+	for name, cfg := range defaultBuckets {
+		tables[name] = cfg
+	}
+	for _, name := range ErigonTables {
+		if _, ok := tables[name]; !ok {
+			tables[name] = kv.TableCfgItem{}
+		}
+	}
+	return tables
+}
+
+// NewMemDB opens in-memory chaindata with ErigonTables
+func NewMemDB() kv.RwDB {
+	return mdbx.NewMDBX(log.New()).InMem().WithTablessCfg(WithErigonTables).MustOpen()
+}
+
+// NewTestDB opens in-memory chaindata with ErigonTables, closed at the end of the test
+func NewTestDB(t testing.TB) kv.RwDB {
+	db := NewMemDB()
+	t.Cleanup(db.Close)
+	return db
+}
+
+// NewTestTx opens in-memory chaindata with ErigonTables and begins a write transaction, both closed at the end
+// of the test
+func NewTestTx(t testing.TB) (kv.RwDB, kv.RwTx) {
+	db := NewTestDB(t)
+	tx, err := db.BeginRw(context.Background()) //nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tx.Rollback)
+	return db, tx
+}
@@ -0,0 +1,213 @@
+package temporal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/changeset"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+)
+
+// Tx reads the latest state and its history. Tables of the history are the changeset tables: kv.AccountChangeSet
+// with keys of kv.PlainState accounts (address) and kv.StorageChangeSet with keys of kv.PlainState storage
+// (address, incarnation, location). Wherever the history is kept - in the changesets and history indices of
+// the database or in the history files - it's read the same way.
+type Tx interface {
+	kv.Tx
+
+	// GetAsOf returns the value of the key before the block, as it was after execution of block-1.
+	// Accounts are returned with their code hash, nil - the key didn't exist.
+	GetAsOf(table string, key []byte, block uint64) ([]byte, error)
+	// HistorySeek returns the value of the key before its first change at or after the block,
+	// ethdb.ErrKeyNotFound if there are no such changes
+	HistorySeek(table string, key []byte, block uint64) ([]byte, error)
+	// HistoryRange calls walker for the changes of keys in blocks [from, to), with values before the changes
+	// (accounts without code hash, as in the changesets). Changes are not ordered.
+	HistoryRange(table string, from, to uint64, walker func(block uint64, k, v []byte) (bool, error)) error
+	// IndexRange returns the blocks in [from, to), in which the key was changed. Changes of storage are
+	// indexed without incarnation, so changes of all incarnations of the contract are returned.
+	IndexRange(table string, key []byte, from, to uint64) (*roaring64.Bitmap, error)
+	// WalkAsOf walks keys of the state from fromKey, as they were before the block, keys which didn't exist then are
+	// skipped. Values changed at or after the block are taken from the history (accounts without code hash).
+	// Accounts are walked without prefix, storage - by contract: the prefix is address and incarnation.
+	WalkAsOf(table string, prefix, fromKey []byte, block uint64, walker func(k, v []byte) (bool, error)) error
+}
+
+// New returns the temporal view of the transaction
+func New(tx kv.Tx) Tx {
+	if ttx, ok := tx.(Tx); ok {
+		return ttx
+	}
+	return &temporalTx{Tx: tx}
+}
+
+// ByCursors returns the temporal view, which reads the history by cursors of the transaction, also when the transaction
+// implements Tx itself. Implementations of Tx fall back to it for what they can't read otherwise.
+func ByCursors(tx kv.Tx) Tx {
+	return &temporalTx{Tx: tx}
+}
+
+type temporalTx struct {
+	kv.Tx
+}
+
+func (tx *temporalTx) GetAsOf(table string, key []byte, block uint64) ([]byte, error) {
+	v, err := tx.HistorySeek(table, key, block)
+	if err == nil {
+		return v, nil
+	}
+	if !errors.Is(err, ethdb.ErrKeyNotFound) {
+		return nil, err
+	}
+	return tx.GetOne(kv.PlainState, key)
+}
+
+func (tx *temporalTx) HistorySeek(table string, key []byte, block uint64) ([]byte, error) {
+	return FindByHistory(tx.Tx, table, key, block)
+}
+
+func (tx *temporalTx) HistoryRange(table string, from, to uint64, walker func(block uint64, k, v []byte) (bool, error)) error {
+	goOn := true
+	if err := historyfiles.WalkChanges(tx.Tx, table, from, to, func(block uint64, k, v []byte) (bool, error) {
+		var err error
+		goOn, err = walker(block, k, v)
+		return goOn, err
+	}); err != nil || !goOn {
+		return err
+	}
+	// changesets of the blocks moved to the files may be not pruned yet
+	end, err := historyfiles.ReadEnd(tx.Tx)
+	if err != nil {
+		return err
+	}
+	if from < end {
+		from = end
+	}
+	return changeset.Walk(tx.Tx, table, dbutils.EncodeBlockNumber(from), 0, func(block uint64, k, v []byte) (bool, error) {
+		if block >= to {
+			return false, nil
+		}
+		return walker(block, k, v)
+	})
+}
+
+func (tx *temporalTx) IndexRange(table string, key []byte, from, to uint64) (*roaring64.Bitmap, error) {
+	if from >= to {
+		return roaring64.New(), nil
+	}
+	result, err := historyfiles.ChangedBlocks(tx.Tx, table, key, from, to)
+	if err != nil {
+		return nil, err
+	}
+	indexKey := changeset.Mapper[table].IndexChunkKey(key, 0)
+	indexKey = indexKey[:len(indexKey)-8]
+	blocks, err := bitmapdb.Get64(tx.Tx, changeset.Mapper[table].IndexBucket, indexKey, from, to-1)
+	if err != nil {
+		return nil, err
+	}
+	blocks.RemoveRange(0, from)
+	blocks.RemoveRange(to, math.MaxUint64)
+	result.Or(blocks)
+	return result, nil
+}
+
+// FindByHistory returns the value of the key before the first change at or after the block,
+// ethdb.ErrKeyNotFound if there are no such changes
+func FindByHistory(tx kv.Tx, table string, key []byte, block uint64) ([]byte, error) {
+	storage := table == kv.StorageChangeSet
+
+	// history of old blocks may be moved to the files
+	data, ok, block, err := historyfiles.Lookup(tx, table, key, block)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if data, err = findInChangeSets(tx, table, storage, key, block); err != nil {
+			return nil, err
+		}
+	}
+
+	//restore codehash
+	if !storage {
+		var acc accounts.Account
+		if err := acc.DecodeForStorage(data); err != nil {
+			return nil, err
+		}
+		if acc.Incarnation > 0 && acc.IsEmptyCodeHash() {
+			var codeHash []byte
+			var err error
+			codeHash, err = tx.GetOne(kv.PlainContractCode, dbutils.PlainGenerateStoragePrefix(key, acc.Incarnation))
+			if err != nil {
+				return nil, err
+			}
+			if len(codeHash) > 0 {
+				acc.CodeHash = common.BytesToHash(codeHash)
+			}
+			data = make([]byte, acc.EncodingLengthForStorage())
+			acc.EncodeForStorage(data)
+		}
+		return data, nil
+	}
+
+	return data, nil
+}
+
+func findInChangeSets(tx kv.Tx, csBucket string, storage bool, key []byte, timestamp uint64) ([]byte, error) {
+	ch, err := tx.Cursor(changeset.Mapper[csBucket].IndexBucket)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	k, v, seekErr := ch.Seek(changeset.Mapper[csBucket].IndexChunkKey(key, timestamp))
+	if seekErr != nil {
+		return nil, seekErr
+	}
+
+	if k == nil {
+		return nil, ethdb.ErrKeyNotFound
+	}
+	if storage {
+		if !bytes.Equal(k[:common.AddressLength], key[:common.AddressLength]) ||
+			!bytes.Equal(k[common.AddressLength:common.AddressLength+common.HashLength], key[common.AddressLength+common.IncarnationLength:]) {
+			return nil, ethdb.ErrKeyNotFound
+		}
+	} else {
+		if !bytes.HasPrefix(k, key) {
+			return nil, ethdb.ErrKeyNotFound
+		}
+	}
+	index := roaring64.New()
+	if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+		return nil, err
+	}
+	found, ok := bitmapdb.SeekInBitmap64(index, timestamp)
+	changeSetBlock := found
+
+	var data []byte
+	if ok {
+		c, err := tx.CursorDupSort(csBucket)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		data, err = changeset.Mapper[csBucket].Find(c, changeSetBlock, key)
+		if err != nil {
+			if !errors.Is(err, changeset.ErrNotFound) {
+				return nil, fmt.Errorf("finding %x in the changeset %d: %w", key, changeSetBlock, err)
+			}
+			return nil, ethdb.ErrKeyNotFound
+		}
+	} else {
+		return nil, ethdb.ErrKeyNotFound
+	}
+	return data, nil
+}
@@ -0,0 +1,97 @@
+package temporal_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+	"github.com/ledgerwatch/erigon/ethdb/temporal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemporalTx(t *testing.T) {
+	ctx := context.Background()
+	files, err := historyfiles.Open(t.TempDir())
+	require.NoError(t, err)
+	db := historyfiles.WrapDB(memdb.NewTestDB(t), files)
+
+	addr := common.HexToAddress("0x1")
+	key := dbutils.PlainGenerateCompositeStorageKey(addr.Bytes(), 1, common.HexToHash("0x3").Bytes())
+	putChange := func(tx kv.RwTx, block uint64, v string) error {
+		csKey := append(dbutils.EncodeBlockNumber(block), key[:common.AddressLength+common.IncarnationLength]...)
+		return tx.Put(kv.StorageChangeSet, csKey, append(common.CopyBytes(key[common.AddressLength+common.IncarnationLength:]), v...))
+	}
+	// block 3 is moved to the files, the later blocks are in the changesets and the history index
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		if err := putChange(tx, 3, "s1"); err != nil {
+			return err
+		}
+		if err := files.Build(ctx, tx, 0, historyfiles.StepSize, "test", t.TempDir()); err != nil {
+			return err
+		}
+		if err := historyfiles.WriteEnd(tx, historyfiles.StepSize); err != nil {
+			return err
+		}
+		index := roaring64.New()
+		for block, v := range map[uint64]string{historyfiles.StepSize + 5: "s2", historyfiles.StepSize + 8: "s3"} {
+			if err := putChange(tx, block, v); err != nil {
+				return err
+			}
+			index.Add(block)
+		}
+		var buf bytes.Buffer
+		if _, err := index.WriteTo(&buf); err != nil {
+			return err
+		}
+		if err := tx.Put(kv.StorageHistory, dbutils.StorageIndexChunkKey(key, math.MaxUint64), buf.Bytes()); err != nil {
+			return err
+		}
+		return tx.Put(kv.PlainState, key, []byte("current"))
+	}))
+
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		ttx := temporal.New(tx)
+		for _, c := range []struct {
+			block uint64
+			v     string
+		}{{0, "s1"}, {4, "s2"}, {historyfiles.StepSize + 5, "s2"}, {historyfiles.StepSize + 6, "s3"}, {historyfiles.StepSize + 9, "current"}} {
+			v, err := ttx.GetAsOf(kv.StorageChangeSet, key, c.block)
+			require.NoError(t, err)
+			require.Equal(t, c.v, string(v), c.block)
+		}
+
+		changes := map[uint64]string{}
+		require.NoError(t, ttx.HistoryRange(kv.StorageChangeSet, 0, historyfiles.StepSize+8, func(block uint64, k, v []byte) (bool, error) {
+			require.Equal(t, key, k)
+			changes[block] = string(v)
+			return true, nil
+		}))
+		require.Equal(t, map[uint64]string{3: "s1", historyfiles.StepSize + 5: "s2"}, changes)
+
+		blocks, err := ttx.IndexRange(kv.StorageChangeSet, key, 0, math.MaxUint64)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{3, historyfiles.StepSize + 5, historyfiles.StepSize + 8}, blocks.ToArray())
+		blocks, err = ttx.IndexRange(kv.StorageChangeSet, key, 4, historyfiles.StepSize+8)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{historyfiles.StepSize + 5}, blocks.ToArray())
+
+		prefix := key[:common.AddressLength+common.IncarnationLength]
+		for block, v := range map[uint64]string{0: "s1", historyfiles.StepSize + 6: "s3", historyfiles.StepSize + 9: "current"} {
+			walked := map[string]string{}
+			require.NoError(t, ttx.WalkAsOf(kv.StorageChangeSet, prefix, prefix, block, func(k, v []byte) (bool, error) {
+				walked[string(k)] = string(v)
+				return true, nil
+			}))
+			require.Equal(t, map[string]string{string(key): v}, walked, block)
+		}
+		require.Error(t, ttx.WalkAsOf(kv.StorageChangeSet, nil, nil, 0, nil))
+		return nil
+	}))
+}
@@ -0,0 +1,367 @@
+package temporal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/ledgerwatch/erigon/ethdb/bitmapdb"
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles"
+)
+
+func (tx *temporalTx) WalkAsOf(table string, prefix, fromKey []byte, block uint64, walker func(k, v []byte) (bool, error)) error {
+	switch table {
+	case kv.AccountChangeSet:
+		if len(prefix) != 0 {
+			return fmt.Errorf("WalkAsOf: accounts are walked without prefix, got %x", prefix)
+		}
+		var startAddress common.Address
+		copy(startAddress[:], fromKey)
+		return walkAccountsAsOf(tx.Tx, startAddress, block, walker)
+	case kv.StorageChangeSet:
+		if len(prefix) != common.AddressLength+common.IncarnationLength || !bytes.HasPrefix(fromKey, prefix) {
+			return fmt.Errorf("WalkAsOf: storage is walked by contract, got prefix %x, key %x", prefix, fromKey)
+		}
+		address := common.BytesToAddress(prefix[:common.AddressLength])
+		incarnation := binary.BigEndian.Uint64(prefix[common.AddressLength:])
+		var startLocation common.Hash
+		copy(startLocation[:], fromKey[len(prefix):])
+		return walkStorageAsOf(tx.Tx, address, incarnation, startLocation, block, walker)
+	}
+	return fmt.Errorf("WalkAsOf: %s is not a history table", table)
+}
+
+// walkStorageAsOf walks storage of the contract incarnation, history of old blocks may be in the files
+func walkStorageAsOf(tx kv.Tx, address common.Address, incarnation uint64, startLocation common.Hash, timestamp uint64, walker func(k, v []byte) (bool, error)) error {
+	prefix := dbutils.PlainGenerateStoragePrefix(address[:], incarnation)
+	walkDB := func(timestamp uint64, walker func(k, v []byte) (bool, error)) error {
+		return walkAsOfStorage(tx, address, incarnation, startLocation, timestamp, func(_, loc, v []byte) (bool, error) {
+			return walker(append(common.CopyBytes(prefix), loc...), v)
+		})
+	}
+	end, err := historyfiles.ReadEnd(tx)
+	if err != nil {
+		return err
+	}
+	if timestamp >= end {
+		return walkDB(timestamp, walker)
+	}
+	files, err := historyfiles.NewAsOfCursor(tx, kv.StorageChangeSet, append(common.CopyBytes(prefix), startLocation[:]...), timestamp)
+	if err != nil {
+		return err
+	}
+	return walkWithFiles(files, prefix, func(walker func(k, v []byte) (bool, error)) error {
+		return walkDB(end, walker)
+	}, walker)
+}
+
+// walkAccountsAsOf walks accounts, history of old blocks may be in the files
+func walkAccountsAsOf(tx kv.Tx, startAddress common.Address, timestamp uint64, walker func(k, v []byte) (bool, error)) error {
+	end, err := historyfiles.ReadEnd(tx)
+	if err != nil {
+		return err
+	}
+	if timestamp >= end {
+		return walkAsOfAccounts(tx, startAddress, timestamp, walker)
+	}
+	files, err := historyfiles.NewAsOfCursor(tx, kv.AccountChangeSet, startAddress[:], timestamp)
+	if err != nil {
+		return err
+	}
+	return walkWithFiles(files, nil, func(walker func(k, v []byte) (bool, error)) error {
+		return walkAsOfAccounts(tx, startAddress, end, walker)
+	}, walker)
+}
+
+// walkWithFiles walks the state as of a block, history of which was moved to the files: keys, which were changed
+// at or after the block in the files, take values from them, the rest is walked by walkAsOfEnd - the walk of
+// the state as of the end of the files. Keys of both walks must have the prefix.
+func walkWithFiles(files *historyfiles.AsOfCursor, prefix []byte, walkAsOfEnd func(walker func(k, v []byte) (bool, error)) error, walker func(k, v []byte) (bool, error)) error {
+	next := func() ([]byte, []byte, error) {
+		k, v, err := files.Next()
+		if err != nil || !bytes.HasPrefix(k, prefix) {
+			return nil, nil, err
+		}
+		return k, v, nil
+	}
+	fk, fv, err := next()
+	if err != nil {
+		return err
+	}
+	goOn := true
+	if err = walkAsOfEnd(func(k, v []byte) (bool, error) {
+		for fk != nil && bytes.Compare(fk, k) <= 0 {
+			changed := bytes.Equal(fk, k)
+			if len(fv) > 0 { // skip keys, which didn't exist
+				if goOn, err = walker(fk, fv); err != nil || !goOn {
+					return false, err
+				}
+			}
+			if fk, fv, err = next(); err != nil {
+				return false, err
+			}
+			if changed {
+				return true, nil
+			}
+		}
+		goOn, err = walker(k, v)
+		return goOn, err
+	}); err != nil || !goOn {
+		return err
+	}
+	for ; fk != nil; fk, fv, err = next() {
+		if len(fv) > 0 {
+			if goOn, err = walker(fk, fv); err != nil || !goOn {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// walkAsOfStorage walks storage of the contract incarnation from startLocation by the changesets and history indices
+// of the database, walker gets address, location and value
+func walkAsOfStorage(tx kv.Tx, address common.Address, incarnation uint64, startLocation common.Hash, timestamp uint64, walker func(k1, k2, v []byte) (bool, error)) error {
+	var startkey = make([]byte, common.AddressLength+common.IncarnationLength+common.HashLength)
+	copy(startkey, address.Bytes())
+	binary.BigEndian.PutUint64(startkey[common.AddressLength:], incarnation)
+	copy(startkey[common.AddressLength+common.IncarnationLength:], startLocation.Bytes())
+
+	var startkeyNoInc = make([]byte, common.AddressLength+common.HashLength)
+	copy(startkeyNoInc, address.Bytes())
+	copy(startkeyNoInc[common.AddressLength:], startLocation.Bytes())
+
+	//for storage
+	mCursor, err := tx.Cursor(kv.PlainState)
+	if err != nil {
+		return err
+	}
+	defer mCursor.Close()
+	mainCursor := ethdb.NewSplitCursor(
+		mCursor,
+		startkey,
+		8*(common.AddressLength+common.IncarnationLength),
+		common.AddressLength,                                            /* part1end */
+		common.AddressLength+common.IncarnationLength,                   /* part2start */
+		common.AddressLength+common.IncarnationLength+common.HashLength, /* part3start */
+	)
+
+	//for historic data
+	shCursor, err := tx.Cursor(kv.StorageHistory)
+	if err != nil {
+		return err
+	}
+	defer shCursor.Close()
+	var hCursor = ethdb.NewSplitCursor(
+		shCursor,
+		startkeyNoInc,
+		8*common.AddressLength,
+		common.AddressLength,                   /* part1end */
+		common.AddressLength,                   /* part2start */
+		common.AddressLength+common.HashLength, /* part3start */
+	)
+	csCursor, err := tx.CursorDupSort(kv.StorageChangeSet)
+	if err != nil {
+		return err
+	}
+	defer csCursor.Close()
+
+	addr, loc, _, v, err1 := mainCursor.Seek()
+	if err1 != nil {
+		return err1
+	}
+
+	hAddr, hLoc, tsEnc, hV, err2 := hCursor.Seek()
+	if err2 != nil {
+		return err2
+	}
+	for hLoc != nil && binary.BigEndian.Uint64(tsEnc) < timestamp {
+		if hAddr, hLoc, tsEnc, hV, err2 = hCursor.Next(); err2 != nil {
+			return err2
+		}
+	}
+	goOn := true
+	for goOn {
+		cmp, br := common.KeyCmp(addr, hAddr)
+		if br {
+			break
+		}
+		if cmp == 0 {
+			cmp, br = common.KeyCmp(loc, hLoc)
+		}
+		if br {
+			break
+		}
+
+		//next key in state
+		if cmp < 0 {
+			goOn, err = walker(addr, loc, v)
+		} else {
+			index := roaring64.New()
+			if _, err = index.ReadFrom(bytes.NewReader(hV)); err != nil {
+				return err
+			}
+			found, ok := bitmapdb.SeekInBitmap64(index, timestamp)
+			changeSetBlock := found
+
+			if ok {
+				// Extract value from the changeSet
+				csKey := make([]byte, 8+common.AddressLength+common.IncarnationLength)
+				copy(csKey, dbutils.EncodeBlockNumber(changeSetBlock))
+				copy(csKey[8:], address[:]) // address + incarnation
+				binary.BigEndian.PutUint64(csKey[8+common.AddressLength:], incarnation)
+				kData := csKey
+				data, err3 := csCursor.SeekBothRange(csKey, hLoc)
+				if err3 != nil {
+					return err3
+				}
+				if !bytes.Equal(kData, csKey) || !bytes.HasPrefix(data, hLoc) {
+					return fmt.Errorf("inconsistent storage changeset and history kData %x, csKey %x, data %x, hLoc %x", kData, csKey, data, hLoc)
+				}
+				data = data[common.HashLength:]
+				if len(data) > 0 { // Skip deleted entries
+					goOn, err = walker(hAddr, hLoc, data)
+				}
+			} else if cmp == 0 {
+				goOn, err = walker(addr, loc, v)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if goOn {
+			if cmp <= 0 {
+				if addr, loc, _, v, err1 = mainCursor.Next(); err1 != nil {
+					return err1
+				}
+			}
+			if cmp >= 0 {
+				hLoc0 := hLoc
+				for hLoc != nil && (bytes.Equal(hLoc0, hLoc) || binary.BigEndian.Uint64(tsEnc) < timestamp) {
+					if hAddr, hLoc, tsEnc, hV, err2 = hCursor.Next(); err2 != nil {
+						return err2
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// walkAsOfAccounts walks accounts from startAddress by the changesets and history indices of the database
+func walkAsOfAccounts(tx kv.Tx, startAddress common.Address, timestamp uint64, walker func(k []byte, v []byte) (bool, error)) error {
+	mainCursor, err := tx.Cursor(kv.PlainState)
+	if err != nil {
+		return err
+	}
+	defer mainCursor.Close()
+	ahCursor, err := tx.Cursor(kv.AccountsHistory)
+	if err != nil {
+		return err
+	}
+	defer ahCursor.Close()
+	var hCursor = ethdb.NewSplitCursor(
+		ahCursor,
+		startAddress.Bytes(),
+		0,                      /* fixedBits */
+		common.AddressLength,   /* part1end */
+		common.AddressLength,   /* part2start */
+		common.AddressLength+8, /* part3start */
+	)
+	csCursor, err := tx.CursorDupSort(kv.AccountChangeSet)
+	if err != nil {
+		return err
+	}
+	defer csCursor.Close()
+
+	k, v, err1 := mainCursor.Seek(startAddress.Bytes())
+	if err1 != nil {
+		return err1
+	}
+	for k != nil && len(k) > common.AddressLength {
+		k, v, err1 = mainCursor.Next()
+		if err1 != nil {
+			return err1
+		}
+	}
+	hK, tsEnc, _, hV, err2 := hCursor.Seek()
+	if err2 != nil {
+		return err2
+	}
+	for hK != nil && binary.BigEndian.Uint64(tsEnc) < timestamp {
+		hK, tsEnc, _, hV, err2 = hCursor.Next()
+		if err2 != nil {
+			return err2
+		}
+	}
+
+	goOn := true
+	for goOn {
+		//exit or next conditions
+		cmp, br := common.KeyCmp(k, hK)
+		if br {
+			break
+		}
+		if cmp < 0 {
+			goOn, err = walker(k, v)
+		} else {
+			index := roaring64.New()
+			_, err = index.ReadFrom(bytes.NewReader(hV))
+			if err != nil {
+				return err
+			}
+			found, ok := bitmapdb.SeekInBitmap64(index, timestamp)
+			changeSetBlock := found
+			if ok {
+				// Extract value from the changeSet
+				csKey := dbutils.EncodeBlockNumber(changeSetBlock)
+				kData := csKey
+				data, err3 := csCursor.SeekBothRange(csKey, hK)
+				if err3 != nil {
+					return err3
+				}
+				if !bytes.Equal(kData, csKey) || !bytes.HasPrefix(data, hK) {
+					return fmt.Errorf("inconsistent account history and changesets, kData %x, csKey %x, data %x, hK %x", kData, csKey, data, hK)
+				}
+				data = data[common.AddressLength:]
+				if len(data) > 0 { // Skip accounts did not exist
+					goOn, err = walker(hK, data)
+				}
+			} else if cmp == 0 {
+				goOn, err = walker(k, v)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if goOn {
+			if cmp <= 0 {
+				k, v, err1 = mainCursor.Next()
+				if err1 != nil {
+					return err1
+				}
+				for k != nil && len(k) > common.AddressLength {
+					k, v, err1 = mainCursor.Next()
+					if err1 != nil {
+						return err1
+					}
+				}
+			}
+			if cmp >= 0 {
+				hK0 := hK
+				for hK != nil && (bytes.Equal(hK0, hK) || binary.BigEndian.Uint64(tsEnc) < timestamp) {
+					hK, tsEnc, _, hV, err1 = hCursor.Next()
+					if err1 != nil {
+						return err1
+					}
+				}
+			}
+		}
+	}
+	return err
+}
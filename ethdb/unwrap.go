@@ -0,0 +1,38 @@
+package ethdb
+
+import "github.com/ledgerwatch/erigon-lib/kv"
+
+// DBWrapper is implemented by wrappers of databases (diagnostics, replication, history files, lag), which add behaviour
+// to the wrapped database. Methods of the underlying database not in kv.RwDB (f.e. Env of mdbx) are not promoted
+// through the wrappers, they are reached by UnwrapDB.
+type DBWrapper interface {
+	UnwrapDB() kv.RoDB
+}
+
+// TxWrapper is implemented by transactions of the wrappers, see DBWrapper
+type TxWrapper interface {
+	UnwrapTx() kv.Tx
+}
+
+// UnwrapDB returns the innermost database under the wrappers
+func UnwrapDB(db kv.RoDB) kv.RoDB {
+	for {
+		w, ok := db.(DBWrapper)
+		if !ok {
+			return db
+		}
+		db = w.UnwrapDB()
+	}
+}
+
+// UnwrapTx returns the innermost transaction under the wrappers, f.e. to get SpaceDirty of mdbx. Writes must go
+// through the wrappers.
+func UnwrapTx(tx kv.Tx) kv.Tx {
+	for {
+		w, ok := tx.(TxWrapper)
+		if !ok {
+			return tx
+		}
+		tx = w.UnwrapTx()
+	}
+}
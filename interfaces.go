@@ -154,6 +154,9 @@ type FilterQuery struct {
 	// {{A}, {B}}         matches topic A in first position AND B in second position
 	// {{A, B}, {C, D}}   matches topic (A OR B) in first position AND (C OR D) in second position
 	Topics [][]common.Hash
+
+	// Continuation resumes eth_getLogs at the position returned with the previous page of logs
+	Continuation string
 }
 
 // LogFilterer provides access to contract log events using a one-off query or continuous
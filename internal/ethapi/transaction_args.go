@@ -0,0 +1,87 @@
+package ethapi
+
+import (
+	"math/big"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+// SendTxArgs represents the arguments to sign and submit a new transaction into the transaction pool.
+type SendTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	// We accept "data" and "input" for backwards-compatibility reasons. "input" is the
+	// newer name and should be preferred by clients.
+	Data  *hexutil.Bytes `json:"data"`
+	Input *hexutil.Bytes `json:"input"`
+
+	// For non-legacy transactions
+	AccessList *types.AccessList `json:"accessList,omitempty"`
+	ChainID    *hexutil.Big      `json:"chainId,omitempty"`
+}
+
+// ToTransaction converts the arguments to a transaction: dynamic fee one if maxFeePerGas is given,
+// access list one if accessList is given, legacy otherwise. Gas, nonce, value, chainId and gasPrice
+// or maxFeePerGas must be set.
+func (args *SendTxArgs) ToTransaction() types.Transaction {
+	var input []byte
+	if args.Input != nil {
+		input = *args.Input
+	} else if args.Data != nil {
+		input = *args.Data
+	}
+	value, _ := uint256.FromBig((*big.Int)(args.Value))
+	commonTx := types.CommonTx{
+		To:    args.To,
+		Nonce: uint64(*args.Nonce),
+		Gas:   uint64(*args.Gas),
+		Value: value,
+		Data:  input,
+	}
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	switch {
+	case args.MaxFeePerGas != nil:
+		chainID, _ := uint256.FromBig((*big.Int)(args.ChainID))
+		tip := new(uint256.Int)
+		if args.MaxPriorityFeePerGas != nil {
+			tip, _ = uint256.FromBig((*big.Int)(args.MaxPriorityFeePerGas))
+		}
+		feeCap, _ := uint256.FromBig((*big.Int)(args.MaxFeePerGas))
+		return &types.DynamicFeeTransaction{
+			CommonTx:   commonTx,
+			Tip:        tip,
+			FeeCap:     feeCap,
+			ChainID:    chainID,
+			AccessList: accessList,
+		}
+	case args.AccessList != nil:
+		chainID, _ := uint256.FromBig((*big.Int)(args.ChainID))
+		gasPrice, _ := uint256.FromBig((*big.Int)(args.GasPrice))
+		return &types.AccessListTx{
+			LegacyTx:   types.LegacyTx{CommonTx: commonTx, GasPrice: gasPrice},
+			ChainID:    chainID,
+			AccessList: accessList,
+		}
+	default:
+		gasPrice, _ := uint256.FromBig((*big.Int)(args.GasPrice))
+		return &types.LegacyTx{CommonTx: commonTx, GasPrice: gasPrice}
+	}
+}
+
+// SignTransactionResult represents a RLP encoded signed transaction.
+type SignTransactionResult struct {
+	Raw hexutil.Bytes     `json:"raw"`
+	Tx  types.Transaction `json:"tx"`
+}
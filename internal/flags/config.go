@@ -0,0 +1,327 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	goflag "flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/pflag"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file contains values of command line flags, keys are names of the flags:
+//
+//	datadir: /data/erigon
+//	http.api: [eth, erigon, web3]
+//	private.api.addr: ${PRIVATE_API_ADDR:-127.0.0.1:9090}
+//
+// Nested maps are flattened with ".", so `http: {port: 8545}` sets --http.port. Lists are joined with ",".
+// ${VAR} and ${VAR:-default} in string values are replaced with environment variables after parsing,
+// so in TOML they must be quoted: `"http.port" = "${HTTP_PORT:-8545}"`.
+// Flags given on the command line take precedence over the file.
+
+const (
+	ConfigFlagName               = "config"
+	PrintEffectiveConfigFlagName = "print-effective-config"
+)
+
+// ConfigFlagUsage is the usage of the --config flag, shared by all binaries
+const ConfigFlagUsage = "Path to YAML (.yaml, .yml) or TOML (.toml) file with values of flags. Flags set on the command line take precedence"
+
+// PrintEffectiveConfigFlagUsage is the usage of the --print-effective-config flag, shared by all binaries
+const PrintEffectiveConfigFlagUsage = "Print values of all flags after applying the config file and the command line, in the format of the config file (YAML by default), and exit"
+
+// ErrEffectiveConfigPrinted is returned by ApplyConfigUrfave and ApplyConfigCobra after printing the effective
+// config for --print-effective-config, the caller is expected to exit without running the command
+var ErrEffectiveConfigPrinted = errors.New("effective config printed")
+
+var envRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Interpolate replaces ${VAR} and ${VAR:-default} with values of environment variables,
+// it's an error to refer to an unset variable without default
+func Interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	var missing []string
+	res := envRe.ReplaceAllStringFunc(s, func(m string) string {
+		groups := envRe.FindStringSubmatch(m)
+		if v, ok := lookup(groups[1]); ok {
+			return v
+		}
+		if groups[2] != "" {
+			return groups[3]
+		}
+		missing = append(missing, groups[1])
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+	return res, nil
+}
+
+// LoadConfig reads the config file into the map of flag name -> value, in the form accepted by the flag
+func LoadConfig(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := ParseConfig(data, configFormat(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// ParseConfig parses content of the config file of the format ("yaml" or "toml")
+func ParseConfig(data []byte, format string) (map[string]string, error) {
+	var raw map[string]interface{}
+	switch format {
+	case "toml":
+		tree, err := toml.LoadBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		raw = tree.ToMap()
+	case "yaml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format of config file: %s", format)
+	}
+	values := map[string]string{}
+	if err := flatten("", raw, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flatten(prefix string, raw map[string]interface{}, values map[string]string) error {
+	for k, v := range raw {
+		name := k
+		if prefix != "" {
+			name = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(name, v, values); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(name, item)
+				if err != nil {
+					return err
+				}
+				items[i] = s
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			s, err := scalar(name, v)
+			if err != nil {
+				return err
+			}
+			values[name] = s
+		}
+		if name == ConfigFlagName || name == PrintEffectiveConfigFlagName {
+			return fmt.Errorf("flag %q can't be set in the config file", name)
+		}
+	}
+	return nil
+}
+
+func scalar(name string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("flag %q: empty value", name)
+	case string:
+		s, err := Interpolate(v, os.LookupEnv)
+		if err != nil {
+			return "", fmt.Errorf("flag %q: %w", name, err)
+		}
+		return s, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("flag %q: value of type %T is not supported, expected string, number, bool or list of them", name, v)
+	}
+}
+
+func configFormat(path string) string {
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		return "toml"
+	}
+	return "yaml"
+}
+
+// FlagSetter abstracts flag sets of cobra and urfave/cli
+type FlagSetter interface {
+	// IsKnown returns false if there is no such flag
+	IsKnown(name string) bool
+	// IsSet returns true if the flag was given on the command line
+	IsSet(name string) bool
+	Set(name, value string) error
+}
+
+// ApplyConfig sets flags, which were not given on the command line, from the config values.
+// All unknown and invalid values are reported in one error.
+func ApplyConfig(flags FlagSetter, values map[string]string, source string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		if !flags.IsKnown(name) {
+			problems = append(problems, fmt.Sprintf("unknown flag %q", name))
+			continue
+		}
+		if flags.IsSet(name) {
+			continue
+		}
+		if err := flags.Set(name, values[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q of flag %q: %v", values[name], name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s:\n\t%s", source, strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// PrintConfig writes the flag values in the format of the config file
+func PrintConfig(w io.Writer, values map[string]string, configPath string) error {
+	var buf bytes.Buffer
+	if configFormat(configPath) == "toml" {
+		data, err := toml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		if err := enc.Encode(values); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// urfaveFlags adapts flags of the urfave/cli application
+type urfaveFlags struct {
+	ctx   *cli.Context
+	names map[string]string // any name of the flag -> the first one
+}
+
+func newUrfaveFlags(ctx *cli.Context) *urfaveFlags {
+	f := &urfaveFlags{ctx: ctx, names: map[string]string{}}
+	for _, flag := range ctx.App.Flags {
+		names := strings.Split(flag.GetName(), ",")
+		for _, name := range names {
+			f.names[strings.TrimSpace(name)] = strings.TrimSpace(names[0])
+		}
+	}
+	return f
+}
+
+func (f *urfaveFlags) IsKnown(name string) bool { _, ok := f.names[name]; return ok }
+func (f *urfaveFlags) IsSet(name string) bool   { return f.ctx.GlobalIsSet(f.names[name]) }
+func (f *urfaveFlags) Set(name, value string) error {
+	return f.ctx.GlobalSet(f.names[name], value)
+}
+
+// excludedFromConfig are flags, which are not printed as part of the effective config
+var excludedFromConfig = map[string]bool{ConfigFlagName: true, PrintEffectiveConfigFlagName: true, "help": true, "version": true}
+
+// ApplyConfigUrfave applies the file given with --config to the flags of the application, and handles
+// --print-effective-config by returning ErrEffectiveConfigPrinted. Both flags must be among the flags of the application.
+func ApplyConfigUrfave(ctx *cli.Context) error {
+	path := ctx.GlobalString(ConfigFlagName)
+	if path != "" {
+		values, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		if err = ApplyConfig(newUrfaveFlags(ctx), values, path); err != nil {
+			return err
+		}
+	}
+	if !ctx.GlobalBool(PrintEffectiveConfigFlagName) {
+		return nil
+	}
+	effective := map[string]string{}
+	for _, flag := range ctx.App.Flags {
+		name := strings.TrimSpace(strings.Split(flag.GetName(), ",")[0])
+		if excludedFromConfig[name] {
+			continue
+		}
+		if v, ok := ctx.GlobalGeneric(name).(goflag.Value); ok {
+			effective[name] = v.String()
+		}
+	}
+	if err := PrintConfig(os.Stdout, effective, path); err != nil {
+		return err
+	}
+	return ErrEffectiveConfigPrinted
+}
+
+// pflagFlags adapts the flag set of the cobra command
+type pflagFlags struct{ fs *pflag.FlagSet }
+
+func (f pflagFlags) IsKnown(name string) bool { return f.fs.Lookup(name) != nil }
+func (f pflagFlags) IsSet(name string) bool   { return f.fs.Changed(name) }
+func (f pflagFlags) Set(name, value string) error {
+	return f.fs.Set(name, value)
+}
+
+// ApplyConfigCobra applies the file given with --config to the flags of the command, and handles
+// --print-effective-config by returning ErrEffectiveConfigPrinted. Both flags must be among the flags of the command.
+func ApplyConfigCobra(fs *pflag.FlagSet) error {
+	path, err := fs.GetString(ConfigFlagName)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		values, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		if err = ApplyConfig(pflagFlags{fs}, values, path); err != nil {
+			return err
+		}
+	}
+	if print, _ := fs.GetBool(PrintEffectiveConfigFlagName); !print {
+		return nil
+	}
+	effective := map[string]string{}
+	fs.VisitAll(func(f *pflag.Flag) {
+		if excludedFromConfig[f.Name] {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			effective[f.Name] = strings.Join(slice.GetSlice(), ",")
+			return
+		}
+		effective[f.Name] = f.Value.String()
+	})
+	if err := PrintConfig(os.Stdout, effective, path); err != nil {
+		return err
+	}
+	return ErrEffectiveConfigPrinted
+}
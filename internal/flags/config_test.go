@@ -0,0 +1,101 @@
+package flags
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"A": "1", "EMPTY": ""}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+
+	s, err := Interpolate("a=${A} b=${B:-2} e=${EMPTY:-3} $HOME", lookup)
+	require.NoError(t, err)
+	require.Equal(t, "a=1 b=2 e= $HOME", s)
+
+	_, err = Interpolate("${B} ${C}", lookup)
+	require.EqualError(t, err, "environment variables are not set: B, C")
+}
+
+func TestParseConfig(t *testing.T) {
+	expected := map[string]string{"datadir": "/data", "http.port": "8545", "http.api": "eth,erigon", "ws": "true", "rpc.gascap": "100"}
+
+	yamlValues, err := ParseConfig([]byte(`
+datadir: /data
+http:
+  port: 8545
+  api: [eth, erigon]
+ws: true
+rpc.gascap: 100
+`), "yaml")
+	require.NoError(t, err)
+	require.Equal(t, expected, yamlValues)
+
+	tomlValues, err := ParseConfig([]byte(`
+datadir = "/data"
+ws = true
+"rpc.gascap" = 100
+[http]
+port = 8545
+api = ["eth", "erigon"]
+`), "toml")
+	require.NoError(t, err)
+	require.Equal(t, expected, tomlValues)
+
+	_, err = ParseConfig([]byte("http: {port: {a: [{b: 1}]}}"), "yaml")
+	require.Error(t, err)
+	_, err = ParseConfig([]byte("config: other.yaml"), "yaml")
+	require.Error(t, err)
+}
+
+func TestParseConfigInterpolate(t *testing.T) {
+	os.Setenv("ERIGON_TEST_DIR", "/data\"\nws = true")
+	defer os.Unsetenv("ERIGON_TEST_DIR")
+	values, err := ParseConfig([]byte(`
+# ${ERIGON_TEST_UNSET} is not expanded in comments
+datadir = "${ERIGON_TEST_DIR}"
+"http.addr" = "${ERIGON_TEST_UNSET:-localhost}"
+`), "toml")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"datadir": "/data\"\nws = true", "http.addr": "localhost"}, values)
+
+	_, err = ParseConfig([]byte("datadir: ${ERIGON_TEST_UNSET}"), "yaml")
+	require.Error(t, err)
+}
+
+func TestPrintConfig(t *testing.T) {
+	values := map[string]string{"datadir": `C:\data "erigon"`, "http.api": "eth,erigon", "http.port": "8545"}
+	for _, path := range []string{"erigon.toml", "erigon.yaml"} {
+		var buf bytes.Buffer
+		require.NoError(t, PrintConfig(&buf, values, path))
+		parsed, err := ParseConfig(buf.Bytes(), configFormat(path))
+		require.NoError(t, err)
+		require.Equal(t, values, parsed, path)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	port := fs.Int("http.port", 8545, "")
+	api := fs.StringSlice("http.api", []string{"eth"}, "")
+	addr := fs.String("http.addr", "localhost", "")
+	require.NoError(t, fs.Parse([]string{"--http.addr", "0.0.0.0"}))
+
+	// command line takes precedence
+	require.NoError(t, ApplyConfig(pflagFlags{fs}, map[string]string{"http.port": "1", "http.api": "eth,debug", "http.addr": "1.1.1.1"}, "test.yaml"))
+	require.Equal(t, 1, *port)
+	require.Equal(t, []string{"eth", "debug"}, *api)
+	require.Equal(t, "0.0.0.0", *addr)
+
+	// all problems are reported at once
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Int("http.port", 8545, "")
+	err := ApplyConfig(pflagFlags{fs}, map[string]string{"http.port": "x", "unknown": "1"}, "test.yaml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid value "x" of flag "http.port"`)
+	require.Contains(t, err.Error(), `unknown flag "unknown"`)
+}
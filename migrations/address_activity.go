@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+)
+
+// addressActivity fills AddressActivity for blocks executed before the Execution stage started to maintain it.
+// Activity is taken from CallFromIndex/CallToIndex and, for blocks not indexed yet, from CallTraceSet - they list
+// the same addresses, including block and uncle beneficiaries. Found blocks are merged with the recorded ones
+// (the lowest first, the highest last), so it's safe to apply again after interruption.
+// If call traces are pruned, the first activity is the first one in the kept history.
+var addressActivity = Migration{
+	Name: "address_activity",
+	Up: func(db kv.RwDB, tmpdir string, progress []byte, BeforeCommit Callback) (err error) {
+		tx, err := db.BeginRw(context.Background())
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, table := range []string{kv.CallFromIndex, kv.CallToIndex} {
+			if err = addressActivityFromIndex(tx, table); err != nil {
+				return err
+			}
+		}
+		indexed, err := stages.GetStageProgress(tx, stages.CallTraces)
+		if err != nil {
+			return err
+		}
+		if err = tx.ForEach(kv.CallTraceSet, dbutils.EncodeBlockNumber(indexed+1), func(k, v []byte) error {
+			blockNum := binary.BigEndian.Uint64(k)
+			return mergeAddressActivity(tx, common.BytesToAddress(v[:common.AddressLength]), blockNum, blockNum)
+		}); err != nil {
+			return err
+		}
+
+		if err := BeforeCommit(tx, nil, true); err != nil {
+			return err
+		}
+		return tx.Commit()
+	},
+}
+
+// addressActivityFromIndex merges the lowest and the highest block of every address in the index of call traces.
+// Chunks of one address follow each other, ordered by their last block.
+func addressActivityFromIndex(tx kv.RwTx, table string) error {
+	var addr common.Address
+	var first, last uint64
+	var found bool
+	if err := tx.ForEach(table, nil, func(k, v []byte) error {
+		bm := roaring64.New()
+		if _, err := bm.ReadFrom(bytes.NewReader(v)); err != nil {
+			return err
+		}
+		if bm.IsEmpty() {
+			return nil
+		}
+		if found && bytes.Equal(addr[:], k[:common.AddressLength]) {
+			last = bm.Maximum()
+			return nil
+		}
+		if found {
+			if err := mergeAddressActivity(tx, addr, first, last); err != nil {
+				return err
+			}
+		}
+		addr = common.BytesToAddress(k[:common.AddressLength])
+		first, last, found = bm.Minimum(), bm.Maximum(), true
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	return mergeAddressActivity(tx, addr, first, last)
+}
+
+func mergeAddressActivity(tx kv.RwTx, addr common.Address, first, last uint64) error {
+	recordedFirst, recordedLast, found, err := rawdb.ReadAddressActivity(tx, addr)
+	if err != nil {
+		return err
+	}
+	if found {
+		if recordedFirst <= first && recordedLast >= last {
+			return nil
+		}
+		if recordedFirst < first {
+			first = recordedFirst
+		}
+		if recordedLast > last {
+			last = recordedLast
+		}
+	}
+	return rawdb.WriteAddressActivity(tx, addr, first, last)
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressActivity(t *testing.T) {
+	require := require.New(t)
+	db := ethdb.NewTestDB(t)
+
+	a, b, c := common.Address{1}, common.Address{2}, common.Address{3}
+	chunk := func(addr common.Address, blocks ...uint64) ([]byte, []byte) {
+		bm := roaring64.BitmapOf(blocks...)
+		var buf bytes.Buffer
+		_, err := bm.WriteTo(&buf)
+		require.NoError(err)
+		return append(common.CopyBytes(addr[:]), dbutils.EncodeBlockNumber(bm.Maximum())...), buf.Bytes()
+	}
+	err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		for _, put := range []struct {
+			table  string
+			blocks []uint64
+			addr   common.Address
+		}{
+			{kv.CallFromIndex, []uint64{3, 5}, a},
+			{kv.CallFromIndex, []uint64{7, 9}, a},
+			{kv.CallToIndex, []uint64{2}, a},
+			{kv.CallToIndex, []uint64{4, 6}, b},
+		} {
+			k, v := chunk(put.addr, put.blocks...)
+			if err := tx.Put(put.table, k, v); err != nil {
+				return err
+			}
+		}
+		if err := stages.SaveStageProgress(tx, stages.CallTraces, 9); err != nil {
+			return err
+		}
+		// not indexed yet
+		v := append(common.CopyBytes(b[:]), 2)
+		if err := tx.Put(kv.CallTraceSet, dbutils.EncodeBlockNumber(10), v); err != nil {
+			return err
+		}
+		v = append(common.CopyBytes(c[:]), 1)
+		if err := tx.Put(kv.CallTraceSet, dbutils.EncodeBlockNumber(11), v); err != nil {
+			return err
+		}
+		// recorded by the Execution stage already
+		return rawdb.WriteAddressActivity(tx, c, 11, 12)
+	})
+	require.NoError(err)
+
+	migrator := NewMigrator(kv.ChainDB)
+	migrator.Migrations = []Migration{addressActivity}
+	require.NoError(migrator.Apply(db, t.TempDir()))
+
+	err = db.View(context.Background(), func(tx kv.Tx) error {
+		for _, expect := range []struct {
+			addr        common.Address
+			first, last uint64
+		}{{a, 2, 9}, {b, 4, 10}, {c, 11, 12}} {
+			first, last, found, err := rawdb.ReadAddressActivity(tx, expect.addr)
+			require.NoError(err)
+			require.True(found)
+			require.Equal(expect.first, first, "%x", expect.addr)
+			require.Equal(expect.last, last, "%x", expect.addr)
+		}
+		return nil
+	})
+	require.NoError(err)
+}
@@ -36,6 +36,8 @@ var migrations = map[kv.Label][]Migration{
 		dbSchemaVersion,
 		fixSequences,
 		storageMode,
+		sendersCompaction,
+		addressActivity,
 	},
 	kv.TxPoolDB: {},
 	kv.SentryDB: {},
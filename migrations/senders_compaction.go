@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/etl"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+)
+
+// sendersCompaction re-encodes senders stored as concatenated addresses into packed arrays, see rawdb.EncodeSenders.
+// Both formats are readable, so it's safe to apply again after interruption: packed values are skipped.
+var sendersCompaction = Migration{
+	Name: "senders_compaction",
+	Up: func(db kv.RwDB, tmpdir string, progress []byte, BeforeCommit Callback) (err error) {
+		tx, err := db.BeginRw(context.Background())
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		logPrefix := "senders_compaction"
+		collector := etl.NewCollector(tmpdir+"senders", etl.NewSortableBuffer(etl.BufferOptimalSize*4))
+		defer collector.Close(logPrefix)
+		if err = tx.ForEach(kv.Senders, nil, func(k, v []byte) error {
+			if len(v) < common.AddressLength || len(v)%common.AddressLength != 0 {
+				return nil // empty or packed already
+			}
+			senders, err := rawdb.DecodeSenders(v)
+			if err != nil {
+				return err
+			}
+			return collector.Collect(k, rawdb.EncodeSenders(senders))
+		}); err != nil {
+			return err
+		}
+		if err = collector.Load(logPrefix, tx, kv.Senders, etl.IdentityLoadFunc, etl.TransformArgs{}); err != nil {
+			return err
+		}
+
+		if err := BeforeCommit(tx, nil, true); err != nil {
+			return err
+		}
+		return tx.Commit()
+	},
+}
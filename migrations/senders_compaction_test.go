@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendersCompaction(t *testing.T) {
+	require := require.New(t)
+	db := memdb.NewTestDB(t)
+
+	a, b := common.Address{1}, common.Address{2}
+	err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := uint64(0); i < 10; i++ {
+			var legacy []byte
+			for j := uint64(0); j < i; j++ {
+				if j%2 == 0 {
+					legacy = append(legacy, a[:]...)
+				} else {
+					legacy = append(legacy, b[:]...)
+				}
+			}
+			if err := tx.Put(kv.Senders, dbutils.BlockBodyKey(i, common.Hash{uint8(i)}), legacy); err != nil {
+				return err
+			}
+		}
+		// written in the new format already
+		return rawdb.WriteSenders(tx, common.Hash{10}, 10, []common.Address{b, b})
+	})
+	require.NoError(err)
+
+	migrator := NewMigrator(kv.ChainDB)
+	migrator.Migrations = []Migration{sendersCompaction}
+	require.NoError(migrator.Apply(db, t.TempDir()))
+
+	err = db.View(context.Background(), func(tx kv.Tx) error {
+		for i := uint64(0); i < 10; i++ {
+			v, err := tx.GetOne(kv.Senders, dbutils.BlockBodyKey(i, common.Hash{uint8(i)}))
+			require.NoError(err)
+			if i > 0 {
+				require.NotZero(len(v) % common.AddressLength)
+			}
+			senders, err := rawdb.ReadSenders(tx, common.Hash{uint8(i)}, i)
+			require.NoError(err)
+			require.Len(senders, int(i))
+			for j, sender := range senders {
+				if j%2 == 0 {
+					require.Equal(a, sender)
+				} else {
+					require.Equal(b, sender)
+				}
+			}
+		}
+		senders, err := rawdb.ReadSenders(tx, common.Hash{10}, 10)
+		require.NoError(err)
+		require.Equal([]common.Address{b, b}, senders)
+		return nil
+	})
+	require.NoError(err)
+
+	// apply again
+	err = db.Update(context.Background(), func(tx kv.RwTx) error {
+		return tx.Delete(kv.Migrations, []byte(sendersCompaction.Name), nil)
+	})
+	require.NoError(err)
+	require.NoError(migrator.Apply(db, t.TempDir()))
+}
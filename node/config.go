@@ -24,7 +24,9 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/erigon-lib/kv"
 
 	"github.com/ledgerwatch/erigon/common"
@@ -148,6 +150,27 @@ type Config struct {
 	// empty string means not to start the listener
 	PrivateApiAddr      string
 	PrivateApiRateLimit uint32
+	// Size of the in-memory log of recent changes streamed to rpcdaemon read replicas, 0 - replication is disabled
+	PrivateApiReplicationLog datasize.ByteSize
+	// Limits of resources of one remote transaction, 0 - no limit
+	PrivateApiMaxCursors    int
+	PrivateApiTxLifetime    time.Duration
+	PrivateApiTxIdleTimeout time.Duration
+	PrivateApiPinLifetime   time.Duration
+	// Buckets, which remote transactions can read: only allowed ones, if any, except denied ones
+	PrivateApiAllowBuckets []string
+	PrivateApiDenyBuckets  []string
+	// Clients with this token can manage auxiliary tables remotely, empty - nobody
+	PrivateApiAdminToken string
+	// Keepalive, limits of messages and flow control windows of connections, 0 - defaults of gRPC
+	PrivateApiKeepaliveTime    time.Duration
+	PrivateApiKeepaliveTimeout time.Duration
+	PrivateApiKeepaliveMinTime time.Duration
+	PrivateApiConnIdle         time.Duration
+	PrivateApiMaxRecvMsg       datasize.ByteSize
+	PrivateApiMaxSendMsg       datasize.ByteSize
+	PrivateApiWindowSize       datasize.ByteSize
+	PrivateApiConnWindowSize   datasize.ByteSize
 
 	staticNodesWarning  bool
 	trustedNodesWarning bool
@@ -30,6 +30,7 @@ import (
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/migrations"
 	"github.com/ledgerwatch/erigon/p2p"
 	"github.com/ledgerwatch/erigon/rpc"
@@ -500,8 +501,10 @@ func OpenDatabase(config *Config, logger log.Logger, label kv.Label) (kv.RwDB, e
 	}
 	var db kv.RwDB
 	if config.DataDir == "" {
-		db = memdb.New()
-		return db, nil
+		if label == kv.ChainDB {
+			return ethdb.NewMemDB(), nil
+		}
+		return memdb.New(), nil
 	}
 	dbPath := config.ResolvePath(name)
 
@@ -509,6 +512,9 @@ func OpenDatabase(config *Config, logger log.Logger, label kv.Label) (kv.RwDB, e
 	log.Info("Opening Database", "label", name, "path", dbPath)
 	openFunc = func(exclusive bool) (kv.RwDB, error) {
 		opts := mdbx.NewMDBX(logger).Path(dbPath).Label(label).DBVerbosity(config.DatabaseVerbosity)
+		if label == kv.ChainDB {
+			opts = opts.WithTablessCfg(ethdb.WithErigonTables)
+		}
 		if exclusive {
 			opts = opts.Exclusive()
 		}
@@ -540,6 +546,10 @@ func OpenDatabase(config *Config, logger log.Logger, label kv.Label) (kv.RwDB, e
 			return nil, err
 		}
 	}
+	if err = ethdb.OpenAuxiliaryTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return db, nil
 }
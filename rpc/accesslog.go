@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// accessRecord is the line of the access log
+type accessRecord struct {
+	Time       time.Time       `json:"time"` // when the call was received
+	Method     string          `json:"method"`
+	ParamsHash string          `json:"paramsHash"`       // first 16 hex digits of SHA-256 of params, tells repeated calls
+	Params     json.RawMessage `json:"params,omitempty"` // only of slow calls
+	Seconds    float64         `json:"seconds"`          // since the call was received, including waiting for the scheduler
+	Bytes      int             `json:"bytes"`            // of the result
+	Client     string          `json:"client"`           // address of the client, without the port
+	Key        string          `json:"key,omitempty"`    // see KeyID
+	Error      string          `json:"error,omitempty"`
+	Code       int             `json:"code,omitempty"` // of the error
+	Slow       bool            `json:"slow,omitempty"`
+}
+
+// AccessLog logs calls of the server: every call is appended to the writer as a JSON line with the method, the hash of
+// params, the duration, the size of the result, the client and the error. Calls longer than the slow threshold are
+// logged with full params also to the log of the daemon, and counted in rpc_slow_calls{method="..."} metrics.
+type AccessLog struct {
+	slow time.Duration // 0 - no slow log
+	log  log.Logger
+
+	mu sync.Mutex
+	w  io.Writer // nil - only the slow log
+}
+
+// NewAccessLog creates the log of calls to w (nil - only slow calls are logged), slow - threshold of slow calls,
+// 0 - they are not logged
+func NewAccessLog(w io.Writer, slow time.Duration) *AccessLog {
+	return &AccessLog{w: w, slow: slow, log: log.New("rpc", "access")}
+}
+
+// Middleware returns the middleware of the server, which logs calls. Register it before other middlewares, to log calls
+// refused or served by them too.
+func (l *AccessLog) Middleware() Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+			result, err := next(ctx, call)
+			l.record(call, time.Since(call.Start), len(result)+call.Streamed, err)
+			return result, err
+		}
+	}
+}
+
+func (l *AccessLog) record(call *Call, duration time.Duration, size int, err error) {
+	slow := l.slow > 0 && duration >= l.slow
+	if l.w == nil && !slow {
+		return
+	}
+	h := sha256.Sum256(call.Params)
+	r := accessRecord{
+		Time:       call.Start.UTC(),
+		Method:     call.Method,
+		ParamsHash: hex.EncodeToString(h[:8]),
+		Seconds:    duration.Seconds(),
+		Bytes:      size,
+		Client:     call.Remote,
+		Key:        KeyID(call.APIKey),
+		Slow:       slow,
+	}
+	if host, _, splitErr := net.SplitHostPort(call.Remote); splitErr == nil {
+		r.Client = host
+	}
+	if err != nil {
+		r.Error = err.Error()
+		var rpcErr Error
+		if errors.As(err, &rpcErr) {
+			r.Code = rpcErr.ErrorCode()
+		}
+	}
+	if slow {
+		r.Params = call.Params
+		metrics.GetOrCreateCounter(`rpc_slow_calls{method="` + call.Method + `"}`).Inc()
+		l.log.Warn("Slow call", "method", r.Method, "duration", duration, "client", r.Client, "bytes", size, "err", err, "params", string(call.Params))
+	}
+	if l.w == nil {
+		return
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		l.log.Warn("could not encode the call", "method", r.Method, "err", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err = l.w.Write(append(line, '\n')); err != nil {
+		l.log.Warn("could not write the access log", "err", err)
+	}
+}
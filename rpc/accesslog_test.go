@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	server := newTestServer()
+	var buf bytes.Buffer
+	server.Use(NewAccessLog(&buf, 50*time.Millisecond).Middleware())
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetHeader(apiKeyHeader, "a")
+	_ = client.Call(nil, "test_echo", "x", 1)
+	_ = client.Call(nil, "test_echo", "x", 1)
+	_ = client.Call(nil, "test_returnError")
+	_ = client.Call(nil, "test_sleep", 100*time.Millisecond)
+
+	var records []accessRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r accessRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	echo := records[0]
+	if echo.Method != "test_echo" || echo.Client != "127.0.0.1" || echo.Key != KeyID("a") || echo.Bytes != len(`{"String":"x","Int":1,"Args":null}`) || echo.Time.IsZero() {
+		t.Errorf("unexpected record %+v", echo)
+	}
+	if echo.Params != nil || echo.Slow {
+		t.Errorf("params of fast calls must not be logged: %+v", echo)
+	}
+	if records[1].ParamsHash != echo.ParamsHash || records[2].ParamsHash == echo.ParamsHash {
+		t.Errorf("hashes of params must tell repeated calls: %s %s %s", echo.ParamsHash, records[1].ParamsHash, records[2].ParamsHash)
+	}
+	if failed := records[2]; failed.Error != "testError" || failed.Code != 444 {
+		t.Errorf("unexpected record of the error %+v", failed)
+	}
+	if slow := records[3]; !slow.Slow || string(slow.Params) != `[100000000]` || slow.Seconds < 0.1 {
+		t.Errorf("unexpected record of the slow call %+v", slow)
+	}
+}
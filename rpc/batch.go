@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BatchLimits limit batch requests, zero values - no limits
+type BatchLimits struct {
+	MaxCalls int    // calls of one batch, bigger batches are refused as a whole
+	MaxGas   uint64 // gas of all calls of one batch, which execute EVM (eth_call), see BatchGasFromContext
+}
+
+// SetBatchLimits sets limits of batch requests. Must be called before the server starts serving.
+func (s *Server) SetBatchLimits(limits BatchLimits) {
+	s.batchLimits = limits
+}
+
+// BatchGasError is the error of calls of a batch, which exhausted the gas of the batch. Its code is -32005
+// ("limit exceeded" of EIP-1474).
+type BatchGasError struct {
+	Limit uint64
+}
+
+func (e *BatchGasError) ErrorCode() int { return -32005 }
+
+func (e *BatchGasError) Error() string {
+	return fmt.Sprintf("gas limit of the batch exceeded: calls of one batch are limited to %d gas", e.Limit)
+}
+
+// BatchGas is the gas shared by calls of one batch. Calls reserve gas before execution and refund the unused gas
+// after, calls executed in parallel can't take more than the limit together.
+type BatchGas struct {
+	limit uint64
+
+	mu   sync.Mutex
+	left uint64
+}
+
+type batchGasKey struct{}
+
+// BatchGasFromContext returns the gas of the batch of the call, nil - the call is not a part of a batch or the gas of
+// batches is not limited
+func BatchGasFromContext(ctx context.Context) *BatchGas {
+	gas, _ := ctx.Value(batchGasKey{}).(*BatchGas)
+	return gas
+}
+
+func withBatchGas(ctx context.Context, limit uint64) context.Context {
+	if limit == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, batchGasKey{}, &BatchGas{limit: limit, left: limit})
+}
+
+// Reserve takes up to gas from the batch, BatchGasError - no gas is left
+func (b *BatchGas) Reserve(gas uint64) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.left == 0 {
+		return 0, &BatchGasError{Limit: b.limit}
+	}
+	if gas > b.left {
+		gas = b.left
+	}
+	b.left -= gas
+	return gas, nil
+}
+
+// Refund returns the reserved gas, which was not used
+func (b *BatchGas) Refund(gas uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.left += gas
+}
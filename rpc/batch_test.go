@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type batchGasService struct{}
+
+func (batchGasService) Burn(ctx context.Context, gas, used uint64) (uint64, error) {
+	batchGas := BatchGasFromContext(ctx)
+	if batchGas == nil {
+		return gas, nil
+	}
+	reserved, err := batchGas.Reserve(gas)
+	if err != nil {
+		return 0, err
+	}
+	if used > reserved {
+		used = reserved
+	}
+	batchGas.Refund(reserved - used)
+	return reserved, nil
+}
+
+func TestBatchLimits(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchLimits(BatchLimits{MaxCalls: 2})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	post := func(body string) string {
+		resp, err := http.Post(ts.URL, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		reply, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(reply)
+	}
+
+	reply := post(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["y",2]}]`)
+	if !strings.Contains(reply, `"id":1`) || !strings.Contains(reply, `"id":2`) {
+		t.Fatalf("batch within the limit is not served: %s", reply)
+	}
+	reply = post(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["y",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["z",3]}]`)
+	if !strings.Contains(reply, "batch too large: 3 calls, the limit is 2") || strings.Contains(reply, `"result"`) {
+		t.Fatalf("batch above the limit is not refused: %s", reply)
+	}
+}
+
+func TestBatchGas(t *testing.T) {
+	server := NewServer(1) // calls of batches are executed sequentially
+	defer server.Stop()
+	if err := server.RegisterName("gas", batchGasService{}); err != nil {
+		t.Fatal(err)
+	}
+	server.SetBatchLimits(BatchLimits{MaxGas: 100})
+	client := DialInProc(server)
+	defer client.Close()
+
+	var single uint64
+	if err := client.Call(&single, "gas_burn", 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if single != 1000 {
+		t.Fatalf("gas of a single call is limited: %d", single)
+	}
+
+	// unused gas is returned to the batch, the last call finds no gas left
+	results := make([]uint64, 4)
+	batch := []BatchElem{
+		{Method: "gas_burn", Args: []interface{}{60, 10}, Result: &results[0]},
+		{Method: "gas_burn", Args: []interface{}{60, 60}, Result: &results[1]},
+		{Method: "gas_burn", Args: []interface{}{60, 30}, Result: &results[2]},
+		{Method: "gas_burn", Args: []interface{}{60, 0}, Result: &results[3]},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint64{60, 60, 30} {
+		if batch[i].Error != nil {
+			t.Fatalf("call %d: %v", i, batch[i].Error)
+		}
+		if results[i] != want {
+			t.Fatalf("call %d reserved %d, want %d", i, results[i], want)
+		}
+	}
+	if batch[3].Error == nil || batch[3].Error.(Error).ErrorCode() != -32005 {
+		t.Fatalf("call above the gas of the batch: %v", batch[3].Error)
+	}
+}
@@ -78,6 +78,11 @@ type Client struct {
 	isHTTP          bool
 	services        *serviceRegistry
 	methodAllowList AllowList
+	scheduler       *Scheduler
+	middlewares     []Middleware
+
+	batchConcurrency uint // of handlers of batches sent by the other side, 0 - default
+	batchLimits      BatchLimits
 
 	idCounter uint32
 
@@ -112,7 +117,14 @@ type clientConn struct {
 
 func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.WithValue(context.Background(), clientContextKey{}, c)
-	handler := newHandler(ctx, conn, c.idgen, c.services, c.methodAllowList, 50)
+	batchConcurrency := c.batchConcurrency
+	if batchConcurrency == 0 {
+		batchConcurrency = 50
+	}
+	handler := newHandler(ctx, conn, c.idgen, c.services, c.methodAllowList, batchConcurrency)
+	handler.scheduler = c.scheduler
+	handler.middlewares = c.middlewares
+	handler.batchLimits = c.batchLimits
 	return &clientConn{conn, handler}
 }
 
@@ -196,27 +208,31 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), nil, nil, 0, BatchLimits{})
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, scheduler *Scheduler, middlewares []Middleware, batchConcurrency uint, batchLimits BatchLimits) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
-		idgen:       idgen,
-		isHTTP:      isHTTP,
-		services:    services,
-		writeConn:   conn,
-		close:       make(chan struct{}),
-		closing:     make(chan struct{}),
-		didClose:    make(chan struct{}),
-		reconnected: make(chan ServerCodec),
-		readOp:      make(chan readOp),
-		readErr:     make(chan error),
-		reqInit:     make(chan *requestOp),
-		reqSent:     make(chan error, 1),
-		reqTimeout:  make(chan *requestOp),
+		idgen:            idgen,
+		isHTTP:           isHTTP,
+		services:         services,
+		scheduler:        scheduler,
+		middlewares:      middlewares,
+		batchConcurrency: batchConcurrency,
+		batchLimits:      batchLimits,
+		writeConn:        conn,
+		close:            make(chan struct{}),
+		closing:          make(chan struct{}),
+		didClose:         make(chan struct{}),
+		reconnected:      make(chan ServerCodec),
+		readOp:           make(chan readOp),
+		readErr:          make(chan error),
+		reqInit:          make(chan *requestOp),
+		reqSent:          make(chan error, 1),
+		reqTimeout:       make(chan *requestOp),
 	}
 	if !isHTTP {
 		go c.dispatch(conn)
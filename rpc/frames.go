@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ugorji/go/codec"
+)
+
+// StreamFormat is the format of the result of a streamable method. By default (FormatJSON) the result is a part
+// of the JSON-RPC response. In other formats the result is a sequence of frames sent over HTTP without the JSON-RPC
+// envelope, each one as soon as it's produced, so neither side has to buffer big results (f.e. traces).
+// An error of the method is sent as the last frame {"error": {"code": ..., "message": ...}}.
+type StreamFormat string
+
+const (
+	FormatJSON  StreamFormat = ""
+	FormatJSONL StreamFormat = "jsonl" // JSON objects separated by newlines
+	FormatCBOR  StreamFormat = "cbor"  // sequence of CBOR items, RFC 8742
+)
+
+var streamContentTypes = map[StreamFormat]string{
+	FormatJSONL: "application/x-ndjson",
+	FormatCBOR:  "application/cbor-seq",
+}
+
+// ParseStreamFormat parses the format requested by a parameter of the method
+func ParseStreamFormat(s string) (StreamFormat, error) {
+	switch f := StreamFormat(strings.ToLower(s)); f {
+	case "json", FormatJSON:
+		return FormatJSON, nil
+	case FormatJSONL, FormatCBOR:
+		return f, nil
+	default:
+		return FormatJSON, fmt.Errorf("unknown stream format %q, supported: json, jsonl, cbor", s)
+	}
+}
+
+// streamFormatOfAccept returns the first frame format in the Accept header of the HTTP request
+func streamFormatOfAccept(accept string) StreamFormat {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for f, contentType := range streamContentTypes {
+			if mediaType == contentType {
+				return f
+			}
+		}
+	}
+	return FormatJSON
+}
+
+type framesKey struct{}
+
+// frames is the state of the HTTP response of a single (not batch) request, which may be switched to frames
+type frames struct {
+	w        http.ResponseWriter
+	accepted StreamFormat
+
+	stream      *jsoniter.Stream // of the streamable method, set before the method is called
+	envelopeLen int              // of the JSON-RPC envelope written to the stream before the result
+	writer      *FrameWriter     // not nil after StartFrames
+}
+
+func contextWithFrames(ctx context.Context, w http.ResponseWriter, accepted StreamFormat) context.Context {
+	return context.WithValue(ctx, framesKey{}, &frames{w: w, accepted: accepted})
+}
+
+func framesFromContext(ctx context.Context) *frames {
+	f, _ := ctx.Value(framesKey{}).(*frames)
+	return f
+}
+
+// framesStarted returns true if the response of the request was switched to frames
+func framesStarted(ctx context.Context) bool {
+	f := framesFromContext(ctx)
+	return f != nil && f.writer != nil
+}
+
+// AcceptedStreamFormat returns the format of frames the HTTP client asked for in the Accept header
+func AcceptedStreamFormat(ctx context.Context) StreamFormat {
+	if f := framesFromContext(ctx); f != nil {
+		return f.accepted
+	}
+	return FormatJSON
+}
+
+// StartFrames switches the response of the streamable method to frames of the given format. It must be called
+// before the method writes anything to the stream. Frames are supported only for single requests over HTTP.
+func StartFrames(ctx context.Context, format StreamFormat) (*FrameWriter, error) {
+	f := framesFromContext(ctx)
+	if f == nil || f.stream == nil {
+		return nil, errors.New("stream formats are supported only for single requests over HTTP")
+	}
+	if f.writer != nil {
+		return f.writer, nil
+	}
+	if len(f.stream.Buffer()) != f.envelopeLen {
+		return nil, errors.New("frames must be started before the result is written")
+	}
+	contentType, ok := streamContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("format %q has no frames", format)
+	}
+	f.stream.SetBuffer(f.stream.Buffer()[:0]) // drop the JSON-RPC envelope
+	f.w.Header().Set("content-type", contentType)
+	f.writer = &FrameWriter{format: format, stream: f.stream}
+	if flusher, ok := f.w.(http.Flusher); ok {
+		f.writer.flusher = flusher
+	}
+	return f.writer, nil
+}
+
+// FrameWriter sends frames of the result to the client
+type FrameWriter struct {
+	format  StreamFormat
+	stream  *jsoniter.Stream
+	flusher http.Flusher
+	cbor    *codec.Encoder
+}
+
+func (w *FrameWriter) Format() StreamFormat { return w.format }
+
+// WriteFrame sends one frame, given as a JSON object, to the client right away
+func (w *FrameWriter) WriteFrame(frame []byte) error {
+	switch w.format {
+	case FormatJSONL:
+		if _, err := w.stream.Write(frame); err != nil {
+			return err
+		}
+		if _, err := w.stream.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	case FormatCBOR:
+		// frames are produced as JSON, because tracers and methods already write JSON
+		v, err := decodeJSONFrame(frame)
+		if err != nil {
+			return err
+		}
+		if w.cbor == nil {
+			var handle codec.CborHandle
+			handle.Canonical = true
+			w.cbor = codec.NewEncoder(w.stream, &handle)
+		}
+		if err := w.cbor.Encode(v); err != nil {
+			return err
+		}
+	}
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+func (w *FrameWriter) writeError(err error) error {
+	frame, marshalErr := json.Marshal(struct {
+		Error *jsonError `json:"error"`
+	}{errorMessage(err).Error})
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return w.WriteFrame(frame)
+}
+
+// decodeJSONFrame decodes the frame keeping integers as integers, so they are not floats in CBOR
+func decodeJSONFrame(frame []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(frame))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return integers(v), nil
+}
+
+func integers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = integers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = integers(item)
+		}
+	}
+	return v
+}
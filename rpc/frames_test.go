@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ugorji/go/codec"
+)
+
+type framesService struct{}
+
+// Logs sends n frames in the requested format, or the usual JSON result
+func (framesService) Logs(ctx context.Context, n int, format *string, stream *jsoniter.Stream) error {
+	f := AcceptedStreamFormat(ctx)
+	if format != nil {
+		var err error
+		if f, err = ParseStreamFormat(*format); err != nil {
+			stream.WriteNil()
+			return err
+		}
+	}
+	if f == FormatJSON {
+		stream.WriteInt(n)
+		return nil
+	}
+	frames, err := StartFrames(ctx, f)
+	if err != nil {
+		stream.WriteNil()
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := frames.WriteFrame([]byte(`{"pc":` + strconv.Itoa(i) + `}`)); err != nil {
+			return err
+		}
+	}
+	return errors.New("out of gas")
+}
+
+func postFrames(t *testing.T, url, accept, body string) (string, []byte) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", contentType)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get("Content-Type"), respBody
+}
+
+func TestHTTPFrames(t *testing.T) {
+	s := NewServer(50)
+	defer s.Stop()
+	if err := s.RegisterName("test", framesService{}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// the format parameter
+	ct, body := postFrames(t, ts.URL, "", `{"jsonrpc":"2.0","id":1,"method":"test_logs","params":[2,"jsonl"]}`)
+	if ct != "application/x-ndjson" {
+		t.Fatalf("wrong content type %q", ct)
+	}
+	want := "{\"pc\":0}\n{\"pc\":1}\n{\"error\":{\"code\":-32000,\"message\":\"out of gas\"}}\n"
+	if string(body) != want {
+		t.Fatalf("wrong frames %q, want %q", body, want)
+	}
+
+	// the Accept header
+	ct, body = postFrames(t, ts.URL, "application/cbor-seq", `{"jsonrpc":"2.0","id":1,"method":"test_logs","params":[1]}`)
+	if ct != "application/cbor-seq" {
+		t.Fatalf("wrong content type %q", ct)
+	}
+	var handle codec.CborHandle
+	dec := codec.NewDecoderBytes(body, &handle)
+	var frame map[string]interface{}
+	if err := dec.Decode(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if pc, ok := frame["pc"].(uint64); !ok || pc != 0 {
+		t.Fatalf("wrong CBOR frame %v", frame)
+	}
+	frame = nil
+	if err := dec.Decode(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := frame["error"]; !ok {
+		t.Fatalf("expected the error frame, got %v", frame)
+	}
+
+	// the usual JSON-RPC response
+	_, body = postFrames(t, ts.URL, "", `{"jsonrpc":"2.0","id":1,"method":"test_logs","params":[3]}`)
+	if want := `{"jsonrpc":"2.0","id":1,"result":3}`; string(bytes.TrimSpace(body)) != want {
+		t.Fatalf("wrong response %s, want %s", body, want)
+	}
+
+	// frames can't be mixed with other responses of a batch
+	_, body = postFrames(t, ts.URL, "", `[{"jsonrpc":"2.0","id":1,"method":"test_logs","params":[1,"jsonl"]}]`)
+	if !bytes.Contains(body, []byte("supported only for single requests")) {
+		t.Fatalf("expected an error of the batch, got %s", body)
+	}
+}
@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The gRPC service serves methods of the server to internal consumers, which don't want to speak JSON. It's described
+// by hand and not generated from a .proto file, messages are well-known types:
+//
+//	service erigon.rpc.JSONRPC {
+//	  // request - {"method": "eth_getBalance", "params": ["0x...", "latest"]}, reply - the result of the method
+//	  rpc Call(google.protobuf.Struct) returns (google.protobuf.Value);
+//	}
+//
+// Errors of methods are returned as gRPC statuses: codes.Unimplemented for unknown methods, codes.InvalidArgument for
+// invalid params and codes.Unknown for others, with google.protobuf.Struct {"code": <JSON-RPC code>, "data": <data>}
+// in details. API keys and sessions are sent in x-api-key and x-session-id metadata.
+const (
+	grpcServiceName    = "erigon.rpc.JSONRPC"
+	grpcCallMethodName = "Call"
+	grpcCallMethod     = "/" + grpcServiceName + "/" + grpcCallMethodName
+	grpcProtoFile      = "erigon/rpc/jsonrpc.proto"
+)
+
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: grpcCallMethodName,
+			Handler:    grpcCallHandler,
+		},
+	},
+	Metadata: grpcProtoFile,
+}
+
+var registerProtoFile sync.Once
+
+// RegisterGRPC serves methods of the server on the gRPC server. Register the reflection service
+// (google.golang.org/grpc/reflection) too, to let tools like grpcurl discover it.
+func RegisterGRPC(g *grpc.Server, s *Server) {
+	registerProtoFile.Do(func() {
+		// the description of the service, for reflection
+		file := &descriptorpb.FileDescriptorProto{
+			Name:       proto.String(grpcProtoFile),
+			Package:    proto.String("erigon.rpc"),
+			Dependency: []string{"google/protobuf/struct.proto"},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("JSONRPC"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String(grpcCallMethodName),
+					InputType:  proto.String(".google.protobuf.Struct"),
+					OutputType: proto.String(".google.protobuf.Value"),
+				}},
+			}},
+			Syntax: proto.String("proto3"),
+		}
+		fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+		if err == nil {
+			err = protoregistry.GlobalFiles.RegisterFile(fd)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("invalid description of %s: %v", grpcServiceName, err))
+		}
+	})
+	g.RegisterService(&grpcServiceDesc, s)
+}
+
+func grpcCallHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(structpb.Struct)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).serveGRPC(ctx, req.(*structpb.Struct))
+	}
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: grpcCallMethod}, handler)
+}
+
+// serveGRPC executes the call by handlers of the server, as if it was sent over HTTP
+func (s *Server) serveGRPC(ctx context.Context, req *structpb.Struct) (*structpb.Value, error) {
+	msg := &jsonrpcMessage{Version: vsn, ID: json.RawMessage("1"), Method: req.Fields["method"].GetStringValue()}
+	if msg.Method == "" {
+		return nil, status.Error(codes.InvalidArgument, "method is not set")
+	}
+	if params := req.Fields["params"]; params != nil {
+		if params.GetListValue() == nil {
+			return nil, status.Error(codes.InvalidArgument, "params must be a list")
+		}
+		var err error
+		if msg.Params, err = protojson.Marshal(params); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(apiKeyHeader); len(keys) > 0 && keys[0] != "" {
+			ctx = ContextWithAPIKey(ctx, keys[0])
+		}
+		if ids := md.Get(sessionHeader); len(ids) > 0 && ids[0] != "" {
+			ctx = ContextWithSession(ctx, ids[0])
+		}
+	}
+	conn := &grpcServerConn{msg: msg, closeCh: make(chan interface{})}
+	if p, ok := peer.FromContext(ctx); ok {
+		conn.remote = p.Addr.String()
+	}
+	s.serveSingleRequest(ctx, conn, nil)
+	if conn.response == nil {
+		return nil, status.Error(codes.Unavailable, "server is stopped")
+	}
+	var answer jsonrpcMessage
+	if err := json.Unmarshal(conn.response, &answer); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if answer.Error != nil {
+		return nil, grpcError(answer.Error)
+	}
+	result := new(structpb.Value)
+	if err := protojson.Unmarshal(answer.Result, result); err != nil {
+		return nil, status.Errorf(codes.Internal, "result: %v", err)
+	}
+	return result, nil
+}
+
+// grpcError converts the error of the method to the gRPC status
+func grpcError(err *jsonError) error {
+	code := codes.Unknown
+	switch err.Code {
+	case -32601:
+		code = codes.Unimplemented
+	case -32602:
+		code = codes.InvalidArgument
+	}
+	st := status.New(code, err.Message)
+	details, detailsErr := structpb.NewStruct(map[string]interface{}{"code": err.Code})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	if err.Data != nil {
+		if data, dataErr := structpb.NewValue(err.Data); dataErr == nil {
+			details.Fields["data"] = data
+		}
+	}
+	if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcServerConn is the codec of one call received over gRPC
+type grpcServerConn struct {
+	msg      *jsonrpcMessage
+	remote   string
+	read     bool
+	response json.RawMessage
+	closeCh  chan interface{}
+	mu       sync.Mutex
+}
+
+func (c *grpcServerConn) readBatch() ([]*jsonrpcMessage, bool, error) {
+	if c.read {
+		return nil, false, io.EOF
+	}
+	c.read = true
+	return []*jsonrpcMessage{c.msg}, false, nil
+}
+
+func (c *grpcServerConn) writeJSON(_ context.Context, v interface{}) error {
+	response, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if response, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.response = response
+	return nil
+}
+
+func (c *grpcServerConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closeCh:
+	default:
+		close(c.closeCh)
+	}
+}
+
+func (c *grpcServerConn) closed() <-chan interface{} { return c.closeCh }
+
+func (c *grpcServerConn) remoteAddr() string { return c.remote }
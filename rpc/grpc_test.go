@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGRPC(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	g := grpc.NewServer()
+	RegisterGRPC(g, server)
+	listener := bufconn.Listen(1 << 20)
+	go g.Serve(listener) //nolint:errcheck
+	defer g.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	call := func(method string, params ...interface{}) (*structpb.Value, error) {
+		req, err := structpb.NewStruct(map[string]interface{}{"method": method, "params": params})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := new(structpb.Value)
+		return reply, conn.Invoke(context.Background(), grpcCallMethod, req, reply)
+	}
+
+	reply, err := call("test_echo", "x", 3, map[string]interface{}{"S": "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := reply.GetStructValue().AsMap()
+	if result["String"] != "x" || result["Int"] != 3.0 || result["Args"].(map[string]interface{})["S"] != "y" {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	_, err = call("test_returnError")
+	st := status.Convert(err)
+	if st.Code() != codes.Unknown || st.Message() != "testError" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("expected details, got %v", st.Details())
+	}
+	details := st.Details()[0].(*structpb.Struct).AsMap()
+	if details["code"] != 444.0 || details["data"] != "testError data" {
+		t.Errorf("unexpected details %v", details)
+	}
+
+	if _, err = call("test_unknown"); status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented, got %v", err)
+	}
+	if _, err = call("test_echo", "x"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+
+	if _, err := protoregistry.GlobalFiles.FindDescriptorByName(grpcServiceName); err != nil {
+		t.Errorf("service is not described for reflection: %v", err)
+	}
+}
@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -63,7 +64,10 @@ type handler struct {
 	log            log.Logger
 	allowSubscribe bool
 
-	allowList AllowList // a list of explicitly allowed methods, if empty -- everything is allowed
+	allowList   AllowList    // a list of explicitly allowed methods, if empty -- everything is allowed
+	scheduler   *Scheduler   // prioritizes calls under load, nil if calls are not limited
+	middlewares []Middleware // of method calls, see Server.Use
+	batchLimits BatchLimits
 
 	subLock             sync.Mutex
 	serverSubs          map[ID]*Subscription
@@ -125,8 +129,15 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage, stream *jsoniter.Stream) {
 	if len(calls) == 0 {
 		return
 	}
+	if h.batchLimits.MaxCalls > 0 && len(calls) > h.batchLimits.MaxCalls {
+		h.startCallProc(func(cp *callProc) {
+			h.conn.writeJSON(cp.ctx, errorMessage(&invalidRequestError{fmt.Sprintf("batch too large: %d calls, the limit is %d", len(calls), h.batchLimits.MaxCalls)}))
+		})
+		return
+	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
+		cp.ctx = withBatchGas(cp.ctx, h.batchLimits.MaxGas)
 		stream.WriteArrayStart()
 		firstResponse := true
 		// All goroutines will place results right to this array. Because requests order must match reply orders.
@@ -222,7 +233,7 @@ func (h *handler) handleMsg(msg *jsonrpcMessage, stream *jsoniter.Stream) {
 		}
 		if needWriteStream {
 			h.conn.writeJSON(cp.ctx, json.RawMessage(stream.Buffer()))
-		} else {
+		} else if !framesStarted(cp.ctx) {
 			stream.Write([]byte("\n"))
 		}
 		for _, n := range cp.notifiers {
@@ -425,7 +436,12 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage, stream *jsoniter
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args, stream)
+	var answer *jsonrpcMessage
+	if len(h.middlewares) > 0 && callb != h.unsubscribeCb {
+		answer = h.runMiddlewares(cp, msg, callb, args, stream, start)
+	} else {
+		answer = h.executeCall(cp.ctx, msg, callb, args, stream)
+	}
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -439,6 +455,18 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage, stream *jsoniter
 	return answer
 }
 
+// executeCall runs the method, waiting for the scheduler if it's set
+func (h *handler) executeCall(ctx context.Context, msg *jsonrpcMessage, callb *callback, args []reflect.Value, stream *jsoniter.Stream) *jsonrpcMessage {
+	if h.scheduler != nil && callb != h.unsubscribeCb {
+		release, err := h.scheduler.acquire(ctx, h.scheduler.ClassOf(msg.Method, APIKeyFromContext(ctx)))
+		if err != nil {
+			return msg.errorResponse(err)
+		}
+		defer release()
+	}
+	return h.runMethod(ctx, msg, callb, pinArgs(ctx, args), stream)
+}
+
 // handleSubscribe processes *_subscribe method calls.
 func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage, stream *jsoniter.Stream) *jsonrpcMessage {
 	if !h.allowSubscribe {
@@ -481,11 +509,23 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 		stream.WriteMore()
 		if msg.ID != nil {
 			stream.WriteObjectField("id")
-			stream.Write(msg.ID)
+			stream.WriteRaw(string(msg.ID)) // buffered, so the envelope can be dropped by StartFrames
 			stream.WriteMore()
 		}
 		stream.WriteObjectField("result")
+		f := framesFromContext(ctx)
+		if f != nil {
+			f.stream, f.envelopeLen = stream, len(stream.Buffer())
+		}
 		_, err := callb.call(ctx, msg.Method, args, stream)
+		if f != nil && f.writer != nil {
+			if err != nil {
+				if err := f.writer.writeError(err); err != nil {
+					h.log.Debug("Failed to send the error frame", "method", msg.Method, "err", err)
+				}
+			}
+			return nil
+		}
 		if err != nil {
 			return msg.errorResponse(err)
 			/*
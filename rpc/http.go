@@ -29,8 +29,6 @@ import (
 	"net/url"
 	"sync"
 	"time"
-
-	jsoniter "github.com/json-iterator/go"
 )
 
 const (
@@ -249,11 +247,19 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		ctx = context.WithValue(ctx, "Origin", origin)
 	}
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		ctx = ContextWithAPIKey(ctx, key)
+	}
+	if id := r.Header.Get(sessionHeader); id != "" {
+		ctx = ContextWithSession(ctx, id)
+	}
+
+	ctx = contextWithFrames(ctx, w, streamFormatOfAccept(r.Header.Get("Accept")))
 
 	w.Header().Set("content-type", contentType)
 	codec := newHTTPServerConn(r, w)
 	defer codec.close()
-	stream := jsoniter.NewStream(jsoniter.ConfigDefault, w, 4096)
+	ctx, stream := newResponseStream(ctx, w)
 	s.serveSingleRequest(ctx, codec, stream)
 }
 
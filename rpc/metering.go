@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// otherKeys is the key ID of usage of keys above the limit of the meter
+const otherKeys = "other"
+
+// KeyID identifies the API key in exported usage, without revealing it: first 16 hex digits of its SHA-256.
+// Calls without a key have ID "".
+func KeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:8])
+}
+
+// Usage of the server by one API key
+type Usage struct {
+	Key     string  `json:"key"` // see KeyID, "other" - keys above the limit of the meter
+	Calls   uint64  `json:"calls"`
+	Errors  uint64  `json:"errors"`
+	Seconds float64 `json:"seconds"` // time of calls since they were received, including waiting for the scheduler
+	Bytes   uint64  `json:"bytes"`   // of results
+}
+
+// usageRecord is the line of the usage file
+type usageRecord struct {
+	Time time.Time `json:"time"` // end of the period
+	Usage
+}
+
+// Meter attributes usage of the server to API keys (see APIKeyFromContext): calls, errors, time of calls and bytes of
+// results. Usage is aggregated in memory and exported by Run every period: added to Prometheus counters
+// rpc_usage_{calls,errors,seconds,bytes}{key="<KeyID>"} and, if the file is set, appended to it as JSON lines,
+// one per key used in the period. Usage of keys above maxKeys distinct ones goes to the key "other".
+type Meter struct {
+	maxKeys int
+	file    string
+	log     log.Logger
+
+	mu    sync.Mutex
+	keys  map[string]string // key ID by key, of all keys seen
+	usage map[string]*Usage // of the current period, by key ID
+}
+
+// NewMeter creates the meter, file "" - usage is exported only to Prometheus
+func NewMeter(maxKeys int, file string) *Meter {
+	return &Meter{maxKeys: maxKeys, file: file, log: log.New("rpc", "metering"), keys: map[string]string{}, usage: map[string]*Usage{}}
+}
+
+// Middleware returns the middleware of the server, which meters calls. Register it before middlewares, which may
+// refuse calls or serve them from a cache, to meter such calls too.
+func (m *Meter) Middleware() Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+			result, err := next(ctx, call)
+			m.record(call.APIKey, time.Since(call.Start), len(result)+call.Streamed, err != nil)
+			return result, err
+		}
+	}
+}
+
+func (m *Meter) record(key string, duration time.Duration, size int, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.keys[key]
+	if !ok {
+		id = otherKeys
+		if key == "" {
+			id = ""
+		} else if len(m.keys) < m.maxKeys {
+			id = KeyID(key)
+			m.keys[key] = id
+		}
+	}
+	u := m.usage[id]
+	if u == nil {
+		u = &Usage{Key: id}
+		m.usage[id] = u
+	}
+	u.Calls++
+	if failed {
+		u.Errors++
+	}
+	u.Seconds += duration.Seconds()
+	u.Bytes += uint64(size)
+}
+
+// Run exports usage every period until the context is done, then exports the last period
+func (m *Meter) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.Export()
+			return
+		case <-ticker.C:
+			m.Export()
+		}
+	}
+}
+
+// Export exports usage of the period since the last export, and starts the next period
+func (m *Meter) Export() {
+	m.mu.Lock()
+	usage := m.usage
+	m.usage = map[string]*Usage{}
+	m.mu.Unlock()
+	if len(usage) == 0 {
+		return
+	}
+	records := make([]usageRecord, 0, len(usage))
+	now := time.Now().UTC()
+	for _, u := range usage {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_usage_calls{key=%q}`, u.Key)).Add(int(u.Calls))
+		metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_usage_errors{key=%q}`, u.Key)).Add(int(u.Errors))
+		metrics.GetOrCreateFloatCounter(fmt.Sprintf(`rpc_usage_seconds{key=%q}`, u.Key)).Add(u.Seconds)
+		metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_usage_bytes{key=%q}`, u.Key)).Add(int(u.Bytes))
+		records = append(records, usageRecord{Time: now, Usage: *u})
+	}
+	if m.file == "" {
+		return
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	if err := appendUsage(m.file, records); err != nil {
+		m.log.Warn("could not write usage", "file", m.file, "err", err)
+	}
+}
+
+func appendUsage(file string, records []usageRecord) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err = enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMeter(t *testing.T) {
+	server := newTestServer()
+	if err := server.RegisterName("frames", framesService{}); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "usage.jsonl")
+	meter := NewMeter(2, file)
+	server.Use(meter.Middleware())
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	call := func(key string, method string, args ...interface{}) {
+		client, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if key != "" {
+			client.SetHeader(apiKeyHeader, key)
+		}
+		_ = client.Call(nil, method, args...)
+	}
+	call("a", "test_echo", "x", 1) // {"String":"x","Int":1,"Args":null}
+	call("a", "frames_logs", 12345, nil)
+	call("b", "test_returnError")
+	call("c", "test_rets") // above the limit of keys, result ""
+	call("", "test_rets")
+	meter.Export()
+	call("a", "test_rets")
+	meter.Export()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []Usage
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Time.IsZero() || record.Seconds <= 0 {
+			t.Errorf("unexpected record %s", scanner.Text())
+		}
+		record.Seconds = 0
+		got = append(got, record.Usage)
+	}
+	// lines of one export are sorted by key ID
+	want := []Usage{
+		{Key: "", Calls: 1, Bytes: 2},
+		{Key: KeyID("b"), Calls: 1, Errors: 1, Bytes: 0},
+		{Key: KeyID("a"), Calls: 2, Bytes: 35 + 38}, // the streamed response includes the envelope {"jsonrpc":"2.0","id":1,"result":}
+		{Key: otherKeys, Calls: 1, Bytes: 2},
+		{Key: KeyID("a"), Calls: 1, Bytes: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if KeyID("a") == "a" || len(KeyID("a")) != 16 {
+		t.Errorf("unexpected key ID %q", KeyID("a"))
+	}
+}
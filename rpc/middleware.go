@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Call is the method call passed through middlewares, see Server.Use
+type Call struct {
+	Method string
+	Params json.RawMessage // read-only: arguments of the method are parsed before middlewares
+	APIKey string          // sent by the client in the X-API-Key header, "" if there is none
+	Remote string          // address of the client, "" if unknown (f.e. in-process clients)
+	Start  time.Time       // when the call was received, middlewares measure the time of the call since it
+
+	// Streamed is the size in bytes of the response (with the JSON-RPC envelope), which the method streamed to
+	// the client instead of returning the result. It's known after next returns.
+	Streamed int
+}
+
+// CallHandler executes the call and returns its result, or the error sent to the client instead of it.
+// The result is nil, if the method streamed it to the client already.
+type CallHandler func(ctx context.Context, call *Call) (json.RawMessage, error)
+
+// Middleware wraps the execution of method calls: it may inspect the call before passing it to next and the result
+// after, replace the result (f.e. from a cache) without calling next, or refuse the call by an error (f.e. auth).
+// Errors implementing Error and DataError keep their code and data. The context passed to next becomes the context
+// of the method.
+type Middleware func(next CallHandler) CallHandler
+
+// Use adds middlewares of method calls, the first one is the outermost. They are called for calls of existing methods
+// with valid params, before the scheduler; not for subscriptions. Must be called before the server starts serving.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// runMiddlewares executes the call through the middlewares
+func (h *handler) runMiddlewares(cp *callProc, msg *jsonrpcMessage, callb *callback, args []reflect.Value, stream *jsoniter.Stream, start time.Time) *jsonrpcMessage {
+	var streamed bool
+	handler := CallHandler(func(ctx context.Context, call *Call) (json.RawMessage, error) {
+		var before int
+		if callb.streamable { // other methods may share the stream with concurrent calls of the batch
+			before = streamedBytes(ctx, stream)
+		}
+		answer := h.executeCall(ctx, msg, callb, args, stream)
+		if answer == nil {
+			streamed = true
+			call.Streamed = streamedBytes(ctx, stream) - before
+			return nil, nil
+		}
+		if answer.Error != nil {
+			return nil, answer.Error
+		}
+		return answer.Result, nil
+	})
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		handler = h.middlewares[i](handler)
+	}
+	call := &Call{Method: msg.Method, Params: msg.Params, APIKey: APIKeyFromContext(cp.ctx), Remote: h.conn.remoteAddr(), Start: start}
+	result, err := handler(cp.ctx, call)
+	if streamed { // the response is sent already
+		return nil
+	}
+	if err != nil {
+		return msg.errorResponse(err)
+	}
+	if result == nil {
+		result = null
+	}
+	return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: result}
+}
+
+type responseWriterKey struct{}
+
+// responseWriter counts bytes written by the stream to the HTTP response of a single request
+type responseWriter struct {
+	w       io.Writer
+	stream  *jsoniter.Stream
+	written int
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.written += n
+	return n, err
+}
+
+// newResponseStream returns the stream of the HTTP response and the context, which tells what's written by it
+func newResponseStream(ctx context.Context, w io.Writer) (context.Context, *jsoniter.Stream) {
+	rw := &responseWriter{w: w}
+	rw.stream = jsoniter.NewStream(jsoniter.ConfigDefault, rw, 4096)
+	return context.WithValue(ctx, responseWriterKey{}, rw), rw.stream
+}
+
+// streamedBytes returns the number of bytes written to the stream: buffered and, for the stream of the HTTP
+// response, flushed. Other streams (batches, websockets) are not flushed by methods.
+func streamedBytes(ctx context.Context, stream *jsoniter.Stream) int {
+	if stream == nil {
+		return 0
+	}
+	n := stream.Buffered()
+	if rw, ok := ctx.Value(responseWriterKey{}).(*responseWriter); ok && rw.stream == stream {
+		n += rw.written
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMiddlewares(t *testing.T) {
+	server := newTestServer()
+	var calls []string
+	var seen *Call
+	var seenResult json.RawMessage
+	server.Use(
+		func(next CallHandler) CallHandler {
+			return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+				calls = append(calls, "outer:"+call.Method)
+				if call.Method == "test_rets" { // cached
+					return json.RawMessage(`"cached"`), nil
+				}
+				if call.APIKey != "secret" && call.Method == "test_returnError" {
+					return nil, &invalidRequestError{"unauthorized"}
+				}
+				return next(ctx, call)
+			}
+		},
+		func(next CallHandler) CallHandler {
+			return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+				calls = append(calls, "inner:"+call.Method)
+				result, err := next(ctx, call)
+				seen, seenResult = call, result
+				return result, err
+			}
+		},
+	)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var echo echoResult
+	if err := client.Call(&echo, "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if seen.Method != "test_echo" || string(seen.Params) != `["x",1]` || seen.Start.IsZero() {
+		t.Errorf("unexpected call %+v", seen)
+	}
+	if string(seenResult) != `{"String":"x","Int":1,"Args":null}` {
+		t.Errorf("unexpected result %s", seenResult)
+	}
+
+	var rets string
+	if err := client.Call(&rets, "test_rets"); err != nil {
+		t.Fatal(err)
+	}
+	if rets != "cached" {
+		t.Errorf("expected the cached result, got %q", rets)
+	}
+
+	err := client.Call(nil, "test_returnError")
+	var rpcErr Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32600 || err.Error() != "unauthorized" {
+		t.Errorf("expected the error of the middleware, got %v", err)
+	}
+
+	want := []string{"outer:test_echo", "inner:test_echo", "outer:test_rets", "outer:test_returnError"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// AnyMethod in limits of RateLimiter applies to methods without own limits
+const AnyMethod = "*"
+
+// Ways to identify clients of RateLimiter
+const (
+	ClientByIP  = "ip"  // address of the client, without the port
+	ClientByKey = "key" // API key (see APIKeyFromContext), calls without a key - by address
+)
+
+// RateLimitError is the error of calls above the rate limit or the quota of the method. Its code is -32005
+// ("limit exceeded" of EIP-1474), data tells in how many seconds the call can be retried.
+type RateLimitError struct {
+	Method     string
+	Limit      string // which limit is exceeded, f.e. "10/s" or "1000 per 24h0m0s"
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) ErrorCode() int { return -32005 }
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %s is limited to %s", e.Method, e.Limit)
+}
+
+func (e *RateLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": int64(math.Ceil(e.RetryAfter.Seconds()))}
+}
+
+// MethodLimit limits calls of a method by one client
+type MethodLimit struct {
+	Rate  rate.Limit // calls per second, bursts of up to a second of calls are allowed; 0 - unlimited
+	Quota uint64     // calls per quota period of the RateLimiter, 0 - unlimited
+}
+
+// RateLimiter limits calls of methods by every client: by the rate of calls and by the quota of calls in fixed
+// periods. Calls above limits fail with RateLimitError. State of up to maxClients recent clients is kept, forgotten
+// clients start from scratch.
+type RateLimiter struct {
+	limits map[string]MethodLimit // by method, AnyMethod - of other methods
+	by     string
+	period time.Duration // of quotas
+	now    func() time.Time
+
+	mu      sync.Mutex
+	clients *lru.Cache // of *clientLimits by client
+}
+
+type clientLimits struct {
+	limiters map[string]*rate.Limiter // by method
+	calls    map[string]uint64        // by method, in the current period
+	period   time.Time                // start of the current period
+}
+
+// NewRateLimiter creates the limiter, by is ClientByIP or ClientByKey, period is the period of quotas
+func NewRateLimiter(limits map[string]MethodLimit, by string, period time.Duration, maxClients int) (*RateLimiter, error) {
+	if by != ClientByIP && by != ClientByKey {
+		return nil, fmt.Errorf("unknown way to identify clients %q, expected %s or %s", by, ClientByIP, ClientByKey)
+	}
+	for method, limit := range limits {
+		if limit.Quota > 0 && period <= 0 {
+			return nil, fmt.Errorf("quota of %s needs a positive period", method)
+		}
+	}
+	clients, err := lru.New(maxClients)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimiter{limits: limits, by: by, period: period, now: time.Now, clients: clients}, nil
+}
+
+// ParseMethodLimits parses limits of methods given as "method=N/s" (also /m, /h) for rates and as "method=N" for
+// quotas, where method may be AnyMethod, and merges them into limits
+func ParseMethodLimits(specs []string, limits map[string]MethodLimit) error {
+	for _, spec := range specs {
+		i := strings.IndexByte(spec, '=')
+		if i <= 0 {
+			return fmt.Errorf("invalid limit %q, expected method=value", spec)
+		}
+		method, value := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		limit := limits[method]
+		if j := strings.IndexByte(value, '/'); j >= 0 {
+			per := map[string]float64{"s": 1, "m": 60, "h": 3600}[value[j+1:]]
+			n, err := strconv.ParseFloat(value[:j], 64)
+			if err != nil || per == 0 || n <= 0 {
+				return fmt.Errorf("invalid rate %q of %s, expected f.e. 10/s", value, method)
+			}
+			limit.Rate = rate.Limit(n / per)
+		} else {
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid quota %q of %s: %w", value, method, err)
+			}
+			limit.Quota = n
+		}
+		limits[method] = limit
+	}
+	return nil
+}
+
+// Middleware returns the middleware of the server, which refuses calls above limits. Register it after middlewares,
+// which should see refused calls too (f.e. metering).
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+			if err := l.allow(l.client(call), call.Method); err != nil {
+				metrics.GetOrCreateCounter(fmt.Sprintf(`rpc_ratelimited{method="%s"}`, call.Method)).Inc()
+				return nil, err
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+func (l *RateLimiter) client(call *Call) string {
+	if l.by == ClientByKey && call.APIKey != "" {
+		return "key:" + call.APIKey
+	}
+	host, _, err := net.SplitHostPort(call.Remote)
+	if err != nil {
+		host = call.Remote
+	}
+	return "ip:" + host
+}
+
+func (l *RateLimiter) allow(client, method string) error {
+	limit, ok := l.limits[method]
+	if !ok {
+		if limit, ok = l.limits[AnyMethod]; !ok {
+			return nil
+		}
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var c *clientLimits
+	if v, ok := l.clients.Get(client); ok {
+		c = v.(*clientLimits)
+	} else {
+		c = &clientLimits{limiters: map[string]*rate.Limiter{}, calls: map[string]uint64{}}
+		l.clients.Add(client, c)
+	}
+	if limit.Quota > 0 {
+		if period := now.Truncate(l.period); period.After(c.period) {
+			c.period = period
+			c.calls = map[string]uint64{}
+		}
+		if c.calls[method] >= limit.Quota {
+			return &RateLimitError{Method: method, Limit: fmt.Sprintf("%d per %s", limit.Quota, l.period), RetryAfter: c.period.Add(l.period).Sub(now)}
+		}
+	}
+	if limit.Rate > 0 {
+		limiter, ok := c.limiters[method]
+		if !ok {
+			limiter = rate.NewLimiter(limit.Rate, int(math.Max(1, math.Ceil(float64(limit.Rate)))))
+			c.limiters[method] = limiter
+		}
+		r := limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			return &RateLimitError{Method: method, Limit: formatRate(limit.Rate), RetryAfter: delay}
+		}
+	}
+	if limit.Quota > 0 {
+		c.calls[method]++
+	}
+	return nil
+}
+
+func formatRate(r rate.Limit) string {
+	switch {
+	case r >= 1:
+		return strconv.FormatFloat(float64(r), 'f', -1, 64) + "/s"
+	case r*60 >= 1:
+		return strconv.FormatFloat(float64(r*60), 'f', -1, 64) + "/m"
+	default:
+		return strconv.FormatFloat(float64(r*3600), 'f', -1, 64) + "/h"
+	}
+}
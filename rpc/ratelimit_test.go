@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMethodLimits(t *testing.T) {
+	limits := map[string]MethodLimit{}
+	if err := ParseMethodLimits([]string{"eth_getLogs=10/s", "eth_call=30/m", "*=100"}, limits); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseMethodLimits([]string{"eth_getLogs=1000"}, limits); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]MethodLimit{
+		"eth_getLogs": {Rate: 10, Quota: 1000},
+		"eth_call":    {Rate: 0.5},
+		AnyMethod:     {Quota: 100},
+	}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("got %+v, expected %+v", limits, expected)
+	}
+	for _, spec := range []string{"eth_call", "=1", "eth_call=1/d", "eth_call=-1/s", "eth_call=x"} {
+		if err := ParseMethodLimits([]string{spec}, map[string]MethodLimit{}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]MethodLimit{
+		"test_echo": {Rate: 2},
+		AnyMethod:   {Quota: 3},
+	}, ClientByKey, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 8, 1, 10, 30, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	server := newTestServer()
+	server.Use(limiter.Middleware())
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	call := func(key string, method string, args ...interface{}) error {
+		client, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if key != "" {
+			client.SetHeader(apiKeyHeader, key)
+		}
+		return client.Call(nil, method, args...)
+	}
+	limited := func(err error, retryAfter int64) {
+		t.Helper()
+		var rpcErr DataError
+		if !errors.As(err, &rpcErr) || err.(Error).ErrorCode() != -32005 {
+			t.Fatalf("expected the rate limit error, got %v", err)
+		}
+		if data := rpcErr.ErrorData().(map[string]interface{}); data["retryAfter"] != float64(retryAfter) {
+			t.Errorf("expected retry after %ds, got %v", retryAfter, data)
+		}
+	}
+
+	// Rate: bursts of a second of calls, per client
+	for i := 0; i < 2; i++ {
+		if err := call("a", "test_echo", "x", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limited(call("a", "test_echo", "x", 1), 1)
+	if err := call("b", "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("", "test_echo", "x", 1); err != nil { // by address
+		t.Fatal(err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if err := call("a", "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Quota: calls of other methods until the end of the period, refused calls don't count
+	for i := 0; i < 3; i++ {
+		if err := call("a", "test_rets"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limited(call("a", "test_rets"), 30*60)
+	if err := call("a", "test_sleep", 0); err != nil { // own quota of the method
+		t.Fatal(err)
+	}
+	now = now.Add(30 * time.Minute)
+	if err := call("a", "test_rets"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRateLimiter(nil, "jwt", time.Hour, 10); err == nil {
+		t.Error("expected an error for unknown way to identify clients")
+	}
+	if _, err := NewRateLimiter(map[string]MethodLimit{AnyMethod: {Quota: 1}}, ClientByIP, 0, 10); err == nil {
+		t.Error("expected an error for quota without period")
+	}
+}
@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// Class is the traffic class of a method call. When all slots of the scheduler are busy,
+// waiting interactive calls are started before waiting batch calls.
+type Class int
+
+const (
+	// ClassInteractive - lightweight calls of wallets and dapps, latency matters
+	ClassInteractive Class = iota
+	// ClassBatch - heavy calls (traces, logs of big ranges, ...), throughput matters
+	ClassBatch
+)
+
+func (c Class) String() string {
+	if c == ClassBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// DefaultBatchMethods are methods, which are scheduled as batch traffic unless configured otherwise
+var DefaultBatchMethods = []string{"trace_*", "debug_trace*", "debug_storageRangeAt", "debug_getModifiedAccountsBy*", "debug_accountRange", "eth_getLogs", "erigon_getLogsByHash"}
+
+const apiKeyHeader = "X-API-Key"
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the API key sent by the client in the X-API-Key header, or "" if there is none
+func APIKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return key
+}
+
+// ContextWithAPIKey returns the context of a call made with the API key
+func ContextWithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+var (
+	schedulerWaitInteractive = metrics.GetOrCreateSummary(`rpc_scheduler_wait_seconds{class="interactive"}`)
+	schedulerWaitBatch       = metrics.GetOrCreateSummary(`rpc_scheduler_wait_seconds{class="batch"}`)
+)
+
+// Scheduler limits amount of method calls executed at the same time by the server,
+// and decides which of the waiting calls is started next:
+//   - waiting interactive calls go before waiting batch calls
+//   - batch calls never take the last quarter of the slots (at least one slot, unless there is only one),
+//     so interactive calls always have free slots when the node is loaded only by the batch traffic
+//
+// Running calls are never interrupted, interactive calls only preempt batch calls in the queue.
+type Scheduler struct {
+	slots      int
+	batchSlots int
+
+	methods         map[string]Class
+	methodPrefixes  map[string]Class // "trace_*" -> prefix "trace_"
+	keys            map[string]Class
+	lock            sync.Mutex
+	running         int
+	runningBatch    int
+	waitInteractive list.List // of chan struct{}
+	waitBatch       list.List
+}
+
+// NewScheduler creates scheduler, which executes at most `slots` calls at the same time.
+// Methods not mentioned in batchMethods are interactive. Entries of batchMethods ending with "*"
+// match all methods with such prefix.
+func NewScheduler(slots int, batchMethods []string) *Scheduler {
+	if slots < 1 {
+		slots = 1
+	}
+	reserved := slots / 4
+	if reserved < 1 && slots > 1 {
+		reserved = 1
+	}
+	batchSlots := slots - reserved
+	s := &Scheduler{slots: slots, batchSlots: batchSlots, methods: map[string]Class{}, methodPrefixes: map[string]Class{}, keys: map[string]Class{}}
+	for _, m := range batchMethods {
+		s.SetMethodClass(m, ClassBatch)
+	}
+	return s
+}
+
+// SetMethodClass assigns class to the method, or to all methods with the prefix if it ends with "*"
+func (s *Scheduler) SetMethodClass(method string, class Class) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if strings.HasSuffix(method, "*") {
+		s.methodPrefixes[strings.TrimSuffix(method, "*")] = class
+		return
+	}
+	s.methods[method] = class
+}
+
+// SetKeyClass assigns class to all calls made with the API key, it takes precedence over the class of the method
+func (s *Scheduler) SetKeyClass(key string, class Class) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keys[key] = class
+}
+
+// ClassOf returns the class of the call of the method with the API key
+func (s *Scheduler) ClassOf(method, apiKey string) Class {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if apiKey != "" {
+		if class, ok := s.keys[apiKey]; ok {
+			return class
+		}
+	}
+	if class, ok := s.methods[method]; ok {
+		return class
+	}
+	// the longest matching prefix wins
+	class, longest := ClassInteractive, -1
+	for prefix, c := range s.methodPrefixes {
+		if len(prefix) > longest && strings.HasPrefix(method, prefix) {
+			class, longest = c, len(prefix)
+		}
+	}
+	return class
+}
+
+// acquire waits for a free slot for the call of the class, the returned func must be called when the call is done
+func (s *Scheduler) acquire(ctx context.Context, class Class) (release func(), err error) {
+	start := time.Now()
+	s.lock.Lock()
+	if s.canStart(class) {
+		s.start(class)
+		s.lock.Unlock()
+		return func() { s.release(class) }, nil
+	}
+	ready := make(chan struct{})
+	queue := s.queue(class)
+	e := queue.PushBack(ready)
+	s.lock.Unlock()
+
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		s.lock.Lock()
+		select {
+		case <-ready:
+			// slot was given to us in the meantime, give it back
+			s.lock.Unlock()
+			s.release(class)
+		default:
+			queue.Remove(e)
+			s.lock.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+	if class == ClassBatch {
+		schedulerWaitBatch.UpdateDuration(start)
+	} else {
+		schedulerWaitInteractive.UpdateDuration(start)
+	}
+	return func() { s.release(class) }, nil
+}
+
+func (s *Scheduler) queue(class Class) *list.List {
+	if class == ClassBatch {
+		return &s.waitBatch
+	}
+	return &s.waitInteractive
+}
+
+func (s *Scheduler) canStart(class Class) bool {
+	if s.running >= s.slots {
+		return false
+	}
+	if class == ClassBatch {
+		return s.waitInteractive.Len() == 0 && s.runningBatch < s.batchSlots
+	}
+	return true
+}
+
+func (s *Scheduler) start(class Class) {
+	s.running++
+	if class == ClassBatch {
+		s.runningBatch++
+	}
+}
+
+func (s *Scheduler) release(class Class) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running--
+	if class == ClassBatch {
+		s.runningBatch--
+	}
+	// hand freed slots over to the waiters, interactive first
+	for {
+		if e := s.waitInteractive.Front(); e != nil && s.canStart(ClassInteractive) {
+			s.waitInteractive.Remove(e)
+			s.start(ClassInteractive)
+			close(e.Value.(chan struct{}))
+			continue
+		}
+		if e := s.waitBatch.Front(); e != nil && s.canStart(ClassBatch) {
+			s.waitBatch.Remove(e)
+			s.start(ClassBatch)
+			close(e.Value.(chan struct{}))
+			continue
+		}
+		return
+	}
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerClassOf(t *testing.T) {
+	s := NewScheduler(4, []string{"trace_*", "debug_trace*", "eth_getLogs"})
+	s.SetMethodClass("trace_block*", ClassInteractive)
+	s.SetKeyClass("indexer", ClassBatch)
+	s.SetKeyClass("wallet", ClassInteractive)
+	for _, c := range []struct {
+		method, key string
+		class       Class
+	}{
+		{"eth_call", "", ClassInteractive},
+		{"eth_getLogs", "", ClassBatch},
+		{"trace_filter", "", ClassBatch},
+		{"trace_blockNumber", "", ClassInteractive}, // longer prefix wins
+		{"debug_traceTransaction", "", ClassBatch},
+		{"eth_call", "indexer", ClassBatch},
+		{"trace_filter", "wallet", ClassInteractive},
+		{"trace_filter", "unknown", ClassBatch},
+	} {
+		if class := s.ClassOf(c.method, c.key); class != c.class {
+			t.Errorf("%s with key %q: expected %s, got %s", c.method, c.key, c.class, class)
+		}
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	ctx := context.Background()
+	s := NewScheduler(4, nil)
+
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		release, err := s.acquire(ctx, ClassBatch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+	// the last quarter of slots is reserved for interactive calls
+	batchStarted := make(chan func(), 1)
+	go func() {
+		release, _ := s.acquire(ctx, ClassBatch)
+		batchStarted <- release
+	}()
+	waitQueued(t, s, 0, 1)
+	releaseInteractive, err := s.acquire(ctx, ClassInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all slots are busy, freed slot goes to the interactive call, though it came later
+	interactiveStarted := make(chan func(), 1)
+	go func() {
+		release, _ := s.acquire(ctx, ClassInteractive)
+		interactiveStarted <- release
+	}()
+	waitQueued(t, s, 1, 1)
+	releases[0]()
+	select {
+	case release := <-interactiveStarted:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("interactive call was not started")
+	}
+	select {
+	case release := <-batchStarted:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch call was not started")
+	}
+	releaseInteractive()
+	releases[1]()
+	releases[2]()
+	if s.running != 0 || s.runningBatch != 0 {
+		t.Fatalf("slots leaked: running %d, batch %d", s.running, s.runningBatch)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(1, nil)
+	release, err := s.acquire(context.Background(), ClassInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = s.acquire(ctx, ClassBatch); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	waitQueued(t, s, 0, 0)
+	release()
+	if s.running != 0 {
+		t.Fatalf("slots leaked: running %d", s.running)
+	}
+}
+
+func TestSchedulerReserve(t *testing.T) {
+	for slots, batchSlots := range map[int]int{1: 1, 2: 1, 3: 2, 4: 3, 8: 6} {
+		if s := NewScheduler(slots, nil); s.batchSlots != batchSlots {
+			t.Errorf("%d slots: expected %d batch slots, got %d", slots, batchSlots, s.batchSlots)
+		}
+	}
+}
+
+func waitQueued(t *testing.T, s *Scheduler, interactive, batch int) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		s.lock.Lock()
+		ok := s.waitInteractive.Len() == interactive && s.waitBatch.Len() == batch
+		s.lock.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d interactive and %d batch calls in the queues", interactive, batch)
+}
@@ -45,11 +45,14 @@ const (
 type Server struct {
 	services        serviceRegistry
 	methodAllowList AllowList
+	scheduler       *Scheduler
+	middlewares     []Middleware
 	idgen           func() ID
 	run             int32
 	codecs          mapset.Set
 
 	batchConcurrency uint
+	batchLimits      BatchLimits
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -67,6 +70,12 @@ func (s *Server) SetAllowList(allowList AllowList) {
 	s.methodAllowList = allowList
 }
 
+// SetScheduler sets the scheduler, which limits amount of concurrently executed calls and
+// prioritizes interactive calls over batch ones. Must be called before the server starts serving.
+func (s *Server) SetScheduler(scheduler *Scheduler) {
+	s.scheduler = scheduler
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -92,7 +101,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.scheduler, s.middlewares, s.batchConcurrency, s.batchLimits)
 	<-codec.closed()
 	c.Close()
 }
@@ -106,10 +115,6 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec, stre
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services, s.methodAllowList, s.batchConcurrency)
-	h.allowSubscribe = false
-	defer h.close(io.EOF, nil)
-
 	reqs, batch, err := codec.readBatch()
 	if err != nil {
 		if err != io.EOF {
@@ -117,6 +122,17 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec, stre
 		}
 		return
 	}
+	if batch { // results of batch requests share one response, they can't be switched to frames
+		ctx = context.WithValue(ctx, framesKey{}, nil)
+	}
+
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.methodAllowList, s.batchConcurrency)
+	h.allowSubscribe = false
+	h.scheduler = s.scheduler
+	h.middlewares = s.middlewares
+	h.batchLimits = s.batchLimits
+	defer h.close(io.EOF, nil)
+
 	if batch {
 		h.handleBatch(reqs, stream)
 	} else {
@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+)
+
+const sessionHeader = "X-Session-ID"
+
+type sessionContextKey struct{}
+
+// SessionFromContext returns the session sent by the client in the X-Session-ID header, or "" if there is none
+func SessionFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionContextKey{}).(string)
+	return id
+}
+
+// ContextWithSession returns the context of a call made in the session
+func ContextWithSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, id)
+}
+
+// PinnedBlock is the block, which calls of a session see as "latest" and "pending"
+type PinnedBlock struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+type pinnedBlockContextKey struct{}
+
+// ContextWithPinnedBlock returns the context, in which block arguments "latest" and "pending" of methods mean the block
+func ContextWithPinnedBlock(ctx context.Context, block PinnedBlock) context.Context {
+	return context.WithValue(ctx, pinnedBlockContextKey{}, block)
+}
+
+// PinnedBlockFromContext returns the block pinned by the session of the call, if any
+func PinnedBlockFromContext(ctx context.Context) (PinnedBlock, bool) {
+	block, ok := ctx.Value(pinnedBlockContextKey{}).(PinnedBlock)
+	return block, ok
+}
+
+var (
+	blockNumberType          = reflect.TypeOf(BlockNumber(0))
+	blockNumberOrHashType    = reflect.TypeOf(BlockNumberOrHash{})
+	blockNumberPtrType       = reflect.PtrTo(blockNumberType)
+	blockNumberOrHashPtrType = reflect.PtrTo(blockNumberOrHashType)
+)
+
+// pinArgs replaces "latest" and "pending" block arguments of the call (also omitted optional ones) by the block pinned
+// in the context. Arguments by block number get its number, arguments by number or hash - its hash, which must stay
+// canonical.
+func pinArgs(ctx context.Context, args []reflect.Value) []reflect.Value {
+	block, ok := PinnedBlockFromContext(ctx)
+	if !ok {
+		return args
+	}
+	pinned := make([]reflect.Value, len(args))
+	copy(pinned, args)
+	floating := func(n BlockNumber) bool { return n == LatestBlockNumber || n == PendingBlockNumber }
+	for i, arg := range args {
+		switch arg.Type() {
+		case blockNumberType:
+			if floating(BlockNumber(arg.Int())) {
+				pinned[i] = reflect.ValueOf(BlockNumber(block.Number))
+			}
+		case blockNumberPtrType:
+			if arg.IsNil() || floating(*arg.Interface().(*BlockNumber)) {
+				n := BlockNumber(block.Number)
+				pinned[i] = reflect.ValueOf(&n)
+			}
+		case blockNumberOrHashType:
+			bnh := arg.Interface().(BlockNumberOrHash)
+			if n, ok := bnh.Number(); ok && floating(n) {
+				pinned[i] = reflect.ValueOf(BlockNumberOrHashWithHash(block.Hash, true))
+			}
+		case blockNumberOrHashPtrType:
+			if arg.IsNil() {
+				byHash := BlockNumberOrHashWithHash(block.Hash, true)
+				pinned[i] = reflect.ValueOf(&byHash)
+			} else if n, ok := arg.Interface().(*BlockNumberOrHash).Number(); ok && floating(n) {
+				byHash := BlockNumberOrHashWithHash(block.Hash, true)
+				pinned[i] = reflect.ValueOf(&byHash)
+			}
+		}
+	}
+	return pinned
+}
+
+// Sessions pin the chain for clients across calls: a client opens the session at the head (see Open) and sends its ID
+// in the X-Session-ID header, then "latest" and "pending" of all its calls mean the head at the opening, so calls
+// (f.e. balance, storage and code of a contract) see the same state even if new blocks arrive in between.
+// Sessions expire after ttl without calls.
+type Sessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+type session struct {
+	block   PinnedBlock
+	expires time.Time
+}
+
+// NewSessions creates the sessions, which expire after ttl without calls
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, sessions: map[string]*session{}}
+}
+
+// Open opens the session pinned at the block and returns its ID
+func (s *Sessions) Open(block PinnedBlock) string {
+	id := string(NewID())
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, ses := range s.sessions {
+		if now.After(ses.expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[id] = &session{block: block, expires: now.Add(s.ttl)}
+	return id
+}
+
+// Close closes the session, returns false if it's unknown or expired already
+func (s *Sessions) Close(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ses, ok := s.sessions[id]
+	delete(s.sessions, id)
+	return ok && time.Now().Before(ses.expires)
+}
+
+// Get returns the block pinned by the session and extends its lifetime
+func (s *Sessions) Get(id string) (PinnedBlock, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ses, ok := s.sessions[id]
+	if !ok {
+		return PinnedBlock{}, false
+	}
+	now := time.Now()
+	if now.After(ses.expires) {
+		delete(s.sessions, id)
+		return PinnedBlock{}, false
+	}
+	ses.expires = now.Add(s.ttl)
+	return ses.block, true
+}
+
+// Middleware returns the middleware of the server, which pins blocks of calls made in sessions. Calls in unknown or
+// expired sessions are refused, so clients don't get results of different blocks unnoticed.
+func (s *Sessions) Middleware() Middleware {
+	return func(next CallHandler) CallHandler {
+		return func(ctx context.Context, call *Call) (json.RawMessage, error) {
+			id := SessionFromContext(ctx)
+			if id == "" {
+				return next(ctx, call)
+			}
+			block, ok := s.Get(id)
+			if !ok {
+				return nil, &invalidRequestError{fmt.Sprintf("unknown or expired session %s", id)}
+			}
+			return next(ContextWithPinnedBlock(ctx, block), call)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+)
+
+type blocksService struct{}
+
+func (blocksService) Number(n BlockNumber) string { return fmt.Sprint(int64(n)) }
+
+func (blocksService) Optional(n *BlockNumber) string {
+	if n == nil {
+		return "nil"
+	}
+	return fmt.Sprint(int64(*n))
+}
+
+func (blocksService) NumberOrHash(bnh BlockNumberOrHash) string {
+	if hash, ok := bnh.Hash(); ok {
+		return fmt.Sprintf("%x %t", hash[30:], bnh.RequireCanonical)
+	}
+	n, _ := bnh.Number()
+	return fmt.Sprint(int64(n))
+}
+
+func TestSessions(t *testing.T) {
+	server := newTestServer()
+	if err := server.RegisterName("blocks", blocksService{}); err != nil {
+		t.Fatal(err)
+	}
+	sessions := NewSessions(time.Minute)
+	server.Use(sessions.Middleware())
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	id := sessions.Open(PinnedBlock{Number: 100, Hash: common.HexToHash("0xabcd")})
+	call := func(session string, method string, args ...interface{}) (string, error) {
+		client, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if session != "" {
+			client.SetHeader(sessionHeader, session)
+		}
+		var result string
+		err = client.Call(&result, method, args...)
+		return result, err
+	}
+	for _, c := range []struct {
+		session, method string
+		args            []interface{}
+		want            string
+	}{
+		{"", "blocks_number", []interface{}{"latest"}, "-1"},
+		{id, "blocks_number", []interface{}{"latest"}, "100"},
+		{id, "blocks_number", []interface{}{"pending"}, "100"},
+		{id, "blocks_number", []interface{}{"0x5"}, "5"},
+		{"", "blocks_optional", nil, "nil"},
+		{id, "blocks_optional", nil, "100"},
+		{id, "blocks_numberOrHash", []interface{}{"latest"}, "abcd true"},
+		{id, "blocks_numberOrHash", []interface{}{"earliest"}, "0"},
+	} {
+		got, err := call(c.session, c.method, c.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s %v in session %q: got %s, want %s", c.method, c.args, c.session, got, c.want)
+		}
+	}
+
+	if !sessions.Close(id) || sessions.Close(id) {
+		t.Error("session must be closed once")
+	}
+	_, err := call(id, "blocks_number", "latest")
+	var rpcErr Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32600 {
+		t.Errorf("expected the error of unknown session, got %v", err)
+	}
+
+	expiring := NewSessions(time.Millisecond)
+	id = expiring.Open(PinnedBlock{Number: 1})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := expiring.Get(id); ok {
+		t.Error("session must expire")
+	}
+}
@@ -219,3 +219,31 @@ func BlockNumberOrHashWithHash(hash common.Hash, canonical bool) BlockNumberOrHa
 		RequireCanonical: canonical,
 	}
 }
+
+// Timestamp is a unix timestamp in seconds, accepted both as a decimal and a hex encoded number
+type Timestamp uint64
+
+// UnmarshalJSON parses the given JSON fragment into a Timestamp. It supports:
+// - the timestamp as a JSON number or decimal string
+// - the timestamp as a hex encoded string
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	input := strings.TrimSpace(string(data))
+	if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
+		input = input[1 : len(input)-1]
+	}
+
+	// Try to parse it as a number
+	ts, err := strconv.ParseUint(input, 10, 64)
+	if err != nil {
+		// Now try as a hex number
+		if ts, err = hexutil.DecodeUint64(input); err != nil {
+			return err
+		}
+	}
+	*t = Timestamp(ts)
+	return nil
+}
+
+func (t Timestamp) Uint64() uint64 {
+	return uint64(t)
+}
@@ -122,3 +122,35 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestTimestampJSONUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected Timestamp
+	}{
+		0: {`"0x"`, true, Timestamp(0)},
+		1: {`"0x0"`, false, Timestamp(0)},
+		2: {`"0x60f98a2c"`, false, Timestamp(1626966572)},
+		3: {`1626966572`, false, Timestamp(1626966572)},
+		4: {`"1626966572"`, false, Timestamp(1626966572)},
+		5: {`"latest"`, true, Timestamp(0)},
+		6: {`-1`, true, Timestamp(0)},
+	}
+
+	for i, test := range tests {
+		var ts Timestamp
+		err := json.Unmarshal([]byte(test.input), &ts)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if ts != test.expected {
+			t.Errorf("Test %d got unexpected value, want %d, got %d", i, test.expected, ts)
+		}
+	}
+}
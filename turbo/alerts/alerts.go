@@ -0,0 +1,219 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Events of alerts, in --alert.events
+const (
+	EventReorg    = "reorg"    // the chain head was reorged deeper than the threshold
+	EventStall    = "stall"    // the chain head didn't advance for the timeout of the watchdog
+	EventDisk     = "disk"     // free space of the datadir is below the threshold
+	EventBadBlock = "badblock" // the sync unwound because of the bad block
+	EventPeers    = "peers"    // the node has less peers than the threshold
+)
+
+// Events are all known events
+var Events = []string{EventReorg, EventStall, EventDisk, EventBadBlock, EventPeers}
+
+const (
+	// alertsQueue - alerts queued above the limit are dropped
+	alertsQueue = 128
+	// deliveryTimeout is the timeout of delivery of one alert to the webhook or by the command
+	deliveryTimeout = 30 * time.Second
+)
+
+// Alert is sent to the webhook and to the command as JSON
+type Alert struct {
+	Event    string                 `json:"event"`
+	Time     time.Time              `json:"time"`
+	Message  string                 `json:"message"`
+	Resolved bool                   `json:"resolved,omitempty"` // the condition of the previous alert of the event is gone
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Check tells if the condition of the event holds now, and describes it
+type Check func() (firing bool, message string, err error)
+
+type namedCheck struct {
+	event  string
+	check  Check
+	firing bool
+}
+
+// Alerter delivers alerts of the enabled events to the webhook (POST of the alert as JSON) and to the command
+// (the alert as JSON on stdin), so that small operators get them without monitoring stack. Conditions, which
+// are polled by checks, are alerted when they start to hold and when they are resolved.
+type Alerter struct {
+	webhook, command string
+	enabled          map[string]bool
+	checks           []*namedCheck
+	queue            chan Alert
+
+	lastHead uint64 // of OnNewHeader, to detect reorgs
+}
+
+// New returns alerter delivering to the webhook URL and to the command, empty - not used
+func New(webhook, command string, events []string) *Alerter {
+	a := &Alerter{webhook: webhook, command: command, enabled: map[string]bool{}, queue: make(chan Alert, alertsQueue)}
+	for _, event := range events {
+		a.enabled[event] = true
+	}
+	return a
+}
+
+// Enabled tells if alerts of the event are delivered
+func (a *Alerter) Enabled(event string) bool {
+	return a.enabled[event]
+}
+
+// Send queues the alert, if its event is enabled. It doesn't wait for the delivery.
+func (a *Alerter) Send(alert Alert) {
+	if !a.enabled[alert.Event] {
+		return
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	select {
+	case a.queue <- alert:
+	default:
+		counter(alert.Event, "dropped").Inc()
+	}
+}
+
+// AddCheck adds the condition of the event polled by Run, if the event is enabled
+func (a *Alerter) AddCheck(event string, check Check) {
+	if a.enabled[event] {
+		a.checks = append(a.checks, &namedCheck{event: event, check: check})
+	}
+}
+
+// ReorgSubscription returns the subscription to new headers (privateapi.Events), which alerts reorgs deeper than depth.
+// After an unwind the sync notifies about headers from the unwind point, so the reorg is the header not above the
+// previous one.
+func (a *Alerter) ReorgSubscription(depth uint64) func(*types.Header) error {
+	return func(h *types.Header) error {
+		number := h.Number.Uint64()
+		if a.lastHead != 0 && number <= a.lastHead && a.lastHead-number+1 > depth {
+			a.Send(Alert{
+				Event:   EventReorg,
+				Message: fmt.Sprintf("chain head %d was reorged to %d blocks deep", a.lastHead, a.lastHead-number+1),
+				Data:    map[string]interface{}{"head": a.lastHead, "depth": a.lastHead - number + 1, "hash": h.Hash()},
+			})
+		}
+		a.lastHead = number
+		return nil
+	}
+}
+
+// OnBadBlock is the hook of the sync (stagedsync.Sync.OnBadBlock)
+func (a *Alerter) OnBadBlock(unwindPoint uint64, badBlock common.Hash) {
+	a.Send(Alert{
+		Event:   EventBadBlock,
+		Message: fmt.Sprintf("bad block %x, the sync unwinds to %d", badBlock, unwindPoint),
+		Data:    map[string]interface{}{"unwindPoint": unwindPoint, "hash": badBlock},
+	})
+}
+
+// Run delivers alerts and polls checks every interval until ctx is done
+func (a *Alerter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-a.queue:
+			a.deliver(ctx, alert)
+		case <-ticker.C:
+			for _, c := range a.checks {
+				a.poll(c)
+			}
+		}
+	}
+}
+
+func (a *Alerter) poll(c *namedCheck) {
+	firing, message, err := c.check()
+	if err != nil {
+		log.Warn("Alert check failed", "event", c.event, "err", err)
+		return
+	}
+	if firing != c.firing {
+		c.firing = firing
+		a.Send(Alert{Event: c.event, Message: message, Resolved: !firing})
+	}
+}
+
+func (a *Alerter) deliver(ctx context.Context, alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Error("Failed to encode alert", "event", alert.Event, "err", err)
+		return
+	}
+	log.Warn("Alert", "event", alert.Event, "message", alert.Message, "resolved", alert.Resolved)
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	if a.webhook != "" {
+		if err := postWebhook(ctx, a.webhook, body); err != nil {
+			counter(alert.Event, "failed").Inc()
+			log.Error("Failed to post alert to the webhook", "event", alert.Event, "err", err)
+		} else {
+			counter(alert.Event, "sent").Inc()
+		}
+	}
+	if a.command != "" {
+		if err := runCommand(ctx, a.command, alert, body); err != nil {
+			counter(alert.Event, "failed").Inc()
+			log.Error("Failed to run the alert command", "event", alert.Event, "err", err)
+		} else {
+			counter(alert.Event, "sent").Inc()
+		}
+	}
+}
+
+func counter(event, result string) *metrics.Counter {
+	return metrics.GetOrCreateCounter(fmt.Sprintf(`alerts{event=%q,result=%q}`, event, result))
+}
+
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// runCommand runs the command with the alert as JSON on stdin, and its event and message in ALERT_EVENT and
+// ALERT_MESSAGE environment variables
+func runCommand(ctx context.Context, command string, alert Alert, body []byte) error {
+	cmd := exec.CommandContext(ctx, command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "ALERT_EVENT="+alert.Event, "ALERT_MESSAGE="+alert.Message)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlerter(t *testing.T) {
+	var mu sync.Mutex
+	var received []Alert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, alert)
+	}))
+	defer ts.Close()
+	alerts := func() []Alert {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Alert(nil), received...)
+	}
+
+	a := New(ts.URL, "", []string{EventReorg, EventBadBlock, EventPeers})
+	peers := 5
+	var peersMu sync.Mutex
+	a.AddCheck(EventPeers, PeersCheck(func() (int, error) {
+		peersMu.Lock()
+		defer peersMu.Unlock()
+		return peers, nil
+	}, 3))
+	a.AddCheck(EventDisk, DiskCheck(t.TempDir(), 0)) // disabled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx, 10*time.Millisecond)
+
+	onHeader := a.ReorgSubscription(2)
+	for _, number := range []int64{10, 11, 12, 11, 12, 10} { // reorgs of 2 and 3 blocks
+		require.NoError(t, onHeader(&types.Header{Number: big.NewInt(number)}))
+	}
+	a.OnBadBlock(9, common.Hash{1})
+	a.Send(Alert{Event: EventStall}) // disabled
+	require.Eventually(t, func() bool { return len(alerts()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, EventReorg, alerts()[0].Event)
+	require.EqualValues(t, 3, alerts()[0].Data["depth"])
+	require.Equal(t, EventBadBlock, alerts()[1].Event)
+
+	// the condition is alerted when it starts to hold and when it's resolved
+	peersMu.Lock()
+	peers = 1
+	peersMu.Unlock()
+	require.Eventually(t, func() bool { return len(alerts()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	peersMu.Lock()
+	peers = 4
+	peersMu.Unlock()
+	require.Eventually(t, func() bool { return len(alerts()) == 4 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, EventPeers, alerts()[2].Event)
+	require.False(t, alerts()[2].Resolved)
+	require.Equal(t, EventPeers, alerts()[3].Event)
+	require.True(t, alerts()[3].Resolved)
+}
+
+func TestAlertCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is a shell script")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "alert.json")
+	command := filepath.Join(dir, "alert.sh")
+	require.NoError(t, ioutil.WriteFile(command, []byte("#!/bin/sh\ncat > "+out+"\necho $ALERT_EVENT >> "+out+"\n"), 0755))
+
+	a := New("", command, []string{EventBadBlock})
+	a.OnBadBlock(9, common.Hash{1})
+	a.deliver(context.Background(), <-a.queue)
+	b, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	// the alert on stdin, then the event from the environment
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var alert Alert
+	require.NoError(t, dec.Decode(&alert))
+	require.Equal(t, EventBadBlock, alert.Event)
+	rest, err := ioutil.ReadAll(dec.Buffered())
+	require.NoError(t, err)
+	require.Equal(t, EventBadBlock, strings.TrimSpace(string(rest)))
+}
@@ -0,0 +1,29 @@
+package alerts
+
+import (
+	"fmt"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// DiskCheck fires when free space of the disk of the dir is below min
+func DiskCheck(dir string, min datasize.ByteSize) Check {
+	return func() (bool, string, error) {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			return false, "", err
+		}
+		return free < uint64(min), fmt.Sprintf("free disk space of %s is %s, the threshold is %s", dir, datasize.ByteSize(free).HR(), min.HR()), nil
+	}
+}
+
+// PeersCheck fires when the amount of peers returned by count is below min
+func PeersCheck(count func() (int, error), min int) Check {
+	return func() (bool, string, error) {
+		peers, err := count()
+		if err != nil {
+			return false, "", err
+		}
+		return peers < min, fmt.Sprintf("the node has %d peers, the threshold is %d", peers, min), nil
+	}
+}
@@ -0,0 +1,12 @@
+// +build !linux,!darwin,!windows
+
+package alerts
+
+import (
+	"errors"
+	"runtime"
+)
+
+func freeDiskSpace(string) (uint64, error) {
+	return 0, errors.New("free disk space is not supported on " + runtime.GOOS)
+}
@@ -14,12 +14,15 @@ var DefaultFlags = []cli.Flag{
 	utils.TxPoolNoLocalsFlag,
 	utils.TxPoolJournalFlag,
 	utils.TxPoolRejournalFlag,
+	utils.TxPoolSnapshotFlag,
+	utils.TxPoolSnapshotIntervalFlag,
 	utils.TxPoolPriceLimitFlag,
 	utils.TxPoolPriceBumpFlag,
 	utils.TxPoolAccountSlotsFlag,
 	utils.TxPoolGlobalSlotsFlag,
 	utils.TxPoolAccountQueueFlag,
 	utils.TxPoolGlobalQueueFlag,
+	utils.TxPoolGlobalBytesFlag,
 	utils.TxPoolLifetimeFlag,
 	PruneFlag,
 	PruneHistoryFlag,
@@ -30,16 +33,68 @@ var DefaultFlags = []cli.Flag{
 	SeedSnapshotsFlag,
 	SnapshotDatabaseLayoutFlag,
 	ExternalSnapshotDownloaderAddrFlag,
+	HistoryFilesFlag,
+	HistoryFilesKeepFlag,
+	LogArchiveFlag,
+	LogArchiveKeepFlag,
+	CrossCheckURLFlag,
+	CrossCheckEveryFlag,
+	CrossCheckHaltFlag,
 	BatchSizeFlag,
+	CommitDirtyShareFlag,
+	CommitEveryFlag,
 	BlockDownloaderWindowFlag,
+	HeaderSegmentFillersFlag,
+	StateCachePolicyFlag,
+	StateCacheAccountsFlag,
+	StateCacheStorageFlag,
+	StateCacheCodeFlag,
+	StateProfileRateFlag,
+	StateProfileWindowFlag,
+	StateProfileWindowsFlag,
+	P2PServingUploadRateFlag,
+	P2PServingRequestRateFlag,
 	DatabaseVerbosityFlag,
 	PrivateApiAddr,
+	PrivateApiReplicationLog,
+	PrivateApiMaxCursors,
+	PrivateApiTxLifetime,
+	PrivateApiTxIdleTimeout,
+	PrivateApiPinLifetime,
+	PrivateApiAllowBuckets,
+	PrivateApiDenyBuckets,
+	PrivateApiAdminToken,
+	PrivateApiKeepaliveTime,
+	PrivateApiKeepaliveTimeout,
+	PrivateApiKeepaliveMinTime,
+	PrivateApiConnIdle,
+	PrivateApiMaxRecvMsg,
+	PrivateApiMaxSendMsg,
+	PrivateApiWindowSize,
+	PrivateApiConnWindowSize,
 	EtlBufferSizeFlag,
+	EtlSortWorkersFlag,
+	EtlLoadReadAheadFlag,
 	TLSFlag,
 	TLSCertFlag,
 	TLSKeyFlag,
 	TLSCACertFlag,
 	SyncLoopThrottleFlag,
+	SyncSourceFlag,
+	SyncSourceToFlag,
+	WatchdogTimeoutFlag,
+	WatchdogActionsFlag,
+	WatchdogWebhookFlag,
+	WatchdogPeersFlag,
+	DiagnosticsTxsFlag,
+	NoAddressActivityFlag,
+	ShutdownTimeoutFlag,
+	AlertWebhookFlag,
+	AlertCommandFlag,
+	AlertEventsFlag,
+	AlertReorgDepthFlag,
+	AlertDiskFreeFlag,
+	AlertPeersFlag,
 	BadBlockFlag,
 	utils.ListenPortFlag,
 	utils.ListenPort65Flag,
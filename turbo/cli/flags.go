@@ -10,9 +10,15 @@ import (
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/common/etl"
 	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/ethdb/privateapi"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
+	"github.com/ledgerwatch/erigon/ethdb/remotedbserver"
+	"github.com/ledgerwatch/erigon/internal/flags"
 	"github.com/ledgerwatch/erigon/node"
+	"github.com/ledgerwatch/erigon/turbo/alerts"
+	"github.com/ledgerwatch/erigon/turbo/diagnostics"
 	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
+	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
 	"github.com/ledgerwatch/log/v3"
 	"github.com/spf13/pflag"
 	"github.com/urfave/cli"
@@ -26,23 +32,101 @@ var (
 	}
 	BatchSizeFlag = cli.StringFlag{
 		Name:  "batchSize",
-		Usage: "Batch size for the execution stage",
+		Usage: "Batch size for the execution stage, full batch is flushed into the DB transaction, see --sync.commit.dirty",
 		Value: "512M",
 	}
+	CommitDirtyShareFlag = cli.Float64Flag{
+		Name:  "sync.commit.dirty",
+		Usage: "Commit the execution stage when dirty pages take this share of the DB limit of dirty pages (which grows with RAM), 0 - commit every batch",
+		Value: ethconfig.Defaults.Commit.DirtyShare,
+	}
+	CommitEveryFlag = cli.DurationFlag{
+		Name:  "sync.commit.every",
+		Usage: "Commit the execution stage at least this often, 0 - no limit",
+		Value: ethconfig.Defaults.Commit.Every,
+	}
 	EtlBufferSizeFlag = cli.StringFlag{
 		Name:  "etl.bufferSize",
 		Usage: "Buffer size for ETL operations.",
 		Value: etl.BufferOptimalSize.String(),
 	}
+	EtlSortWorkersFlag = cli.IntFlag{
+		Name:  "etl.sort.workers",
+		Usage: "Full buffers of ETL operations sorted and written to disk in the background, each takes memory of --etl.bufferSize (0 - by the collecting goroutine)",
+		Value: etl.SortWorkers,
+	}
+	EtlLoadReadAheadFlag = cli.IntFlag{
+		Name:  "etl.load.readahead",
+		Usage: "Entries of every file of ETL operations decoded in the background during loading into tables (0 - by the loading goroutine)",
+		Value: etl.LoadReadAhead,
+	}
 	BlockDownloaderWindowFlag = cli.IntFlag{
 		Name:  "blockDownloaderWindow",
 		Usage: "Outstanding limit of block bodies being downloaded",
 		Value: 32768,
 	}
+	StateCachePolicyFlag = cli.StringFlag{
+		Name:  "state.cache.policy",
+		Usage: "Eviction policy of the cache of accounts, storage and code for execution of blocks: lru or arc",
+		Value: ethconfig.Defaults.StateCache.Policy,
+	}
+	StateCacheAccountsFlag = cli.IntFlag{
+		Name:  "state.cache.accounts",
+		Usage: "Accounts kept in the state cache (0 - not cached)",
+		Value: ethconfig.Defaults.StateCache.Accounts,
+	}
+	StateCacheStorageFlag = cli.IntFlag{
+		Name:  "state.cache.storage",
+		Usage: "Storage slots kept in the state cache (0 - not cached)",
+		Value: ethconfig.Defaults.StateCache.Storage,
+	}
+	StateCacheCodeFlag = cli.IntFlag{
+		Name:  "state.cache.code",
+		Usage: "Contract codes kept in the state cache (0 - not cached)",
+		Value: ethconfig.Defaults.StateCache.Code,
+	}
+	StateProfileRateFlag = cli.IntFlag{
+		Name:  "state.profile.rate",
+		Usage: "Sample every N-th read of accounts and storage slots by execution of blocks, the hottest ones are served by debug_stateAccessProfile (0 - not profiled)",
+		Value: ethconfig.Defaults.StateProfile.SampleRate,
+	}
+	StateProfileWindowFlag = cli.DurationFlag{
+		Name:  "state.profile.window",
+		Usage: "Samples of the state profile are aggregated by windows of this duration",
+		Value: ethconfig.Defaults.StateProfile.Window,
+	}
+	StateProfileWindowsFlag = cli.IntFlag{
+		Name:  "state.profile.windows",
+		Usage: "The last windows of the state profile kept",
+		Value: ethconfig.Defaults.StateProfile.Windows,
+	}
+	HeaderSegmentFillersFlag = cli.IntFlag{
+		Name:  "headers.segment.fillers",
+		Usage: "Requests filling the gap below one anchor of downloaded headers from different peers in parallel (1 - one segment at a time)",
+		Value: headerdownload.DefaultSegmentFillers,
+	}
+	ConfigFlag = cli.StringFlag{
+		Name:  flags.ConfigFlagName,
+		Usage: flags.ConfigFlagUsage,
+	}
+	PrintEffectiveConfigFlag = cli.BoolFlag{
+		Name:  flags.PrintEffectiveConfigFlagName,
+		Usage: flags.PrintEffectiveConfigFlagUsage,
+	}
+	P2PServingUploadRateFlag = cli.StringFlag{
+		Name:  "p2p.serving.uploadrate",
+		Usage: "Limit of upload bandwidth (per second) used to serve block bodies and receipts to peers, 0 - no limit. Example: 10MB",
+		Value: "0",
+	}
+	P2PServingRequestRateFlag = cli.Float64Flag{
+		Name:  "p2p.serving.requestrate",
+		Usage: "Limit of GetBlockBodies and GetReceipts requests per second served to one peer, requests above it are left unanswered. 0 - no limit",
+		Value: 0,
+	}
 
 	PrivateApiAddr = cli.StringFlag{
 		Name:  "private.api.addr",
-		Usage: "private api network address, for example: 127.0.0.1:9090, empty string means not to start the listener. do not expose to public network. serves remote database interface",
+		Usage: "private api network address, for example: 127.0.0.1:9090 or unix:///path/to/socket, empty string means not to start the listener. do not expose to public network. serves remote database interface",
 		Value: "127.0.0.1:9090",
 	}
 
@@ -52,6 +136,102 @@ var (
 		Value: 500,
 	}
 
+	PrivateApiReplicationLog = cli.StringFlag{
+		Name:  "private.api.replication.log",
+		Usage: "Keep this much of recent changes of RPC tables in memory and stream them to rpcdaemon read replicas (rpcdaemon --replica.dir). Replicas which fall behind more than that copy the tables again. Empty string disables replication. Example: 512MB",
+		Value: "",
+	}
+
+	PrivateApiMaxCursors = cli.IntFlag{
+		Name:  "private.api.maxcursors",
+		Usage: "Limit of cursors open in one remote transaction, clients which open more get an error. 0 - no limit",
+		Value: remotedbserver.DefaultLimits.MaxCursors,
+	}
+
+	PrivateApiTxLifetime = cli.DurationFlag{
+		Name:  "private.api.tx.lifetime",
+		Usage: "Remote transactions open longer than this are closed by the server. 0 - no limit",
+		Value: remotedbserver.DefaultLimits.MaxTxLifetime,
+	}
+
+	PrivateApiTxIdleTimeout = cli.DurationFlag{
+		Name:  "private.api.tx.idle",
+		Usage: "Remote transactions, in which the client sends no requests for so long, are closed by the server. 0 - no limit",
+		Value: remotedbserver.DefaultLimits.IdleTimeout,
+	}
+
+	PrivateApiPinLifetime = cli.DurationFlag{
+		Name:  "private.api.pin.lifetime",
+		Usage: "Views of the database pinned by remote clients longer than this are released, transactions at them are closed by the server. 0 - no limit",
+		Value: remotedbserver.DefaultLimits.MaxPinLifetime,
+	}
+
+	PrivateApiAllowBuckets = cli.StringFlag{
+		Name:  "private.api.buckets.allow",
+		Usage: "Comma separated buckets, which remote transactions can read, f.e. Header,BlockBody,Receipt,CanonicalHeader,HeaderNumber to hand out headers and receipts only. Transactions, which touch other buckets, are closed. Empty - all buckets",
+		Value: "",
+	}
+
+	PrivateApiDenyBuckets = cli.StringFlag{
+		Name:  "private.api.buckets.deny",
+		Usage: "Comma separated buckets, which remote transactions can't read, even if they are in --private.api.buckets.allow",
+		Value: "",
+	}
+
+	PrivateApiAdminToken = cli.StringFlag{
+		Name:  "private.api.admin.token",
+		Usage: "Remote clients, which send this token, can create, clear and drop auxiliary tables (not of the schema of Erigon) by maintenance tools, f.e. 'integration remote_bucket'. Use it with --tls. Empty - disabled",
+		Value: "",
+	}
+
+	PrivateApiKeepaliveTime = cli.DurationFlag{
+		Name:  "private.api.keepalive.time",
+		Usage: "Ping connections of the private API idle for so long, so load balancers and NATs between Erigon and rpcdaemon don't drop them. 0 - 2h",
+		Value: 0,
+	}
+
+	PrivateApiKeepaliveTimeout = cli.DurationFlag{
+		Name:  "private.api.keepalive.timeout",
+		Usage: "Close connections of the private API, which don't acknowledge a ping in so long. 0 - 20s",
+		Value: 0,
+	}
+
+	PrivateApiKeepaliveMinTime = cli.DurationFlag{
+		Name:  "private.api.keepalive.min.time",
+		Usage: "Disconnect clients of the private API, which ping more often than this (see rpcdaemon --private.api.keepalive.time)",
+		Value: privateapi.DefaultConnParams.MinPingInterval,
+	}
+
+	PrivateApiConnIdle = cli.DurationFlag{
+		Name:  "private.api.conn.idle",
+		Usage: "Gracefully close connections of the private API without calls for so long, clients reconnect on the next call. Set it below the idle timeout of load balancers in front of Erigon. 0 - never",
+		Value: 0,
+	}
+
+	PrivateApiMaxRecvMsg = cli.StringFlag{
+		Name:  "private.api.max.recv.msg",
+		Usage: "Size limit of requests to the private API, f.e. 16MB. Empty - 4MB",
+		Value: "",
+	}
+
+	PrivateApiMaxSendMsg = cli.StringFlag{
+		Name:  "private.api.max.send.msg",
+		Usage: "Size limit of replies of the private API, f.e. 64MB. Empty - no limit",
+		Value: "",
+	}
+
+	PrivateApiWindowSize = cli.StringFlag{
+		Name:  "private.api.window.size",
+		Usage: "Initial flow control window of streams of the private API, f.e. 1MB. Bigger windows speed up big replies over links with a high latency. Empty - grown dynamically by gRPC",
+		Value: "",
+	}
+
+	PrivateApiConnWindowSize = cli.StringFlag{
+		Name:  "private.api.conn.window.size",
+		Usage: "Initial flow control window of connections of the private API, f.e. 4MB. Empty - grown dynamically by gRPC",
+		Value: "",
+	}
+
 	MaxPeersFlag = cli.IntFlag{
 		Name:  "maxpeers",
 		Usage: "Maximum number of network peers (network disabled if set to 0)",
@@ -118,6 +298,40 @@ var (
 		Usage: `enable external snapshot downloader`,
 	}
 
+	HistoryFilesFlag = cli.BoolFlag{
+		Name:  "history.files",
+		Usage: `Move changesets and history indices of old blocks from the DB into compressed immutable files in <datadir>/erigon/history (experimental, can't be reverted)`,
+	}
+	HistoryFilesKeepFlag = cli.Uint64Flag{
+		Name:  "history.files.keep",
+		Usage: `Amount of recent blocks, history of which stays in the DB (blocks below can't be unwound)`,
+		Value: ethconfig.Defaults.HistoryFiles.Keep,
+	}
+
+	LogArchiveFlag = cli.BoolFlag{
+		Name:  "logs.archive",
+		Usage: `Append logs of old blocks to the append-only archive in <datadir>/erigon/logs, partitioned by 100K blocks with bloom indices (served by eth_getLogs of local rpcdaemon)`,
+	}
+	LogArchiveKeepFlag = cli.Uint64Flag{
+		Name:  "logs.archive.keep",
+		Usage: `Amount of recent blocks, logs of which are not archived yet (blocks below can't be unwound)`,
+		Value: ethconfig.Defaults.LogArchive.Keep,
+	}
+
+	CrossCheckURLFlag = cli.StringFlag{
+		Name:  "crosscheck.url",
+		Usage: `JSON-RPC endpoint of the trusted node, state and receipts roots of synced blocks are compared with its blocks`,
+	}
+	CrossCheckEveryFlag = cli.Uint64Flag{
+		Name:  "crosscheck.every",
+		Usage: `Distance between the blocks compared with the trusted node`,
+		Value: ethconfig.Defaults.CrossCheck.Every,
+	}
+	CrossCheckHaltFlag = cli.BoolFlag{
+		Name:  "crosscheck.halt",
+		Usage: `Stop the sync at the block which differs from the trusted node (by default it's only logged as an error)`,
+	}
+
 	// mTLS flags
 	TLSFlag = cli.BoolFlag{
 		Name:  "tls",
@@ -150,6 +364,79 @@ var (
 		Value: "",
 	}
 
+	SyncSourceFlag = cli.StringFlag{
+		Name:  "sync.source",
+		Usage: "Take headers and bodies from another local datadir, chaindata or directory of headers and bodies snapshots instead of peers, and run all other stages as usual (for replay of blocks and nodes without network, use with --nodiscover --maxpeers=0)",
+	}
+	SyncSourceToFlag = cli.Uint64Flag{
+		Name:  "sync.source.to",
+		Usage: "Last block to take from --sync.source (default: all blocks of the source)",
+	}
+
+	WatchdogTimeoutFlag = cli.DurationFlag{
+		Name:  "watchdog.timeout",
+		Usage: "Execute --watchdog.actions when the chain head doesn't advance for this time, and again after every next one (0 - disabled)",
+	}
+	WatchdogActionsFlag = cli.StringFlag{
+		Name:  "watchdog.actions",
+		Usage: "Comma separated recovery actions of the watchdog, in the order of execution: " + strings.Join(diagnostics.WatchdogActions, ","),
+		Value: strings.Join(ethconfig.Defaults.Watchdog.Actions, ","),
+	}
+	WatchdogWebhookFlag = cli.StringFlag{
+		Name:  "watchdog.webhook",
+		Usage: "URL, to which the webhook action of the watchdog POSTs the stalled head as JSON",
+	}
+	WatchdogPeersFlag = cli.Float64Flag{
+		Name:  "watchdog.peers",
+		Usage: "Share of the peers disconnected by the rotate-peers action of the watchdog",
+		Value: ethconfig.Defaults.Watchdog.Peers,
+	}
+
+	DiagnosticsTxsFlag = cli.BoolFlag{
+		Name:  "diagnostics.txs",
+		Usage: "Track open DB transactions with their callers for the diagnostics bundle (txs.txt). Costs a walk of the stack per transaction",
+	}
+
+	NoAddressActivityFlag = cli.BoolFlag{
+		Name:  "noaddressactivity",
+		Usage: "Don't maintain first and last activity blocks of addresses during execution, erigon_getAddressActivity finds nothing then. Saves space and time of execution, the activity is not restored if the flag is removed later",
+	}
+
+	ShutdownTimeoutFlag = cli.DurationFlag{
+		Name:  "shutdown.timeout",
+		Usage: "Deadline of the shutdown: the sync stops at a safe boundary of stages and commits its progress before it, after it the running stage is aborted and its uncommitted work is lost (0 - no deadline)",
+		Value: ethconfig.Defaults.ShutdownTimeout,
+	}
+
+	AlertWebhookFlag = cli.StringFlag{
+		Name:  "alert.webhook",
+		Usage: "URL, to which alerts of --alert.events are POSTed as JSON",
+	}
+	AlertCommandFlag = cli.StringFlag{
+		Name:  "alert.command",
+		Usage: "Executable run on every alert of --alert.events, with the alert as JSON on stdin",
+	}
+	AlertEventsFlag = cli.StringFlag{
+		Name:  "alert.events",
+		Usage: "Comma separated events alerted to --alert.webhook and --alert.command: " + strings.Join(alerts.Events, ","),
+		Value: strings.Join(ethconfig.Defaults.Alerts.Events, ","),
+	}
+	AlertReorgDepthFlag = cli.Uint64Flag{
+		Name:  "alert.reorg.depth",
+		Usage: "Reorgs of more blocks are alerted",
+		Value: ethconfig.Defaults.Alerts.ReorgDepth,
+	}
+	AlertDiskFreeFlag = cli.StringFlag{
+		Name:  "alert.disk.free",
+		Usage: "Free space of the disk of the datadir, below which it's alerted",
+		Value: ethconfig.Defaults.Alerts.DiskFree.String(),
+	}
+	AlertPeersFlag = cli.IntFlag{
+		Name:  "alert.peers",
+		Usage: "Amount of peers, below which it's alerted",
+		Value: ethconfig.Defaults.Alerts.MinPeers,
+	}
+
 	BadBlockFlag = cli.IntFlag{
 		Name:  "bad.block",
 		Usage: "Marks block with given number bad and forces initial reorg before normal staged sync",
@@ -179,6 +466,25 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 	cfg.Snapshot.Seeding = ctx.GlobalBool(SeedSnapshotsFlag.Name)
 	cfg.Snapshot.Enabled = ctx.GlobalBool(SnapshotDatabaseLayoutFlag.Name)
 
+	cfg.HistoryFiles.Enabled = ctx.GlobalBool(HistoryFilesFlag.Name)
+	cfg.HistoryFiles.Keep = ctx.GlobalUint64(HistoryFilesKeepFlag.Name)
+	if cfg.HistoryFiles.Enabled && cfg.Prune.History.Enabled() {
+		utils.Fatalf("--%s can't be used together with pruning of history", HistoryFilesFlag.Name)
+	}
+
+	cfg.LogArchive.Enabled = ctx.GlobalBool(LogArchiveFlag.Name)
+	cfg.LogArchive.Keep = ctx.GlobalUint64(LogArchiveKeepFlag.Name)
+	if cfg.LogArchive.Enabled && cfg.Prune.Receipts.Enabled() {
+		utils.Fatalf("--%s can't be used together with pruning of receipts", LogArchiveFlag.Name)
+	}
+
+	cfg.CrossCheck.URL = ctx.GlobalString(CrossCheckURLFlag.Name)
+	cfg.CrossCheck.Every = ctx.GlobalUint64(CrossCheckEveryFlag.Name)
+	cfg.CrossCheck.Halt = ctx.GlobalBool(CrossCheckHaltFlag.Name)
+	if cfg.CrossCheck.Every == 0 {
+		utils.Fatalf("--%s must be positive", CrossCheckEveryFlag.Name)
+	}
+
 	if ctx.GlobalString(BatchSizeFlag.Name) != "" {
 		err := cfg.BatchSize.UnmarshalText([]byte(ctx.GlobalString(BatchSizeFlag.Name)))
 		if err != nil {
@@ -186,6 +492,12 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		}
 	}
 
+	cfg.Commit.DirtyShare = ctx.GlobalFloat64(CommitDirtyShareFlag.Name)
+	cfg.Commit.Every = ctx.GlobalDuration(CommitEveryFlag.Name)
+	if cfg.Commit.DirtyShare < 0 || cfg.Commit.DirtyShare > 1 {
+		utils.Fatalf("--%s must be between 0 and 1", CommitDirtyShareFlag.Name)
+	}
+
 	if ctx.GlobalString(EtlBufferSizeFlag.Name) != "" {
 		sizeVal := datasize.ByteSize(0)
 		size := &sizeVal
@@ -195,10 +507,27 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		}
 		etl.BufferOptimalSize = *size
 	}
+	etl.SortWorkers = ctx.GlobalInt(EtlSortWorkersFlag.Name)
+	etl.LoadReadAhead = ctx.GlobalInt(EtlLoadReadAheadFlag.Name)
+	if etl.SortWorkers < 0 || etl.LoadReadAhead < 0 {
+		utils.Fatalf("--%s and --%s must not be negative", EtlSortWorkersFlag.Name, EtlLoadReadAheadFlag.Name)
+	}
 
 	cfg.ExternalSnapshotDownloaderAddr = ctx.GlobalString(ExternalSnapshotDownloaderAddrFlag.Name)
 	cfg.StateStream = ctx.GlobalBool(StateStreamFlag.Name)
 	cfg.BlockDownloaderWindow = ctx.GlobalInt(BlockDownloaderWindowFlag.Name)
+	cfg.HeaderSegmentFillers = ctx.GlobalInt(HeaderSegmentFillersFlag.Name)
+	cfg.StateCache.Policy = ctx.GlobalString(StateCachePolicyFlag.Name)
+	cfg.StateCache.Accounts = ctx.GlobalInt(StateCacheAccountsFlag.Name)
+	cfg.StateCache.Storage = ctx.GlobalInt(StateCacheStorageFlag.Name)
+	cfg.StateCache.Code = ctx.GlobalInt(StateCacheCodeFlag.Name)
+	cfg.StateProfile.SampleRate = ctx.GlobalInt(StateProfileRateFlag.Name)
+	cfg.StateProfile.Window = ctx.GlobalDuration(StateProfileWindowFlag.Name)
+	cfg.StateProfile.Windows = ctx.GlobalInt(StateProfileWindowsFlag.Name)
+	if err := cfg.P2PServingUploadRate.UnmarshalText([]byte(ctx.GlobalString(P2PServingUploadRateFlag.Name))); err != nil {
+		utils.Fatalf("Invalid %s provided: %v", P2PServingUploadRateFlag.Name, err)
+	}
+	cfg.P2PServingRequestRate = ctx.GlobalFloat64(P2PServingRequestRateFlag.Name)
 
 	if ctx.GlobalString(SyncLoopThrottleFlag.Name) != "" {
 		syncLoopThrottle, err := time.ParseDuration(ctx.GlobalString(SyncLoopThrottleFlag.Name))
@@ -207,7 +536,64 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		}
 		cfg.SyncLoopThrottle = syncLoopThrottle
 	}
+	cfg.Watchdog.Timeout = ctx.GlobalDuration(WatchdogTimeoutFlag.Name)
+	cfg.Watchdog.Actions = nil
+	for _, action := range strings.Split(ctx.GlobalString(WatchdogActionsFlag.Name), ",") {
+		if action = strings.TrimSpace(action); action == "" {
+			continue
+		}
+		known := false
+		for _, a := range diagnostics.WatchdogActions {
+			known = known || a == action
+		}
+		if !known {
+			utils.Fatalf("Unknown action %s in --%s", action, WatchdogActionsFlag.Name)
+		}
+		cfg.Watchdog.Actions = append(cfg.Watchdog.Actions, action)
+	}
+	cfg.Watchdog.Webhook = ctx.GlobalString(WatchdogWebhookFlag.Name)
+	cfg.Watchdog.Peers = ctx.GlobalFloat64(WatchdogPeersFlag.Name)
+	if cfg.Watchdog.Timeout < 0 {
+		utils.Fatalf("--%s must not be negative", WatchdogTimeoutFlag.Name)
+	}
+	if cfg.Watchdog.Peers < 0 || cfg.Watchdog.Peers > 1 {
+		utils.Fatalf("--%s must be between 0 and 1", WatchdogPeersFlag.Name)
+	}
+	for _, action := range cfg.Watchdog.Actions {
+		if action == diagnostics.ActionWebhook && cfg.Watchdog.Webhook == "" {
+			utils.Fatalf("--%s is required by the webhook action", WatchdogWebhookFlag.Name)
+		}
+	}
+	cfg.Alerts.Webhook = ctx.GlobalString(AlertWebhookFlag.Name)
+	cfg.Alerts.Command = ctx.GlobalString(AlertCommandFlag.Name)
+	cfg.Alerts.Events = nil
+	for _, event := range strings.Split(ctx.GlobalString(AlertEventsFlag.Name), ",") {
+		if event = strings.TrimSpace(event); event == "" {
+			continue
+		}
+		known := false
+		for _, e := range alerts.Events {
+			known = known || e == event
+		}
+		if !known {
+			utils.Fatalf("Unknown event %s in --%s", event, AlertEventsFlag.Name)
+		}
+		cfg.Alerts.Events = append(cfg.Alerts.Events, event)
+	}
+	cfg.Alerts.ReorgDepth = ctx.GlobalUint64(AlertReorgDepthFlag.Name)
+	if err := cfg.Alerts.DiskFree.UnmarshalText([]byte(ctx.GlobalString(AlertDiskFreeFlag.Name))); err != nil {
+		utils.Fatalf("Invalid %s provided: %v", AlertDiskFreeFlag.Name, err)
+	}
+	cfg.Alerts.MinPeers = ctx.GlobalInt(AlertPeersFlag.Name)
 	cfg.BadBlock = uint64(ctx.GlobalInt(BadBlockFlag.Name))
+	cfg.SyncSource.Path = ctx.GlobalString(SyncSourceFlag.Name)
+	cfg.SyncSource.To = ctx.GlobalUint64(SyncSourceToFlag.Name)
+	cfg.NoAddressActivity = ctx.GlobalBool(NoAddressActivityFlag.Name)
+	cfg.DiagnosticsTxs = ctx.GlobalBool(DiagnosticsTxsFlag.Name)
+	cfg.ShutdownTimeout = ctx.GlobalDuration(ShutdownTimeoutFlag.Name)
+	if cfg.ShutdownTimeout < 0 {
+		utils.Fatalf("--%s must not be negative", ShutdownTimeoutFlag.Name)
+	}
 }
 
 func ApplyFlagsForEthConfigCobra(f *pflag.FlagSet, cfg *ethconfig.Config) {
@@ -251,6 +637,12 @@ func ApplyFlagsForEthConfigCobra(f *pflag.FlagSet, cfg *ethconfig.Config) {
 			utils.Fatalf("Invalid batchSize provided: %v", err)
 		}
 	}
+	if v := f.Float64(CommitDirtyShareFlag.Name, CommitDirtyShareFlag.Value, CommitDirtyShareFlag.Usage); v != nil {
+		cfg.Commit.DirtyShare = *v
+	}
+	if v := f.Duration(CommitEveryFlag.Name, CommitEveryFlag.Value, CommitEveryFlag.Usage); v != nil {
+		cfg.Commit.Every = *v
+	}
 	if v := f.String(EtlBufferSizeFlag.Name, EtlBufferSizeFlag.Value, EtlBufferSizeFlag.Usage); v != nil {
 		sizeVal := datasize.ByteSize(0)
 		size := &sizeVal
@@ -260,6 +652,34 @@ func ApplyFlagsForEthConfigCobra(f *pflag.FlagSet, cfg *ethconfig.Config) {
 		}
 		etl.BufferOptimalSize = *size
 	}
+	if v := f.Int(EtlSortWorkersFlag.Name, EtlSortWorkersFlag.Value, EtlSortWorkersFlag.Usage); v != nil {
+		etl.SortWorkers = *v
+	}
+	if v := f.Int(EtlLoadReadAheadFlag.Name, EtlLoadReadAheadFlag.Value, EtlLoadReadAheadFlag.Usage); v != nil {
+		etl.LoadReadAhead = *v
+	}
+
+	if v := f.String(StateCachePolicyFlag.Name, StateCachePolicyFlag.Value, StateCachePolicyFlag.Usage); v != nil {
+		cfg.StateCache.Policy = *v
+	}
+	if v := f.Int(StateCacheAccountsFlag.Name, StateCacheAccountsFlag.Value, StateCacheAccountsFlag.Usage); v != nil {
+		cfg.StateCache.Accounts = *v
+	}
+	if v := f.Int(StateCacheStorageFlag.Name, StateCacheStorageFlag.Value, StateCacheStorageFlag.Usage); v != nil {
+		cfg.StateCache.Storage = *v
+	}
+	if v := f.Int(StateCacheCodeFlag.Name, StateCacheCodeFlag.Value, StateCacheCodeFlag.Usage); v != nil {
+		cfg.StateCache.Code = *v
+	}
+	if v := f.Int(StateProfileRateFlag.Name, StateProfileRateFlag.Value, StateProfileRateFlag.Usage); v != nil {
+		cfg.StateProfile.SampleRate = *v
+	}
+	if v := f.Duration(StateProfileWindowFlag.Name, StateProfileWindowFlag.Value, StateProfileWindowFlag.Usage); v != nil {
+		cfg.StateProfile.Window = *v
+	}
+	if v := f.Int(StateProfileWindowsFlag.Name, StateProfileWindowsFlag.Value, StateProfileWindowsFlag.Usage); v != nil {
+		cfg.StateProfile.Windows = *v
+	}
 
 	if v := f.String(ExternalSnapshotDownloaderAddrFlag.Name, ExternalSnapshotDownloaderAddrFlag.Value, ExternalSnapshotDownloaderAddrFlag.Usage); v != nil {
 		cfg.ExternalSnapshotDownloaderAddr = *v
@@ -284,6 +704,41 @@ func setPrivateApi(ctx *cli.Context, cfg *node.Config) {
 		log.Warn("private.api.ratelimit is too big", "force", maxRateLimit)
 		cfg.PrivateApiRateLimit = maxRateLimit
 	}
+	if v := ctx.GlobalString(PrivateApiReplicationLog.Name); v != "" {
+		if err := cfg.PrivateApiReplicationLog.UnmarshalText([]byte(v)); err != nil {
+			utils.Fatalf("Invalid %s provided: %v", PrivateApiReplicationLog.Name, err)
+		}
+	}
+	cfg.PrivateApiMaxCursors = ctx.GlobalInt(PrivateApiMaxCursors.Name)
+	cfg.PrivateApiTxLifetime = ctx.GlobalDuration(PrivateApiTxLifetime.Name)
+	cfg.PrivateApiTxIdleTimeout = ctx.GlobalDuration(PrivateApiTxIdleTimeout.Name)
+	cfg.PrivateApiPinLifetime = ctx.GlobalDuration(PrivateApiPinLifetime.Name)
+	if v := ctx.GlobalString(PrivateApiAllowBuckets.Name); v != "" {
+		cfg.PrivateApiAllowBuckets = strings.Split(v, ",")
+	}
+	if v := ctx.GlobalString(PrivateApiDenyBuckets.Name); v != "" {
+		cfg.PrivateApiDenyBuckets = strings.Split(v, ",")
+	}
+	cfg.PrivateApiAdminToken = ctx.GlobalString(PrivateApiAdminToken.Name)
+	cfg.PrivateApiKeepaliveTime = ctx.GlobalDuration(PrivateApiKeepaliveTime.Name)
+	cfg.PrivateApiKeepaliveTimeout = ctx.GlobalDuration(PrivateApiKeepaliveTimeout.Name)
+	cfg.PrivateApiKeepaliveMinTime = ctx.GlobalDuration(PrivateApiKeepaliveMinTime.Name)
+	cfg.PrivateApiConnIdle = ctx.GlobalDuration(PrivateApiConnIdle.Name)
+	for _, f := range []struct {
+		flag cli.StringFlag
+		size *datasize.ByteSize
+	}{
+		{PrivateApiMaxRecvMsg, &cfg.PrivateApiMaxRecvMsg},
+		{PrivateApiMaxSendMsg, &cfg.PrivateApiMaxSendMsg},
+		{PrivateApiWindowSize, &cfg.PrivateApiWindowSize},
+		{PrivateApiConnWindowSize, &cfg.PrivateApiConnWindowSize},
+	} {
+		if v := ctx.GlobalString(f.flag.Name); v != "" {
+			if err := f.size.UnmarshalText([]byte(v)); err != nil {
+				utils.Fatalf("Invalid %s provided: %v", f.flag.Name, err)
+			}
+		}
+	}
 	if ctx.GlobalBool(TLSFlag.Name) {
 		certFile := ctx.GlobalString(TLSCertFlag.Name)
 		keyFile := ctx.GlobalString(TLSKeyFlag.Name)
@@ -17,7 +17,12 @@ func MakeApp(action func(*cli.Context), cliFlags []cli.Flag) *cli.App {
 	app := flags.NewApp("", "", "erigon experimental cli")
 	app.Action = action
 	app.Flags = append(cliFlags, debug.Flags...) // debug flags are required
+	app.Flags = append(app.Flags, ConfigFlag, PrintEffectiveConfigFlag)
 	app.Before = func(ctx *cli.Context) error {
+		// values from the config file must be in place before anything reads the flags
+		if err := flags.ApplyConfigUrfave(ctx); err != nil {
+			return err
+		}
 		return debug.Setup(ctx)
 	}
 	app.After = func(ctx *cli.Context) error {
@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Report writes one file of the bundle
+type Report func(w io.Writer) error
+
+type namedReport struct {
+	name   string
+	report Report
+}
+
+// Collector writes bundles of diagnostic reports, which capture the state of the node for bug reports
+// (for example, when it looks hung): what staged sync is doing, open database transactions,
+// goroutine stacks and the last log lines.
+type Collector struct {
+	dir     string
+	lock    sync.Mutex // one bundle at a time
+	reports []namedReport
+}
+
+// NewCollector returns collector, which writes bundles into subdirectories of dir.
+// Goroutine stacks are always part of the bundle, other reports are added with AddReport.
+func NewCollector(dir string) *Collector {
+	c := &Collector{dir: dir}
+	c.AddReport("goroutines.txt", func(w io.Writer) error {
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	return c
+}
+
+// AddReport adds the report written into the file with the given name of every bundle
+func (c *Collector) AddReport(name string, report Report) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.reports = append(c.reports, namedReport{name: name, report: report})
+}
+
+// WriteBundle writes all reports into the new timestamped directory and returns its path.
+// Failure of one report doesn't stop others, the error is written into its file instead.
+func (c *Collector) WriteBundle() (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	dir := filepath.Join(c.dir, "bundle-"+time.Now().UTC().Format("20060102-150405.000"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	for _, r := range c.reports {
+		if err := writeReport(filepath.Join(dir, r.name), r.report); err != nil {
+			return dir, fmt.Errorf("%s: %w", r.name, err)
+		}
+	}
+	return dir, nil
+}
+
+func writeReport(path string, report Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = report(f); err != nil {
+		if _, err = fmt.Fprintf(f, "\nreport failed: %v\n", err); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+func (c *Collector) writeBundleAndLog(trigger string) (string, error) {
+	dir, err := c.WriteBundle()
+	if err != nil {
+		log.Error("Failed to write diagnostics bundle", "trigger", trigger, "dir", dir, "err", err)
+		return "", err
+	}
+	log.Info("Diagnostics bundle written", "trigger", trigger, "dir", dir)
+	return dir, nil
+}
+
+// API exposes the collector as admin_diagnosticsBundle
+type API struct {
+	collector *Collector
+}
+
+func NewAPI(collector *Collector) *API {
+	return &API{collector: collector}
+}
+
+// DiagnosticsBundle writes the bundle and returns its directory
+func (api *API) DiagnosticsBundle(_ context.Context) (string, error) {
+	return api.collector.writeBundleAndLog("rpc")
+}
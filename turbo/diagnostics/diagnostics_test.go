@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRing(t *testing.T) {
+	ring := NewLogRing(3)
+	logger := log.New()
+	logger.SetHandler(ring)
+	for i := 0; i < 5; i++ {
+		logger.Info("line", "i", i)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, ring.Report(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		require.Contains(t, line, fmt.Sprintf("i=%d", i+2))
+	}
+}
+
+func TestOpenTxs(t *testing.T) {
+	txs := NewOpenTxs()
+	db := TrackTxs(memdb.New(), txs)
+	defer db.Close()
+
+	tx, err := db.BeginRw(context.Background())
+	require.NoError(t, err)
+	// mdbx doesn't allow read transactions in the thread of the write transaction
+	roTx := make(chan kv.Tx)
+	go func() {
+		tx, err := db.BeginRo(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		roTx <- tx
+	}()
+	ro := <-roTx
+
+	var buf bytes.Buffer
+	require.NoError(t, txs.Report(&buf))
+	report := buf.String()
+	require.Contains(t, report, "open transactions: 2")
+	require.Contains(t, report, "diagnostics.TestOpenTxs")
+	// the oldest first
+	require.Less(t, strings.Index(report, "rw\t"), strings.Index(report, "ro\t"))
+
+	ro.Rollback()
+	require.NoError(t, tx.Commit())
+	tx.Rollback()
+
+	buf.Reset()
+	require.NoError(t, txs.Report(&buf))
+	require.Equal(t, "open transactions: 0\n", buf.String())
+}
+
+func TestWriteBundle(t *testing.T) {
+	c := NewCollector(t.TempDir())
+	c.AddReport("ok.txt", func(w io.Writer) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+	c.AddReport("failed.txt", func(w io.Writer) error { return errors.New("broken") })
+	dir, err := c.WriteBundle()
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "ok.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "failed.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "report failed: broken")
+	content, err = ioutil.ReadFile(filepath.Join(dir, "goroutines.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "TestWriteBundle")
+}
@@ -0,0 +1,59 @@
+package diagnostics
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// LogRing is the log handler, which keeps the last lines of the log for the bundle
+type LogRing struct {
+	lock   sync.Mutex
+	format log.Format
+	lines  [][]byte
+	next   int
+	full   bool
+}
+
+func NewLogRing(size int) *LogRing {
+	return &LogRing{format: log.TerminalFormatNoColor(), lines: make([][]byte, size)}
+}
+
+func (r *LogRing) Log(rec *log.Record) error {
+	line := r.format.Format(rec)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lines[r.next] = line
+	r.next++
+	if r.next == len(r.lines) {
+		r.next, r.full = 0, true
+	}
+	return nil
+}
+
+// Report writes the kept lines, oldest first
+func (r *LogRing) Report(w io.Writer) error {
+	r.lock.Lock()
+	lines := make([][]byte, 0, len(r.lines))
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	r.lock.Unlock()
+	for _, line := range lines {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallLogRing adds the ring of the given size to the root logger. It keeps records of Info
+// and higher levels regardless of verbosity, formatting of Debug and Trace records is too costly.
+func InstallLogRing(size int) *LogRing {
+	ring := NewLogRing(size)
+	root := log.Root()
+	root.SetHandler(log.MultiHandler(root.GetHandler(), log.LvlFilterHandler(log.LvlInfo, ring)))
+	return ring
+}
@@ -0,0 +1,26 @@
+// +build !windows
+
+package diagnostics
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WriteOnSignal writes the bundle every time the process receives SIGUSR1, until quit is closed
+func (c *Collector) WriteOnSignal(quit <-chan struct{}) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				_, _ = c.writeBundleAndLog("SIGUSR1")
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,4 @@
+package diagnostics
+
+// WriteOnSignal does nothing on Windows, there is no SIGUSR1. The bundle is written by admin_diagnosticsBundle there.
+func (c *Collector) WriteOnSignal(quit <-chan struct{}) {}
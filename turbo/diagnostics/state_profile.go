@@ -0,0 +1,33 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ledgerwatch/erigon/core/state"
+)
+
+// StateProfileAPI exposes the profiler of state reads by execution of blocks as debug_stateAccessProfile
+type StateProfileAPI struct {
+	profiler *state.AccessProfiler
+}
+
+func NewStateProfileAPI(profiler *state.AccessProfiler) *StateProfileAPI {
+	return &StateProfileAPI{profiler: profiler}
+}
+
+// StateAccessProfile returns the hottest accounts and storage slots of the last windows (nil - all kept ones),
+// limit of each (nil - state.DefaultAccessProfileLimit)
+func (api *StateProfileAPI) StateAccessProfile(_ context.Context, windows *int, limit *int) (*state.AccessProfile, error) {
+	if api.profiler == nil {
+		return nil, errors.New("state access profiler is disabled, see --state.profile.rate")
+	}
+	var w, l int
+	if windows != nil {
+		w = *windows
+	}
+	if limit != nil {
+		l = *limit
+	}
+	return api.profiler.Profile(w, l), nil
+}
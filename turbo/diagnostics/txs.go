@@ -0,0 +1,184 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// OpenTxs tracks transactions of the DB, which are not committed or rolled back yet.
+// Long-living transactions are the usual suspects of hangs and of the growth of the DB.
+type OpenTxs struct {
+	lock   sync.Mutex
+	nextID uint64
+	txs    map[uint64]openTx
+}
+
+type openTx struct {
+	rw      bool
+	started time.Time
+	caller  string
+}
+
+func NewOpenTxs() *OpenTxs {
+	return &OpenTxs{txs: map[uint64]openTx{}}
+}
+
+// wrapperPackages begin transactions on behalf of their callers, they are skipped in the reports
+var wrapperPackages = []string{
+	"github.com/ledgerwatch/erigon/turbo/diagnostics.(*trackedDB).",
+	"github.com/ledgerwatch/erigon/ethdb/historyfiles.",
+	"github.com/ledgerwatch/erigon/ethdb/replication.",
+	"github.com/ledgerwatch/erigon/ethdb/olddb.",
+	"github.com/ledgerwatch/erigon-lib/kv",
+}
+
+// caller returns the first function up the stack, which is not a DB wrapper
+func caller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		wrapper := false
+		for _, p := range wrapperPackages {
+			if strings.HasPrefix(frame.Function, p) {
+				wrapper = true
+				break
+			}
+		}
+		if !wrapper {
+			return fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func (t *OpenTxs) add(rw bool) uint64 {
+	caller := caller()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.nextID++
+	t.txs[t.nextID] = openTx{rw: rw, started: time.Now(), caller: caller}
+	return t.nextID
+}
+
+func (t *OpenTxs) remove(id uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.txs, id)
+}
+
+// Report writes open transactions, the oldest first
+func (t *OpenTxs) Report(w io.Writer) error {
+	t.lock.Lock()
+	txs := make([]openTx, 0, len(t.txs))
+	for _, tx := range t.txs {
+		txs = append(txs, tx)
+	}
+	t.lock.Unlock()
+	sort.Slice(txs, func(i, j int) bool { return txs[i].started.Before(txs[j].started) })
+	now := time.Now()
+	if _, err := fmt.Fprintf(w, "open transactions: %d\n", len(txs)); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		kind := "ro"
+		if tx.rw {
+			kind = "rw"
+		}
+		if _, err := fmt.Fprintf(w, "%s\tage=%s\tstarted=%s\tby %s\n", kind, now.Sub(tx.started).Truncate(time.Millisecond), tx.started.UTC().Format(time.RFC3339), tx.caller); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrackTxs returns database, which registers its transactions in txs. Every transaction walks the stack
+// to find its caller, so the tracking is enabled by --diagnostics.txs only.
+func TrackTxs(db kv.RwDB, txs *OpenTxs) kv.RwDB {
+	return &trackedDB{RwDB: db, txs: txs}
+}
+
+type trackedDB struct {
+	kv.RwDB
+	txs *OpenTxs
+}
+
+func (db *trackedDB) UnwrapDB() kv.RoDB { return db.RwDB }
+
+type trackedTx struct {
+	kv.Tx
+	txs *OpenTxs
+	id  uint64
+}
+
+func (tx *trackedTx) UnwrapTx() kv.Tx { return tx.Tx }
+
+func (tx *trackedTx) Rollback() {
+	tx.Tx.Rollback()
+	tx.txs.remove(tx.id)
+}
+
+type trackedRwTx struct {
+	kv.RwTx
+	txs *OpenTxs
+	id  uint64
+}
+
+func (tx *trackedRwTx) UnwrapTx() kv.Tx { return tx.RwTx }
+
+func (tx *trackedRwTx) Commit() error {
+	defer tx.txs.remove(tx.id)
+	return tx.RwTx.Commit()
+}
+
+func (tx *trackedRwTx) Rollback() {
+	tx.RwTx.Rollback()
+	tx.txs.remove(tx.id)
+}
+
+func (db *trackedDB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	tx, err := db.RwDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedTx{Tx: tx, txs: db.txs, id: db.txs.add(false)}, nil
+}
+
+func (db *trackedDB) BeginRw(ctx context.Context) (kv.RwTx, error) {
+	tx, err := db.RwDB.BeginRw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedRwTx{RwTx: tx, txs: db.txs, id: db.txs.add(true)}, nil
+}
+
+func (db *trackedDB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+func (db *trackedDB) Update(ctx context.Context, f func(tx kv.RwTx) error) error {
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
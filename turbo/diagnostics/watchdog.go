@@ -0,0 +1,156 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Names of the recovery actions of the watchdog, in --watchdog.actions
+const (
+	ActionBundle      = "diagnostics"  // write the diagnostics bundle
+	ActionRestartSync = "restart-sync" // reset the header downloader to the headers in the database
+	ActionRotatePeers = "rotate-peers" // disconnect part of the peers, so that discovery replaces them
+	ActionWebhook     = "webhook"      // POST the stall as JSON to --watchdog.webhook
+)
+
+// WatchdogActions are all known actions, in the default order
+var WatchdogActions = []string{ActionBundle, ActionRestartSync, ActionRotatePeers, ActionWebhook}
+
+// webhookTimeout is the timeout of one POST to the webhook
+const webhookTimeout = 10 * time.Second
+
+// Stall is the head, which hasn't advanced for the timeout of the watchdog
+type Stall struct {
+	Head     uint64        `json:"head"`
+	Since    time.Time     `json:"since"`            // when the head was seen first
+	Duration time.Duration `json:"duration"`         // since then, in nanoseconds
+	Attempt  int           `json:"attempt"`          // 1 - the first timeout of the stall, 2 - the second, etc
+	Bundle   string        `json:"bundle,omitempty"` // directory of the diagnostics bundle, if it was written before
+}
+
+// Action is the recovery action, which the watchdog executes when the head stalls
+type Action func(ctx context.Context, stall *Stall) error
+
+type namedAction struct {
+	name   string
+	action Action
+
+	ok, failed *metrics.Counter
+}
+
+// Watchdog detects the chain head, which hasn't advanced for the timeout, and executes recovery actions.
+// They are executed in the order they were added, failure of one doesn't stop others. While the head stays
+// the same, the actions are repeated after every next timeout.
+type Watchdog struct {
+	timeout time.Duration
+	head    func() (uint64, error)
+	actions []namedAction
+	stalls  *metrics.Counter
+}
+
+// NewWatchdog returns the watchdog, which polls the head by the given func
+func NewWatchdog(timeout time.Duration, head func() (uint64, error)) *Watchdog {
+	return &Watchdog{timeout: timeout, head: head, stalls: metrics.GetOrCreateCounter("watchdog_stalls")}
+}
+
+// AddAction adds the action executed on stalls after the previously added ones
+func (w *Watchdog) AddAction(name string, action Action) {
+	counter := func(result string) *metrics.Counter {
+		return metrics.GetOrCreateCounter(fmt.Sprintf(`watchdog_actions{action=%q,result=%q}`, name, result))
+	}
+	w.actions = append(w.actions, namedAction{name: name, action: action, ok: counter("ok"), failed: counter("failed")})
+}
+
+// Run polls the head until ctx is done
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	var head uint64
+	var since, next time.Time
+	var attempt int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		h, err := w.head()
+		if err != nil {
+			log.Warn("Watchdog failed to read the chain head", "err", err)
+			continue
+		}
+		now := time.Now()
+		if since.IsZero() || h != head {
+			if attempt > 0 {
+				log.Info("Chain head advanced after stall", "from", head, "to", h, "stalled", now.Sub(since))
+			}
+			head, since, next, attempt = h, now, now.Add(w.timeout), 0
+			continue
+		}
+		if now.Before(next) {
+			continue
+		}
+		attempt++
+		next = now.Add(w.timeout)
+		w.stall(ctx, &Stall{Head: head, Since: since, Duration: now.Sub(since), Attempt: attempt})
+	}
+}
+
+func (w *Watchdog) stall(ctx context.Context, stall *Stall) {
+	w.stalls.Inc()
+	log.Warn("Chain head stalled", "head", stall.Head, "for", stall.Duration, "attempt", stall.Attempt)
+	for _, a := range w.actions {
+		if err := a.action(ctx, stall); err != nil {
+			a.failed.Inc()
+			log.Error("Watchdog action failed", "action", a.name, "err", err)
+			continue
+		}
+		a.ok.Inc()
+		log.Info("Watchdog action done", "action", a.name)
+	}
+}
+
+// BundleAction writes the diagnostics bundle of the collector, its directory is passed to the next actions
+func BundleAction(c *Collector) Action {
+	return func(_ context.Context, stall *Stall) error {
+		dir, err := c.writeBundleAndLog("watchdog")
+		if err != nil {
+			return err
+		}
+		stall.Bundle = dir
+		return nil
+	}
+}
+
+// WebhookAction POSTs the stall as JSON to the url, responses other than 2xx are failures
+func WebhookAction(url string) Action {
+	return func(ctx context.Context, stall *Stall) error {
+		body, err := json.Marshal(stall)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook responded %s", resp.Status)
+		}
+		return nil
+	}
+}
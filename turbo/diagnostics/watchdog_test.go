@@ -0,0 +1,68 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+	var head uint64 = 1
+	w := NewWatchdog(100*time.Millisecond, func() (uint64, error) { return atomic.LoadUint64(&head), nil })
+
+	collector := NewCollector(t.TempDir())
+	w.AddAction(ActionBundle, BundleAction(collector))
+	w.AddAction("failing", func(context.Context, *Stall) error { return errors.New("failed") })
+	var mu sync.Mutex
+	var stalls []Stall
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var stall Stall
+		if err := json.NewDecoder(r.Body).Decode(&stall); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		stalls = append(stalls, stall)
+	}))
+	defer ts.Close()
+	w.AddAction(ActionWebhook, WebhookAction(ts.URL))
+	received := func() []Stall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Stall(nil), stalls...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// the actions are repeated while the head stays the same
+	require.Eventually(t, func() bool { return len(received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	for i, stall := range received() {
+		require.Equal(t, uint64(1), stall.Head)
+		require.Equal(t, i+1, stall.Attempt)
+		require.GreaterOrEqual(t, stall.Duration, time.Duration(i+1)*100*time.Millisecond)
+		require.NotEmpty(t, stall.Bundle) // written by the previous action
+		files, err := ioutil.ReadDir(stall.Bundle)
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+	}
+
+	// the advanced head starts the new stall
+	atomic.StoreUint64(&head, 2)
+	require.Eventually(t, func() bool {
+		stalls := received()
+		last := stalls[len(stalls)-1]
+		return last.Head == 2 && last.Attempt == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
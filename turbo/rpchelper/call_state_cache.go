@@ -0,0 +1,194 @@
+package rpchelper
+
+import (
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+)
+
+const (
+	// DefaultCallStateCacheBlocks is the amount of blocks for which state read by historical calls is kept
+	DefaultCallStateCacheBlocks = 32
+	// DefaultCallStateCacheItems is the max amount of accounts, storage slots and codes kept per block
+	DefaultCallStateCacheItems = 100_000
+)
+
+var (
+	callAccountHit  = metrics.GetOrCreateCounter(`rpc_call_state_cache_hit{kind="account"}`)
+	callAccountMiss = metrics.GetOrCreateCounter(`rpc_call_state_cache_miss{kind="account"}`)
+	callStorageHit  = metrics.GetOrCreateCounter(`rpc_call_state_cache_hit{kind="storage"}`)
+	callStorageMiss = metrics.GetOrCreateCounter(`rpc_call_state_cache_miss{kind="storage"}`)
+	callCodeHit     = metrics.GetOrCreateCounter(`rpc_call_state_cache_hit{kind="code"}`)
+	callCodeMiss    = metrics.GetOrCreateCounter(`rpc_call_state_cache_miss{kind="code"}`)
+)
+
+// CallStateCache keeps accounts, storage slots and contract code read by calls executed on top of historical state,
+// so concurrent and repeated calls at the same block (f.e. eth_call of a popular view function at block X) don't
+// reconstruct the same values from history again. Entries are keyed by block hash: state of a given block never
+// changes, so entries never become stale and are only evicted by LRU. State of the latest block keeps changing with
+// the head, it's cached by the shared state cache set by SetLatest.
+type CallStateCache struct {
+	blocks   *lru.Cache // common.Hash -> *blockStateCache
+	maxItems int
+	latest   *state.SharedCache
+	profiler *state.AccessProfiler
+}
+
+func NewCallStateCache(blocks, maxItems int) *CallStateCache {
+	c, _ := lru.New(blocks)
+	return &CallStateCache{blocks: c, maxItems: maxItems}
+}
+
+type storageKey struct {
+	address     common.Address
+	incarnation uint64
+	key         common.Hash
+}
+
+// blockStateCache is the state read at one block. Values are shared between readers and must not be modified,
+// accounts are copied by IntraBlockState when state objects are created.
+type blockStateCache struct {
+	mu       sync.RWMutex
+	accounts map[common.Address]*accounts.Account // nil value - account doesn't exist
+	storage  map[storageKey][]byte
+	code     map[common.Hash][]byte
+	items    int
+}
+
+func (b *blockStateCache) full(maxItems int) bool {
+	return b.items >= maxItems
+}
+
+func (c *CallStateCache) block(blockHash common.Hash) *blockStateCache {
+	if b, ok := c.blocks.Get(blockHash); ok {
+		return b.(*blockStateCache)
+	}
+	b := &blockStateCache{
+		accounts: map[common.Address]*accounts.Account{},
+		storage:  map[storageKey][]byte{},
+		code:     map[common.Hash][]byte{},
+	}
+	// Concurrent callers may race here, the one which loses just fills a cache nobody else sees
+	if prev, ok, _ := c.blocks.PeekOrAdd(blockHash, b); ok {
+		return prev.(*blockStateCache)
+	}
+	return b
+}
+
+// SetLatest sets the cache of the latest state, nil - the latest state is not cached
+func (c *CallStateCache) SetLatest(latest *state.SharedCache) {
+	c.latest = latest
+}
+
+// SetProfiler sets the profiler, which samples reads of readers of the cache, nil - reads are not profiled
+func (c *CallStateCache) SetProfiler(profiler *state.AccessProfiler) {
+	c.profiler = profiler
+}
+
+// LatestReader wraps reader of the latest state, which is the state of the given block, into a reader which goes
+// through the cache of the latest state, if it's set
+func (c *CallStateCache) LatestReader(blockNumber uint64, blockHash common.Hash, r state.StateReader) state.StateReader {
+	if c == nil {
+		return r
+	}
+	return c.profiler.Reader(state.AccessSourceRPC, c.latest.Reader(r, blockNumber, blockHash))
+}
+
+// Reader wraps reader of the state at the given block into a reader which goes through the cache of this block
+func (c *CallStateCache) Reader(blockHash common.Hash, r state.StateReader) state.StateReader {
+	if c == nil {
+		return r
+	}
+	return c.profiler.Reader(state.AccessSourceRPC, &callStateReader{r: r, b: c.block(blockHash), maxItems: c.maxItems})
+}
+
+type callStateReader struct {
+	r        state.StateReader
+	b        *blockStateCache
+	maxItems int
+}
+
+func (cr *callStateReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	cr.b.mu.RLock()
+	a, ok := cr.b.accounts[address]
+	cr.b.mu.RUnlock()
+	if ok {
+		callAccountHit.Inc()
+		return a, nil
+	}
+	callAccountMiss.Inc()
+	a, err := cr.r.ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	cr.b.mu.Lock()
+	defer cr.b.mu.Unlock()
+	if _, ok = cr.b.accounts[address]; !ok && !cr.b.full(cr.maxItems) {
+		cr.b.accounts[address] = a
+		cr.b.items++
+	}
+	return a, nil
+}
+
+func (cr *callStateReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	k := storageKey{address: address, incarnation: incarnation, key: *key}
+	cr.b.mu.RLock()
+	v, ok := cr.b.storage[k]
+	cr.b.mu.RUnlock()
+	if ok {
+		callStorageHit.Inc()
+		return v, nil
+	}
+	callStorageMiss.Inc()
+	v, err := cr.r.ReadAccountStorage(address, incarnation, key)
+	if err != nil {
+		return nil, err
+	}
+	cr.b.mu.Lock()
+	defer cr.b.mu.Unlock()
+	if _, ok = cr.b.storage[k]; !ok && !cr.b.full(cr.maxItems) {
+		cr.b.storage[k] = v
+		cr.b.items++
+	}
+	return v, nil
+}
+
+// ReadAccountCode caches code by its hash, the same code deployed at many addresses (f.e. proxies) is kept once
+func (cr *callStateReader) ReadAccountCode(address common.Address, incarnation uint64, codeHash common.Hash) ([]byte, error) {
+	cr.b.mu.RLock()
+	c, ok := cr.b.code[codeHash]
+	cr.b.mu.RUnlock()
+	if ok {
+		callCodeHit.Inc()
+		return c, nil
+	}
+	callCodeMiss.Inc()
+	c, err := cr.r.ReadAccountCode(address, incarnation, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) == 0 {
+		return c, nil
+	}
+	cr.b.mu.Lock()
+	defer cr.b.mu.Unlock()
+	if _, ok = cr.b.code[codeHash]; !ok && !cr.b.full(cr.maxItems) {
+		cr.b.code[codeHash] = c
+		cr.b.items++
+	}
+	return c, nil
+}
+
+func (cr *callStateReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	c, err := cr.ReadAccountCode(address, incarnation, codeHash)
+	return len(c), err
+}
+
+// ReadAccountIncarnation is only needed to create contracts, which is rare enough to not be cached
+func (cr *callStateReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	return cr.r.ReadAccountIncarnation(address)
+}
@@ -0,0 +1,92 @@
+package rpchelper
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader serves fixed state and counts reads which reached it
+type countingReader struct {
+	reads int
+}
+
+func (r *countingReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	r.reads++
+	if address == (common.Address{}) {
+		return nil, nil
+	}
+	return &accounts.Account{Nonce: 1, Balance: *uint256.NewInt(2), Initialised: true}, nil
+}
+
+func (r *countingReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	r.reads++
+	return key[:1], nil
+}
+
+func (r *countingReader) ReadAccountCode(address common.Address, incarnation uint64, codeHash common.Hash) ([]byte, error) {
+	r.reads++
+	return []byte{0x60, 0x00}, nil
+}
+
+func (r *countingReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	r.reads++
+	return 2, nil
+}
+
+func (r *countingReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	r.reads++
+	return 0, nil
+}
+
+func TestCallStateCache(t *testing.T) {
+	require := require.New(t)
+	c := NewCallStateCache(2, 3)
+	underlying := &countingReader{}
+	blockA, blockB := common.Hash{0xa}, common.Hash{0xb}
+
+	r := c.Reader(blockA, underlying)
+	a, err := r.ReadAccountData(common.Address{1})
+	require.NoError(err)
+	require.Equal(uint64(1), a.Nonce)
+	a, err = r.ReadAccountData(common.Address{})
+	require.NoError(err)
+	require.Nil(a)
+	v, err := r.ReadAccountStorage(common.Address{1}, 1, &common.Hash{7})
+	require.NoError(err)
+	require.Equal([]byte{7}, v)
+	require.Equal(3, underlying.reads)
+
+	// another reader of the same block is served from the cache, including absent accounts
+	r = c.Reader(blockA, underlying)
+	a, err = r.ReadAccountData(common.Address{1})
+	require.NoError(err)
+	require.Equal(uint64(1), a.Nonce)
+	a, err = r.ReadAccountData(common.Address{})
+	require.NoError(err)
+	require.Nil(a)
+	v, err = r.ReadAccountStorage(common.Address{1}, 1, &common.Hash{7})
+	require.NoError(err)
+	require.Equal([]byte{7}, v)
+	require.Equal(3, underlying.reads)
+
+	// block is full, new values are read through
+	size, err := r.ReadAccountCodeSize(common.Address{1}, 1, common.Hash{1})
+	require.NoError(err)
+	require.Equal(2, size)
+	_, err = r.ReadAccountCode(common.Address{1}, 1, common.Hash{1})
+	require.NoError(err)
+	require.Equal(5, underlying.reads)
+
+	// other blocks don't share values
+	r = c.Reader(blockB, underlying)
+	_, err = r.ReadAccountData(common.Address{1})
+	require.NoError(err)
+	require.Equal(6, underlying.reads)
+
+	var disabled *CallStateCache
+	require.Equal(underlying, disabled.Reader(blockA, underlying))
+}
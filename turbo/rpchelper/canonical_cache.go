@@ -0,0 +1,125 @@
+package rpchelper
+
+import (
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+const (
+	// DefaultCanonicalCacheSize is the amount of entries kept in each of the number->hash and hash->header caches
+	DefaultCanonicalCacheSize = 4096
+	// canonicalCacheMinDepth is the distance from the last notified head after which number->hash mapping is cached.
+	// Reorgs deeper than that are still handled by invalidation in OnNewHeader, this only protects against
+	// readers which opened their transaction before the reorg and insert stale mapping after invalidation.
+	canonicalCacheMinDepth = 64
+)
+
+var (
+	canonicalHashHit  = metrics.GetOrCreateCounter(`rpc_canonical_cache_hit{kind="hash"}`)
+	canonicalHashMiss = metrics.GetOrCreateCounter(`rpc_canonical_cache_miss{kind="hash"}`)
+	headerHit         = metrics.GetOrCreateCounter(`rpc_canonical_cache_hit{kind="header"}`)
+	headerMiss        = metrics.GetOrCreateCounter(`rpc_canonical_cache_miss{kind="header"}`)
+	canonicalEvicted  = metrics.GetOrCreateCounter(`rpc_canonical_cache_reorg_evicted`)
+)
+
+// CanonicalCache keeps number->hash of the canonical chain and hash->header, shared by all rpcdaemon handlers.
+// hash->header entries never become stale, number->hash entries are invalidated by OnNewHeader when
+// the canonical chain changes. Without header notifications (f.e. rpcdaemon in chaindata-only mode)
+// the number->hash part never gets populated, because the cache can't know when it becomes stale.
+// Headers returned by the cache are shared, callers must not modify them.
+type CanonicalCache struct {
+	mu       sync.Mutex
+	hashes   *lru.Cache // uint64 -> common.Hash
+	headers  *lru.Cache // common.Hash -> *types.Header
+	lastHead uint64
+}
+
+func NewCanonicalCache(size int) *CanonicalCache {
+	hashes, _ := lru.New(size)
+	headers, _ := lru.New(size)
+	return &CanonicalCache{hashes: hashes, headers: headers}
+}
+
+// OnNewHeader must be called for every new canonical header announced by Erigon. Announcing a header at height N
+// drops all cached canonical hashes at N and above, because they may belong to the chain which was just unwound.
+func (c *CanonicalCache) OnNewHeader(header *types.Header) {
+	number := header.Number.Uint64()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number <= c.lastHead {
+		for _, k := range c.hashes.Keys() {
+			if k.(uint64) >= number {
+				c.hashes.Remove(k)
+				canonicalEvicted.Inc()
+			}
+		}
+	}
+	c.lastHead = number
+	c.headers.Add(header.Hash(), header)
+}
+
+// CanonicalHash returns hash of the canonical block with given number, see rawdb.ReadCanonicalHash
+func (c *CanonicalCache) CanonicalHash(tx kv.Getter, number uint64) (common.Hash, error) {
+	if h, ok := c.hashes.Get(number); ok {
+		canonicalHashHit.Inc()
+		return h.(common.Hash), nil
+	}
+	canonicalHashMiss.Inc()
+	hash, err := rawdb.ReadCanonicalHash(tx, number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if hash == (common.Hash{}) {
+		return hash, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number+canonicalCacheMinDepth <= c.lastHead {
+		c.hashes.Add(number, hash)
+	}
+	return hash, nil
+}
+
+// HeaderByHash returns header with given hash or nil if it's unknown, see rawdb.ReadHeaderByHash
+func (c *CanonicalCache) HeaderByHash(tx kv.Getter, hash common.Hash) (*types.Header, error) {
+	if h, ok := c.headers.Get(hash); ok {
+		headerHit.Inc()
+		return h.(*types.Header), nil
+	}
+	headerMiss.Inc()
+	header, err := rawdb.ReadHeaderByHash(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		c.headers.Add(hash, header)
+	}
+	return header, nil
+}
+
+// HeaderByNumber returns canonical header with given number or nil if it's unknown, see rawdb.ReadHeaderByNumber
+func (c *CanonicalCache) HeaderByNumber(tx kv.Getter, number uint64) (*types.Header, error) {
+	hash, err := c.CanonicalHash(tx, number)
+	if err != nil {
+		return nil, err
+	}
+	if hash == (common.Hash{}) {
+		return nil, nil
+	}
+	if h, ok := c.headers.Get(hash); ok {
+		headerHit.Inc()
+		return h.(*types.Header), nil
+	}
+	headerMiss.Inc()
+	header := rawdb.ReadHeader(tx, hash, number)
+	if header != nil {
+		c.headers.Add(hash, header)
+	}
+	return header, nil
+}
@@ -0,0 +1,53 @@
+package rpchelper
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalCacheReorg(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	require := require.New(t)
+	c := NewCanonicalCache(DefaultCanonicalCacheSize)
+
+	writeChain := func(extra []byte, from, to uint64) {
+		for i := from; i <= to; i++ {
+			h := &types.Header{Number: big.NewInt(int64(i)), Extra: extra}
+			rawdb.WriteHeader(tx, h)
+			require.NoError(rawdb.WriteCanonicalHash(tx, h.Hash(), i))
+		}
+	}
+	writeChain(nil, 0, 200)
+	c.OnNewHeader(&types.Header{Number: big.NewInt(200)})
+
+	h10, err := c.HeaderByNumber(tx, 10)
+	require.NoError(err)
+	require.Equal(uint64(10), h10.Number.Uint64())
+	hash50, err := c.CanonicalHash(tx, 50)
+	require.NoError(err)
+
+	// reorg replacing blocks from 20 onwards, announced by header 20 of the new chain
+	writeChain([]byte{1}, 20, 200)
+	c.OnNewHeader(rawdb.ReadHeaderByNumber(tx, 20))
+
+	newHash50, err := c.CanonicalHash(tx, 50)
+	require.NoError(err)
+	require.NotEqual(hash50, newHash50)
+
+	cached10, err := c.CanonicalHash(tx, 10)
+	require.NoError(err)
+	require.Equal(h10.Hash(), cached10)
+
+	missing, err := c.HeaderByNumber(tx, 1000)
+	require.NoError(err)
+	require.Nil(missing)
+	unknown, err := c.HeaderByHash(tx, common.Hash{1})
+	require.NoError(err)
+	require.Nil(unknown)
+}
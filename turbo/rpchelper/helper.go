@@ -9,6 +9,7 @@ import (
 	"github.com/ledgerwatch/erigon/core/rawdb"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/headlag"
 	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/turbo/adapter"
 )
@@ -70,6 +71,13 @@ func GetBlockNumber(blockNrOrHash rpc.BlockNumberOrHash, tx kv.Tx, filters *filt
 	return blockNumber, hash, nil
 }
 
+// PlainStateAt returns true if the state of the block is the plain state, it's the case for "latest" unless the head is
+// lagged (rpc.headlag): then the block is behind the plain state, and its state must be read from the history
+func PlainStateAt(blockNrOrHash rpc.BlockNumberOrHash, tx kv.Tx) bool {
+	num, ok := blockNrOrHash.Number()
+	return ok && num == rpc.LatestBlockNumber && !headlag.Lagged(tx)
+}
+
 func GetAccount(tx kv.Tx, blockNumber uint64, address common.Address) (*accounts.Account, error) {
 	reader := adapter.NewStateReader(tx, blockNumber)
 	return reader.ReadAccountData(address)
@@ -0,0 +1,81 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// abandonAfter - a step which didn't return so long after the deadline is abandoned
+const abandonAfter = 5 * time.Second
+
+// ErrAbandoned is the error of a step, which didn't return in time after the deadline
+var ErrAbandoned = errors.New("abandoned after the deadline")
+
+// Coordinator stops components of the node one by one, in the order of Add, within one deadline. Steps get
+// the context of the shutdown, which is cancelled at the deadline: before it they stop gracefully (at safe
+// boundaries, committing their progress), after it they must abort. A step, which doesn't return shortly after
+// the deadline, is abandoned and the next steps run, so the shutdown takes at most a bit more than the deadline.
+type Coordinator struct {
+	deadline     time.Duration // 0 - no deadline, steps stop gracefully however long it takes
+	abandonAfter time.Duration
+	steps        []step
+}
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(deadline time.Duration) *Coordinator {
+	return &Coordinator{deadline: deadline, abandonAfter: abandonAfter}
+}
+
+// Add appends the step, stop must return promptly once ctx is done
+func (c *Coordinator) Add(name string, stop func(ctx context.Context) error) {
+	c.steps = append(c.steps, step{name: name, stop: stop})
+}
+
+// Run runs all steps, also after failures of the previous ones, and returns the first error
+func (c *Coordinator) Run() error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if c.deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.deadline)
+	}
+	defer cancel()
+	start := time.Now()
+	var firstErr error
+	for _, s := range c.steps {
+		stepStart := time.Now()
+		err := c.runStep(ctx, s)
+		if err != nil {
+			log.Warn("Shutdown step failed", "step", s.name, "took", time.Since(stepStart), "err", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", s.name, err)
+			}
+			continue
+		}
+		log.Info("Shutdown step done", "step", s.name, "took", time.Since(stepStart))
+	}
+	log.Info("Shutdown done", "took", time.Since(start))
+	return firstErr
+}
+
+func (c *Coordinator) runStep(ctx context.Context, s step) error {
+	done := make(chan error, 1)
+	go func() { done <- s.stop(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(c.abandonAfter):
+		return ErrAbandoned
+	}
+}
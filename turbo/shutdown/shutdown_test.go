@@ -0,0 +1,61 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoordinator(t *testing.T) {
+	c := New(time.Second)
+	var order []string
+	failed := errors.New("failed")
+	c.Add("graceful", func(ctx context.Context) error {
+		order = append(order, "graceful")
+		return nil
+	})
+	c.Add("failing", func(ctx context.Context) error {
+		order = append(order, "failing")
+		return failed
+	})
+	c.Add("last", func(ctx context.Context) error {
+		order = append(order, "last")
+		return nil
+	})
+	err := c.Run()
+	require.ErrorIs(t, err, failed)
+	require.Equal(t, []string{"graceful", "failing", "last"}, order)
+}
+
+func TestCoordinatorDeadline(t *testing.T) {
+	c := New(50 * time.Millisecond)
+	c.abandonAfter = 50 * time.Millisecond
+	aborted := make(chan struct{})
+	c.Add("aborts", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(aborted)
+		return nil
+	})
+	c.Add("hangs", func(ctx context.Context) error {
+		select {}
+	})
+	ran := false
+	c.Add("after", func(ctx context.Context) error {
+		require.Error(t, ctx.Err())
+		ran = true
+		return nil
+	})
+	start := time.Now()
+	err := c.Run()
+	require.ErrorIs(t, err, ErrAbandoned)
+	require.True(t, ran)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+	select {
+	case <-aborted:
+	default:
+		t.Fatal("step was not aborted at the deadline")
+	}
+}
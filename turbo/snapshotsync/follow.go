@@ -0,0 +1,90 @@
+package snapshotsync
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/ethdb/snapshotdb"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// FollowSnapshots wraps the database of Erigon, opened by another process (rpcdaemon with --datadir), by the headers
+// snapshot of Erigon. Erigon moves headers of the next epoch into the new snapshot, deletes them from the database
+// and saves the block of the new snapshot by one transaction - so every read transaction checks the block of the
+// snapshot and switches to the new one first, headers are found either in the database or in the snapshot.
+func FollowSnapshots(chainDb kv.RwDB, snapshotsDir string) kv.RwDB {
+	return &followingKV{SnapshotKV: snapshotdb.NewSnapshotKV().DB(chainDb).Open(), snapshotsDir: snapshotsDir}
+}
+
+type followingKV struct {
+	*snapshotdb.SnapshotKV
+	snapshotsDir string
+
+	mu    sync.Mutex
+	block uint64 // of the open headers snapshot, 0 - no snapshot
+}
+
+func (s *followingKV) BeginRo(ctx context.Context) (kv.Tx, error) {
+	for {
+		tx, err := s.SnapshotKV.BeginRo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		block, err := currentHeadersSnapshotBlock(tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		s.mu.Lock()
+		current := s.block
+		s.mu.Unlock()
+		if block == current {
+			return tx, nil
+		}
+		tx.Rollback()
+		if err = s.switchTo(block); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *followingKV) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := s.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+// switchTo opens the headers snapshot of the block instead of the current one, which is closed once its transactions
+// are done
+func (s *followingKV) switchTo(block uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if block == s.block {
+		return nil
+	}
+	var snKV kv.RoDB
+	if block > 0 {
+		var err error
+		if snKV, err = OpenHeadersSnapshot(SnapshotName(s.snapshotsDir, "headers", block)); err != nil {
+			return err
+		}
+	}
+	s.SnapshotKV.UpdateSnapshots("headers", snKV, make(chan struct{}, 1))
+	log.Info("Headers snapshot switched", "from", s.block, "to", block)
+	s.block = block
+	return nil
+}
+
+// currentHeadersSnapshotBlock returns the block of the headers snapshot of Erigon, 0 - no snapshot
+func currentHeadersSnapshotBlock(tx kv.Tx) (uint64, error) {
+	v, err := tx.GetOne(kv.BittorrentInfo, kv.CurrentHeadersSnapshotBlock)
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
@@ -0,0 +1,61 @@
+package snapshotsync
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowSnapshots(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := memdb.NewTestDB(t)
+	var headers []*types.Header
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		for i := int64(0); i < 4; i++ {
+			h := &types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(1), Extra: []byte{byte(i)}}
+			rawdb.WriteHeader(tx, h)
+			if err := rawdb.WriteCanonicalHash(tx, h.Hash(), h.Number.Uint64()); err != nil {
+				return err
+			}
+			headers = append(headers, h)
+		}
+		return nil
+	}))
+	follow := FollowSnapshots(db, dir)
+
+	readHeaders := func() {
+		require.NoError(t, follow.View(ctx, func(tx kv.Tx) error {
+			for _, h := range headers {
+				read := rawdb.ReadHeaderByNumber(tx, h.Number.Uint64())
+				require.NotNil(t, read, "header %d", h.Number)
+				require.Equal(t, h.Hash(), read.Hash())
+			}
+			return nil
+		}))
+	}
+	readHeaders()
+
+	// Erigon moves headers into the snapshot, as SnapshotMigrator does
+	for _, block := range []uint64{1, 2} {
+		require.NoError(t, follow.View(ctx, func(tx kv.Tx) error {
+			return CreateHeadersSnapshot(ctx, tx, block, SnapshotName(dir, "headers", block))
+		}))
+		require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+			for _, h := range headers[:block+1] {
+				if err := tx.Delete(kv.Headers, dbutils.HeaderKey(h.Number.Uint64(), h.Hash()), nil); err != nil {
+					return err
+				}
+			}
+			return tx.Put(kv.BittorrentInfo, kv.CurrentHeadersSnapshotBlock, dbutils.EncodeBlockNumber(block))
+		}))
+		readHeaders()
+	}
+}
@@ -509,6 +509,78 @@ func TestChainTxReorgs(t *testing.T) {
 	}
 }
 
+func TestAddressActivityReorg(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		x      = common.Address{0xaa}
+		y      = common.Address{0xbb}
+		miner  = common.Address{0xcc}
+		gspec  = &core.Genesis{
+			Config:   params.TestChainConfig,
+			GasLimit: 3141592,
+			Alloc:    core.GenesisAlloc{addr: {Balance: big.NewInt(1000000)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	m := stages.MockWithGenesis(t, gspec, key)
+	transfer := func(gen *core.BlockGen, to common.Address) {
+		txn, err := types.SignTx(types.NewTransaction(gen.TxNonce(addr), to, uint256.NewInt(1000), params.TxGas, nil, nil), *signer, key)
+		require.NoError(t, err)
+		gen.AddTx(txn)
+	}
+
+	// Both chains share the first block
+	chainA, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 3, func(i int, gen *core.BlockGen) {
+		switch i {
+		case 0:
+			transfer(gen, x)
+		case 2:
+			transfer(gen, x)
+			transfer(gen, y)
+		}
+	}, false /* intemediateHashes */)
+	require.NoError(t, err)
+	chainB, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 5, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			transfer(gen, x)
+			return
+		}
+		gen.SetCoinbase(miner)
+	}, false /* intemediateHashes */)
+	require.NoError(t, err)
+	require.Equal(t, chainA.Blocks[0].Hash(), chainB.Blocks[0].Hash())
+
+	activity := func(a common.Address) (uint64, uint64, bool) {
+		tx, err := m.DB.BeginRo(context.Background())
+		require.NoError(t, err)
+		defer tx.Rollback()
+		first, last, found, err := rawdb.ReadAddressActivity(tx, a)
+		require.NoError(t, err)
+		return first, last, found
+	}
+
+	require.NoError(t, m.InsertChain(chainA))
+	first, last, found := activity(x)
+	require.True(t, found)
+	require.Equal(t, []uint64{1, 3}, []uint64{first, last})
+	first, last, found = activity(addr)
+	require.True(t, found)
+	require.Equal(t, []uint64{1, 3}, []uint64{first, last})
+	_, _, found = activity(y)
+	require.True(t, found)
+
+	require.NoError(t, m.InsertChain(chainB))
+	first, last, found = activity(x)
+	require.True(t, found)
+	require.Equal(t, []uint64{1, 1}, []uint64{first, last})
+	_, _, found = activity(y)
+	require.False(t, found)
+	first, last, found = activity(miner)
+	require.True(t, found)
+	require.Equal(t, []uint64{2, 5}, []uint64{first, last})
+}
+
 // Tests if the canonical block can be fetched from the database during chain insertion.
 func TestCanonicalBlockRetrieval(t *testing.T) {
 	m := newCanonical(t, 0)
@@ -0,0 +1,196 @@
+// Package blocksource reads canonical blocks from the local disk for the sync instead of downloading them from peers:
+// from the database of another node or from snapshots of headers and bodies. It makes possible to replay exactly
+// the same blocks into a fresh datadir to reproduce an execution bug, and to provision nodes without network access.
+package blocksource
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/common/dbutils"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Source is the database of another node or snapshots of headers and bodies
+type Source struct {
+	headers kv.RoDB
+	bodies  kv.RoDB
+	// snapshots have neither canonical markings nor stage progress, but they have only headers of canonical blocks
+	snapshots bool
+}
+
+var _ stagedsync.BlockSource = &Source{}
+
+// Open opens the source at the path: datadir or chaindata of another node (it may be running),
+// or directory with headers<N> and bodies<N> snapshots, the latest of them are used
+func Open(path string) (*Source, error) {
+	for _, chaindata := range []string{filepath.Join(path, "chaindata"), path} {
+		if _, err := os.Stat(filepath.Join(chaindata, "mdbx.dat")); err != nil {
+			continue
+		}
+		db, err := mdbx.NewMDBX(log.New()).Path(chaindata).Readonly().Open()
+		if err != nil {
+			return nil, fmt.Errorf("block source %s: %w", chaindata, err)
+		}
+		return FromDB(db), nil
+	}
+	headersDir, err := latestSnapshot(path, "headers")
+	if err != nil {
+		return nil, err
+	}
+	bodiesDir, err := latestSnapshot(path, "bodies")
+	if err != nil {
+		return nil, err
+	}
+	headers, err := snapshotsync.OpenHeadersSnapshot(headersDir)
+	if err != nil {
+		return nil, fmt.Errorf("block source %s: %w", headersDir, err)
+	}
+	bodies, err := snapshotsync.OpenBodiesSnapshot(log.New(), bodiesDir)
+	if err != nil {
+		headers.Close()
+		return nil, fmt.Errorf("block source %s: %w", bodiesDir, err)
+	}
+	return &Source{headers: headers, bodies: bodies, snapshots: true}, nil
+}
+
+// FromDB makes the source of an already opened chaindata
+func FromDB(db kv.RoDB) *Source {
+	return &Source{headers: db, bodies: db}
+}
+
+// latestSnapshot returns the directory of the snapshot of the kind with the highest block
+func latestSnapshot(dir, kind string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("block source: %w", err)
+	}
+	var latest string
+	var latestBlock uint64
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), kind) {
+			continue
+		}
+		block, err := strconv.ParseUint(strings.TrimPrefix(e.Name(), kind), 10, 64)
+		if err != nil {
+			continue
+		}
+		if latest == "" || block > latestBlock {
+			latest, latestBlock = filepath.Join(dir, e.Name()), block
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("block source: %s is neither datadir, nor chaindata, nor directory of %s snapshots", dir, kind)
+	}
+	return latest, nil
+}
+
+func (s *Source) Close() {
+	s.headers.Close()
+	if s.bodies != s.headers {
+		s.bodies.Close()
+	}
+}
+
+func (s *Source) View(ctx context.Context, f func(r stagedsync.BlockReader) error) error {
+	return s.headers.View(ctx, func(headersTx kv.Tx) error {
+		if s.bodies == s.headers {
+			return f(&reader{headersTx: headersTx, bodiesTx: headersTx, snapshots: s.snapshots})
+		}
+		return s.bodies.View(ctx, func(bodiesTx kv.Tx) error {
+			return f(&reader{headersTx: headersTx, bodiesTx: bodiesTx, snapshots: s.snapshots})
+		})
+	})
+}
+
+type reader struct {
+	headersTx kv.Tx
+	bodiesTx  kv.Tx
+	snapshots bool
+}
+
+func (r *reader) Head() (uint64, error) {
+	if !r.snapshots {
+		headers, err := stages.GetStageProgress(r.headersTx, stages.Headers)
+		if err != nil {
+			return 0, err
+		}
+		bodies, err := stages.GetStageProgress(r.bodiesTx, stages.Bodies)
+		if err != nil {
+			return 0, err
+		}
+		if bodies < headers {
+			return bodies, nil
+		}
+		return headers, nil
+	}
+	headers, err := lastBlock(r.headersTx, kv.Headers)
+	if err != nil {
+		return 0, err
+	}
+	bodies, err := lastBlock(r.bodiesTx, kv.BlockBody)
+	if err != nil {
+		return 0, err
+	}
+	if bodies < headers {
+		return bodies, nil
+	}
+	return headers, nil
+}
+
+// lastBlock returns the highest block in the table, keys of which start with the block number
+func lastBlock(tx kv.Tx, table string) (uint64, error) {
+	c, err := tx.Cursor(table)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	k, _, err := c.Last()
+	if err != nil || len(k) < 8 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(k), nil
+}
+
+func (r *reader) Header(number uint64) (*types.Header, error) {
+	if !r.snapshots {
+		hash, err := rawdb.ReadCanonicalHash(r.headersTx, number)
+		if err != nil || hash == (common.Hash{}) {
+			return nil, err
+		}
+		return rawdb.ReadHeader(r.headersTx, hash, number), nil
+	}
+	c, err := r.headersTx.Cursor(kv.Headers)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	k, v, err := c.Seek(dbutils.EncodeBlockNumber(number))
+	if err != nil || len(k) < 8 || binary.BigEndian.Uint64(k) != number {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err = rlp.DecodeBytes(v, header); err != nil {
+		return nil, fmt.Errorf("invalid header %d in snapshot: %w", number, err)
+	}
+	return header, nil
+}
+
+func (r *reader) Body(number uint64, hash common.Hash) (*types.Body, error) {
+	return rawdb.ReadBody(r.bodiesTx, hash, number), nil
+}
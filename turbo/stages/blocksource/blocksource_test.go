@@ -0,0 +1,75 @@
+package blocksource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/params"
+	stages2 "github.com/ledgerwatch/erigon/turbo/stages"
+	"github.com/ledgerwatch/erigon/turbo/stages/blocksource"
+	"github.com/stretchr/testify/require"
+)
+
+// newSync has only Headers and Bodies stages, which read blocks from the source
+func newSync(ctx context.Context, db kv.RwDB, source stagedsync.BlockSource, to uint64) *stagedsync.Sync {
+	noUnwind := func(firstCycle bool, u *stagedsync.UnwindState, s *stagedsync.StageState, tx kv.RwTx) error {
+		return nil
+	}
+	return stagedsync.New(
+		stagedsync.UseLocalSource(ctx, []*stagedsync.Stage{
+			{ID: stages.Headers, Unwind: noUnwind},
+			{ID: stages.Bodies, Unwind: noUnwind},
+		}, stagedsync.StageLocalSourceCfg(db, source, to)),
+		stagedsync.UnwindOrder{stages.Bodies, stages.Headers},
+		stagedsync.PruneOrder{stages.Bodies, stages.Headers},
+	)
+}
+
+func TestSyncFromLocalSource(t *testing.T) {
+	ctx := context.Background()
+	m := stages2.Mock(t)
+	signer := types.LatestSignerForChainID(m.ChainConfig.ChainID)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 10, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{1})
+		txn, err := types.SignTx(types.NewTransaction(b.TxNonce(m.Address), common.Address{2}, uint256.NewInt(1000), params.TxGas, nil, nil), *signer, m.Key)
+		require.NoError(t, err)
+		b.AddTx(txn)
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+	require.NoError(t, m.InsertChain(chain))
+
+	// a fresh node with the same genesis takes blocks up to 7 from the first one
+	fresh := stages2.Mock(t)
+	require.NoError(t, newSync(ctx, fresh.DB, blocksource.FromDB(m.DB), 7).Run(fresh.DB, nil, true))
+	require.NoError(t, fresh.DB.View(ctx, func(tx kv.Tx) error {
+		for _, s := range []stages.SyncStage{stages.Headers, stages.Bodies} {
+			progress, err := stages.GetStageProgress(tx, s)
+			require.NoError(t, err)
+			require.Equal(t, uint64(7), progress, s)
+		}
+		require.Equal(t, chain.Blocks[6].Hash(), rawdb.ReadHeadHeaderHash(tx))
+		for _, b := range chain.Blocks[:7] {
+			block := rawdb.ReadBlock(tx, b.Hash(), b.NumberU64())
+			require.NotNil(t, block)
+			require.Equal(t, b.Transactions()[0].Hash(), block.Transactions()[0].Hash())
+		}
+		return nil
+	}))
+
+	// a node of another chain is refused
+	other := stages2.Mock(t)
+	otherChain, err := core.GenerateChain(other.ChainConfig, other.Genesis, other.Engine, other.DB, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{3})
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+	require.NoError(t, other.InsertChain(otherChain))
+	require.Error(t, newSync(ctx, other.DB, blocksource.FromDB(m.DB), 0).Run(other.DB, nil, true))
+}
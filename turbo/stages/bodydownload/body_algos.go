@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -54,6 +55,7 @@ func (bd *BodyDownload) UpdateFromDb(db kv.RwTx) (headHeight uint64, headHash co
 		bd.requests[i] = nil
 	}
 	bd.peerMap = make(map[string]int)
+	bd.penalties = nil
 	headHeight = bodyProgress
 	headHash, err = rawdb.ReadCanonicalHash(db, headHeight)
 	if err != nil {
@@ -176,10 +178,22 @@ func (bd *BodyDownload) RequestMoreBodies(db kv.Tx, blockNum uint64, currentTime
 }
 
 func (bd *BodyDownload) RequestSent(bodyReq *BodyRequest, timeWithTimeout uint64, peer []byte) {
+	if timeWithTimeout >= bd.pruneRequestedAt {
+		bd.pruneRequested(timeWithTimeout)
+		bd.pruneRequestedAt = timeWithTimeout + lateAnswerWindow
+	}
+	requested, ok := bd.peerRequested[string(peer)]
+	if !ok {
+		requested = make(map[DoubleHash]uint64, len(bodyReq.BlockNums))
+		bd.peerRequested[string(peer)] = requested
+	}
 	for _, blockNum := range bodyReq.BlockNums {
 		if blockNum < bd.requestedLow {
 			continue
 		}
+		if header := bd.deliveriesH[blockNum-bd.requestedLow]; header != nil {
+			requested[doubleHashOfHeader(header)] = timeWithTimeout + lateAnswerWindow
+		}
 		req := bd.requests[blockNum-bd.requestedLow]
 		if req != nil {
 			bd.requests[blockNum-bd.requestedLow].waitUntil = timeWithTimeout
@@ -188,9 +202,26 @@ func (bd *BodyDownload) RequestSent(bodyReq *BodyRequest, timeWithTimeout uint64
 	}
 }
 
-// DeliverBodies takes the block body received from a peer and adds it to the various data structures
+// pruneRequested removes records of requests, late answers to which are not expected before the given time.
+// Records outlive cycles of the stage, so answers to requests of the previous cycle are not taken as invalid
+func (bd *BodyDownload) pruneRequested(before uint64) {
+	for peerID, requested := range bd.peerRequested {
+		for doubleHash, until := range requested {
+			if until < before {
+				delete(requested, doubleHash)
+			}
+		}
+		if len(requested) == 0 {
+			delete(bd.peerRequested, peerID)
+		}
+	}
+}
+
+// DeliverBodies takes the block body received from a peer and adds it to the various data structures.
+// Roots of the bodies are computed here, in parallel, so the stage loop only has to match them with the headers.
 func (bd *BodyDownload) DeliverBodies(txs [][][]byte, uncles [][]*types.Header, lenOfP2PMsg uint64, peerID string) {
-	bd.deliveryCh <- Delivery{txs: txs, uncles: uncles, lenOfP2PMessage: lenOfP2PMsg, peerID: peerID}
+	hashes := bd.hashBodies(txs, uncles)
+	bd.deliveryCh <- Delivery{txs: txs, uncles: uncles, hashes: hashes, lenOfP2PMessage: lenOfP2PMsg, peerID: peerID}
 
 	select {
 	case bd.DeliveryNotify <- struct{}{}:
@@ -223,6 +254,35 @@ func (rt RawTransactions) EncodeIndex(i int, w *bytes.Buffer) {
 	w.Write(rt[i]) //nolint:errcheck
 }
 
+// hashBodies computes uncle and transaction roots of the bodies on at most bd.verifyWorkers goroutines
+func (bd *BodyDownload) hashBodies(txs [][][]byte, uncles [][]*types.Header) []DoubleHash {
+	hashes := make([]DoubleHash, len(txs))
+	var wg sync.WaitGroup
+	for i := range txs {
+		bd.verifyWorkers <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-bd.verifyWorkers
+				wg.Done()
+			}()
+			uncleHash := types.CalcUncleHash(uncles[i])
+			txHash := types.DeriveSha(RawTransactions(txs[i]))
+			copy(hashes[i][:], uncleHash.Bytes())
+			copy(hashes[i][common.HashLength:], txHash.Bytes())
+		}(i)
+	}
+	wg.Wait()
+	return hashes
+}
+
+func doubleHashOfHeader(header *types.Header) DoubleHash {
+	var doubleHash DoubleHash
+	copy(doubleHash[:], header.UncleHash.Bytes())
+	copy(doubleHash[common.HashLength:], header.TxHash.Bytes())
+	return doubleHash
+}
+
 func (bd *BodyDownload) doDeliverBodies() (err error) {
 Loop:
 	for {
@@ -235,15 +295,21 @@ Loop:
 		}
 
 		reqMap := make(map[uint64]*BodyRequest)
-		txs, uncles, lenOfP2PMessage, _ := delivery.txs, delivery.uncles, delivery.lenOfP2PMessage, delivery.peerID
+		txs, uncles, lenOfP2PMessage, peerID := delivery.txs, delivery.uncles, delivery.lenOfP2PMessage, delivery.peerID
 		var delivered, undelivered int
+		requested := bd.peerRequested[peerID]
+		penalized := false
 
 		for i := range txs {
-			uncleHash := types.CalcUncleHash(uncles[i])
-			txHash := types.DeriveSha(RawTransactions(txs[i]))
-			var doubleHash DoubleHash
-			copy(doubleHash[:], uncleHash.Bytes())
-			copy(doubleHash[common.HashLength:], txHash.Bytes())
+			doubleHash := delivery.hashes[i]
+			// Records are kept after delivery: duplicates and late answers match them too
+			if _, ok := requested[doubleHash]; !ok && !penalized {
+				// Roots of the body don't match any header recently requested from the peer, it's an invalid body,
+				// so the peer is dropped now instead of failing the execution later
+				log.Debug("Invalid block body delivered", "peer", fmt.Sprintf("%x", peerID), "uncleHash", common.BytesToHash(doubleHash[:common.HashLength]), "txHash", common.BytesToHash(doubleHash[common.HashLength:]))
+				bd.penalties = append(bd.penalties, headerdownload.PenaltyItem{PeerID: peerID, Penalty: headerdownload.BadBlockPenalty})
+				penalized = true
+			}
 
 			// Block numbers are added to the bd.delivered bitmap here, only for blocks for which the body has been received, and their double hashes are present in the bd.requesredMap
 			// Also, block numbers can be added to bd.delivered for empty blocks, above
@@ -337,6 +403,13 @@ func (bd *BodyDownload) GetDeliveries() ([]*types.Header, []*types.RawBody, erro
 	return headers, rawBodies, nil
 }
 
+// GetPenalties returns peers which delivered invalid bodies since the previous call
+func (bd *BodyDownload) GetPenalties() []headerdownload.PenaltyItem {
+	penalties := bd.penalties
+	bd.penalties = nil
+	return penalties
+}
+
 func (bd *BodyDownload) DeliveryCounts() (float64, float64) {
 	return bd.deliveredCount, bd.wastedCount
 }
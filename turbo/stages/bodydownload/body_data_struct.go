@@ -1,10 +1,13 @@
 package bodydownload
 
 import (
+	"runtime"
+
 	"github.com/RoaringBitmap/roaring/roaring64"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/consensus"
 	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
 )
 
 // DoubleHash is type to be used for the mapping between TxHash and UncleHash to the block header
@@ -12,16 +15,25 @@ type DoubleHash [2 * common.HashLength]byte
 
 const MaxBodiesInRequest = 1024
 
+// lateAnswerWindow is the time (in seconds), after the timeout of the request, during which bodies delivered by
+// the peer are still taken as answers to it, and not as invalid ones
+const lateAnswerWindow = 60
+
 type Delivery struct {
 	peerID          string
 	txs             [][][]byte
 	uncles          [][]*types.Header
+	hashes          []DoubleHash // uncle and transaction roots of the bodies, computed on delivery
 	lenOfP2PMessage uint64
 }
 
 // BodyDownload represents the state of body downloading process
 type BodyDownload struct {
 	peerMap          map[string]int
+	peerRequested    map[string]map[DoubleHash]uint64 // bodies requested from every peer, until when late answers are expected
+	pruneRequestedAt uint64                           // time of the next removal of expired records from peerRequested
+	penalties        []headerdownload.PenaltyItem
+	verifyWorkers    chan struct{} // limits number of goroutines hashing delivered bodies
 	requestedMap     map[DoubleHash]uint64
 	DeliveryNotify   chan struct{}
 	deliveryCh       chan Delivery
@@ -58,6 +70,8 @@ func NewBodyDownload(outstandingLimit int, engine consensus.Engine) *BodyDownloa
 		deliveriesB:      make([]*types.RawBody, outstandingLimit+MaxBodiesInRequest),
 		requests:         make([]*BodyRequest, outstandingLimit+MaxBodiesInRequest),
 		peerMap:          make(map[string]int),
+		peerRequested:    make(map[string]map[DoubleHash]uint64),
+		verifyWorkers:    make(chan struct{}, runtime.NumCPU()),
 		prefetchedBlocks: NewPrefetchedBlocks(),
 		// DeliveryNotify has capacity 1, and it is also used so that senders never block
 		// This makes this channel a mailbox with no more than one letter in it, meaning
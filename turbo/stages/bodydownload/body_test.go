@@ -1,10 +1,19 @@
 package bodydownload
 
 import (
+	"math/big"
 	"testing"
 
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/consensus/ethash"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/rlp"
+	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateBodyDownload(t *testing.T) {
@@ -14,3 +23,58 @@ func TestCreateBodyDownload(t *testing.T) {
 		t.Fatalf("update from db: %v", err)
 	}
 }
+
+func TestDeliverInvalidBodies(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	var bodies [][][]byte
+	for i := uint64(1); i <= 2; i++ {
+		raw, err := rlp.EncodeToBytes(types.NewTransaction(i, common.Address{}, uint256.NewInt(0), 21000, uint256.NewInt(1), nil))
+		require.NoError(t, err)
+		body := [][]byte{raw}
+		h := &types.Header{Number: new(big.Int).SetUint64(i), Difficulty: big.NewInt(1), UncleHash: types.EmptyUncleHash, TxHash: types.DeriveSha(RawTransactions(body))}
+		rawdb.WriteHeader(tx, h)
+		require.NoError(t, rawdb.WriteCanonicalHash(tx, h.Hash(), i))
+		bodies = append(bodies, body)
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Headers, 2))
+
+	bd := NewBodyDownload(100, ethash.NewFaker())
+	_, _, _, err := bd.UpdateFromDb(tx)
+	require.NoError(t, err)
+	req, _, err := bd.RequestMoreBodies(tx, 0, 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, req.BlockNums)
+	bd.RequestSent(req, 100, []byte("good"))
+
+	// the body of the block 2 doesn't match the header
+	bd.DeliverBodies(bodies[:1], [][]*types.Header{nil}, 100, "good")
+	bd.DeliverBodies([][][]byte{bodies[0]}, [][]*types.Header{{{Number: big.NewInt(1)}}}, 100, "bad")
+	headers, rawBodies, err := bd.GetDeliveries()
+	require.NoError(t, err)
+	require.Len(t, headers, 1)
+	require.Equal(t, bodies[0], rawBodies[0].Transactions)
+	require.Equal(t, []headerdownload.PenaltyItem{{PeerID: "bad", Penalty: headerdownload.BadBlockPenalty}}, bd.GetPenalties())
+	require.Empty(t, bd.GetPenalties())
+
+	bd.DeliverBodies(bodies[1:], [][]*types.Header{nil}, 100, "good")
+	headers, _, err = bd.GetDeliveries()
+	require.NoError(t, err)
+	require.Len(t, headers, 1)
+	require.Equal(t, uint64(2), headers[0].Number.Uint64())
+	require.Empty(t, bd.GetPenalties())
+
+	// duplicates and late answers to requests of the previous cycle of the stage are not penalized
+	_, _, _, err = bd.UpdateFromDb(tx)
+	require.NoError(t, err)
+	bd.DeliverBodies(bodies, [][]*types.Header{nil, nil}, 100, "good")
+	_, _, err = bd.GetDeliveries()
+	require.NoError(t, err)
+	require.Empty(t, bd.GetPenalties())
+
+	// records of requests expire
+	bd.RequestSent(&BodyRequest{}, 100+lateAnswerWindow+1, []byte("other"))
+	bd.DeliverBodies(bodies[:1], [][]*types.Header{nil}, 100, "good")
+	_, _, err = bd.GetDeliveries()
+	require.NoError(t, err)
+	require.Equal(t, []headerdownload.PenaltyItem{{PeerID: "good", Penalty: headerdownload.BadBlockPenalty}}, bd.GetPenalties())
+}
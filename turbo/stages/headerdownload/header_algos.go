@@ -546,6 +546,61 @@ func (hd *HeaderDownload) SentRequest(req *HeaderRequest, currentTime, timeout u
 	heap.Fix(hd.anchorQueue, 0)
 }
 
+// DefaultSegmentFillers is the default number of requests filling the gap below one anchor in parallel
+const DefaultSegmentFillers = 4
+
+// SetSegmentFillers sets the number of requests filling the gap below one anchor in parallel, 1 - only the request
+// by the hash of the anchor, so the gap is filled by one segment at a time
+func (hd *HeaderDownload) SetSegmentFillers(fillers int) {
+	hd.lock.Lock()
+	defer hd.lock.Unlock()
+	hd.segmentFillers = fillers
+}
+
+// RequestSegmentFills returns requests, which fill gaps below anchors (between skeleton headers, for example) in
+// parallel with the requests of RequestMoreHeaders. The gap below an anchor is split into segments of 192 headers;
+// the first one is requested by the hash of the anchor, up to segmentFillers-1 segments below it are requested by
+// number, each from any peer that has them, so high latency of one peer does not serialise the gap. Filled segments
+// become anchors, and are stitched to the chain by extendDown and connect only when the hash of their highest
+// header is the parent hash of the anchor above, so segments of forks or of dishonest peers never get into the
+// chain, and their anchors expire. Fills of an anchor are requested again after the timeout.
+func (hd *HeaderDownload) RequestSegmentFills(currentTime, timeout uint64) []*HeaderRequest {
+	hd.lock.Lock()
+	defer hd.lock.Unlock()
+	if hd.segmentFillers <= 1 || len(hd.anchors) == 0 {
+		return nil
+	}
+	anchors := make([]*Anchor, 0, len(hd.anchors))
+	for _, anchor := range hd.anchors {
+		anchors = append(anchors, anchor)
+	}
+	sort.Slice(anchors, func(i, j int) bool { return anchors[i].blockHeight < anchors[j].blockHeight })
+	var requests []*HeaderRequest
+	bottom := hd.highestInDb // Nothing is requested below the highest header in the database and below lower anchors
+	for _, anchor := range anchors {
+		gapBottom := bottom
+		if anchor.blockHeight > bottom {
+			bottom = anchor.blockHeight
+		}
+		if anchor.fillTimestamp > currentTime || anchor.timeouts >= 10 {
+			continue
+		}
+		for i := 1; i < hd.segmentFillers; i++ {
+			if anchor.blockHeight <= gapBottom+1+uint64(i)*192 {
+				break
+			}
+			top := anchor.blockHeight - 1 - uint64(i)*192
+			length := top - gapBottom
+			if length > 192 {
+				length = 192
+			}
+			requests = append(requests, &HeaderRequest{Number: top, Length: length, Skip: 0, Reverse: true})
+			anchor.fillTimestamp = currentTime + timeout
+		}
+	}
+	return requests
+}
+
 func (hd *HeaderDownload) RequestSkeleton() *HeaderRequest {
 	hd.lock.RLock()
 	defer hd.lock.RUnlock()
@@ -74,12 +74,13 @@ func (lq *LinkQueue) Pop() interface{} {
 }
 
 type Anchor struct {
-	peerID      string
-	links       []*Link     // Links attached immediately to this anchor
-	parentHash  common.Hash // Hash of the header this anchor can be connected to (to disappear)
-	blockHeight uint64
-	timestamp   uint64 // Zero when anchor has just been created, otherwise timestamps when timeout on this anchor request expires
-	timeouts    int    // Number of timeout that this anchor has experiences - after certain threshold, it gets invalidated
+	peerID        string
+	links         []*Link     // Links attached immediately to this anchor
+	parentHash    common.Hash // Hash of the header this anchor can be connected to (to disappear)
+	blockHeight   uint64
+	timestamp     uint64 // Zero when anchor has just been created, otherwise timestamps when timeout on this anchor request expires
+	timeouts      int    // Number of timeout that this anchor has experiences - after certain threshold, it gets invalidated
+	fillTimestamp uint64 // Zero when the gap below the anchor has not been filled, otherwise timestamp when timeout on the fill requests expires
 }
 
 type AnchorQueue []*Anchor
@@ -188,6 +189,7 @@ type HeaderDownload struct {
 	topSeenHeight      uint64
 	requestChaining    bool // Whether the downloader is allowed to issue more requests when previous responses created or moved an anchor
 	fetching           bool // Set when the stage that is actively fetching the headers is in progress
+	segmentFillers     int  // Number of requests filling the gap below one anchor in parallel, including the request by the hash of the anchor
 }
 
 // HeaderRecord encapsulates two forms of the same header - raw RLP encoding (to avoid duplicated decodings and encodings), and parsed value types.Header
@@ -216,6 +218,7 @@ func NewHeaderDownload(
 		anchorQueue:        &AnchorQueue{},
 		seenAnnounces:      NewSeenAnnounces(),
 		DeliveryNotify:     make(chan struct{}, 1),
+		segmentFillers:     DefaultSegmentFillers,
 	}
 	heap.Init(hd.persistedLinkQueue)
 	heap.Init(hd.linkQueue)
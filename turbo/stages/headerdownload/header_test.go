@@ -4,6 +4,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/consensus/ethash"
 	"github.com/ledgerwatch/erigon/core/types"
 )
@@ -178,3 +179,70 @@ func TestSingleHeaderAsSegment(t *testing.T) {
 		t.Errorf("handle newBlock msg: %v", err)
 	}
 }
+
+func TestRequestSegmentFills(t *testing.T) {
+	engine := ethash.NewFaker()
+	hd := NewHeaderDownload(100, 10000, engine)
+	// Canonical chain and a fork of it from the block 600
+	headers := make([]*types.Header, 1001)
+	forked := make([]*types.Header, 1001)
+	for i := range headers {
+		headers[i] = &types.Header{Number: big.NewInt(int64(i))}
+		forked[i] = &types.Header{Number: big.NewInt(int64(i))}
+		if i > 0 {
+			headers[i].ParentHash = headers[i-1].Hash()
+			forked[i].ParentHash = forked[i-1].Hash()
+			if i == 600 {
+				forked[i].ParentHash = headers[i-1].Hash()
+				forked[i].Extra = []byte("fork")
+			}
+		}
+	}
+	segment := func(chain []*types.Header, from, to uint64) *ChainSegment {
+		s := &ChainSegment{}
+		for i := to; i >= from; i-- {
+			s.Headers = append(s.Headers, chain[i])
+			s.HeadersRaw = append(s.HeadersRaw, []byte{})
+		}
+		return s
+	}
+
+	hd.ProcessSegment(segment(headers, 1000, 1000), false, "skeleton")
+	fills := hd.RequestSegmentFills(10, 5)
+	if len(fills) != 3 {
+		t.Fatalf("expected 3 fill requests, got %d", len(fills))
+	}
+	for i, top := range []uint64{807, 615, 423} {
+		if fills[i].Number != top || fills[i].Length != 192 || !fills[i].Reverse || fills[i].Hash != (common.Hash{}) {
+			t.Errorf("unexpected fill request %d: %+v", i, fills[i])
+		}
+	}
+	if fills = hd.RequestSegmentFills(12, 5); fills != nil {
+		t.Errorf("expected no fill requests before the timeout, got %d", len(fills))
+	}
+
+	// The segment of the fork is not stitched to the anchor
+	hd.ProcessSegment(segment(forked, 616, 807), false, "fork")
+	hd.ProcessSegment(segment(headers, 808, 999), false, "peer")
+	if len(hd.anchors) != 2 {
+		t.Errorf("expected 2 anchors after the fork, got %d", len(hd.anchors))
+	}
+	// The honest segment is stitched
+	hd.ProcessSegment(segment(headers, 616, 807), false, "peer")
+	if _, ok := hd.anchors[headers[615].Hash()]; !ok {
+		t.Errorf("expected the anchor of the filled segment")
+	}
+	if _, ok := hd.anchors[headers[807].Hash()]; ok {
+		t.Errorf("expected the anchor to be connected to the filled segment")
+	}
+
+	// The gap below the filled segment is filled down to the database
+	fills = hd.RequestSegmentFills(20, 5)
+	if len(fills) != 3 || fills[2].Number != 39 || fills[2].Length != 39 {
+		t.Errorf("unexpected fill requests: %+v", fills)
+	}
+	hd.SetSegmentFillers(1)
+	if fills = hd.RequestSegmentFills(40, 5); fills != nil {
+		t.Errorf("expected no fill requests, got %d", len(fills))
+	}
+}
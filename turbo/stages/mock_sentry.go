@@ -15,13 +15,13 @@ import (
 	proto_sentry "github.com/ledgerwatch/erigon-lib/gointerfaces/sentry"
 	ptypes "github.com/ledgerwatch/erigon-lib/gointerfaces/types"
 	"github.com/ledgerwatch/erigon-lib/kv"
-	"github.com/ledgerwatch/erigon-lib/kv/memdb"
 	"github.com/ledgerwatch/erigon/cmd/sentry/download"
 	"github.com/ledgerwatch/erigon/common"
 	"github.com/ledgerwatch/erigon/consensus"
 	"github.com/ledgerwatch/erigon/consensus/ethash"
 	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/core/vm"
 	"github.com/ledgerwatch/erigon/crypto"
@@ -30,6 +30,7 @@ import (
 	"github.com/ledgerwatch/erigon/eth/protocols/eth"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb"
 	"github.com/ledgerwatch/erigon/ethdb/privateapi"
 	"github.com/ledgerwatch/erigon/ethdb/prune"
 	"github.com/ledgerwatch/erigon/params"
@@ -166,9 +167,9 @@ func MockWithEverything(t *testing.T, gspec *core.Genesis, key *ecdsa.PrivateKey
 		PeerId: gointerfaces.ConvertBytesToH512([]byte("12345")),
 	}
 	if t != nil {
-		mock.DB = memdb.NewTestDB(t)
+		mock.DB = ethdb.NewTestDB(t)
 	} else {
-		mock.DB = memdb.New()
+		mock.DB = ethdb.NewMemDB()
 	}
 	mock.Ctx, mock.cancel = context.WithCancel(context.Background())
 	mock.Address = crypto.PubkeyToAddress(mock.Key.PublicKey)
@@ -184,6 +185,7 @@ func MockWithEverything(t *testing.T, gspec *core.Genesis, key *ecdsa.PrivateKey
 	cfg.BatchSize = 1 * datasize.MB
 	cfg.BodyDownloadTimeoutSeconds = 10
 	cfg.TxPool.Journal = ""
+	cfg.TxPool.Snapshot = ""
 	cfg.TxPool.StartOnInit = true
 	txPoolConfig := cfg.TxPool
 
@@ -222,7 +224,15 @@ func MockWithEverything(t *testing.T, gspec *core.Genesis, key *ecdsa.PrivateKey
 	networkID := uint64(1)
 	mock.SentryClient = remote.NewSentryClientDirect(eth.ETH66, mock)
 	sentries := []remote.SentryClient{mock.SentryClient}
-	mock.downloader, err = download.NewControlServer(mock.DB, "mock", mock.ChainConfig, mock.Genesis.Hash(), mock.Engine, networkID, sentries, blockDownloaderWindow)
+	mock.downloader, err = download.NewControlServer(mock.DB, "mock", mock.ChainConfig, mock.Genesis.Hash(), mock.Engine, networkID, sentries, blockDownloaderWindow, download.ServingLimits{})
+	if err != nil {
+		if t != nil {
+			t.Fatal(err)
+		} else {
+			panic(err)
+		}
+	}
+	stateCache, err := state.NewSharedCache(cfg.StateCache)
 	if err != nil {
 		if t != nil {
 			t.Fatal(err)
@@ -266,12 +276,16 @@ func MockWithEverything(t *testing.T, gspec *core.Genesis, key *ecdsa.PrivateKey
 				mock.DB,
 				prune,
 				cfg.BatchSize,
+				cfg.Commit,
 				nil,
 				mock.ChainConfig,
 				mock.Engine,
 				&vm.Config{},
 				nil,
+				stateCache,
+				nil,
 				cfg.StateStream,
+				cfg.NoAddressActivity,
 				mock.tmpdir,
 			),
 			stagedsync.StageTranspileCfg(
@@ -287,10 +301,15 @@ func MockWithEverything(t *testing.T, gspec *core.Genesis, key *ecdsa.PrivateKey
 			),
 			stagedsync.StageHashStateCfg(mock.DB, mock.tmpdir),
 			stagedsync.StageTrieCfg(mock.DB, true, true, mock.tmpdir),
+			stagedsync.StageCrossCheckCfg(mock.DB, nil, ethconfig.Defaults.CrossCheck.Every, false),
 			stagedsync.StageHistoryCfg(mock.DB, prune, mock.tmpdir),
+			stagedsync.StageHistoryFilesCfg(mock.DB, params.FullImmutabilityThreshold, mock.tmpdir),
 			stagedsync.StageLogIndexCfg(mock.DB, prune, mock.tmpdir),
+			stagedsync.StageLogArchiveCfg(mock.DB, nil, params.FullImmutabilityThreshold),
 			stagedsync.StageCallTracesCfg(mock.DB, prune, 0, mock.tmpdir),
 			stagedsync.StageTxLookupCfg(mock.DB, prune, mock.tmpdir),
+			stagedsync.StageFeeSeriesCfg(mock.DB),
+			stagedsync.StageDataGasStatsCfg(mock.DB, mock.ChainConfig),
 			stagedsync.StageTxPoolCfg(mock.DB, txPool, func() {
 				mock.StreamWg.Add(1)
 				go txpool.RecvTxMessageLoop(mock.Ctx, mock.SentryClient, mock.downloader, mock.TxPoolP2PServer.HandleInboundMessage, &mock.ReceiveWg)
@@ -14,13 +14,17 @@ import (
 	"github.com/ledgerwatch/erigon/common/debug"
 	"github.com/ledgerwatch/erigon/core"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/vm"
 	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/ethdb/logarchive"
 	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/turbo/shards"
 	"github.com/ledgerwatch/erigon/turbo/snapshotsync"
+	"github.com/ledgerwatch/erigon/turbo/stages/blocksource"
 	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
 	"github.com/ledgerwatch/erigon/turbo/txpool"
 	"github.com/ledgerwatch/log/v3"
@@ -74,6 +78,8 @@ func StageLoop(
 			select {
 			case <-ctx.Done():
 				return
+			case <-sync.Interrupted():
+				return
 			case <-c:
 			}
 		}
@@ -127,6 +133,13 @@ func StageLoopStep(
 	}
 
 	err = sync.Run(db, tx, initialCycle)
+	if errors.Is(err, stagedsync.ErrInterrupted) && canRunCycleInOneTransaction {
+		// stages before the boundary are complete, their work is kept
+		if errTx := tx.Commit(); errTx != nil {
+			return errTx
+		}
+		return err
+	}
 	if err != nil {
 		return err
 	}
@@ -209,73 +222,122 @@ func NewStagedSync(
 	client *snapshotsync.Client,
 	snapshotMigrator *snapshotsync.SnapshotMigrator,
 	accumulator *shards.Accumulator,
+	profiler *state.AccessProfiler,
 ) (*stagedsync.Sync, error) {
-	return stagedsync.New(
-		stagedsync.DefaultStages(
-			ctx,
+	var logArchive *logarchive.Archive
+	if cfg.LogArchive.Enabled {
+		var err error
+		if logArchive, err = logarchive.Open(cfg.LogArchive.Dir); err != nil {
+			return nil, err
+		}
+		go func() {
+			<-ctx.Done()
+			logArchive.Close()
+		}()
+	}
+	stateCache, err := state.NewSharedCache(cfg.StateCache)
+	if err != nil {
+		return nil, err
+	}
+	var trusted *rpc.Client
+	if cfg.CrossCheck.URL != "" {
+		var err error
+		if trusted, err = rpc.DialContext(ctx, cfg.CrossCheck.URL); err != nil {
+			return nil, fmt.Errorf("trusted node for the cross-check: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			trusted.Close()
+		}()
+	}
+	stagesList := stagedsync.DefaultStages(
+		ctx,
+		cfg.Prune,
+		stagedsync.StageHeadersCfg(
+			db,
+			controlServer.Hd,
+			*controlServer.ChainConfig,
+			controlServer.SendHeaderRequest,
+			controlServer.PropagateNewBlockHashes,
+			controlServer.Penalize,
+			cfg.BatchSize,
+			p2pCfg.NoDiscovery,
+		),
+		stagedsync.StageBlockHashesCfg(db, tmpdir),
+		stagedsync.StageSnapshotHeadersCfg(db, cfg.Snapshot, client, snapshotMigrator, logger),
+		stagedsync.StageBodiesCfg(
+			db,
+			controlServer.Bd,
+			controlServer.SendBodyRequest,
+			controlServer.Penalize,
+			controlServer.BroadcastNewBlock,
+			cfg.BodyDownloadTimeoutSeconds,
+			*controlServer.ChainConfig,
+			cfg.BatchSize,
+		),
+		stagedsync.StageSnapshotBodiesCfg(db, cfg.Snapshot, client, snapshotMigrator, tmpdir),
+		stagedsync.StageSendersCfg(db, controlServer.ChainConfig, tmpdir),
+		stagedsync.StageExecuteBlocksCfg(
+			db,
 			cfg.Prune,
-			stagedsync.StageHeadersCfg(
-				db,
-				controlServer.Hd,
-				*controlServer.ChainConfig,
-				controlServer.SendHeaderRequest,
-				controlServer.PropagateNewBlockHashes,
-				controlServer.Penalize,
-				cfg.BatchSize,
-				p2pCfg.NoDiscovery,
-			),
-			stagedsync.StageBlockHashesCfg(db, tmpdir),
-			stagedsync.StageSnapshotHeadersCfg(db, cfg.Snapshot, client, snapshotMigrator, logger),
-			stagedsync.StageBodiesCfg(
-				db,
-				controlServer.Bd,
-				controlServer.SendBodyRequest,
-				controlServer.Penalize,
-				controlServer.BroadcastNewBlock,
-				cfg.BodyDownloadTimeoutSeconds,
-				*controlServer.ChainConfig,
-				cfg.BatchSize,
-			),
-			stagedsync.StageSnapshotBodiesCfg(db, cfg.Snapshot, client, snapshotMigrator, tmpdir),
-			stagedsync.StageSendersCfg(db, controlServer.ChainConfig, tmpdir),
-			stagedsync.StageExecuteBlocksCfg(
-				db,
-				cfg.Prune,
-				cfg.BatchSize,
-				nil,
-				controlServer.ChainConfig,
-				controlServer.Engine,
-				&vm.Config{EnableTEMV: cfg.Prune.Experiments.TEVM},
-				accumulator,
-				cfg.StateStream,
-				tmpdir,
-			),
-			stagedsync.StageTranspileCfg(
-				db,
-				cfg.BatchSize,
-				controlServer.ChainConfig,
-			),
-			stagedsync.StageSnapshotStateCfg(db, cfg.Snapshot, tmpdir, client, snapshotMigrator),
-			stagedsync.StageHashStateCfg(db, tmpdir),
-			stagedsync.StageTrieCfg(db, true, true, tmpdir),
-			stagedsync.StageHistoryCfg(db, cfg.Prune, tmpdir),
-			stagedsync.StageLogIndexCfg(db, cfg.Prune, tmpdir),
-			stagedsync.StageCallTracesCfg(db, cfg.Prune, 0, tmpdir),
-			stagedsync.StageTxLookupCfg(db, cfg.Prune, tmpdir),
-			stagedsync.StageTxPoolCfg(db, txPool, func() {
-				for i := range txPoolServer.Sentries {
-					go func(i int) {
-						txpool.RecvTxMessageLoop(ctx, txPoolServer.Sentries[i], controlServer, txPoolServer.HandleInboundMessage, nil)
-					}(i)
-					go func(i int) {
-						txpool.RecvPeersLoop(ctx, txPoolServer.Sentries[i], controlServer, txPoolServer.RecentPeers, nil)
-					}(i)
-				}
-				txPoolServer.TxFetcher.Start()
-			}),
-			stagedsync.StageFinishCfg(db, tmpdir, client, snapshotMigrator, logger),
-			false, /* test */
+			cfg.BatchSize,
+			cfg.Commit,
+			nil,
+			controlServer.ChainConfig,
+			controlServer.Engine,
+			&vm.Config{EnableTEMV: cfg.Prune.Experiments.TEVM},
+			accumulator,
+			stateCache,
+			profiler,
+			cfg.StateStream,
+			cfg.NoAddressActivity,
+			tmpdir,
+		),
+		stagedsync.StageTranspileCfg(
+			db,
+			cfg.BatchSize,
+			controlServer.ChainConfig,
 		),
+		stagedsync.StageSnapshotStateCfg(db, cfg.Snapshot, tmpdir, client, snapshotMigrator),
+		stagedsync.StageHashStateCfg(db, tmpdir),
+		stagedsync.StageTrieCfg(db, true, true, tmpdir),
+		stagedsync.StageCrossCheckCfg(db, trusted, cfg.CrossCheck.Every, cfg.CrossCheck.Halt),
+		stagedsync.StageHistoryCfg(db, cfg.Prune, tmpdir),
+		stagedsync.StageHistoryFilesCfg(db, cfg.HistoryFiles.Keep, tmpdir),
+		stagedsync.StageLogIndexCfg(db, cfg.Prune, tmpdir),
+		stagedsync.StageLogArchiveCfg(db, logArchive, cfg.LogArchive.Keep),
+		stagedsync.StageCallTracesCfg(db, cfg.Prune, 0, tmpdir),
+		stagedsync.StageTxLookupCfg(db, cfg.Prune, tmpdir),
+		stagedsync.StageFeeSeriesCfg(db),
+		stagedsync.StageDataGasStatsCfg(db, controlServer.ChainConfig),
+		stagedsync.StageTxPoolCfg(db, txPool, func() {
+			for i := range txPoolServer.Sentries {
+				go func(i int) {
+					txpool.RecvTxMessageLoop(ctx, txPoolServer.Sentries[i], controlServer, txPoolServer.HandleInboundMessage, nil)
+				}(i)
+				go func(i int) {
+					txpool.RecvPeersLoop(ctx, txPoolServer.Sentries[i], controlServer, txPoolServer.RecentPeers, nil)
+				}(i)
+			}
+			txPoolServer.TxFetcher.Start()
+		}),
+		stagedsync.StageFinishCfg(db, tmpdir, client, snapshotMigrator, logger),
+		false, /* test */
+	)
+	if cfg.SyncSource.Path != "" {
+		source, err := blocksource.Open(cfg.SyncSource.Path)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			<-ctx.Done()
+			source.Close()
+		}()
+		log.Info("Taking headers and bodies from local source", "path", cfg.SyncSource.Path, "to", cfg.SyncSource.To)
+		stagesList = stagedsync.UseLocalSource(ctx, stagesList, stagedsync.StageLocalSourceCfg(db, source, cfg.SyncSource.To))
+	}
+	return stagedsync.New(
+		stagesList,
 		stagedsync.DefaultUnwindOrder,
 		stagedsync.DefaultPruneOrder,
 	), nil
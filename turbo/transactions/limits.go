@@ -0,0 +1,17 @@
+package transactions
+
+import "github.com/ledgerwatch/erigon/core/vm"
+
+// EVMLimits are limits of EVM execution initiated by RPC (eth_call, traces), independent of the consensus rules,
+// so that a single request can't make the daemon allocate gigabytes of EVM memory
+type EVMLimits struct {
+	MaxMemory    uint64 // total memory of all call frames, in bytes, 0 - no limit
+	MaxCallDepth int    // 0 - consensus limit
+}
+
+// Apply sets the limits in the EVM config
+func (l EVMLimits) Apply(cfg vm.Config) vm.Config {
+	cfg.MaxMemory = l.MaxMemory
+	cfg.MaxCallDepth = l.MaxCallDepth
+	return cfg
+}
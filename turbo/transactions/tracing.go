@@ -20,6 +20,7 @@ import (
 	"github.com/ledgerwatch/erigon/core/vm/stack"
 	"github.com/ledgerwatch/erigon/eth/tracers"
 	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
 )
 
 type BlockGetter interface {
@@ -81,6 +82,7 @@ func TraceTx(
 	ibs vm.IntraBlockState,
 	config *tracers.TraceConfig,
 	chainConfig *params.ChainConfig,
+	limits EVMLimits,
 	stream *jsoniter.Stream,
 ) error {
 	// Assemble the structured logger or the JavaScript tracer
@@ -88,6 +90,11 @@ func TraceTx(
 		tracer vm.Tracer
 		err    error
 	)
+	frames, err := startFrames(ctx, config)
+	if err != nil {
+		stream.WriteNil()
+		return err
+	}
 	var streaming bool
 	switch {
 	case config != nil && config.Tracer != nil:
@@ -114,37 +121,45 @@ func TraceTx(
 		streaming = false
 
 	case config == nil:
-		tracer = NewJsonStreamLogger(nil, ctx, stream)
+		tracer = NewJsonStreamLogger(nil, ctx, stream).withFrames(frames)
 		streaming = true
 
 	default:
-		tracer = NewJsonStreamLogger(config.LogConfig, ctx, stream)
+		tracer = NewJsonStreamLogger(config.LogConfig, ctx, stream).withFrames(frames)
 		streaming = true
 	}
 	// Run the transaction with tracing enabled.
-	vmenv := vm.NewEVM(blockCtx, txCtx, ibs, chainConfig, vm.Config{Debug: true, Tracer: tracer})
+	vmenv := vm.NewEVM(blockCtx, txCtx, ibs, chainConfig, limits.Apply(vm.Config{Debug: true, Tracer: tracer}))
 
 	var refunds bool = true
 	if config != nil && config.NoRefunds != nil && *config.NoRefunds {
 		refunds = false
 	}
-	if streaming {
+	if streaming && frames == nil {
 		stream.WriteObjectStart()
 		stream.WriteObjectField("structLogs")
 		stream.WriteArrayStart()
 	}
 	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()), refunds, false /* gasBailout */)
 	if err != nil {
-		if streaming {
+		if streaming && frames == nil {
 			stream.WriteArrayEnd()
 			stream.WriteObjectEnd()
 		}
 		return fmt.Errorf("tracing failed: %v", err)
 	}
+	if frames != nil {
+		// struct logs are already sent as frames, the last frame is the result
+		stream = jsoniter.NewStream(jsoniter.ConfigDefault, nil, 256)
+	}
 	// Depending on the tracer type, format and return the output
 	if streaming {
-		stream.WriteArrayEnd()
-		stream.WriteMore()
+		if frames == nil {
+			stream.WriteArrayEnd()
+			stream.WriteMore()
+		} else {
+			stream.WriteObjectStart()
+		}
 		stream.WriteObjectField("gas")
 		stream.WriteUint64(result.UsedGas)
 		stream.WriteMore()
@@ -166,9 +181,37 @@ func TraceTx(
 			return err1
 		}
 	}
+	if frames != nil {
+		return frames.WriteFrame(stream.Buffer())
+	}
 	return nil
 }
 
+// startFrames switches the response to frames, if the client asked for them by the Format of the config
+// or by the Accept header. Returns nil if the trace is a part of the usual JSON-RPC response.
+func startFrames(ctx context.Context, config *tracers.TraceConfig) (*rpc.FrameWriter, error) {
+	format := rpc.AcceptedStreamFormat(ctx)
+	if config != nil && config.Format != nil {
+		var err error
+		if format, err = rpc.ParseStreamFormat(*config.Format); err != nil {
+			return nil, err
+		}
+	}
+	if format == rpc.FormatJSON {
+		return nil, nil
+	}
+	return rpc.StartFrames(ctx, format)
+}
+
+// StreamsFrames returns true if the trace is sent as frames instead of the usual JSON-RPC response
+func StreamsFrames(ctx context.Context, config *tracers.TraceConfig) bool {
+	if config != nil && config.Format != nil {
+		format, err := rpc.ParseStreamFormat(*config.Format)
+		return err != nil || format != rpc.FormatJSON
+	}
+	return rpc.AcceptedStreamFormat(ctx) != rpc.FormatJSON
+}
+
 // StructLogger is an EVM state logger and implements Tracer.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
@@ -179,6 +222,7 @@ type JsonStreamLogger struct {
 	cfg          vm.LogConfig
 	stream       *jsoniter.Stream
 	firstCapture bool
+	frames       *rpc.FrameWriter // struct logs are sent as frames, if not nil
 
 	locations common.Hashes // For sorting
 	storage   map[common.Address]vm.Storage
@@ -201,6 +245,15 @@ func NewJsonStreamLogger(cfg *vm.LogConfig, ctx context.Context, stream *jsonite
 	return logger
 }
 
+// withFrames makes the logger send every struct log as a separate frame
+func (l *JsonStreamLogger) withFrames(frames *rpc.FrameWriter) *JsonStreamLogger {
+	if frames != nil {
+		l.frames = frames
+		l.stream = jsoniter.NewStream(jsoniter.ConfigDefault, nil, 4096) // buffer of the current frame
+	}
+	return l
+}
+
 // CaptureStart implements the Tracer interface to initialize the tracing operation.
 func (l *JsonStreamLogger) CaptureStart(depth int, from common.Address, to common.Address, precompile bool, create bool, calltype vm.CallType, input []byte, gas uint64, value *big.Int, codeHash common.Hash) error {
 	return nil
@@ -219,7 +272,9 @@ func (l *JsonStreamLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, ga
 	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
 		return vm.ErrTraceLimitReached
 	}
-	if !l.firstCapture {
+	if l.frames != nil {
+		l.stream.SetBuffer(l.stream.Buffer()[:0])
+	} else if !l.firstCapture {
 		l.stream.WriteMore()
 	} else {
 		l.firstCapture = false
@@ -324,6 +379,9 @@ func (l *JsonStreamLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, ga
 		l.stream.WriteObjectEnd()
 	}
 	l.stream.WriteObjectEnd()
+	if l.frames != nil {
+		return l.frames.WriteFrame(l.stream.Buffer())
+	}
 	return l.stream.Flush()
 }
 
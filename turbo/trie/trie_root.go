@@ -97,6 +97,9 @@ type RootHashAggregator struct {
 	wasIH          bool
 	wasIHStorage   bool
 	root           common.Hash
+	rd             RetainDecider // if set, trie nodes on the paths to the retained keys are constructed instead of hashed
+	rootNode       node          // root of the constructed trie nodes, when `rd` is set
+	retainBuf      []byte
 	hc             HashCollector2
 	shc            StorageHashCollector2
 	currStorage    bytes.Buffer // Current key for the structure generation algorithm, as well as the input tape for the hash builder
@@ -312,6 +315,23 @@ func (l *FlatDBTrieLoader) CalcTrieRoot(tx kv.Tx, prefix []byte, quit <-chan str
 	return l.receiver.Root(), nil
 }
 
+// CalcProofTrie works like CalcTrieRoot for the whole state, but additionally constructs all trie nodes
+// on the paths to the keys retained by the RetainDecider given to Reset, so that Merkle proofs
+// for these keys can be taken from the returned trie. Keys of storage items need to be in the format
+// {addrHash}{incarnation}{keyHash}, as for the storage part of the intermediate trie hashes.
+func (l *FlatDBTrieLoader) CalcProofTrie(tx kv.Tx, quit <-chan struct{}) (*Trie, error) {
+	l.receiver = l.defaultReceiver
+	l.defaultReceiver.rd = l.rd
+	defer func() { l.defaultReceiver.rd = nil }()
+	root, err := l.CalcTrieRoot(tx, nil, quit)
+	if err != nil {
+		return nil, err
+	}
+	t := New(root)
+	t.root = l.defaultReceiver.rootNode
+	return t, nil
+}
+
 func (l *FlatDBTrieLoader) logProgress(accountKey, ihK []byte) {
 	var k string
 	if accountKey != nil {
@@ -326,6 +346,21 @@ func (r *RootHashAggregator) RetainNothing(_ []byte) bool {
 	return false
 }
 
+func (r *RootHashAggregator) retainAccount(prefix []byte) bool {
+	return r.rd != nil && r.rd.Retain(prefix)
+}
+
+// retainStorage prepends the prefix of the storage trie with the account and incarnation,
+// to make it comparable with the retained keys
+func (r *RootHashAggregator) retainStorage(prefix []byte) bool {
+	if r.rd == nil {
+		return false
+	}
+	hexutil.DecompressNibbles(r.currAccK, &r.retainBuf)
+	r.retainBuf = append(r.retainBuf, prefix...)
+	return r.rd.Retain(r.retainBuf)
+}
+
 func (r *RootHashAggregator) Reset(hc HashCollector2, shc StorageHashCollector2, trace bool) {
 	r.hc = hc
 	r.shc = shc
@@ -343,6 +378,7 @@ func (r *RootHashAggregator) Reset(hc HashCollector2, shc StorageHashCollector2,
 	r.valueStorage = nil
 	r.wasIHStorage = false
 	r.root = common.Hash{}
+	r.rootNode = nil
 	r.trace = trace
 	r.hb.trace = trace
 }
@@ -492,6 +528,9 @@ func (r *RootHashAggregator) Receive(itemType StreamItem,
 		}
 		if r.hb.hasRoot() {
 			r.root = r.hb.rootHash()
+			if r.rd != nil {
+				r.rootNode = r.hb.root()
+			}
 		} else {
 			r.root = EmptyRoot
 		}
@@ -562,7 +601,7 @@ func (r *RootHashAggregator) genStructStorage() error {
 		r.leafData.Value = rlphacks.RlpSerializableBytes(r.valueStorage)
 		data = &r.leafData
 	}
-	r.groupsStorage, r.hasTreeStorage, r.hasHashStorage, err = GenStructStep(r.RetainNothing, r.currStorage.Bytes(), r.succStorage.Bytes(), r.hb, func(keyHex []byte, hasState, hasTree, hasHash uint16, hashes, rootHash []byte) error {
+	r.groupsStorage, r.hasTreeStorage, r.hasHashStorage, err = GenStructStep(r.retainStorage, r.currStorage.Bytes(), r.succStorage.Bytes(), r.hb, func(keyHex []byte, hasState, hasTree, hasHash uint16, hashes, rootHash []byte) error {
 		if r.shc == nil {
 			return nil
 		}
@@ -630,7 +669,7 @@ func (r *RootHashAggregator) genStructAccount() error {
 	r.currStorage.Reset()
 	r.succStorage.Reset()
 	var err error
-	if r.groups, r.hasTree, r.hasHash, err = GenStructStep(r.RetainNothing, r.curr.Bytes(), r.succ.Bytes(), r.hb, func(keyHex []byte, hasState, hasTree, hasHash uint16, hashes, rootHash []byte) error {
+	if r.groups, r.hasTree, r.hasHash, err = GenStructStep(r.retainAccount, r.curr.Bytes(), r.succ.Bytes(), r.hb, func(keyHex []byte, hasState, hasTree, hasHash uint16, hashes, rootHash []byte) error {
 		if r.hc == nil {
 			return nil
 		}